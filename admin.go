@@ -0,0 +1,153 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/multierr"
+	"strings"
+)
+
+const (
+	// AdminSessionsPath is the URI path of the server sessions. A delete command to '/sessions/<sessionId>' finishes
+	// the session with the Reason present in the command resource, if any.
+	AdminSessionsPath = "/sessions"
+	// AdminBroadcastPath is the URI path for broadcasting announcements. A set command to '/broadcast' sends the
+	// command resource as a message to all the established sessions.
+	AdminBroadcastPath = "/broadcast"
+)
+
+// ErrSessionNotFound is returned when the specified session is not established in the server.
+var ErrSessionNotFound = errors.New("session not found")
+
+// AdminACL determines if the node of a session can execute the administration commands.
+type AdminACL func(ctx context.Context, node Node) bool
+
+// AdminIdentities creates an AdminACL which allows only the specified identities, regardless of the instance.
+func AdminIdentities(identities ...Identity) AdminACL {
+	allowed := make(map[Identity]struct{}, len(identities))
+	for _, i := range identities {
+		allowed[i] = struct{}{}
+	}
+	return func(_ context.Context, node Node) bool {
+		_, ok := allowed[node.Identity]
+		return ok
+	}
+}
+
+// DisconnectSession fails the specified established session, sending the reason to the remote node.
+func (srv *Server) DisconnectSession(ctx context.Context, sessionID string, reason *Reason) error {
+	c, ok := srv.session(sessionID)
+	if !ok {
+		return fmt.Errorf("disconnect session: %w", ErrSessionNotFound)
+	}
+	if reason == nil {
+		reason = NewReason(ReasonCodeSessionError, "The session was finished by the server")
+	}
+	if err := c.FailSession(ctx, reason); err != nil {
+		return fmt.Errorf("disconnect session: %w", err)
+	}
+	return nil
+}
+
+// Broadcast sends a copy of the message to the remote node of each established session.
+// It returns the number of sessions that the message was sent to, and the errors of the sessions which the sending
+// failed.
+func (srv *Server) Broadcast(ctx context.Context, msg *Message) (int, error) {
+	if msg == nil {
+		panic("nil message")
+	}
+
+	var sent int
+	var errs []error
+	for _, c := range srv.establishedSessions() {
+		m := *msg
+		if m.From == (Node{}) {
+			m.From = c.localNode
+		}
+		m.To = c.remoteNode
+		if err := c.SendMessage(ctx, &m); err != nil {
+			errs = append(errs, fmt.Errorf("broadcast to %v: %w", c.sessionID, err))
+			continue
+		}
+		sent++
+	}
+	return sent, multierr.Combine(errs...)
+}
+
+// adminCommandHandler handles the administration commands of the sessions allowed by the ACL.
+type adminCommandHandler struct {
+	srv *Server
+	acl AdminACL
+}
+
+func (h *adminCommandHandler) Match(cmd *RequestCommand) bool {
+	if cmd.URI == nil || !h.addressedToServer(cmd.To) {
+		return false
+	}
+	switch cmd.Method {
+	case CommandMethodDelete:
+		return strings.HasPrefix(cmd.URI.Path(), AdminSessionsPath+"/")
+	case CommandMethodSet:
+		return cmd.URI.Path() == AdminBroadcastPath
+	}
+	return false
+}
+
+// addressedToServer indicates if the command destination is the server node. The commands without a destination are
+// addressed to the server, while the other ones are left to the application handlers.
+func (h *adminCommandHandler) addressedToServer(to Node) bool {
+	if to == (Node{}) {
+		return true
+	}
+	node := h.srv.currentConfig().Node
+	return to.Identity == node.Identity && (to.Instance == "" || to.Instance == node.Instance)
+}
+
+func (h *adminCommandHandler) Handle(ctx context.Context, cmd *RequestCommand, s Sender) error {
+	// The session node is used instead of the command sender, which is defined by the client
+	node, _ := ContextSessionRemoteNode(ctx)
+	if !h.acl(ctx, node) {
+		AuditAuthorizationDenied(ctx, cmd.URI.Path(), "the node is not an administrator")
+		return s.SendResponseCommand(ctx, cmd.FailureResponse(UnauthorizedReason("The node is not authorized to execute the command")))
+	}
+
+	if cmd.Method == CommandMethodDelete {
+		return h.disconnectSession(ctx, cmd, s)
+	}
+	return h.broadcast(ctx, cmd, s)
+}
+
+func (h *adminCommandHandler) disconnectSession(ctx context.Context, cmd *RequestCommand, s Sender) error {
+	sessionID := strings.TrimPrefix(cmd.URI.Path(), AdminSessionsPath+"/")
+	reason, _ := cmd.Resource.(*Reason)
+
+	if current, _ := ContextSessionID(ctx); current == sessionID {
+		// The response must be sent before the current session is finished
+		if err := s.SendResponseCommand(ctx, cmd.SuccessResponse()); err != nil {
+			return err
+		}
+		_ = h.srv.DisconnectSession(ctx, sessionID, reason)
+		return nil
+	}
+
+	if err := h.srv.DisconnectSession(ctx, sessionID, reason); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return s.SendResponseCommand(ctx, cmd.FailureResponse(NotFoundReason(err.Error())))
+		}
+		return s.SendResponseCommand(ctx, cmd.FailureResponse(NewReason(ReasonCodeCommandProcessingError, err.Error())))
+	}
+	return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+}
+
+func (h *adminCommandHandler) broadcast(ctx context.Context, cmd *RequestCommand, s Sender) error {
+	if cmd.Resource == nil {
+		return s.SendResponseCommand(ctx, cmd.FailureResponse(InvalidArgumentReason("The announcement content is required")))
+	}
+
+	msg := &Message{}
+	msg.SetContent(cmd.Resource)
+	// The partial failures are ignored, since the sessions could be finishing
+	_, _ = h.srv.Broadcast(ctx, msg)
+	return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+}
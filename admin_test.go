@@ -0,0 +1,185 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func startAdminServer(t *testing.T, addr InProcessAddr, auditor Auditor) *Server {
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		AdminCommands(AdminIdentities(Identity{Name: "admin", Domain: "localhost"})).
+		Auditor(auditor).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	return srv
+}
+
+func establishInProcGuestSession(t *testing.T, ctx context.Context, addr InProcessAddr, name string) *ClientChannel {
+	client, err := DialInProcess(addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := NewClientChannel(client, 1)
+	ses, err := channel.EstablishSession(
+		ctx,
+		func([]SessionCompression) SessionCompression {
+			return SessionCompressionNone
+		},
+		func([]SessionEncryption) SessionEncryption {
+			return SessionEncryptionNone
+		},
+		Identity{Name: name, Domain: "localhost"},
+		func([]AuthenticationScheme, Authentication) Authentication {
+			return &GuestAuthentication{}
+		},
+		"default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, SessionStateEstablished, ses.State)
+	return channel
+}
+
+func TestServer_AdminCommands_DisconnectSession(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("admin-disconnect")
+	srv := startAdminServer(t, addr, &auditRecorder{})
+	defer silentClose(srv)
+	admin := establishInProcGuestSession(t, ctx, addr, "admin")
+	defer silentClose(admin)
+	user := establishInProcGuestSession(t, ctx, addr, "user")
+	defer silentClose(user)
+	reason := &Reason{Code: 42, Description: "Maintenance"}
+	cmd := &RequestCommand{}
+	cmd.SetURIString(AdminSessionsPath + "/" + user.sessionID).
+		SetMethod(CommandMethodDelete).
+		SetResource(reason)
+	cmd.ID = "disconnect-1"
+
+	// Act
+	respCmd, err := admin.ProcessCommand(ctx, cmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "session finish timeout")
+	case <-user.RcvDone():
+	}
+	assert.Equal(t, SessionStateFailed, user.State())
+	assert.Eventually(t, func() bool {
+		_, ok := srv.session(user.sessionID)
+		return !ok
+	}, 100*time.Millisecond, 5*time.Millisecond)
+}
+
+func TestServer_AdminCommands_Broadcast(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("admin-broadcast")
+	srv := startAdminServer(t, addr, &auditRecorder{})
+	defer silentClose(srv)
+	admin := establishInProcGuestSession(t, ctx, addr, "admin")
+	defer silentClose(admin)
+	user := establishInProcGuestSession(t, ctx, addr, "user")
+	defer silentClose(user)
+	announcement := TextDocument("The server will restart in 5 minutes")
+	cmd := &RequestCommand{}
+	cmd.SetURIString(AdminBroadcastPath).
+		SetMethod(CommandMethodSet).
+		SetResource(&announcement)
+	cmd.ID = "broadcast-1"
+
+	// Act
+	respCmd, err := admin.ProcessCommand(ctx, cmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	for _, c := range []*ClientChannel{admin, user} {
+		select {
+		case <-ctx.Done():
+			assert.FailNow(t, "receive message timeout")
+		case msg := <-c.MsgChan():
+			assert.Equal(t, MediaTypeTextPlain(), msg.Type)
+			assert.Equal(t, &announcement, msg.Content)
+			assert.Equal(t, c.remoteNode, msg.From)
+		}
+	}
+}
+
+func TestServer_AdminCommands_Denied(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("admin-denied")
+	recorder := &auditRecorder{}
+	srv := startAdminServer(t, addr, recorder)
+	defer silentClose(srv)
+	user := establishInProcGuestSession(t, ctx, addr, "user")
+	defer silentClose(user)
+	cmd := &RequestCommand{}
+	cmd.SetURIString(AdminSessionsPath + "/" + user.sessionID).
+		SetMethod(CommandMethodDelete)
+	cmd.ID = "disconnect-1"
+
+	// Act
+	respCmd, err := user.ProcessCommand(ctx, cmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusFailure, respCmd.Status)
+	assert.True(t, user.Established())
+	assert.Contains(t, recorder.types(), AuditEventAuthorizationDenied)
+}
+
+func TestAdminCommandHandler_Match_Destination(t *testing.T) {
+	// Arrange
+	server := Node{Identity: Identity{Name: "postmaster", Domain: "localhost"}, Instance: "server1"}
+	h := &adminCommandHandler{srv: &Server{config: &ServerConfig{Node: server}}}
+	cases := []struct {
+		to   Node
+		want bool
+	}{
+		{Node{}, true},
+		{server, true},
+		{Node{Identity: server.Identity}, true},
+		{Node{Identity: server.Identity, Instance: "server2"}, false},
+		{Node{Identity: Identity{Name: "user", Domain: "localhost"}}, false},
+	}
+
+	for _, c := range cases {
+		cmd := &RequestCommand{}
+		cmd.SetURIString(AdminBroadcastPath).SetMethod(CommandMethodSet)
+		cmd.To = c.to
+
+		// Act
+		actual := h.Match(cmd)
+
+		// Assert
+		assert.Equal(t, c.want, actual, "to %v", c.to)
+	}
+}
+
+func TestNewServer_AdminCommands_SharedMux(t *testing.T) {
+	// Arrange
+	config := NewServerConfig()
+	config.AdminACL = AdminIdentities(Identity{Name: "admin", Domain: "localhost"})
+	mux := &EnvelopeMux{}
+
+	// Act
+	srv := NewServer(config, mux, NewBoundListener(NewInProcessTransportListener("admin-shared-mux"), InProcessAddr("admin-shared-mux")))
+
+	// Assert
+	assert.Empty(t, mux.reqCmdHandlers)
+	assert.Len(t, srv.mux.reqCmdHandlers, 1)
+}
@@ -0,0 +1,43 @@
+package lime
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Annotate attaches a local value to the envelope, like an authorization decision or a routing hint, which can be
+// read by the next modules of the pipeline. Unlike the Metadata, the annotations are never serialized: the channel
+// clears them before sending the envelope.
+// As with the context values, the key should be comparable and of an unexported type, to avoid collisions between
+// packages.
+func (env *Envelope) Annotate(key, value any) *Envelope {
+	if key == nil {
+		panic("nil key")
+	}
+	if !reflect.TypeOf(key).Comparable() {
+		panic(fmt.Errorf("key of type %T is not comparable", key))
+	}
+	if env.annotations == nil {
+		env.annotations = make(map[any]any)
+	}
+	env.annotations[key] = value
+	return env
+}
+
+// Annotation returns the value attached to the envelope for the key.
+func (env *Envelope) Annotation(key any) (any, bool) {
+	v, ok := env.annotations[key]
+	return v, ok
+}
+
+// RemoveAnnotation removes the value attached to the envelope for the key.
+func (env *Envelope) RemoveAnnotation(key any) *Envelope {
+	delete(env.annotations, key)
+	return env
+}
+
+// ClearAnnotations removes all the values attached to the envelope.
+func (env *Envelope) ClearAnnotations() *Envelope {
+	env.annotations = nil
+	return env
+}
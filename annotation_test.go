@@ -0,0 +1,150 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type annotationKey string
+
+func TestEnvelope_Annotate(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+
+	// Act
+	msg.Annotate(annotationKey("route"), "shard-1")
+	v, ok := msg.Annotation(annotationKey("route"))
+	_, ok2 := msg.Annotation("route")
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, "shard-1", v)
+	assert.False(t, ok2)
+}
+
+func TestEnvelope_Annotate_NotComparable(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+
+	// Act / Assert
+	assert.Panics(t, func() {
+		msg.Annotate([]string{"route"}, "shard-1")
+	})
+}
+
+func TestEnvelope_RemoveAnnotation(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.Annotate(annotationKey("route"), "shard-1")
+	msg.Annotate(annotationKey("authorized"), true)
+
+	// Act
+	msg.RemoveAnnotation(annotationKey("route"))
+
+	// Assert
+	_, ok1 := msg.Annotation(annotationKey("route"))
+	_, ok2 := msg.Annotation(annotationKey("authorized"))
+	assert.False(t, ok1)
+	assert.True(t, ok2)
+}
+
+func TestEnvelope_Annotate_NotSerialized(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	expected, _ := json.Marshal(msg)
+	msg.Annotate(annotationKey("route"), "shard-1")
+
+	// Act
+	actual, err := json.Marshal(msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestMessage_Clone_Annotations(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.Annotate(annotationKey("route"), "shard-1")
+
+	// Act
+	c, err := msg.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Annotate(annotationKey("route"), "shard-2")
+
+	// Assert
+	v1, _ := msg.Annotation(annotationKey("route"))
+	v2, _ := c.Annotation(annotationKey("route"))
+	assert.Equal(t, "shard-1", v1)
+	assert.Equal(t, "shard-2", v2)
+}
+
+func TestChannel_SendMessage_ClearsAnnotations(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	msg := createMessage()
+	msg.Annotate(annotationKey("route"), "shard-1")
+
+	// Act
+	err := c.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	actual, err := client.Receive(ctx)
+	assert.NoError(t, err)
+	if assert.IsType(t, &Message{}, actual) {
+		_, ok := actual.(*Message).Annotation(annotationKey("route"))
+		assert.False(t, ok)
+	}
+}
+
+func TestEnvelopeMux_Annotations(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	routes := make(chan any, 1)
+	mux := &EnvelopeMux{}
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool {
+			msg.Annotate(annotationKey("route"), "shard-1")
+			return false
+		},
+		func(ctx context.Context, msg *Message, s Sender) error {
+			return nil
+		})
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			route, _ := msg.Annotation(annotationKey("route"))
+			routes <- route
+			return nil
+		})
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+
+	// Act
+	_ = client.Send(ctx, createMessage())
+
+	// Assert
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case route := <-routes:
+		assert.Equal(t, "shard-1", route)
+	}
+}
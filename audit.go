@@ -0,0 +1,161 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditEventType defines the type of the security events emitted by the server.
+type AuditEventType string
+
+const (
+	// AuditEventAuthenticationSucceeded is emitted when a client identity is authenticated.
+	AuditEventAuthenticationSucceeded = AuditEventType("authentication.succeeded")
+	// AuditEventAuthenticationFailed is emitted when the authentication of a client identity is refused or fails.
+	AuditEventAuthenticationFailed = AuditEventType("authentication.failed")
+	// AuditEventSessionEstablished is emitted when a client session is established.
+	AuditEventSessionEstablished = AuditEventType("session.established")
+	// AuditEventSessionFailed is emitted when the establishment of a client session fails.
+	AuditEventSessionFailed = AuditEventType("session.failed")
+	// AuditEventSessionFinished is emitted when an established client session is finished.
+	AuditEventSessionFinished = AuditEventType("session.finished")
+	// AuditEventAuthorizationDenied is emitted when an operation requested by a client is denied.
+	AuditEventAuthorizationDenied = AuditEventType("authorization.denied")
+)
+
+// AuditEvent is a structured record of a security relevant event in a server session.
+type AuditEvent struct {
+	Type       AuditEventType       `json:"type"`                 // Type is the event type.
+	Timestamp  time.Time            `json:"timestamp"`            // Timestamp is the moment when the event occurred.
+	SessionID  string               `json:"sessionId,omitempty"`  // SessionID is the id of the session.
+	Identity   Identity             `json:"identity,omitempty"`   // Identity is the client identity, if known.
+	Node       Node                 `json:"node,omitempty"`       // Node is the client node address, after the session establishment.
+	RemoteAddr string               `json:"remoteAddr,omitempty"` // RemoteAddr is the client transport address.
+	Scheme     AuthenticationScheme `json:"scheme,omitempty"`     // Scheme is the authentication scheme used by the client.
+	Role       DomainRole           `json:"role,omitempty"`       // Role is the client role in the server domain, after the authentication.
+	Resource   string               `json:"resource,omitempty"`   // Resource is the target of a denied operation, like a command URI.
+	Reason     string               `json:"reason,omitempty"`     // Reason describes the cause of a failure or denial.
+}
+
+// Auditor receives the security events emitted by the server.
+// The implementations should be safe for concurrent use, since the events of distinct sessions are emitted in
+// parallel.
+type Auditor interface {
+	Audit(ctx context.Context, event AuditEvent)
+}
+
+// AuditorFunc is an adapter to allow the use of functions as Auditor.
+type AuditorFunc func(ctx context.Context, event AuditEvent)
+
+func (f AuditorFunc) Audit(ctx context.Context, event AuditEvent) {
+	f(ctx, event)
+}
+
+// JSONAuditor writes the audit events to an io.Writer as JSON lines, which can be a file or a pipe to a log
+// collector.
+type JSONAuditor struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditor creates a JSONAuditor that writes to the specified writer.
+func NewJSONAuditor(w io.Writer) *JSONAuditor {
+	if w == nil {
+		panic("nil writer")
+	}
+	return &JSONAuditor{enc: json.NewEncoder(w)}
+}
+
+func (a *JSONAuditor) Audit(_ context.Context, event AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// The audit should not interfere with the session, so the write errors are ignored
+	_ = a.enc.Encode(event)
+}
+
+var contextKeyAuditor = contextKey("auditor")
+
+// contextAuditor is the auditor of the session and the clock for the timestamps of its events.
+type contextAuditor struct {
+	auditor Auditor
+	clock   Clock
+}
+
+// withAuditor adds the auditor to the context used by the session envelope handlers.
+func withAuditor(ctx context.Context, a Auditor, clock Clock) context.Context {
+	return context.WithValue(ctx, contextKeyAuditor, contextAuditor{auditor: a, clock: clock})
+}
+
+// AuditAuthorizationDenied emits an AuditEventAuthorizationDenied event for the session in the context, which
+// should be called by the envelope handlers when refusing a client request.
+// It does nothing if the server has no Auditor.
+func AuditAuthorizationDenied(ctx context.Context, resource string, reason string) {
+	a, ok := ctx.Value(contextKeyAuditor).(contextAuditor)
+	if !ok || a.auditor == nil {
+		return
+	}
+	event := AuditEvent{
+		Type:      AuditEventAuthorizationDenied,
+		Timestamp: a.clock.Now(),
+		Resource:  resource,
+		Reason:    reason,
+	}
+	event.SessionID, _ = ContextSessionID(ctx)
+	event.Node, _ = ContextSessionRemoteNode(ctx)
+	event.Identity = event.Node.Identity
+	a.auditor.Audit(ctx, event)
+}
+
+// auditAuthenticate wraps the authentication function for emitting the results of the authentication attempts.
+func auditAuthenticate(
+	a Auditor,
+	c *ServerChannel,
+	authenticate func(context.Context, Identity, Authentication) (*AuthenticationResult, error),
+) func(context.Context, Identity, Authentication) (*AuthenticationResult, error) {
+	return func(ctx context.Context, identity Identity, authentication Authentication) (*AuthenticationResult, error) {
+		result, err := authenticate(ctx, identity, authentication)
+
+		event := c.auditEvent(AuditEventAuthenticationFailed)
+		event.Identity = identity
+		if authentication != nil {
+			event.Scheme = authentication.GetAuthenticationScheme()
+		}
+
+		switch {
+		case err != nil:
+			event.Reason = err.Error()
+		case result == nil:
+			event.Reason = "empty authentication result"
+		case result.Role != "" && result.Role != DomainRoleUnknown:
+			event.Type = AuditEventAuthenticationSucceeded
+			event.Role = result.Role
+		case result.RoundTrip != nil:
+			// The authentication is not completed yet
+			return result, err
+		default:
+			event.Reason = "the credentials were refused"
+		}
+
+		a.Audit(ctx, event)
+		return result, err
+	}
+}
+
+// auditEvent creates an event with the session information of the channel.
+func (c *ServerChannel) auditEvent(t AuditEventType) AuditEvent {
+	event := AuditEvent{
+		Type:      t,
+		Timestamp: c.clock.Now(),
+		SessionID: c.sessionID,
+		Node:      c.remoteNode,
+		Identity:  c.remoteNode.Identity,
+	}
+	if addr := c.transport.RemoteAddr(); addr != nil {
+		event.RemoteAddr = fmt.Sprint(addr)
+	}
+	return event
+}
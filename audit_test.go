@@ -0,0 +1,167 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"sync"
+	"testing"
+	"time"
+)
+
+type auditRecorder struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (r *auditRecorder) Audit(_ context.Context, event AuditEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *auditRecorder) types() []AuditEventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []AuditEventType
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestServer_ListenAndServe_Audit(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr1 := InProcessAddr("localhost")
+	config := NewServerConfig()
+	config.SchemeOpts = []AuthenticationScheme{AuthenticationSchemeGuest}
+	recorder := &auditRecorder{}
+	config.Auditor = recorder
+	srv := NewServer(config, &EnvelopeMux{}, createBoundInProcTransportListener(addr1))
+	startServer(t, srv)
+	client, _ := DialInProcess(addr1, 1)
+	defer silentClose(client)
+	channel := NewClientChannel(client, 1)
+	defer silentClose(channel)
+
+	// Act
+	ses, err := channel.EstablishSession(
+		ctx,
+		func([]SessionCompression) SessionCompression {
+			return SessionCompressionNone
+		},
+		func([]SessionEncryption) SessionEncryption {
+			return SessionEncryptionNone
+		},
+		Identity{
+			Name:   "client1",
+			Domain: "localhost",
+		},
+		func([]AuthenticationScheme, Authentication) Authentication {
+			return &GuestAuthentication{}
+		},
+		"default")
+	_ = srv.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStateEstablished, ses.State)
+	expected := []AuditEventType{AuditEventAuthenticationSucceeded, AuditEventSessionEstablished, AuditEventSessionFinished}
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(expected, recorder.types())
+	}, 100*time.Millisecond, 5*time.Millisecond)
+	assert.Equal(t, "client1", recorder.events[0].Identity.Name)
+	assert.Equal(t, AuthenticationSchemeGuest, recorder.events[0].Scheme)
+	assert.Equal(t, ses.ID, recorder.events[1].SessionID)
+	assert.Equal(t, ses.To, recorder.events[1].Node)
+}
+
+func TestAuditAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   *AuthenticationResult
+		err      error
+		expected []AuditEventType
+	}{
+		{"Succeeded", MemberAuthenticationResult(), nil, []AuditEventType{AuditEventAuthenticationSucceeded}},
+		{"Refused", UnknownAuthenticationResult(), nil, []AuditEventType{AuditEventAuthenticationFailed}},
+		{"Error", nil, errors.New("database unavailable"), []AuditEventType{AuditEventAuthenticationFailed}},
+		{"RoundTrip", &AuthenticationResult{RoundTrip: &GuestAuthentication{}}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			client, server := newInProcessTransportPair("localhost", 1)
+			defer silentClose(client)
+			c := NewServerChannel(server, 1, NewServerConfig().Node, "session1")
+			recorder := &auditRecorder{}
+			authenticate := auditAuthenticate(recorder, c, func(context.Context, Identity, Authentication) (*AuthenticationResult, error) {
+				return tt.result, tt.err
+			})
+
+			// Act
+			_, _ = authenticate(context.Background(), Identity{Name: "golang", Domain: "localhost"}, &PlainAuthentication{})
+
+			// Assert
+			assert.Equal(t, tt.expected, recorder.types())
+			for _, e := range recorder.events {
+				assert.Equal(t, "session1", e.SessionID)
+				assert.Equal(t, AuthenticationSchemePlain, e.Scheme)
+			}
+		})
+	}
+}
+
+func TestAuditAuthorizationDenied(t *testing.T) {
+	// Arrange
+	recorder := &auditRecorder{}
+	client, server := newInProcessTransportPair("localhost", 1)
+	defer silentClose(client)
+	c := NewServerChannel(server, 1, NewServerConfig().Node, "session1")
+	c.remoteNode = Node{Identity: Identity{Name: "golang", Domain: "localhost"}, Instance: "home"}
+	ctx := withAuditor(sessionContext(context.Background(), c.channel), recorder, SystemClock)
+
+	// Act
+	AuditAuthorizationDenied(ctx, "/accounts/admin", "insufficient role")
+	AuditAuthorizationDenied(context.Background(), "/ignored", "no auditor")
+
+	// Assert
+	if assert.Len(t, recorder.events, 1) {
+		e := recorder.events[0]
+		assert.Equal(t, AuditEventAuthorizationDenied, e.Type)
+		assert.Equal(t, "session1", e.SessionID)
+		assert.Equal(t, c.remoteNode, e.Node)
+		assert.Equal(t, "/accounts/admin", e.Resource)
+		assert.Equal(t, "insufficient role", e.Reason)
+	}
+}
+
+func TestJSONAuditor_Audit(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	a := NewJSONAuditor(&buf)
+	event := AuditEvent{
+		Type:      AuditEventSessionEstablished,
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		SessionID: "session1",
+	}
+
+	// Act
+	a.Audit(context.Background(), event)
+	a.Audit(context.Background(), event)
+
+	// Assert
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+	var actual map[string]any
+	assert.NoError(t, json.Unmarshal(lines[0], &actual))
+	assert.Equal(t, "session.established", actual["type"])
+	assert.Equal(t, "session1", actual["sessionId"])
+	assert.Equal(t, "2024-01-02T03:04:05Z", actual["timestamp"])
+}
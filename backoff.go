@@ -0,0 +1,106 @@
+package lime
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BackoffStrategy defines the intervals between the attempts of an operation, like the client reconnections, the
+// webhook delivery retries and the message retransmissions.
+type BackoffStrategy interface {
+	// Backoff returns the interval before the retry, which starts at 0 for the first retry after the initial
+	// attempt. The prev value is the interval returned for the previous retry, or 0 for the first.
+	Backoff(retry int, prev time.Duration) time.Duration
+}
+
+// BackoffFunc is an adapter to allow the use of ordinary functions as a BackoffStrategy.
+type BackoffFunc func(retry int, prev time.Duration) time.Duration
+
+func (f BackoffFunc) Backoff(retry int, prev time.Duration) time.Duration {
+	return f(retry, prev)
+}
+
+// ExponentialBackoff multiplies the interval on each retry, starting with the Initial value.
+type ExponentialBackoff struct {
+	Initial    time.Duration // Initial is the interval before the first retry.
+	Max        time.Duration // Max is the maximum interval. If zero, the interval is not limited.
+	Multiplier float64       // Multiplier is the growth factor of the interval. If zero, 2 is used.
+}
+
+func (b ExponentialBackoff) Backoff(retry int, _ time.Duration) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	interval := float64(b.Initial) * math.Pow(multiplier, float64(retry))
+	return capBackoff(interval, b.Max)
+}
+
+// LinearBackoff increases the interval by the Step value on each retry, starting with the Initial value.
+type LinearBackoff struct {
+	Initial time.Duration // Initial is the interval before the first retry.
+	Step    time.Duration // Step is the increment of the interval on each retry.
+	Max     time.Duration // Max is the maximum interval. If zero, the interval is not limited.
+}
+
+func (b LinearBackoff) Backoff(retry int, _ time.Duration) time.Duration {
+	interval := float64(b.Initial) + float64(b.Step)*float64(retry)
+	return capBackoff(interval, b.Max)
+}
+
+// DecorrelatedJitterBackoff picks a random interval between the base and three times the previous interval, which
+// spreads the retries of many clients failing at the same time, like after a server restart.
+// It is safe for concurrent use.
+type DecorrelatedJitterBackoff struct {
+	base time.Duration
+	max  time.Duration
+	rnd  *rand.Rand
+	mu   sync.Mutex
+}
+
+// NewDecorrelatedJitterBackoff creates a DecorrelatedJitterBackoff with the minimum and maximum intervals. If max
+// is zero, the interval is not limited.
+func NewDecorrelatedJitterBackoff(base, max time.Duration) *DecorrelatedJitterBackoff {
+	return NewDecorrelatedJitterBackoffWithSeed(base, max, time.Now().UnixNano())
+}
+
+// NewDecorrelatedJitterBackoffWithSeed is like NewDecorrelatedJitterBackoff, with a seed for reproducing the
+// sequence of intervals.
+func NewDecorrelatedJitterBackoffWithSeed(base, max time.Duration, seed int64) *DecorrelatedJitterBackoff {
+	if base <= 0 {
+		panic("the base interval should be positive")
+	}
+	return &DecorrelatedJitterBackoff{
+		base: base,
+		max:  max,
+		rnd:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (b *DecorrelatedJitterBackoff) Backoff(_ int, prev time.Duration) time.Duration {
+	upper := 3 * prev
+	if upper <= b.base {
+		return capBackoff(float64(b.base), b.max)
+	}
+	b.mu.Lock()
+	interval := b.base + time.Duration(b.rnd.Int63n(int64(upper-b.base)))
+	b.mu.Unlock()
+	return capBackoff(float64(interval), b.max)
+}
+
+func capBackoff(interval float64, max time.Duration) time.Duration {
+	if max > 0 && interval > float64(max) {
+		return max
+	}
+	if interval > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(interval)
+}
+
+// quadraticBackoff is the default interval between the client reconnections.
+var quadraticBackoff = BackoffFunc(func(retry int, _ time.Duration) time.Duration {
+	return time.Duration(retry*retry) * 100 * time.Millisecond
+})
@@ -0,0 +1,112 @@
+package lime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func backoffSequence(s BackoffStrategy, n int) []time.Duration {
+	intervals := make([]time.Duration, n)
+	var prev time.Duration
+	for i := range intervals {
+		prev = s.Backoff(i, prev)
+		intervals[i] = prev
+	}
+	return intervals
+}
+
+func TestExponentialBackoff_Backoff(t *testing.T) {
+	// Arrange
+	s := ExponentialBackoff{Initial: 100 * time.Millisecond, Max: time.Second}
+
+	// Act
+	actual := backoffSequence(s, 6)
+
+	// Assert
+	assert.Equal(t, []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}, actual)
+}
+
+func TestExponentialBackoff_Backoff_Multiplier(t *testing.T) {
+	// Arrange
+	s := ExponentialBackoff{Initial: time.Second, Multiplier: 1.5}
+
+	// Act
+	actual := backoffSequence(s, 3)
+
+	// Assert
+	assert.Equal(t, []time.Duration{time.Second, 1500 * time.Millisecond, 2250 * time.Millisecond}, actual)
+}
+
+func TestExponentialBackoff_Backoff_Overflow(t *testing.T) {
+	// Arrange
+	s := ExponentialBackoff{Initial: time.Second}
+
+	// Act
+	actual := s.Backoff(1000, 0)
+
+	// Assert
+	assert.Greater(t, actual, time.Duration(0))
+}
+
+func TestLinearBackoff_Backoff(t *testing.T) {
+	// Arrange
+	s := LinearBackoff{Initial: time.Second, Step: 500 * time.Millisecond, Max: 2 * time.Second}
+
+	// Act
+	actual := backoffSequence(s, 4)
+
+	// Assert
+	assert.Equal(t, []time.Duration{
+		time.Second,
+		1500 * time.Millisecond,
+		2 * time.Second,
+		2 * time.Second,
+	}, actual)
+}
+
+func TestDecorrelatedJitterBackoff_Backoff(t *testing.T) {
+	// Arrange
+	base := 100 * time.Millisecond
+	max := 5 * time.Second
+	s := NewDecorrelatedJitterBackoffWithSeed(base, max, 42)
+
+	// Act
+	actual := backoffSequence(s, 50)
+
+	// Assert
+	assert.Equal(t, base, actual[0])
+	for i := 1; i < len(actual); i++ {
+		assert.GreaterOrEqual(t, actual[i], base)
+		assert.LessOrEqual(t, actual[i], max)
+		assert.LessOrEqual(t, actual[i], 3*actual[i-1])
+	}
+}
+
+func TestDecorrelatedJitterBackoff_Backoff_Seed(t *testing.T) {
+	// Arrange
+	s1 := NewDecorrelatedJitterBackoffWithSeed(time.Millisecond, time.Second, 7)
+	s2 := NewDecorrelatedJitterBackoffWithSeed(time.Millisecond, time.Second, 7)
+
+	// Act
+	actual1 := backoffSequence(s1, 10)
+	actual2 := backoffSequence(s2, 10)
+
+	// Assert
+	assert.Equal(t, actual1, actual2)
+}
+
+func TestQuadraticBackoff_Backoff(t *testing.T) {
+	// Act
+	actual := backoffSequence(quadraticBackoff, 4)
+
+	// Assert
+	assert.Equal(t, []time.Duration{0, 100 * time.Millisecond, 400 * time.Millisecond, 900 * time.Millisecond}, actual)
+}
@@ -0,0 +1,65 @@
+package lime
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BinaryDocument represents arbitrary bytes with their declared media type, like an image/png content, which are
+// encoded in base64 in the envelopes. It is the document of the received contents and resources whose media type is
+// of a binary family, like image/* or application/pdf, and has no registered factory. See RegisterBinaryMediaType.
+type BinaryDocument struct {
+	Type MediaType // Type is the media type of the data.
+	Data []byte    // Data is the document bytes.
+}
+
+// NewBinaryDocument creates a BinaryDocument with the media type and data.
+func NewBinaryDocument(t MediaType, data []byte) *BinaryDocument {
+	return &BinaryDocument{Type: t, Data: data}
+}
+
+func (d *BinaryDocument) MediaType() MediaType {
+	return d.Type
+}
+
+// MarshalJSON encodes the data as a base64 string.
+func (d *BinaryDocument) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Data)
+}
+
+// UnmarshalJSON decodes the data from a base64 string.
+func (d *BinaryDocument) UnmarshalJSON(b []byte) error {
+	var data []byte
+	if err := json.Unmarshal(b, &data); err != nil {
+		return fmt.Errorf("binary document: %w", err)
+	}
+	d.Data = data
+	return nil
+}
+
+// binaryMediaTypes are the media types decoded as BinaryDocument, where the '*' subtype matches any subtype of the type.
+var binaryMediaTypes = map[MediaType]bool{
+	{Type: "image", Subtype: "*"}:                         true,
+	{Type: "audio", Subtype: "*"}:                         true,
+	{Type: "video", Subtype: "*"}:                         true,
+	{Type: "font", Subtype: "*"}:                          true,
+	{Type: MediaTypeApplication, Subtype: "octet-stream"}: true,
+	{Type: MediaTypeApplication, Subtype: "pdf"}:          true,
+	{Type: MediaTypeApplication, Subtype: "zip"}:          true,
+	{Type: MediaTypeApplication, Subtype: "gzip"}:         true,
+}
+
+// RegisterBinaryMediaType defines a media type, or a type family with the '*' subtype, whose contents and resources
+// without a registered factory are decoded as BinaryDocument. The other media types without a factory are decoded as
+// TextDocument, except the JSON ones.
+func RegisterBinaryMediaType(t MediaType) {
+	binaryMediaTypes[t] = true
+}
+
+// isBinaryMediaType indicates if the documents of the media type without a registered factory are BinaryDocument.
+func isBinaryMediaType(t MediaType) bool {
+	if t.IsJson() {
+		return false
+	}
+	return binaryMediaTypes[t] || binaryMediaTypes[MediaType{Type: t.Type, Subtype: "*"}]
+}
@@ -0,0 +1,92 @@
+package lime
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessage_MarshalJSON_BinaryDocument(t *testing.T) {
+	// Arrange
+	m := Message{}
+	m.ID = "4609d0a3-00eb-4e16-9d44-27d115c6eb31"
+	m.SetContent(NewBinaryDocument(MediaType{Type: "image", Subtype: "png"}, []byte{0x89, 'P', 'N', 'G'}))
+
+	// Act
+	b, err := json.Marshal(&m)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","type":"image/png","content":"iVBORw=="}`, string(b))
+}
+
+func TestMessage_UnmarshalJSON_BinaryDocument(t *testing.T) {
+	// Arrange
+	j := []byte(`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","type":"image/png","content":"iVBORw=="}`)
+	var m Message
+
+	// Act
+	err := json.Unmarshal(j, &m)
+
+	// Assert
+	assert.NoError(t, err)
+	d, ok := m.Content.(*BinaryDocument)
+	if assert.True(t, ok) {
+		assert.Equal(t, MediaType{Type: "image", Subtype: "png"}, d.MediaType())
+		assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, d.Data)
+	}
+}
+
+func TestMessage_UnmarshalJSON_BinaryDocumentInvalid(t *testing.T) {
+	// Arrange
+	j := []byte(`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","type":"image/png","content":"not base64!"}`)
+	var m Message
+
+	// Act
+	err := json.Unmarshal(j, &m)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestGetDocumentFactory_TextFallback(t *testing.T) {
+	// Arrange
+	mediaType := MediaType{Type: "text", Subtype: "html"}
+
+	// Act
+	factory, err := GetDocumentFactory(mediaType)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.IsType(t, new(TextDocument), factory())
+}
+
+func TestMessage_UnmarshalJSON_UnknownTypeText(t *testing.T) {
+	// Arrange
+	j := []byte(`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","type":"application/xml","content":"<a>hi</a>"}`)
+	var m Message
+
+	// Act
+	err := json.Unmarshal(j, &m)
+
+	// Assert
+	assert.NoError(t, err)
+	d, ok := m.Content.(*TextDocument)
+	if assert.True(t, ok) {
+		assert.Equal(t, TextDocument("<a>hi</a>"), *d)
+	}
+}
+
+func TestRegisterBinaryMediaType(t *testing.T) {
+	// Arrange
+	mediaType := MediaType{Type: MediaTypeApplication, Subtype: "x-lime-test-binary"}
+	RegisterBinaryMediaType(mediaType)
+	defer delete(binaryMediaTypes, mediaType)
+
+	// Act
+	factory, err := GetDocumentFactory(mediaType)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.IsType(t, new(BinaryDocument), factory())
+}
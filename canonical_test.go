@@ -0,0 +1,57 @@
+package lime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCanonicalizeJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"SortedKeys", `{"b":1,"a":{"d":true,"c":null}}`, `{"a":{"c":null,"d":true},"b":1}`},
+		{"Whitespace", " [ 1 , \"a b\" , { } ] \n", `[1,"a b",{}]`},
+		{"UTF16KeyOrder", `{"😀":1,"ﬁ":2}`, "{\"\U0001f600\":1,\"ﬁ\":2}"},
+		{"Numbers", `[1.0,-0,1e21,1E-7,0.000001,123.456e2,100]`, `[1,0,1e+21,1e-7,0.000001,12345.6,100]`},
+		{"Escapes", `"<tag> & é   \"\\ \/ \u0001\t"`, "\"<tag> & é   \\\"\\\\ / \\u0001\\t\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := CanonicalizeJSON([]byte(tt.input))
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(actual))
+		})
+	}
+}
+
+func TestCanonicalizeJSON_Invalid(t *testing.T) {
+	// Act
+	_, err1 := CanonicalizeJSON([]byte(`{"a":`))
+	_, err2 := CanonicalizeJSON([]byte(`{} {}`))
+
+	// Assert
+	assert.Error(t, err1)
+	assert.Error(t, err2)
+}
+
+func TestMarshalCanonical_Message(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.SetMetadataKeyValue("z", "<last>")
+	msg.SetMetadataKeyValue("a", "first")
+
+	// Act
+	actual, err := MarshalCanonical(msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`{"content":"Hello world","id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","metadata":{"a":"first","z":"<last>"},`+
+			`"to":"golang@limeprotocol.org/default","type":"text/plain"}`,
+		string(actual))
+}
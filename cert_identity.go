@@ -0,0 +1,209 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrCertificateRevoked is returned by the RevocationChecker functions for the revoked certificates.
+var ErrCertificateRevoked = errors.New("certificate revoked")
+
+// CertificateMapper maps the client certificates of the TLS sessions to the identities that they authenticate, for
+// the transport authentication scheme. See ServerBuilder.EnableCertificateAuthentication.
+type CertificateMapper interface {
+	// MapCertificate returns the identity of the certificate and its role in the identity domain, or false if the
+	// certificate is not mapped.
+	MapCertificate(ctx context.Context, cert *x509.Certificate) (Identity, DomainRole, bool)
+}
+
+// RevocationChecker checks if a client certificate was revoked by its issuer, like by consulting the CRL or the OCSP
+// responder of the CA, returning ErrCertificateRevoked if it was. The chain is the verified chain of the certificate,
+// starting with it.
+type RevocationChecker func(ctx context.Context, cert *x509.Certificate, chain []*x509.Certificate) error
+
+// CertificateRegistry is a CertificateMapper of the certificates registered by their fingerprint or subject
+// alternative names, like the certificates issued by an internal CA:
+//
+//	r := lime.NewCertificateRegistry()
+//	r.AddFingerprint("9f86d081884c7d65...", lime.Identity{Name: "billing", Domain: "msging.net"}, lime.DomainRoleMember)
+//	r.AddSAN("spiffe://msging.net/reports", lime.Identity{Name: "reports", Domain: "msging.net"}, lime.DomainRoleMember)
+//
+// The fingerprint mappings take precedence over the SAN ones. The SANs are the DNS names, email addresses, IP
+// addresses and URIs of the certificates.
+type CertificateRegistry struct {
+	mu           sync.RWMutex
+	fingerprints map[string]certificateIdentity
+	sans         map[string]certificateIdentity
+}
+
+type certificateIdentity struct {
+	identity Identity
+	role     DomainRole
+}
+
+// NewCertificateRegistry creates an empty CertificateRegistry.
+func NewCertificateRegistry() *CertificateRegistry {
+	return &CertificateRegistry{
+		fingerprints: make(map[string]certificateIdentity),
+		sans:         make(map[string]certificateIdentity),
+	}
+}
+
+// AddFingerprint maps the certificate with the SHA-256 fingerprint to the identity, replacing the previous mapping.
+// The fingerprint is hex encoded, optionally with colons, like the output of the openssl x509 -fingerprint command.
+func (r *CertificateRegistry) AddFingerprint(fingerprint string, identity Identity, role DomainRole) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fingerprints[normalizeFingerprint(fingerprint)] = certificateIdentity{identity: identity, role: role}
+}
+
+// AddSAN maps the certificates with the subject alternative name to the identity, replacing the previous mapping.
+func (r *CertificateRegistry) AddSAN(san string, identity Identity, role DomainRole) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sans[san] = certificateIdentity{identity: identity, role: role}
+}
+
+// RemoveFingerprint deletes the mapping of the fingerprint.
+func (r *CertificateRegistry) RemoveFingerprint(fingerprint string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.fingerprints, normalizeFingerprint(fingerprint))
+}
+
+// RemoveSAN deletes the mapping of the subject alternative name.
+func (r *CertificateRegistry) RemoveSAN(san string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sans, san)
+}
+
+func (r *CertificateRegistry) MapCertificate(_ context.Context, cert *x509.Certificate) (Identity, DomainRole, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if m, ok := r.fingerprints[CertificateFingerprint(cert)]; ok {
+		return m.identity, m.role, true
+	}
+	for _, san := range certificateSANs(cert) {
+		if m, ok := r.sans[san]; ok {
+			return m.identity, m.role, true
+		}
+	}
+	return Identity{}, "", false
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of the certificate, hex encoded in lower case.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+}
+
+func certificateSANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.IPAddresses)+len(cert.URIs))
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+// CRLRevocationChecker creates a RevocationChecker for the certificate revocation lists, which should be verified by
+// the caller and replaced when the issuers publish new ones. The certificates issued by a CA without a list are
+// considered valid, while an expired list fails the check.
+func CRLRevocationChecker(crls ...*x509.RevocationList) RevocationChecker {
+	return CRLRevocationCheckerWithClock(SystemClock, crls...)
+}
+
+// CRLRevocationCheckerWithClock creates a CRLRevocationChecker that checks the expiration of the lists with the
+// clock. If the clock is nil, the SystemClock is used.
+func CRLRevocationCheckerWithClock(clock Clock, crls ...*x509.RevocationList) RevocationChecker {
+	clock = clockOrSystem(clock)
+	return func(_ context.Context, cert *x509.Certificate, _ []*x509.Certificate) error {
+		for _, crl := range crls {
+			if !crlIssued(crl, cert) {
+				continue
+			}
+			if !crl.NextUpdate.IsZero() && clock.Now().After(crl.NextUpdate) {
+				return fmt.Errorf("the revocation list of %v is expired", crl.Issuer)
+			}
+			for _, entry := range crl.RevokedCertificateEntries {
+				if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return ErrCertificateRevoked
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// crlIssued indicates if the revocation list is from the issuer of the certificate, comparing the authority key IDs
+// if defined, since the CAs can share the subject name.
+func crlIssued(crl *x509.RevocationList, cert *x509.Certificate) bool {
+	if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+		return false
+	}
+	if len(crl.AuthorityKeyId) != 0 && len(cert.AuthorityKeyId) != 0 {
+		return bytes.Equal(crl.AuthorityKeyId, cert.AuthorityKeyId)
+	}
+	return true
+}
+
+// ContextTLSConnectionState gets the state of the TLS connection of the session from the context, with the client
+// certificates. It is defined while authenticating the sessions of the encrypted transports.
+func ContextTLSConnectionState(ctx context.Context) (tls.ConnectionState, bool) {
+	state, ok := ctx.Value(contextKeyTLSConnectionState).(tls.ConnectionState)
+	return state, ok
+}
+
+// tlsStateContext adds the TLS connection state of the channel transport to the context, if it is encrypted.
+func tlsStateContext(ctx context.Context, c *channel) context.Context {
+	if state, ok := c.TLSConnectionState(); ok {
+		return context.WithValue(ctx, contextKeyTLSConnectionState, state)
+	}
+	return ctx
+}
+
+// certificateAuthenticator authenticates the transport sessions by their client certificates.
+type certificateAuthenticator struct {
+	mapper  CertificateMapper
+	checker RevocationChecker
+}
+
+func (a *certificateAuthenticator) authenticate(ctx context.Context, identity Identity) (*AuthenticationResult, error) {
+	state, ok := ContextTLSConnectionState(ctx)
+	// The certificates that were not verified against the CAs of the listener are not trusted, since the client
+	// could present any certificate with the SANs of another identity
+	if !ok || len(state.PeerCertificates) == 0 || len(state.VerifiedChains) == 0 {
+		return UnknownAuthenticationResult(), nil
+	}
+	cert := state.PeerCertificates[0]
+
+	if a.checker != nil {
+		// The certificates are refused if their status cannot be determined
+		if err := a.checker(ctx, cert, state.VerifiedChains[0]); err != nil {
+			return UnknownAuthenticationResult(), nil
+		}
+	}
+
+	mapped, role, ok := a.mapper.MapCertificate(ctx, cert)
+	if !ok || mapped != identity {
+		return UnknownAuthenticationResult(), nil
+	}
+	return &AuthenticationResult{Role: role}, nil
+}
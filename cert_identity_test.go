@@ -0,0 +1,346 @@
+package lime
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createCertificateAuthority(t testing.TB) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Acme Internal CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func createClientCertificate(t testing.TB, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64, san string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if u, err := url.Parse(san); err == nil && u.Scheme != "" {
+		template.URIs = []*url.URL{u}
+	} else if strings.Contains(san, "@") {
+		template.EmailAddresses = []string{san}
+	} else {
+		template.DNSNames = []string{san}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+func createRevocationList(t testing.TB, ca *x509.Certificate, caKey *ecdsa.PrivateKey, nextUpdate time.Time, serials ...int64) *x509.RevocationList {
+	template := x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, s := range serials {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(s),
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &template, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+func TestCertificateRegistry_MapCertificate_Fingerprint(t *testing.T) {
+	// Arrange
+	ca, caKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, ca, caKey, 2, "billing.msging.net")
+	r := NewCertificateRegistry()
+	identity := Identity{Name: "billing", Domain: "msging.net"}
+	fingerprint := strings.ToUpper(CertificateFingerprint(cert.Leaf))
+	var colons []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		colons = append(colons, fingerprint[i:i+2])
+	}
+	r.AddFingerprint(strings.Join(colons, ":"), identity, DomainRoleAuthority)
+
+	// Act
+	mapped, role, ok := r.MapCertificate(context.Background(), cert.Leaf)
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, identity, mapped)
+	assert.Equal(t, DomainRoleAuthority, role)
+}
+
+func TestCertificateRegistry_MapCertificate_SAN(t *testing.T) {
+	// Arrange
+	ca, caKey := createCertificateAuthority(t)
+	r := NewCertificateRegistry()
+	r.AddSAN("spiffe://msging.net/reports", Identity{Name: "reports", Domain: "msging.net"}, DomainRoleMember)
+	r.AddSAN("billing@msging.net", Identity{Name: "billing", Domain: "msging.net"}, DomainRoleMember)
+	uriCert := createClientCertificate(t, ca, caKey, 2, "spiffe://msging.net/reports")
+	emailCert := createClientCertificate(t, ca, caKey, 3, "billing@msging.net")
+	unknownCert := createClientCertificate(t, ca, caKey, 4, "unknown.msging.net")
+
+	// Act
+	uriIdentity, _, uriOk := r.MapCertificate(context.Background(), uriCert.Leaf)
+	emailIdentity, _, emailOk := r.MapCertificate(context.Background(), emailCert.Leaf)
+	_, _, unknownOk := r.MapCertificate(context.Background(), unknownCert.Leaf)
+
+	// Assert
+	assert.True(t, uriOk)
+	assert.Equal(t, Identity{Name: "reports", Domain: "msging.net"}, uriIdentity)
+	assert.True(t, emailOk)
+	assert.Equal(t, Identity{Name: "billing", Domain: "msging.net"}, emailIdentity)
+	assert.False(t, unknownOk)
+}
+
+func TestCertificateRegistry_MapCertificate_FingerprintPrecedence(t *testing.T) {
+	// Arrange
+	ca, caKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, ca, caKey, 2, "billing.msging.net")
+	r := NewCertificateRegistry()
+	r.AddSAN("billing.msging.net", Identity{Name: "billing", Domain: "msging.net"}, DomainRoleMember)
+	r.AddFingerprint(CertificateFingerprint(cert.Leaf), Identity{Name: "admin", Domain: "msging.net"}, DomainRoleAuthority)
+
+	// Act
+	mapped, role, ok := r.MapCertificate(context.Background(), cert.Leaf)
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, Identity{Name: "admin", Domain: "msging.net"}, mapped)
+	assert.Equal(t, DomainRoleAuthority, role)
+}
+
+func TestCertificateRegistry_Remove(t *testing.T) {
+	// Arrange
+	ca, caKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, ca, caKey, 2, "billing.msging.net")
+	r := NewCertificateRegistry()
+	r.AddSAN("billing.msging.net", Identity{Name: "billing", Domain: "msging.net"}, DomainRoleMember)
+	r.AddFingerprint(CertificateFingerprint(cert.Leaf), Identity{Name: "admin", Domain: "msging.net"}, DomainRoleAuthority)
+
+	// Act
+	r.RemoveFingerprint(CertificateFingerprint(cert.Leaf))
+	r.RemoveSAN("billing.msging.net")
+
+	// Assert
+	_, _, ok := r.MapCertificate(context.Background(), cert.Leaf)
+	assert.False(t, ok)
+}
+
+func TestCRLRevocationChecker(t *testing.T) {
+	// Arrange
+	ca, caKey := createCertificateAuthority(t)
+	otherCA, otherKey := createCertificateAuthority(t)
+	revoked := createClientCertificate(t, ca, caKey, 2, "revoked.msging.net")
+	valid := createClientCertificate(t, ca, caKey, 3, "valid.msging.net")
+	otherIssuer := createClientCertificate(t, otherCA, otherKey, 2, "other.msging.net")
+	checker := CRLRevocationChecker(createRevocationList(t, ca, caKey, time.Now().Add(time.Hour), 2))
+	ctx := context.Background()
+
+	// Act
+	revokedErr := checker(ctx, revoked.Leaf, nil)
+	validErr := checker(ctx, valid.Leaf, nil)
+	otherErr := checker(ctx, otherIssuer.Leaf, nil)
+
+	// Assert
+	assert.ErrorIs(t, revokedErr, ErrCertificateRevoked)
+	assert.NoError(t, validErr)
+	assert.NoError(t, otherErr)
+}
+
+func TestCRLRevocationChecker_Expired(t *testing.T) {
+	// Arrange
+	ca, caKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, ca, caKey, 3, "valid.msging.net")
+	checker := CRLRevocationChecker(createRevocationList(t, ca, caKey, time.Now().Add(-time.Minute)))
+
+	// Act
+	err := checker(context.Background(), cert.Leaf, nil)
+
+	// Assert
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrCertificateRevoked))
+}
+
+func TestCRLRevocationCheckerWithClock_Expired(t *testing.T) {
+	// Arrange
+	ca, caKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, ca, caKey, 3, "valid.msging.net")
+	crl := createRevocationList(t, ca, caKey, time.Now().Add(time.Hour))
+	checker := CRLRevocationCheckerWithClock(&fixedClock{now: time.Now().Add(2 * time.Hour)}, crl)
+
+	// Act
+	err := checker(context.Background(), cert.Leaf, nil)
+
+	// Assert
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrCertificateRevoked))
+}
+
+// establishCertificateSession establishes a session with a server that trusts the client certificates issued by the
+// CA and authenticates them with the registry, returning the establishment error.
+func establishCertificateSession(t *testing.T, ca *x509.Certificate, cert *tls.Certificate, name string, registry CertificateMapper, checker RevocationChecker, clientAuth tls.ClientAuthType) error {
+	addr := createLocalhostTCPAddress().(*net.TCPAddr)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	server := NewServerBuilder().
+		ListenTCP(addr, &TCPConfig{TLSConfig: &tls.Config{
+			GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return createCertificate("127.0.0.1")
+			},
+			ClientAuth: clientAuth,
+			ClientCAs:  clientCAs,
+		}}).
+		EnableCertificateAuthentication(registry, checker).
+		Build()
+	defer silentClose(server)
+	startServer(t, server)
+	client := NewClientBuilder().
+		UseTCP(addr, &TCPConfig{TLSConfig: &tls.Config{
+			ServerName:         "127.0.0.1",
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{*cert},
+		}}).
+		Name(name).
+		Domain("msging.net").
+		TransportAuthentication().
+		Warmup().
+		Build()
+	defer silentClose(client)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	select {
+	case err := <-client.Ready():
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestServer_EnableCertificateAuthentication(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ca, caKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, ca, caKey, 2, "billing.msging.net")
+	registry := NewCertificateRegistry()
+	registry.AddSAN("billing.msging.net", Identity{Name: "billing", Domain: "msging.net"}, DomainRoleMember)
+
+	// Act
+	err := establishCertificateSession(t, ca, cert, "billing", registry, nil, tls.RequireAndVerifyClientCert)
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestServer_EnableCertificateAuthentication_OtherIdentity(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ca, caKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, ca, caKey, 2, "billing.msging.net")
+	registry := NewCertificateRegistry()
+	registry.AddSAN("billing.msging.net", Identity{Name: "billing", Domain: "msging.net"}, DomainRoleMember)
+
+	// Act
+	err := establishCertificateSession(t, ca, cert, "admin", registry, nil, tls.RequireAndVerifyClientCert)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestServer_EnableCertificateAuthentication_Revoked(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ca, caKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, ca, caKey, 2, "billing.msging.net")
+	registry := NewCertificateRegistry()
+	registry.AddSAN("billing.msging.net", Identity{Name: "billing", Domain: "msging.net"}, DomainRoleMember)
+	checker := CRLRevocationChecker(createRevocationList(t, ca, caKey, time.Now().Add(time.Hour), 2))
+
+	// Act
+	err := establishCertificateSession(t, ca, cert, "billing", registry, checker, tls.RequireAndVerifyClientCert)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestServer_EnableCertificateAuthentication_UnknownCA(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ca, _ := createCertificateAuthority(t)
+	otherCA, otherKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, otherCA, otherKey, 2, "billing.msging.net")
+	registry := NewCertificateRegistry()
+	registry.AddSAN("billing.msging.net", Identity{Name: "billing", Domain: "msging.net"}, DomainRoleMember)
+
+	// Act
+	err := establishCertificateSession(t, ca, cert, "billing", registry, nil, tls.RequireAndVerifyClientCert)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestServer_EnableCertificateAuthentication_NotVerified(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ca, _ := createCertificateAuthority(t)
+	otherCA, otherKey := createCertificateAuthority(t)
+	cert := createClientCertificate(t, otherCA, otherKey, 2, "billing.msging.net")
+	registry := NewCertificateRegistry()
+	registry.AddSAN("billing.msging.net", Identity{Name: "billing", Domain: "msging.net"}, DomainRoleMember)
+
+	// Act
+	err := establishCertificateSession(t, ca, cert, "billing", registry, nil, tls.RequireAnyClientCert)
+
+	// Assert
+	assert.Error(t, err)
+}
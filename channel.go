@@ -7,6 +7,8 @@ import (
 	"log"
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type MessageSender interface {
@@ -81,10 +83,51 @@ type channel struct {
 	startRcv      sync.Once
 	stopRcv       sync.Once
 	rcvDone       chan struct{}
+	rcvErr        error // rcvErr is the cause of the receiver stop, set before closing rcvDone
 	client        bool
-
-	processingCmds   map[string]chan *ResponseCommand
+	codecs        []Codec // codecs are the supported codecs for the session negotiation
+	codec         Codec   // codec is the codec selected by the server during the session establishment
+
+	version        int           // version is the envelope schema version of the channel
+	sessionVersion int           // sessionVersion is the envelope schema version negotiated for the session
+	shims          []VersionShim // shims convert the documents between the versions, sorted by version
+
+	negotiationTracer NegotiationTracer // negotiationTracer receives the session establishment events
+	tap               *Tap              // tap mirrors the envelopes sent and received while established
+	replay            *replayProtection // replay holds the envelope nonces, if the replay protection is enabled
+	contentHashes     bool              // contentHashes indicates if the sent envelopes have the content hash
+	identityMap       *IdentityMap      // identityMap rewrites the identities of the exchanged envelopes
+	sequencing        bool              // sequencing indicates if the received envelopes have sequence numbers
+	lastSequence      uint64            // lastSequence is the sequence number of the last received envelope
+	values            SessionValues     // values is the key/value store of the session
+	culture           atomic.Value      // culture is the default culture of the sent envelopes
+	lastSessionSent   time.Time         // lastSessionSent is the moment of the last sent session, for the round-trip latency
+	failedReason      *Reason           // failedReason is the reason of the sent or received failed session
+	sendWatchdog      *SendWatchdog     // sendWatchdog reports the sends blocked beyond its threshold
+	idPolicy          *EnvelopeIDPolicy // idPolicy defines the checks of the sent envelope IDs
+	sentIDs           *envelopeIDWindow // sentIDs holds the recent sent IDs, if the reuse is checked
+	filters           []*Policy         // filters are the policies that the received envelopes must match
+	features          []Feature         // features are the features advertised to the remote party
+	remoteFeatures    []Feature         // remoteFeatures are the features advertised by the remote party
+	pendingSends      atomic.Int32      // pendingSends is the number of sends in progress, if the watchdog is defined
+	lastSendActivity  atomic.Int64      // lastSendActivity is the moment of the last completed send, in Unix nanoseconds
+
+	// unknownEnvelopeHandler receives the envelopes of unknown types, which are ignored by the receiver.
+	unknownEnvelopeHandler func(ctx context.Context, err *UnknownEnvelopeError)
+
+	// malformedEnvelopeHandler receives the envelopes that could not be decoded, which are skipped by the receiver.
+	malformedEnvelopeHandler func(ctx context.Context, err *MalformedEnvelopeError)
+
+	// sessionHandler handles the sessions received while established, returning false for the ones that stop the
+	// receiver, like the finishing session.
+	sessionHandler func(ctx context.Context, ses *Session) bool
+
+	processingCmds   map[string]*processingCommand
 	processingCmdsMu sync.RWMutex
+	respMatching     CommandResponseMatching // respMatching defines how the responses are correlated with the commands
+
+	replies awaitingReplies // replies are the sent messages waiting for a reply
+	clock   Clock           // clock provides the timestamps of the channel, like the ones of the route hops
 
 	cancel context.CancelFunc // The function for cancelling the listener goroutine
 }
@@ -103,8 +146,9 @@ func newChannel(t Transport, bufferSize int) *channel {
 		inRespCmdChan:    make(chan *ResponseCommand, bufferSize),
 		inSesChan:        make(chan *Session, 1),
 		rcvDone:          make(chan struct{}),
-		processingCmds:   make(map[string]chan *ResponseCommand),
+		processingCmds:   make(map[string]*processingCommand),
 		processingCmdsMu: sync.RWMutex{},
+		clock:            SystemClock,
 	}
 	return &c
 }
@@ -119,7 +163,13 @@ func (c *channel) startReceiver() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancel = cancel
-	go receiveFromTransport(ctx, c, c.rcvDone)
+	role := "server"
+	if c.client {
+		role = "client"
+	}
+	goLabeled(ctx, "channel.receiver", func(ctx context.Context) {
+		receiveFromTransport(ctx, c, c.rcvDone)
+	}, "session", c.sessionID, "role", role)
 }
 
 func (c *channel) stopReceiver() {
@@ -134,6 +184,7 @@ func (c *channel) stopReceiver() {
 
 func (c *channel) setState(state SessionState) {
 	c.setStateWLock(state)
+	c.traceState(state)
 
 	switch state {
 	case SessionStateEstablished:
@@ -170,40 +221,81 @@ func (c *channel) RespCmdChan() <-chan *ResponseCommand {
 }
 
 func receiveFromTransport(ctx context.Context, c *channel, done chan<- struct{}) {
+	var rcvErr error
 	defer func() {
-		close(done)
+		// The loop ends without an error when the session state is changed locally
+		if rcvErr == nil {
+			rcvErr = &SessionEndedError{Session: &Session{State: c.State(), Reason: c.failedReason}}
+		}
+		c.rcvErr = rcvErr
 		close(c.inMsgChan)
 		close(c.inNotChan)
 		close(c.inReqCmdChan)
 		close(c.inRespCmdChan)
 		close(c.inSesChan)
+		close(done)
 	}()
 
-	for c.Established() {
+	// The envelopes already buffered by the transport are drained without checking the session state between them,
+	// since receiving them does not wait for the connection
+	buffered, _ := c.transport.(BufferedTransport)
+	for drain := false; drain || c.Established(); drain = buffered != nil && buffered.Buffered() > 0 {
 		env, err := c.transport.Receive(ctx)
 		if err != nil {
+			var unknownErr *UnknownEnvelopeError
+			if errors.As(err, &unknownErr) {
+				c.handleUnknownEnvelope(ctx, unknownErr)
+				continue
+			}
+			var malformedErr *MalformedEnvelopeError
+			if errors.As(err, &malformedErr) {
+				c.handleMalformedEnvelope(ctx, malformedErr)
+				continue
+			}
 			if ctx.Err() == nil {
 				log.Printf("receiveFromTransport: %v", err)
+				rcvErr = fmt.Errorf("receive: %w", err)
+			} else {
+				rcvErr = ErrChannelClosed
 			}
 			return
 		}
 
+		if !c.verifyNonce(ctx, env) {
+			rcvErr = &SessionEndedError{Session: &Session{State: SessionStateFailed, Reason: c.failedReason}}
+			return
+		}
+		c.mirror(env, TapDirectionReceived)
+
+		env, ok := c.upgradeReceived(ctx, env)
+		if !ok {
+			continue
+		}
+		env = c.mapReceived(env)
+		c.stampSequence(env)
+
 		switch e := env.(type) {
 		case *Message:
+			if !c.replies.listened.Load() && c.replies.trySubmit(e, c.remoteNode) {
+				continue
+			}
 			select {
 			case <-ctx.Done():
+				rcvErr = ErrChannelClosed
 				return
 			case c.inMsgChan <- e:
 			}
 		case *Notification:
 			select {
 			case <-ctx.Done():
+				rcvErr = ErrChannelClosed
 				return
 			case c.inNotChan <- e:
 			}
 		case *RequestCommand:
 			select {
 			case <-ctx.Done():
+				rcvErr = ErrChannelClosed
 				return
 			case c.inReqCmdChan <- e:
 			}
@@ -216,12 +308,17 @@ func receiveFromTransport(ctx context.Context, c *channel, done chan<- struct{})
 				}
 			}
 		case *Session:
+			if c.sessionHandler != nil && c.sessionHandler(ctx, e) {
+				continue
+			}
 			select {
 			case <-ctx.Done():
+				rcvErr = ErrChannelClosed
 				return
 			case c.inSesChan <- e:
 				// If a session is received while established,
 				// the receiver goroutine can stop.
+				rcvErr = &SessionEndedError{Session: e}
 				if c.client {
 					c.setStateWLock(e.State)
 				}
@@ -233,6 +330,40 @@ func receiveFromTransport(ctx context.Context, c *channel, done chan<- struct{})
 	}
 }
 
+// OnUnknownEnvelope defines a handler for the received envelopes whose fields match no known envelope type, like
+// the ones of future protocol extensions. These envelopes are ignored by the receiver, which is not stopped.
+func (c *channel) OnUnknownEnvelope(f func(ctx context.Context, err *UnknownEnvelopeError)) {
+	if err := c.ensureState(SessionStateNew, "set unknown envelope handler"); err != nil {
+		panic(err)
+	}
+	c.unknownEnvelopeHandler = f
+}
+
+func (c *channel) handleUnknownEnvelope(ctx context.Context, err *UnknownEnvelopeError) {
+	if c.unknownEnvelopeHandler == nil {
+		log.Printf("receiveFromTransport: ignoring envelope '%v': %v", err.ID, err)
+		return
+	}
+	c.unknownEnvelopeHandler(sessionContext(ctx, c), err)
+}
+
+// OnMalformedEnvelope defines a handler for the received envelopes that could not be decoded, like the ones with a
+// document that does not match its media type. These envelopes are skipped by the receiver, which is not stopped.
+func (c *channel) OnMalformedEnvelope(f func(ctx context.Context, err *MalformedEnvelopeError)) {
+	if err := c.ensureState(SessionStateNew, "set malformed envelope handler"); err != nil {
+		panic(err)
+	}
+	c.malformedEnvelopeHandler = f
+}
+
+func (c *channel) handleMalformedEnvelope(ctx context.Context, err *MalformedEnvelopeError) {
+	if c.malformedEnvelopeHandler == nil {
+		log.Printf("receiveFromTransport: skipping envelope: %v", err)
+		return
+	}
+	c.malformedEnvelopeHandler(sessionContext(ctx, c), err)
+}
+
 func (c *channel) ID() string {
 	return c.sessionID
 }
@@ -261,12 +392,46 @@ func (c *channel) sendSession(ctx context.Context, ses *Session) error {
 		return fmt.Errorf("send session: cannot do in the %v state", state)
 	}
 
+	c.sendMu.Lock()
 	err := c.transport.Send(ctx, ses)
+	c.sendMu.Unlock()
 	if err != nil {
 		return fmt.Errorf("send session: transport error: %w", err)
 	}
+	c.traceSession(ses, false)
 	return nil
 }
+
+// failFromReceiver fails the session like FailSession, but without waiting for the channel receiver to stop, since
+// it is called by the receiver itself, which finishes after the state change. The clients only close the transport,
+// since the failed session envelope is sent by the server.
+func (c *channel) failFromReceiver(ctx context.Context, reason *Reason) error {
+	var err error
+	if !c.client {
+		ses := Session{
+			Envelope: Envelope{
+				ID:   c.sessionID,
+				From: c.localNode,
+				To:   c.remoteNode,
+			},
+			State:  SessionStateFailed,
+			Reason: reason,
+		}
+		err = c.sendSession(ctx, &ses)
+	}
+
+	c.failedReason = reason
+	c.setStateWLock(SessionStateFailed)
+	c.traceState(SessionStateFailed)
+
+	if err == nil {
+		if err = c.transport.Close(); err != nil {
+			err = fmt.Errorf("closing the transport failed: %w", err)
+		}
+	}
+	return err
+}
+
 func (c *channel) receiveSession(ctx context.Context) (*Session, error) {
 	if ctx == nil {
 		panic("nil context")
@@ -276,6 +441,15 @@ func (c *channel) receiveSession(ctx context.Context) (*Session, error) {
 
 	switch state {
 	case SessionStateFinished:
+		// The receiver goroutine sets the state of the client after buffering the received session, so a finished
+		// session replied before the call, like the response to FinishSession, is still returned instead of failing
+		select {
+		case s, ok := <-c.inSesChan:
+			if ok {
+				return s, nil
+			}
+		default:
+		}
 		return nil, fmt.Errorf("receive session: cannot do in the %v state", state)
 	case SessionStateEstablished:
 		select {
@@ -303,25 +477,50 @@ func (c *channel) receiveSession(ctx context.Context) (*Session, error) {
 		return nil, errors.New("receive session: unexpected envelope type")
 	}
 
+	c.traceSession(ses, true)
 	return ses, nil
 }
 
 func (c *channel) SendMessage(ctx context.Context, msg *Message) error {
+	if msg != nil {
+		c.propagateContext(ctx, &msg.Envelope)
+	}
 	return c.sendToTransport(ctx, msg, "send message")
 }
 
 func (c *channel) SendNotification(ctx context.Context, not *Notification) error {
+	if not != nil {
+		c.propagateContext(ctx, &not.Envelope)
+	}
 	return c.sendToTransport(ctx, not, "send notification")
 }
 
 func (c *channel) SendRequestCommand(ctx context.Context, cmd *RequestCommand) error {
+	if cmd != nil {
+		c.propagateContext(ctx, &cmd.Envelope)
+	}
 	return c.sendToTransport(ctx, cmd, "send request command")
 }
 
 func (c *channel) SendResponseCommand(ctx context.Context, cmd *ResponseCommand) error {
+	if cmd != nil {
+		c.propagateContext(ctx, &cmd.Envelope)
+	}
 	return c.sendToTransport(ctx, cmd, "send response command")
 }
 
+// SetDefaultCulture defines the culture of the envelopes sent by the channel, like 'pt-BR', when neither the
+// envelope nor the context define one.
+func (c *channel) SetDefaultCulture(culture string) {
+	c.culture.Store(culture)
+}
+
+// DefaultCulture returns the default culture of the envelopes sent by the channel.
+func (c *channel) DefaultCulture() string {
+	culture, _ := c.culture.Load().(string)
+	return culture
+}
+
 func (c *channel) ProcessCommand(ctx context.Context, reqCmd *RequestCommand) (*ResponseCommand, error) {
 	return c.processCommand(ctx, c, reqCmd)
 }
@@ -343,13 +542,35 @@ func (c *channel) sendToTransport(ctx context.Context, e envelope, action string
 		return err
 	}
 
+	e, err := c.downgradeEnvelope(c.mapSent(e))
+	if err != nil {
+		return fmt.Errorf("%v: %w", action, err)
+	}
+	e, err = c.stampContentHash(e)
+	if err != nil {
+		return fmt.Errorf("%v: %w", action, err)
+	}
+	if err = c.validateEnvelopeID(e); err != nil {
+		return fmt.Errorf("%v: %w", action, err)
+	}
+
+	done := c.watchSend(envelopeOf(e).ID, action)
 	c.sendMu.Lock()
 	defer c.sendMu.Unlock()
 
-	if err := c.transport.Send(ctx, e); err != nil {
+	if err = c.trackEnvelopeID(ctx, e); err != nil {
+		done(false)
+		return fmt.Errorf("%v: %w", action, err)
+	}
+	e = c.stampNonce(e)
+	envelopeOf(e).ClearAnnotations()
+	err = c.transport.Send(ctx, e)
+	done(err == nil)
+	if err != nil {
 		return fmt.Errorf("%v: %w", action, err)
 	}
 
+	c.mirror(e, TapDirectionSent)
 	return nil
 }
 
@@ -358,15 +579,10 @@ func (c *channel) ensureEstablished(action string) error {
 }
 
 func (c *channel) ensureState(state SessionState, action string) error {
-	if err := c.ensureTransportOK(action); err != nil {
-		return err
+	if s := c.State(); s != state {
+		return newSessionStateError(action, s, state)
 	}
-
-	s := c.State()
-	if s != state {
-		return fmt.Errorf("%v: cannot do in the %v state", action, s)
-	}
-	return nil
+	return c.ensureTransportOK(action)
 }
 
 func (c *channel) ensureTransportOK(action string) error {
@@ -387,6 +603,9 @@ func (c *channel) processCommand(ctx context.Context, sender RequestCommandSende
 	if reqCmd.ID == "" {
 		panic("process command: invalid command id")
 	}
+	if err := c.ensureEstablished("process command"); err != nil {
+		return nil, err
+	}
 
 	c.processingCmdsMu.Lock()
 
@@ -396,7 +615,8 @@ func (c *channel) processCommand(ctx context.Context, sender RequestCommandSende
 	}
 
 	respChan := make(chan *ResponseCommand, 1)
-	c.processingCmds[reqCmd.ID] = respChan
+	progress, _ := ctx.Value(contextKeyCommandProgress).(func(*ResponseCommand))
+	c.processingCmds[reqCmd.ID] = &processingCommand{respChan: respChan, progress: progress, to: reqCmd.To}
 	c.processingCmdsMu.Unlock()
 
 	defer func() {
@@ -424,18 +644,26 @@ func (c *channel) trySubmitCommandResult(respCmd *ResponseCommand) bool {
 	}
 
 	c.processingCmdsMu.RLock()
-	respChan, ok := c.processingCmds[respCmd.ID]
+	processing, ok := c.processingCmds[respCmd.ID]
 	c.processingCmdsMu.RUnlock()
 
-	if !ok {
+	if !ok || !c.matchesCommandResponse(processing, respCmd) {
 		return false
 	}
 
+	// The pending responses are intermediate, so the command keeps waiting for the final one
+	if respCmd.Status == CommandStatusPending {
+		if processing.progress != nil {
+			processing.progress(respCmd)
+		}
+		return true
+	}
+
 	c.processingCmdsMu.Lock()
 	delete(c.processingCmds, respCmd.ID)
 	c.processingCmdsMu.Unlock()
 
-	respChan <- respCmd
+	processing.respChan <- respCmd
 	return true
 }
 
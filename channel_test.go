@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/goleak"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -75,6 +76,108 @@ func TestChannel_SendMessage_WhenEstablished(t *testing.T) {
 	assert.Equal(t, m, actual)
 }
 
+func TestChannel_SendMessage_PropagateConversationID(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	m := createMessage()
+	metadata := map[string]string{"key": "value"}
+	m.Metadata = metadata
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(WithConversationID(ctx, "conversation-1"), m)
+
+	// Assert
+	assert.NoError(t, err)
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "conversation-1", actual.(*Message).ConversationID())
+	assert.Equal(t, "value", actual.(*Message).Metadata["key"])
+	assert.NotContains(t, metadata, MetadataKeyConversationID)
+}
+
+func TestChannel_SendMessage_KeepConversationID(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	m := createMessage()
+	m.SetConversationID("conversation-2")
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(WithConversationID(ctx, "conversation-1"), m)
+
+	// Assert
+	assert.NoError(t, err)
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "conversation-2", actual.(*Message).ConversationID())
+}
+
+func TestChannel_SendMessage_PropagateCulture(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	c.SetDefaultCulture("en-US")
+	m := createMessage()
+	metadata := map[string]string{"key": "value"}
+	m.Metadata = metadata
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(WithCulture(ctx, "pt-BR"), m)
+
+	// Assert
+	assert.NoError(t, err)
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "pt-BR", actual.(*Message).Culture())
+	assert.Equal(t, "value", actual.(*Message).Metadata["key"])
+	assert.NotContains(t, metadata, MetadataKeyCulture)
+}
+
+func TestChannel_SendMessage_DefaultCulture(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 2)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	c.SetDefaultCulture("en-US")
+	m1 := createMessage()
+	m2 := createMessage()
+	m2.SetCulture("es-ES")
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err1 := c.SendMessage(ctx, m1)
+	err2 := c.SendMessage(ctx, m2)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	actual1, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "en-US", actual1.(*Message).Culture())
+	actual2, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "es-ES", actual2.(*Message).Culture())
+}
+
 func TestChannel_SendMessage_Batch(t *testing.T) {
 	// Arrange
 	defer goleak.VerifyNone(t)
@@ -761,3 +864,97 @@ func TestChannel_ProcessCommand_ResponseWithAnotherId(t *testing.T) {
 		assert.Equal(t, respCmd, actualRespCmd)
 	}
 }
+
+// unknownEnvelopeTransport returns an unknown envelope error before the envelopes of the wrapped transport.
+type unknownEnvelopeTransport struct {
+	Transport
+	once bool
+}
+
+func (t *unknownEnvelopeTransport) Receive(ctx context.Context) (envelope, error) {
+	if !t.once {
+		t.once = true
+		raw := rawEnvelope{ID: "99", unknown: []byte(`{"id":"99","stream":{}}`)}
+		return raw.toEnvelope()
+	}
+	return t.Transport.Receive(ctx)
+}
+
+func TestChannel_ReceiveMessage_AfterUnknownEnvelope(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(&unknownEnvelopeTransport{Transport: client}, 1)
+	defer silentClose(c)
+	unknown := make(chan *UnknownEnvelopeError, 1)
+	c.OnUnknownEnvelope(func(ctx context.Context, err *UnknownEnvelopeError) {
+		unknown <- err
+	})
+	c.setState(SessionStateEstablished)
+	m := createMessage()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := server.Send(ctx, m)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case actual := <-c.MsgChan():
+		assert.Equal(t, m, actual)
+	}
+	select {
+	case unknownErr := <-unknown:
+		assert.Equal(t, "99", unknownErr.ID)
+		assert.Equal(t, `{"id":"99","stream":{}}`, string(unknownErr.Raw))
+	default:
+		assert.Fail(t, "unknown envelope not handled")
+	}
+}
+
+// countingBufferedTransport counts the calls to Buffered of the transport.
+type countingBufferedTransport struct {
+	*inProcessTransport
+	calls atomic.Int32
+}
+
+func (t *countingBufferedTransport) Buffered() int {
+	t.calls.Add(1)
+	return t.inProcessTransport.Buffered()
+}
+
+func TestChannel_Receive_DrainsBufferedEnvelopes(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 3)
+	transport := &countingBufferedTransport{inProcessTransport: client}
+	c := newChannel(transport, 3)
+	defer silentClose(c)
+	for _, id := range []string{"1", "2", "3"} {
+		not := createNotification()
+		not.ID = id
+		if err := server.Send(ctx, not); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Act
+	c.setState(SessionStateEstablished)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		case not := <-c.NotChan():
+			ids = append(ids, not.ID)
+		}
+	}
+
+	// Assert
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	assert.GreaterOrEqual(t, transport.calls.Load(), int32(3))
+}
@@ -0,0 +1,129 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ChaosConfig defines the faults injected by a chaos transport.
+// The rates are probabilities between 0 and 1, evaluated on each envelope.
+type ChaosConfig struct {
+	Latency       time.Duration // Latency is the fixed delay added to each sent and received envelope.
+	Jitter        time.Duration // Jitter is the maximum random delay added to the Latency.
+	DropRate      float64       // DropRate is the probability of an envelope being silently discarded.
+	DuplicateRate float64       // DuplicateRate is the probability of an envelope being delivered twice.
+	CloseRate     float64       // CloseRate is the probability of the transport being abruptly closed.
+	Seed          int64         // Seed defines the random source seed. If zero, the current time is used.
+	Clock         Clock         // Clock measures the Latency and Jitter delays. If not defined, the SystemClock is used.
+}
+
+// ErrChaosClosed is returned when a chaos transport abruptly closes the connection.
+var ErrChaosClosed = errors.New("chaos transport: connection abruptly closed")
+
+type chaosTransport struct {
+	Transport
+	ChaosConfig
+	rnd       *rand.Rand
+	rndMu     sync.Mutex
+	pending   envelope // pending is a duplicated envelope to be returned in the next receive
+	pendingMu sync.Mutex
+}
+
+// NewChaosTransport decorates the specified transport with fault injection, allowing the testing of the resilience of
+// the reconnection and deduplication logic.
+func NewChaosTransport(t Transport, config *ChaosConfig) Transport {
+	if t == nil || reflect.ValueOf(t).IsNil() {
+		panic("nil transport")
+	}
+	if config == nil {
+		config = &ChaosConfig{}
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &chaosTransport{
+		Transport:   t,
+		ChaosConfig: *config,
+		rnd:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (t *chaosTransport) Send(ctx context.Context, e envelope) error {
+	if err := t.fault(ctx); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	if t.chance(t.DropRate) {
+		return nil
+	}
+	if err := t.Transport.Send(ctx, e); err != nil {
+		return err
+	}
+	if t.chance(t.DuplicateRate) {
+		return t.Transport.Send(ctx, e)
+	}
+	return nil
+}
+
+func (t *chaosTransport) Receive(ctx context.Context) (envelope, error) {
+	t.pendingMu.Lock()
+	if pending := t.pending; pending != nil {
+		t.pending = nil
+		t.pendingMu.Unlock()
+		return pending, nil
+	}
+	t.pendingMu.Unlock()
+
+	for {
+		e, err := t.Transport.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err = t.fault(ctx); err != nil {
+			return nil, fmt.Errorf("receive: %w", err)
+		}
+		if t.chance(t.DropRate) {
+			continue
+		}
+		if t.chance(t.DuplicateRate) {
+			t.pendingMu.Lock()
+			t.pending = e
+			t.pendingMu.Unlock()
+		}
+		return e, nil
+	}
+}
+
+// fault applies the latency and the abrupt closing faults.
+func (t *chaosTransport) fault(ctx context.Context) error {
+	if t.chance(t.CloseRate) {
+		_ = t.Transport.Close()
+		return ErrChaosClosed
+	}
+
+	delay := t.Latency
+	if t.Jitter > 0 {
+		t.rndMu.Lock()
+		delay += time.Duration(t.rnd.Int63n(int64(t.Jitter)))
+		t.rndMu.Unlock()
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	return sleep(ctx, clockOrSystem(t.Clock), delay)
+}
+
+func (t *chaosTransport) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	t.rndMu.Lock()
+	defer t.rndMu.Unlock()
+	return t.rnd.Float64() < rate
+}
@@ -0,0 +1,87 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+func TestChaosTransport_Send_Latency(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	defer silentClose(client)
+	c := NewChaosTransport(client, &ChaosConfig{Latency: 50 * time.Millisecond})
+	msg := createMessage()
+	start := time.Now()
+
+	// Act
+	err := c.Send(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, actual)
+}
+
+func TestChaosTransport_Send_Drop(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	defer silentClose(client)
+	c := NewChaosTransport(client, &ChaosConfig{DropRate: 1})
+
+	// Act
+	err := c.Send(ctx, createMessage())
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = server.Receive(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestChaosTransport_Receive_Duplicate(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	defer silentClose(client)
+	c := NewChaosTransport(client, &ChaosConfig{DuplicateRate: 1})
+	msg := createMessage()
+	_ = server.Send(ctx, msg)
+
+	// Act
+	actual1, err1 := c.Receive(ctx)
+	actual2, err2 := c.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, msg, actual1)
+	assert.Equal(t, msg, actual2)
+}
+
+func TestChaosTransport_Send_Close(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := NewChaosTransport(client, &ChaosConfig{CloseRate: 1})
+
+	// Act
+	err := c.Send(ctx, createMessage())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrChaosClosed)
+	assert.False(t, c.Connected())
+}
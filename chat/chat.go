@@ -13,10 +13,25 @@ func RegisterChatDocuments() {
 	lime.RegisterDocumentFactory(func() lime.Document {
 		return &Delegation{}
 	})
+	lime.RegisterDocumentFactory(func() lime.Document {
+		return &Group{}
+	})
+	lime.RegisterDocumentFactory(func() lime.Document {
+		return &GroupMember{}
+	})
+	lime.RegisterDocumentFactory(func() lime.Document {
+		return &Input{}
+	})
 	lime.RegisterDocumentFactory(func() lime.Document {
 		return &Presence{}
 	})
+	lime.RegisterDocumentFactory(func() lime.Document {
+		return &InstancePresence{}
+	})
 	lime.RegisterDocumentFactory(func() lime.Document {
 		return &Receipt{}
 	})
+	lime.RegisterDocumentFactory(func() lime.Document {
+		return &Select{}
+	})
 }
@@ -0,0 +1,200 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"github.com/phonero/lime"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Input represents a request for a structured user input.
+// The Label is presented to the user and the Validation rule should be used by the receiving side to check the reply.
+type Input struct {
+	// The text or document presented to the user.
+	Label *lime.DocumentContainer `json:"label,omitempty"`
+	// The validation rule for the user input.
+	Validation *InputValidation `json:"validation,omitempty"`
+}
+
+func MediaTypeInput() lime.MediaType {
+	return lime.MediaType{
+		Type:    "application",
+		Subtype: "vnd.lime.input",
+		Suffix:  "json",
+	}
+}
+
+func (i *Input) MediaType() lime.MediaType {
+	return MediaTypeInput()
+}
+
+// Validate checks if the provided reply document satisfies the input validation rule.
+// If the input doesn't have a validation rule, any reply is considered valid.
+func (i *Input) Validate(reply lime.Document) error {
+	if i.Validation == nil {
+		return nil
+	}
+	return i.Validation.Validate(reply)
+}
+
+// Select represents a text with options to be selected by the user.
+type Select struct {
+	// The text presented to the user before the options.
+	Text string `json:"text,omitempty"`
+	// The options available for selection.
+	Options []SelectOption `json:"options"`
+}
+
+func MediaTypeSelect() lime.MediaType {
+	return lime.MediaType{
+		Type:    "application",
+		Subtype: "vnd.lime.select",
+		Suffix:  "json",
+	}
+}
+
+func (s *Select) MediaType() lime.MediaType {
+	return MediaTypeSelect()
+}
+
+// SelectOption represents an option of a Select document.
+type SelectOption struct {
+	// The order of the option, which can be used by the user to select it, like '1' for the first option.
+	Order int `json:"order,omitempty"`
+	// The text of the option.
+	Text string `json:"text,omitempty"`
+	// The media type of the value, if defined.
+	Type *lime.MediaType `json:"type,omitempty"`
+	// The value to be sent in the reply when the option is selected. If not defined, the reply is the option text.
+	Value interface{} `json:"value,omitempty"`
+}
+
+// InputValidation defines the rules for validating an input reply.
+type InputValidation struct {
+	// The validation rule to be used.
+	Rule InputValidationRule `json:"rule,omitempty"`
+	// The regular expression for the regex validation rule.
+	Regex string `json:"regex,omitempty"`
+	// The media type for the type validation rule.
+	Type *lime.MediaType `json:"type,omitempty"`
+	// The error message to be presented to the user if the reply is invalid.
+	Error string `json:"error,omitempty"`
+}
+
+// Validate checks if the provided reply document satisfies the validation rule.
+// The returned error message uses the Error value, if defined.
+func (v *InputValidation) Validate(reply lime.Document) error {
+	if reply == nil {
+		return v.error(errors.New("reply is required"))
+	}
+
+	if v.Rule == InputValidationRuleType {
+		if v.Type == nil {
+			return errors.New("input validation: type is required for the type rule")
+		}
+		if reply.MediaType() != *v.Type {
+			return v.error(fmt.Errorf("unexpected reply type '%v'", reply.MediaType()))
+		}
+		return nil
+	}
+
+	text, ok := replyText(reply)
+	if !ok {
+		return v.error(fmt.Errorf("unexpected reply type '%v'", reply.MediaType()))
+	}
+	text = strings.TrimSpace(text)
+
+	switch v.Rule {
+	case "", InputValidationRuleText:
+		return nil
+	case InputValidationRuleNumber:
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return v.error(fmt.Errorf("'%v' is not a number", text))
+		}
+	case InputValidationRuleDate:
+		if _, err := ParseInputDate(text); err != nil {
+			return v.error(fmt.Errorf("'%v' is not a date", text))
+		}
+	case InputValidationRuleRegex:
+		re, err := regexp.Compile(v.Regex)
+		if err != nil {
+			return fmt.Errorf("input validation: invalid regex: %w", err)
+		}
+		if !re.MatchString(text) {
+			return v.error(fmt.Errorf("'%v' does not match the expected format", text))
+		}
+	default:
+		return fmt.Errorf("input validation: unknown rule '%v'", v.Rule)
+	}
+
+	return nil
+}
+
+func (v *InputValidation) error(err error) error {
+	if v.Error != "" {
+		return &InputValidationError{Message: v.Error, Err: err}
+	}
+	return &InputValidationError{Message: err.Error(), Err: err}
+}
+
+// InputValidationError is returned when a reply doesn't satisfy an input validation rule.
+// The Message value can be presented to the user.
+type InputValidationError struct {
+	Message string
+	Err     error
+}
+
+func (e *InputValidationError) Error() string {
+	return e.Message
+}
+
+func (e *InputValidationError) Unwrap() error {
+	return e.Err
+}
+
+// InputValidationRule defines the available input validation rules.
+type InputValidationRule string
+
+const (
+	// InputValidationRuleText indicates that any text is accepted.
+	InputValidationRuleText = InputValidationRule("text")
+	// InputValidationRuleNumber indicates that the reply should be a number.
+	InputValidationRuleNumber = InputValidationRule("number")
+	// InputValidationRuleDate indicates that the reply should be a date.
+	InputValidationRuleDate = InputValidationRule("date")
+	// InputValidationRuleRegex indicates that the reply should match the validation regular expression.
+	InputValidationRuleRegex = InputValidationRule("regex")
+	// InputValidationRuleType indicates that the reply document should have the validation media type.
+	InputValidationRuleType = InputValidationRule("type")
+)
+
+var inputDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"02/01/2006",
+}
+
+// ParseInputDate parses a date reply using the accepted layouts for the date validation rule.
+func ParseInputDate(s string) (time.Time, error) {
+	var err error
+	for _, layout := range inputDateLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func replyText(reply lime.Document) (string, bool) {
+	switch d := reply.(type) {
+	case lime.TextDocument:
+		return string(d), true
+	case *lime.TextDocument:
+		return string(*d), true
+	}
+	return "", false
+}
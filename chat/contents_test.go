@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInput_MarshalJSON(t *testing.T) {
+	// Arrange
+	i := &Input{
+		Label:      lime.NewDocumentContainer(lime.TextDocument("What is your age?")),
+		Validation: &InputValidation{Rule: InputValidationRuleNumber, Error: "Invalid age"},
+	}
+
+	// Act
+	b, err := json.Marshal(i)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"label":{"type":"text/plain","value":"What is your age?"},"validation":{"rule":"number","error":"Invalid age"}}`, string(b))
+}
+
+func TestInputValidation_Validate_Number(t *testing.T) {
+	// Arrange
+	v := &InputValidation{Rule: InputValidationRuleNumber, Error: "Invalid age"}
+
+	// Act
+	okErr := v.Validate(lime.TextDocument(" 42 "))
+	err := v.Validate(lime.TextDocument("forty-two"))
+
+	// Assert
+	assert.NoError(t, okErr)
+	var validationErr *InputValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "Invalid age", validationErr.Error())
+}
+
+func TestInputValidation_Validate_Date(t *testing.T) {
+	// Arrange
+	v := &InputValidation{Rule: InputValidationRuleDate}
+
+	// Act
+	okErr := v.Validate(lime.TextDocument("2021-05-04"))
+	err := v.Validate(lime.TextDocument("yesterday"))
+
+	// Assert
+	assert.NoError(t, okErr)
+	assert.Error(t, err)
+}
+
+func TestInputValidation_Validate_Regex(t *testing.T) {
+	// Arrange
+	v := &InputValidation{Rule: InputValidationRuleRegex, Regex: `^\d{5}-\d{3}$`}
+
+	// Act
+	okErr := v.Validate(lime.TextDocument("12345-678"))
+	err := v.Validate(lime.TextDocument("12345"))
+
+	// Assert
+	assert.NoError(t, okErr)
+	assert.Error(t, err)
+}
+
+func TestInputValidation_Validate_Type(t *testing.T) {
+	// Arrange
+	mediaType := MediaTypeContact()
+	v := &InputValidation{Rule: InputValidationRuleType, Type: &mediaType}
+
+	// Act
+	okErr := v.Validate(&Contact{})
+	err := v.Validate(lime.TextDocument("a contact"))
+
+	// Assert
+	assert.NoError(t, okErr)
+	assert.Error(t, err)
+}
@@ -0,0 +1,589 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/phonero/lime"
+	"sort"
+	"sync"
+)
+
+// GroupType defines the visibility of a group.
+type GroupType string
+
+const (
+	// GroupTypePublic is a group that can be read by any node.
+	GroupTypePublic = GroupType("public")
+	// GroupTypePrivate is a group that can be read only by its members.
+	GroupTypePrivate = GroupType("private")
+)
+
+// Group represents a group of identities, which receive the messages addressed to the group identity.
+type Group struct {
+	// The group identity, in the name@domain format.
+	Identity lime.Identity `json:"identity"`
+	// The group name.
+	Name string `json:"name,omitempty"`
+	// The group type.
+	Type GroupType `json:"type,omitempty"`
+	// The group photo URI.
+	PhotoURI string `json:"photoUri,omitempty"`
+}
+
+func MediaTypeGroup() lime.MediaType {
+	return lime.MediaType{
+		Type:    "application",
+		Subtype: "vnd.lime.group",
+		Suffix:  "json",
+	}
+}
+
+func (g *Group) MediaType() lime.MediaType {
+	return MediaTypeGroup()
+}
+
+// GroupMemberRole defines the permissions of a member in a group.
+type GroupMemberRole string
+
+const (
+	// GroupMemberRoleListener is a member that only receives the group messages.
+	GroupMemberRoleListener = GroupMemberRole("listener")
+	// GroupMemberRoleMember is a member that receives and sends messages to the group.
+	GroupMemberRoleMember = GroupMemberRole("member")
+	// GroupMemberRoleModerator is a member that can also manage the group members.
+	GroupMemberRoleModerator = GroupMemberRole("moderator")
+	// GroupMemberRoleOwner is a member that can also delete the group.
+	GroupMemberRoleOwner = GroupMemberRole("owner")
+)
+
+// GroupMember represents a member of a group.
+type GroupMember struct {
+	// The member identity.
+	Address lime.Identity `json:"address"`
+	// The member role in the group. If empty, GroupMemberRoleMember is assumed.
+	Role GroupMemberRole `json:"role,omitempty"`
+}
+
+func MediaTypeGroupMember() lime.MediaType {
+	return lime.MediaType{
+		Type:    "application",
+		Subtype: "vnd.lime.groupmember",
+		Suffix:  "json",
+	}
+}
+
+func (m *GroupMember) MediaType() lime.MediaType {
+	return MediaTypeGroupMember()
+}
+
+// CreateGroup creates the group in the server, with the session identity as its owner.
+func CreateGroup(ctx context.Context, processor lime.CommandProcessor, group *Group) error {
+	if group == nil {
+		panic("nil group")
+	}
+	cmd := &lime.RequestCommand{}
+	cmd.SetURI(lime.NewResourceURI(lime.GroupsPath)).
+		SetMethod(lime.CommandMethodSet).
+		SetResource(group).
+		SetNewEnvelopeID()
+	if err := processGroupCommand(ctx, processor, cmd, nil); err != nil {
+		return fmt.Errorf("create group: %w", err)
+	}
+	return nil
+}
+
+// GetGroup returns the group with the identity.
+func GetGroup(ctx context.Context, processor lime.CommandProcessor, group lime.Identity) (*Group, error) {
+	cmd := &lime.RequestCommand{}
+	cmd.SetURI(lime.GroupURI(group)).
+		SetMethod(lime.CommandMethodGet).
+		SetNewEnvelopeID()
+
+	var g *Group
+	err := processGroupCommand(ctx, processor, cmd, func(d lime.Document) bool {
+		g, _ = d.(*Group)
+		return g != nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get group: %w", err)
+	}
+	return g, nil
+}
+
+// DeleteGroup deletes the group, which is allowed only for its owners.
+func DeleteGroup(ctx context.Context, processor lime.CommandProcessor, group lime.Identity) error {
+	cmd := &lime.RequestCommand{}
+	cmd.SetURI(lime.GroupURI(group)).
+		SetMethod(lime.CommandMethodDelete).
+		SetNewEnvelopeID()
+	if err := processGroupCommand(ctx, processor, cmd, nil); err != nil {
+		return fmt.Errorf("delete group: %w", err)
+	}
+	return nil
+}
+
+// AddGroupMembers adds the members to the group, or changes their roles if they are already members.
+func AddGroupMembers(ctx context.Context, processor lime.CommandProcessor, group lime.Identity, members ...*GroupMember) error {
+	items := make([]lime.Document, 0, len(members))
+	for _, m := range members {
+		items = append(items, m)
+	}
+	cmd := &lime.RequestCommand{}
+	cmd.SetURI(lime.GroupMembersURI(group)).
+		SetMethod(lime.CommandMethodSet).
+		SetResource(lime.NewDocumentCollection(items, MediaTypeGroupMember())).
+		SetNewEnvelopeID()
+	if err := processGroupCommand(ctx, processor, cmd, nil); err != nil {
+		return fmt.Errorf("add group members: %w", err)
+	}
+	return nil
+}
+
+// GetGroupMembers returns the members of the group.
+func GetGroupMembers(ctx context.Context, processor lime.CommandProcessor, group lime.Identity) ([]*GroupMember, error) {
+	cmd := &lime.RequestCommand{}
+	cmd.SetURI(lime.GroupMembersURI(group)).
+		SetMethod(lime.CommandMethodGet).
+		SetNewEnvelopeID()
+
+	var members []*GroupMember
+	err := processGroupCommand(ctx, processor, cmd, func(d lime.Document) bool {
+		items := []lime.Document{d}
+		if c, ok := d.(*lime.DocumentCollection); ok {
+			items = c.Items
+		}
+		for _, item := range items {
+			m, ok := item.(*GroupMember)
+			if !ok {
+				return false
+			}
+			members = append(members, m)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get group members: %w", err)
+	}
+	return members, nil
+}
+
+// RemoveGroupMember removes the member from the group. Any member can remove itself, while the other members can be
+// removed only by the moderators and owners.
+func RemoveGroupMember(ctx context.Context, processor lime.CommandProcessor, group, member lime.Identity) error {
+	cmd := &lime.RequestCommand{}
+	cmd.SetURI(lime.GroupMemberURI(group, member)).
+		SetMethod(lime.CommandMethodDelete).
+		SetNewEnvelopeID()
+	if err := processGroupCommand(ctx, processor, cmd, nil); err != nil {
+		return fmt.Errorf("remove group member: %w", err)
+	}
+	return nil
+}
+
+// SendGroupMessage sends the content to the group, which is delivered by the server to the other group members.
+func SendGroupMessage(ctx context.Context, sender lime.MessageSender, group lime.Identity, content lime.Document) error {
+	msg := &lime.Message{}
+	msg.SetContent(content).
+		SetTo(lime.Node{Identity: group}).
+		SetNewEnvelopeID()
+	if err := sender.SendMessage(ctx, msg); err != nil {
+		return fmt.Errorf("send group message: %w", err)
+	}
+	return nil
+}
+
+// processGroupCommand processes the command, passing the response resource, if any, to the read function, which
+// returns false if the resource type is unexpected.
+func processGroupCommand(ctx context.Context, processor lime.CommandProcessor, cmd *lime.RequestCommand, read func(d lime.Document) bool) error {
+	respCmd, err := processor.ProcessCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if respCmd.Status != lime.CommandStatusSuccess {
+		return fmt.Errorf("command failed: %v", respCmd.Reason)
+	}
+	if read != nil && respCmd.Resource != nil && !read(respCmd.Resource) {
+		return fmt.Errorf("unexpected resource type '%v'", respCmd.Resource.MediaType())
+	}
+	return nil
+}
+
+var (
+	// ErrGroupNotFound is returned by the GroupStore for a group that does not exist.
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrGroupExists is returned by the GroupStore when creating a group with an identity already in use.
+	ErrGroupExists = errors.New("group already exists")
+	// ErrGroupDomain is returned by the GroupStore when creating a group with an identity outside of its domain.
+	ErrGroupDomain = errors.New("group identity not in the groups domain")
+)
+
+// GroupStore is an in-memory store of groups and their members, which provides the server handlers for the group
+// resources and messages.
+// The group identities are restricted to a domain reserved for the groups, so a session cannot create a group with
+// the identity of a user and receive its messages:
+//
+//	groups := chat.NewGroupStore("groups.msging.net")
+//	var srv *lime.Server
+//	srv = lime.NewServerBuilder().
+//		RequestCommandHandler(groups.CommandHandler()).
+//		MessageHandler(groups.MessageHandler(func(ctx context.Context, msg *lime.Message) (int, error) {
+//			return srv.DeliverMessage(ctx, msg)
+//		})).
+//		// ...
+//		Build()
+type GroupStore struct {
+	domain string
+	mu     sync.RWMutex
+	groups map[lime.Identity]*storedGroup
+}
+
+type storedGroup struct {
+	group   Group
+	members map[lime.Identity]GroupMemberRole
+}
+
+// NewGroupStore creates an empty GroupStore for the groups in the domain, which should not be used by the identities
+// of the server sessions.
+func NewGroupStore(domain string) *GroupStore {
+	if domain == "" {
+		panic("empty groups domain")
+	}
+	return &GroupStore{domain: domain, groups: make(map[lime.Identity]*storedGroup)}
+}
+
+// Domain returns the domain of the group identities.
+func (s *GroupStore) Domain() string {
+	return s.domain
+}
+
+// Create adds the group, with the owner identity as its first member.
+func (s *GroupStore) Create(owner lime.Identity, group Group) error {
+	if group.Identity.Domain != s.domain {
+		return ErrGroupDomain
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.groups[group.Identity]; ok {
+		return ErrGroupExists
+	}
+	s.groups[group.Identity] = &storedGroup{
+		group:   group,
+		members: map[lime.Identity]GroupMemberRole{owner: GroupMemberRoleOwner},
+	}
+	return nil
+}
+
+// Delete removes the group and its members.
+func (s *GroupStore) Delete(group lime.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.groups[group]; !ok {
+		return ErrGroupNotFound
+	}
+	delete(s.groups, group)
+	return nil
+}
+
+// Group returns the group with the identity.
+func (s *GroupStore) Group(group lime.Identity) (*Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.groups[group]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	copied := g.group
+	return &copied, nil
+}
+
+// Members returns the members of the group, ordered by their identities.
+func (s *GroupStore) Members(group lime.Identity) ([]*GroupMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.groups[group]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	members := make([]*GroupMember, 0, len(g.members))
+	for identity, role := range g.members {
+		members = append(members, &GroupMember{Address: identity, Role: role})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Address.String() < members[j].Address.String()
+	})
+	return members, nil
+}
+
+// AddMember adds the member to the group, or changes its role if it is already a member.
+func (s *GroupStore) AddMember(group lime.Identity, member GroupMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.groups[group]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	if member.Role == "" {
+		member.Role = GroupMemberRoleMember
+	}
+	g.members[member.Address] = member.Role
+	return nil
+}
+
+// RemoveMember removes the member from the group. It does nothing if the identity is not a member.
+func (s *GroupStore) RemoveMember(group, member lime.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.groups[group]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	delete(g.members, member)
+	return nil
+}
+
+// role returns the role of the identity in the group, or an empty role if it is not a member.
+func (s *GroupStore) role(group, identity lime.Identity) (GroupMemberRole, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.groups[group]
+	if !ok {
+		return "", false
+	}
+	return g.members[identity], true
+}
+
+// CommandHandler returns the server handler of the commands to the GroupsPath resources, which are authorized for the
+// session identity:
+//   - set '/groups' creates a group owned by the session identity, in the store domain;
+//   - get '/groups/<group>' and '/groups/<group>/members' are allowed for the members, or any node if the group is
+//     public;
+//   - set '/groups/<group>/members' is allowed for the moderators and owners, but only the owners can change the
+//     role of an owner or grant it;
+//   - delete '/groups/<group>/members/<member>' is allowed for the moderators and owners, or the member itself, but
+//     only the owners can remove another owner;
+//   - delete '/groups/<group>' is allowed for the owners.
+func (s *GroupStore) CommandHandler() lime.RequestCommandHandler {
+	return &groupCommandHandler{store: s}
+}
+
+// GroupDeliverer sends a message to the sessions of its destination, returning the number of sessions which it was
+// sent to, like the lime.Server.DeliverMessage method.
+type GroupDeliverer func(ctx context.Context, msg *lime.Message) (int, error)
+
+// MessageHandler returns the server handler of the messages addressed to the groups of the store. A copy of each
+// message is delivered to the other group members, from the group identity and on behalf of the sender, which must
+// be a member with a role other than GroupMemberRoleListener.
+func (s *GroupStore) MessageHandler(deliver GroupDeliverer) lime.MessageHandler {
+	if deliver == nil {
+		panic("nil deliverer")
+	}
+	return &groupMessageHandler{store: s, deliver: deliver}
+}
+
+type groupCommandHandler struct {
+	store *GroupStore
+}
+
+func (h *groupCommandHandler) Match(cmd *lime.RequestCommand) bool {
+	return cmd.URI != nil && cmd.URI.IsResource(lime.GroupsPath)
+}
+
+func (h *groupCommandHandler) Handle(ctx context.Context, cmd *lime.RequestCommand, s lime.Sender) error {
+	node, _ := lime.ContextSessionRemoteNode(ctx)
+	resp := h.process(ctx, cmd, node.Identity)
+	return s.SendResponseCommand(ctx, resp)
+}
+
+func (h *groupCommandHandler) process(ctx context.Context, cmd *lime.RequestCommand, identity lime.Identity) *lime.ResponseCommand {
+	if cmd.URI.Path() == lime.GroupsPath {
+		if cmd.Method != lime.CommandMethodSet {
+			return cmd.FailureResponse(lime.NewReason(lime.ReasonCodeCommandMethodNotSupported, ""))
+		}
+		return h.create(cmd, identity)
+	}
+
+	if group, member, ok := cmd.URI.GroupMember(); ok {
+		if cmd.Method != lime.CommandMethodDelete {
+			return cmd.FailureResponse(lime.NewReason(lime.ReasonCodeCommandMethodNotSupported, ""))
+		}
+		role, found := h.store.role(group, identity)
+		if !found {
+			return cmd.FailureResponse(lime.NotFoundReason(ErrGroupNotFound.Error()))
+		}
+		if member != identity {
+			if !canManageMembers(role) {
+				return h.unauthorized(ctx, cmd, "the node is not a group moderator")
+			}
+			if memberRole, _ := h.store.role(group, member); memberRole == GroupMemberRoleOwner && role != GroupMemberRoleOwner {
+				return h.unauthorized(ctx, cmd, "the node is not a group owner")
+			}
+		}
+		if err := h.store.RemoveMember(group, member); err != nil {
+			return cmd.FailureResponse(lime.NotFoundReason(err.Error()))
+		}
+		return cmd.SuccessResponse()
+	}
+
+	group, ok := cmd.URI.ResourceIdentity(lime.GroupsPath)
+	if !ok {
+		return cmd.FailureResponse(lime.InvalidArgumentReason("Invalid group identity"))
+	}
+	g, err := h.store.Group(group)
+	if err != nil {
+		return cmd.FailureResponse(lime.NotFoundReason(err.Error()))
+	}
+	role, _ := h.store.role(group, identity)
+
+	if cmd.URI.Path() == lime.GroupURI(group).Path() {
+		switch cmd.Method {
+		case lime.CommandMethodGet:
+			if role == "" && g.Type != GroupTypePublic {
+				return h.unauthorized(ctx, cmd, "the node is not a group member")
+			}
+			return cmd.SuccessResponseWithResource(g)
+		case lime.CommandMethodDelete:
+			if role != GroupMemberRoleOwner {
+				return h.unauthorized(ctx, cmd, "the node is not a group owner")
+			}
+			if err = h.store.Delete(group); err != nil {
+				return cmd.FailureResponse(lime.NotFoundReason(err.Error()))
+			}
+			return cmd.SuccessResponse()
+		}
+		return cmd.FailureResponse(lime.NewReason(lime.ReasonCodeCommandMethodNotSupported, ""))
+	}
+
+	if cmd.URI.Path() != lime.GroupMembersURI(group).Path() {
+		return cmd.FailureResponse(lime.NewReason(lime.ReasonCodeCommandResourceNotSupported, ""))
+	}
+	switch cmd.Method {
+	case lime.CommandMethodGet:
+		if role == "" && g.Type != GroupTypePublic {
+			return h.unauthorized(ctx, cmd, "the node is not a group member")
+		}
+		members, err := h.store.Members(group)
+		if err != nil {
+			return cmd.FailureResponse(lime.NotFoundReason(err.Error()))
+		}
+		items := make([]lime.Document, 0, len(members))
+		for _, m := range members {
+			items = append(items, m)
+		}
+		return cmd.SuccessResponseWithResource(lime.NewDocumentCollection(items, MediaTypeGroupMember()))
+	case lime.CommandMethodSet:
+		if !canManageMembers(role) {
+			return h.unauthorized(ctx, cmd, "the node is not a group moderator")
+		}
+		members, ok := groupMembers(cmd.Resource)
+		if !ok {
+			return cmd.FailureResponse(lime.InvalidArgumentReason("The group members are required"))
+		}
+		for _, m := range members {
+			// Only the owners can grant the owner role or change the role of another owner
+			if role == GroupMemberRoleOwner {
+				continue
+			}
+			if current, _ := h.store.role(group, m.Address); m.Role == GroupMemberRoleOwner || current == GroupMemberRoleOwner {
+				return h.unauthorized(ctx, cmd, "the node is not a group owner")
+			}
+		}
+		for _, m := range members {
+			if err = h.store.AddMember(group, *m); err != nil {
+				return cmd.FailureResponse(lime.NotFoundReason(err.Error()))
+			}
+		}
+		return cmd.SuccessResponse()
+	}
+	return cmd.FailureResponse(lime.NewReason(lime.ReasonCodeCommandMethodNotSupported, ""))
+}
+
+func (h *groupCommandHandler) create(cmd *lime.RequestCommand, owner lime.Identity) *lime.ResponseCommand {
+	g, ok := cmd.Resource.(*Group)
+	if !ok || g.Identity.Name == "" || g.Identity.Domain == "" {
+		return cmd.FailureResponse(lime.InvalidArgumentReason("The group identity is required"))
+	}
+	err := h.store.Create(owner, *g)
+	if errors.Is(err, ErrGroupDomain) {
+		return cmd.FailureResponse(lime.InvalidArgumentReason(fmt.Sprintf("The group domain must be '%v'", h.store.domain)))
+	}
+	if err != nil {
+		return cmd.FailureResponse(lime.NewReason(lime.ReasonCodeCommandNotAllowed, err.Error()))
+	}
+	return cmd.SuccessResponse()
+}
+
+func (h *groupCommandHandler) unauthorized(ctx context.Context, cmd *lime.RequestCommand, detail string) *lime.ResponseCommand {
+	lime.AuditAuthorizationDenied(ctx, cmd.URI.Path(), detail)
+	return cmd.FailureResponse(lime.UnauthorizedReason("The node is not authorized to execute the command"))
+}
+
+func canManageMembers(role GroupMemberRole) bool {
+	return role == GroupMemberRoleOwner || role == GroupMemberRoleModerator
+}
+
+// groupMembers returns the members of a GroupMember or collection resource.
+func groupMembers(d lime.Document) ([]*GroupMember, bool) {
+	switch r := d.(type) {
+	case *GroupMember:
+		return []*GroupMember{r}, true
+	case *lime.DocumentCollection:
+		members := make([]*GroupMember, 0, len(r.Items))
+		for _, item := range r.Items {
+			m, ok := item.(*GroupMember)
+			if !ok {
+				return nil, false
+			}
+			members = append(members, m)
+		}
+		return members, len(members) != 0
+	}
+	return nil, false
+}
+
+type groupMessageHandler struct {
+	store   *GroupStore
+	deliver GroupDeliverer
+}
+
+func (h *groupMessageHandler) Match(msg *lime.Message) bool {
+	if msg.To.Domain != h.store.domain {
+		return false
+	}
+	_, ok := h.store.role(msg.To.Identity, lime.Identity{})
+	return ok
+}
+
+func (h *groupMessageHandler) Handle(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+	// The session node is used instead of the message sender, which is defined by the client
+	node, _ := lime.ContextSessionRemoteNode(ctx)
+	group := msg.To.Identity
+	role, _ := h.store.role(group, node.Identity)
+	if role == "" || role == GroupMemberRoleListener {
+		lime.AuditAuthorizationDenied(ctx, group.String(), "the node cannot send messages to the group")
+		if msg.ID == "" {
+			return nil
+		}
+		not := msg.FailedNotification(lime.NewReason(lime.ReasonCodeUnauthorizedSender, ""))
+		not.To = node
+		return s.SendNotification(ctx, not)
+	}
+
+	members, err := h.store.Members(group)
+	if err != nil {
+		// The group was deleted after the message was matched
+		return nil
+	}
+	for _, m := range members {
+		if m.Address == node.Identity {
+			continue
+		}
+		copied := *msg
+		copied.From = lime.Node{Identity: group}
+		copied.PP = node
+		copied.To = lime.Node{Identity: m.Address}
+		// The members that are not connected or whose sessions are failing are ignored
+		_, _ = h.deliver(ctx, &copied)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGroupMember_MarshalJSON(t *testing.T) {
+	// Arrange
+	m := &GroupMember{Address: lime.Identity{Name: "john", Domain: "msging.net"}, Role: GroupMemberRoleModerator}
+
+	// Act
+	b, err := json.Marshal(m)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"address":"john@msging.net","role":"moderator"}`, string(b))
+}
+
+func TestGetGroupMembers(t *testing.T) {
+	// Arrange
+	group := lime.Identity{Name: "team", Domain: "msging.net"}
+	var received *lime.RequestCommand
+	processor := commandProcessorFunc(func(ctx context.Context, cmd *lime.RequestCommand) (*lime.ResponseCommand, error) {
+		received = cmd
+		return cmd.SuccessResponseWithResource(lime.NewDocumentCollection([]lime.Document{
+			&GroupMember{Address: lime.Identity{Name: "john", Domain: "msging.net"}, Role: GroupMemberRoleOwner},
+			&GroupMember{Address: lime.Identity{Name: "mary", Domain: "msging.net"}},
+		}, MediaTypeGroupMember())), nil
+	})
+
+	// Act
+	members, err := GetGroupMembers(context.Background(), processor, group)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "/groups/team@msging.net/members", received.URI.String())
+	assert.Equal(t, lime.CommandMethodGet, received.Method)
+	if assert.Len(t, members, 2) {
+		assert.Equal(t, GroupMemberRoleOwner, members[0].Role)
+		assert.Equal(t, "mary", members[1].Address.Name)
+	}
+}
+
+func TestAddGroupMembers_Failure(t *testing.T) {
+	// Arrange
+	processor := commandProcessorFunc(func(ctx context.Context, cmd *lime.RequestCommand) (*lime.ResponseCommand, error) {
+		return cmd.FailureResponse(lime.UnauthorizedReason("")), nil
+	})
+
+	// Act
+	err := AddGroupMembers(
+		context.Background(),
+		processor,
+		lime.Identity{Name: "team", Domain: "msging.net"},
+		&GroupMember{Address: lime.Identity{Name: "john", Domain: "msging.net"}})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "add group members: command failed")
+}
+
+func TestGroupStore_Members(t *testing.T) {
+	// Arrange
+	store := NewGroupStore("groups.msging.net")
+	group := Group{Identity: lime.Identity{Name: "team", Domain: "groups.msging.net"}}
+	owner := lime.Identity{Name: "mary", Domain: "msging.net"}
+	member := lime.Identity{Name: "john", Domain: "msging.net"}
+	assert.NoError(t, store.Create(owner, group))
+
+	// Act
+	err := store.AddMember(group.Identity, GroupMember{Address: member})
+
+	// Assert
+	assert.NoError(t, err)
+	members, err := store.Members(group.Identity)
+	assert.NoError(t, err)
+	assert.Equal(t, []*GroupMember{
+		{Address: member, Role: GroupMemberRoleMember},
+		{Address: owner, Role: GroupMemberRoleOwner},
+	}, members)
+	assert.ErrorIs(t, store.Create(owner, group), ErrGroupExists)
+	assert.ErrorIs(t, store.Create(owner, Group{Identity: member}), ErrGroupDomain)
+	assert.NoError(t, store.Delete(group.Identity))
+	_, err = store.Members(group.Identity)
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+}
+
+func TestGroupStore_Handlers(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	store := NewGroupStore("groups.localhost")
+	received := make(chan *lime.Message, 2)
+	newClient := startGroupServer(t, lime.InProcessAddr("chat-groups"), store, received)
+	owner := newClient("mary")
+	defer func() { _ = owner.Close() }()
+	member := newClient("john")
+	defer func() { _ = member.Close() }()
+	group := lime.Identity{Name: "team", Domain: "groups.localhost"}
+	johnIdentity := lime.Identity{Name: "john", Domain: "localhost"}
+
+	// Act
+	err := CreateGroup(ctx, owner, &Group{Identity: group, Name: "Team", Type: GroupTypePrivate})
+	assert.NoError(t, err)
+	_, getErr := GetGroup(ctx, member, group)
+	err = AddGroupMembers(ctx, owner, group, &GroupMember{Address: johnIdentity})
+	assert.NoError(t, err)
+	addErr := AddGroupMembers(ctx, member, group, &GroupMember{Address: lime.Identity{Name: "ann", Domain: "localhost"}})
+	err = SendGroupMessage(ctx, owner, group, lime.TextDocument("hello"))
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Error(t, getErr)
+	assert.Error(t, addErr)
+	g, err := GetGroup(ctx, member, group)
+	assert.NoError(t, err)
+	assert.Equal(t, "Team", g.Name)
+	members, err := GetGroupMembers(ctx, owner, group)
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	select {
+	case msg := <-received:
+		assert.Equal(t, lime.TextDocument("hello"), msg.Content)
+		assert.Equal(t, group, msg.From.Identity)
+		assert.Equal(t, "mary", msg.PP.Name)
+		assert.Equal(t, "john", msg.To.Name)
+	case <-ctx.Done():
+		t.Fatal("the group message was not delivered")
+	}
+	assert.NoError(t, RemoveGroupMember(ctx, member, group, johnIdentity))
+	assert.NoError(t, DeleteGroup(ctx, owner, group))
+	_, err = GetGroup(ctx, owner, group)
+	assert.Error(t, err)
+	assert.Empty(t, received)
+}
+
+func TestGroupStore_Handlers_UserIdentity(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	store := NewGroupStore("groups.localhost")
+	newClient := startGroupServer(t, lime.InProcessAddr("chat-groups-user-identity"), store, make(chan *lime.Message, 1))
+	mallory := newClient("mallory")
+	defer func() { _ = mallory.Close() }()
+	alice := lime.Identity{Name: "alice", Domain: "localhost"}
+	handler := store.MessageHandler(func(ctx context.Context, msg *lime.Message) (int, error) {
+		return 0, nil
+	})
+
+	group := lime.Identity{Name: "team", Domain: "groups.localhost"}
+
+	// Act
+	err := CreateGroup(ctx, mallory, &Group{Identity: alice})
+	groupErr := CreateGroup(ctx, mallory, &Group{Identity: group})
+
+	// Assert
+	assert.Error(t, err)
+	assert.NoError(t, groupErr)
+	_, err = store.Group(alice)
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+	assert.False(t, handler.Match(createTextMessage(alice)))
+	assert.True(t, handler.Match(createTextMessage(group)))
+}
+
+func TestGroupStore_Handlers_ModeratorCannotChangeOwner(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	store := NewGroupStore("groups.localhost")
+	newClient := startGroupServer(t, lime.InProcessAddr("chat-groups-moderator"), store, make(chan *lime.Message, 1))
+	owner := newClient("mary")
+	defer func() { _ = owner.Close() }()
+	moderator := newClient("john")
+	defer func() { _ = moderator.Close() }()
+	group := lime.Identity{Name: "team", Domain: "groups.localhost"}
+	ownerIdentity := lime.Identity{Name: "mary", Domain: "localhost"}
+	assert.NoError(t, CreateGroup(ctx, owner, &Group{Identity: group}))
+	assert.NoError(t, AddGroupMembers(ctx, owner, group, &GroupMember{
+		Address: lime.Identity{Name: "john", Domain: "localhost"},
+		Role:    GroupMemberRoleModerator,
+	}))
+
+	// Act
+	demoteErr := AddGroupMembers(ctx, moderator, group, &GroupMember{Address: ownerIdentity, Role: GroupMemberRoleListener})
+	removeErr := RemoveGroupMember(ctx, moderator, group, ownerIdentity)
+
+	// Assert
+	assert.Error(t, demoteErr)
+	assert.Error(t, removeErr)
+	members, err := store.Members(group)
+	assert.NoError(t, err)
+	assert.Contains(t, members, &GroupMember{Address: ownerIdentity, Role: GroupMemberRoleOwner})
+}
+
+// createTextMessage creates a text message addressed to the identity.
+func createTextMessage(to lime.Identity) *lime.Message {
+	msg := &lime.Message{}
+	msg.SetContent(lime.TextDocument("hello")).
+		SetTo(lime.Node{Identity: to}).
+		SetNewEnvelopeID()
+	return msg
+}
+
+// startGroupServer starts an in-process server with the group handlers of the store, returning a function that
+// connects the clients, which write the received messages to the channel.
+func startGroupServer(t *testing.T, addr lime.InProcessAddr, store *GroupStore, received chan<- *lime.Message) func(name string) *lime.Client {
+	t.Helper()
+	RegisterChatDocuments()
+	var srv *lime.Server
+	srv = lime.NewServerBuilder().
+		ListenInProcess(addr).
+		EnablePlainAuthentication(func(ctx context.Context, identity lime.Identity, password string) (*lime.AuthenticationResult, error) {
+			return lime.MemberAuthenticationResult(), nil
+		}).
+		RequestCommandHandler(store.CommandHandler()).
+		MessageHandler(store.MessageHandler(func(ctx context.Context, msg *lime.Message) (int, error) {
+			return srv.DeliverMessage(ctx, msg)
+		})).
+		Build()
+	go func() {
+		_ = srv.ListenAndServe()
+	}()
+	t.Cleanup(func() { _ = srv.Close() })
+	// The clients retry the connection until the server is listening
+
+	return func(name string) *lime.Client {
+		return lime.NewClientBuilder().
+			UseInProcess(addr, 1).
+			Name(name).
+			PlainAuthentication("secret").
+			MessagesHandlerFunc(func(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+				received <- msg
+				return nil
+			}).
+			Build()
+	}
+}
@@ -0,0 +1,130 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"github.com/phonero/lime"
+	"net/url"
+	"sort"
+)
+
+// PresencesPath is the URI path of the presences of the instances of an identity. A get command to
+// 'lime://name@domain/presences' returns a collection with the presence of each connected instance, and a merge
+// command to '/presences/instance' changes the presence of an instance of the session identity.
+const PresencesPath = "/presences"
+
+// InstancePresence represents the presence of a single instance of an identity. It is an extension of the protocol,
+// so its media type is in the extensions namespace, while its members are the ones of the Presence type.
+type InstancePresence struct {
+	Presence
+	// Instance is the name of the identity instance.
+	Instance string `json:"instance,omitempty"`
+}
+
+func MediaTypeInstancePresence() lime.MediaType {
+	return lime.MediaType{
+		Type:    "application",
+		Subtype: "x-lime-instance-presence",
+		Suffix:  "json",
+	}
+}
+
+func (p *InstancePresence) MediaType() lime.MediaType {
+	return MediaTypeInstancePresence()
+}
+
+// Online indicates if the instance is available for receiving envelopes.
+func (p *InstancePresence) Online() bool {
+	return p.Status != "" && p.Status != PresenceStatusUnavailable
+}
+
+// GetInstancePresences returns the presence of the connected instances of the identity, ordered by the priority in
+// descending order.
+func GetInstancePresences(ctx context.Context, processor lime.CommandProcessor, identity lime.Identity) ([]*InstancePresence, error) {
+	uri, err := lime.ParseLimeURI(fmt.Sprintf("%v://%v%v", lime.URISchemeLime, identity, PresencesPath))
+	if err != nil {
+		return nil, fmt.Errorf("get instance presences: %w", err)
+	}
+	cmd := &lime.RequestCommand{}
+	cmd.SetURI(uri).
+		SetMethod(lime.CommandMethodGet).
+		SetNewEnvelopeID()
+
+	respCmd, err := processor.ProcessCommand(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("get instance presences: %w", err)
+	}
+	if respCmd.Status != lime.CommandStatusSuccess {
+		return nil, fmt.Errorf("get instance presences: command failed: %v", respCmd.Reason)
+	}
+
+	var items []lime.Document
+	switch d := respCmd.Resource.(type) {
+	case nil:
+		return nil, nil
+	case *InstancePresence:
+		items = []lime.Document{d}
+	case *lime.DocumentCollection:
+		items = d.Items
+	default:
+		return nil, fmt.Errorf("get instance presences: unexpected resource type '%v'", d.MediaType())
+	}
+
+	presences := make([]*InstancePresence, 0, len(items))
+	for _, item := range items {
+		p, ok := item.(*InstancePresence)
+		if !ok {
+			return nil, fmt.Errorf("get instance presences: unexpected item type '%v'", item.MediaType())
+		}
+		presences = append(presences, p)
+	}
+	sort.SliceStable(presences, func(i, j int) bool {
+		return priority(presences[i]) > priority(presences[j])
+	})
+	return presences, nil
+}
+
+// GetOnlineInstances returns the names of the instances of the identity that are available for receiving envelopes,
+// ordered by the priority in descending order.
+func GetOnlineInstances(ctx context.Context, processor lime.CommandProcessor, identity lime.Identity) ([]string, error) {
+	presences, err := GetInstancePresences(ctx, processor, identity)
+	if err != nil {
+		return nil, err
+	}
+	var instances []string
+	for _, p := range presences {
+		if p.Online() {
+			instances = append(instances, p.Instance)
+		}
+	}
+	return instances, nil
+}
+
+// SetInstancePriority changes the priority of an instance of the session identity, which is used by the server to
+// choose the instance that receives the envelopes addressed to the identity.
+func SetInstancePriority(ctx context.Context, processor lime.CommandProcessor, instance string, priority int) error {
+	if instance == "" {
+		panic("empty instance")
+	}
+	cmd := &lime.RequestCommand{}
+	cmd.SetURIString(PresencesPath + "/" + url.PathEscape(instance)).
+		SetMethod(lime.CommandMethodMerge).
+		SetResource(&InstancePresence{Presence: Presence{Priority: &priority}, Instance: instance}).
+		SetNewEnvelopeID()
+
+	respCmd, err := processor.ProcessCommand(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("set instance priority: %w", err)
+	}
+	if respCmd.Status != lime.CommandStatusSuccess {
+		return fmt.Errorf("set instance priority: command failed: %v", respCmd.Reason)
+	}
+	return nil
+}
+
+func priority(p *InstancePresence) int {
+	if p.Priority == nil {
+		return 0
+	}
+	return *p.Priority
+}
@@ -0,0 +1,100 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type commandProcessorFunc func(ctx context.Context, cmd *lime.RequestCommand) (*lime.ResponseCommand, error)
+
+func (f commandProcessorFunc) ProcessCommand(ctx context.Context, cmd *lime.RequestCommand) (*lime.ResponseCommand, error) {
+	return f(ctx, cmd)
+}
+
+func TestInstancePresence_MarshalJSON(t *testing.T) {
+	// Arrange
+	priority := 2
+	p := &InstancePresence{
+		Presence: Presence{Status: PresenceStatusAvailable, Priority: &priority},
+		Instance: "phone",
+	}
+
+	// Act
+	b, err := json.Marshal(p)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status":"available","priority":2,"instance":"phone"}`, string(b))
+}
+
+func TestInstancePresence_MediaType(t *testing.T) {
+	// Act
+	mediaType := (&InstancePresence{}).MediaType()
+
+	// Assert
+	assert.Equal(t, "application/x-lime-instance-presence+json", mediaType.String())
+}
+
+func TestGetOnlineInstances(t *testing.T) {
+	// Arrange
+	low, high := 1, 5
+	var received *lime.RequestCommand
+	processor := commandProcessorFunc(func(ctx context.Context, cmd *lime.RequestCommand) (*lime.ResponseCommand, error) {
+		received = cmd
+		return cmd.SuccessResponseWithResource(&lime.DocumentCollection{
+			Total:    3,
+			ItemType: MediaTypeInstancePresence(),
+			Items: []lime.Document{
+				&InstancePresence{Presence: Presence{Status: PresenceStatusAway, Priority: &low}, Instance: "desktop"},
+				&InstancePresence{Presence: Presence{Status: PresenceStatusUnavailable}, Instance: "tablet"},
+				&InstancePresence{Presence: Presence{Status: PresenceStatusAvailable, Priority: &high}, Instance: "phone"},
+			},
+		}), nil
+	})
+
+	// Act
+	instances, err := GetOnlineInstances(context.Background(), processor, lime.Identity{Name: "golang", Domain: "limeprotocol.org"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"phone", "desktop"}, instances)
+	assert.Equal(t, lime.CommandMethodGet, received.Method)
+	assert.Equal(t, "lime://golang@limeprotocol.org/presences", received.URI.String())
+}
+
+func TestGetInstancePresences_Failure(t *testing.T) {
+	// Arrange
+	processor := commandProcessorFunc(func(ctx context.Context, cmd *lime.RequestCommand) (*lime.ResponseCommand, error) {
+		return cmd.FailureResponse(&lime.Reason{Code: 13, Description: "Forbidden"}), nil
+	})
+
+	// Act
+	presences, err := GetInstancePresences(context.Background(), processor, lime.Identity{Name: "golang", Domain: "limeprotocol.org"})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, presences)
+}
+
+func TestSetInstancePriority(t *testing.T) {
+	// Arrange
+	var received *lime.RequestCommand
+	processor := commandProcessorFunc(func(ctx context.Context, cmd *lime.RequestCommand) (*lime.ResponseCommand, error) {
+		received = cmd
+		return cmd.SuccessResponse(), nil
+	})
+
+	// Act
+	err := SetInstancePriority(context.Background(), processor, "phone", 10)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, lime.CommandMethodMerge, received.Method)
+	assert.Equal(t, "/presences/phone", received.URI.String())
+	if assert.IsType(t, &InstancePresence{}, received.Resource) {
+		assert.Equal(t, 10, *received.Resource.(*InstancePresence).Priority)
+	}
+}
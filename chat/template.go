@@ -0,0 +1,129 @@
+package chat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/phonero/lime"
+	"text/template"
+)
+
+// Template builds message documents from Go templates (see the text/template package), like the notifications of a
+// broadcast service, personalized with the data of each destination:
+//
+//	t := chat.MustTextTemplate("greeting", "Hello {{.Name}}, your order {{.Order}} was shipped")
+//	msg, err := t.Message(to, map[string]string{"Name": "John", "Order": "#123"})
+type Template struct {
+	text     *template.Template
+	options  []*template.Template // options are the option texts of a select template
+	isSelect bool
+}
+
+// Recipient is a destination of the messages built by a Template, with the data for its personalization.
+type Recipient struct {
+	To   lime.Node
+	Data interface{}
+}
+
+// NewTextTemplate parses a template for lime.TextDocument values.
+func NewTextTemplate(name, text string) (*Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("new text template: %w", err)
+	}
+	return &Template{text: t}, nil
+}
+
+// MustTextTemplate is like NewTextTemplate but panics if the template cannot be parsed.
+func MustTextTemplate(name, text string) *Template {
+	t, err := NewTextTemplate(name, text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// NewSelectTemplate parses a template for Select documents, with the text and options templates. The options are
+// numbered in the specified order.
+func NewSelectTemplate(name, text string, options ...string) (*Template, error) {
+	if len(options) == 0 {
+		return nil, errors.New("new select template: options are required")
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("new select template: %w", err)
+	}
+	st := &Template{text: t, isSelect: true}
+	for i, o := range options {
+		ot, err := template.New(fmt.Sprintf("%v.option%d", name, i+1)).Option("missingkey=error").Parse(o)
+		if err != nil {
+			return nil, fmt.Errorf("new select template: option %d: %w", i+1, err)
+		}
+		st.options = append(st.options, ot)
+	}
+	return st, nil
+}
+
+// MustSelectTemplate is like NewSelectTemplate but panics if the templates cannot be parsed.
+func MustSelectTemplate(name, text string, options ...string) *Template {
+	t, err := NewSelectTemplate(name, text, options...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Document executes the template with the data, returning a *lime.TextDocument or a *Select value.
+func (t *Template) Document(data interface{}) (lime.Document, error) {
+	text, err := execute(t.text, data)
+	if err != nil {
+		return nil, err
+	}
+	if !t.isSelect {
+		d := lime.TextDocument(text)
+		return &d, nil
+	}
+
+	s := &Select{Text: text, Options: make([]SelectOption, len(t.options))}
+	for i, o := range t.options {
+		optionText, err := execute(o, data)
+		if err != nil {
+			return nil, err
+		}
+		s.Options[i] = SelectOption{Order: i + 1, Text: optionText}
+	}
+	return s, nil
+}
+
+// Message creates a message for the destination, with a new ID and the document built from the data.
+func (t *Template) Message(to lime.Node, data interface{}) (*lime.Message, error) {
+	d, err := t.Document(data)
+	if err != nil {
+		return nil, err
+	}
+	msg := &lime.Message{}
+	msg.SetContent(d).SetNewEnvelopeID().SetTo(to)
+	return msg, nil
+}
+
+// Messages creates a message for each recipient, personalized with its data. It fails without returning any message
+// if the template cannot be executed for one of them.
+func (t *Template) Messages(recipients []Recipient) ([]*lime.Message, error) {
+	messages := make([]*lime.Message, 0, len(recipients))
+	for _, r := range recipients {
+		msg, err := t.Message(r.To, r.Data)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %v: %w", r.To, err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func execute(t *template.Template, data interface{}) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+	return b.String(), nil
+}
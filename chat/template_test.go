@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"encoding/json"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTemplate_Message_Text(t *testing.T) {
+	// Arrange
+	tmpl := MustTextTemplate("shipping", "Hello {{.Name}}, your order {{.Order}} was shipped")
+	to := lime.ParseNode("john@limeprotocol.org")
+
+	// Act
+	msg, err := tmpl.Message(to, map[string]string{"Name": "John", "Order": "#123"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.ID)
+	assert.Equal(t, to, msg.To)
+	assert.Equal(t, lime.MediaTypeTextPlain(), msg.Type)
+	d := lime.TextDocument("Hello John, your order #123 was shipped")
+	assert.Equal(t, &d, msg.Content)
+}
+
+func TestTemplate_Document_Select(t *testing.T) {
+	// Arrange
+	tmpl := MustSelectTemplate("survey", "{{.Name}}, how was your order?", "Great", "Not good, call {{.Phone}}")
+
+	// Act
+	d, err := tmpl.Document(struct{ Name, Phone string }{"John", "555-1234"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &Select{
+		Text: "John, how was your order?",
+		Options: []SelectOption{
+			{Order: 1, Text: "Great"},
+			{Order: 2, Text: "Not good, call 555-1234"},
+		},
+	}, d)
+	b, _ := json.Marshal(d)
+	assert.JSONEq(t, `{"text":"John, how was your order?","options":[{"order":1,"text":"Great"},{"order":2,"text":"Not good, call 555-1234"}]}`, string(b))
+}
+
+func TestTemplate_Messages(t *testing.T) {
+	// Arrange
+	tmpl := MustTextTemplate("greeting", "Hello {{.}}")
+	recipients := []Recipient{
+		{To: lime.ParseNode("john@limeprotocol.org"), Data: "John"},
+		{To: lime.ParseNode("mary@limeprotocol.org"), Data: "Mary"},
+	}
+
+	// Act
+	messages, err := tmpl.Messages(recipients)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, messages, 2) {
+		for i, text := range []string{"Hello John", "Hello Mary"} {
+			d := lime.TextDocument(text)
+			assert.Equal(t, recipients[i].To, messages[i].To)
+			assert.Equal(t, &d, messages[i].Content)
+		}
+		assert.NotEqual(t, messages[0].ID, messages[1].ID)
+	}
+}
+
+func TestTemplate_Messages_MissingKey(t *testing.T) {
+	// Arrange
+	tmpl := MustTextTemplate("greeting", "Hello {{.Name}}")
+	recipients := []Recipient{
+		{To: lime.ParseNode("john@limeprotocol.org"), Data: map[string]string{"Name": "John"}},
+		{To: lime.ParseNode("mary@limeprotocol.org"), Data: map[string]string{}},
+	}
+
+	// Act
+	messages, err := tmpl.Messages(recipients)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, messages)
+}
+
+func TestNewSelectTemplate_Invalid(t *testing.T) {
+	// Act
+	_, err1 := NewSelectTemplate("survey", "How was your order?")
+	_, err2 := NewSelectTemplate("survey", "How was your order?", "{{.Great")
+
+	// Assert
+	assert.Error(t, err1)
+	assert.Error(t, err2)
+}
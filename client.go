@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"log"
-	"math"
 	"net"
 	"net/http"
 	"os"
@@ -22,16 +21,28 @@ import (
 // There are methods for sending messages, notifications and command.
 // It also allows the definition of handles for receiving these envelopes from the remote party.
 type Client struct {
-	config  *ClientConfig
-	channel *ClientChannel
-	mu      sync.RWMutex // mutex for setting the channel
-	mux     *EnvelopeMux
-	lock    chan struct{}      // lock is used as a mutex for channel lifetime handling operations
-	cancel  context.CancelFunc // cancel stops the channel listener goroutine
-	done    chan bool          // done is used by the listener goroutine to signal its end
+	config    *ClientConfig
+	channel   *ClientChannel
+	mu        sync.RWMutex // mutex for setting the channel
+	mux       *EnvelopeMux
+	lock      chan struct{}      // lock is used as a mutex for channel lifetime handling operations
+	cancel    context.CancelFunc // cancel stops the channel listener goroutine
+	done      chan bool          // done is used by the listener goroutine to signal its end
+	redirect  *Redirect          // redirect is the redirection received from the server, used in the next connection
+	ready     chan struct{}      // ready is closed when the result of the first session establishment is defined
+	readyErr  error              // readyErr is the result of the first session establishment
+	readyOnce sync.Once
+	// resumptionToken is the token issued in the last established session, for resuming it in the next connection
+	resumptionToken string
+	subscriptions   subscriptions  // subscriptions are the resource subscriptions renewed after the reconnections
+	resubscribing   sync.WaitGroup // resubscribing tracks the renewal of the subscriptions
 }
 
+// ErrClientClosed is sent to the Ready channel when the client is closed before the session is established.
+var ErrClientClosed = errors.New("client closed")
+
 // NewClient creates a new instance of the Client type.
+// The client uses a copy of the mux, so the handlers must be registered before its creation.
 func NewClient(config *ClientConfig, mux *EnvelopeMux) *Client {
 	if config == nil {
 		config = defaultClientConfig
@@ -39,15 +50,65 @@ func NewClient(config *ClientConfig, mux *EnvelopeMux) *Client {
 	if mux == nil || reflect.ValueOf(mux).IsNil() {
 		panic("nil mux")
 	}
+	// The internal handlers are added to a copy, since the mux can be shared by other clients
+	mux = mux.clone()
 	c := &Client{
 		config: config,
 		mux:    mux,
 		lock:   make(chan struct{}, 1),
+		ready:  make(chan struct{}),
+	}
+	if config.NewRedirectTransport != nil {
+		// The redirect handler must be the first one, since it should not be captured by the user handlers
+		mux.msgHandlers = append([]MessageHandler{&messageHandler{
+			predicate:   isRedirectMessage,
+			handlerFunc: c.handleRedirect,
+		}}, mux.msgHandlers...)
+	}
+	if config.NotificationTracker != nil {
+		// The tracker must be the first one, since it should receive all the notifications of the tracked messages
+		mux.notHandlers = append([]NotificationHandler{config.NotificationTracker}, mux.notHandlers...)
 	}
+	if config.MessageStore != nil {
+		mux.store = config.MessageStore
+	}
+	// The observe handler must be the first one, since the observations of the subscriptions belong to them
+	mux.reqCmdHandlers = append([]RequestCommandHandler{&requestCommandHandler{
+		predicate:   c.subscriptions.matchObserve,
+		handlerFunc: c.subscriptions.handleObserve,
+	}}, mux.reqCmdHandlers...)
 	c.startListener()
 	return c
 }
 
+// Ready returns a channel that receives nil when the first session with the server is established, which happens in
+// background since the client creation, and is closed afterwards. If the Warmup option is enabled, it receives the
+// error of the first establishment attempt instead, although the client keeps retrying. It receives ErrClientClosed
+// if the client is closed before.
+// Each call returns a new channel, so all the callers receive the result, even after it is defined.
+func (c *Client) Ready() <-chan error {
+	result := make(chan error, 1)
+	select {
+	case <-c.ready:
+		result <- c.readyErr
+		close(result)
+	default:
+		goLabeled(context.Background(), "client.ready", func(context.Context) {
+			<-c.ready
+			result <- c.readyErr
+			close(result)
+		})
+	}
+	return result
+}
+
+func (c *Client) signalReady(err error) {
+	c.readyOnce.Do(func() {
+		c.readyErr = err
+		close(c.ready)
+	})
+}
+
 // Establish forces the establishment of a session, in case of not being already established.
 // It also awaits for any establishment operation that is in progress, returning only when it succeeds.
 func (c *Client) Establish(ctx context.Context) error {
@@ -58,6 +119,7 @@ func (c *Client) Establish(ctx context.Context) error {
 // Close stops the listener and finishes any established session with the server.
 func (c *Client) Close() error {
 	c.stopListener()
+	c.signalReady(ErrClientClosed)
 
 	if c.channel == nil {
 		return nil
@@ -94,7 +156,14 @@ func (c *Client) SendMessage(ctx context.Context, msg *Message) error {
 	if err != nil {
 		return err
 	}
-	return channel.SendMessage(ctx, msg)
+	if c.config.NotificationTracker != nil {
+		c.config.NotificationTracker.Track(msg)
+	}
+	if err = channel.SendMessage(ctx, msg); err != nil {
+		return err
+	}
+	storeMessage(ctx, c.config.MessageStore, TapDirectionSent, msg)
+	return nil
 }
 
 // SendNotification asynchronously sends a Notification to the server.
@@ -104,7 +173,11 @@ func (c *Client) SendNotification(ctx context.Context, not *Notification) error
 	if err != nil {
 		return err
 	}
-	return channel.SendNotification(ctx, not)
+	if err = channel.SendNotification(ctx, not); err != nil {
+		return err
+	}
+	storeNotification(ctx, c.config.MessageStore, TapDirectionSent, not)
+	return nil
 }
 
 // SendRequestCommand asynchronously sends a RequestCommand to the server.
@@ -120,7 +193,17 @@ func (c *Client) SendRequestCommand(ctx context.Context, cmd *RequestCommand) er
 }
 
 // ProcessCommand send a RequestCommand to the server and returns the corresponding ResponseCommand.
+// If the ResourceCache is configured, the get commands may be answered from the cache.
 func (c *Client) ProcessCommand(ctx context.Context, cmd *RequestCommand) (*ResponseCommand, error) {
+	if c.config.ResourceCache != nil {
+		return c.config.ResourceCache.processCommand(cmd, func() (*ResponseCommand, error) {
+			return c.processCommand(ctx, cmd)
+		})
+	}
+	return c.processCommand(ctx, cmd)
+}
+
+func (c *Client) processCommand(ctx context.Context, cmd *RequestCommand) (*ResponseCommand, error) {
 	channel, err := c.getOrBuildChannel(ctx)
 	if err != nil {
 		return nil, err
@@ -158,7 +241,12 @@ func (c *Client) getOrBuildChannel(ctx context.Context) (*ClientChannel, error)
 		return c.channel, nil
 	}
 
-	count := 0.0
+	backoff := c.config.ReconnectBackoff
+	if backoff == nil {
+		backoff = quadraticBackoff
+	}
+	count := 0
+	var interval time.Duration
 
 	for ctx.Err() == nil {
 		if c.channel != nil {
@@ -175,12 +263,16 @@ func (c *Client) getOrBuildChannel(ctx context.Context) (*ClientChannel, error)
 			c.mu.Lock()
 			c.channel = channel
 			c.mu.Unlock()
+			c.signalReady(nil)
 			return channel, nil
 		}
+		if c.config.Warmup && ctx.Err() == nil {
+			c.signalReady(err)
+		}
 
-		interval := time.Duration(math.Pow(count, 2)*100) * time.Millisecond
+		interval = backoff.Backoff(count, interval)
 		log.Printf("build channel error on attempt %v, sleeping %v ms: %v", count, interval, err)
-		time.Sleep(interval)
+		_ = sleep(ctx, clockOrSystem(c.config.Clock), interval)
 		count++
 	}
 
@@ -192,41 +284,178 @@ func (c *Client) startListener() {
 	c.cancel = cancel
 	c.done = make(chan bool)
 
-	go func() {
+	goLabeled(ctx, "client.listener", func(ctx context.Context) {
 		defer close(c.done)
 
+		var last *ClientChannel
 		for ctx.Err() == nil {
 			channel, err := c.getOrBuildChannel(ctx)
 			if err != nil {
 				log.Printf("client: listen: %v", err)
 				continue
 			}
+			if last != nil && channel != last {
+				// The subscriptions are renewed while listening, since the observations may arrive before the responses
+				c.resubscribing.Add(1)
+				goLabeled(ctx, "client.resubscribe", func(ctx context.Context) {
+					defer c.resubscribing.Done()
+					c.resubscribe(ctx)
+				})
+			}
+			last = channel
 
 			if err := c.mux.ListenClient(ctx, channel); err != nil {
 				if errors.Is(err, context.Canceled) {
 					continue
 				}
+				if errors.Is(err, errRedirected) {
+					c.closeChannel()
+					continue
+				}
 				log.Printf("client: listen: %v", err)
 			}
 		}
-	}()
+	})
 }
 
 func (c *Client) stopListener() {
 	if c.cancel != nil {
 		c.cancel()
 		<-c.done
+		c.resubscribing.Wait()
 		c.cancel = nil
 	}
 }
 
+// closeChannel finishes the current session, forcing a new channel to be built on the next usage.
+func (c *Client) closeChannel() {
+	c.lock <- struct{}{}
+	defer func() {
+		<-c.lock
+	}()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.channel == nil {
+		return
+	}
+
+	if c.channel.Established() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if _, err := c.channel.FinishSession(ctx); err != nil {
+			log.Printf("client: close channel: %v", err)
+		}
+	} else {
+		_ = c.channel.Close()
+	}
+	c.channel = nil
+}
+
+func (c *Client) newTransport(ctx context.Context) (Transport, error) {
+	// The redirection is used only once, so the next connections use the original address
+	c.mu.Lock()
+	redirect := c.redirect
+	c.redirect = nil
+	c.mu.Unlock()
+
+	if redirect == nil {
+		return c.config.NewTransport(ctx)
+	}
+
+	transport, err := c.config.NewRedirectTransport(ctx, redirect)
+	if err != nil {
+		return nil, fmt.Errorf("redirect to %v: %w", redirect.Address, err)
+	}
+	return transport, nil
+}
+
+// errRedirected signals to the listener that the current channel should be replaced.
+var errRedirected = errors.New("session redirected")
+
+func isRedirectMessage(msg *Message) bool {
+	return msg.Type == MediaTypeRedirect()
+}
+
+// handleRedirect follows the redirections sent by the server. The ones sent by other nodes are ignored, since the
+// server routes the messages of the other users, which could move the client to a server that they control.
+func (c *Client) handleRedirect(ctx context.Context, msg *Message, _ Sender) error {
+	redirect, ok := msg.Content.(*Redirect)
+	if !ok {
+		return nil
+	}
+	if remote, _ := ContextSessionRemoteNode(ctx); msg.From != (Node{}) && msg.From != remote {
+		log.Printf("client: ignoring the redirect from %v", msg.From)
+		return nil
+	}
+	if _, err := redirect.URL(); err != nil {
+		log.Printf("client: invalid redirect: %v", err)
+		return nil
+	}
+
+	c.mu.Lock()
+	c.redirect = redirect
+	c.mu.Unlock()
+	return errRedirected
+}
+
 func (c *Client) buildChannel(ctx context.Context) (*ClientChannel, error) {
-	transport, err := c.config.NewTransport(ctx)
+	clock := clockOrSystem(c.config.Clock)
+	start := clock.Now()
+	transport, err := c.newTransport(ctx)
+	transportOpen := clock.Now().Sub(start)
 	if err != nil {
+		c.reportEstablishment(EstablishmentMetrics{TransportOpen: transportOpen, Total: transportOpen, Err: err})
 		return nil, fmt.Errorf("buildChannel: %w", err)
 	}
 
 	channel := NewClientChannel(transport, c.config.ChannelBufferSize)
+	if c.config.RequireEncryption {
+		channel.RequireEncryption()
+	}
+	if len(c.config.Codecs) > 0 {
+		channel.SetCodecs(c.config.Codecs...)
+	}
+	if c.config.NegotiationTracer != nil {
+		channel.SetNegotiationTracer(c.config.NegotiationTracer)
+	}
+	if c.config.SendWatchdog != nil {
+		channel.SetSendWatchdog(c.config.SendWatchdog)
+	}
+	if c.config.Clock != nil {
+		channel.SetClock(c.config.Clock)
+	}
+	if c.config.EnvelopeIDPolicy != nil {
+		channel.SetEnvelopeIDPolicy(c.config.EnvelopeIDPolicy)
+	}
+	if c.config.CommandResponseMatching != CommandResponseMatchID {
+		channel.SetCommandResponseMatching(c.config.CommandResponseMatching)
+	}
+	if len(c.config.Features) > 0 {
+		channel.AdvertiseFeatures(c.config.Features...)
+	}
+	if c.config.ReplayProtection {
+		channel.EnableReplayProtection()
+	}
+	if c.config.ContentHashes {
+		channel.EnableContentHashes()
+	}
+	if c.config.SequenceNumbers {
+		channel.EnableSequenceNumbers()
+	}
+	if c.config.Version > 0 {
+		channel.SetVersion(c.config.Version, c.config.VersionShims...)
+	}
+	if c.config.OnUnknownEnvelope != nil {
+		channel.OnUnknownEnvelope(c.config.OnUnknownEnvelope)
+	}
+	if c.config.OnMalformedEnvelope != nil {
+		channel.OnMalformedEnvelope(c.config.OnMalformedEnvelope)
+	}
+	if c.config.ResumeSessions && c.resumptionToken != "" {
+		channel.SetResumptionToken(c.resumptionToken)
+	}
 	ses, err := channel.EstablishSession(
 		ctx,
 		c.config.CompSelector,
@@ -235,17 +464,38 @@ func (c *Client) buildChannel(ctx context.Context) (*ClientChannel, error) {
 		c.config.Authenticator,
 		c.config.Node.Instance,
 	)
+	if err == nil && ses.State != SessionStateEstablished {
+		err = channel.EstablishmentErr()
+		if err == nil {
+			err = fmt.Errorf("channel state is %v", ses.State)
+		}
+	}
+	metrics := channel.EstablishmentMetrics()
+	metrics.TransportOpen = transportOpen
+	metrics.Total += transportOpen
+	metrics.Err = err
+	c.reportEstablishment(metrics)
 	if err != nil {
 		return nil, fmt.Errorf("buildChannel: %w", err)
 	}
 
-	if ses.State != SessionStateEstablished {
-		return nil, fmt.Errorf("buildChannel: channel state is %v", ses.State)
+	if c.config.ResumeSessions {
+		c.resumptionToken = channel.ResumptionToken()
+	}
+	if err = c.setupSession(ctx, channel); err != nil {
+		_ = channel.Close()
+		return nil, fmt.Errorf("buildChannel: %w", err)
 	}
 
 	return channel, nil
 }
 
+func (c *Client) reportEstablishment(metrics EstablishmentMetrics) {
+	if c.config.OnEstablishment != nil {
+		c.config.OnEstablishment(metrics)
+	}
+}
+
 // ClientConfig defines the configurations for a Client instance.
 type ClientConfig struct {
 	// Node represents the address that the client should use in the session negotiation.
@@ -263,6 +513,70 @@ type ClientConfig struct {
 	// Authenticator is called during the session authentication and allows the client to provide its credentials
 	// during the process.
 	Authenticator Authenticator
+	// NewRedirectTransport is called for creating a transport for the address of a Redirect document received from
+	// the server. If not defined, the redirect messages are delivered to the message handlers as any other message.
+	// Only the redirects sent by the server are followed, and each one is used only in the next connection.
+	NewRedirectTransport func(ctx context.Context, r *Redirect) (Transport, error)
+	// ResourceCache stores the responses of the get commands processed by the client.
+	// If not defined, all the commands are sent to the server.
+	ResourceCache *ResourceCache
+	// NotificationTracker aggregates the notifications of the messages sent by the client.
+	// If defined, the notifications of the sent messages are not delivered to the notification handlers.
+	NotificationTracker *NotificationTracker
+	// MessageStore records the messages and notifications sent and received by the client.
+	MessageStore MessageStore
+	// Codecs are the envelope codecs offered to the server during the session establishment, in the preference
+	// order. If the server ignores the offer, the session keeps using JSON.
+	Codecs []Codec
+	// RequireEncryption aborts the session establishment if the server does not offer the TLS encryption.
+	RequireEncryption bool
+	// NegotiationTracer receives the session establishment events, for the diagnosis of failed or slow
+	// establishments.
+	NegotiationTracer NegotiationTracer
+	// SendWatchdog reports the sends of the session that are blocked beyond its threshold.
+	SendWatchdog *SendWatchdog
+	// EnvelopeIDPolicy defines the checks of the IDs of the envelopes sent in the session.
+	EnvelopeIDPolicy *EnvelopeIDPolicy
+	// CommandResponseMatching defines how the responses received in the session are correlated with the commands.
+	CommandResponseMatching CommandResponseMatching
+	// Features are advertised to the server in the new session.
+	Features []Feature
+	// ReplayProtection enables the envelope nonces in the session, which are used only if the server also enables
+	// them.
+	ReplayProtection bool
+	// ContentHashes makes the session embed the content hash in the sent messages and commands.
+	ContentHashes bool
+	// SequenceNumbers makes the session stamp the received envelopes with their order. See Envelope.Sequence.
+	SequenceNumbers bool
+	// ResumeSessions makes the client present the resumption token issued by the server in the last session when
+	// reconnecting, which allows the server to skip the authentication.
+	ResumeSessions bool
+	// Version is the envelope schema version of the client, which is negotiated with the server.
+	Version int
+	// VersionShims convert the documents exchanged with a server of an older version.
+	VersionShims []VersionShim
+	// OnUnknownEnvelope receives the envelopes of unknown types, which are ignored by the channel.
+	OnUnknownEnvelope func(ctx context.Context, err *UnknownEnvelopeError)
+	// OnMalformedEnvelope receives the envelopes that could not be decoded, which are skipped by the channel.
+	OnMalformedEnvelope func(ctx context.Context, err *MalformedEnvelopeError)
+	// ReconnectBackoff defines the intervals between the failed channel establishment attempts. If not defined, the
+	// interval grows quadratically, in steps of 100 milliseconds.
+	ReconnectBackoff BackoffStrategy
+	// Clock measures the reconnection intervals and the message retransmission timeouts, and provides the timestamps
+	// of the channel. If not defined, the SystemClock is used.
+	Clock Clock
+	// Presence is set in the '/presence' resource after each session establishment, if defined. See
+	// ClientBuilder.AutoPresence.
+	Presence Document
+	// Receipts is set in the '/receipt' resource after each session establishment, if defined. See
+	// ClientBuilder.AutoReceipts.
+	Receipts Document
+	// OnEstablishment receives the durations of the phases of each session establishment attempt, including the
+	// failed ones.
+	OnEstablishment func(metrics EstablishmentMetrics)
+	// Warmup makes the Ready channel receive the error of the first session establishment attempt, allowing the
+	// latency-sensitive applications to fail fast, instead of waiting for the session to be established.
+	Warmup bool
 }
 
 var defaultClientConfig = NewClientConfig()
@@ -404,11 +718,25 @@ func (b *ClientBuilder) RequestCommandHandler(handler RequestCommandHandler) *Cl
 	return b
 }
 
+// MergeHandlerFunc allows the registration of a handler for the merge commands with the specified URI path, which
+// merges the command resource into the current value of the resource.
+func (b *ClientBuilder) MergeHandlerFunc(path string, get ResourceGetter, set ResourceSetter) *ClientBuilder {
+	b.mux.MergeHandlerFunc(path, get, set)
+	return b
+}
+
+// RequestCommandMiddleware allows the registration of middlewares for the received commands with a URI path under
+// the specified prefix. The middlewares are executed in the registration order, before the command handlers.
+func (b *ClientBuilder) RequestCommandMiddleware(prefix string, middlewares ...RequestCommandMiddleware) *ClientBuilder {
+	b.mux.RequestCommandMiddleware(prefix, middlewares...)
+	return b
+}
+
 // AutoReplyPings adds a RequestCommandHandler handler to automatically reply ping requests from the remote node.
 func (b *ClientBuilder) AutoReplyPings() *ClientBuilder {
 	return b.RequestCommandHandlerFunc(
 		func(cmd *RequestCommand) bool {
-			return cmd.Method == CommandMethodGet && cmd.URI.Path() == "/ping"
+			return cmd.Method == CommandMethodGet && cmd.URI.Path() == PingPath
 		},
 		func(ctx context.Context, cmd *RequestCommand, s Sender) error {
 			return s.SendResponseCommand(
@@ -447,6 +775,16 @@ func (b *ClientBuilder) UseTCP(addr net.Addr, config *TCPConfig) *ClientBuilder
 	return b
 }
 
+// UseTransportMux opens the sessions of the client in the multiplexed connection, which can be shared by many
+// clients. The connection is not closed with the client. This is an experimental feature.
+func (b *ClientBuilder) UseTransportMux(m *TransportMux) *ClientBuilder {
+	if m == nil {
+		panic("nil transport mux")
+	}
+	b.config.NewTransport = m.Open
+	return b
+}
+
 // UseWebsocket adds a Websockets listener to the server, allowing receiving connections from this transport.
 func (b *ClientBuilder) UseWebsocket(urlStr string, requestHeader http.Header, tls *tls.Config) *ClientBuilder {
 	b.config.NewTransport = func(ctx context.Context) (Transport, error) {
@@ -463,6 +801,40 @@ func (b *ClientBuilder) UseInProcess(addr InProcessAddr, bufferSize int) *Client
 	return b
 }
 
+// FollowRedirects enables the handling of Redirect messages sent by the server, replacing the current session by a
+// new one established with the redirect address. If newTransport is nil, the DialRedirect function is used.
+func (b *ClientBuilder) FollowRedirects(newTransport func(ctx context.Context, r *Redirect) (Transport, error)) *ClientBuilder {
+	if newTransport == nil {
+		newTransport = DialRedirect
+	}
+	b.config.NewRedirectTransport = newTransport
+	return b
+}
+
+// DialRedirect opens a transport connection with the address of the Redirect document.
+// The supported schemes are 'net.tcp', 'ws', 'wss' and 'in.process', using the default transport configurations.
+func DialRedirect(ctx context.Context, r *Redirect) (Transport, error) {
+	u, err := r.URL()
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "net.tcp", "tcp":
+		addr, err := net.ResolveTCPAddr("tcp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return DialTcp(ctx, addr, nil)
+	case "ws", "wss":
+		return DialWebsocket(ctx, r.Address, nil, nil)
+	case InProcessNetwork:
+		return DialInProcess(InProcessAddr(u.Host), 1)
+	}
+
+	return nil, fmt.Errorf("unsupported redirect scheme '%v'", u.Scheme)
+}
+
 // GuestAuthentication enables the use of the guest authentication scheme during the session establishment with the server.
 func (b *ClientBuilder) GuestAuthentication() *ClientBuilder {
 	b.config.Authenticator = func([]AuthenticationScheme, Authentication) Authentication {
@@ -511,6 +883,18 @@ func (b *ClientBuilder) ExternalAuthentication(token, issuer string) *ClientBuil
 	return b
 }
 
+// CustomAuthentication enables the use of a custom authentication scheme during the session establishment with the
+// server. The scheme Authentication type should be registered with the RegisterAuthenticationFactory function.
+func (b *ClientBuilder) CustomAuthentication(a Authentication) *ClientBuilder {
+	if a == nil {
+		panic("nil authentication")
+	}
+	b.config.Authenticator = func([]AuthenticationScheme, Authentication) Authentication {
+		return a
+	}
+	return b
+}
+
 // Compression sets the compression to be used in the session negotiation.
 func (b *ClientBuilder) Compression(c SessionCompression) *ClientBuilder {
 	b.config.CompSelector = func([]SessionCompression) SessionCompression {
@@ -527,6 +911,177 @@ func (b *ClientBuilder) Encryption(e SessionEncryption) *ClientBuilder {
 	return b
 }
 
+// CacheResources enables the caching of the resources retrieved by get commands for the specified duration.
+// The cached resources are invalidated when a set, merge or delete command is processed for the same URI.
+func (b *ClientBuilder) CacheResources(ttl time.Duration) *ClientBuilder {
+	b.config.ResourceCache = NewResourceCache(ttl)
+	return b
+}
+
+// TrackNotifications enables the aggregation of the notifications of the messages sent by the client in the
+// specified tracker. The notifications of the sent messages are handled by the tracker and are not delivered to the
+// notification handlers.
+func (b *ClientBuilder) TrackNotifications(t *NotificationTracker) *ClientBuilder {
+	if t == nil {
+		panic("nil tracker")
+	}
+	b.config.NotificationTracker = t
+	return b
+}
+
+// StoreMessages defines a store for recording the messages and notifications sent and received by the client.
+func (b *ClientBuilder) StoreMessages(s MessageStore) *ClientBuilder {
+	if s == nil {
+		panic("nil store")
+	}
+	b.config.MessageStore = s
+	return b
+}
+
+// Codecs offers the specified envelope codecs to the server during the session establishment, in the preference
+// order. The codec is only switched in transports that implement the CodecTransport interface.
+func (b *ClientBuilder) Codecs(codecs ...Codec) *ClientBuilder {
+	b.config.Codecs = append(b.config.Codecs, codecs...)
+	return b
+}
+
+// RequireEncryption aborts the session establishment if the server does not offer the TLS encryption, instead of
+// proceeding in plaintext.
+func (b *ClientBuilder) RequireEncryption() *ClientBuilder {
+	b.config.RequireEncryption = true
+	return b
+}
+
+// TraceNegotiation defines a tracer for the session establishment events.
+func (b *ClientBuilder) TraceNegotiation(t NegotiationTracer) *ClientBuilder {
+	b.config.NegotiationTracer = t
+	return b
+}
+
+// OnEstablishment defines a function for receiving the durations of the phases of each session establishment
+// attempt, like the transport connection, the negotiation, the TLS handshake and the authentication.
+func (b *ClientBuilder) OnEstablishment(f func(metrics EstablishmentMetrics)) *ClientBuilder {
+	b.config.OnEstablishment = f
+	return b
+}
+
+// SendWatchdog defines a watchdog for the sends of the session that are blocked beyond its threshold, like when the
+// server is not reading from the connection.
+func (b *ClientBuilder) SendWatchdog(w *SendWatchdog) *ClientBuilder {
+	b.config.SendWatchdog = w
+	return b
+}
+
+// EnvelopeIDPolicy defines the checks of the IDs of the envelopes sent in the session, like the format validation
+// and the detection of the reused message and command IDs, which would mismatch their notifications and responses.
+func (b *ClientBuilder) EnvelopeIDPolicy(p *EnvelopeIDPolicy) *ClientBuilder {
+	b.config.EnvelopeIDPolicy = p
+	return b
+}
+
+// CommandResponseMatching defines how the responses received in the session are correlated with the commands, like
+// requiring them to be sent by the identity of the command destination, regardless of the instance.
+func (b *ClientBuilder) CommandResponseMatching(m CommandResponseMatching) *ClientBuilder {
+	b.config.CommandResponseMatching = m
+	return b
+}
+
+// AdvertiseFeatures adds features to be advertised to the server in the new session, which can be checked by the
+// server modules through the Supports method of the session channel.
+func (b *ClientBuilder) AdvertiseFeatures(features ...Feature) *ClientBuilder {
+	b.config.Features = append(b.config.Features, features...)
+	return b
+}
+
+// EnableReplayProtection makes the session stamp the envelopes with nonces and fail if a replayed envelope is
+// received. The protection is used only if the server also enables it.
+func (b *ClientBuilder) EnableReplayProtection() *ClientBuilder {
+	b.config.ReplayProtection = true
+	return b
+}
+
+// EnableContentHashes makes the session embed the content hash in the sent messages and commands, allowing the
+// server to detect corrupted contents.
+func (b *ClientBuilder) EnableContentHashes() *ClientBuilder {
+	b.config.ContentHashes = true
+	return b
+}
+
+// EnableSequenceNumbers makes the session stamp the received envelopes with their order, allowing it to be restored
+// after the concurrent processing. See Envelope.Sequence.
+func (b *ClientBuilder) EnableSequenceNumbers() *ClientBuilder {
+	b.config.SequenceNumbers = true
+	return b
+}
+
+// ResumeSessions makes the client present the resumption token issued by the server when reconnecting, allowing the
+// server to skip the authentication. See ResumptionTokens.
+func (b *ClientBuilder) ResumeSessions() *ClientBuilder {
+	b.config.ResumeSessions = true
+	return b
+}
+
+// VerifyContentHashes enables the verification of the content hashes of the received messages and commands,
+// rejecting the ones whose content does not match the hash. The envelopes without a hash are accepted.
+func (b *ClientBuilder) VerifyContentHashes() *ClientBuilder {
+	b.mux.VerifyContentHashes()
+	return b
+}
+
+// OnHandlerError defines the policy for the failures of the envelope handlers, like sending a failed notification
+// or dropping the envelope instead of finishing the session. The handler panics are also recovered.
+func (b *ClientBuilder) OnHandlerError(policy HandlerErrorPolicy) *ClientBuilder {
+	b.mux.OnHandlerError(policy)
+	return b
+}
+
+// Filter defines a policy that the received envelopes must match for being handled, like
+// lime.MustCompilePolicy(`from.domain == "msging.net"`). See EnvelopeMux.Filter.
+func (b *ClientBuilder) Filter(policy *Policy) *ClientBuilder {
+	b.mux.Filter(policy)
+	return b
+}
+
+// Version defines the envelope schema version of the client and the shims for converting the documents exchanged
+// with a server of an older version.
+func (b *ClientBuilder) Version(version int, shims ...VersionShim) *ClientBuilder {
+	b.config.Version = version
+	b.config.VersionShims = shims
+	return b
+}
+
+// OnUnknownEnvelope defines a handler for the received envelopes of unknown types, which are ignored by the channel.
+func (b *ClientBuilder) OnUnknownEnvelope(f func(ctx context.Context, err *UnknownEnvelopeError)) *ClientBuilder {
+	b.config.OnUnknownEnvelope = f
+	return b
+}
+
+// OnMalformedEnvelope defines a handler for the received envelopes that could not be decoded, which are skipped by
+// the channel.
+func (b *ClientBuilder) OnMalformedEnvelope(f func(ctx context.Context, err *MalformedEnvelopeError)) *ClientBuilder {
+	b.config.OnMalformedEnvelope = f
+	return b
+}
+
+// ReconnectBackoff defines the intervals between the failed channel establishment attempts.
+func (b *ClientBuilder) ReconnectBackoff(s BackoffStrategy) *ClientBuilder {
+	b.config.ReconnectBackoff = s
+	return b
+}
+
+// Clock defines the clock for the reconnection intervals and the message retransmission timeouts.
+func (b *ClientBuilder) Clock(c Clock) *ClientBuilder {
+	b.config.Clock = c
+	return b
+}
+
+// Warmup makes the Ready channel of the client report the result of the first session establishment attempt, which
+// starts when the client is built, so the applications can await the session before sending the first envelope.
+func (b *ClientBuilder) Warmup() *ClientBuilder {
+	b.config.Warmup = true
+	return b
+}
+
 // ChannelBufferSize is the size of the internal envelope buffer used by the ClientChannel.
 // Greater values may improve the performance, but will also increase the process memory usage.
 func (b *ClientBuilder) ChannelBufferSize(bufferSize int) *ClientBuilder {
@@ -2,18 +2,31 @@ package lime
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 )
 
+// ErrEncryptionRequired is returned when the session establishment is aborted because the server does not offer
+// the TLS encryption to a channel that requires it.
+var ErrEncryptionRequired = errors.New("encryption required")
+
 // ClientChannel implements the client-side communication channel in a Lime session.
 type ClientChannel struct {
 	*channel
+	requireEncryption bool
+	establishmentErr  *EstablishmentError // establishmentErr is the failure of the last session establishment
+	authenticator     Authenticator       // authenticator is used again when the server requests a re-authentication
+	resumptionToken   string              // resumptionToken is presented in the authentication and replaced by the issued one
+	metrics           EstablishmentMetrics
 }
 
 func NewClientChannel(t Transport, bufferSize int) *ClientChannel {
 	c := newChannel(t, bufferSize)
 	c.client = true
-	return &ClientChannel{channel: c}
+	cc := &ClientChannel{channel: c}
+	c.sessionHandler = cc.handleReauthentication
+	return cc
 }
 
 // receiveSessionFromServer receives a session from the remote node.
@@ -29,6 +42,26 @@ func (c *ClientChannel) receiveSessionFromServer(ctx context.Context) (*Session,
 	}
 
 	c.sessionID = ses.ID
+
+	if ses.State == SessionStateEstablished && c.version > 0 {
+		c.selectVersion(ses)
+	}
+	if ses.State == SessionStateEstablished {
+		c.receiveFeatures(ses)
+		c.acceptReplayKey(ses)
+	}
+
+	// The codec must be changed before the state, which starts the channel receiver
+	if name, ok := ses.Metadata[MetadataKeySessionCodec]; ok && ses.State == SessionStateEstablished {
+		codec := c.findCodec(name)
+		if codec == nil {
+			return nil, fmt.Errorf("receive session: %w: unsupported codec '%v'", ErrNegotiationRejected, name)
+		}
+		if err := c.switchCodec(ctx, codec); err != nil {
+			return nil, err
+		}
+	}
+
 	c.setState(ses.State)
 
 	if ses.State == SessionStateFinished || ses.State == SessionStateFailed {
@@ -46,7 +79,13 @@ func (c *ClientChannel) startNewSession(ctx context.Context) (*Session, error) {
 		return nil, err
 	}
 
-	if err := c.sendSession(ctx, &Session{State: SessionStateNew}); err != nil {
+	newSes := Session{State: SessionStateNew}
+	c.offerCodecs(&newSes)
+	c.offerVersion(&newSes)
+	c.advertiseFeatures(&newSes)
+	c.offerReplayKey(&newSes)
+
+	if err := c.sendSession(ctx, &newSes); err != nil {
 		return nil, fmt.Errorf("sending new session failed: %w", err)
 	}
 
@@ -102,6 +141,9 @@ func (c *ClientChannel) authenticateSession(ctx context.Context, identity Identi
 		State: SessionStateAuthenticating,
 	}
 	authSes.SetAuthentication(auth)
+	if c.resumptionToken != "" {
+		authSes.SetMetadataKeyValue(MetadataKeyResumptionToken, c.resumptionToken)
+	}
 
 	if err := c.sendSession(ctx, &authSes); err != nil {
 		return nil, fmt.Errorf("sending authenticating session failed: %w", err)
@@ -157,7 +199,20 @@ var TransportAuthenticator Authenticator = func(schemes []AuthenticationScheme,
 	return &TransportAuthentication{}
 }
 
+// RequireEncryption makes the channel abort the session establishment if the transport is not encrypted before the
+// authentication, instead of proceeding in plaintext. If the server offers the TLS encryption during the negotiation,
+// it is selected regardless of the encryption selector.
+func (c *ClientChannel) RequireEncryption() {
+	if err := c.ensureState(SessionStateNew, "require encryption"); err != nil {
+		panic(err)
+	}
+	c.requireEncryption = true
+}
+
 // EstablishSession performs the client session negotiation and authentication handshake.
+// If the establishment fails, an EstablishmentError is returned, which can be compared with the failure mode errors,
+// like ErrAuthenticationFailed, through errors.Is. If the server fails the session, the failed session is returned
+// without an error, and its EstablishmentError is returned by the EstablishmentErr method.
 func (c *ClientChannel) EstablishSession(
 	ctx context.Context,
 	compSelector CompressionSelector,
@@ -170,13 +225,33 @@ func (c *ClientChannel) EstablishSession(
 		panic("the authenticator should not be nil")
 	}
 
-	if c.state != SessionStateNew {
-		panic("channel state is not new")
+	if s := c.State(); s != SessionStateNew {
+		return nil, newSessionStateError("establish session", s, SessionStateNew)
 	}
+	c.authenticator = authenticator
+	c.establishmentErr = nil
+
+	c.metrics = EstablishmentMetrics{}
+	start := c.clock.Now()
+	var authStart time.Time
+	defer func() {
+		end := c.clock.Now()
+		c.metrics.Total = end.Sub(start)
+		if authStart.IsZero() {
+			c.metrics.Negotiation = c.metrics.Total - c.metrics.TLSHandshake
+		} else {
+			c.metrics.Negotiation = authStart.Sub(start) - c.metrics.TLSHandshake
+			c.metrics.Authentication = end.Sub(authStart)
+		}
+	}()
 
+	step := SessionStateNew
 	ses, err := c.startNewSession(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("establish session: %w", err)
+		return nil, c.establishmentError(step, err)
+	}
+	if ses.State == SessionStateFailed {
+		return c.failedSession(step, ses)
 	}
 
 	// Session negotiation
@@ -189,39 +264,65 @@ func (c *ClientChannel) EstablishSession(
 			panic("nil encrypt selector")
 		}
 
+		step = SessionStateNegotiating
+
 		// Select options
+		encrypt := encryptSelector(ses.EncryptionOptions)
+		if c.requireEncryption {
+			if !contains(ses.EncryptionOptions, SessionEncryptionTLS) {
+				return nil, c.abortSession(step, ErrEncryptionRequired)
+			}
+			encrypt = SessionEncryptionTLS
+		}
+
 		ses, err = c.negotiateSession(
 			ctx,
 			compSelector(ses.CompressionOptions),
-			encryptSelector(ses.EncryptionOptions))
+			encrypt)
 		if err != nil {
-			return nil, fmt.Errorf("establish session: %w", err)
+			return nil, c.establishmentError(step, err)
 		}
 
 		if ses.State == SessionStateNegotiating {
 			if ses.Compression != "" && ses.Compression != c.transport.Compression() {
 				err = c.transport.SetCompression(ctx, ses.Compression)
 				if err != nil {
-					return nil, fmt.Errorf("establish session: set compression: %w", err)
+					return nil, c.establishmentError(step, fmt.Errorf("set compression: %w", err))
 				}
 			}
 			if ses.Encryption != "" && ses.Encryption != c.transport.Encryption() {
+				tlsStart := c.clock.Now()
 				err = c.transport.SetEncryption(ctx, ses.Encryption)
+				c.metrics.TLSHandshake = c.clock.Now().Sub(tlsStart)
 				if err != nil {
-					return nil, fmt.Errorf("establish session: set encryption: %w", err)
+					return nil, c.establishmentError(step, fmt.Errorf("set encryption: %w", err))
 				}
 			}
 		}
 
 		// Await for authentication options
-		ses, err = c.receiveSessionFromServer(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("establish session: %w", err)
+		if ses.State == SessionStateNegotiating {
+			ses, err = c.receiveSessionFromServer(ctx)
+			if err != nil {
+				return nil, c.establishmentError(step, err)
+			}
+		}
+		if ses.State == SessionStateFailed {
+			return c.failedSession(step, ses)
 		}
 	}
 
+	// The credentials should not be sent in plaintext
+	if c.requireEncryption &&
+		(ses.State == SessionStateAuthenticating || ses.State == SessionStateEstablished) &&
+		c.transport.Encryption() != SessionEncryptionTLS {
+		return nil, c.abortSession(step, ErrEncryptionRequired)
+	}
+
 	// Session authentication
+	step = SessionStateAuthenticating
 	var roundTrip Authentication
+	authStart = c.clock.Now()
 
 	for ses.State == SessionStateAuthenticating {
 		ses, err = c.authenticateSession(
@@ -231,14 +332,28 @@ func (c *ClientChannel) EstablishSession(
 			instance,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("establish session: %w", err)
+			return nil, c.establishmentError(step, err)
 		}
 		roundTrip = ses.Authentication
 	}
+	if ses.State == SessionStateFailed {
+		return c.failedSession(step, ses)
+	}
+	if ses.State == SessionStateEstablished {
+		c.resumptionToken = ses.Metadata[MetadataKeyResumptionToken]
+	}
 
 	return ses, nil
 }
 
+// abortSession closes the transport during the session establishment.
+func (c *ClientChannel) abortSession(step SessionState, err error) error {
+	if closeErr := c.transport.Close(); closeErr != nil {
+		err = fmt.Errorf("%w (closing the transport failed: %v)", err, closeErr)
+	}
+	return c.establishmentError(step, err)
+}
+
 // FinishSession performs the session finishing handshake.
 func (c *ClientChannel) FinishSession(ctx context.Context) (*Session, error) {
 	if err := c.sendFinishingSession(ctx); err != nil {
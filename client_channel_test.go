@@ -203,3 +203,184 @@ func TestClientChannel_FinishSession(t *testing.T) {
 	assert.False(t, c.Established())
 	assert.False(t, c.transport.Connected())
 }
+
+// finishedFirstTransport delays the return of the finishing session send until the finished session is received.
+type finishedFirstTransport struct {
+	Transport
+	finished func() bool
+}
+
+func (t *finishedFirstTransport) Send(ctx context.Context, e envelope) error {
+	if err := t.Transport.Send(ctx, e); err != nil {
+		return err
+	}
+	if ses, ok := e.(*Session); ok && ses.State == SessionStateFinishing {
+		for !t.finished() && ctx.Err() == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return nil
+}
+
+func TestClientChannel_FinishSession_FinishedReceivedFirst(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	transport := &finishedFirstTransport{Transport: client}
+	c := NewClientChannel(transport, 1)
+	defer silentClose(c)
+	transport.finished = func() bool { return c.State() == SessionStateFinished }
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	sessionID := "52e59849-19a8-4b2d-86b7-3fa563cdb616"
+	go func() {
+		_, _ = server.Receive(ctx)
+		_ = server.Send(ctx, &Session{Envelope: Envelope{ID: sessionID}, State: SessionStateEstablished})
+		_, _ = server.Receive(ctx)
+		_ = server.Send(ctx, &Session{Envelope: Envelope{ID: sessionID}, State: SessionStateFinished})
+	}()
+	_, err := c.EstablishSession(ctx, NoneCompressionSelector, NoneEncryptionSelector, Identity{}, GuestAuthenticator, "")
+	assert.NoError(t, err)
+
+	// Act
+	actual, err := c.FinishSession(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.NotNil(t, actual) {
+		assert.Equal(t, SessionStateFinished, actual.State)
+	}
+	assert.Equal(t, SessionStateFinished, c.State())
+}
+
+func TestClientChannel_EstablishSession_RequireEncryption(t *testing.T) {
+	tests := []struct {
+		name string
+		ses  *Session
+	}{
+		{
+			"EncryptionNotOffered",
+			&Session{
+				State:              SessionStateNegotiating,
+				CompressionOptions: []SessionCompression{SessionCompressionNone},
+				EncryptionOptions:  []SessionEncryption{SessionEncryptionNone},
+			},
+		},
+		{
+			"NoNegotiation",
+			&Session{
+				State:         SessionStateAuthenticating,
+				SchemeOptions: []AuthenticationScheme{AuthenticationSchemePlain},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			defer goleak.VerifyNone(t)
+			client, server := newInProcessTransportPair("localhost", 1)
+			c := NewClientChannel(client, 1)
+			defer silentClose(c)
+			c.RequireEncryption()
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+			authenticated := false
+
+			// Act
+			go func() {
+				if _, err := server.Receive(ctx); err != nil {
+					return
+				}
+				tt.ses.ID = "52e59849-19a8-4b2d-86b7-3fa563cdb616"
+				_ = server.Send(ctx, tt.ses)
+			}()
+			_, err := c.EstablishSession(
+				ctx,
+				func(compressions []SessionCompression) SessionCompression {
+					return compressions[0]
+				},
+				func(encryptions []SessionEncryption) SessionEncryption {
+					return encryptions[0]
+				},
+				Identity{Name: "golang", Domain: "limeprotocol.org"},
+				func(schemes []AuthenticationScheme, authentication Authentication) Authentication {
+					authenticated = true
+					return &PlainAuthentication{}
+				},
+				"home",
+			)
+
+			// Assert
+			assert.ErrorIs(t, err, ErrEncryptionRequired)
+			assert.False(t, authenticated)
+			assert.False(t, c.transport.Connected())
+		})
+	}
+}
+
+func TestClientChannel_EstablishSession_WhenAlreadyEstablished(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_, err := c.EstablishSession(
+		ctx,
+		nil,
+		nil,
+		Identity{Name: "golang", Domain: "limeprotocol.org"},
+		func(schemes []AuthenticationScheme, authentication Authentication) Authentication {
+			return &GuestAuthentication{}
+		},
+		"home",
+	)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrSessionAlreadyEstablished)
+	var stateErr *SessionStateError
+	assert.ErrorAs(t, err, &stateErr)
+	assert.Equal(t, SessionStateEstablished, stateErr.State)
+	assert.Equal(t, SessionStateNew, stateErr.Expected)
+}
+
+func TestClientChannel_ProcessCommand_WhenNew(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_, err := c.ProcessCommand(ctx, createGetPingCommand())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrSessionNotEstablished)
+	assert.Equal(t, "process command: cannot do in the new state", err.Error())
+}
+
+func TestClientChannel_SendMessage_WhenFinished(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	c.setState(SessionStateFinished)
+	_ = client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(ctx, createMessage())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrSessionFinished)
+	assert.NotErrorIs(t, err, ErrSessionNotEstablished)
+}
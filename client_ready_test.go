@@ -0,0 +1,108 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+func TestClient_Ready(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("client-ready")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+
+	// Act
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		Warmup().
+		Build()
+	defer silentClose(client)
+
+	// Assert
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case err := <-client.Ready():
+		assert.NoError(t, err)
+	}
+	assert.True(t, client.channelOK())
+	err, ok := <-client.Ready()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+}
+
+func TestClient_Ready_WarmupFailed(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	dialErr := errors.New("connection refused")
+	config := NewClientConfig()
+	config.Warmup = true
+	config.NewTransport = func(ctx context.Context) (Transport, error) {
+		return nil, dialErr
+	}
+
+	// Act
+	client := NewClient(config, &EnvelopeMux{})
+	defer silentClose(client)
+
+	// Assert
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case err := <-client.Ready():
+		assert.ErrorIs(t, err, dialErr)
+	}
+}
+
+func TestClient_Ready_Closed(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	config := NewClientConfig()
+	config.NewTransport = func(ctx context.Context) (Transport, error) {
+		return nil, errors.New("connection refused")
+	}
+	client := NewClient(config, &EnvelopeMux{})
+
+	// Act
+	err := client.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, <-client.Ready(), ErrClientClosed)
+}
+
+func TestClient_Ready_ManyCallers(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	config := NewClientConfig()
+	config.NewTransport = func(ctx context.Context) (Transport, error) {
+		return nil, errors.New("connection refused")
+	}
+	client := NewClient(config, &EnvelopeMux{})
+	before1 := client.Ready()
+	before2 := client.Ready()
+
+	// Act
+	err := client.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, <-before1, ErrClientClosed)
+	assert.ErrorIs(t, <-before2, ErrClientClosed)
+	assert.ErrorIs(t, <-client.Ready(), ErrClientClosed)
+}
@@ -0,0 +1,68 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sessionSetupTimeout is the time for the server to reply each command of the session setup.
+const sessionSetupTimeout = 10 * time.Second
+
+// setupSession sets the configured presence and receipts after the session establishment, before it is used by the
+// client. If any command fails, the establishment fails and is retried by the client.
+func (c *Client) setupSession(ctx context.Context, channel *ClientChannel) error {
+	for _, s := range []struct {
+		path     string
+		resource Document
+	}{
+		{PresencePath, c.config.Presence},
+		{ReceiptPath, c.config.Receipts},
+	} {
+		if s.resource == nil {
+			continue
+		}
+		if err := setResource(ctx, channel, s.path, s.resource); err != nil {
+			return fmt.Errorf("set %v: %w", s.path, err)
+		}
+	}
+	return nil
+}
+
+func setResource(ctx context.Context, channel *ClientChannel, path string, resource Document) error {
+	ctx, cancel := context.WithTimeout(ctx, sessionSetupTimeout)
+	defer cancel()
+
+	cmd := &RequestCommand{}
+	cmd.SetURIString(path).
+		SetMethod(CommandMethodSet).
+		SetResource(resource).
+		SetNewEnvelopeID()
+	respCmd, err := channel.ProcessCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if respCmd.Status != CommandStatusSuccess {
+		if respCmd.Reason == nil {
+			return errors.New("command failed")
+		}
+		return fmt.Errorf("command failed: %w", respCmd.Reason)
+	}
+	return nil
+}
+
+// AutoPresence makes the client set the presence, like a chat.Presence value, after each session establishment,
+// before any other envelope is sent. If the command fails, the session is closed and the establishment is retried.
+func (b *ClientBuilder) AutoPresence(presence Document) *ClientBuilder {
+	b.config.Presence = presence
+	return b
+}
+
+// AutoReceipts makes the client set the receipts, like a chat.Receipt value with the notification events to be
+// received, after each session establishment and the presence. If the command fails, the session is closed and the
+// establishment is retried.
+func (b *ClientBuilder) AutoReceipts(receipts Document) *ClientBuilder {
+	b.config.Receipts = receipts
+	return b
+}
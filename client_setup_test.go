@@ -0,0 +1,124 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setupRecorder records the setup commands received by a server, failing the first ones if requested.
+type setupRecorder struct {
+	mu       sync.Mutex
+	paths    []string
+	failures int
+}
+
+func (r *setupRecorder) handle(ctx context.Context, cmd *RequestCommand, s Sender) error {
+	r.mu.Lock()
+	r.paths = append(r.paths, cmd.URI.Path())
+	fail := r.failures > 0
+	if fail {
+		r.failures--
+	}
+	r.mu.Unlock()
+	if fail {
+		return s.SendResponseCommand(ctx, cmd.FailureResponse(&Reason{Code: ReasonCodeGeneralError, Description: "Try again"}))
+	}
+	return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+}
+
+func (r *setupRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.paths...)
+}
+
+func startSetupServer(t *testing.T, addr InProcessAddr, r *setupRecorder) *Server {
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			return MemberAuthenticationResult(), nil
+		}).
+		RequestCommandHandlerFunc(
+			func(cmd *RequestCommand) bool {
+				return cmd.Method == CommandMethodSet && (cmd.URI.Path() == "/presence" || cmd.URI.Path() == "/receipt")
+			},
+			r.handle).
+		Build()
+	startServer(t, srv)
+	return srv
+}
+
+func TestClient_AutoPresence_AutoReceipts(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("client-auto-presence")
+	r := &setupRecorder{}
+	srv := startSetupServer(t, addr, r)
+	defer silentClose(srv)
+
+	// Act
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		PlainAuthentication("secret").
+		AutoPresence(&JsonDocument{"status": "available"}).
+		AutoReceipts(&JsonDocument{"events": []interface{}{"failed", "consumed"}}).
+		Build()
+	defer silentClose(client)
+	err := <-client.Ready()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/presence", "/receipt"}, r.recorded())
+}
+
+func TestClient_AutoPresence_Retry(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("client-auto-presence-retry")
+	r := &setupRecorder{failures: 1}
+	srv := startSetupServer(t, addr, r)
+	defer silentClose(srv)
+
+	// Act
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		PlainAuthentication("secret").
+		AutoPresence(&JsonDocument{"status": "available"}).
+		ReconnectBackoff(LinearBackoff{Step: time.Millisecond, Max: time.Millisecond}).
+		Build()
+	defer silentClose(client)
+	var err error
+	select {
+	case err = <-client.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("client not ready")
+	}
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/presence", "/presence"}, r.recorded())
+}
+
+func TestClient_WithoutAutoPresence(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("client-without-auto-presence")
+	r := &setupRecorder{}
+	srv := startSetupServer(t, addr, r)
+	defer silentClose(srv)
+
+	// Act
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		PlainAuthentication("secret").
+		Build()
+	defer silentClose(client)
+	err := <-client.Ready()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, r.recorded())
+}
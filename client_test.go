@@ -3,10 +3,12 @@ package lime
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/goleak"
 	"log"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -52,3 +54,240 @@ func TestClient_NewClient_Message(t *testing.T) {
 	err = client.Close()
 	assert.NoError(t, err)
 }
+
+func TestClient_NewClient_FollowRedirect(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	addr1 := InProcessAddr("redirect-server1")
+	addr2 := InProcessAddr("redirect-server2")
+	server1 := NewServerBuilder().
+		ListenInProcess(addr1).
+		EnableGuestAuthentication().
+		Established(func(sessionID string, c *ServerChannel) {
+			msg := &Message{}
+			msg.SetContent(&Redirect{Address: fmt.Sprintf("in.process://%v", addr2)})
+			_ = c.SendMessage(ctx, msg)
+		}).
+		Build()
+	defer silentClose(server1)
+	msgChan := make(chan *Message, 1)
+	establishedChan := make(chan string, 1)
+	server2 := NewServerBuilder().
+		ListenInProcess(addr2).
+		EnableGuestAuthentication().
+		Established(func(sessionID string, c *ServerChannel) {
+			establishedChan <- sessionID
+		}).
+		MessagesHandlerFunc(
+			func(ctx context.Context, msg *Message, s Sender) error {
+				msgChan <- msg
+				return nil
+			}).
+		Build()
+	defer silentClose(server2)
+	startServer(t, server1)
+	startServer(t, server2)
+	client := NewClientBuilder().
+		UseInProcess(addr1, 1).
+		GuestAuthentication().
+		FollowRedirects(nil).
+		Build()
+	msg := createMessage()
+
+	// Act
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case <-establishedChan:
+	}
+	err := client.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case rcvMsg := <-msgChan:
+		assert.Equal(t, msg.ID, rcvMsg.ID)
+	}
+	silentClose(client)
+}
+
+func TestClient_NewClient_IgnoreRedirectFromOtherNode(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	addr1 := InProcessAddr("redirect-other-server1")
+	addr2 := InProcessAddr("redirect-other-server2")
+	server1 := NewServerBuilder().
+		ListenInProcess(addr1).
+		EnableGuestAuthentication().
+		Established(func(sessionID string, c *ServerChannel) {
+			redirect := &Message{}
+			redirect.From = Node{Identity: Identity{Name: "mallory", Domain: "localhost"}}
+			redirect.SetContent(&Redirect{Address: fmt.Sprintf("in.process://%v", addr2)})
+			_ = c.SendMessage(ctx, redirect)
+			// The next message signals that the redirect was handled
+			_ = c.SendMessage(ctx, createMessage())
+		}).
+		Build()
+	defer silentClose(server1)
+	server2 := NewServerBuilder().
+		ListenInProcess(addr2).
+		EnableGuestAuthentication().
+		Build()
+	defer silentClose(server2)
+	startServer(t, server1)
+	startServer(t, server2)
+	received := make(chan *Message, 2)
+	var redirected atomic.Bool
+	client := NewClientBuilder().
+		UseInProcess(addr1, 1).
+		GuestAuthentication().
+		FollowRedirects(func(ctx context.Context, r *Redirect) (Transport, error) {
+			redirected.Store(true)
+			return DialInProcess(addr2, 1)
+		}).
+		MessagesHandlerFunc(func(ctx context.Context, msg *Message, s Sender) error {
+			received <- msg
+			return nil
+		}).
+		Build()
+	defer silentClose(client)
+
+	// Act
+	var msg *Message
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case msg = <-received:
+	}
+
+	// Assert
+	assert.Equal(t, MediaTypeTextPlain(), msg.Type)
+	assert.False(t, redirected.Load())
+	client.mu.RLock()
+	assert.Nil(t, client.redirect)
+	client.mu.RUnlock()
+}
+
+func TestClient_SendMessageWithRetransmission(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	addr1 := createLocalhostTCPAddress().(*net.TCPAddr)
+	var count atomic.Int32
+	server := NewServerBuilder().
+		ListenTCP(addr1, nil).
+		EnableGuestAuthentication().
+		MessagesHandlerFunc(
+			func(ctx context.Context, msg *Message, s Sender) error {
+				// Ignores the first transmission
+				if count.Add(1) == 1 {
+					return nil
+				}
+				return s.SendNotification(ctx, msg.Notification(NotificationEventReceived))
+			}).
+		Build()
+	defer silentClose(server)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, ErrServerClosed) {
+			log.Println(err)
+		}
+	}()
+	config := NewClientConfig()
+	config.EncryptSelector = NoneEncryptionSelector
+	config.NewTransport = func(ctx context.Context) (Transport, error) {
+		return DialTcp(ctx, addr1, nil)
+	}
+	config.NotificationTracker = NewNotificationTracker()
+	client := NewClient(config, &EnvelopeMux{})
+	msg := createMessage()
+	policy := RetransmissionPolicy{Timeout: 50 * time.Millisecond, MaxRetries: 2}
+
+	// Act
+	status, err := client.SendMessageWithRetransmission(ctx, msg, policy)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, NotificationEventReceived, status.Event)
+	assert.Equal(t, int32(2), count.Load())
+	err = client.Close()
+	assert.NoError(t, err)
+}
+
+func TestClient_SendMessageWithRetransmission_NotAcknowledged(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	addr1 := createLocalhostTCPAddress().(*net.TCPAddr)
+	var count atomic.Int32
+	server := NewServerBuilder().
+		ListenTCP(addr1, nil).
+		EnableGuestAuthentication().
+		MessagesHandlerFunc(
+			func(ctx context.Context, msg *Message, s Sender) error {
+				count.Add(1)
+				return s.SendNotification(ctx, msg.Notification(NotificationEventAccepted))
+			}).
+		Build()
+	defer silentClose(server)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, ErrServerClosed) {
+			log.Println(err)
+		}
+	}()
+	config := NewClientConfig()
+	config.EncryptSelector = NoneEncryptionSelector
+	config.NewTransport = func(ctx context.Context) (Transport, error) {
+		return DialTcp(ctx, addr1, nil)
+	}
+	config.NotificationTracker = NewNotificationTracker()
+	client := NewClient(config, &EnvelopeMux{})
+	msg := createMessage()
+	policy := RetransmissionPolicy{Timeout: 30 * time.Millisecond, MaxRetries: 2}
+
+	// Act
+	status, err := client.SendMessageWithRetransmission(ctx, msg, policy)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrMessageNotAcknowledged)
+	assert.Equal(t, NotificationEventAccepted, status.Event)
+	assert.Equal(t, int32(3), count.Load())
+	err = client.Close()
+	assert.NoError(t, err)
+}
+
+func TestNewClient_SharedMux(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	config := NewClientConfig()
+	config.NewTransport = func(ctx context.Context) (Transport, error) {
+		return nil, errors.New("connection refused")
+	}
+	config.NotificationTracker = NewNotificationTracker()
+	config.MessageStore = NewMemoryMessageStore()
+	mux := &EnvelopeMux{}
+	mux.MessageHandlerFunc(func(msg *Message) bool { return true }, func(ctx context.Context, msg *Message, s Sender) error {
+		return nil
+	})
+
+	// Act
+	client1 := NewClient(config, mux)
+	client2 := NewClient(config, mux)
+	_ = client1.Close()
+	_ = client2.Close()
+
+	// Assert
+	assert.Len(t, mux.msgHandlers, 1)
+	assert.Empty(t, mux.notHandlers)
+	assert.Empty(t, mux.reqCmdHandlers)
+	assert.Nil(t, mux.store)
+	assert.Len(t, client1.mux.notHandlers, 1)
+	assert.Len(t, client2.mux.reqCmdHandlers, 1)
+}
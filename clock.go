@@ -0,0 +1,95 @@
+package lime
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides the current time and the timers for the time-dependent components, like the caches, the
+// notification tracker and the client retries. It allows the timing behavior to be tested deterministically with a
+// fake implementation, like the limetest.FakeClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a Timer that sends the current time on its channel after the duration.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event timer created by a Clock.
+type Timer interface {
+	// C returns the channel where the time is sent when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, returning false if it already fired or was stopped.
+	Stop() bool
+}
+
+// SystemClock is the Clock of the time package, which is used when none is defined.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+// clockOrSystem returns the clock, or the SystemClock if it is nil.
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
+
+// sleep waits for the duration in the clock or until the context is done.
+func sleep(ctx context.Context, c Clock, d time.Duration) error {
+	timer := c.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// withClockTimeout is like context.WithTimeout, but the timeout is measured in the clock. When the timeout expires,
+// the context is canceled with the context.DeadlineExceeded cause.
+func withClockTimeout(ctx context.Context, c Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := c.NewTimer(timeout)
+	goLabeled(ctx, "clock.timeout", func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+		case <-timer.C():
+			cancel(context.DeadlineExceeded)
+		}
+	})
+	return ctx, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// SetClock defines the clock for the timestamps of the channel, like the ones of the route hops.
+func (c *channel) SetClock(clock Clock) {
+	if err := c.ensureState(SessionStateNew, "set clock"); err != nil {
+		panic(err)
+	}
+	c.clock = clockOrSystem(clock)
+}
@@ -0,0 +1,150 @@
+package lime
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+)
+
+// CloneDocument returns a deep copy of the document, which can be changed without affecting the original one.
+// The pointer documents are copied by their JSON representation into a new value of the same type, so only the
+// serialized fields are kept, except the StreamedDocument ones, whose files are copied. The non-pointer documents, like TextDocument, are returned as is.
+func CloneDocument(d Document) (Document, error) {
+	if d == nil {
+		return nil, nil
+	}
+	v := reflect.ValueOf(d)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return d, nil
+	}
+	if sd, ok := d.(*StreamedDocument); ok {
+		// The streamed documents are copied by their files, since they are not decoded
+		clone, err := sd.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("clone document: %w", err)
+		}
+		return clone, nil
+	}
+	if jd, ok := d.(*JsonDocument); ok {
+		clone := JsonDocument(cloneJSONValue(map[string]interface{}(*jd)).(map[string]interface{}))
+		return &clone, nil
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("clone document: %w", err)
+	}
+	clone := reflect.New(v.Type().Elem())
+	if err := json.Unmarshal(b, clone.Interface()); err != nil {
+		return nil, fmt.Errorf("clone document: %w", err)
+	}
+	return clone.Interface().(Document), nil
+}
+
+// cloneJSONValue copies the maps and slices of a decoded JSON value, keeping the other values as is.
+func cloneJSONValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if t == nil {
+			return t
+		}
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[k] = cloneJSONValue(e)
+		}
+		return m
+	case []interface{}:
+		if t == nil {
+			return t
+		}
+		s := make([]interface{}, len(t))
+		for i, e := range t {
+			s[i] = cloneJSONValue(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func (env *Envelope) clone() Envelope {
+	c := *env
+	c.Metadata = maps.Clone(env.Metadata)
+	c.annotations = maps.Clone(env.annotations)
+	if env.Extensions != nil {
+		c.Extensions = cloneJSONValue(env.Extensions).(map[string]interface{})
+	}
+	return c
+}
+
+// Clone returns a deep copy of the message, including its metadata and content, so it can be changed, for instance,
+// for different destinations without affecting the original one.
+// It returns an error if the content cannot be serialized.
+func (msg *Message) Clone() (*Message, error) {
+	content, err := CloneDocument(msg.Content)
+	if err != nil {
+		return nil, fmt.Errorf("clone message: %w", err)
+	}
+	c := *msg
+	c.Envelope = msg.Envelope.clone()
+	c.Content = content
+	return &c, nil
+}
+
+// Clone returns a deep copy of the notification, including its metadata and reason.
+func (not *Notification) Clone() *Notification {
+	c := *not
+	c.Envelope = not.Envelope.clone()
+	if not.Reason != nil {
+		r := *not.Reason
+		c.Reason = &r
+	}
+	return &c
+}
+
+func (cmd *Command) clone() (Command, error) {
+	resource, err := CloneDocument(cmd.Resource)
+	if err != nil {
+		return Command{}, err
+	}
+	c := *cmd
+	c.Envelope = cmd.Envelope.clone()
+	if cmd.Type != nil {
+		t := *cmd.Type
+		c.Type = &t
+	}
+	c.Resource = resource
+	return c, nil
+}
+
+// Clone returns a deep copy of the request command, including its metadata, URI and resource.
+// It returns an error if the resource cannot be serialized.
+func (cmd *RequestCommand) Clone() (*RequestCommand, error) {
+	command, err := cmd.Command.clone()
+	if err != nil {
+		return nil, fmt.Errorf("clone request command: %w", err)
+	}
+	c := *cmd
+	c.Command = command
+	if cmd.URI != nil {
+		c.URI = &URI{url: cmd.URI.URL()}
+	}
+	return &c, nil
+}
+
+// Clone returns a deep copy of the response command, including its metadata, resource and reason.
+// It returns an error if the resource cannot be serialized.
+func (cmd *ResponseCommand) Clone() (*ResponseCommand, error) {
+	command, err := cmd.Command.clone()
+	if err != nil {
+		return nil, fmt.Errorf("clone response command: %w", err)
+	}
+	c := *cmd
+	c.Command = command
+	if cmd.Reason != nil {
+		r := *cmd.Reason
+		c.Reason = &r
+	}
+	return &c, nil
+}
@@ -0,0 +1,147 @@
+package lime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessage_Clone(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.SetMetadataKeyValue("key", "value")
+
+	// Act
+	clone, err := msg.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.To.Instance = "other"
+	clone.SetMetadataKeyValue("key", "changed")
+	*clone.Content.(*TextDocument) = "Changed"
+
+	// Assert
+	assert.Equal(t, "default", msg.To.Instance)
+	assert.Equal(t, "value", msg.Metadata["key"])
+	assert.Equal(t, TextDocument("Hello world"), *msg.Content.(*TextDocument))
+	assert.Equal(t, msg.Type, clone.Type)
+}
+
+func TestMessage_Clone_JsonDocument(t *testing.T) {
+	// Arrange
+	msg := &Message{}
+	msg.SetContent(&JsonDocument{
+		"name":  "John",
+		"tags":  []interface{}{"a", "b"},
+		"extra": map[string]interface{}{"age": 42},
+	})
+
+	// Act
+	clone, err := msg.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := *clone.Content.(*JsonDocument)
+	d["tags"].([]interface{})[0] = "c"
+	d["extra"].(map[string]interface{})["age"] = 43
+
+	// Assert
+	original := *msg.Content.(*JsonDocument)
+	assert.Equal(t, "a", original["tags"].([]interface{})[0])
+	assert.Equal(t, 42, original["extra"].(map[string]interface{})["age"])
+}
+
+func TestNotification_Clone(t *testing.T) {
+	// Arrange
+	not := createNotification()
+	not.Event = NotificationEventFailed
+	not.Reason = &Reason{Code: 1, Description: "Failure"}
+
+	// Act
+	clone := not.Clone()
+	clone.Reason.Description = "Changed"
+
+	// Assert
+	assert.Equal(t, "Failure", not.Reason.Description)
+	assert.Equal(t, not.ID, clone.ID)
+}
+
+func TestRequestCommand_Clone(t *testing.T) {
+	// Arrange
+	cmd := &RequestCommand{}
+	cmd.SetURIString("/contacts").
+		SetMethod(CommandMethodSet).
+		SetResource(&DocumentCollection{
+			Total:    1,
+			ItemType: MediaTypeTextPlain(),
+			Items:    []Document{TextDocument("Item")},
+		})
+
+	// Act
+	clone, err := cmd.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.Resource.(*DocumentCollection).Items[0] = TextDocument("Changed")
+	clone.Type.Subtype = "changed"
+
+	// Assert
+	assert.Equal(t, TextDocument("Item"), cmd.Resource.(*DocumentCollection).Items[0])
+	assert.Equal(t, "vnd.lime.collection", cmd.Type.Subtype)
+	assert.Equal(t, cmd.URI.String(), clone.URI.String())
+	assert.NotSame(t, cmd.URI, clone.URI)
+}
+
+func TestResponseCommand_Clone(t *testing.T) {
+	// Arrange
+	cmd := &ResponseCommand{}
+	cmd.ID = "1"
+	cmd.SetStatusFailure(Reason{Code: 1, Description: "Failure"})
+
+	// Act
+	clone, err := cmd.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.Reason.Code = 2
+
+	// Assert
+	assert.Equal(t, 1, cmd.Reason.Code)
+	assert.Equal(t, CommandStatusFailure, clone.Status)
+}
+
+// unserializableDocument is a document that cannot be serialized, like one with a channel field.
+type unserializableDocument struct {
+	Values chan int `json:"values"`
+}
+
+func (d *unserializableDocument) MediaType() MediaType {
+	return MediaType{Type: "application", Subtype: "x-unserializable", Suffix: "json"}
+}
+
+func TestMessage_Clone_UnserializableContent(t *testing.T) {
+	// Arrange
+	msg := &Message{}
+	msg.SetContent(&unserializableDocument{Values: make(chan int)})
+
+	// Act
+	clone, err := msg.Clone()
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, clone)
+}
+
+func TestRequestCommand_Clone_UnserializableResource(t *testing.T) {
+	// Arrange
+	cmd := &RequestCommand{}
+	cmd.SetURIString("/values").
+		SetMethod(CommandMethodSet).
+		SetResource(&unserializableDocument{Values: make(chan int)})
+
+	// Act
+	clone, err := cmd.Clone()
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, clone)
+}
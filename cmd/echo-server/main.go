@@ -0,0 +1,112 @@
+// Command echo-server is a Lime server that echoes the messages back to their senders, which can be used as an
+// integration test target and as an example of a server.
+//
+// It accepts TCP and websocket connections and authenticates the clients of any name with a static key:
+//
+//	echo-server -key mysecretkey -tcp :55321 -ws :8080
+//
+// Each message received is replied with a message with the same content, followed by a consumed notification, if the
+// message has an ID. The pings are also replied.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"github.com/phonero/lime"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	tcpAddr := flag.String("tcp", ":55321", "the TCP listener address, or empty to disable it")
+	wsAddr := flag.String("ws", ":8080", "the websocket listener address, or empty to disable it")
+	key := flag.String("key", os.Getenv("ECHO_SERVER_KEY"), "the key of the clients, which defaults to the ECHO_SERVER_KEY variable")
+	domain := flag.String("domain", "localhost", "the domain of the server node")
+	trace := flag.Bool("trace", false, "write the envelopes to the standard output")
+	flag.Parse()
+
+	if *key == "" {
+		log.Fatalln("echo-server: the key is required")
+	}
+	if *tcpAddr == "" && *wsAddr == "" {
+		log.Fatalln("echo-server: at least one listener is required")
+	}
+
+	var traceWriter lime.TraceWriter
+	if *trace {
+		traceWriter = lime.NewStdoutTraceWriter()
+	}
+
+	b := newServerBuilder(*domain, *key)
+	if *tcpAddr != "" {
+		addr, err := net.ResolveTCPAddr("tcp", *tcpAddr)
+		if err != nil {
+			log.Fatalf("echo-server: tcp address: %v\n", err)
+		}
+		b.ListenTCP(addr, &lime.TCPConfig{TraceWriter: traceWriter})
+	}
+	if *wsAddr != "" {
+		addr, err := net.ResolveTCPAddr("tcp", *wsAddr)
+		if err != nil {
+			log.Fatalf("echo-server: websocket address: %v\n", err)
+		}
+		b.ListenWebsocket(addr, &lime.WebsocketConfig{
+			TraceWriter: traceWriter,
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		})
+	}
+	server := b.Build()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.ListenAndServe(); err != lime.ErrServerClosed {
+			log.Printf("echo-server: listen: %v\n", err)
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	fmt.Printf("Listening at tcp '%v' and websocket '%v'. Press Ctrl+C to stop.\n", *tcpAddr, *wsAddr)
+	select {
+	case <-sig:
+	case <-done:
+		os.Exit(1)
+	}
+
+	if err := server.Close(); err != nil {
+		log.Printf("echo-server: close: %v\n", err)
+	}
+}
+
+// newServerBuilder creates the builder of the echo server, without the listeners.
+func newServerBuilder(domain, key string) *lime.ServerBuilder {
+	return lime.NewServerBuilder().
+		Domain(domain).
+		MessagesHandlerFunc(echo).
+		AutoReplyPings().
+		EnableKeyAuthentication(func(ctx context.Context, identity lime.Identity, k string) (*lime.AuthenticationResult, error) {
+			if subtle.ConstantTimeCompare([]byte(k), []byte(key)) != 1 {
+				return lime.UnknownAuthenticationResult(), nil
+			}
+			return lime.MemberAuthenticationResult(), nil
+		})
+}
+
+// echo replies the message with its content and notifies that it was consumed.
+func echo(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+	if err := s.SendMessage(ctx, msg.Reply(msg.Content)); err != nil {
+		return err
+	}
+	if msg.ID == "" {
+		return nil
+	}
+	return s.SendNotification(ctx, msg.Notification(lime.NotificationEventConsumed))
+}
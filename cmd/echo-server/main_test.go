@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func startEchoServer(t *testing.T, addr lime.InProcessAddr) *lime.Server {
+	srv := newServerBuilder("localhost", "secret").
+		ListenInProcess(addr).
+		Build()
+	go func() {
+		_ = srv.ListenAndServe()
+	}()
+	// The first connection attempt of the clients should reach the server
+	listening := assert.Eventually(t, func() bool {
+		transport, err := lime.DialInProcess(addr, 1)
+		if err != nil {
+			return false
+		}
+		_ = transport.Close()
+		return true
+	}, time.Second, time.Millisecond)
+	if !listening {
+		t.FailNow()
+	}
+	return srv
+}
+
+func TestEcho(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	addr := lime.InProcessAddr("echo-server")
+	srv := startEchoServer(t, addr)
+	defer srv.Close()
+	messages := make(chan *lime.Message, 1)
+	notifications := make(chan *lime.Notification, 1)
+	client := lime.NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		KeyAuthentication("secret").
+		MessagesHandlerFunc(func(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+			messages <- msg
+			return nil
+		}).
+		NotificationsHandlerFunc(func(ctx context.Context, not *lime.Notification) error {
+			notifications <- not
+			return nil
+		}).
+		Build()
+	defer client.Close()
+	msg := &lime.Message{}
+	d := lime.TextDocument("Hello world")
+	msg.SetContent(&d).SetNewEnvelopeID()
+
+	// Act
+	err := client.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case echoMsg := <-messages:
+		assert.Equal(t, msg.Content, echoMsg.Content)
+		assert.Equal(t, msg.ID, echoMsg.ReplyTo())
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case not := <-notifications:
+		assert.Equal(t, msg.ID, not.ID)
+		assert.Equal(t, lime.NotificationEventConsumed, not.Event)
+	}
+}
+
+func TestEcho_InvalidKey(t *testing.T) {
+	// Arrange
+	addr := lime.InProcessAddr("echo-server-invalid-key")
+	srv := startEchoServer(t, addr)
+	defer srv.Close()
+
+	// Act
+	client := lime.NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		KeyAuthentication("wrong").
+		Warmup().
+		Build()
+	defer client.Close()
+	err := <-client.Ready()
+
+	// Assert
+	assert.ErrorIs(t, err, lime.ErrAuthenticationFailed)
+}
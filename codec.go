@@ -0,0 +1,185 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// MetadataKeySessionCodecs is the new session metadata key that holds the codec names supported by the client,
+	// separated by commas and in the preference order.
+	MetadataKeySessionCodecs = "#codecs"
+	// MetadataKeySessionCodec is the established session metadata key that holds the codec name selected by the
+	// server. If absent, the session keeps using the JSON codec.
+	MetadataKeySessionCodec = "#codec"
+)
+
+// Codec defines a serialization format for the envelopes in a transport stream.
+// The encoded and decoded values are compatible with the encoding/json package, so the codec implementations can
+// rely on the JSON marshaling methods and field tags.
+type Codec interface {
+	Name() string                   // Name is the codec identifier used in the session negotiation, like 'msgpack'.
+	NewEncoder(w io.Writer) Encoder // NewEncoder creates an encoder that writes to the specified writer.
+	NewDecoder(r io.Reader) Decoder // NewDecoder creates a decoder that reads from the specified reader.
+}
+
+// Encoder writes encoded values to an output stream.
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads and decodes values from an input stream.
+type Decoder interface {
+	Decode(v any) error
+}
+
+// CodecTransport is implemented by the transports that support changing the envelope serialization format after
+// the session establishment.
+type CodecTransport interface {
+	Transport
+	Codec() Codec                                // Codec returns the current transport codec.
+	SetCodec(ctx context.Context, c Codec) error // SetCodec defines the codec for the next sent and received envelopes.
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+// CodecJSON is the default codec, which is used by the transports before the session establishment.
+var CodecJSON Codec = jsonCodec{}
+
+// SetCodecs defines the codecs supported by the channel for the negotiation during the session establishment, in
+// the preference order. The negotiation only happens if the transport implements the CodecTransport interface, and
+// the session keeps using the JSON codec if the remote party ignores the negotiation.
+func (c *channel) SetCodecs(codecs ...Codec) {
+	if err := c.ensureState(SessionStateNew, "set codecs"); err != nil {
+		panic(err)
+	}
+	c.codecs = codecs
+}
+
+// offerCodecs adds the supported codecs to the new session sent by the client.
+func (c *channel) offerCodecs(ses *Session) {
+	if len(c.codecs) == 0 {
+		return
+	}
+	if _, ok := c.transport.(CodecTransport); !ok {
+		return
+	}
+	names := make([]string, len(c.codecs))
+	for i, codec := range c.codecs {
+		names[i] = codec.Name()
+	}
+	ses.SetMetadataKeyValue(MetadataKeySessionCodecs, strings.Join(names, ","))
+}
+
+// selectCodec chooses the first codec offered by the client in the new session that is supported by the server.
+func (c *channel) selectCodec(ses *Session) Codec {
+	if len(c.codecs) == 0 {
+		return nil
+	}
+	if _, ok := c.transport.(CodecTransport); !ok {
+		return nil
+	}
+	offered, ok := ses.Metadata[MetadataKeySessionCodecs]
+	if !ok {
+		return nil
+	}
+	for _, name := range strings.Split(offered, ",") {
+		if codec := c.findCodec(strings.TrimSpace(name)); codec != nil {
+			return codec
+		}
+	}
+	return nil
+}
+
+func (c *channel) findCodec(name string) Codec {
+	for _, codec := range c.codecs {
+		if codec.Name() == name {
+			return codec
+		}
+	}
+	return nil
+}
+
+// switchCodec changes the transport codec, which should happen before the channel receiver starts.
+func (c *channel) switchCodec(ctx context.Context, codec Codec) error {
+	t, ok := c.transport.(CodecTransport)
+	if !ok {
+		return errors.New("set codec: the transport does not support codecs")
+	}
+	if t.Codec() != nil && t.Codec().Name() == codec.Name() {
+		return nil
+	}
+	if err := t.SetCodec(ctx, codec); err != nil {
+		return fmt.Errorf("set codec: %w", err)
+	}
+	return nil
+}
+
+// hasJSONValue indicates if the data of the reader starts with a complete JSON object or non-empty array, which
+// can be decoded without reading more data. The data is scanned up to the end of the value, without decoding it.
+func hasJSONValue(r io.Reader) bool {
+	var (
+		buf      [512]byte
+		depth    int
+		inString bool
+		escaped  bool
+		empty    bool // empty indicates that the top-level array has no elements so far
+	)
+	for {
+		n, err := r.Read(buf[:])
+		for _, b := range buf[:n] {
+			switch {
+			case escaped:
+				escaped = false
+			case inString:
+				if b == '\\' {
+					escaped = true
+				} else if b == '"' {
+					inString = false
+				}
+			case b == ' ' || b == '\t' || b == '\r' || b == '\n':
+			case depth == 0 && b != '{' && b != '[':
+				return false
+			case b == '{' || b == '[':
+				if depth == 0 {
+					empty = b == '['
+				} else {
+					empty = false
+				}
+				depth++
+			case b == '}' || b == ']':
+				depth--
+				if depth == 0 {
+					// The empty arrays are skipped by the transports, which wait for the next value
+					if !empty {
+						return true
+					}
+				}
+			default:
+				if b == '"' {
+					inString = true
+				}
+				empty = false
+			}
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
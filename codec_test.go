@@ -0,0 +1,182 @@
+package lime
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// base64Codec encodes each JSON value as a base64 line, which is incompatible with the JSON decoder.
+type base64Codec struct{}
+
+func (base64Codec) Name() string {
+	return "base64"
+}
+
+func (base64Codec) NewEncoder(w io.Writer) Encoder {
+	return &base64Encoder{w: w}
+}
+
+func (base64Codec) NewDecoder(r io.Reader) Decoder {
+	return &base64Decoder{r: bufio.NewReader(r)}
+}
+
+type base64Encoder struct {
+	w io.Writer
+}
+
+func (e *base64Encoder) Encode(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write([]byte(base64.StdEncoding.EncodeToString(b) + "\n"))
+	return err
+}
+
+type base64Decoder struct {
+	r *bufio.Reader
+}
+
+func (d *base64Decoder) Decode(v any) error {
+	line, err := d.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	b, err := base64.StdEncoding.DecodeString(line[:len(line)-1])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func establishTCPCodecSession(t *testing.T, ctx context.Context, serverCodecs []Codec, clientCodecs []Codec) (*ClientChannel, chan *Message, func()) {
+	addr := createLocalhostTCPAddress()
+	config := NewServerConfig()
+	config.SchemeOpts = []AuthenticationScheme{AuthenticationSchemeGuest}
+	config.Codecs = serverCodecs
+	msgChan := make(chan *Message, 1)
+	mux := &EnvelopeMux{}
+	mux.MessageHandlerFunc(
+		func(*Message) bool {
+			return true
+		},
+		func(ctx context.Context, msg *Message, s Sender) error {
+			msgChan <- msg
+			return nil
+		})
+	srv := NewServer(config, mux, createBoundTCPTransportListener(addr))
+	startServer(t, srv)
+
+	client, err := DialTcp(ctx, addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := NewClientChannel(client, 1)
+	channel.SetCodecs(clientCodecs...)
+	ses, err := channel.EstablishSession(
+		ctx,
+		func([]SessionCompression) SessionCompression {
+			return SessionCompressionNone
+		},
+		func([]SessionEncryption) SessionEncryption {
+			return SessionEncryptionNone
+		},
+		Identity{
+			Name:   "client1",
+			Domain: "localhost",
+		},
+		func([]AuthenticationScheme, Authentication) Authentication {
+			return &GuestAuthentication{}
+		},
+		"default")
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStateEstablished, ses.State)
+
+	return channel, msgChan, func() {
+		// Closing the server first unblocks the channel receiver
+		silentClose(srv)
+		silentClose(channel)
+	}
+}
+
+func TestChannel_EstablishSession_NegotiateCodec(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	channel, msgChan, closeAll := establishTCPCodecSession(t, ctx, []Codec{base64Codec{}}, []Codec{base64Codec{}, CodecJSON})
+	defer closeAll()
+	msg := createMessage()
+
+	// Act
+	err := channel.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "base64", channel.transport.(CodecTransport).Codec().Name())
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "receive message timeout")
+	case receivedMsg := <-msgChan:
+		assert.Equal(t, msg, receivedMsg)
+	}
+}
+
+func TestChannel_EstablishSession_CodecIgnoredByServer(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	channel, msgChan, closeAll := establishTCPCodecSession(t, ctx, nil, []Codec{base64Codec{}})
+	defer closeAll()
+	msg := createMessage()
+
+	// Act
+	err := channel.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CodecJSON, channel.transport.(CodecTransport).Codec())
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "receive message timeout")
+	case receivedMsg := <-msgChan:
+		assert.Equal(t, msg, receivedMsg)
+	}
+}
+
+func TestHasJSONValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected bool
+	}{
+		{"Object", `{"id":"1"}`, true},
+		{"Array", ` [{"id":"1"}]`, true},
+		{"Incomplete", `{"id":"1"`, false},
+		{"EscapedQuote", `{"id":"\"}"`, false},
+		{"BracesInString", `{"id":"}{"}`, true},
+		{"EmptyArray", `[ ]`, false},
+		{"EmptyArrayAndObject", `[]{"id":"1"}`, true},
+		{"Empty", "", false},
+		{"Whitespace", " \r\n", false},
+		{"Scalar", `null`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual := hasJSONValue(strings.NewReader(tt.data))
+
+			// Assert
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package lime
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/url"
 )
@@ -38,7 +37,7 @@ func (cmd *Command) toRawEnvelope() (*rawEnvelope, error) {
 	if cmd.Resource != nil {
 		b, err := json.Marshal(cmd.Resource)
 		if err != nil {
-			return nil, err
+			return nil, newEnvelopeError(commandKind(raw), cmd.ID, "resource", err)
 		}
 		r := json.RawMessage(b)
 		raw.Resource = &r
@@ -60,20 +59,24 @@ func (cmd *Command) populate(raw *rawEnvelope) error {
 	// Create the document type instance and unmarshal the json to it
 	if raw.Resource != nil {
 		if raw.Type == nil {
-			return errors.New("command resource type is required when resource is present")
+			return newEnvelopeError(commandKind(raw), raw.ID, "type", ErrFieldRequired)
 		}
 
-		document, err := UnmarshalDocument(raw.Resource, *raw.Type)
+		document, err := unmarshalRawDocument(raw, raw.Resource, *raw.Type)
 		if err != nil {
-			return err
+			return newEnvelopeError(commandKind(raw), raw.ID, "resource", err)
 		}
 
 		cmd.Resource = document
 		cmd.Type = raw.Type
+		if raw.streamed == nil {
+			annotateReceivedContentHash(&cmd.Envelope, raw.Resource)
+			annotateReceivedMergePatch(cmd, raw.Resource, raw.Method)
+		}
 	}
 
 	if raw.Method == nil {
-		return errors.New("command method is required")
+		return newEnvelopeError(commandKind(raw), raw.ID, "method", ErrFieldRequired)
 	}
 
 	cmd.Method = *raw.Method
@@ -81,6 +84,17 @@ func (cmd *Command) populate(raw *rawEnvelope) error {
 	return nil
 }
 
+// commandKind returns the command envelope type for the errors.
+func commandKind(raw *rawEnvelope) string {
+	if raw.URI != nil {
+		return envelopeKindRequestCommand
+	}
+	if raw.Status != nil {
+		return envelopeKindResponseCommand
+	}
+	return envelopeKindCommand
+}
+
 // RequestCommand represents a request for a resource that can be sent to a remote party.
 type RequestCommand struct {
 	Command
@@ -120,7 +134,7 @@ func (cmd *RequestCommand) SuccessResponse() *ResponseCommand {
 // SuccessResponseWithResource creates a success response Command for the current request.
 func (cmd *RequestCommand) SuccessResponseWithResource(resource Document) *ResponseCommand {
 	respCmd := cmd.SuccessResponse()
-	respCmd.Resource = resource
+	respCmd.SetResource(resource)
 	return respCmd
 }
 
@@ -145,7 +159,7 @@ func (cmd *RequestCommand) MarshalJSON() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(raw)
+	return marshalRawEnvelope(envelopeKindRequestCommand, raw)
 }
 
 func (cmd *RequestCommand) UnmarshalJSON(b []byte) error {
@@ -203,7 +217,7 @@ func (cmd *ResponseCommand) MarshalJSON() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(raw)
+	return marshalRawEnvelope(envelopeKindResponseCommand, raw)
 }
 
 func (cmd *ResponseCommand) UnmarshalJSON(b []byte) error {
@@ -309,6 +323,9 @@ type CommandStatus string
 const (
 	CommandStatusSuccess = CommandStatus("success")
 	CommandStatusFailure = CommandStatus("failure")
+	// CommandStatusPending indicates an intermediate response of a long-running command, which is followed by the
+	// final success or failure response.
+	CommandStatusPending = CommandStatus("pending")
 )
 
 const URISchemeLime = "lime"
@@ -366,7 +383,8 @@ func (u *URI) Owner() *Identity {
 	if u.url == nil || u.url.User == nil {
 		return nil
 	}
-	i := ParseIdentity(u.url.User.Username())
+	// The identity domain is the URI host, like in 'lime://name@domain/presence'
+	i := Identity{Name: u.url.User.Username(), Domain: u.url.Hostname()}
 	return &i
 }
 
@@ -0,0 +1,158 @@
+package lime
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ResourceCache stores the results of get commands, avoiding repeated round-trips for resources that rarely change,
+// like the account and the contacts list.
+// The entries are keyed by the destination, URI and media type of the command, and are invalidated after the TTL or
+// when a set, merge or delete command is sent to the same URI.
+type ResourceCache struct {
+	ttl     time.Duration
+	clock   Clock
+	mu      sync.Mutex
+	entries map[resourceCacheKey]resourceCacheEntry
+}
+
+type resourceCacheKey struct {
+	to        string
+	uri       string
+	mediaType string
+}
+
+type resourceCacheEntry struct {
+	respCmd *ResponseCommand
+	expires time.Time
+}
+
+// NewResourceCache creates a new ResourceCache which keeps the resources for the specified duration.
+func NewResourceCache(ttl time.Duration) *ResourceCache {
+	if ttl <= 0 {
+		panic("the ttl should be positive")
+	}
+	return &ResourceCache{
+		ttl:     ttl,
+		clock:   SystemClock,
+		entries: make(map[resourceCacheKey]resourceCacheEntry),
+	}
+}
+
+// SetClock defines the clock for the expiration of the resources. It should be called before the cache is used.
+func (c *ResourceCache) SetClock(clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clockOrSystem(clock)
+}
+
+// Invalidate removes all the cached resources for the specified URI, regardless of the media type.
+func (c *ResourceCache) Invalidate(uri *URI) {
+	if uri == nil {
+		return
+	}
+	c.invalidate(uri.String())
+}
+
+// Clear removes all the cached resources.
+func (c *ResourceCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[resourceCacheKey]resourceCacheEntry)
+}
+
+// Len returns the number of cached resources, including the expired ones that were not evicted yet.
+func (c *ResourceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// processCommand returns the cached response for the get commands or calls the process function, caching its
+// successful responses.
+func (c *ResourceCache) processCommand(reqCmd *RequestCommand, process func() (*ResponseCommand, error)) (*ResponseCommand, error) {
+	if reqCmd.URI == nil {
+		return process()
+	}
+
+	switch reqCmd.Method {
+	case CommandMethodGet:
+		key := newResourceCacheKey(reqCmd)
+		if respCmd, ok := c.get(key); ok {
+			// The response should match the request
+			respCmd.ID = reqCmd.ID
+			return respCmd, nil
+		}
+		respCmd, err := process()
+		if err == nil && respCmd != nil && respCmd.Status == CommandStatusSuccess {
+			c.set(key, respCmd)
+		}
+		return respCmd, err
+	case CommandMethodSet, CommandMethodMerge, CommandMethodDelete:
+		// Invalidates even in case of failure, since the resource state is unknown
+		defer c.invalidate(reqCmd.URI.String())
+		return process()
+	default:
+		return process()
+	}
+}
+
+func (c *ResourceCache) get(key resourceCacheKey) (*ResponseCommand, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.clock.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	// Returns a copy, since the response ID is changed and the resource may be changed by the caller
+	respCmd, err := entry.respCmd.Clone()
+	if err != nil {
+		log.Printf("resource cache: %v", err)
+		return nil, false
+	}
+	return respCmd, true
+}
+
+func (c *ResourceCache) set(key resourceCacheKey, respCmd *ResponseCommand) {
+	// The responses that cannot be copied are not cached, since the caller may change them
+	copied, err := respCmd.Clone()
+	if err != nil {
+		log.Printf("resource cache: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = resourceCacheEntry{
+		respCmd: copied,
+		expires: c.clock.Now().Add(c.ttl),
+	}
+}
+
+func (c *ResourceCache) invalidate(uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.uri == uri {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func newResourceCacheKey(reqCmd *RequestCommand) resourceCacheKey {
+	key := resourceCacheKey{
+		to:  reqCmd.To.String(),
+		uri: reqCmd.URI.String(),
+	}
+	if reqCmd.Type != nil {
+		key.mediaType = reqCmd.Type.String()
+	}
+	return key
+}
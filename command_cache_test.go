@@ -0,0 +1,144 @@
+package lime
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newCountingProcess(respCmd *ResponseCommand, err error) (func() (*ResponseCommand, error), *int) {
+	count := 0
+	return func() (*ResponseCommand, error) {
+		count++
+		return respCmd, err
+	}, &count
+}
+
+func TestResourceCache_ProcessCommand_Get(t *testing.T) {
+	// Arrange
+	c := NewResourceCache(time.Minute)
+	reqCmd := createGetPingCommand()
+	respCmd := reqCmd.SuccessResponse()
+	process, count := newCountingProcess(respCmd, nil)
+
+	// Act
+	actual1, err1 := c.processCommand(reqCmd, process)
+	reqCmd.ID = "other-id"
+	actual2, err2 := c.processCommand(reqCmd, process)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, 1, *count)
+	assert.Equal(t, respCmd, actual1)
+	assert.Equal(t, "other-id", actual2.ID)
+	assert.Equal(t, respCmd.Status, actual2.Status)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestResourceCache_ProcessCommand_GetResponseChanged(t *testing.T) {
+	// Arrange
+	c := NewResourceCache(time.Minute)
+	reqCmd := createGetPingCommand()
+	resource := JsonDocument{"count": 1}
+	respCmd := reqCmd.SuccessResponseWithResource(&resource)
+	respCmd.Metadata = map[string]string{"origin": "server"}
+	process, _ := newCountingProcess(respCmd, nil)
+	actual1, _ := c.processCommand(reqCmd, process)
+	actual1.Metadata["origin"] = "changed"
+	(*actual1.Resource.(*JsonDocument))["count"] = 2
+
+	// Act
+	actual2, err := c.processCommand(reqCmd, process)
+	actual2.Metadata["origin"] = "changed again"
+	(*actual2.Resource.(*JsonDocument))["count"] = 3
+	actual3, _ := c.processCommand(reqCmd, process)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "server", actual3.Metadata["origin"])
+	assert.EqualValues(t, 1, (*actual3.Resource.(*JsonDocument))["count"])
+}
+
+func TestResourceCache_ProcessCommand_GetFailure(t *testing.T) {
+	// Arrange
+	c := NewResourceCache(time.Minute)
+	reqCmd := createGetPingCommand()
+	respCmd := reqCmd.FailureResponse(&Reason{Code: 1, Description: "failure"})
+	process, count := newCountingProcess(respCmd, nil)
+
+	// Act
+	_, _ = c.processCommand(reqCmd, process)
+	_, _ = c.processCommand(reqCmd, process)
+
+	// Assert
+	assert.Equal(t, 2, *count)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestResourceCache_ProcessCommand_GetError(t *testing.T) {
+	// Arrange
+	c := NewResourceCache(time.Minute)
+	reqCmd := createGetPingCommand()
+	errProcess := errors.New("process error")
+	process, _ := newCountingProcess(nil, errProcess)
+
+	// Act
+	actual, err := c.processCommand(reqCmd, process)
+
+	// Assert
+	assert.ErrorIs(t, err, errProcess)
+	assert.Nil(t, actual)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestResourceCache_ProcessCommand_GetExpired(t *testing.T) {
+	// Arrange
+	c := NewResourceCache(10 * time.Millisecond)
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	c.SetClock(clock)
+	reqCmd := createGetPingCommand()
+	process, count := newCountingProcess(reqCmd.SuccessResponse(), nil)
+	_, _ = c.processCommand(reqCmd, process)
+	clock.now = clock.now.Add(20 * time.Millisecond)
+
+	// Act
+	_, err := c.processCommand(reqCmd, process)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, *count)
+}
+
+func TestResourceCache_ProcessCommand_SetInvalidates(t *testing.T) {
+	// Arrange
+	c := NewResourceCache(time.Minute)
+	getCmd := createGetPingCommand()
+	process, count := newCountingProcess(getCmd.SuccessResponse(), nil)
+	_, _ = c.processCommand(getCmd, process)
+	setCmd := createGetPingCommand()
+	setCmd.Method = CommandMethodSet
+
+	// Act
+	_, err := c.processCommand(setCmd, process)
+	_, _ = c.processCommand(getCmd, process)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *count)
+}
+
+func TestResourceCache_Invalidate(t *testing.T) {
+	// Arrange
+	c := NewResourceCache(time.Minute)
+	reqCmd := createGetPingCommand()
+	process, _ := newCountingProcess(reqCmd.SuccessResponse(), nil)
+	_, _ = c.processCommand(reqCmd, process)
+
+	// Act
+	c.Invalidate(reqCmd.URI)
+
+	// Assert
+	assert.Equal(t, 0, c.Len())
+}
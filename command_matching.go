@@ -0,0 +1,53 @@
+package lime
+
+// CommandResponseMatching defines how the received response commands are correlated with the request commands
+// processed by the channel.
+type CommandResponseMatching int
+
+const (
+	// CommandResponseMatchID correlates the responses only by the command ID, regardless of the node that sent them.
+	// This is the default.
+	CommandResponseMatchID CommandResponseMatching = iota
+	// CommandResponseMatchIdentity correlates the responses by the command ID and the identity of the sender, which
+	// must be the same of the request destination, ignoring the instance. It accepts a response from
+	// 'postmaster@limeprotocol.org/#server2' for a request to 'postmaster@limeprotocol.org/#server1', but not one from
+	// another identity that reused the ID, which is delivered as an unsolicited response instead.
+	// The requests and responses without a node are considered to be addressed to and sent by the remote node of the
+	// session.
+	CommandResponseMatchIdentity
+)
+
+func (m CommandResponseMatching) String() string {
+	switch m {
+	case CommandResponseMatchID:
+		return "id"
+	case CommandResponseMatchIdentity:
+		return "identity"
+	}
+	return "unknown"
+}
+
+// SetCommandResponseMatching defines how the channel correlates the received responses with the processed commands.
+func (c *channel) SetCommandResponseMatching(m CommandResponseMatching) {
+	if err := c.ensureState(SessionStateNew, "set command response matching"); err != nil {
+		panic(err)
+	}
+	c.respMatching = m
+}
+
+// matchesCommandResponse indicates if the response was sent by the destination of the processed command, according
+// to the matching of the channel.
+func (c *channel) matchesCommandResponse(processing *processingCommand, respCmd *ResponseCommand) bool {
+	if c.respMatching != CommandResponseMatchIdentity {
+		return true
+	}
+	to := processing.to
+	if to == (Node{}) {
+		to = c.remoteNode
+	}
+	from := respCmd.From
+	if from == (Node{}) {
+		from = c.remoteNode
+	}
+	return from.Identity == to.Identity
+}
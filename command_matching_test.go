@@ -0,0 +1,119 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+// respondCommand replies the request command received by the transport with the response.
+func respondCommand(ctx context.Context, cancel context.CancelFunc, server Transport, respCmd *ResponseCommand) {
+	if _, err := server.Receive(ctx); err != nil {
+		cancel()
+		return
+	}
+	_ = server.Send(ctx, respCmd)
+}
+
+func TestChannel_ProcessCommand_MatchIdentityAnotherInstance(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetCommandResponseMatching(CommandResponseMatchIdentity)
+	c.setState(SessionStateEstablished)
+	reqCmd := createGetPingCommand()
+	reqCmd.To.Instance = "server1"
+	respCmd := createResponseCommand()
+	respCmd.From.Instance = "server2"
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	go respondCommand(ctx, cancel, server, respCmd)
+
+	// Act
+	actual, err := c.ProcessCommand(ctx, reqCmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, respCmd, actual)
+}
+
+func TestChannel_ProcessCommand_MatchIdentityAnotherIdentity(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetCommandResponseMatching(CommandResponseMatchIdentity)
+	c.setState(SessionStateEstablished)
+	reqCmd := createGetPingCommand()
+	respCmd := createResponseCommand()
+	respCmd.From = Node{Identity: Identity{Name: "other", Domain: "limeprotocol.org"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	go respondCommand(ctx, cancel, server, respCmd)
+
+	// Act
+	actual, err := c.ProcessCommand(ctx, reqCmd)
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, actual)
+	ctx, cancel = context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case actualRespCmd := <-c.RespCmdChan():
+		assert.Equal(t, respCmd, actualRespCmd)
+	}
+}
+
+func TestChannel_ProcessCommand_MatchIdentityRemoteNode(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetCommandResponseMatching(CommandResponseMatchIdentity)
+	c.remoteNode = Node{Identity: Identity{Name: "postmaster", Domain: "limeprotocol.org"}, Instance: "server1"}
+	c.setState(SessionStateEstablished)
+	reqCmd := createGetPingCommand()
+	reqCmd.To = Node{}
+	respCmd := createResponseCommand()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	go respondCommand(ctx, cancel, server, respCmd)
+
+	// Act
+	actual, err := c.ProcessCommand(ctx, reqCmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, respCmd, actual)
+}
+
+func TestChannel_ProcessCommand_MatchIDAnotherIdentity(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	reqCmd := createGetPingCommand()
+	respCmd := createResponseCommand()
+	respCmd.From = Node{Identity: Identity{Name: "other", Domain: "limeprotocol.org"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	go respondCommand(ctx, cancel, server, respCmd)
+
+	// Act
+	actual, err := c.ProcessCommand(ctx, reqCmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, respCmd, actual)
+}
@@ -0,0 +1,58 @@
+package lime
+
+import (
+	"context"
+	"strconv"
+)
+
+// MetadataKeyProgress is the metadata key of the pending command responses that holds the completion percentage of
+// the operation, from 0 to 100.
+const MetadataKeyProgress = "#progress"
+
+// processingCommand is a request command waiting for its response.
+type processingCommand struct {
+	respChan chan *ResponseCommand
+	progress func(*ResponseCommand)
+	to       Node // to is the destination of the command
+}
+
+// PendingResponse creates an intermediate response Command for the current request, reporting the progress of a
+// long-running operation. The progress is the completion percentage, from 0 to 100, and a negative value indicates
+// that it is unknown. Any number of pending responses can be sent before the final success or failure response.
+func (cmd *RequestCommand) PendingResponse(progress int) *ResponseCommand {
+	respCmd := &ResponseCommand{
+		Command: Command{
+			Envelope: Envelope{
+				ID:   cmd.ID,
+				From: cmd.To,
+				To:   cmd.Sender(),
+			},
+			Method: cmd.Method,
+		},
+		Status: CommandStatusPending,
+	}
+	if progress >= 0 {
+		respCmd.SetMetadataKeyValue(MetadataKeyProgress, strconv.Itoa(min(progress, 100)))
+	}
+	return respCmd
+}
+
+// Progress returns the completion percentage reported by a pending response, if present.
+func (cmd *ResponseCommand) Progress() (int, bool) {
+	v, ok := cmd.Metadata[MetadataKeyProgress]
+	if !ok {
+		return 0, false
+	}
+	progress, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+	return progress, true
+}
+
+// WithCommandProgress returns a copy of the context with a callback for the pending responses received while
+// processing a command with it, before the final response is returned. The callback is called by the channel
+// receiver goroutine, so it should not block.
+func WithCommandProgress(ctx context.Context, fn func(respCmd *ResponseCommand)) context.Context {
+	return context.WithValue(ctx, contextKeyCommandProgress, fn)
+}
@@ -0,0 +1,104 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+func TestRequestCommand_PendingResponse(t *testing.T) {
+	// Arrange
+	reqCmd := createGetPingCommand()
+
+	// Act
+	respCmd := reqCmd.PendingResponse(40)
+
+	// Assert
+	assert.Equal(t, reqCmd.ID, respCmd.ID)
+	assert.Equal(t, CommandStatusPending, respCmd.Status)
+	progress, ok := respCmd.Progress()
+	assert.True(t, ok)
+	assert.Equal(t, 40, progress)
+}
+
+func TestRequestCommand_PendingResponse_UnknownProgress(t *testing.T) {
+	// Arrange
+	reqCmd := createGetPingCommand()
+
+	// Act
+	respCmd := reqCmd.PendingResponse(-1)
+
+	// Assert
+	assert.Equal(t, CommandStatusPending, respCmd.Status)
+	_, ok := respCmd.Progress()
+	assert.False(t, ok)
+}
+
+func TestChannel_ProcessCommand_Progress(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 4)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	reqCmd := createGetPingCommand()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	var progress []int
+	ctx = WithCommandProgress(ctx, func(respCmd *ResponseCommand) {
+		p, _ := respCmd.Progress()
+		progress = append(progress, p)
+	})
+
+	go func() {
+		_, err := server.Receive(ctx)
+		if err != nil {
+			cancel()
+			return
+		}
+
+		_ = server.Send(ctx, reqCmd.PendingResponse(25))
+		_ = server.Send(ctx, reqCmd.PendingResponse(75))
+		_ = server.Send(ctx, reqCmd.SuccessResponse())
+	}()
+
+	// Act
+	actual, err := c.ProcessCommand(ctx, reqCmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, actual.Status)
+	assert.Equal(t, []int{25, 75}, progress)
+}
+
+func TestChannel_ProcessCommand_PendingWithoutCallback(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 4)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	reqCmd := createGetPingCommand()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	go func() {
+		_, err := server.Receive(ctx)
+		if err != nil {
+			cancel()
+			return
+		}
+
+		_ = server.Send(ctx, reqCmd.PendingResponse(50))
+		_ = server.Send(ctx, reqCmd.FailureResponse(NewReason(ReasonCodeGeneralError, "failed")))
+	}()
+
+	// Act
+	actual, err := c.ProcessCommand(ctx, reqCmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusFailure, actual.Status)
+}
@@ -0,0 +1,176 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// CommandResponseCache keeps the responses of the received commands by the sender identity and envelope ID for a
+// short window, so a client that retries a command after a timeout receives the original response instead of
+// executing a non-idempotent command again.
+// The retries that arrive while the original command is in execution await for its response.
+type CommandResponseCache struct {
+	ttl       time.Duration
+	clock     Clock
+	mu        sync.Mutex
+	entries   map[commandResponseKey]*commandResponseEntry
+	lastSweep time.Time
+}
+
+type commandResponseKey struct {
+	sender string
+	id     string
+}
+
+type commandResponseEntry struct {
+	done    chan struct{} // done is closed when the original command handling completes
+	respCmd *ResponseCommand
+	expires time.Time
+}
+
+// NewCommandResponseCache creates a new CommandResponseCache which keeps the responses for the specified duration.
+func NewCommandResponseCache(ttl time.Duration) *CommandResponseCache {
+	if ttl <= 0 {
+		panic("the ttl should be positive")
+	}
+	return &CommandResponseCache{
+		ttl:       ttl,
+		clock:     SystemClock,
+		entries:   make(map[commandResponseKey]*commandResponseEntry),
+		lastSweep: SystemClock.Now(),
+	}
+}
+
+// SetClock defines the clock for the expiration of the responses. It should be called before the cache is used.
+func (c *CommandResponseCache) SetClock(clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clockOrSystem(clock)
+	c.lastSweep = c.clock.Now()
+}
+
+// Len returns the number of cached responses, including the expired ones that were not evicted yet.
+func (c *CommandResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Middleware returns a RequestCommandMiddleware that replies the retried commands with the cached responses.
+// The commands without ID are not cached, since they do not expect a response.
+func (c *CommandResponseCache) Middleware() RequestCommandMiddleware {
+	return func(next RequestCommandHandlerFunc) RequestCommandHandlerFunc {
+		return func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+			if cmd.ID == "" {
+				return next(ctx, cmd, s)
+			}
+			key := commandResponseKey{sender: commandSender(ctx, cmd), id: cmd.ID}
+
+			for {
+				entry, found := c.getOrAdd(key)
+				if !found {
+					return c.execute(ctx, key, entry, cmd, s, next)
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-entry.done:
+				}
+				if entry.respCmd != nil {
+					// The cached response is copied, since the sent ones can be changed by the channel modules
+					respCmd, err := entry.respCmd.Clone()
+					if err != nil {
+						return fmt.Errorf("command response cache: %w", err)
+					}
+					return s.SendResponseCommand(ctx, respCmd)
+				}
+				// The original command was not responded, so it can be executed again
+			}
+		}
+	}
+}
+
+func (c *CommandResponseCache) execute(ctx context.Context, key commandResponseKey, entry *commandResponseEntry, cmd *RequestCommand, s Sender, next RequestCommandHandlerFunc) error {
+	rs := &recordingSender{Sender: s, id: cmd.ID}
+	defer func() {
+		c.complete(key, entry, rs.respCmd)
+	}()
+	return next(ctx, cmd, rs)
+}
+
+func (c *CommandResponseCache) getOrAdd(key commandResponseKey) (*commandResponseEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if now.Sub(c.lastSweep) > c.ttl {
+		c.sweep(now)
+	}
+
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expires) {
+		return entry, true
+	}
+	entry := &commandResponseEntry{
+		done: make(chan struct{}),
+		// The entry is not evicted while the command is in execution
+		expires: time.Unix(1<<62, 0),
+	}
+	c.entries[key] = entry
+	return entry, false
+}
+
+func (c *CommandResponseCache) complete(key commandResponseKey, entry *commandResponseEntry, respCmd *ResponseCommand) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if respCmd == nil {
+		// Without a response, a retry should execute the command
+		delete(c.entries, key)
+	} else {
+		entry.respCmd = respCmd
+		entry.expires = c.clock.Now().Add(c.ttl)
+	}
+	close(entry.done)
+}
+
+// sweep removes the expired entries. It should be called with the lock held.
+func (c *CommandResponseCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.lastSweep = now
+}
+
+// commandSender returns the sender identity of the command, preferring the session remote node since the command
+// From address is defined by the client.
+func commandSender(ctx context.Context, cmd *RequestCommand) string {
+	if node, ok := ContextSessionRemoteNode(ctx); ok && node.Name != "" {
+		return node.Identity.String()
+	}
+	return cmd.Sender().Identity.String()
+}
+
+// recordingSender keeps a copy of the response sent for a command. The responses that cannot be copied are not
+// kept, so the retries execute the command again.
+type recordingSender struct {
+	Sender
+	id      string
+	respCmd *ResponseCommand
+}
+
+func (s *recordingSender) SendResponseCommand(ctx context.Context, respCmd *ResponseCommand) error {
+	if respCmd != nil && respCmd.ID == s.id {
+		copied, err := respCmd.Clone()
+		if err != nil {
+			log.Printf("command response cache: %v", err)
+		}
+		s.respCmd = copied
+	}
+	return s.Sender.SendResponseCommand(ctx, respCmd)
+}
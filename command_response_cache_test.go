@@ -0,0 +1,180 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// responseRecorder is a Sender that keeps the sent response commands.
+type responseRecorder struct {
+	Sender
+	mu        sync.Mutex
+	responses []*ResponseCommand
+}
+
+func (r *responseRecorder) SendResponseCommand(_ context.Context, respCmd *ResponseCommand) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append(r.responses, respCmd)
+	return nil
+}
+
+func newSetCounterHandler(count *atomic.Int32) RequestCommandHandlerFunc {
+	return func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+		n := count.Add(1)
+		resource := TextDocument(string(rune('0' + n)))
+		return s.SendResponseCommand(ctx, cmd.SuccessResponseWithResource(&resource))
+	}
+}
+
+func createSetCommand() *RequestCommand {
+	cmd := &RequestCommand{}
+	cmd.ID = "set-1"
+	cmd.From = Node{Identity: Identity{Name: "golang", Domain: "limeprotocol.org"}, Instance: "home"}
+	cmd.SetURIString("/counter").SetMethod(CommandMethodSet)
+	return cmd
+}
+
+func TestCommandResponseCache_Middleware_Retry(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	var count atomic.Int32
+	handler := NewCommandResponseCache(time.Minute).Middleware()(newSetCounterHandler(&count))
+	s := &responseRecorder{}
+
+	// Act
+	err1 := handler(ctx, createSetCommand(), s)
+	err2 := handler(ctx, createSetCommand(), s)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, int32(1), count.Load())
+	if assert.Len(t, s.responses, 2) {
+		assert.Equal(t, s.responses[0], s.responses[1])
+	}
+}
+
+func TestCommandResponseCache_Middleware_SentResponseChanged(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	handler := NewCommandResponseCache(time.Minute).Middleware()(func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+		resource := JsonDocument{"count": 1}
+		respCmd := cmd.SuccessResponseWithResource(&resource)
+		respCmd.Metadata = map[string]string{"origin": "handler"}
+		return s.SendResponseCommand(ctx, respCmd)
+	})
+	s := &responseRecorder{}
+	_ = handler(ctx, createSetCommand(), s)
+	s.responses[0].Metadata["origin"] = "changed"
+	(*s.responses[0].Resource.(*JsonDocument))["count"] = 2
+
+	// Act
+	err := handler(ctx, createSetCommand(), s)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, s.responses, 2) {
+		assert.Equal(t, "handler", s.responses[1].Metadata["origin"])
+		assert.EqualValues(t, 1, (*s.responses[1].Resource.(*JsonDocument))["count"])
+	}
+}
+
+func TestCommandResponseCache_Middleware_DistinctSenders(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	var count atomic.Int32
+	handler := NewCommandResponseCache(time.Minute).Middleware()(newSetCounterHandler(&count))
+	s := &responseRecorder{}
+	cmd1 := createSetCommand()
+	cmd2 := createSetCommand()
+	cmd2.From.Name = "other"
+
+	// Act
+	_ = handler(ctx, cmd1, s)
+	_ = handler(ctx, cmd2, s)
+
+	// Assert
+	assert.Equal(t, int32(2), count.Load())
+}
+
+func TestCommandResponseCache_Middleware_Expired(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	var count atomic.Int32
+	cache := NewCommandResponseCache(10 * time.Millisecond)
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	cache.SetClock(clock)
+	handler := cache.Middleware()(newSetCounterHandler(&count))
+	s := &responseRecorder{}
+
+	// Act
+	_ = handler(ctx, createSetCommand(), s)
+	clock.now = clock.now.Add(20 * time.Millisecond)
+	_ = handler(ctx, createSetCommand(), s)
+
+	// Assert
+	assert.Equal(t, int32(2), count.Load())
+	assert.Equal(t, 1, cache.Len())
+}
+
+func TestCommandResponseCache_Middleware_ConcurrentRetry(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	var count atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := NewCommandResponseCache(time.Minute).Middleware()(
+		func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+			count.Add(1)
+			close(started)
+			<-release
+			return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+		})
+	s := &responseRecorder{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = handler(ctx, createSetCommand(), s)
+	}()
+	<-started
+
+	// Act
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	err := handler(ctx, createSetCommand(), s)
+	wg.Wait()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), count.Load())
+	assert.Len(t, s.responses, 2)
+}
+
+func TestCommandResponseCache_Middleware_NotResponded(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	var count atomic.Int32
+	cache := NewCommandResponseCache(time.Minute)
+	handler := cache.Middleware()(func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+		count.Add(1)
+		return nil
+	})
+	s := &responseRecorder{}
+
+	// Act
+	_ = handler(ctx, createSetCommand(), s)
+	_ = handler(ctx, createSetCommand(), s)
+
+	// Assert
+	assert.Equal(t, int32(2), count.Load())
+	assert.Equal(t, 0, cache.Len())
+}
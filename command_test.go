@@ -335,3 +335,18 @@ func createResponseCommand() *ResponseCommand {
 	c.Status = CommandStatusSuccess
 	return &c
 }
+
+func TestRequestCommand_SuccessResponseWithResource(t *testing.T) {
+	// Arrange
+	cmd := createGetPingCommand()
+
+	// Act
+	respCmd := cmd.SuccessResponseWithResource(&Ping{})
+
+	// Assert
+	assert.Equal(t, cmd.ID, respCmd.ID)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	if assert.NotNil(t, respCmd.Type) {
+		assert.Equal(t, MediaTypePing(), *respCmd.Type)
+	}
+}
@@ -0,0 +1,166 @@
+package lime
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// AdaptiveCompression decides which sent payloads are compressed by a transport with compression enabled,
+// skipping the small ones and the ones that compress poorly, like the already compressed media, to save CPU.
+// The compression ratio of a payload is estimated by compressing a sample of its beginning with the fastest level.
+type AdaptiveCompression struct {
+	// MinSize is the size, in bytes, below which the payloads are sent uncompressed.
+	MinSize int
+	// MaxRatio is the compressed to uncompressed size ratio of the sample, from 0 to 1, above which the payloads
+	// are sent uncompressed. If zero, the ratio is not estimated.
+	MaxRatio float64
+	// SampleSize is the length of the sample, in bytes. If zero, 4 KB are used.
+	SampleSize int
+}
+
+// DefaultAdaptiveCompression skips the compression of the payloads smaller than 512 bytes or whose sample is not
+// reduced by at least 10%.
+var DefaultAdaptiveCompression = AdaptiveCompression{
+	MinSize:    512,
+	MaxRatio:   0.9,
+	SampleSize: 4 << 10,
+}
+
+var flateWriters = sync.Pool{
+	New: func() any {
+		w, _ := flate.NewWriter(io.Discard, flate.BestSpeed)
+		return w
+	},
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.BestSpeed)
+		return w
+	},
+}
+
+// shouldCompress indicates if the payload should be compressed, counting the decision in the stats.
+func (a *AdaptiveCompression) shouldCompress(payload []byte, stats *compressionCounters) bool {
+	if len(payload) < a.MinSize {
+		stats.skipped.Add(1)
+		return false
+	}
+	if a.MaxRatio <= 0 {
+		stats.compressed.Add(1)
+		return true
+	}
+
+	sampleSize := a.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = 4 << 10
+	}
+	sample := payload[:min(len(payload), sampleSize)]
+	var compressed bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	w.Reset(&compressed)
+	_, _ = w.Write(sample)
+	_ = w.Close()
+	w.Reset(io.Discard)
+	flateWriters.Put(w)
+
+	stats.sampledBytes.Add(int64(len(sample)))
+	stats.sampledCompressedBytes.Add(int64(compressed.Len()))
+	if float64(compressed.Len())/float64(len(sample)) > a.MaxRatio {
+		stats.skipped.Add(1)
+		return false
+	}
+	stats.compressed.Add(1)
+	return true
+}
+
+// compressionCounters holds the adaptive compression counters of a transport.
+type compressionCounters struct {
+	compressed             atomic.Int64
+	skipped                atomic.Int64
+	sampledBytes           atomic.Int64
+	sampledCompressedBytes atomic.Int64
+}
+
+// ratio returns the estimated compression ratio of the sampled payloads, or zero if none was sampled.
+func (c *compressionCounters) ratio() float64 {
+	sampled := c.sampledBytes.Load()
+	if sampled == 0 {
+		return 0
+	}
+	return float64(c.sampledCompressedBytes.Load()) / float64(sampled)
+}
+
+// gzipMagic are the first bytes of a gzip member. They never appear in the JSON data, since the control characters
+// must be escaped in the strings.
+var gzipMagic = [2]byte{0x1f, 0x8b}
+
+// gzipDetectingReader decompresses the gzip members found in the stream, passing the other data through. It
+// tolerates the peers that compress the data without negotiating it, like some proxies.
+type gzipDetectingReader struct {
+	r        *bufio.Reader
+	gz       *gzip.Reader
+	inMember bool // inMember indicates if the current data is from a gzip member
+}
+
+func newGzipDetectingReader(r io.Reader) *gzipDetectingReader {
+	return &gzipDetectingReader{r: bufio.NewReader(r)}
+}
+
+func (d *gzipDetectingReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if d.inMember {
+			n, err := d.gz.Read(p)
+			if errors.Is(err, io.EOF) {
+				// The data after the member can be plain or another member
+				d.inMember = false
+				if n > 0 {
+					return n, nil
+				}
+				continue
+			}
+			if err != nil {
+				err = fmt.Errorf("gzip: %w", err)
+			}
+			return n, err
+		}
+
+		// Waits for data and passes through everything before the first magic byte
+		if _, err := d.r.Peek(1); err != nil {
+			return 0, err
+		}
+		buffered, _ := d.r.Peek(d.r.Buffered())
+		i := bytes.IndexByte(buffered, gzipMagic[0])
+		if i < 0 {
+			i = len(buffered)
+		}
+		if i > 0 {
+			return d.r.Read(p[:min(len(p), i)])
+		}
+		if magic, err := d.r.Peek(len(gzipMagic)); err != nil || magic[1] != gzipMagic[1] {
+			return d.r.Read(p[:1])
+		}
+
+		var err error
+		if d.gz == nil {
+			d.gz, err = gzip.NewReader(d.r)
+		} else {
+			err = d.gz.Reset(d.r)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("gzip: %w", err)
+		}
+		d.gz.Multistream(false)
+		d.inMember = true
+	}
+}
@@ -0,0 +1,167 @@
+package lime
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveCompression_ShouldCompress_Small(t *testing.T) {
+	// Arrange
+	a := DefaultAdaptiveCompression
+	var stats compressionCounters
+
+	// Act
+	actual := a.shouldCompress([]byte(strings.Repeat("a", 100)), &stats)
+
+	// Assert
+	assert.False(t, actual)
+	assert.Equal(t, int64(1), stats.skipped.Load())
+	assert.Equal(t, float64(0), stats.ratio())
+}
+
+func TestAdaptiveCompression_ShouldCompress_Compressible(t *testing.T) {
+	// Arrange
+	a := DefaultAdaptiveCompression
+	var stats compressionCounters
+
+	// Act
+	actual := a.shouldCompress([]byte(strings.Repeat(`{"type":"text/plain","content":"Hello world"}`, 100)), &stats)
+
+	// Assert
+	assert.True(t, actual)
+	assert.Equal(t, int64(1), stats.compressed.Load())
+	assert.Less(t, stats.ratio(), 0.1)
+}
+
+func TestAdaptiveCompression_ShouldCompress_PoorRatio(t *testing.T) {
+	// Arrange
+	a := DefaultAdaptiveCompression
+	var stats compressionCounters
+	random := make([]byte, 8<<10)
+	_, _ = rand.Read(random)
+	payload := []byte(`{"type":"image/png","content":"` + base64.StdEncoding.EncodeToString(random) + `"}`)
+
+	// Act
+	actual := a.shouldCompress(payload, &stats)
+
+	// Assert
+	assert.False(t, actual)
+	assert.Equal(t, int64(1), stats.skipped.Load())
+	assert.Equal(t, int64(a.SampleSize), stats.sampledBytes.Load())
+}
+
+func TestAdaptiveCompression_ShouldCompress_WithoutRatio(t *testing.T) {
+	// Arrange
+	a := AdaptiveCompression{MinSize: 10}
+	var stats compressionCounters
+	random := make([]byte, 1<<10)
+	_, _ = rand.Read(random)
+
+	// Act
+	actual := a.shouldCompress(random, &stats)
+
+	// Assert
+	assert.True(t, actual)
+	assert.Equal(t, int64(0), stats.sampledBytes.Load())
+}
+
+func TestWebsocketTransport_Send_AdaptiveCompression(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostWSAddr()
+	listener := NewWebsocketTransportListener(&WebsocketConfig{
+		EnableCompression:   true,
+		AdaptiveCompression: &DefaultAdaptiveCompression,
+	})
+	if err := listener.Listen(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(listener)
+	transportChan := make(chan Transport, 1)
+	listenTransports(transportChan, listener)
+	d := websocket.Dialer{EnableCompression: true, Subprotocols: []string{"lime"}}
+	conn, _, err := d.DialContext(ctx, fmt.Sprintf("ws://%s", addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &websocketTransport{conn: conn, c: SessionCompressionNone, e: SessionEncryptionNone}
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	small := createMessage()
+	large := createMessage()
+	text := TextDocument(strings.Repeat("Hello world ", 200))
+	large.Content = &text
+
+	// Act
+	err1 := server.Send(ctx, small)
+	err2 := server.Send(ctx, large)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	actual1, err := client.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, small, actual1)
+	actual2, err := client.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, large, actual2)
+	stats := server.(StatsTransport).Stats()
+	assert.Equal(t, int64(1), stats.EnvelopesCompressed)
+	assert.Equal(t, int64(1), stats.CompressionSkipped)
+	assert.Greater(t, stats.CompressionRatio, 0.0)
+	assert.Less(t, stats.CompressionRatio, 0.5)
+}
+
+func gzipData(t testing.TB, data string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGzipDetectingReader_Read(t *testing.T) {
+	// Arrange
+	var stream bytes.Buffer
+	stream.WriteString(`{"id":"1"}`)
+	stream.Write(gzipData(t, `{"id":"2"}`))
+	stream.Write(gzipData(t, `{"id":"3"}`))
+	stream.WriteString(`{"id":"4","content":"\u001f"}`)
+	r := newGzipDetectingReader(&stream)
+
+	// Act
+	actual, err := io.ReadAll(r)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":"1"}{"id":"2"}{"id":"3"}{"id":"4","content":"\u001f"}`, string(actual))
+}
+
+func TestGzipDetectingReader_Read_Corrupted(t *testing.T) {
+	// Arrange
+	data := gzipData(t, `{"id":"1"}`)
+	r := newGzipDetectingReader(bytes.NewReader(data[:len(data)-4]))
+
+	// Act
+	_, err := io.ReadAll(r)
+
+	// Assert
+	assert.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package lime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+)
+
+// MetadataKeyContentHash is the metadata key with the SHA-256 hash of the canonical JSON encoding of the message
+// content or command resource, in the 'sha256=<hex>' format.
+const MetadataKeyContentHash = "#contentHash"
+
+// ErrContentHashMismatch is returned when the content of an envelope does not match its hash.
+var ErrContentHashMismatch = errors.New("content hash mismatch")
+
+// ContentHash computes the hash of the document in the MetadataKeyContentHash format.
+func ContentHash(d Document) (string, error) {
+	b, err := MarshalCanonical(d)
+	if err != nil {
+		return "", fmt.Errorf("content hash: %w", err)
+	}
+	return canonicalContentHash(b), nil
+}
+
+func canonicalContentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256=" + hex.EncodeToString(sum[:])
+}
+
+// receivedContentHashKey is the annotation key of the hash of the content of a received envelope, computed from the
+// JSON as received instead of the decoded document, which can ignore some of its fields.
+type receivedContentHashKey struct{}
+
+// annotateReceivedContentHash attaches the hash of the received JSON content to the envelope, if it has a hash or a
+// nonce MAC in the metadata.
+func annotateReceivedContentHash(env *Envelope, raw *json.RawMessage) {
+	_, hashed := env.Metadata[MetadataKeyContentHash]
+	_, stamped := env.Metadata[MetadataKeyNonceMAC]
+	if !hashed && !stamped || raw == nil {
+		return
+	}
+	b, err := CanonicalizeJSON(*raw)
+	if err != nil {
+		return
+	}
+	env.Annotate(receivedContentHashKey{}, canonicalContentHash(b))
+}
+
+// SetContentHash embeds the hash of the content in the message metadata.
+func (msg *Message) SetContentHash() error {
+	hash, err := ContentHash(msg.Content)
+	if err != nil {
+		return err
+	}
+	msg.SetMetadataKeyValue(MetadataKeyContentHash, hash)
+	return nil
+}
+
+// VerifyContentHash checks the content against the hash in the metadata, if present. For the received messages, the
+// content is checked as received, before being decoded.
+func (msg *Message) VerifyContentHash() error {
+	return verifyContentHash(&msg.Envelope, msg.Content)
+}
+
+// SetContentHash embeds the hash of the resource in the command metadata. Commands without a resource are not
+// changed.
+func (cmd *Command) SetContentHash() error {
+	if cmd.Resource == nil {
+		return nil
+	}
+	hash, err := ContentHash(cmd.Resource)
+	if err != nil {
+		return err
+	}
+	cmd.SetMetadataKeyValue(MetadataKeyContentHash, hash)
+	return nil
+}
+
+// VerifyContentHash checks the resource against the hash in the metadata, if present. For the received commands, the
+// resource is checked as received, before being decoded.
+func (cmd *Command) VerifyContentHash() error {
+	return verifyContentHash(&cmd.Envelope, cmd.Resource)
+}
+
+func verifyContentHash(env *Envelope, d Document) error {
+	expected, ok := env.Metadata[MetadataKeyContentHash]
+	if !ok {
+		return nil
+	}
+	if d == nil {
+		return ErrContentHashMismatch
+	}
+	actual, ok := env.Annotation(receivedContentHashKey{})
+	if !ok {
+		var err error
+		if actual, err = ContentHash(d); err != nil {
+			return err
+		}
+	}
+	if actual != expected {
+		return ErrContentHashMismatch
+	}
+	return nil
+}
+
+// EnableContentHashes makes the channel embed the content hash in the messages and commands sent while
+// established. The envelopes passed to the send methods are not changed.
+func (c *channel) EnableContentHashes() {
+	if err := c.ensureState(SessionStateNew, "enable content hashes"); err != nil {
+		panic(err)
+	}
+	c.contentHashes = true
+}
+
+// stampContentHash returns a copy of the envelope with the content hash, if enabled.
+func (c *channel) stampContentHash(e envelope) (envelope, error) {
+	if !c.contentHashes {
+		return e, nil
+	}
+
+	withHash := func(env Envelope, d Document) (Envelope, error) {
+		if d == nil {
+			return env, nil
+		}
+		hash, err := ContentHash(d)
+		if err != nil {
+			return env, err
+		}
+		env.Metadata = maps.Clone(env.Metadata)
+		if env.Metadata == nil {
+			env.Metadata = make(map[string]string)
+		}
+		env.Metadata[MetadataKeyContentHash] = hash
+		return env, nil
+	}
+
+	var err error
+	switch e := e.(type) {
+	case *Message:
+		stamped := *e
+		stamped.Envelope, err = withHash(e.Envelope, e.Content)
+		return &stamped, err
+	case *RequestCommand:
+		stamped := *e
+		stamped.Envelope, err = withHash(e.Envelope, e.Resource)
+		return &stamped, err
+	case *ResponseCommand:
+		stamped := *e
+		stamped.Envelope, err = withHash(e.Envelope, e.Resource)
+		return &stamped, err
+	default:
+		return e, nil
+	}
+}
+
+// VerifyContentHashes enables the verification of the content hashes of the received messages and commands, when
+// present. The messages and request commands that do not match are replied with a failure with the
+// ReasonCodeValidationError code, while the response commands are discarded.
+func (m *EnvelopeMux) VerifyContentHashes() {
+	m.contentHashes = true
+}
+
+// verifyContentHash returns the reason for rejecting the envelope, or nil if its hash matches or is absent.
+func (m *EnvelopeMux) verifyContentHash(env *Envelope, d Document) *Reason {
+	if !m.contentHashes {
+		return nil
+	}
+	if err := verifyContentHash(env, d); err != nil {
+		if !errors.Is(err, ErrContentHashMismatch) {
+			// The document could not be encoded, but it was decoded from the envelope
+			log.Printf("verify content hash: %v", err)
+		}
+		return NewReason(ReasonCodeValidationError, "The content does not match the hash")
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package lime
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+func TestMessage_SetContentHash(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+
+	// Act
+	err := msg.SetContentHash()
+
+	// Assert
+	assert.NoError(t, err)
+	sum := sha256.Sum256([]byte(`"Hello world"`))
+	assert.Equal(t, "sha256="+hex.EncodeToString(sum[:]), msg.Metadata[MetadataKeyContentHash])
+	assert.NoError(t, msg.VerifyContentHash())
+}
+
+func TestMessage_VerifyContentHash_Mismatch(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	_ = msg.SetContentHash()
+	content := TextDocument("Hello w0rld")
+	msg.SetContent(&content)
+
+	// Act
+	err := msg.VerifyContentHash()
+
+	// Assert
+	assert.ErrorIs(t, err, ErrContentHashMismatch)
+}
+
+func TestMessage_VerifyContentHash_WithoutHash(t *testing.T) {
+	// Act
+	err := createMessage().VerifyContentHash()
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestMessage_VerifyContentHash_ReceivedContent(t *testing.T) {
+	// Arrange
+	empty := sha256.Sum256([]byte(`{}`))
+	extra := sha256.Sum256([]byte(`{"extra":"value"}`))
+	tests := []struct {
+		name     string
+		hash     [32]byte
+		expected error
+	}{
+		{"DecodedDocument", empty, ErrContentHashMismatch},
+		{"ReceivedContent", extra, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"id":"1","to":"golang@limeprotocol.org","type":"application/vnd.lime.ping+json",` +
+				`"content":{ "extra": "value" },"metadata":{"#contentHash":"sha256=` + hex.EncodeToString(tt.hash[:]) + `"}}`
+			var msg Message
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatal(err)
+			}
+
+			// Act
+			err := msg.VerifyContentHash()
+
+			// Assert
+			assert.Equal(t, tt.expected, err)
+		})
+	}
+}
+
+func TestCommand_SetContentHash(t *testing.T) {
+	// Arrange
+	cmd := createGetPingCommand().SuccessResponseWithResource(&JsonDocument{"status": "ok"})
+
+	// Act
+	err := cmd.SetContentHash()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, cmd.Metadata, MetadataKeyContentHash)
+	(*cmd.Resource.(*JsonDocument))["status"] = "failed"
+	assert.ErrorIs(t, cmd.VerifyContentHash(), ErrContentHashMismatch)
+}
+
+func TestChannel_SendMessage_ContentHashes(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.EnableContentHashes()
+	c.setState(SessionStateEstablished)
+	msg := createMessage()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotContains(t, msg.Metadata, MetadataKeyContentHash)
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	expected, _ := ContentHash(msg.Content)
+	assert.Equal(t, expected, actual.(*Message).Metadata[MetadataKeyContentHash])
+}
+
+func TestEnvelopeMux_ListenServer_RejectContentHashMismatch(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	mux.VerifyContentHashes()
+	handled := false
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			handled = true
+			return nil
+		})
+	msg := createMessage()
+	msg.SetMetadataKeyValue(MetadataKeyContentHash, "sha256=00")
+	if err := client.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+
+	// Act
+	env, err := client.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	cancel()
+	assert.False(t, handled)
+	if not, ok := env.(*Notification); assert.True(t, ok) {
+		assert.Equal(t, NotificationEventFailed, not.Event)
+		assert.Equal(t, ReasonCodeValidationError, not.Reason.Code)
+	}
+}
@@ -1,6 +1,9 @@
 package lime
 
-import "context"
+import (
+	"context"
+	"maps"
+)
 
 type contextKey string
 
@@ -12,12 +15,21 @@ var (
 	contextKeySessionID         = contextKey("sessionID")
 	contextKeySessionRemoteNode = contextKey("sessionRemoteNode")
 	contextKeySessionLocalNode  = contextKey("sessionLocalNode")
+	contextKeyConversationID    = contextKey("conversationID")
+	contextKeySessionValues     = contextKey("sessionValues")
+	contextKeyCulture           = contextKey("culture")
+	contextKeyCommandProgress   = contextKey("commandProgress")
+	contextKeyRouteTrace        = contextKey("routeTrace")
+	contextKeyRetransmission    = contextKey("retransmission")
+
+	contextKeyTLSConnectionState = contextKey("tlsConnectionState")
 )
 
 func sessionContext(ctx context.Context, c *channel) context.Context {
 	ctx = context.WithValue(ctx, contextKeySessionID, c.sessionID)
 	ctx = context.WithValue(ctx, contextKeySessionRemoteNode, c.remoteNode)
 	ctx = context.WithValue(ctx, contextKeySessionLocalNode, c.localNode)
+	ctx = context.WithValue(ctx, contextKeySessionValues, &c.values)
 	return ctx
 }
 
@@ -38,3 +50,93 @@ func ContextSessionLocalNode(ctx context.Context) (Node, bool) {
 	node, ok := ctx.Value(contextKeySessionLocalNode).(Node)
 	return node, ok
 }
+
+// receivedContext returns a copy of the context with the values of the received envelope, like the conversation id,
+// which are propagated to the envelopes sent while handling it.
+func receivedContext(ctx context.Context, env *Envelope) context.Context {
+	ctx = conversationContext(ctx, env)
+	ctx = cultureContext(ctx, env)
+	return routeTraceContext(ctx, env)
+}
+
+// propagateContext sets the values of the context in the envelope being sent by the channel.
+func (c *channel) propagateContext(ctx context.Context, env *Envelope) {
+	propagateConversationID(ctx, env)
+	propagateCulture(ctx, env, c.DefaultCulture())
+	propagateRouteTrace(ctx, env, c.localNode, c.clock)
+}
+
+// setSharedMetadataValue sets the metadata value in a copy of the envelope metadata, to avoid changing a map that
+// can be shared by other envelopes.
+func setSharedMetadataValue(env *Envelope, key, value string) {
+	metadata := maps.Clone(env.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string, 1)
+	}
+	metadata[key] = value
+	env.Metadata = metadata
+}
+
+// ContextConversationID gets the conversation id from the context.
+// It is defined when handling a received envelope with the MetadataKeyConversationID metadata.
+func ContextConversationID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKeyConversationID).(string)
+	return id, ok
+}
+
+// WithConversationID returns a copy of the context with the conversation id, which is propagated to the envelopes
+// sent through a channel using the context.
+func WithConversationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKeyConversationID, id)
+}
+
+// conversationContext adds the conversation id of the received envelope to the context, if present.
+func conversationContext(ctx context.Context, env *Envelope) context.Context {
+	if id := env.ConversationID(); id != "" {
+		return WithConversationID(ctx, id)
+	}
+	return ctx
+}
+
+// propagateConversationID sets the conversation id of the context in the envelope, if not defined.
+func propagateConversationID(ctx context.Context, env *Envelope) {
+	id, ok := ContextConversationID(ctx)
+	if !ok || id == "" || env.ConversationID() != "" {
+		return
+	}
+	setSharedMetadataValue(env, MetadataKeyConversationID, id)
+}
+
+// ContextCulture gets the culture from the context.
+// It is defined when handling a received envelope with the MetadataKeyCulture metadata.
+func ContextCulture(ctx context.Context) (string, bool) {
+	culture, ok := ctx.Value(contextKeyCulture).(string)
+	return culture, ok
+}
+
+// WithCulture returns a copy of the context with the culture, which is propagated to the envelopes sent through a
+// channel using the context.
+func WithCulture(ctx context.Context, culture string) context.Context {
+	return context.WithValue(ctx, contextKeyCulture, culture)
+}
+
+// cultureContext adds the culture of the received envelope to the context, if present.
+func cultureContext(ctx context.Context, env *Envelope) context.Context {
+	if culture := env.Culture(); culture != "" {
+		return WithCulture(ctx, culture)
+	}
+	return ctx
+}
+
+// propagateCulture sets the culture of the context in the envelope, or the default culture if the context has none.
+// The envelopes with a defined culture are not changed.
+func propagateCulture(ctx context.Context, env *Envelope, defaultCulture string) {
+	culture, ok := ContextCulture(ctx)
+	if !ok || culture == "" {
+		culture = defaultCulture
+	}
+	if culture == "" || env.Culture() != "" {
+		return
+	}
+	setSharedMetadataValue(env, MetadataKeyCulture, culture)
+}
@@ -0,0 +1,108 @@
+package lime
+
+import (
+	"context"
+	"sync"
+)
+
+// IsDelegated indicates if the envelope was sent by a delegate node on behalf of the From identity, as defined by the
+// PP field.
+func (env *Envelope) IsDelegated() bool {
+	return env.PP != (Node{}) && env.PP.Identity != env.From.Identity
+}
+
+// DelegationChecker determines if the owner identity has granted the delegate identity the permission to send
+// envelopes on its behalf.
+type DelegationChecker func(ctx context.Context, owner Identity, delegate Identity) (bool, error)
+
+// DelegationStore holds the delegations granted by the identities in memory.
+// Its HasDelegation method can be used as a DelegationChecker.
+type DelegationStore struct {
+	mu          sync.RWMutex
+	delegations map[Identity]map[Identity]struct{}
+}
+
+// NewDelegationStore creates a new instance of DelegationStore.
+func NewDelegationStore() *DelegationStore {
+	return &DelegationStore{delegations: make(map[Identity]map[Identity]struct{})}
+}
+
+// Grant allows the delegate to send envelopes on behalf of the owner.
+func (s *DelegationStore) Grant(owner Identity, delegate Identity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delegates, ok := s.delegations[owner]
+	if !ok {
+		delegates = make(map[Identity]struct{})
+		s.delegations[owner] = delegates
+	}
+	delegates[delegate] = struct{}{}
+}
+
+// Revoke removes the permission of the delegate to send envelopes on behalf of the owner.
+func (s *DelegationStore) Revoke(owner Identity, delegate Identity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.delegations[owner], delegate)
+	if len(s.delegations[owner]) == 0 {
+		delete(s.delegations, owner)
+	}
+}
+
+// HasDelegation indicates if the owner has granted a delegation to the delegate.
+func (s *DelegationStore) HasDelegation(_ context.Context, owner Identity, delegate Identity) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.delegations[owner][delegate]
+	return ok, nil
+}
+
+// VerifyDelegations enables the verification of the sender of the received envelopes.
+// The envelopes with a From identity distinct of the session remote identity are only accepted when the PP field has
+// the session identity and the checker confirms that the From identity has granted it a delegation.
+// The rejected messages and request commands are replied with a failure with the ReasonCodeUnauthorizedSender code,
+// while the other envelopes are discarded.
+func (m *EnvelopeMux) VerifyDelegations(checker DelegationChecker) {
+	if checker == nil {
+		panic("nil checker")
+	}
+	m.delegations = checker
+}
+
+// verifySender returns the reason for rejecting the envelope, or nil if the sender is allowed.
+func (m *EnvelopeMux) verifySender(ctx context.Context, c *channel, env *Envelope) *Reason {
+	if m.delegations == nil || c.remoteNode.Name == "" {
+		return nil
+	}
+
+	// The identity of the remote node is mapped in the received envelopes
+	remote := c.receivedIdentity(c.remoteNode.Identity)
+	// The senders without a name, like the ones with only the domain, are also checked, so only an empty From is
+	// accepted as the session identity
+	if env.PP == (Node{}) {
+		if env.From == (Node{}) || env.From.Identity == remote {
+			return nil
+		}
+		return m.denySender(ctx, env, "The sender is not allowed to send on behalf of the identity")
+	}
+
+	if env.PP.Identity != remote {
+		return m.denySender(ctx, env, "The delegate identity does not match the session identity")
+	}
+	if env.From == (Node{}) || env.From.Identity == remote {
+		return nil
+	}
+	ok, err := m.delegations(ctx, env.From.Identity, env.PP.Identity)
+	if err != nil {
+		return m.denySender(ctx, env, "The delegation could not be verified")
+	}
+	if !ok {
+		return m.denySender(ctx, env, "The identity has not granted a delegation to the sender")
+	}
+	return nil
+}
+
+func (m *EnvelopeMux) denySender(ctx context.Context, env *Envelope, description string) *Reason {
+	AuditAuthorizationDenied(ctx, env.From.Identity.String(), description)
+	return NewReason(ReasonCodeUnauthorizedSender, description)
+}
@@ -0,0 +1,137 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+func TestEnvelopeMux_verifySender(t *testing.T) {
+	owner := Node{Identity: Identity{Name: "owner", Domain: "localhost"}, Instance: "home"}
+	delegate := Node{Identity: Identity{Name: "delegate", Domain: "localhost"}, Instance: "home"}
+	other := Node{Identity: Identity{Name: "other", Domain: "localhost"}, Instance: "home"}
+	store := NewDelegationStore()
+	store.Grant(owner.Identity, delegate.Identity)
+	tests := []struct {
+		name    string
+		from    Node
+		pp      Node
+		checker DelegationChecker
+		allowed bool
+	}{
+		{"NoFrom", Node{}, Node{}, store.HasDelegation, true},
+		{"FromSession", delegate, Node{}, store.HasDelegation, true},
+		{"SpoofedFrom", owner, Node{}, store.HasDelegation, false},
+		{"SpoofedFromDomain", Node{Identity: Identity{Domain: "localhost"}}, Node{}, store.HasDelegation, false},
+		{"SpoofedFromInstance", Node{Instance: "home"}, Node{}, store.HasDelegation, false},
+		{"SpoofedFromOtherDomain", Node{Identity: Identity{Name: "delegate", Domain: "other.com"}}, Node{}, store.HasDelegation, false},
+		{"Delegated", owner, delegate, store.HasDelegation, true},
+		{"SpoofedPP", owner, other, store.HasDelegation, false},
+		{"NotDelegated", other, delegate, store.HasDelegation, false},
+		{"DelegatedFromDomain", Node{Identity: Identity{Domain: "localhost"}}, delegate, store.HasDelegation, false},
+		{"CheckerError", owner, delegate, func(context.Context, Identity, Identity) (bool, error) {
+			return false, errors.New("store unavailable")
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			client, server := newInProcessTransportPair("localhost", 1)
+			defer silentClose(client)
+			c := newChannel(server, 1)
+			c.remoteNode = delegate
+			mux := &EnvelopeMux{}
+			mux.VerifyDelegations(tt.checker)
+			msg := createMessage()
+			msg.From = tt.from
+			msg.PP = tt.pp
+
+			// Act
+			reason := mux.verifySender(context.Background(), c, &msg.Envelope)
+
+			// Assert
+			if tt.allowed {
+				assert.Nil(t, reason)
+			} else if assert.NotNil(t, reason) {
+				assert.Equal(t, ReasonCodeUnauthorizedSender, reason.Code)
+			}
+		})
+	}
+}
+
+func TestEnvelopeMux_ListenServer_RejectSpoofedMessage(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.remoteNode = Node{Identity: Identity{Name: "delegate", Domain: "localhost"}, Instance: "home"}
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	mux.VerifyDelegations(NewDelegationStore().HasDelegation)
+	handled := false
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			handled = true
+			return nil
+		})
+	msg := createMessage()
+	msg.SetFromString("owner@localhost/home").SetPPString("delegate@localhost/home")
+	if err := client.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+
+	// Act
+	env, err := client.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	cancel()
+	assert.False(t, handled)
+	if not, ok := env.(*Notification); assert.True(t, ok) {
+		assert.Equal(t, NotificationEventFailed, not.Event)
+		assert.Equal(t, ReasonCodeUnauthorizedSender, not.Reason.Code)
+		assert.Equal(t, msg.PP, not.To)
+	}
+}
+
+func TestDelegationStore_Revoke(t *testing.T) {
+	// Arrange
+	store := NewDelegationStore()
+	owner := Identity{Name: "owner", Domain: "localhost"}
+	delegate := Identity{Name: "delegate", Domain: "localhost"}
+	store.Grant(owner, delegate)
+
+	// Act
+	store.Revoke(owner, delegate)
+
+	// Assert
+	ok, err := store.HasDelegation(context.Background(), owner, delegate)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Empty(t, store.delegations)
+}
+
+func TestEnvelope_IsDelegated(t *testing.T) {
+	// Arrange
+	env := &Envelope{}
+	env.SetFromString("owner@localhost/home")
+
+	// Act
+	notDelegated := env.IsDelegated()
+	env.SetPPString("delegate@localhost/home")
+	delegated := env.IsDelegated()
+
+	// Assert
+	assert.False(t, notDelegated)
+	assert.True(t, delegated)
+}
@@ -0,0 +1,36 @@
+package lime
+
+// DiagnosticsChannel is the description of a server session listed by the diagnostics endpoint of the limediag
+// package.
+type DiagnosticsChannel struct {
+	SessionID  string          `json:"sessionId"`
+	State      SessionState    `json:"state"`
+	LocalNode  Node            `json:"localNode"`
+	RemoteNode Node            `json:"remoteNode"`
+	RemoteAddr string          `json:"remoteAddr,omitempty"`
+	Stats      *TransportStats `json:"stats,omitempty"`
+}
+
+// DiagnosticsChannels returns the description of the established sessions.
+func (srv *Server) DiagnosticsChannels() []DiagnosticsChannel {
+	sessions := srv.establishedSessions()
+	channels := make([]DiagnosticsChannel, 0, len(sessions))
+	for _, c := range sessions {
+		d := DiagnosticsChannel{
+			SessionID:  c.ID(),
+			State:      c.State(),
+			LocalNode:  c.LocalNode(),
+			RemoteNode: c.RemoteNode(),
+		}
+		if c.transport.Connected() {
+			if addr := c.transport.RemoteAddr(); addr != nil {
+				d.RemoteAddr = addr.String()
+			}
+		}
+		if stats, ok := c.TransportStats(); ok {
+			d.Stats = &stats
+		}
+		channels = append(channels, d)
+	}
+	return channels
+}
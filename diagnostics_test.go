@@ -0,0 +1,34 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestServer_DiagnosticsChannels(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("diagnostics")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	client := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(client)
+
+	// Act
+	channels := srv.DiagnosticsChannels()
+
+	// Assert
+	if assert.Len(t, channels, 1) {
+		assert.Equal(t, client.ID(), channels[0].SessionID)
+		assert.Equal(t, SessionStateEstablished, channels[0].State)
+		assert.Equal(t, "golang", channels[0].RemoteNode.Name)
+	}
+}
@@ -0,0 +1,267 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ErrDispatcherClosed is returned when a task is dispatched to a closed Dispatcher.
+var ErrDispatcherClosed = errors.New("dispatcher closed")
+
+// Dispatcher executes the handling of the received envelopes, allowing the concurrent processing of envelopes
+// from different identities.
+// The tasks with the same key must be executed sequentially, in the dispatch order.
+type Dispatcher interface {
+	// Dispatch schedules the task for execution, blocking if the dispatcher queue is full until the context is done.
+	Dispatch(ctx context.Context, key string, task func()) error
+	// Close stops accepting new tasks and awaits for the execution of the pending ones.
+	Close() error
+}
+
+// WorkerPool is a Dispatcher that executes the tasks in a fixed number of goroutines, with a bounded queue.
+// The tasks with the same key are executed in order by a single worker at a time, but any idle worker can pick the
+// tasks of a key when there's no other task with the same key in execution.
+type WorkerPool struct {
+	slots     chan struct{}    // slots bounds the number of pending tasks
+	ready     chan *keyedTasks // ready holds the keys with pending tasks that are not being executed
+	mu        sync.Mutex
+	keys      map[string]*keyedTasks
+	wg        sync.WaitGroup
+	closed    bool
+	closeOnce sync.Once
+}
+
+type keyedTasks struct {
+	key   string
+	tasks []func()
+}
+
+// NewWorkerPool creates a new WorkerPool with the specified number of workers and queue size, which is the maximum
+// number of pending tasks.
+func NewWorkerPool(workers int, queueSize int) *WorkerPool {
+	if workers <= 0 {
+		panic("the workers should be positive")
+	}
+	if queueSize <= 0 {
+		panic("the queue size should be positive")
+	}
+	p := &WorkerPool{
+		slots: make(chan struct{}, queueSize),
+		// The number of ready keys is never greater than the number of pending tasks
+		ready: make(chan *keyedTasks, queueSize),
+		keys:  make(map[string]*keyedTasks),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		goLabeled(context.Background(), "dispatcher.worker", func(context.Context) {
+			p.work()
+		})
+	}
+	return p
+}
+
+func (p *WorkerPool) Dispatch(ctx context.Context, key string, task func()) error {
+	if task == nil {
+		panic("nil task")
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case p.slots <- struct{}{}:
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		<-p.slots
+		return ErrDispatcherClosed
+	}
+
+	if k, ok := p.keys[key]; ok {
+		// The key is already ready or in execution, so the task is executed after the previous ones
+		k.tasks = append(k.tasks, task)
+		return nil
+	}
+
+	k := &keyedTasks{key: key, tasks: []func(){task}}
+	p.keys[key] = k
+	p.ready <- k
+	return nil
+}
+
+func (p *WorkerPool) Close() error {
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		close(p.ready)
+		p.mu.Unlock()
+	})
+	p.wg.Wait()
+	return nil
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for k := range p.ready {
+		p.runKey(k)
+	}
+}
+
+// runKey executes the tasks of the key until there's no one pending.
+func (p *WorkerPool) runKey(k *keyedTasks) {
+	for {
+		p.mu.Lock()
+		if len(k.tasks) == 0 {
+			delete(p.keys, k.key)
+			p.mu.Unlock()
+			return
+		}
+		task := k.tasks[0]
+		k.tasks[0] = nil
+		k.tasks = k.tasks[1:]
+		p.mu.Unlock()
+
+		task()
+		<-p.slots
+	}
+}
+
+// ShardedDispatcher is a Dispatcher that assigns each key to a fixed worker goroutine (a shard) through consistent
+// hashing, so the tasks of a key are always executed by the same worker, while different keys run in parallel.
+// Each shard has its own queue, so a slow key only delays the keys of the same shard.
+type ShardedDispatcher struct {
+	ring      *hashRing
+	shards    []chan func()
+	mu        sync.RWMutex
+	closed    bool
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// DefaultShardReplicas is the number of points of each shard in the consistent hash ring.
+const DefaultShardReplicas = 64
+
+// NewShardedDispatcher creates a new ShardedDispatcher with the specified number of shards and queue size per shard.
+func NewShardedDispatcher(shards int, queueSize int) *ShardedDispatcher {
+	if shards <= 0 {
+		panic("the shards should be positive")
+	}
+	if queueSize <= 0 {
+		panic("the queue size should be positive")
+	}
+	d := &ShardedDispatcher{
+		ring:   newHashRing(shards, DefaultShardReplicas),
+		shards: make([]chan func(), shards),
+	}
+	d.wg.Add(shards)
+	for i := range d.shards {
+		d.shards[i] = make(chan func(), queueSize)
+		shard := d.shards[i]
+		goLabeled(context.Background(), "dispatcher.shard", func(context.Context) {
+			d.work(shard)
+		}, "shard", strconv.Itoa(i))
+	}
+	return d
+}
+
+// ShardOf returns the index of the shard that executes the tasks of the specified key.
+func (d *ShardedDispatcher) ShardOf(key string) int {
+	return d.ring.get(key)
+}
+
+func (d *ShardedDispatcher) Dispatch(ctx context.Context, key string, task func()) error {
+	if task == nil {
+		panic("nil task")
+	}
+
+	// The read lock avoids sending to a closed shard, while allowing concurrent dispatches
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrDispatcherClosed
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case d.shards[d.ring.get(key)] <- task:
+		return nil
+	}
+}
+
+func (d *ShardedDispatcher) Close() error {
+	d.closeOnce.Do(func() {
+		d.mu.Lock()
+		d.closed = true
+		for _, shard := range d.shards {
+			close(shard)
+		}
+		d.mu.Unlock()
+	})
+	d.wg.Wait()
+	return nil
+}
+
+func (d *ShardedDispatcher) work(shard <-chan func()) {
+	defer d.wg.Done()
+	for task := range shard {
+		task()
+	}
+}
+
+// hashRing implements consistent hashing of keys to nodes, identified by their indexes.
+type hashRing struct {
+	points []uint32       // points are the sorted hashes of the nodes replicas
+	nodes  map[uint32]int // nodes maps the points to the node indexes
+}
+
+func newHashRing(nodes int, replicas int) *hashRing {
+	r := &hashRing{
+		points: make([]uint32, 0, nodes*replicas),
+		nodes:  make(map[uint32]int, nodes*replicas),
+	}
+	for n := 0; n < nodes; n++ {
+		for i := 0; i < replicas; i++ {
+			p := hashKey(fmt.Sprintf("%d-%d", n, i))
+			if _, ok := r.nodes[p]; ok {
+				// Ignores the rare collisions, keeping the first node
+				continue
+			}
+			r.points = append(r.points, p)
+			r.nodes[p] = n
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// get returns the node of the first point clockwise from the key hash.
+func (r *hashRing) get(key string) int {
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.nodes[r.points[i]]
+}
+
+// hashKey hashes the key with FNV-1a, followed by the murmur3 finalizer, which spreads the hashes of similar keys
+// over the ring.
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	x := h.Sum32()
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
@@ -0,0 +1,202 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_Dispatch_PreserveKeyOrder(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p := NewWorkerPool(4, 16)
+	keys := 8
+	count := 100
+	var mu sync.Mutex
+	actual := make(map[string][]int)
+
+	// Act
+	for i := 0; i < count; i++ {
+		for k := 0; k < keys; k++ {
+			key := fmt.Sprintf("key%d", k)
+			i := i
+			err := p.Dispatch(ctx, key, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				actual[key] = append(actual[key], i)
+			})
+			assert.NoError(t, err)
+		}
+	}
+	assert.NoError(t, p.Close())
+
+	// Assert
+	assert.Len(t, actual, keys)
+	for _, values := range actual {
+		if assert.Len(t, values, count) {
+			for i, v := range values {
+				assert.Equal(t, i, v)
+			}
+		}
+	}
+}
+
+func TestWorkerPool_Dispatch_Concurrency(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	workers := 4
+	p := NewWorkerPool(workers, 16)
+	var running, maxRunning atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	// Act
+	for k := 0; k < workers; k++ {
+		_ = p.Dispatch(ctx, fmt.Sprintf("key%d", k), func() {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			wg.Done()
+			wg.Wait()
+			running.Add(-1)
+		})
+	}
+	assert.NoError(t, p.Close())
+
+	// Assert
+	assert.Equal(t, int32(workers), maxRunning.Load())
+}
+
+func TestWorkerPool_Dispatch_WhenQueueFull(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	p := NewWorkerPool(1, 1)
+	release := make(chan struct{})
+	_ = p.Dispatch(context.Background(), "key", func() { <-release })
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := p.Dispatch(ctx, "key", func() {})
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	close(release)
+	assert.NoError(t, p.Close())
+}
+
+func TestWorkerPool_Dispatch_WhenClosed(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	p := NewWorkerPool(1, 1)
+	_ = p.Close()
+
+	// Act
+	err := p.Dispatch(context.Background(), "key", func() {})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrDispatcherClosed)
+}
+
+func TestShardedDispatcher_Dispatch_PreserveKeyOrder(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	d := NewShardedDispatcher(4, 16)
+	keys := 8
+	count := 100
+	var mu sync.Mutex
+	actual := make(map[string][]int)
+
+	// Act
+	for i := 0; i < count; i++ {
+		for k := 0; k < keys; k++ {
+			key := fmt.Sprintf("key%d", k)
+			i := i
+			err := d.Dispatch(ctx, key, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				actual[key] = append(actual[key], i)
+			})
+			assert.NoError(t, err)
+		}
+	}
+	assert.NoError(t, d.Close())
+
+	// Assert
+	assert.Len(t, actual, keys)
+	for _, values := range actual {
+		if assert.Len(t, values, count) {
+			for i, v := range values {
+				assert.Equal(t, i, v)
+			}
+		}
+	}
+}
+
+func TestShardedDispatcher_ShardOf(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	d := NewShardedDispatcher(8, 1)
+	defer silentClose(d)
+	counts := make([]int, 8)
+
+	// Act
+	for i := 0; i < 8000; i++ {
+		counts[d.ShardOf(fmt.Sprintf("user%d@domain.com", i))]++
+	}
+
+	// Assert
+	assert.Equal(t, d.ShardOf("john@domain.com"), d.ShardOf("john@domain.com"))
+	for _, c := range counts {
+		// The keys should be reasonably distributed
+		assert.Greater(t, c, 500)
+	}
+}
+
+func TestHashRing_Get_MinimalRemapping(t *testing.T) {
+	// Arrange
+	r1 := newHashRing(8, DefaultShardReplicas)
+	r2 := newHashRing(9, DefaultShardReplicas)
+	total := 10000
+	moved := 0
+
+	// Act
+	for i := 0; i < total; i++ {
+		key := fmt.Sprintf("user%d@domain.com", i)
+		if r1.get(key) != r2.get(key) {
+			moved++
+		}
+	}
+
+	// Assert
+	// Only the keys assigned to the new node should move
+	assert.Less(t, moved, total/4)
+}
+
+func TestShardedDispatcher_Dispatch_WhenClosed(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	d := NewShardedDispatcher(1, 1)
+	_ = d.Close()
+
+	// Act
+	err := d.Dispatch(context.Background(), "key", func() {})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrDispatcherClosed)
+}
@@ -3,6 +3,7 @@ package lime
 import (
 	"encoding/json"
 	"errors"
+	"net/url"
 )
 
 func init() {
@@ -22,6 +23,15 @@ func init() {
 	RegisterDocumentFactory(func() Document {
 		return &Ping{}
 	})
+	RegisterDocumentFactory(func() Document {
+		return &Redirect{}
+	})
+	RegisterDocumentFactory(func() Document {
+		return &Reason{}
+	})
+	RegisterDocumentFactory(func() Document {
+		return &PublicKey{}
+	})
 }
 
 // Document defines an entity with a media type.
@@ -250,3 +260,45 @@ func MediaTypePing() MediaType {
 func (p *Ping) MediaType() MediaType {
 	return MediaTypePing()
 }
+
+// Redirect allows a node to instruct the remote party to establish a new session with another address.
+type Redirect struct {
+	// Address is the URI of the node that the remote party should connect to, like 'net.tcp://server2:55321'.
+	Address string `json:"address,omitempty"`
+	// Context is an optional document that should be presented to the new address.
+	Context *DocumentContainer `json:"context,omitempty"`
+}
+
+func MediaTypeRedirect() MediaType {
+	return MediaType{
+		Type:    "application",
+		Subtype: "vnd.lime.redirect",
+		Suffix:  "json",
+	}
+}
+
+func (r *Redirect) MediaType() MediaType {
+	return MediaTypeRedirect()
+}
+
+// URL parses the redirect address.
+func (r *Redirect) URL() (*url.URL, error) {
+	if r.Address == "" {
+		return nil, errors.New("redirect address is required")
+	}
+	return url.Parse(r.Address)
+}
+
+// MediaTypeReason is the media type of the Reason, which can also be sent as a document, like in the resource of a
+// command for finishing a session.
+func MediaTypeReason() MediaType {
+	return MediaType{
+		Type:    "application",
+		Subtype: "vnd.lime.reason",
+		Suffix:  "json",
+	}
+}
+
+func (r *Reason) MediaType() MediaType {
+	return MediaTypeReason()
+}
@@ -1,6 +1,7 @@
 package lime
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,6 +24,12 @@ type Envelope struct {
 	To Node
 	// Metadata holds additional information to be delivered with the envelope.
 	Metadata map[string]string
+	// Extensions holds additional typed information, like numbers, booleans and objects, to be delivered beside the
+	// metadata. It is only serialized if enabled by EnableEnvelopeExtensions.
+	Extensions map[string]interface{}
+
+	sequence    uint64      // sequence is the received order of the envelope in the session, if enabled
+	annotations map[any]any // annotations are the local values attached by the modules, which are never sent
 }
 
 func (env *Envelope) SetID(id string) *Envelope {
@@ -73,12 +80,40 @@ func (env *Envelope) SetMetadataKeyValue(key string, value string) *Envelope {
 	return env
 }
 
+// MetadataKeyConversationID is the metadata key that holds the identifier of the conversation that the envelope
+// belongs to, allowing the correlation of the exchanged envelopes.
+const MetadataKeyConversationID = "#conversationId"
+
+// ConversationID returns the identifier of the conversation that the envelope belongs to, if defined.
+func (env *Envelope) ConversationID() string {
+	return env.Metadata[MetadataKeyConversationID]
+}
+
+// SetConversationID sets the identifier of the conversation that the envelope belongs to.
+func (env *Envelope) SetConversationID(id string) *Envelope {
+	return env.SetMetadataKeyValue(MetadataKeyConversationID, id)
+}
+
+// MetadataKeyCulture is the metadata key that holds the culture of the envelope content, as an IETF language tag
+// like 'pt-BR', allowing the receiver to reply in the same language.
+const MetadataKeyCulture = "#culture"
+
+// Culture returns the culture of the envelope content, if defined.
+func (env *Envelope) Culture() string {
+	return env.Metadata[MetadataKeyCulture]
+}
+
+// SetCulture sets the culture of the envelope content.
+func (env *Envelope) SetCulture(culture string) *Envelope {
+	return env.SetMetadataKeyValue(MetadataKeyCulture, culture)
+}
+
 // Sender returns the envelope sender Node.
 func (env *Envelope) Sender() Node {
 	if env.PP == (Node{}) {
-		return env.PP
-	} else {
 		return env.From
+	} else {
+		return env.PP
 	}
 }
 
@@ -95,6 +130,9 @@ func (env *Envelope) toRawEnvelope() (*rawEnvelope, error) {
 		raw.To = &env.To
 	}
 	raw.Metadata = env.Metadata
+	if EnvelopeExtensionsEnabled() {
+		raw.Extensions = env.Extensions
+	}
 
 	return &raw, nil
 }
@@ -105,6 +143,9 @@ func (env *Envelope) populate(raw *rawEnvelope) error {
 	}
 	env.ID = raw.ID
 	env.Metadata = raw.Metadata
+	if EnvelopeExtensionsEnabled() {
+		env.Extensions = raw.Extensions
+	}
 	if raw.From != nil {
 		env.From = *raw.From
 	}
@@ -140,6 +181,40 @@ type envelope interface {
 	toRawEnvelope() (*rawEnvelope, error)
 }
 
+// TransportEnvelope is the envelope sent and received by a Transport, which is a *Message, *Notification,
+// *RequestCommand, *ResponseCommand or *Session. It allows the Transport interface to be implemented outside the
+// package: the envelopes are encoded with the json package and decoded with the UnmarshalTransportEnvelope function.
+type TransportEnvelope = envelope
+
+// UnmarshalTransportEnvelope decodes the JSON of an envelope to its type, returning an *UnknownEnvelopeError in the
+// error chain if the fields match no envelope type.
+func UnmarshalTransportEnvelope(b []byte) (TransportEnvelope, error) {
+	var raw rawEnvelope
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	return raw.toEnvelope()
+}
+
+// envelopeOf returns the common fields of a message, notification, command or session, or an empty Envelope for
+// the other types.
+func envelopeOf(e envelope) *Envelope {
+	switch e := e.(type) {
+	case *Message:
+		return &e.Envelope
+	case *Notification:
+		return &e.Envelope
+	case *RequestCommand:
+		return &e.Envelope
+	case *ResponseCommand:
+		return &e.Envelope
+	case *Session:
+		return &e.Envelope
+	default:
+		return &Envelope{}
+	}
+}
+
 // rawEnvelope is an intermediate type for marshalling.
 type rawEnvelope struct {
 	// Common envelope properties
@@ -150,6 +225,8 @@ type rawEnvelope struct {
 	To       *Node             `json:"to,omitempty"`
 	Metadata map[string]string `json:"metadata,omitempty"`
 
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+
 	// Shared properties
 
 	Reason *Reason    `json:"reason,omitempty"` // Shared by Notification and Message
@@ -186,6 +263,35 @@ type rawEnvelope struct {
 	SchemeOptions      []AuthenticationScheme `json:"schemeOptions,omitempty"`
 	Scheme             *AuthenticationScheme  `json:"scheme,omitempty"`
 	Authentication     *json.RawMessage       `json:"authentication,omitempty"`
+
+	// unknown is the JSON of an envelope with fields that match no known envelope type
+	unknown json.RawMessage
+	// size is the length of the decoded JSON, in bytes
+	size int64
+	// streamed is the message content or command resource written to a file by the transport
+	streamed *StreamedDocument
+}
+
+// rawEnvelopeBatch holds the envelopes decoded from a single value, which can be an envelope or an array of
+// envelopes, since some peers send multiple envelopes in one write.
+type rawEnvelopeBatch []rawEnvelope
+
+func (b *rawEnvelopeBatch) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) != 0 && trimmed[0] == '[' {
+		var raws []rawEnvelope
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return err
+		}
+		*b = raws
+		return nil
+	}
+
+	var raw rawEnvelope
+	if err := raw.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*b = rawEnvelopeBatch{raw}
+	return nil
 }
 
 func (re *rawEnvelope) envelopeType() (string, error) {
@@ -208,7 +314,7 @@ func (re *rawEnvelope) envelopeType() (string, error) {
 		return "Session", nil
 	}
 
-	return "", errors.New("could not determine the envelope type")
+	return "", newEnvelopeError(envelopeKindUnknown, re.ID, "", &UnknownEnvelopeError{ID: re.ID, Raw: re.unknown})
 }
 
 func (re *rawEnvelope) toEnvelope() (envelope, error) {
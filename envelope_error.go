@@ -0,0 +1,210 @@
+package lime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrFieldRequired indicates that a required envelope field is missing.
+var ErrFieldRequired = errors.New("field is required")
+
+// ErrUnknownEnvelope indicates that the fields of a received envelope match no known envelope type, which can be
+// an extension of a newer protocol version.
+var ErrUnknownEnvelope = errors.New("could not determine the envelope type")
+
+const (
+	envelopeKindUnknown         = "envelope"
+	envelopeKindMessage         = "message"
+	envelopeKindNotification    = "notification"
+	envelopeKindCommand         = "command"
+	envelopeKindRequestCommand  = "request command"
+	envelopeKindResponseCommand = "response command"
+	envelopeKindSession         = "session"
+)
+
+// EnvelopeError is returned when an envelope cannot be marshaled or unmarshaled, identifying the envelope and the
+// offending field, like "message 4609...: invalid 'type': invalid media type "text": missing subtype".
+type EnvelopeError struct {
+	// Kind is the envelope type, like 'message' or 'request command', or 'envelope' if it could not be determined.
+	Kind string
+	// ID is the envelope identifier, if available.
+	ID string
+	// Field is the JSON name of the offending field, if it could be determined.
+	Field string
+	// Err is the underlying error.
+	Err error
+
+	// raw is the JSON of the envelope, if it was well-formed
+	raw json.RawMessage
+}
+
+func (e *EnvelopeError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Kind)
+	if e.ID != "" {
+		b.WriteString(" ")
+		b.WriteString(e.ID)
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&b, ": invalid '%v'", e.Field)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *EnvelopeError) Unwrap() error {
+	return e.Err
+}
+
+func newEnvelopeError(kind string, id string, field string, err error) *EnvelopeError {
+	return &EnvelopeError{Kind: kind, ID: id, Field: field, Err: err}
+}
+
+// UnknownEnvelopeError is returned when an envelope of an unknown type is received. It matches ErrUnknownEnvelope.
+type UnknownEnvelopeError struct {
+	// ID is the envelope identifier, if available.
+	ID string
+	// Raw is the JSON of the envelope, if it was received with the JSON codec.
+	Raw json.RawMessage
+}
+
+func (e *UnknownEnvelopeError) Error() string {
+	return ErrUnknownEnvelope.Error()
+}
+
+func (e *UnknownEnvelopeError) Is(target error) bool {
+	return target == ErrUnknownEnvelope
+}
+
+// plainRawEnvelope has the rawEnvelope fields without its custom unmarshalling.
+type plainRawEnvelope rawEnvelope
+
+func (re *rawEnvelope) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*plainRawEnvelope)(re)); err != nil {
+		return unmarshalEnvelopeError(b, err)
+	}
+	re.size = int64(len(b))
+	if _, err := re.envelopeType(); err != nil {
+		// Keeps the JSON for the unknown envelope handlers, since the unknown fields are discarded
+		re.unknown = append(json.RawMessage(nil), b...)
+	}
+	return nil
+}
+
+// unmarshalEnvelopeError identifies the envelope and the field that caused the unmarshalling error.
+// It is only called after a failure, so it can afford to decode the fields one by one.
+func unmarshalEnvelopeError(b []byte, err error) error {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(b, &fields) != nil {
+		return newEnvelopeError(envelopeKindUnknown, "", "", err)
+	}
+
+	envErr := newEnvelopeError(envelopeKindOfFields(fields), "", "", err)
+	envErr.raw = append(json.RawMessage(nil), b...)
+	if id, ok := fields["id"]; ok {
+		_ = json.Unmarshal(id, &envErr.ID)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, _ := json.Marshal(map[string]json.RawMessage{name: fields[name]})
+		if json.Unmarshal(field, &plainRawEnvelope{}) != nil {
+			envErr.Field = name
+			break
+		}
+	}
+	return envErr
+}
+
+// envelopeKindOfFields determines the envelope type by the presence of its fields, like rawEnvelope.envelopeType.
+func envelopeKindOfFields(fields map[string]json.RawMessage) string {
+	has := func(name string) bool {
+		_, ok := fields[name]
+		return ok
+	}
+	switch {
+	case has("method") && has("uri"):
+		return envelopeKindRequestCommand
+	case has("method") && has("status"):
+		return envelopeKindResponseCommand
+	case has("event"):
+		return envelopeKindNotification
+	case has("content"):
+		return envelopeKindMessage
+	case has("state"):
+		return envelopeKindSession
+	}
+	return envelopeKindUnknown
+}
+
+// marshalRawEnvelope marshals the raw envelope, identifying the offending field in case of errors.
+func marshalRawEnvelope(kind string, raw *rawEnvelope) ([]byte, error) {
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, newEnvelopeError(kind, raw.ID, raw.invalidField(), err)
+	}
+	return b, nil
+}
+
+// invalidField returns the JSON name of the first field that cannot be marshaled.
+func (re *rawEnvelope) invalidField() string {
+	v := reflect.ValueOf(re).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.IsZero() || !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if _, err := json.Marshal(f.Interface()); err != nil {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			return name
+		}
+	}
+	return ""
+}
+
+// MalformedEnvelopeError is returned by the transports when a received envelope is well-formed JSON, but could not be
+// decoded, like one whose document does not match its media type. The transport can continue receiving, since the
+// envelope boundary is known, and the channels skip the envelope instead of stopping the receiver.
+type MalformedEnvelopeError struct {
+	// Raw is the JSON of the envelope. The envelopes that were decoded before failing are encoded again, without the
+	// unknown fields.
+	Raw json.RawMessage
+	// Err is the decoding error, usually an EnvelopeError.
+	Err error
+}
+
+func (e *MalformedEnvelopeError) Error() string {
+	return fmt.Sprintf("malformed envelope: %v", e.Err)
+}
+
+func (e *MalformedEnvelopeError) Unwrap() error {
+	return e.Err
+}
+
+// malformedEnvelope returns a MalformedEnvelopeError for the error of unmarshalling a well-formed envelope, or nil
+// for the other errors, after which the transport cannot continue receiving.
+func malformedEnvelope(err error) *MalformedEnvelopeError {
+	var envErr *EnvelopeError
+	if !errors.As(err, &envErr) || envErr.raw == nil {
+		return nil
+	}
+	return &MalformedEnvelopeError{Raw: envErr.raw, Err: envErr}
+}
+
+// populateError returns the error of converting the raw envelope, wrapped in a MalformedEnvelopeError, except for
+// the envelopes of unknown types, which are handled separately.
+func (re *rawEnvelope) populateError(err error) error {
+	if errors.Is(err, ErrUnknownEnvelope) {
+		return err
+	}
+	raw, _ := json.Marshal((*plainRawEnvelope)(re))
+	return &MalformedEnvelopeError{Raw: raw, Err: err}
+}
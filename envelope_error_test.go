@@ -0,0 +1,104 @@
+package lime
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessage_UnmarshalJSON_InvalidType(t *testing.T) {
+	// Arrange
+	j := []byte(`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","to":"golang@limeprotocol.org/default","type":"text","content":"Hello world"}`)
+	var m Message
+
+	// Act
+	err := json.Unmarshal(j, &m)
+
+	// Assert
+	var envErr *EnvelopeError
+	if assert.ErrorAs(t, err, &envErr) {
+		assert.Equal(t, "message", envErr.Kind)
+		assert.Equal(t, "4609d0a3-00eb-4e16-9d44-27d115c6eb31", envErr.ID)
+		assert.Equal(t, "type", envErr.Field)
+	}
+	assert.Equal(t, `message 4609d0a3-00eb-4e16-9d44-27d115c6eb31: invalid 'type': invalid media type "text": missing subtype`, err.Error())
+}
+
+func TestMessage_UnmarshalJSON_MissingContent(t *testing.T) {
+	// Arrange
+	j := []byte(`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","type":"text/plain"}`)
+	var m Message
+
+	// Act
+	err := json.Unmarshal(j, &m)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrFieldRequired)
+	assert.Equal(t, "message 4609d0a3-00eb-4e16-9d44-27d115c6eb31: invalid 'content': field is required", err.Error())
+}
+
+func TestRawEnvelope_Decode_InvalidMethod(t *testing.T) {
+	// Arrange
+	dec := json.NewDecoder(bytes.NewBufferString(`{"id":"99","method":"fetch","uri":"/ping"}`))
+	var raw rawEnvelope
+
+	// Act
+	err := dec.Decode(&raw)
+
+	// Assert
+	var envErr *EnvelopeError
+	if assert.ErrorAs(t, err, &envErr) {
+		assert.Equal(t, "request command", envErr.Kind)
+		assert.Equal(t, "99", envErr.ID)
+		assert.Equal(t, "method", envErr.Field)
+	}
+}
+
+func TestRawEnvelope_ToEnvelope_UnknownType(t *testing.T) {
+	// Arrange
+	raw := rawEnvelope{ID: "99"}
+
+	// Act
+	_, err := raw.toEnvelope()
+
+	// Assert
+	assert.Equal(t, "envelope 99: could not determine the envelope type", err.Error())
+}
+
+func TestRequestCommand_MarshalJSON_InvalidMethod(t *testing.T) {
+	// Arrange
+	cmd := createGetPingCommand()
+	cmd.ID = "99"
+	cmd.Method = "fetch"
+
+	// Act
+	_, err := json.Marshal(cmd)
+
+	// Assert
+	var envErr *EnvelopeError
+	if assert.ErrorAs(t, err, &envErr) {
+		assert.Equal(t, "request command", envErr.Kind)
+		assert.Equal(t, "99", envErr.ID)
+		assert.Equal(t, "method", envErr.Field)
+	}
+}
+
+func TestRawEnvelope_UnmarshalJSON_UnknownType(t *testing.T) {
+	// Arrange
+	b := []byte(`{"id":"99","from":"golang@localhost/home","stream":{"offset":10}}`)
+	var raw rawEnvelope
+
+	// Act
+	err := json.Unmarshal(b, &raw)
+	_, envErr := raw.toEnvelope()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, envErr, ErrUnknownEnvelope)
+	var unknownErr *UnknownEnvelopeError
+	if assert.ErrorAs(t, envErr, &unknownErr) {
+		assert.Equal(t, "99", unknownErr.ID)
+		assert.JSONEq(t, string(b), string(unknownErr.Raw))
+	}
+}
@@ -0,0 +1,55 @@
+package lime
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// envelopeExtensions is the feature flag for the serialization of the envelope extensions.
+var envelopeExtensions atomic.Bool
+
+// EnableEnvelopeExtensions defines if the Envelope Extensions are serialized in the 'extensions' field of the sent
+// envelopes and populated from the received ones. Since the field is not defined by the protocol, it should only be
+// enabled when all the nodes support it. It is disabled by default, when the extensions are ignored.
+func EnableEnvelopeExtensions(enabled bool) {
+	envelopeExtensions.Store(enabled)
+}
+
+// EnvelopeExtensionsEnabled indicates if the Envelope Extensions are serialized.
+func EnvelopeExtensionsEnabled() bool {
+	return envelopeExtensions.Load()
+}
+
+// SetExtension sets a typed extension value, which can be any value that can be serialized to JSON.
+func (env *Envelope) SetExtension(key string, value interface{}) *Envelope {
+	if env.Extensions == nil {
+		env.Extensions = make(map[string]interface{})
+	}
+	env.Extensions[key] = value
+	return env
+}
+
+// Extension returns the extension value for the key. The values of received envelopes have the types produced by
+// the encoding/json package, like float64 for the numbers; use DecodeExtension for other types.
+func (env *Envelope) Extension(key string) (interface{}, bool) {
+	v, ok := env.Extensions[key]
+	return v, ok
+}
+
+// DecodeExtension stores the extension value for the key in the value pointed by v, converting it through its JSON
+// representation. It returns false if the extension is not defined.
+func (env *Envelope) DecodeExtension(key string, v interface{}) (bool, error) {
+	value, ok := env.Extensions[key]
+	if !ok {
+		return false, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return true, fmt.Errorf("decode extension %v: %w", key, err)
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return true, fmt.Errorf("decode extension %v: %w", key, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,98 @@
+package lime
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessage_MarshalJSON_ExtensionsDisabled(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.SetExtension("priority", 5)
+
+	// Act
+	b, err := json.Marshal(msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "extensions")
+}
+
+func TestMessage_MarshalJSON_ExtensionsEnabled(t *testing.T) {
+	// Arrange
+	EnableEnvelopeExtensions(true)
+	defer EnableEnvelopeExtensions(false)
+	msg := createMessage()
+	msg.SetExtension("priority", 5).SetExtension("urgent", true)
+
+	// Act
+	b, err := json.Marshal(msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.JSONEq(t,
+		`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","to":"golang@limeprotocol.org/default","type":"text/plain","content":"Hello world","extensions":{"priority":5,"urgent":true}}`,
+		string(b))
+}
+
+func TestMessage_UnmarshalJSON_Extensions(t *testing.T) {
+	// Arrange
+	EnableEnvelopeExtensions(true)
+	defer EnableEnvelopeExtensions(false)
+	b := []byte(`{"id":"1","type":"text/plain","content":"Hello","extensions":{"priority":5,"geo":{"lat":-19.9,"lng":-43.9}}}`)
+	var msg Message
+
+	// Act
+	err := json.Unmarshal(b, &msg)
+
+	// Assert
+	assert.NoError(t, err)
+	priority, ok := msg.Extension("priority")
+	assert.True(t, ok)
+	assert.Equal(t, float64(5), priority)
+	var n int
+	ok, err = msg.DecodeExtension("priority", &n)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	var geo struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	}
+	ok, err = msg.DecodeExtension("geo", &geo)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, -19.9, geo.Lat)
+	ok, _ = msg.DecodeExtension("missing", &n)
+	assert.False(t, ok)
+}
+
+func TestMessage_UnmarshalJSON_ExtensionsIgnoredWhenDisabled(t *testing.T) {
+	// Arrange
+	b := []byte(`{"id":"1","type":"text/plain","content":"Hello","extensions":{"priority":5}}`)
+	var msg Message
+
+	// Act
+	err := json.Unmarshal(b, &msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Nil(t, msg.Extensions)
+}
+
+func TestMessage_Clone_Extensions(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.SetExtension("geo", map[string]interface{}{"lat": -19.9})
+
+	// Act
+	clone, err := msg.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.Extensions["geo"].(map[string]interface{})["lat"] = 0.0
+
+	// Assert
+	assert.Equal(t, -19.9, msg.Extensions["geo"].(map[string]interface{})["lat"])
+}
@@ -0,0 +1,143 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+)
+
+var (
+	// ErrInvalidEnvelopeID is returned when sending an envelope whose ID is refused by the EnvelopeIDPolicy.
+	ErrInvalidEnvelopeID = errors.New("invalid envelope id")
+	// ErrDuplicateEnvelopeID is returned when sending a message or a request command with the ID of a previous one
+	// of the session, whose notifications or response would be mismatched.
+	ErrDuplicateEnvelopeID = errors.New("duplicate envelope id")
+)
+
+// EnvelopeIDPolicy defines the checks of the IDs of the envelopes sent by the channels. The envelopes without ID,
+// like the messages that do not require notifications, are not checked.
+// Only the envelopes originated by the local node, which have no sender or are sent by it, are checked. The
+// forwarded envelopes, like the messages delivered by a server to the destination sessions, keep the IDs defined by
+// their senders.
+type EnvelopeIDPolicy struct {
+	// Validate checks the format of the IDs, returning an error for the malformed ones, like the UUIDEnvelopeID
+	// function. If nil, any format is accepted.
+	Validate func(id string) error
+	// Window is the number of the most recent message and request command IDs of the session that are checked for
+	// reuse. The responses and notifications are not checked, since they have the IDs of the envelopes they reply.
+	// If zero, the reuse is not checked.
+	Window int
+}
+
+// UUIDEnvelopeID validates that the ID is a UUID, like the ones generated by the NewEnvelopeID function.
+func UUIDEnvelopeID(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("not an uuid: %w", err)
+	}
+	return nil
+}
+
+// MatchEnvelopeID creates a validation function for the EnvelopeIDPolicy that accepts the IDs matching the regular
+// expression, for the applications with their own ID format.
+func MatchEnvelopeID(re *regexp.Regexp) func(id string) error {
+	if re == nil {
+		panic("nil regexp")
+	}
+	return func(id string) error {
+		if !re.MatchString(id) {
+			return fmt.Errorf("does not match %v", re)
+		}
+		return nil
+	}
+}
+
+// SetEnvelopeIDPolicy defines the checks of the IDs of the envelopes sent by the channel while established.
+func (c *channel) SetEnvelopeIDPolicy(p *EnvelopeIDPolicy) {
+	if err := c.ensureState(SessionStateNew, "set envelope id policy"); err != nil {
+		panic(err)
+	}
+	if p.Window < 0 {
+		panic("negative window")
+	}
+	c.idPolicy = p
+	if p.Window > 0 {
+		c.sentIDs = &envelopeIDWindow{ids: make(map[string]struct{}, p.Window), order: make([]string, p.Window)}
+	}
+}
+
+// originatedLocally indicates if the envelope is sent on behalf of the local node, instead of forwarded from
+// another one.
+func (c *channel) originatedLocally(e envelope) bool {
+	sender := envelopeOf(e).Sender()
+	return sender.Name == "" || sender.Identity == c.localNode.Identity
+}
+
+// validateEnvelopeID checks the format of the ID of the sent envelope, if the policy is defined.
+func (c *channel) validateEnvelopeID(e envelope) error {
+	if c.idPolicy == nil || c.idPolicy.Validate == nil || !c.originatedLocally(e) {
+		return nil
+	}
+	id := envelopeOf(e).ID
+	if id == "" {
+		return nil
+	}
+	if err := c.idPolicy.Validate(id); err != nil {
+		return fmt.Errorf("%w '%v': %v", ErrInvalidEnvelopeID, id, err)
+	}
+	return nil
+}
+
+// trackEnvelopeID adds the ID of the sent message or request command to the window of the session, failing if it is
+// already there. It must be called with the send lock.
+func (c *channel) trackEnvelopeID(ctx context.Context, e envelope) error {
+	if c.sentIDs == nil || isRetransmission(ctx) || !c.originatedLocally(e) {
+		return nil
+	}
+	var key string
+	switch e := e.(type) {
+	case *Message:
+		key = "message:" + e.ID
+	case *RequestCommand:
+		key = "command:" + e.ID
+	}
+	if key == "" || envelopeOf(e).ID == "" {
+		return nil
+	}
+	if !c.sentIDs.add(key) {
+		return fmt.Errorf("%w '%v'", ErrDuplicateEnvelopeID, envelopeOf(e).ID)
+	}
+	return nil
+}
+
+// envelopeIDWindow holds the most recent IDs, discarding the oldest one when full.
+type envelopeIDWindow struct {
+	ids   map[string]struct{}
+	order []string // order is a ring buffer of the IDs
+	next  int
+}
+
+func (w *envelopeIDWindow) add(id string) bool {
+	if _, ok := w.ids[id]; ok {
+		return false
+	}
+	if old := w.order[w.next]; old != "" {
+		delete(w.ids, old)
+	}
+	w.order[w.next] = id
+	w.next = (w.next + 1) % len(w.order)
+	w.ids[id] = struct{}{}
+	return true
+}
+
+// withRetransmission marks the context of the sends that repeat an envelope on purpose, which are not checked for
+// the ID reuse.
+func withRetransmission(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyRetransmission, true)
+}
+
+func isRetransmission(ctx context.Context) bool {
+	retransmission, _ := ctx.Value(contextKeyRetransmission).(bool)
+	return retransmission
+}
@@ -0,0 +1,171 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestChannel_SendMessage_InvalidEnvelopeID(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 10)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetEnvelopeIDPolicy(&EnvelopeIDPolicy{Validate: UUIDEnvelopeID})
+	c.setState(SessionStateEstablished)
+	m := createMessage()
+	m.ID = "1"
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(ctx, m)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidEnvelopeID)
+}
+
+func TestChannel_SendMessage_MatchEnvelopeID(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 10)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetEnvelopeIDPolicy(&EnvelopeIDPolicy{Validate: MatchEnvelopeID(regexp.MustCompile(`^msg-\d+$`))})
+	c.setState(SessionStateEstablished)
+	valid := createMessage()
+	valid.ID = "msg-1"
+	invalid := createMessage()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	validErr := c.SendMessage(ctx, valid)
+	invalidErr := c.SendMessage(ctx, invalid)
+
+	// Assert
+	assert.NoError(t, validErr)
+	assert.ErrorIs(t, invalidErr, ErrInvalidEnvelopeID)
+}
+
+func TestChannel_SendMessage_DuplicateEnvelopeID(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 10)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetEnvelopeIDPolicy(&EnvelopeIDPolicy{Window: 2})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	m := createMessage()
+	assert.NoError(t, c.SendMessage(ctx, m))
+
+	// Act
+	err := c.SendMessage(ctx, m)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrDuplicateEnvelopeID)
+}
+
+func TestChannel_SendMessage_DuplicateEnvelopeIDOutsideWindow(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 10)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetEnvelopeIDPolicy(&EnvelopeIDPolicy{Window: 2})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	m := createMessage()
+	assert.NoError(t, c.SendMessage(ctx, m))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, c.SendMessage(ctx, &Message{Envelope: Envelope{ID: NewEnvelopeID()}}))
+	}
+
+	// Act
+	err := c.SendMessage(ctx, m)
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestChannel_SendEnvelopes_SameIDDifferentTypes(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 10)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetEnvelopeIDPolicy(&EnvelopeIDPolicy{Validate: UUIDEnvelopeID, Window: 10})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, c.SendMessage(ctx, createMessage()))
+
+	// Act
+	cmdErr := c.SendRequestCommand(ctx, createGetPingCommand())
+	notErr := c.SendNotification(ctx, createNotification())
+	dupCmdErr := c.SendRequestCommand(ctx, createGetPingCommand())
+
+	// Assert
+	assert.NoError(t, cmdErr)
+	assert.NoError(t, notErr)
+	assert.ErrorIs(t, dupCmdErr, ErrDuplicateEnvelopeID)
+}
+
+func TestChannel_SendMessage_Retransmission(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 10)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetEnvelopeIDPolicy(&EnvelopeIDPolicy{Window: 10})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	m := createMessage()
+	assert.NoError(t, c.SendMessage(ctx, m))
+
+	// Act
+	err := c.SendMessage(withRetransmission(ctx), m)
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestChannel_SendMessage_ForwardedEnvelopeID(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 10)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.localNode = Node{Identity: Identity{Name: "postmaster", Domain: "localhost"}, Instance: "server1"}
+	c.SetEnvelopeIDPolicy(&EnvelopeIDPolicy{Validate: UUIDEnvelopeID, Window: 10})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	fromAlice := createMessage()
+	fromAlice.ID = "1"
+	fromAlice.SetFromString("alice@localhost/home")
+	fromBob := createMessage()
+	fromBob.ID = "1"
+	fromBob.SetFromString("bob@localhost/home")
+	local := createMessage()
+	local.ID = "1"
+	local.From = c.localNode
+
+	// Act
+	aliceErr := c.SendMessage(ctx, fromAlice)
+	bobErr := c.SendMessage(ctx, fromBob)
+	localErr := c.SendMessage(ctx, local)
+
+	// Assert
+	assert.NoError(t, aliceErr)
+	assert.NoError(t, bobErr)
+	assert.ErrorIs(t, localErr, ErrInvalidEnvelopeID)
+}
@@ -0,0 +1,554 @@
+package lime
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// The envelope maps have the same shape as the decoded JSON of the envelopes, like {"id": "...", "to":
+// "golang@limeprotocol.org", "type": "text/plain", "content": "Hello world"}, so they can be exposed to dynamic
+// scripting layers, like policy engines, without encoding the envelope to JSON and decoding it again.
+// The values are of the types produced by the encoding/json package: string, float64, bool, []interface{} and
+// map[string]interface{}. The JSON and text documents are converted directly, while the other document types are
+// converted through their JSON representation. Unlike the serialization, the extensions are always included.
+
+// ToMap converts the message to a map with the shape of its JSON representation.
+func (msg *Message) ToMap() (map[string]interface{}, error) {
+	if msg.Content == nil {
+		return nil, newEnvelopeError(envelopeKindMessage, msg.ID, "content", ErrFieldRequired)
+	}
+	m := msg.Envelope.toMap()
+	content, err := documentToMapValue(msg.Content)
+	if err != nil {
+		return nil, newEnvelopeError(envelopeKindMessage, msg.ID, "content", err)
+	}
+	m["type"] = msg.Type.String()
+	m["content"] = content
+	return m, nil
+}
+
+// FromMap replaces the message with the values of a map created by ToMap, validating it like the deserialization.
+func (msg *Message) FromMap(m map[string]interface{}) error {
+	message := Message{}
+	if err := message.Envelope.populateMap(envelopeKindMessage, m); err != nil {
+		return err
+	}
+	ok, err := mapText(m, "type", &message.Type)
+	if err != nil {
+		return newEnvelopeError(envelopeKindMessage, message.ID, "type", err)
+	}
+	if !ok {
+		return newEnvelopeError(envelopeKindMessage, message.ID, "type", ErrFieldRequired)
+	}
+	content, ok := m["content"]
+	if !ok || content == nil {
+		return newEnvelopeError(envelopeKindMessage, message.ID, "content", ErrFieldRequired)
+	}
+	message.Content, err = documentFromMapValue(content, message.Type)
+	if err != nil {
+		return newEnvelopeError(envelopeKindMessage, message.ID, "content", err)
+	}
+
+	*msg = message
+	return nil
+}
+
+// ToMap converts the notification to a map with the shape of its JSON representation.
+func (not *Notification) ToMap() (map[string]interface{}, error) {
+	m := not.Envelope.toMap()
+	if not.Event != "" {
+		m["event"] = string(not.Event)
+	}
+	if not.Reason != nil {
+		m["reason"] = reasonToMap(not.Reason)
+	}
+	return m, nil
+}
+
+// FromMap replaces the notification with the values of a map created by ToMap, validating it like the
+// deserialization.
+func (not *Notification) FromMap(m map[string]interface{}) error {
+	notification := Notification{}
+	if err := notification.Envelope.populateMap(envelopeKindNotification, m); err != nil {
+		return err
+	}
+	ok, err := mapText(m, "event", &notification.Event)
+	if err != nil {
+		return newEnvelopeError(envelopeKindNotification, notification.ID, "event", err)
+	}
+	if !ok {
+		return newEnvelopeError(envelopeKindNotification, notification.ID, "event", ErrFieldRequired)
+	}
+	if notification.Reason, err = reasonFromMap(m); err != nil {
+		return newEnvelopeError(envelopeKindNotification, notification.ID, "reason", err)
+	}
+
+	*not = notification
+	return nil
+}
+
+// ToMap converts the command to a map with the shape of its JSON representation.
+func (cmd *RequestCommand) ToMap() (map[string]interface{}, error) {
+	m, err := cmd.Command.toMap(envelopeKindRequestCommand)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.URI != nil {
+		m["uri"] = cmd.URI.String()
+	}
+	return m, nil
+}
+
+// FromMap replaces the command with the values of a map created by ToMap, validating it like the deserialization.
+func (cmd *RequestCommand) FromMap(m map[string]interface{}) error {
+	command := RequestCommand{}
+	if err := command.Command.populateMap(envelopeKindRequestCommand, m); err != nil {
+		return err
+	}
+	uri := &URI{}
+	ok, err := mapText(m, "uri", uri)
+	if err != nil {
+		return newEnvelopeError(envelopeKindRequestCommand, command.ID, "uri", err)
+	}
+	if ok {
+		command.URI = uri
+	}
+
+	*cmd = command
+	return nil
+}
+
+// ToMap converts the command to a map with the shape of its JSON representation.
+func (cmd *ResponseCommand) ToMap() (map[string]interface{}, error) {
+	m, err := cmd.Command.toMap(envelopeKindResponseCommand)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Status != "" {
+		m["status"] = string(cmd.Status)
+	}
+	if cmd.Reason != nil {
+		m["reason"] = reasonToMap(cmd.Reason)
+	}
+	return m, nil
+}
+
+// FromMap replaces the command with the values of a map created by ToMap, validating it like the deserialization.
+func (cmd *ResponseCommand) FromMap(m map[string]interface{}) error {
+	command := ResponseCommand{}
+	if err := command.Command.populateMap(envelopeKindResponseCommand, m); err != nil {
+		return err
+	}
+	status, _, err := mapString(m, "status")
+	if err != nil {
+		return newEnvelopeError(envelopeKindResponseCommand, command.ID, "status", err)
+	}
+	command.Status = CommandStatus(status)
+	if command.Reason, err = reasonFromMap(m); err != nil {
+		return newEnvelopeError(envelopeKindResponseCommand, command.ID, "reason", err)
+	}
+
+	*cmd = command
+	return nil
+}
+
+// ToMap converts the session to a map with the shape of its JSON representation.
+func (s *Session) ToMap() (map[string]interface{}, error) {
+	m := s.Envelope.toMap()
+	if s.State != "" {
+		m["state"] = string(s.State)
+	}
+	if s.EncryptionOptions != nil {
+		m["encryptionOptions"] = stringsToMapValue(s.EncryptionOptions)
+	}
+	if s.Encryption != "" {
+		m["encryption"] = string(s.Encryption)
+	}
+	if s.CompressionOptions != nil {
+		m["compressionOptions"] = stringsToMapValue(s.CompressionOptions)
+	}
+	if s.Compression != "" {
+		m["compression"] = string(s.Compression)
+	}
+	if s.SchemeOptions != nil {
+		m["schemeOptions"] = stringsToMapValue(s.SchemeOptions)
+	}
+	if s.Scheme != "" {
+		m["scheme"] = string(s.Scheme)
+	}
+	if s.Authentication != nil {
+		a, err := jsonToMapValue(s.Authentication)
+		if err != nil {
+			return nil, newEnvelopeError(envelopeKindSession, s.ID, "authentication", err)
+		}
+		m["authentication"] = a
+	}
+	if s.Reason != nil {
+		m["reason"] = reasonToMap(s.Reason)
+	}
+	return m, nil
+}
+
+// FromMap replaces the session with the values of a map created by ToMap, validating it like the deserialization.
+func (s *Session) FromMap(m map[string]interface{}) error {
+	session := Session{}
+	if err := session.Envelope.populateMap(envelopeKindSession, m); err != nil {
+		return err
+	}
+	ok, err := mapText(m, "state", &session.State)
+	if err != nil {
+		return newEnvelopeError(envelopeKindSession, session.ID, "state", err)
+	}
+	if !ok {
+		return newEnvelopeError(envelopeKindSession, session.ID, "state", ErrFieldRequired)
+	}
+	if session.EncryptionOptions, err = mapStrings[SessionEncryption](m, "encryptionOptions"); err != nil {
+		return newEnvelopeError(envelopeKindSession, session.ID, "encryptionOptions", err)
+	}
+	if session.CompressionOptions, err = mapStrings[SessionCompression](m, "compressionOptions"); err != nil {
+		return newEnvelopeError(envelopeKindSession, session.ID, "compressionOptions", err)
+	}
+	if session.SchemeOptions, err = mapStrings[AuthenticationScheme](m, "schemeOptions"); err != nil {
+		return newEnvelopeError(envelopeKindSession, session.ID, "schemeOptions", err)
+	}
+	for _, f := range []struct {
+		key string
+		v   *string
+	}{
+		{"encryption", (*string)(&session.Encryption)},
+		{"compression", (*string)(&session.Compression)},
+		{"scheme", (*string)(&session.Scheme)},
+	} {
+		if *f.v, _, err = mapString(m, f.key); err != nil {
+			return newEnvelopeError(envelopeKindSession, session.ID, f.key, err)
+		}
+	}
+	if a, ok := m["authentication"]; ok && a != nil {
+		if session.Scheme == "" {
+			return newEnvelopeError(envelopeKindSession, session.ID, "scheme", ErrFieldRequired)
+		}
+		factory, ok := authFactories[session.Scheme]
+		if !ok {
+			return newEnvelopeError(envelopeKindSession, session.ID, "scheme", fmt.Errorf(`unknown authentication scheme '%v'`, session.Scheme))
+		}
+		auth := factory()
+		if err = jsonFromMapValue(a, &auth); err != nil {
+			return newEnvelopeError(envelopeKindSession, session.ID, "authentication", err)
+		}
+		session.Authentication = auth
+	}
+	if session.Reason, err = reasonFromMap(m); err != nil {
+		return newEnvelopeError(envelopeKindSession, session.ID, "reason", err)
+	}
+
+	*s = session
+	return nil
+}
+
+func (env *Envelope) toMap() map[string]interface{} {
+	m := make(map[string]interface{})
+	if env.ID != "" {
+		m["id"] = env.ID
+	}
+	if env.From != (Node{}) {
+		m["from"] = env.From.String()
+	}
+	if env.PP != (Node{}) {
+		m["pp"] = env.PP.String()
+	}
+	if env.To != (Node{}) {
+		m["to"] = env.To.String()
+	}
+	if len(env.Metadata) != 0 {
+		metadata := make(map[string]interface{}, len(env.Metadata))
+		for k, v := range env.Metadata {
+			metadata[k] = v
+		}
+		m["metadata"] = metadata
+	}
+	if len(env.Extensions) != 0 {
+		m["extensions"] = cloneJSONValue(env.Extensions)
+	}
+	return m
+}
+
+func (env *Envelope) populateMap(kind string, m map[string]interface{}) error {
+	id, _, err := mapString(m, "id")
+	if err != nil {
+		return newEnvelopeError(kind, "", "id", err)
+	}
+	env.ID = id
+	for _, f := range []struct {
+		key string
+		n   *Node
+	}{
+		{"from", &env.From},
+		{"pp", &env.PP},
+		{"to", &env.To},
+	} {
+		if _, err = mapText(m, f.key, f.n); err != nil {
+			return newEnvelopeError(kind, id, f.key, err)
+		}
+	}
+
+	switch metadata := m["metadata"].(type) {
+	case nil:
+	case map[string]string:
+		env.Metadata = make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			env.Metadata[k] = v
+		}
+	case map[string]interface{}:
+		env.Metadata = make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			s, ok := v.(string)
+			if !ok {
+				return newEnvelopeError(kind, id, "metadata", fmt.Errorf("expected a string value for %v, got %T", k, v))
+			}
+			env.Metadata[k] = s
+		}
+	default:
+		return newEnvelopeError(kind, id, "metadata", fmt.Errorf("expected an object, got %T", metadata))
+	}
+
+	switch extensions := m["extensions"].(type) {
+	case nil:
+	case map[string]interface{}:
+		env.Extensions = cloneJSONValue(extensions).(map[string]interface{})
+	default:
+		return newEnvelopeError(kind, id, "extensions", fmt.Errorf("expected an object, got %T", extensions))
+	}
+	return nil
+}
+
+func (cmd *Command) toMap(kind string) (map[string]interface{}, error) {
+	m := cmd.Envelope.toMap()
+	if cmd.Method != "" {
+		m["method"] = string(cmd.Method)
+	}
+	if cmd.Resource != nil {
+		resource, err := documentToMapValue(cmd.Resource)
+		if err != nil {
+			return nil, newEnvelopeError(kind, cmd.ID, "resource", err)
+		}
+		m["resource"] = resource
+		if cmd.Type != nil {
+			m["type"] = cmd.Type.String()
+		}
+	}
+	return m, nil
+}
+
+func (cmd *Command) populateMap(kind string, m map[string]interface{}) error {
+	if err := cmd.Envelope.populateMap(kind, m); err != nil {
+		return err
+	}
+	if resource, ok := m["resource"]; ok && resource != nil {
+		t := MediaType{}
+		ok, err := mapText(m, "type", &t)
+		if err != nil {
+			return newEnvelopeError(kind, cmd.ID, "type", err)
+		}
+		if !ok {
+			return newEnvelopeError(kind, cmd.ID, "type", ErrFieldRequired)
+		}
+		cmd.Resource, err = documentFromMapValue(resource, t)
+		if err != nil {
+			return newEnvelopeError(kind, cmd.ID, "resource", err)
+		}
+		cmd.Type = &t
+	}
+	ok, err := mapText(m, "method", &cmd.Method)
+	if err != nil {
+		return newEnvelopeError(kind, cmd.ID, "method", err)
+	}
+	if !ok {
+		return newEnvelopeError(kind, cmd.ID, "method", ErrFieldRequired)
+	}
+	return nil
+}
+
+func reasonToMap(r *Reason) map[string]interface{} {
+	m := make(map[string]interface{})
+	if r.Code != 0 {
+		m["code"] = float64(r.Code)
+	}
+	if r.Description != "" {
+		m["description"] = r.Description
+	}
+	return m
+}
+
+func reasonFromMap(m map[string]interface{}) (*Reason, error) {
+	switch r := m["reason"].(type) {
+	case nil:
+		return nil, nil
+	case *Reason:
+		reason := *r
+		return &reason, nil
+	case map[string]interface{}:
+		reason := Reason{}
+		code, err := mapInt(r, "code")
+		if err != nil {
+			return nil, err
+		}
+		reason.Code = code
+		if reason.Description, _, err = mapString(r, "description"); err != nil {
+			return nil, err
+		}
+		return &reason, nil
+	default:
+		return nil, fmt.Errorf("expected an object, got %T", r)
+	}
+}
+
+// documentToMapValue converts the document to its JSON value, directly for the JSON and text documents.
+func documentToMapValue(d Document) (interface{}, error) {
+	switch d := d.(type) {
+	case *JsonDocument:
+		if d == nil {
+			return nil, nil
+		}
+		return cloneJSONValue(map[string]interface{}(*d)), nil
+	case *TextDocument:
+		if d == nil {
+			return nil, nil
+		}
+		return string(*d), nil
+	case TextDocument:
+		return string(d), nil
+	default:
+		return jsonToMapValue(d)
+	}
+}
+
+// documentFromMapValue converts the JSON value to a document of the media type, directly for the JSON and text
+// documents.
+func documentFromMapValue(v interface{}, t MediaType) (Document, error) {
+	factory, err := GetDocumentFactory(t)
+	if err != nil {
+		return nil, err
+	}
+	d := factory()
+	switch doc := d.(type) {
+	case *JsonDocument:
+		if obj, ok := v.(map[string]interface{}); ok {
+			*doc = cloneJSONValue(obj).(map[string]interface{})
+			return doc, nil
+		}
+	case *TextDocument:
+		if s, ok := v.(string); ok {
+			*doc = TextDocument(s)
+			return doc, nil
+		}
+	}
+	if err = jsonFromMapValue(v, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func jsonToMapValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err = json.Unmarshal(b, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func jsonFromMapValue(value interface{}, v interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func stringsToMapValue[T ~string](values []T) []interface{} {
+	s := make([]interface{}, len(values))
+	for i, v := range values {
+		s[i] = string(v)
+	}
+	return s
+}
+
+// mapString returns the string value of the key, if present.
+func mapString(m map[string]interface{}, key string) (string, bool, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		return v, true, nil
+	case fmt.Stringer:
+		return v.String(), true, nil
+	default:
+		return "", false, fmt.Errorf("expected a string, got %T", v)
+	}
+}
+
+// mapText parses the string value of the key into v, if present.
+func mapText(m map[string]interface{}, key string, v encoding.TextUnmarshaler) (bool, error) {
+	s, ok, err := mapString(m, key)
+	if !ok || err != nil {
+		return false, err
+	}
+	return true, v.UnmarshalText([]byte(s))
+}
+
+// mapStrings returns the string array value of the key, if present.
+func mapStrings[T ~string](m map[string]interface{}, key string) ([]T, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return nil, nil
+	case []T:
+		return append([]T{}, v...), nil
+	case []string:
+		s := make([]T, len(v))
+		for i, e := range v {
+			s[i] = T(e)
+		}
+		return s, nil
+	case []interface{}:
+		s := make([]T, len(v))
+		for i, e := range v {
+			str, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string item, got %T", e)
+			}
+			s[i] = T(str)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("expected an array, got %T", v)
+	}
+}
+
+// mapInt returns the integer value of the key, which can be of any numeric type.
+func mapInt(m map[string]interface{}, key string) (int, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("expected an integer, got %v", v)
+		}
+		return int(v), nil
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", v)
+	}
+}
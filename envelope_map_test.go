@@ -0,0 +1,220 @@
+package lime
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// decodeJSONMap returns the decoded JSON of the envelope, which should be equal to its map.
+func decodeJSONMap(t *testing.T, v interface{}) map[string]interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMessage_ToMap(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.From = ParseNode("postmaster@limeprotocol.org/#server1")
+	msg.SetMetadataKeyValue("#message.origin", "golang")
+	msg.SetContent(createJsonDocument())
+
+	// Act
+	m, err := msg.ToMap()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, decodeJSONMap(t, msg), m)
+}
+
+func TestMessage_FromMap(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.SetMetadataKeyValue("#message.origin", "golang")
+	msg.SetExtension("priority", 2.0)
+	m, _ := msg.ToMap()
+	actual := &Message{}
+
+	// Act
+	err := actual.FromMap(m)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, msg, actual)
+}
+
+func TestMessage_FromMap_JsonDocument(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.SetContent(createJsonDocument())
+	m, _ := msg.ToMap()
+	actual := &Message{}
+
+	// Act
+	err := actual.FromMap(m)
+	m["content"].(map[string]interface{})["property1"] = "changed"
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, msg, actual)
+}
+
+func TestMessage_FromMap_MissingContent(t *testing.T) {
+	// Arrange
+	m := map[string]interface{}{"id": "1", "type": "text/plain"}
+	msg := &Message{}
+
+	// Act
+	err := msg.FromMap(m)
+
+	// Assert
+	var envErr *EnvelopeError
+	if assert.True(t, errors.As(err, &envErr)) {
+		assert.Equal(t, "content", envErr.Field)
+		assert.ErrorIs(t, err, ErrFieldRequired)
+	}
+}
+
+func TestMessage_FromMap_InvalidMetadata(t *testing.T) {
+	// Arrange
+	m := map[string]interface{}{
+		"id":       "1",
+		"type":     "text/plain",
+		"content":  "Hello world",
+		"metadata": map[string]interface{}{"count": 1.0},
+	}
+	msg := &Message{}
+
+	// Act
+	err := msg.FromMap(m)
+
+	// Assert
+	var envErr *EnvelopeError
+	if assert.True(t, errors.As(err, &envErr)) {
+		assert.Equal(t, "metadata", envErr.Field)
+	}
+}
+
+func TestNotification_ToMap_FromMap(t *testing.T) {
+	// Arrange
+	not := createNotification()
+	not.SetFailed(&Reason{Code: ReasonCodeMessageProcessingError, Description: "The message processing failed"})
+	actual := &Notification{}
+
+	// Act
+	m, err1 := not.ToMap()
+	err2 := actual.FromMap(m)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, decodeJSONMap(t, not), m)
+	assert.Equal(t, not, actual)
+}
+
+func TestNotification_FromMap_MissingEvent(t *testing.T) {
+	// Arrange
+	not := &Notification{}
+
+	// Act
+	err := not.FromMap(map[string]interface{}{"id": "1"})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrFieldRequired)
+}
+
+func TestRequestCommand_ToMap_FromMap(t *testing.T) {
+	// Arrange
+	cmd := createSetCommand()
+	cmd.SetResource(&Ping{})
+	actual := &RequestCommand{}
+
+	// Act
+	m, err1 := cmd.ToMap()
+	err2 := actual.FromMap(m)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, decodeJSONMap(t, cmd), m)
+	assert.Equal(t, cmd, actual)
+}
+
+func TestRequestCommand_FromMap_InvalidMethod(t *testing.T) {
+	// Arrange
+	m := map[string]interface{}{"id": "1", "method": "post", "uri": "/ping"}
+	cmd := &RequestCommand{}
+
+	// Act
+	err := cmd.FromMap(m)
+
+	// Assert
+	var envErr *EnvelopeError
+	if assert.True(t, errors.As(err, &envErr)) {
+		assert.Equal(t, "method", envErr.Field)
+	}
+}
+
+func TestResponseCommand_ToMap_FromMap(t *testing.T) {
+	// Arrange
+	cmd := createResponseCommand()
+	cmd.SetStatusFailure(Reason{Code: ReasonCodeCommandResourceNotFound, Description: "The resource was not found"})
+	actual := &ResponseCommand{}
+
+	// Act
+	m, err1 := cmd.ToMap()
+	err2 := actual.FromMap(m)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, decodeJSONMap(t, cmd), m)
+	assert.Equal(t, cmd, actual)
+}
+
+func TestResponseCommand_FromMap_IntegerCode(t *testing.T) {
+	// Arrange
+	m := map[string]interface{}{
+		"id":     "1",
+		"method": "get",
+		"status": "failure",
+		"reason": map[string]interface{}{"code": 67, "description": "The resource was not found"},
+	}
+	cmd := &ResponseCommand{}
+
+	// Act
+	err := cmd.FromMap(m)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &Reason{Code: 67, Description: "The resource was not found"}, cmd.Reason)
+}
+
+func TestSession_ToMap_FromMap(t *testing.T) {
+	// Arrange
+	s := createSession()
+	s.State = SessionStateAuthenticating
+	s.SchemeOptions = []AuthenticationScheme{AuthenticationSchemeGuest, AuthenticationSchemePlain}
+	a := &PlainAuthentication{}
+	a.SetPasswordAsBase64("secret")
+	s.SetAuthentication(a)
+	actual := &Session{}
+
+	// Act
+	m, err1 := s.ToMap()
+	err2 := actual.FromMap(m)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, decodeJSONMap(t, s), m)
+	assert.Equal(t, s, actual)
+}
@@ -0,0 +1,102 @@
+package lime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// specFieldOrder is the order of the envelope fields in the examples of the Lime protocol specification. The fields
+// of each envelope type are a subset of it, like 'id', 'from', 'to', 'type' and 'content' for the messages.
+var specFieldOrder = []string{
+	"id",
+	"from",
+	"pp",
+	"to",
+	"method",
+	"uri",
+	"status",
+	"state",
+	"event",
+	"type",
+	"content",
+	"resource",
+	"encryptionOptions",
+	"encryption",
+	"compressionOptions",
+	"compression",
+	"schemeOptions",
+	"scheme",
+	"authentication",
+	"reason",
+	"metadata",
+}
+
+// MarshalSpecOrder returns the JSON encoding of the envelope with its fields in the order of the Lime protocol
+// specification, followed by the fields that it does not define, like the extensions, sorted by name.
+// Unlike the default encoding, whose order follows the internal types of the package, the output is stable across
+// the library versions, so it can be used by the golden file tests and the diff-based change detection. The values
+// of the fields, like the message contents, are kept as encoded by their types.
+func MarshalSpecOrder(e any) ([]byte, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("marshal spec order: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("marshal spec order: %w", err)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for _, key := range specFieldOrder {
+		if _, ok := fields[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	extra := make([]string, 0, len(fields)-len(keys))
+	for key := range fields {
+		if !isSpecField(key) {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	keys = append(keys, extra...)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(fields[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalSpecOrderIndent is like MarshalSpecOrder, but indents the output like the json.MarshalIndent function,
+// which is more readable in the golden files.
+func MarshalSpecOrderIndent(e any, prefix, indent string) ([]byte, error) {
+	b, err := MarshalSpecOrder(e)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, fmt.Errorf("marshal spec order: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func isSpecField(key string) bool {
+	for _, k := range specFieldOrder {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
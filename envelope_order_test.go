@@ -0,0 +1,91 @@
+package lime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMarshalSpecOrder(t *testing.T) {
+	EnableEnvelopeExtensions(true)
+	defer EnableEnvelopeExtensions(false)
+	msg := createMessage()
+	msg.SetMetadataKeyValue("z", "last")
+	msg.SetMetadataKeyValue("a", "first")
+	msg.SetExtension("trace", "1")
+	failed := createResponseCommand()
+	failed.Status = CommandStatusFailure
+	failed.Reason = &Reason{Code: 67, Description: "Not found"}
+	notification := createNotification()
+	notification.Reason = &Reason{Code: 1}
+
+	tests := []struct {
+		name     string
+		envelope any
+		expected string
+	}{
+		{
+			"Message",
+			msg,
+			`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","to":"golang@limeprotocol.org/default","type":"text/plain",` +
+				`"content":"Hello world","metadata":{"a":"first","z":"last"},"extensions":{"trace":"1"}}`,
+		},
+		{
+			"Notification",
+			notification,
+			`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","to":"golang@limeprotocol.org/default","event":"received",` +
+				`"reason":{"code":1}}`,
+		},
+		{
+			"RequestCommand",
+			createGetPingCommand(),
+			`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","to":"postmaster@limeprotocol.org","method":"get","uri":"/ping"}`,
+		},
+		{
+			"ResponseCommand",
+			failed,
+			`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","from":"postmaster@limeprotocol.org","method":"get",` +
+				`"status":"failure","reason":{"code":67,"description":"Not found"}}`,
+		},
+		{
+			"Session",
+			createSession(),
+			`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","from":"postmaster@limeprotocol.org/#server1",` +
+				`"to":"golang@limeprotocol.org/default","state":"established"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := MarshalSpecOrder(tt.envelope)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(actual))
+		})
+	}
+}
+
+func TestMarshalSpecOrderIndent(t *testing.T) {
+	// Arrange
+	cmd := createGetPingCommand()
+
+	// Act
+	actual, err := MarshalSpecOrderIndent(cmd, "", "  ")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "id": "4609d0a3-00eb-4e16-9d44-27d115c6eb31",
+  "to": "postmaster@limeprotocol.org",
+  "method": "get",
+  "uri": "/ping"
+}`, string(actual))
+}
+
+func TestMarshalSpecOrder_NotObject(t *testing.T) {
+	// Act
+	_, err := MarshalSpecOrder([]string{"a"})
+
+	// Assert
+	assert.Error(t, err)
+}
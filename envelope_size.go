@@ -0,0 +1,105 @@
+package lime
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// EnvelopeSizeBuckets are the upper bounds, in bytes, of the buckets of the received envelope size histogram of the
+// TransportStats. The histogram has an additional bucket for the envelopes larger than the last bound.
+var EnvelopeSizeBuckets = []int64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+
+const (
+	// LargestEnvelopesCount is the number of largest received envelopes reported by the TransportStats.
+	LargestEnvelopesCount = 10
+	// LargestEnvelopesWindow is how long a received envelope is considered for the largest envelopes report.
+	LargestEnvelopesWindow = time.Hour
+)
+
+// EnvelopeSize describes a received envelope in the largest envelopes report, for diagnosing which envelopes are
+// approaching the transport ReadLimit.
+type EnvelopeSize struct {
+	Type      string    `json:"type"`           // Type is the envelope type, like 'message' or 'request command'.
+	ID        string    `json:"id,omitempty"`   // ID is the envelope identifier.
+	From      Node      `json:"from,omitempty"` // From is the envelope sender.
+	Size      int64     `json:"size"`           // Size is the encoded length of the envelope, in bytes.
+	Timestamp time.Time `json:"timestamp"`      // Timestamp is the moment when the envelope was received.
+}
+
+// envelopeSizes holds the size distribution of the received envelopes.
+type envelopeSizes struct {
+	mu        sync.Mutex
+	histogram []int64
+	largest   []EnvelopeSize // largest is sorted by size, in descending order
+}
+
+func (s *envelopeSizes) record(e envelope, size int64, now time.Time) {
+	if size <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.histogram == nil {
+		s.histogram = make([]int64, len(EnvelopeSizeBuckets)+1)
+	}
+	i, _ := slices.BinarySearch(EnvelopeSizeBuckets, size)
+	s.histogram[i]++
+
+	s.expire(now)
+	if len(s.largest) == LargestEnvelopesCount && s.largest[len(s.largest)-1].Size >= size {
+		return
+	}
+	env := envelopeOf(e)
+	entry := EnvelopeSize{
+		Type:      envelopeKind(e),
+		ID:        env.ID,
+		From:      env.From,
+		Size:      size,
+		Timestamp: now,
+	}
+	i, _ = slices.BinarySearchFunc(s.largest, size, func(l EnvelopeSize, size int64) int {
+		if l.Size > size {
+			return -1
+		}
+		return 1
+	})
+	s.largest = slices.Insert(s.largest, i, entry)
+	if len(s.largest) > LargestEnvelopesCount {
+		s.largest = s.largest[:LargestEnvelopesCount]
+	}
+}
+
+// expire removes the largest envelopes received before the window.
+func (s *envelopeSizes) expire(now time.Time) {
+	s.largest = slices.DeleteFunc(s.largest, func(l EnvelopeSize) bool {
+		return now.Sub(l.Timestamp) > LargestEnvelopesWindow
+	})
+}
+
+func (s *envelopeSizes) snapshot(now time.Time) (histogram []int64, largest []EnvelopeSize) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now)
+	return slices.Clone(s.histogram), slices.Clone(s.largest)
+}
+
+// envelopeKind returns the type name of the envelope.
+func envelopeKind(e envelope) string {
+	switch e.(type) {
+	case *Message:
+		return envelopeKindMessage
+	case *Notification:
+		return envelopeKindNotification
+	case *RequestCommand:
+		return envelopeKindRequestCommand
+	case *ResponseCommand:
+		return envelopeKindResponseCommand
+	case *Session:
+		return envelopeKindSession
+	default:
+		return envelopeKindUnknown
+	}
+}
@@ -0,0 +1,64 @@
+package lime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEnvelopeSizes_Record_Histogram(t *testing.T) {
+	// Arrange
+	var s envelopeSizes
+	now := time.Now()
+
+	// Act
+	s.record(createMessage(), 100, now)
+	s.record(createMessage(), 256, now)
+	s.record(createNotification(), 300, now)
+	s.record(createMessage(), 2<<20, now)
+
+	// Assert
+	histogram, _ := s.snapshot(now)
+	assert.Equal(t, []int64{2, 1, 0, 0, 0, 0, 0, 1}, histogram)
+}
+
+func TestEnvelopeSizes_Record_Largest(t *testing.T) {
+	// Arrange
+	var s envelopeSizes
+	now := time.Now()
+	for i := 1; i <= LargestEnvelopesCount+5; i++ {
+		s.record(createMessage(), int64(i*100), now)
+	}
+	notification := createNotification()
+
+	// Act
+	s.record(notification, 10000, now)
+
+	// Assert
+	_, largest := s.snapshot(now)
+	if assert.Len(t, largest, LargestEnvelopesCount) {
+		assert.Equal(t, int64(10000), largest[0].Size)
+		assert.Equal(t, "notification", largest[0].Type)
+		assert.Equal(t, notification.ID, largest[0].ID)
+		assert.Equal(t, notification.From, largest[0].From)
+		assert.Equal(t, int64(1500), largest[1].Size)
+		assert.Equal(t, int64(700), largest[LargestEnvelopesCount-1].Size)
+	}
+}
+
+func TestEnvelopeSizes_Snapshot_ExpiredLargest(t *testing.T) {
+	// Arrange
+	var s envelopeSizes
+	now := time.Now()
+	s.record(createMessage(), 5000, now.Add(-2*LargestEnvelopesWindow))
+	s.record(createMessage(), 1000, now)
+
+	// Act
+	histogram, largest := s.snapshot(now)
+
+	// Assert
+	assert.Equal(t, int64(2), histogram[1]+histogram[3])
+	if assert.Len(t, largest, 1) {
+		assert.Equal(t, int64(1000), largest[0].Size)
+	}
+}
@@ -0,0 +1,31 @@
+package lime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEnvelope_Sender(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     Node
+		pp       Node
+		expected Node
+	}{
+		{"From", ParseNode("golang@limeprotocol.org/default"), Node{}, ParseNode("golang@limeprotocol.org/default")},
+		{"PP", ParseNode("golang@limeprotocol.org/default"), ParseNode("postmaster@limeprotocol.org"), ParseNode("postmaster@limeprotocol.org")},
+		{"Empty", Node{}, Node{}, Node{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			env := &Envelope{From: tt.from, PP: tt.pp}
+
+			// Act
+			actual := env.Sender()
+
+			// Assert
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// The failure modes of the client session establishment, which can be compared through errors.Is with the errors
+// returned by ClientChannel.EstablishSession, ClientChannel.EstablishmentErr and the Client.
+var (
+	// ErrTransportClosed is returned when the transport is closed by the server or the network during the
+	// establishment.
+	ErrTransportClosed = errors.New("transport closed")
+	// ErrEstablishmentTimeout is returned when the context deadline is exceeded during the establishment. The
+	// EstablishmentError State indicates in which step it happened.
+	ErrEstablishmentTimeout = errors.New("session establishment timeout")
+	// ErrSessionRejected is returned when the server fails the new session, before the negotiation.
+	ErrSessionRejected = errors.New("session rejected")
+	// ErrNegotiationRejected is returned when the negotiation options are refused by the server or the channel, like
+	// when the encryption is required but not offered, or when the negotiated options cannot be applied.
+	ErrNegotiationRejected = errors.New("session negotiation rejected")
+	// ErrAuthenticationFailed is returned when the server fails the session during the authentication. The
+	// EstablishmentError Reason has the cause sent by the server.
+	ErrAuthenticationFailed = errors.New("session authentication failed")
+)
+
+// EstablishmentError is returned when the client session establishment fails. It wraps one of the failure mode
+// errors, like ErrAuthenticationFailed, and the underlying cause, if any:
+//
+//	ses, err := channel.EstablishSession(ctx, ...)
+//	if err == nil && ses.State == lime.SessionStateFailed {
+//		err = channel.EstablishmentErr()
+//	}
+//	var estErr *lime.EstablishmentError
+//	if errors.Is(err, lime.ErrAuthenticationFailed) && errors.As(err, &estErr) {
+//		log.Printf("invalid credentials: %v", estErr.Reason)
+//	}
+type EstablishmentError struct {
+	// State is the step of the establishment that failed, which is SessionStateNew, SessionStateNegotiating or
+	// SessionStateAuthenticating.
+	State SessionState
+	// Reason is the reason of the failed session received from the server, if any.
+	Reason *Reason
+	err    error // err is the failure mode error, if classified
+	cause  error // cause is the underlying error, if any
+}
+
+func (e *EstablishmentError) Error() string {
+	switch {
+	case e.cause != nil:
+		return fmt.Sprintf("establish session: %v", e.cause)
+	case e.Reason != nil:
+		return fmt.Sprintf("establish session: %v in the %v state: %v", e.err, e.State, *e.Reason)
+	}
+	return fmt.Sprintf("establish session: %v in the %v state", e.err, e.State)
+}
+
+func (e *EstablishmentError) Unwrap() []error {
+	var errs []error
+	if e.err != nil {
+		errs = append(errs, e.err)
+	}
+	if e.cause != nil {
+		errs = append(errs, e.cause)
+	}
+	return errs
+}
+
+// EstablishmentErr returns the EstablishmentError of the last session establishment of the channel, including when
+// the server failed the session, or nil if it was established.
+func (c *ClientChannel) EstablishmentErr() error {
+	if c.establishmentErr == nil {
+		return nil
+	}
+	return c.establishmentErr
+}
+
+// establishmentError classifies the error of an establishment step.
+func (c *ClientChannel) establishmentError(step SessionState, cause error) *EstablishmentError {
+	e := &EstablishmentError{State: step, cause: cause}
+	c.establishmentErr = e
+	switch {
+	case errors.Is(cause, context.DeadlineExceeded):
+		e.err = ErrEstablishmentTimeout
+	case errors.Is(cause, ErrEncryptionRequired):
+		e.err = ErrNegotiationRejected
+	case errors.Is(cause, io.EOF) || errors.Is(cause, net.ErrClosed) || !c.transport.Connected():
+		e.err = ErrTransportClosed
+	case step == SessionStateNegotiating:
+		// The negotiated options could not be applied to the transport
+		e.err = ErrNegotiationRejected
+	}
+	return e
+}
+
+// failedSession records the error of a failed session received from the server in an establishment step.
+func (c *ClientChannel) failedSession(step SessionState, ses *Session) (*Session, error) {
+	e := &EstablishmentError{State: step, Reason: ses.Reason}
+	c.establishmentErr = e
+	switch step {
+	case SessionStateNew:
+		e.err = ErrSessionRejected
+	case SessionStateNegotiating:
+		e.err = ErrNegotiationRejected
+	default:
+		e.err = ErrAuthenticationFailed
+	}
+	return ses, nil
+}
@@ -0,0 +1,190 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+// serveSessions replies the client sessions received by the transport with the sessions, in order, until the
+// replies end.
+func serveSessions(ctx context.Context, server Transport, replies ...*Session) {
+	for _, reply := range replies {
+		if _, err := server.Receive(ctx); err != nil {
+			return
+		}
+		reply.ID = "52e59849-19a8-4b2d-86b7-3fa563cdb616"
+		if err := server.Send(ctx, reply); err != nil {
+			return
+		}
+	}
+}
+
+func establishGuestSession(ctx context.Context, c *ClientChannel) (*Session, error) {
+	return c.EstablishSession(
+		ctx,
+		NoneCompressionSelector,
+		NoneEncryptionSelector,
+		Identity{Name: "golang", Domain: "limeprotocol.org"},
+		GuestAuthenticator,
+		"home")
+}
+
+func TestClientChannel_EstablishSession_AuthenticationFailed(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	reason := &Reason{Code: ReasonCodeSessionAuthenticationFailed, Description: "Invalid credentials"}
+	go serveSessions(ctx, server,
+		&Session{State: SessionStateAuthenticating, SchemeOptions: []AuthenticationScheme{AuthenticationSchemeGuest}},
+		&Session{State: SessionStateFailed, Reason: reason})
+
+	// Act
+	ses, err := establishGuestSession(ctx, c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStateFailed, ses.State)
+	estErr := c.EstablishmentErr()
+	assert.ErrorIs(t, estErr, ErrAuthenticationFailed)
+	var e *EstablishmentError
+	if assert.True(t, errors.As(estErr, &e)) {
+		assert.Equal(t, SessionStateAuthenticating, e.State)
+		assert.Equal(t, reason, e.Reason)
+	}
+}
+
+func TestClientChannel_EstablishSession_Rejected(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	go serveSessions(ctx, server, &Session{State: SessionStateFailed})
+
+	// Act
+	_, err := establishGuestSession(ctx, c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, c.EstablishmentErr(), ErrSessionRejected)
+}
+
+func TestClientChannel_EstablishSession_NegotiationRejected(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	go serveSessions(ctx, server,
+		&Session{
+			State:              SessionStateNegotiating,
+			CompressionOptions: []SessionCompression{SessionCompressionNone},
+			EncryptionOptions:  []SessionEncryption{SessionEncryptionNone},
+		},
+		&Session{State: SessionStateFailed, Reason: &Reason{Code: ReasonCodeSessionNegotiationInvalidOptions}})
+
+	// Act
+	_, err := establishGuestSession(ctx, c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, c.EstablishmentErr(), ErrNegotiationRejected)
+}
+
+func TestClientChannel_EstablishSession_Timeout(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	go serveSessions(ctx, server,
+		&Session{State: SessionStateAuthenticating, SchemeOptions: []AuthenticationScheme{AuthenticationSchemeGuest}})
+
+	// Act
+	_, err := establishGuestSession(ctx, c)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrEstablishmentTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var e *EstablishmentError
+	if assert.True(t, errors.As(err, &e)) {
+		assert.Equal(t, SessionStateAuthenticating, e.State)
+	}
+	assert.Equal(t, err, c.EstablishmentErr())
+}
+
+func TestClientChannel_EstablishSession_TransportClosed(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	go func() {
+		if _, err := server.Receive(ctx); err == nil {
+			_ = server.Close()
+		}
+	}()
+
+	// Act
+	_, err := establishGuestSession(ctx, c)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrTransportClosed)
+	assert.NotErrorIs(t, err, ErrEstablishmentTimeout)
+}
+
+func TestClientChannel_EstablishSession_EncryptionRequired(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	c.RequireEncryption()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	go serveSessions(ctx, server,
+		&Session{State: SessionStateAuthenticating, SchemeOptions: []AuthenticationScheme{AuthenticationSchemeGuest}})
+
+	// Act
+	_, err := establishGuestSession(ctx, c)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNegotiationRejected)
+	assert.ErrorIs(t, err, ErrEncryptionRequired)
+}
+
+func TestClient_Ready_AuthenticationFailed(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("client-establishment-error")
+	srv := startMetricsServer(t, addr, 0)
+	defer silentClose(srv)
+
+	// Act
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		PlainAuthentication("wrong").
+		Warmup().
+		Build()
+	defer silentClose(client)
+	err := <-client.Ready()
+
+	// Assert
+	assert.ErrorIs(t, err, ErrAuthenticationFailed)
+}
@@ -0,0 +1,39 @@
+package lime
+
+import (
+	"fmt"
+	"time"
+)
+
+// EstablishmentMetrics are the durations of the phases of a client session establishment, which allow the
+// attribution of a high connection latency to the network, the server negotiation or the authentication backend.
+type EstablishmentMetrics struct {
+	// TransportOpen is the time for creating the transport, including the connection with the server.
+	TransportOpen time.Duration
+	// Negotiation is the time for exchanging the new and negotiating sessions with the server, excluding the TLS
+	// handshake.
+	Negotiation time.Duration
+	// TLSHandshake is the time for upgrading the transport encryption, if negotiated.
+	TLSHandshake time.Duration
+	// Authentication is the time for exchanging the authenticating sessions, until the session is established.
+	Authentication time.Duration
+	// Total is the time of the whole establishment.
+	Total time.Duration
+	// Err is the failure of the establishment, if any. The durations of the phases after the failure are zero.
+	Err error
+}
+
+func (m EstablishmentMetrics) String() string {
+	s := fmt.Sprintf("transport=%v negotiation=%v tls=%v authentication=%v total=%v",
+		m.TransportOpen, m.Negotiation, m.TLSHandshake, m.Authentication, m.Total)
+	if m.Err != nil {
+		s += fmt.Sprintf(" err=%q", m.Err.Error())
+	}
+	return s
+}
+
+// EstablishmentMetrics returns the durations of the phases of the last session establishment of the channel. The
+// transport is opened before the channel creation, so its duration is not measured.
+func (c *ClientChannel) EstablishmentMetrics() EstablishmentMetrics {
+	return c.metrics
+}
@@ -0,0 +1,123 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// metricsRecorder records the establishment metrics reported by a client.
+type metricsRecorder struct {
+	mu      sync.Mutex
+	metrics []EstablishmentMetrics
+}
+
+func (r *metricsRecorder) record(m EstablishmentMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+func (r *metricsRecorder) recorded() []EstablishmentMetrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]EstablishmentMetrics{}, r.metrics...)
+}
+
+func startMetricsServer(t *testing.T, addr InProcessAddr, authDelay time.Duration) *Server {
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		CompressionOptions(SessionCompressionNone).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			time.Sleep(authDelay)
+			if password != "secret" {
+				return UnknownAuthenticationResult(), nil
+			}
+			return MemberAuthenticationResult(), nil
+		}).
+		Build()
+	startServer(t, srv)
+	return srv
+}
+
+func TestClient_OnEstablishment(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("client-establishment-metrics")
+	srv := startMetricsServer(t, addr, 20*time.Millisecond)
+	defer silentClose(srv)
+	r := &metricsRecorder{}
+
+	// Act
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		PlainAuthentication("secret").
+		OnEstablishment(r.record).
+		Build()
+	defer silentClose(client)
+	err := <-client.Ready()
+
+	// Assert
+	assert.NoError(t, err)
+	metrics := r.recorded()
+	if assert.Len(t, metrics, 1) {
+		m := metrics[0]
+		assert.NoError(t, m.Err)
+		assert.GreaterOrEqual(t, m.Authentication, 20*time.Millisecond)
+		assert.Less(t, m.Negotiation, m.Authentication)
+		assert.Zero(t, m.TLSHandshake)
+		assert.Equal(t, m.Total, m.TransportOpen+m.Negotiation+m.TLSHandshake+m.Authentication)
+	}
+}
+
+func TestClient_OnEstablishment_Failed(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("client-establishment-metrics-failed")
+	srv := startMetricsServer(t, addr, 0)
+	defer silentClose(srv)
+	r := &metricsRecorder{}
+
+	// Act
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		PlainAuthentication("wrong").
+		OnEstablishment(r.record).
+		Warmup().
+		Build()
+	defer silentClose(client)
+	err := <-client.Ready()
+
+	// Assert
+	assert.Error(t, err)
+	metrics := r.recorded()
+	if assert.NotEmpty(t, metrics) {
+		assert.Error(t, metrics[0].Err)
+		assert.NotZero(t, metrics[0].Authentication)
+	}
+}
+
+func TestClient_OnEstablishment_TransportFailed(t *testing.T) {
+	// Arrange
+	r := &metricsRecorder{}
+
+	// Act
+	client := NewClientBuilder().
+		UseInProcess("client-establishment-metrics-unknown", 1).
+		OnEstablishment(r.record).
+		Warmup().
+		Build()
+	defer silentClose(client)
+	err := <-client.Ready()
+
+	// Assert
+	assert.Error(t, err)
+	metrics := r.recorded()
+	if assert.NotEmpty(t, metrics) {
+		assert.Error(t, metrics[0].Err)
+		assert.Equal(t, metrics[0].TransportOpen, metrics[0].Total)
+		assert.Zero(t, metrics[0].Negotiation)
+	}
+}
@@ -56,7 +56,7 @@ func main() {
 	log.Println("Session established")
 
 	reqCmd := &lime.RequestCommand{}
-	reqCmd.SetURIString("/presence").
+	reqCmd.SetURI(lime.NewResourceURI(lime.PresencePath)).
 		SetResource(&chat.Presence{
 			Status:      chat.PresenceStatusAvailable,
 			RoutingRule: chat.RoutingRuleIdentity}).
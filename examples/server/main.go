@@ -30,7 +30,7 @@ func main() {
 		AutoReplyPings().
 		RequestCommandHandlerFunc(
 			func(cmd *lime.RequestCommand) bool {
-				return cmd.Method == lime.CommandMethodSet && cmd.URI.Path() == "/presence"
+				return cmd.Method == lime.CommandMethodSet && cmd.URI.Path() == lime.PresencePath
 			},
 			func(ctx context.Context, cmd *lime.RequestCommand, s lime.Sender) error {
 				return s.SendResponseCommand(
@@ -0,0 +1,98 @@
+package lime
+
+import (
+	"slices"
+	"strings"
+)
+
+// MetadataKeySessionFeatures is the session metadata key that holds the names of the features advertised by a party,
+// separated by commas. The client sends its features in the new session and the server replies its own in the
+// established session.
+const MetadataKeySessionFeatures = "#features"
+
+// Feature is a capability of the session that the modules can check before adapting their behavior, like skipping
+// the compression of the contents when the transport is already compressed.
+// The features are either negotiated for the session, like the transport compression, or advertised by the remote
+// party in the session metadata, like the application defined ones.
+type Feature string
+
+const (
+	// FeatureGzipCompression indicates that the envelopes are compressed with gzip by the transport.
+	FeatureGzipCompression = Feature("compression:gzip")
+	// FeatureTLSEncryption indicates that the envelopes are encrypted with TLS by the transport.
+	FeatureTLSEncryption = Feature("encryption:tls")
+	// FeatureContentHashes indicates that the remote party sends the content hash of the envelopes.
+	FeatureContentHashes = Feature("contentHashes")
+	// FeatureReplayProtection indicates that the remote party sends and verifies the envelope nonces.
+	FeatureReplayProtection = Feature("replayProtection")
+)
+
+// AdvertiseFeatures defines features of the channel to be advertised to the remote party in the session
+// establishment, in addition to the ones of the enabled options, like the content hashes.
+func (c *channel) AdvertiseFeatures(features ...Feature) {
+	if err := c.ensureState(SessionStateNew, "advertise features"); err != nil {
+		panic(err)
+	}
+	for _, f := range features {
+		if f == "" || strings.Contains(string(f), ",") {
+			panic("invalid feature name")
+		}
+	}
+	c.features = append(c.features, features...)
+}
+
+// Supports indicates if the feature was negotiated for the session or advertised by the remote party.
+func (c *channel) Supports(f Feature) bool {
+	switch f {
+	case FeatureGzipCompression:
+		return c.transport.Compression() == SessionCompressionGzip
+	case FeatureTLSEncryption:
+		return c.transport.Encryption() == SessionEncryptionTLS
+	}
+	return slices.Contains(c.remoteFeatures, f)
+}
+
+// Features returns the features negotiated for the session and advertised by the remote party, sorted by name.
+func (c *channel) Features() []Feature {
+	features := slices.Clone(c.remoteFeatures)
+	for _, f := range []Feature{FeatureGzipCompression, FeatureTLSEncryption} {
+		if c.Supports(f) && !slices.Contains(features, f) {
+			features = append(features, f)
+		}
+	}
+	slices.Sort(features)
+	return features
+}
+
+// advertiseFeatures adds the features of the channel to the session sent to the remote party.
+func (c *channel) advertiseFeatures(ses *Session) {
+	features := slices.Clone(c.features)
+	if c.contentHashes {
+		features = append(features, FeatureContentHashes)
+	}
+	if c.replay != nil {
+		features = append(features, FeatureReplayProtection)
+	}
+	if len(features) == 0 {
+		return
+	}
+	names := make([]string, len(features))
+	for i, f := range features {
+		names[i] = string(f)
+	}
+	ses.SetMetadataKeyValue(MetadataKeySessionFeatures, strings.Join(names, ","))
+}
+
+// receiveFeatures stores the features advertised by the remote party in the session.
+func (c *channel) receiveFeatures(ses *Session) {
+	c.remoteFeatures = nil
+	value := ses.Metadata[MetadataKeySessionFeatures]
+	if value == "" {
+		return
+	}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			c.remoteFeatures = append(c.remoteFeatures, Feature(name))
+		}
+	}
+}
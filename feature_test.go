@@ -0,0 +1,92 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// gzipTransport reports the gzip compression, like a transport that negotiated it.
+type gzipTransport struct {
+	Transport
+}
+
+func (t *gzipTransport) Compression() SessionCompression {
+	return SessionCompressionGzip
+}
+
+func TestChannel_EstablishSession_Features(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("features")
+	serverChannels := make(chan *ServerChannel, 1)
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		AdvertiseFeatures("presence").
+		Established(func(sessionID string, c *ServerChannel) {
+			serverChannels <- c
+		}).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	client, err := DialInProcess(addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	c.AdvertiseFeatures("content:gzip", "receipts")
+	c.EnableContentHashes()
+
+	// Act
+	ses, err := establishGuestSession(ctx, c)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStateEstablished, ses.State)
+	assert.True(t, c.Supports("presence"))
+	assert.False(t, c.Supports("receipts"))
+	assert.False(t, c.Supports(FeatureGzipCompression))
+	assert.Equal(t, []Feature{"presence"}, c.Features())
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case sc := <-serverChannels:
+		assert.True(t, sc.Supports("content:gzip"))
+		assert.True(t, sc.Supports(FeatureContentHashes))
+		assert.False(t, sc.Supports("presence"))
+		assert.Equal(t, []Feature{"content:gzip", FeatureContentHashes, "receipts"}, sc.Features())
+	}
+}
+
+func TestChannel_Supports_TransportCompression(t *testing.T) {
+	// Arrange
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := newChannel(&gzipTransport{Transport: client}, 1)
+	defer silentClose(c)
+
+	// Act
+	gzip := c.Supports(FeatureGzipCompression)
+	tls := c.Supports(FeatureTLSEncryption)
+
+	// Assert
+	assert.True(t, gzip)
+	assert.False(t, tls)
+	assert.Equal(t, []Feature{FeatureGzipCompression}, c.Features())
+}
+
+func TestChannel_AdvertiseFeatures_Invalid(t *testing.T) {
+	// Arrange
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+
+	// Act & Assert
+	assert.Panics(t, func() {
+		c.AdvertiseFeatures("a,b")
+	})
+}
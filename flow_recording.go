@@ -0,0 +1,236 @@
+package lime
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlowRecordingVersion is the version of the FlowRecording format.
+const FlowRecordingVersion = "1.0"
+
+// FlowRecording is the envelope flow of a session in a HAR-like interchange format, for the visualization tools and
+// the support teams. It is built from the records of the FileTracer, which can be exported with the
+// ExportFlowRecordings function.
+type FlowRecording struct {
+	// Version is the version of the format.
+	Version string `json:"version"`
+	// SessionID is the ID of the traced session.
+	SessionID string `json:"sessionId"`
+	// Started is the moment of the first traced envelope.
+	Started time.Time `json:"startedDateTime"`
+	// Entries are the traced envelopes, ordered by their timestamps.
+	Entries []FlowEntry `json:"entries"`
+}
+
+// FlowEntry is a traced envelope of a FlowRecording.
+type FlowEntry struct {
+	// Timestamp is the moment when the envelope was traced.
+	Timestamp time.Time `json:"startedDateTime"`
+	// Offset is the time since the start of the recording, in milliseconds.
+	Offset float64 `json:"offset"`
+	// Direction is the transport operation, which can be 'send' or 'receive'.
+	Direction string `json:"direction"`
+	// Type is the envelope type, which can be 'session', 'message', 'notification', 'command' or 'response', or
+	// empty if it is unknown.
+	Type string `json:"type,omitempty"`
+	// ID is the envelope ID.
+	ID string `json:"id,omitempty"`
+	// Size is the length of the envelope JSON, in bytes.
+	Size int `json:"size"`
+	// Latency is the time since the envelope that it replies, in milliseconds, which is the request command of a
+	// response or the message of a notification, in the opposite direction. It is nil for the other envelopes.
+	Latency *float64 `json:"latency,omitempty"`
+	// Envelope is the envelope JSON representation.
+	Envelope json.RawMessage `json:"envelope"`
+}
+
+// NewFlowRecording builds the recording of the session from its trace records, which are ordered by their timestamps
+// since the sent and received envelopes are traced independently.
+func NewFlowRecording(sessionID string, records []TraceRecord) *FlowRecording {
+	rec := &FlowRecording{Version: FlowRecordingVersion, SessionID: sessionID, Entries: make([]FlowEntry, 0, len(records))}
+	if len(records) == 0 {
+		return rec
+	}
+	records = append([]TraceRecord(nil), records...)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	rec.Started = records[0].Timestamp
+
+	// pending are the timestamps of the messages and request commands by direction and ID, for the latencies
+	pending := make(map[string]time.Time)
+	for _, r := range records {
+		e := FlowEntry{
+			Timestamp: r.Timestamp,
+			Offset:    milliseconds(r.Timestamp.Sub(rec.Started)),
+			Direction: r.Action,
+			Size:      len(r.Envelope),
+			Envelope:  r.Envelope,
+		}
+		var raw rawEnvelope
+		if err := json.Unmarshal(r.Envelope, &raw); err == nil {
+			e.Type = flowEntryType(&raw)
+			e.ID = raw.ID
+		}
+
+		if e.ID != "" {
+			switch e.Type {
+			case "message", "command":
+				pending[e.Direction+":"+e.ID] = e.Timestamp
+			case "notification", "response":
+				if sent, ok := pending[oppositeDirection(e.Direction)+":"+e.ID]; ok {
+					latency := milliseconds(e.Timestamp.Sub(sent))
+					e.Latency = &latency
+				}
+			}
+		}
+		rec.Entries = append(rec.Entries, e)
+	}
+	return rec
+}
+
+func flowEntryType(raw *rawEnvelope) string {
+	t, err := raw.envelopeType()
+	if err != nil {
+		return ""
+	}
+	switch t {
+	case "RequestCommand":
+		return "command"
+	case "ResponseCommand":
+		return "response"
+	default:
+		return strings.ToLower(t)
+	}
+}
+
+func oppositeDirection(direction string) string {
+	if direction == "send" {
+		return "receive"
+	}
+	return "send"
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ExportFlowRecordings converts the trace files written by a FileTracer in the trace directory to a FlowRecording
+// file for each session, named as '<sessionID>.flow.json', in the output directory. It returns the paths of the
+// written files.
+func ExportFlowRecordings(traceDir, outDir string) ([]string, error) {
+	sessions, err := traceFilesBySession(traceDir)
+	if err != nil {
+		return nil, fmt.Errorf("export flow recordings: %w", err)
+	}
+	if err = os.MkdirAll(outDir, 0o755); err != nil {
+		return nil, fmt.Errorf("export flow recordings: %w", err)
+	}
+
+	ids := make([]string, 0, len(sessions))
+	for id := range sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	paths := make([]string, 0, len(ids))
+	for _, id := range ids {
+		var records []TraceRecord
+		for _, file := range sessions[id] {
+			fileRecords, err := readTraceFile(file)
+			if err != nil {
+				return paths, fmt.Errorf("export flow recordings: %w", err)
+			}
+			records = append(records, fileRecords...)
+		}
+
+		path := filepath.Join(outDir, id+".flow.json")
+		if err = writeFlowRecording(path, NewFlowRecording(id, records)); err != nil {
+			return paths, fmt.Errorf("export flow recordings: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// traceFilesBySession returns the trace files of the directory by session, in the rotation order.
+func traceFilesBySession(dir string) (map[string][]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+	if err != nil {
+		return nil, err
+	}
+	type traceFile struct {
+		path string
+		seq  int
+	}
+	files := make(map[string][]traceFile)
+	for _, path := range matches {
+		name := strings.TrimSuffix(filepath.Base(path), ".jsonl.gz")
+		i := strings.LastIndex(name, "-")
+		if i <= 0 {
+			continue
+		}
+		seq, err := strconv.Atoi(name[i+1:])
+		if err != nil {
+			continue
+		}
+		files[name[:i]] = append(files[name[:i]], traceFile{path: path, seq: seq})
+	}
+
+	sessions := make(map[string][]string, len(files))
+	for id, f := range files {
+		sort.Slice(f, func(i, j int) bool { return f[i].seq < f[j].seq })
+		for _, tf := range f {
+			sessions[id] = append(sessions[id], tf.path)
+		}
+	}
+	return sessions, nil
+}
+
+func readTraceFile(path string) ([]TraceRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	defer gz.Close()
+
+	var records []TraceRecord
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(DefaultReadLimit))
+	for scanner.Scan() {
+		var r TraceRecord
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		records = append(records, r)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return records, nil
+}
+
+func writeFlowRecording(path string, rec *FlowRecording) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(rec); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
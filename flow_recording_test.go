@@ -0,0 +1,123 @@
+package lime
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func createTraceRecord(t *testing.T, timestamp time.Time, action string, e envelope) TraceRecord {
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return TraceRecord{Timestamp: timestamp, Action: action, Envelope: b}
+}
+
+func TestNewFlowRecording(t *testing.T) {
+	// Arrange
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ses := createSession()
+	cmd := createGetPingCommand()
+	msg := createMessage()
+	records := []TraceRecord{
+		createTraceRecord(t, start, "receive", ses),
+		createTraceRecord(t, start.Add(10*time.Millisecond), "send", cmd),
+		createTraceRecord(t, start.Add(25*time.Millisecond), "receive", createResponseCommand()),
+		createTraceRecord(t, start.Add(30*time.Millisecond), "receive", msg),
+		createTraceRecord(t, start.Add(32*time.Millisecond), "send", createNotification()),
+	}
+
+	// Act
+	rec := NewFlowRecording(ses.ID, records)
+
+	// Assert
+	assert.Equal(t, FlowRecordingVersion, rec.Version)
+	assert.Equal(t, ses.ID, rec.SessionID)
+	assert.Equal(t, start, rec.Started)
+	assert.Len(t, rec.Entries, 5)
+	var types []string
+	for _, e := range rec.Entries {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []string{"session", "command", "response", "message", "notification"}, types)
+	assert.Equal(t, "send", rec.Entries[1].Direction)
+	assert.Equal(t, cmd.ID, rec.Entries[1].ID)
+	assert.Equal(t, 10.0, rec.Entries[1].Offset)
+	assert.Equal(t, len(records[1].Envelope), rec.Entries[1].Size)
+	assert.Nil(t, rec.Entries[1].Latency)
+	if assert.NotNil(t, rec.Entries[2].Latency) {
+		assert.Equal(t, 15.0, *rec.Entries[2].Latency)
+	}
+	if assert.NotNil(t, rec.Entries[4].Latency) {
+		assert.Equal(t, 2.0, *rec.Entries[4].Latency)
+	}
+}
+
+func TestNewFlowRecording_SameDirection(t *testing.T) {
+	// Arrange
+	start := time.Now()
+	records := []TraceRecord{
+		createTraceRecord(t, start, "send", createMessage()),
+		createTraceRecord(t, start.Add(time.Millisecond), "send", createNotification()),
+	}
+
+	// Act
+	rec := NewFlowRecording("session", records)
+
+	// Assert
+	assert.Len(t, rec.Entries, 2)
+	assert.Nil(t, rec.Entries[1].Latency)
+}
+
+func TestExportFlowRecordings(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	traceDir := t.TempDir()
+	outDir := filepath.Join(t.TempDir(), "flows")
+	tracer, err := NewFileTracer(traceDir, 512)
+	assert.NoError(t, err)
+	tw := tracer.NewTraceWriter()
+	ses := createSession()
+	writeTraceEnvelopes(t, *tw.SendWriter(), &Session{State: SessionStateNew})
+	writeTraceEnvelopes(t, *tw.ReceiveWriter(), ses)
+	for i := 0; i < 10; i++ {
+		writeTraceEnvelopes(t, *tw.SendWriter(), createMessage())
+	}
+	if err = tw.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	paths, err := ExportFlowRecordings(traceDir, outDir)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(outDir, ses.ID+".flow.json")}, paths)
+	b, err := os.ReadFile(paths[0])
+	assert.NoError(t, err)
+	var rec FlowRecording
+	assert.NoError(t, json.Unmarshal(b, &rec))
+	assert.Equal(t, ses.ID, rec.SessionID)
+	assert.Len(t, rec.Entries, 12)
+	assert.Equal(t, "session", rec.Entries[0].Type)
+	assert.Equal(t, "message", rec.Entries[11].Type)
+}
+
+func TestExportFlowRecordings_Empty(t *testing.T) {
+	// Arrange
+	traceDir := t.TempDir()
+	outDir := t.TempDir()
+
+	// Act
+	paths, err := ExportFlowRecordings(traceDir, outDir)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, paths)
+}
@@ -0,0 +1,73 @@
+package lime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"strings"
+)
+
+// PprofLabelPrefix is the prefix of the pprof labels of the lime goroutines, which are shown in the goroutine
+// profiles, like 'lime.session'.
+const PprofLabelPrefix = "lime."
+
+// goLabeled runs the function in a new goroutine with the lime pprof labels, which identify it in the goroutine
+// profiles and allow the detection of leaks. The name is the 'lime.goroutine' label, and the labels are additional
+// key/value pairs, which are prefixed with PprofLabelPrefix. The labels of the context are kept.
+func goLabeled(ctx context.Context, name string, f func(ctx context.Context), labels ...string) {
+	kv := make([]string, 0, len(labels)+2)
+	kv = append(kv, PprofLabelPrefix+"goroutine", name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		kv = append(kv, PprofLabelPrefix+labels[i], labels[i+1])
+	}
+	go pprof.Do(ctx, pprof.Labels(kv...), f)
+}
+
+// WriteGoroutines writes the stacks of the running goroutines with the lime labels, in the format of the goroutine
+// profile with debug=1. It includes the internal goroutines, like the channel receivers, and the ones started by
+// them, like the envelope handlers. It returns the number of goroutines written.
+func WriteGoroutines(w io.Writer) (int, error) {
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		return 0, err
+	}
+
+	// The groups of goroutines with the same stack are separated by blank lines, starting with the count, like
+	// '3 @ 0x43e2ce ...', and have a '# labels: {...}' line
+	scanner := bufio.NewScanner(&profile)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	var group []string
+	total := 0
+	flush := func() error {
+		defer func() { group = group[:0] }()
+		for _, line := range group {
+			if !strings.HasPrefix(line, "# labels:") || !strings.Contains(line, `"`+PprofLabelPrefix) {
+				continue
+			}
+			var count int
+			if _, err := fmt.Sscanf(group[0], "%d @", &count); err == nil {
+				total += count
+			}
+			_, err := fmt.Fprintln(w, strings.Join(group, "\n")+"\n")
+			return err
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := flush(); err != nil {
+				return total, err
+			}
+			continue
+		}
+		group = append(group, line)
+	}
+	if err := flush(); err != nil {
+		return total, err
+	}
+	return total, scanner.Err()
+}
@@ -0,0 +1,55 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWriteGoroutines(t *testing.T) {
+	// Arrange
+	started := make(chan struct{})
+	stop := make(chan struct{})
+	goLabeled(context.Background(), "test", func(context.Context) {
+		close(started)
+		<-stop
+	}, "session", "5ba9a3b4")
+	<-started
+	defer close(stop)
+	var buf bytes.Buffer
+
+	// Act
+	n, err := WriteGoroutines(&buf)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotZero(t, n)
+	assert.Contains(t, buf.String(), `"lime.goroutine":"test"`)
+	assert.Contains(t, buf.String(), `"lime.session":"5ba9a3b4"`)
+	assert.Contains(t, buf.String(), "TestWriteGoroutines")
+	assert.NotContains(t, buf.String(), "testing.tRunner")
+}
+
+func TestChannel_ReceiverLabels(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	defer silentClose(server)
+	c := newChannel(client, 1)
+	c.sessionID = "7c9f2a1e"
+	c.client = true
+	var buf bytes.Buffer
+
+	// Act
+	c.setState(SessionStateEstablished)
+	defer silentClose(c)
+	_ = server.Send(context.Background(), createMessage())
+	<-c.MsgChan()
+	_, err := WriteGoroutines(&buf)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `"lime.goroutine":"channel.receiver"`)
+	assert.Contains(t, buf.String(), `"lime.session":"7c9f2a1e"`)
+	assert.Contains(t, buf.String(), `"lime.role":"client"`)
+}
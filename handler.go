@@ -4,13 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 )
 
 type EnvelopeMux struct {
-	msgHandlers     []MessageHandler
-	notHandlers     []NotificationHandler
-	reqCmdHandlers  []RequestCommandHandler
-	respCmdHandlers []ResponseCommandHandler
+	msgHandlers       []MessageHandler
+	notHandlers       []NotificationHandler
+	reqCmdHandlers    []RequestCommandHandler
+	respCmdHandlers   []ResponseCommandHandler
+	reqCmdMiddlewares []uriMiddleware
+	dispatcher        Dispatcher
+	delegations       DelegationChecker
+	contentHashes     bool
+	store             MessageStore       // store records the received messages and notifications
+	meter             *TenantMeter       // meter counts the received envelopes by tenant and enforces their quotas
+	errorPolicy       HandlerErrorPolicy // errorPolicy decides the action for the handler failures
+	filters           []*Policy          // filters are the policies that the received envelopes must match
 }
 
 func (m *EnvelopeMux) ListenServer(ctx context.Context, c *ServerChannel) error {
@@ -32,45 +43,191 @@ func (m *EnvelopeMux) listen(ctx context.Context, c *channel) error {
 		return err
 	}
 
-	for c.Established() && ctx.Err() == nil {
-		ctx := sessionContext(ctx, c)
+	m.startMetering(c)
+
+	// The awaited replies are submitted after the verifications of the received messages
+	c.replies.listened.Store(true)
+	defer c.replies.listened.Store(false)
+
+	d := newListenDispatch(ctx, m.dispatcher)
+	defer d.wait()
+
+	for c.Established() && d.ctx.Err() == nil {
+		ctx := sessionContext(d.ctx, c)
 
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return d.err(ctx.Err())
 		case <-c.RcvDone():
-			return nil
+			return d.err(nil)
 		case msg, ok := <-c.MsgChan():
 			if !ok {
 				return errors.New("msg chan: channel closed")
 			}
-			if err := m.handleMessage(ctx, msg, c); err != nil {
+			if err := d.run(ctx, c, &msg.Envelope, func(ctx context.Context) error {
+				reason := m.verifySender(ctx, c, &msg.Envelope)
+				if reason == nil {
+					reason = m.filterEnvelope(ctx, c, msg)
+				}
+				if reason == nil {
+					reason = m.verifyContentHash(&msg.Envelope, msg.Content)
+				}
+				if reason == nil {
+					reason = m.meterUsage(ctx, c, msg)
+				}
+				if reason != nil {
+					discardStreamed(msg.Content)
+					if msg.ID == "" {
+						return nil
+					}
+					return c.SendNotification(ctx, msg.FailedNotification(reason))
+				}
+				storeMessage(ctx, m.store, TapDirectionReceived, msg)
+				if c.replies.trySubmit(msg, c.remoteNode) {
+					return nil
+				}
+				return m.guard(ctx, c, msg, func() error {
+					return m.handleMessage(ctx, msg, c)
+				})
+			}); err != nil {
 				return err
 			}
 		case not, ok := <-c.NotChan():
 			if !ok {
 				return errors.New("not chan: channel closed")
 			}
-			if err := m.handleNotification(ctx, not); err != nil {
+			if err := d.run(ctx, c, &not.Envelope, func(ctx context.Context) error {
+				if reason := m.verifySender(ctx, c, &not.Envelope); reason != nil {
+					return nil
+				}
+				if reason := m.filterEnvelope(ctx, c, not); reason != nil {
+					return nil
+				}
+				_ = m.meterUsage(ctx, c, not)
+				storeNotification(ctx, m.store, TapDirectionReceived, not)
+				return m.guard(ctx, c, not, func() error {
+					return m.handleNotification(ctx, not)
+				})
+			}); err != nil {
 				return err
 			}
 		case reqCmd, ok := <-c.ReqCmdChan():
 			if !ok {
 				return errors.New("req cmd chan: channel closed")
 			}
-			if err := m.handleRequestCommand(ctx, reqCmd, c); err != nil {
+			if err := d.run(ctx, c, &reqCmd.Envelope, func(ctx context.Context) error {
+				reason := m.verifySender(ctx, c, &reqCmd.Envelope)
+				if reason == nil {
+					reason = m.filterEnvelope(ctx, c, reqCmd)
+				}
+				if reason == nil {
+					reason = m.verifyContentHash(&reqCmd.Envelope, reqCmd.Resource)
+				}
+				if reason == nil {
+					reason = m.meterUsage(ctx, c, reqCmd)
+				}
+				if reason != nil {
+					discardStreamed(reqCmd.Resource)
+					return c.SendResponseCommand(ctx, reqCmd.FailureResponse(reason))
+				}
+				return m.guard(ctx, c, reqCmd, func() error {
+					return m.handleRequestCommand(ctx, reqCmd, c)
+				})
+			}); err != nil {
 				return err
 			}
 		case respCmd, ok := <-c.RespCmdChan():
 			if !ok {
 				return errors.New("resp cmd chan: channel closed")
 			}
-			if err := m.handleResponseCommand(ctx, respCmd, c); err != nil {
+			if err := d.run(ctx, c, &respCmd.Envelope, func(ctx context.Context) error {
+				reason := m.verifySender(ctx, c, &respCmd.Envelope)
+				if reason == nil {
+					reason = m.filterEnvelope(ctx, c, respCmd)
+				}
+				if reason == nil {
+					reason = m.verifyContentHash(&respCmd.Envelope, respCmd.Resource)
+				}
+				if reason == nil {
+					reason = m.meterUsage(ctx, c, respCmd)
+				}
+				if reason != nil {
+					discardStreamed(respCmd.Resource)
+					return nil
+				}
+				return m.guard(ctx, c, respCmd, func() error {
+					return m.handleResponseCommand(ctx, respCmd, c)
+				})
+			}); err != nil {
 				return err
 			}
 		}
 	}
-	return ctx.Err()
+	return d.err(ctx.Err())
+}
+
+// listenDispatch executes the envelope handlers of a listener, directly or through a Dispatcher.
+type listenDispatch struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	dispatcher Dispatcher
+	wg         sync.WaitGroup
+	errOnce    sync.Once
+	handlerErr error
+}
+
+func newListenDispatch(ctx context.Context, dispatcher Dispatcher) *listenDispatch {
+	d := &listenDispatch{dispatcher: dispatcher}
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	return d
+}
+
+// run executes the handler function, or dispatches it keyed by the envelope sender identity.
+// The errors of the dispatched handlers stop the listener.
+func (d *listenDispatch) run(ctx context.Context, c *channel, env *Envelope, f func(ctx context.Context) error) error {
+	ctx = receivedContext(ctx, env)
+	if d.dispatcher == nil {
+		return f(ctx)
+	}
+
+	d.wg.Add(1)
+	err := d.dispatcher.Dispatch(ctx, dispatchKey(env, c), func() {
+		defer d.wg.Done()
+		if err := f(ctx); err != nil {
+			d.errOnce.Do(func() {
+				d.handlerErr = err
+				d.cancel()
+			})
+		}
+	})
+	if err != nil {
+		d.wg.Done()
+		return fmt.Errorf("dispatch: %w", err)
+	}
+	return nil
+}
+
+// err returns the error of a dispatched handler, if any, or the specified error.
+func (d *listenDispatch) err(err error) error {
+	d.wait()
+	if d.handlerErr != nil {
+		return d.handlerErr
+	}
+	return err
+}
+
+// wait awaits for the dispatched handlers to complete.
+func (d *listenDispatch) wait() {
+	d.wg.Wait()
+	d.cancel()
+}
+
+// dispatchKey returns the identity of the envelope sender, which is the remote node if not defined.
+func dispatchKey(env *Envelope, c *channel) string {
+	if env.From.Identity != (Identity{}) {
+		return env.From.Identity.String()
+	}
+	return c.remoteNode.Identity.String()
 }
 
 func (m *EnvelopeMux) handleMessage(ctx context.Context, msg *Message, s Sender) error {
@@ -81,8 +238,9 @@ func (m *EnvelopeMux) handleMessage(ctx context.Context, msg *Message, s Sender)
 		if err := h.Handle(ctx, msg, s); err != nil {
 			return fmt.Errorf("handle message: %w", err)
 		}
-		break
+		return nil
 	}
+	discardStreamed(msg.Content)
 	return nil
 }
 
@@ -100,6 +258,24 @@ func (m *EnvelopeMux) handleNotification(ctx context.Context, not *Notification)
 }
 
 func (m *EnvelopeMux) handleRequestCommand(ctx context.Context, cmd *RequestCommand, s Sender) error {
+	var handler RequestCommandHandlerFunc = m.dispatchRequestCommand
+
+	// Apply the middlewares in the reverse order, so the first registered is the outermost
+	var path string
+	if cmd.URI != nil {
+		path = cmd.URI.Path()
+	}
+	for i := len(m.reqCmdMiddlewares) - 1; i >= 0; i-- {
+		mw := m.reqCmdMiddlewares[i]
+		if mw.match(path) {
+			handler = mw.middleware(handler)
+		}
+	}
+
+	return handler(ctx, cmd, s)
+}
+
+func (m *EnvelopeMux) dispatchRequestCommand(ctx context.Context, cmd *RequestCommand, s Sender) error {
 	for _, h := range m.reqCmdHandlers {
 		if !h.Match(cmd) {
 			continue
@@ -107,8 +283,9 @@ func (m *EnvelopeMux) handleRequestCommand(ctx context.Context, cmd *RequestComm
 		if err := h.Handle(ctx, cmd, s); err != nil {
 			return fmt.Errorf("handle command: %w", err)
 		}
-		break
+		return nil
 	}
+	discardStreamed(cmd.Resource)
 	return nil
 }
 
@@ -120,11 +297,31 @@ func (m *EnvelopeMux) handleResponseCommand(ctx context.Context, cmd *ResponseCo
 		if err := h.Handle(ctx, cmd, s); err != nil {
 			return fmt.Errorf("handle command: %w", err)
 		}
-		break
+		return nil
 	}
+	discardStreamed(cmd.Resource)
 	return nil
 }
 
+// clone returns a copy of the mux with its own handler lists, for adding internal handlers without changing the mux
+// of the caller, which can be shared.
+func (m *EnvelopeMux) clone() *EnvelopeMux {
+	c := *m
+	c.msgHandlers = slices.Clone(m.msgHandlers)
+	c.notHandlers = slices.Clone(m.notHandlers)
+	c.reqCmdHandlers = slices.Clone(m.reqCmdHandlers)
+	c.respCmdHandlers = slices.Clone(m.respCmdHandlers)
+	c.reqCmdMiddlewares = slices.Clone(m.reqCmdMiddlewares)
+	c.filters = slices.Clone(m.filters)
+	return &c
+}
+
+// UseDispatcher defines a Dispatcher for executing the handlers of the received envelopes, instead of executing them
+// sequentially in the listener goroutine. The envelopes of each sender identity are still handled in order.
+func (m *EnvelopeMux) UseDispatcher(d Dispatcher) {
+	m.dispatcher = d
+}
+
 // MessageHandlerFunc allows the definition of a function for handling received messages that matches
 // the specified predicate. Note that the registration order matters, since the receiving process stops when
 // the first predicate match occurs.
@@ -161,6 +358,21 @@ func (m *EnvelopeMux) RequestCommandHandler(handler RequestCommandHandler) {
 	m.reqCmdHandlers = append(m.reqCmdHandlers, handler)
 }
 
+// RequestCommandMiddleware registers middlewares for the received commands with a URI path under the specified prefix.
+// The prefix matches entire path segments, so '/contacts' matches '/contacts' and '/contacts/john@domain.com', but
+// not '/contactsgroup'. The middlewares are executed in the registration order, before the command handlers.
+func (m *EnvelopeMux) RequestCommandMiddleware(prefix string, middlewares ...RequestCommandMiddleware) {
+	for _, mw := range middlewares {
+		if mw == nil {
+			panic("nil middleware")
+		}
+		m.reqCmdMiddlewares = append(m.reqCmdMiddlewares, uriMiddleware{
+			prefix:     prefix,
+			middleware: mw,
+		})
+	}
+}
+
 func (m *EnvelopeMux) ResponseCommandHandlerFunc(predicate ResponseCommandPredicate, f ResponseCommandHandlerFunc) {
 	m.ResponseCommandHandler(&responseCommandHandler{
 		predicate:   predicate,
@@ -185,6 +397,14 @@ type MessageHandler interface {
 // MessagePredicate defines an expression for checking if the specified Message satisfies a condition.
 type MessagePredicate func(msg *Message) bool
 
+// MessageTypeMatches returns a MessagePredicate that is satisfied by the messages with a media type matched by the
+// specified one, which can contain wildcards like 'image/*'.
+func MessageTypeMatches(t MediaType) MessagePredicate {
+	return func(msg *Message) bool {
+		return t.Matches(msg.Type)
+	}
+}
+
 // MessageHandlerFunc defines an action to be executed to a Message.
 type MessageHandlerFunc func(ctx context.Context, msg *Message, s Sender) error
 
@@ -268,6 +488,27 @@ func (h *requestCommandHandler) Handle(ctx context.Context, cmd *RequestCommand,
 	return h.handlerFunc(ctx, cmd, s)
 }
 
+// RequestCommandMiddleware defines a function that wraps a RequestCommandHandlerFunc, allowing the execution of
+// actions before and after the next handler, like authorization checks and logging.
+// The middleware can inject values in the context passed to the next handler or skip it by not calling it.
+type RequestCommandMiddleware func(next RequestCommandHandlerFunc) RequestCommandHandlerFunc
+
+type uriMiddleware struct {
+	prefix     string
+	middleware RequestCommandMiddleware
+}
+
+func (m *uriMiddleware) match(path string) bool {
+	prefix := strings.TrimSuffix(m.prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
 // ResponseCommandHandler defines a handler for processing Command instances received from a channel.
 type ResponseCommandHandler interface {
 	// Match indicates if the specified ResponseCommand should be handled by the instance.
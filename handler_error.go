@@ -0,0 +1,133 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// HandlerErrorAction is the action taken by the listener when an envelope handler fails.
+type HandlerErrorAction int
+
+const (
+	// HandlerErrorFinishSession stops the listener, which finishes the session. This is the action for the handler
+	// errors when no policy is defined.
+	HandlerErrorFinishSession HandlerErrorAction = iota
+	// HandlerErrorNotify replies the failed message with a failed notification and the failed request command with a
+	// failure response, and keeps listening. The envelopes without id are dropped.
+	HandlerErrorNotify
+	// HandlerErrorDrop logs the error and keeps listening.
+	HandlerErrorDrop
+)
+
+// HandlerError is the failure of an envelope handler, which can be an error or a panic.
+type HandlerError struct {
+	// Envelope is the handled envelope, which can be a *Message, *Notification, *RequestCommand or *ResponseCommand
+	// value.
+	Envelope envelope
+	// Err is the error returned by the handler, or a *PanicError.
+	Err error
+}
+
+func (e *HandlerError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is a panic recovered from an envelope handler.
+type PanicError struct {
+	Value any    // Value is the value passed to panic.
+	Stack []byte // Stack is the stack trace of the goroutine that panicked.
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panic: %v", e.Value)
+}
+
+// HandlerErrorPolicy decides the action for a handler failure, allowing it to be logged or reported first.
+type HandlerErrorPolicy func(ctx context.Context, err *HandlerError) HandlerErrorAction
+
+// FinishSessionOnHandlerErrors is a HandlerErrorPolicy that finishes the session.
+func FinishSessionOnHandlerErrors(context.Context, *HandlerError) HandlerErrorAction {
+	return HandlerErrorFinishSession
+}
+
+// NotifyHandlerErrors is a HandlerErrorPolicy that replies the failed envelopes.
+func NotifyHandlerErrors(context.Context, *HandlerError) HandlerErrorAction {
+	return HandlerErrorNotify
+}
+
+// DropHandlerErrors is a HandlerErrorPolicy that logs and drops the failed envelopes.
+func DropHandlerErrors(context.Context, *HandlerError) HandlerErrorAction {
+	return HandlerErrorDrop
+}
+
+// OnHandlerError defines the policy for the errors returned by the handlers, which also recovers their panics. If
+// not defined, the handler errors finish the session and the panics are not recovered.
+func (m *EnvelopeMux) OnHandlerError(policy HandlerErrorPolicy) {
+	m.errorPolicy = policy
+}
+
+// guard executes the handler function, applying the error policy to its failures.
+func (m *EnvelopeMux) guard(ctx context.Context, c *channel, e envelope, f func() error) error {
+	if m.errorPolicy == nil {
+		return f()
+	}
+
+	err := recoverHandler(f)
+	if err == nil {
+		return nil
+	}
+	herr := &HandlerError{Envelope: e, Err: err}
+	switch m.errorPolicy(ctx, herr) {
+	case HandlerErrorNotify:
+		log.Printf("handler error: %v", err)
+		return notifyHandlerError(ctx, c, e, err)
+	case HandlerErrorDrop:
+		log.Printf("handler error: %v", err)
+		return nil
+	default:
+		return herr
+	}
+}
+
+func recoverHandler(f func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
+// notifyHandlerError replies the failed envelope with the reason of the error, if it is a *Reason, or a generic one.
+func notifyHandlerError(ctx context.Context, c *channel, e envelope, err error) error {
+	var reason *Reason
+	errors.As(err, &reason)
+
+	switch e := e.(type) {
+	case *Message:
+		if e.ID == "" {
+			return nil
+		}
+		if reason == nil {
+			reason = NewReason(ReasonCodeMessageProcessingError, "The message processing failed")
+		}
+		return c.SendNotification(ctx, e.FailedNotification(reason))
+	case *RequestCommand:
+		if e.ID == "" {
+			return nil
+		}
+		if reason == nil {
+			reason = NewReason(ReasonCodeCommandProcessingError, "The command processing failed")
+		}
+		return c.SendResponseCommand(ctx, e.FailureResponse(reason))
+	default:
+		return nil
+	}
+}
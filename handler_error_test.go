@@ -0,0 +1,182 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEnvelopeMux_OnHandlerError_Notify(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	mux.OnHandlerError(NotifyHandlerErrors)
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			return errors.New("database unavailable")
+		})
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+
+	// Act
+	_ = client.Send(ctx, createMessage())
+	actual1, err1 := client.Receive(ctx)
+	_ = client.Send(ctx, createMessage())
+	actual2, err2 := client.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	for _, actual := range []envelope{actual1, actual2} {
+		if assert.IsType(t, &Notification{}, actual) {
+			not := actual.(*Notification)
+			assert.Equal(t, NotificationEventFailed, not.Event)
+			assert.Equal(t, createMessage().ID, not.ID)
+			assert.Equal(t, ReasonCodeMessageProcessingError, not.Reason.Code)
+		}
+	}
+}
+
+func TestEnvelopeMux_OnHandlerError_NotifyReason(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	mux.OnHandlerError(NotifyHandlerErrors)
+	reason := &Reason{Code: ReasonCodeCommandResourceNotFound, Description: "The resource was not found"}
+	mux.RequestCommandHandlerFunc(
+		func(cmd *RequestCommand) bool { return true },
+		func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+			return reason
+		})
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+	cmd := createGetPingCommand()
+
+	// Act
+	_ = client.Send(ctx, cmd)
+	actual, err := client.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.IsType(t, &ResponseCommand{}, actual) {
+		resp := actual.(*ResponseCommand)
+		assert.Equal(t, cmd.ID, resp.ID)
+		assert.Equal(t, CommandStatusFailure, resp.Status)
+		assert.Equal(t, reason, resp.Reason)
+	}
+}
+
+func TestEnvelopeMux_OnHandlerError_Panic(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	failures := make(chan *HandlerError, 2)
+	mux := &EnvelopeMux{}
+	mux.OnHandlerError(func(ctx context.Context, err *HandlerError) HandlerErrorAction {
+		failures <- err
+		return HandlerErrorDrop
+	})
+	mux.NotificationHandlerFunc(
+		func(not *Notification) bool { return true },
+		func(ctx context.Context, not *Notification) error {
+			panic("nil map")
+		})
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+	not := createNotification()
+
+	// Act
+	_ = client.Send(ctx, not)
+	_ = client.Send(ctx, not)
+
+	// Assert
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		case err := <-failures:
+			var panicErr *PanicError
+			if assert.ErrorAs(t, err, &panicErr) {
+				assert.Equal(t, "nil map", panicErr.Value)
+				assert.NotEmpty(t, panicErr.Stack)
+			}
+			assert.Equal(t, not.ID, err.Envelope.(*Notification).ID)
+		}
+	}
+	assert.True(t, c.Established())
+}
+
+func TestEnvelopeMux_OnHandlerError_FinishSession(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	handlerErr := errors.New("database unavailable")
+	mux := &EnvelopeMux{}
+	mux.OnHandlerError(FinishSessionOnHandlerErrors)
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			return handlerErr
+		})
+	_ = client.Send(ctx, createMessage())
+
+	// Act
+	err := mux.listen(ctx, c)
+
+	// Assert
+	var herr *HandlerError
+	if assert.ErrorAs(t, err, &herr) {
+		assert.ErrorIs(t, err, handlerErr)
+		assert.IsType(t, &Message{}, herr.Envelope)
+	}
+}
+
+func TestEnvelopeMux_WithoutHandlerErrorPolicy(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	handlerErr := errors.New("database unavailable")
+	mux := &EnvelopeMux{}
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			return handlerErr
+		})
+	_ = client.Send(ctx, createMessage())
+
+	// Act
+	err := mux.listen(ctx, c)
+
+	// Assert
+	assert.ErrorIs(t, err, handlerErr)
+	var herr *HandlerError
+	assert.False(t, errors.As(err, &herr))
+}
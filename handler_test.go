@@ -0,0 +1,211 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+type middlewareKey struct{}
+
+func TestEnvelopeMux_RequestCommandMiddleware(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	mux := &EnvelopeMux{}
+	var calls []string
+	track := func(name string) RequestCommandMiddleware {
+		return func(next RequestCommandHandlerFunc) RequestCommandHandlerFunc {
+			return func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+				calls = append(calls, name)
+				return next(context.WithValue(ctx, middlewareKey{}, name), cmd, s)
+			}
+		}
+	}
+	mux.RequestCommandMiddleware("/", track("root"))
+	mux.RequestCommandMiddleware("/contacts", track("contacts"))
+	mux.RequestCommandMiddleware("/ping", track("ping"))
+	var actualValue any
+	mux.RequestCommandHandlerFunc(
+		func(cmd *RequestCommand) bool { return true },
+		func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+			actualValue = ctx.Value(middlewareKey{})
+			return nil
+		})
+	cmd := createGetPingCommand()
+
+	// Act
+	err := mux.handleRequestCommand(ctx, cmd, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"root", "ping"}, calls)
+	assert.Equal(t, "ping", actualValue)
+}
+
+func TestEnvelopeMux_RequestCommandMiddleware_ShortCircuit(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	mux := &EnvelopeMux{}
+	errDenied := errors.New("denied")
+	mux.RequestCommandMiddleware("/ping", func(next RequestCommandHandlerFunc) RequestCommandHandlerFunc {
+		return func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+			return errDenied
+		}
+	})
+	handled := false
+	mux.RequestCommandHandlerFunc(
+		func(cmd *RequestCommand) bool { return true },
+		func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+			handled = true
+			return nil
+		})
+	cmd := createGetPingCommand()
+
+	// Act
+	err := mux.handleRequestCommand(ctx, cmd, nil)
+
+	// Assert
+	assert.ErrorIs(t, err, errDenied)
+	assert.False(t, handled)
+}
+
+func TestUriMiddleware_Match(t *testing.T) {
+	tests := []struct {
+		prefix string
+		path   string
+		want   bool
+	}{
+		{"/", "/ping", true},
+		{"", "/ping", true},
+		{"/contacts", "/contacts", true},
+		{"/contacts", "/contacts/john@domain.com", true},
+		{"/contacts/", "/contacts/john@domain.com", true},
+		{"/contacts", "/contactsgroup", false},
+		{"/contacts", "/ping", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix+" "+tt.path, func(t *testing.T) {
+			m := uriMiddleware{prefix: tt.prefix}
+			assert.Equal(t, tt.want, m.match(tt.path))
+		})
+	}
+}
+
+func TestMessageTypeMatches(t *testing.T) {
+	// Arrange
+	pattern, _ := ParseMediaType("text/*")
+	predicate := MessageTypeMatches(pattern)
+	msg := createMessage()
+	jsonMsg := createMessage()
+	jsonMsg.Type = MediaTypeApplicationJson()
+
+	// Act & Assert
+	assert.True(t, predicate(msg))
+	assert.False(t, predicate(jsonMsg))
+}
+
+func TestEnvelopeMux_ListenServer_ConversationContext(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	actual := make(chan string, 1)
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			id, _ := ContextConversationID(ctx)
+			actual <- id
+			return nil
+		})
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+	msg := createMessage()
+	msg.SetConversationID("conversation-1")
+
+	// Act
+	err := client.Send(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case id := <-actual:
+		assert.Equal(t, "conversation-1", id)
+	case <-ctx.Done():
+		assert.Fail(t, "message not handled")
+	}
+	cancel()
+}
+
+func TestEnvelopeMux_ListenServer_CultureContext(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			reply := createMessage()
+			return s.SendMessage(ctx, reply)
+		})
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+	msg := createMessage()
+	msg.SetCulture("pt-BR")
+
+	// Act
+	err := client.Send(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	reply, err := client.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "pt-BR", reply.(*Message).Culture())
+	cancel()
+}
+
+func TestEnvelopeMux_ListenServer_Dispatcher(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	p := NewWorkerPool(2, 2)
+	defer silentClose(p)
+	mux := &EnvelopeMux{}
+	mux.UseDispatcher(p)
+	errHandler := errors.New("handler error")
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			return errHandler
+		})
+	msg := createMessage()
+	msg.SetFromString("john@localhost")
+	if err := client.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	err := mux.listen(ctx, c)
+
+	// Assert
+	assert.ErrorIs(t, err, errHandler)
+}
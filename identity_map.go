@@ -0,0 +1,157 @@
+package lime
+
+import (
+	"sync"
+)
+
+// IdentityMap rewrites the identities of the envelopes between the internal and the external namespaces, like the
+// internal service identities and the public bot identities, in the way of a NAT. The From, To and PP identities of
+// the received envelopes are mapped to the internal ones, and the ones of the sent envelopes to the external ones.
+// The node instances are kept. The session envelopes are not rewritten.
+// The From and PP identities of the received envelopes are only mapped when they are the identity of the remote node
+// of the session, so a remote party cannot take an internal identity by claiming its external one.
+type IdentityMap struct {
+	mu         sync.RWMutex
+	toInternal map[Identity]Identity
+	toExternal map[Identity]Identity
+}
+
+// NewIdentityMap creates an empty IdentityMap.
+func NewIdentityMap() *IdentityMap {
+	return &IdentityMap{
+		toInternal: make(map[Identity]Identity),
+		toExternal: make(map[Identity]Identity),
+	}
+}
+
+// Add maps the internal identity to the external one, replacing the previous mappings of both.
+func (m *IdentityMap) Add(internal, external Identity) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if previous, ok := m.toExternal[internal]; ok {
+		delete(m.toInternal, previous)
+	}
+	if previous, ok := m.toInternal[external]; ok {
+		delete(m.toExternal, previous)
+	}
+	m.toExternal[internal] = external
+	m.toInternal[external] = internal
+}
+
+// Remove deletes the mapping of the internal identity.
+func (m *IdentityMap) Remove(internal Identity) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if external, ok := m.toExternal[internal]; ok {
+		delete(m.toInternal, external)
+		delete(m.toExternal, internal)
+	}
+}
+
+// Internal returns the internal identity mapped to the external one.
+func (m *IdentityMap) Internal(external Identity) (Identity, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	internal, ok := m.toInternal[external]
+	return internal, ok
+}
+
+// External returns the external identity mapped to the internal one.
+func (m *IdentityMap) External(internal Identity) (Identity, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	external, ok := m.toExternal[internal]
+	return external, ok
+}
+
+// rewrite returns a copy of the envelope with the identities mapped, or the same envelope if none is mapped.
+// The From and PP identities are only mapped if accepted by the sender function.
+func (m *IdentityMap) rewrite(e envelope, mapping map[Identity]Identity, sender func(Identity) bool) envelope {
+	if _, ok := e.(*Session); ok {
+		return e
+	}
+	env := envelopeOf(e)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	from, fromOK := mapping[env.From.Identity]
+	fromOK = fromOK && sender(env.From.Identity)
+	to, toOK := mapping[env.To.Identity]
+	pp, ppOK := mapping[env.PP.Identity]
+	ppOK = ppOK && sender(env.PP.Identity)
+	if !fromOK && !toOK && !ppOK {
+		return e
+	}
+
+	rewritten := copyEnvelope(e)
+	env = envelopeOf(rewritten)
+	if fromOK {
+		env.From.Identity = from
+	}
+	if toOK {
+		env.To.Identity = to
+	}
+	if ppOK {
+		env.PP.Identity = pp
+	}
+	return rewritten
+}
+
+// copyEnvelope returns a shallow copy of the message, notification or command.
+func copyEnvelope(e envelope) envelope {
+	switch e := e.(type) {
+	case *Message:
+		c := *e
+		return &c
+	case *Notification:
+		c := *e
+		return &c
+	case *RequestCommand:
+		c := *e
+		return &c
+	case *ResponseCommand:
+		c := *e
+		return &c
+	default:
+		return e
+	}
+}
+
+// SetIdentityMap defines the mapping of the identities of the envelopes exchanged in the session.
+func (c *channel) SetIdentityMap(m *IdentityMap) {
+	if err := c.ensureState(SessionStateNew, "set identity map"); err != nil {
+		panic(err)
+	}
+	c.identityMap = m
+}
+
+// mapReceived returns the received envelope with the internal identities, if there is a map. The sender identities
+// are verified against the remote node before being mapped, since the mux verifies them after.
+func (c *channel) mapReceived(e envelope) envelope {
+	if c.identityMap == nil {
+		return e
+	}
+	return c.identityMap.rewrite(e, c.identityMap.toInternal, func(id Identity) bool {
+		return id == c.remoteNode.Identity
+	})
+}
+
+// mapSent returns the envelope to be sent with the external identities, if there is a map.
+func (c *channel) mapSent(e envelope) envelope {
+	if c.identityMap == nil {
+		return e
+	}
+	return c.identityMap.rewrite(e, c.identityMap.toExternal, func(Identity) bool {
+		return true
+	})
+}
+
+// receivedIdentity returns the identity as it is in the received envelopes, which is the internal one if mapped.
+func (c *channel) receivedIdentity(id Identity) Identity {
+	if c.identityMap != nil {
+		if internal, ok := c.identityMap.Internal(id); ok {
+			return internal
+		}
+	}
+	return id
+}
@@ -0,0 +1,144 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIdentityMap_Add(t *testing.T) {
+	// Arrange
+	m := NewIdentityMap()
+	internal := Identity{Name: "billing-svc", Domain: "internal"}
+	external := Identity{Name: "billing", Domain: "bots.limeprotocol.org"}
+
+	// Act
+	m.Add(internal, external)
+
+	// Assert
+	actualExternal, ok1 := m.External(internal)
+	actualInternal, ok2 := m.Internal(external)
+	assert.True(t, ok1)
+	assert.Equal(t, external, actualExternal)
+	assert.True(t, ok2)
+	assert.Equal(t, internal, actualInternal)
+}
+
+func TestIdentityMap_Add_Replace(t *testing.T) {
+	// Arrange
+	m := NewIdentityMap()
+	internal := Identity{Name: "billing-svc", Domain: "internal"}
+	m.Add(internal, Identity{Name: "billing", Domain: "bots.limeprotocol.org"})
+	external := Identity{Name: "payments", Domain: "bots.limeprotocol.org"}
+
+	// Act
+	m.Add(internal, external)
+
+	// Assert
+	actual, _ := m.External(internal)
+	assert.Equal(t, external, actual)
+	_, ok := m.Internal(Identity{Name: "billing", Domain: "bots.limeprotocol.org"})
+	assert.False(t, ok)
+}
+
+func TestIdentityMap_Remove(t *testing.T) {
+	// Arrange
+	m := NewIdentityMap()
+	internal := Identity{Name: "billing-svc", Domain: "internal"}
+	external := Identity{Name: "billing", Domain: "bots.limeprotocol.org"}
+	m.Add(internal, external)
+
+	// Act
+	m.Remove(internal)
+
+	// Assert
+	_, ok1 := m.External(internal)
+	_, ok2 := m.Internal(external)
+	assert.False(t, ok1)
+	assert.False(t, ok2)
+}
+
+func TestChannel_IdentityMap(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	m := NewIdentityMap()
+	internal := Identity{Name: "golang-svc", Domain: "internal"}
+	m.Add(internal, Identity{Name: "golang", Domain: "limeprotocol.org"})
+	c.SetIdentityMap(m)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	sent := createMessage()
+	sent.To = Node{}
+	sent.From = Node{Identity: internal, Instance: "default"}
+
+	// Act
+	errSend := c.SendMessage(ctx, sent)
+	actualSent, errReceive := server.Receive(ctx)
+	_ = server.Send(ctx, createMessage())
+	actualReceived := <-c.MsgChan()
+
+	// Assert
+	assert.NoError(t, errSend)
+	assert.NoError(t, errReceive)
+	assert.Equal(t, internal, sent.From.Identity)
+	assert.Equal(t, Node{Identity: Identity{Name: "golang", Domain: "limeprotocol.org"}, Instance: "default"}, actualSent.(*Message).From)
+	assert.Equal(t, Node{Identity: internal, Instance: "default"}, actualReceived.To)
+}
+
+func TestChannel_IdentityMap_SpoofedSender(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	m := NewIdentityMap()
+	internal := Identity{Name: "billing-svc", Domain: "internal"}
+	external := Identity{Name: "billing", Domain: "bots.limeprotocol.org"}
+	m.Add(internal, external)
+	c.SetIdentityMap(m)
+	c.remoteNode = Node{Identity: Identity{Name: "mallory", Domain: "limeprotocol.org"}, Instance: "home"}
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	msg := createMessage()
+	msg.From = Node{Identity: external, Instance: "home"}
+
+	// Act
+	_ = server.Send(ctx, msg)
+	actual := <-c.MsgChan()
+
+	// Assert
+	assert.Equal(t, external, actual.From.Identity)
+}
+
+func TestEnvelopeMux_verifySender_IdentityMap(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	m := NewIdentityMap()
+	internal := Identity{Name: "billing-svc", Domain: "internal"}
+	external := Identity{Name: "billing", Domain: "bots.limeprotocol.org"}
+	m.Add(internal, external)
+	c.SetIdentityMap(m)
+	c.remoteNode = Node{Identity: external, Instance: "home"}
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	mux.VerifyDelegations(NewDelegationStore().HasDelegation)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	msg := createMessage()
+	msg.From = Node{Identity: external, Instance: "home"}
+
+	// Act
+	_ = server.Send(ctx, msg)
+	actual := <-c.MsgChan()
+	reason := mux.verifySender(ctx, c, &actual.Envelope)
+
+	// Assert
+	assert.Equal(t, internal, actual.From.Identity)
+	assert.Nil(t, reason)
+}
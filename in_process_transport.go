@@ -9,12 +9,13 @@ import (
 )
 
 type inProcessTransport struct {
-	remote  *inProcessTransport // The remote party
-	addr    InProcessAddr
-	envChan chan envelope
-	done    chan bool
-	closed  bool
-	mu      sync.RWMutex
+	remote   *inProcessTransport // The remote party
+	addr     InProcessAddr
+	envChan  chan envelope
+	done     chan bool
+	closed   bool
+	mu       sync.RWMutex
+	counters transportCounters
 }
 
 func (t *inProcessTransport) Close() error {
@@ -39,6 +40,7 @@ func (t *inProcessTransport) Send(_ context.Context, e envelope) error {
 		return errors.New("transport is closed")
 	}
 	t.remote.envChan <- e
+	t.counters.envelopesEncoded.Add(1)
 	return nil
 }
 
@@ -52,10 +54,22 @@ func (t *inProcessTransport) Receive(ctx context.Context) (envelope, error) {
 	case <-t.done:
 		return nil, errors.New("transport was closed while receiving")
 	case e := <-t.envChan:
+		t.counters.envelopesDecoded.Add(1)
 		return e, nil
 	}
 }
 
+// Buffered returns the number of envelopes sent by the remote party and not yet received.
+func (t *inProcessTransport) Buffered() int {
+	return len(t.envChan)
+}
+
+// Stats returns the envelope counters of the transport.
+// The byte counters are always zero, since the envelopes are not serialized.
+func (t *inProcessTransport) Stats() TransportStats {
+	return t.counters.snapshot()
+}
+
 func newInProcessTransport(addr InProcessAddr, bufferSize int) *inProcessTransport {
 	return &inProcessTransport{
 		addr:    addr,
@@ -130,7 +144,9 @@ func NewInProcessTransportListener(addr InProcessAddr) TransportListener {
 func (l *inProcessTransportListener) Close() error {
 	l.closedMu.Lock()
 	defer l.closedMu.Unlock()
+	inProcListenersMu.Lock()
 	delete(inProcListeners, l.addr)
+	inProcListenersMu.Unlock()
 	l.closed = true
 	l.done <- true
 	return nil
@@ -146,6 +162,8 @@ func (l *inProcessTransportListener) Listen(_ context.Context, addr net.Addr) er
 		return fmt.Errorf("empty in process address %s", inProcAddr)
 	}
 
+	inProcListenersMu.Lock()
+	defer inProcListenersMu.Unlock()
 	if _, ok := inProcListeners[inProcAddr]; ok {
 		return fmt.Errorf("a listerer is already active on address %s", inProcAddr)
 	}
@@ -179,17 +197,22 @@ func (l *inProcessTransportListener) listening() bool {
 func (l *inProcessTransportListener) newClient(addr InProcessAddr, bufferSize int) *inProcessTransport {
 	// Create transport pair
 	client, server := newInProcessTransportPair(addr, bufferSize)
-	go func() {
+	goLabeled(context.Background(), "inprocess.accept", func(context.Context) {
 		l.transports <- server
-	}()
+	})
 	return client
 }
 
-var inProcListeners = make(map[InProcessAddr]*inProcessTransportListener)
+var (
+	inProcListeners   = make(map[InProcessAddr]*inProcessTransportListener)
+	inProcListenersMu sync.RWMutex
+)
 
 // DialInProcess creates a new in process transport connection to the specified path.
 func DialInProcess(addr InProcessAddr, bufferSize int) (Transport, error) {
+	inProcListenersMu.RLock()
 	l := inProcListeners[addr]
+	inProcListenersMu.RUnlock()
 	if l == nil {
 		return nil, fmt.Errorf("in process connection refused on %s address", addr)
 	}
@@ -0,0 +1,227 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+const (
+	// KafkaHeaderEnvelopeType is the header of the Kafka records with the envelope type, like 'message'.
+	KafkaHeaderEnvelopeType = "lime-envelope-type"
+	// KafkaHeaderEnvelopeID is the header of the Kafka records with the envelope ID.
+	KafkaHeaderEnvelopeID = "lime-envelope-id"
+)
+
+// KafkaRecord is a record written to or read from a Kafka topic.
+type KafkaRecord struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// KafkaWriter defines the operations of a Kafka producer used by the KafkaSink, allowing the use of any Kafka library
+// through a small adapter.
+type KafkaWriter interface {
+	// WriteRecords writes the records to their topics, returning after they are acknowledged by the brokers.
+	WriteRecords(ctx context.Context, records ...KafkaRecord) error
+}
+
+// KafkaReader defines the operations of a Kafka consumer used by the KafkaSource.
+type KafkaReader interface {
+	// FetchRecord blocks until a record is available in the subscribed topics.
+	FetchRecord(ctx context.Context) (KafkaRecord, error)
+	// CommitRecords marks the records as consumed.
+	CommitRecords(ctx context.Context, records ...KafkaRecord) error
+}
+
+// KafkaConfig defines the topics and the partition keys of the Kafka adapters.
+type KafkaConfig struct {
+	// MessagesTopic is the topic of the messages. If empty, the messages are not published.
+	MessagesTopic string
+	// NotificationsTopic is the topic of the notifications. If empty, the notifications are not published.
+	NotificationsTopic string
+	// CommandsTopic is the topic of the request and response commands. If empty, the commands are not published.
+	CommandsTopic string
+	// Key returns the partition key of an envelope. The envelopes with the same key are written to the same
+	// partition, which keeps their order.
+	Key func(env *Envelope) []byte
+}
+
+// NewKafkaConfig creates a new instance of KafkaConfig with the default values, which use the destination identity
+// as the partition key.
+func NewKafkaConfig() *KafkaConfig {
+	return &KafkaConfig{
+		MessagesTopic:      "lime.messages",
+		NotificationsTopic: "lime.notifications",
+		CommandsTopic:      "lime.commands",
+		Key:                KafkaDestinationKey,
+	}
+}
+
+// KafkaDestinationKey returns the destination identity of the envelope as the partition key, so all the envelopes
+// of an identity are consumed in order. The envelopes without destination have no key.
+func KafkaDestinationKey(env *Envelope) []byte {
+	if env.To.Identity == (Identity{}) {
+		return nil
+	}
+	return []byte(env.To.Identity.String())
+}
+
+// KafkaSink publishes the received envelopes to Kafka topics, enabling event-sourced backends.
+type KafkaSink struct {
+	config *KafkaConfig
+	writer KafkaWriter
+}
+
+// NewKafkaSink creates a new KafkaSink that writes the envelopes through the specified writer.
+func NewKafkaSink(config *KafkaConfig, writer KafkaWriter) *KafkaSink {
+	if config == nil {
+		panic("nil config")
+	}
+	if writer == nil {
+		panic("nil writer")
+	}
+	return &KafkaSink{config: config, writer: writer}
+}
+
+// MessageHandler returns a MessageHandler that publishes all the received messages.
+func (s *KafkaSink) MessageHandler() MessageHandler {
+	return &messageHandler{
+		handlerFunc: func(ctx context.Context, msg *Message, _ Sender) error {
+			return s.Publish(ctx, msg)
+		},
+	}
+}
+
+// NotificationHandler returns a NotificationHandler that publishes all the received notifications.
+func (s *KafkaSink) NotificationHandler() NotificationHandler {
+	return &notificationHandler{
+		handlerFunc: func(ctx context.Context, not *Notification) error {
+			return s.Publish(ctx, not)
+		},
+	}
+}
+
+// WriteTap implements the TapSink interface, so the sink can receive the envelopes mirrored by a Tap.
+func (s *KafkaSink) WriteTap(ctx context.Context, r *TapRecord) error {
+	return s.Publish(ctx, r.Envelope)
+}
+
+// Publish writes the envelope to the topic of its type. The envelopes of the types without topic are ignored.
+func (s *KafkaSink) Publish(ctx context.Context, e envelope) error {
+	var topic string
+	var env *Envelope
+	var envType TapEnvelopeType
+	switch e := e.(type) {
+	case *Message:
+		topic, env, envType = s.config.MessagesTopic, &e.Envelope, TapEnvelopeTypeMessage
+	case *Notification:
+		topic, env, envType = s.config.NotificationsTopic, &e.Envelope, TapEnvelopeTypeNotification
+	case *RequestCommand:
+		topic, env, envType = s.config.CommandsTopic, &e.Envelope, TapEnvelopeTypeRequestCommand
+	case *ResponseCommand:
+		topic, env, envType = s.config.CommandsTopic, &e.Envelope, TapEnvelopeTypeResponseCommand
+	default:
+		return fmt.Errorf("kafka sink: unexpected envelope type %T", e)
+	}
+	if topic == "" {
+		return nil
+	}
+
+	value, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("kafka sink: %w", err)
+	}
+	record := KafkaRecord{
+		Topic: topic,
+		Value: value,
+		Headers: map[string]string{
+			KafkaHeaderEnvelopeType: string(envType),
+		},
+	}
+	if env.ID != "" {
+		record.Headers[KafkaHeaderEnvelopeID] = env.ID
+	}
+	if s.config.Key != nil {
+		record.Key = s.config.Key(env)
+	}
+
+	if err = s.writer.WriteRecords(ctx, record); err != nil {
+		return fmt.Errorf("kafka sink: %w", err)
+	}
+	return nil
+}
+
+// KafkaSource consumes envelopes from Kafka topics and sends them through a channel or client.
+type KafkaSource struct {
+	reader KafkaReader
+	sender Sender
+}
+
+// NewKafkaSource creates a new KafkaSource that sends the envelopes read by the reader through the sender.
+func NewKafkaSource(reader KafkaReader, sender Sender) *KafkaSource {
+	if reader == nil {
+		panic("nil reader")
+	}
+	if sender == nil {
+		panic("nil sender")
+	}
+	return &KafkaSource{reader: reader, sender: sender}
+}
+
+// Run consumes the records until the context is canceled or an error occurs.
+// A record is committed only after its envelope is sent, so the failed ones are consumed again after a restart.
+// The records that are not valid envelopes are logged and committed, since they would never be sent.
+func (s *KafkaSource) Run(ctx context.Context) error {
+	for {
+		record, err := s.reader.FetchRecord(ctx)
+		if err != nil {
+			return fmt.Errorf("kafka source: fetch: %w", err)
+		}
+
+		e, err := decodeKafkaRecord(record)
+		if err != nil {
+			log.Printf("kafka source: topic '%v': %v", record.Topic, err)
+		} else if err = s.send(ctx, e); err != nil {
+			return fmt.Errorf("kafka source: %w", err)
+		}
+
+		if err = s.reader.CommitRecords(ctx, record); err != nil {
+			return fmt.Errorf("kafka source: commit: %w", err)
+		}
+	}
+}
+
+func (s *KafkaSource) send(ctx context.Context, e envelope) error {
+	switch e := e.(type) {
+	case *Message:
+		return s.sender.SendMessage(ctx, e)
+	case *Notification:
+		return s.sender.SendNotification(ctx, e)
+	case *RequestCommand:
+		return s.sender.SendRequestCommand(ctx, e)
+	case *ResponseCommand:
+		return s.sender.SendResponseCommand(ctx, e)
+	default:
+		return fmt.Errorf("unexpected envelope type %T", e)
+	}
+}
+
+func decodeKafkaRecord(record KafkaRecord) (envelope, error) {
+	var raw rawEnvelope
+	if err := json.Unmarshal(record.Value, &raw); err != nil {
+		return nil, err
+	}
+	e, err := raw.toEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := e.(*Session); ok {
+		return nil, errors.New("session envelopes are not supported")
+	}
+	return e, nil
+}
@@ -0,0 +1,150 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kafkaRecorder is a KafkaWriter and KafkaReader that keeps the written records and returns the pending ones.
+type kafkaRecorder struct {
+	mu        sync.Mutex
+	written   []KafkaRecord
+	pending   []KafkaRecord
+	committed []KafkaRecord
+}
+
+func (k *kafkaRecorder) WriteRecords(_ context.Context, records ...KafkaRecord) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.written = append(k.written, records...)
+	return nil
+}
+
+func (k *kafkaRecorder) FetchRecord(ctx context.Context) (KafkaRecord, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.pending) == 0 {
+		return KafkaRecord{}, errors.New("no records")
+	}
+	r := k.pending[0]
+	k.pending = k.pending[1:]
+	return r, nil
+}
+
+func (k *kafkaRecorder) CommitRecords(_ context.Context, records ...KafkaRecord) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.committed = append(k.committed, records...)
+	return nil
+}
+
+// senderRecorder is a Sender that keeps the sent envelopes.
+type senderRecorder struct {
+	messageRecorder
+	reqCmds  []*RequestCommand
+	respCmds []*ResponseCommand
+}
+
+func (r *senderRecorder) SendRequestCommand(_ context.Context, cmd *RequestCommand) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reqCmds = append(r.reqCmds, cmd)
+	return nil
+}
+
+func (r *senderRecorder) SendResponseCommand(_ context.Context, cmd *ResponseCommand) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.respCmds = append(r.respCmds, cmd)
+	return nil
+}
+
+func TestKafkaSink_MessageHandler(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	writer := &kafkaRecorder{}
+	sink := NewKafkaSink(NewKafkaConfig(), writer)
+	msg := createMessage()
+
+	// Act
+	err := sink.MessageHandler().Handle(ctx, msg, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, writer.written, 1)
+	r := writer.written[0]
+	assert.Equal(t, "lime.messages", r.Topic)
+	assert.Equal(t, []byte(msg.To.Identity.String()), r.Key)
+	assert.Equal(t, "message", r.Headers[KafkaHeaderEnvelopeType])
+	assert.Equal(t, msg.ID, r.Headers[KafkaHeaderEnvelopeID])
+	e, err := decodeKafkaRecord(r)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, e)
+}
+
+func TestKafkaSink_Publish_NoTopic(t *testing.T) {
+	// Arrange
+	writer := &kafkaRecorder{}
+	config := NewKafkaConfig()
+	config.NotificationsTopic = ""
+	sink := NewKafkaSink(config, writer)
+
+	// Act
+	err := sink.Publish(context.Background(), createNotification())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, writer.written)
+}
+
+func TestKafkaSink_Publish_NoDestination(t *testing.T) {
+	// Arrange
+	writer := &kafkaRecorder{}
+	sink := NewKafkaSink(NewKafkaConfig(), writer)
+	cmd := createGetPingCommand()
+	cmd.To = Node{}
+
+	// Act
+	err := sink.Publish(context.Background(), cmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, writer.written, 1)
+	assert.Equal(t, "lime.commands", writer.written[0].Topic)
+	assert.Nil(t, writer.written[0].Key)
+	assert.Equal(t, "requestCommand", writer.written[0].Headers[KafkaHeaderEnvelopeType])
+}
+
+func TestKafkaSource_Run(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	kafka := &kafkaRecorder{}
+	sink := NewKafkaSink(NewKafkaConfig(), kafka)
+	msg := createMessage()
+	not := createNotification()
+	cmd := createGetPingCommand()
+	for _, e := range []envelope{msg, not, cmd} {
+		assert.NoError(t, sink.Publish(ctx, e))
+	}
+	invalid := KafkaRecord{Topic: "lime.messages", Value: []byte(`{"invalid":`)}
+	kafka.pending = append(kafka.written, invalid)
+	sender := &senderRecorder{}
+	source := NewKafkaSource(kafka, sender)
+
+	// Act
+	err := source.Run(ctx)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no records")
+	assert.Equal(t, []*Message{msg}, sender.messages)
+	assert.Equal(t, []*Notification{not}, sender.notifications)
+	assert.Equal(t, []*RequestCommand{cmd}, sender.reqCmds)
+	assert.Len(t, kafka.committed, 4)
+}
@@ -0,0 +1,471 @@
+// Package limeconformance provides black-box tests that verify if a lime.Transport implementation, including the
+// third-party ones, behaves as expected by the lime channels: the envelope framing, the delivery order, the
+// cancellation and closing semantics and the session negotiation.
+//
+// The transports of other packages implement the Send and Receive methods with the lime.TransportEnvelope type,
+// encoding the envelopes with the json package and decoding them with the lime.UnmarshalTransportEnvelope function.
+//
+// The tests are run from a regular test function of the implementation package:
+//
+//	func TestMyTransport_Conformance(t *testing.T) {
+//		limeconformance.Run(t, func(t *testing.T) (lime.Transport, lime.Transport) {
+//			return dialAndAccept(t)
+//		})
+//	}
+package limeconformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Timeout is the maximum duration of each test operation.
+var Timeout = 5 * time.Second
+
+// TransportPair creates a pair of connected transports for a test, which are closed by the tests.
+// The client transport is the one that starts the session.
+type TransportPair func(t *testing.T) (client lime.Transport, server lime.Transport)
+
+// Run runs all the conformance tests for the transports created by newPair.
+func Run(t *testing.T, newPair TransportPair) {
+	t.Run("Transport", func(t *testing.T) {
+		RunTransport(t, newPair)
+	})
+	t.Run("Session", func(t *testing.T) {
+		RunSession(t, newPair)
+	})
+}
+
+// RunTransport runs the tests of the framing and delivery semantics of the transports.
+func RunTransport(t *testing.T, newPair TransportPair) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, client lime.Transport, server lime.Transport)
+	}{
+		{"Message", testMessage},
+		{"Notification", testNotification},
+		{"RequestCommand", testRequestCommand},
+		{"ResponseCommand", testResponseCommand},
+		{"Session", testSession},
+		{"ServerToClient", testServerToClient},
+		{"Order", testOrder},
+		{"ConcurrentSendReceive", testConcurrentSendReceive},
+		{"LargeEnvelope", testLargeEnvelope},
+		{"SpecialCharacters", testSpecialCharacters},
+		{"ReceiveCanceled", testReceiveCanceled},
+		{"Options", testOptions},
+		{"Close", testClose},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newPair(t)
+			defer client.Close()
+			defer server.Close()
+			tt.test(t, client, server)
+		})
+	}
+}
+
+// RunSession runs the tests of the session establishment, envelope exchange and finishing by lime channels over the
+// transports.
+func RunSession(t *testing.T, newPair TransportPair) {
+	t.Run("Establish", func(t *testing.T) {
+		client, server := establish(t, newPair)
+		assert.True(t, client.Established())
+		assert.True(t, server.Established())
+		assert.Equal(t, clientNode, server.RemoteNode())
+		assert.Equal(t, clientNode, client.LocalNode())
+		assert.Equal(t, serverNode, client.RemoteNode())
+		assert.Equal(t, client.ID(), server.ID())
+	})
+	t.Run("ExchangeEnvelopes", func(t *testing.T) {
+		testExchangeEnvelopes(t, newPair)
+	})
+	t.Run("Finish", func(t *testing.T) {
+		testFinish(t, newPair)
+	})
+}
+
+var (
+	clientNode = lime.Node{Identity: lime.Identity{Name: "conformance", Domain: "limeprotocol.org"}, Instance: "client"}
+	serverNode = lime.Node{Identity: lime.Identity{Name: "postmaster", Domain: "limeprotocol.org"}, Instance: "server"}
+)
+
+func newContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func newMessage(id string, text string) *lime.Message {
+	msg := &lime.Message{}
+	msg.SetID(id)
+	msg.SetFrom(clientNode)
+	msg.SetTo(serverNode)
+	msg.SetContent(lime.TextDocument(text))
+	return msg
+}
+
+// sendReceive sends the envelope from one transport and receives it in the other.
+func sendReceive(t *testing.T, from lime.Transport, to lime.Transport, e interface{}) interface{} {
+	ctx := newContext(t)
+	var err error
+	switch e := e.(type) {
+	case *lime.Message:
+		err = from.Send(ctx, e)
+	case *lime.Notification:
+		err = from.Send(ctx, e)
+	case *lime.RequestCommand:
+		err = from.Send(ctx, e)
+	case *lime.ResponseCommand:
+		err = from.Send(ctx, e)
+	case *lime.Session:
+		err = from.Send(ctx, e)
+	default:
+		t.Fatalf("unexpected envelope type %T", e)
+	}
+	require.NoError(t, err, "send")
+
+	received, err := to.Receive(ctx)
+	require.NoError(t, err, "receive")
+	return received
+}
+
+func testMessage(t *testing.T, client lime.Transport, server lime.Transport) {
+	msg := newMessage("message-1", "Hello world")
+	msg.SetMetadataKeyValue("custom", "value")
+
+	received := sendReceive(t, client, server, msg)
+
+	actual, ok := received.(*lime.Message)
+	require.True(t, ok, "expected a message, got %T", received)
+	assert.Equal(t, msg.ID, actual.ID)
+	assert.Equal(t, msg.From, actual.From)
+	assert.Equal(t, msg.To, actual.To)
+	assert.Equal(t, msg.Metadata, actual.Metadata)
+	assert.Equal(t, msg.Type, actual.Type)
+	assertText(t, "Hello world", actual.Content)
+}
+
+func testNotification(t *testing.T, client lime.Transport, server lime.Transport) {
+	not := &lime.Notification{Event: lime.NotificationEventFailed}
+	not.SetID("message-1")
+	not.SetFrom(serverNode)
+	not.SetTo(clientNode)
+	not.Reason = &lime.Reason{Code: lime.ReasonCodeGeneralError, Description: "Failure"}
+
+	received := sendReceive(t, client, server, not)
+
+	actual, ok := received.(*lime.Notification)
+	require.True(t, ok, "expected a notification, got %T", received)
+	assert.Equal(t, not.ID, actual.ID)
+	assert.Equal(t, not.From, actual.From)
+	assert.Equal(t, not.To, actual.To)
+	assert.Equal(t, not.Event, actual.Event)
+	assert.Equal(t, not.Reason, actual.Reason)
+}
+
+func testRequestCommand(t *testing.T, client lime.Transport, server lime.Transport) {
+	cmd := &lime.RequestCommand{}
+	cmd.SetID("command-1").SetTo(serverNode)
+	cmd.Method = lime.CommandMethodSet
+	cmd.SetURI(lime.NewResourceURI(lime.PresencePath))
+	cmd.SetResource(&lime.JsonDocument{"status": "available"})
+
+	received := sendReceive(t, client, server, cmd)
+
+	actual, ok := received.(*lime.RequestCommand)
+	require.True(t, ok, "expected a request command, got %T", received)
+	assert.Equal(t, cmd.ID, actual.ID)
+	assert.Equal(t, cmd.To, actual.To)
+	assert.Equal(t, cmd.Method, actual.Method)
+	assert.Equal(t, cmd.URI.String(), actual.URI.String())
+	assert.Equal(t, cmd.Type, actual.Type)
+	if assert.IsType(t, &lime.JsonDocument{}, actual.Resource) {
+		assert.Equal(t, "available", (*actual.Resource.(*lime.JsonDocument))["status"])
+	}
+}
+
+func testResponseCommand(t *testing.T, client lime.Transport, server lime.Transport) {
+	cmd := &lime.ResponseCommand{}
+	cmd.SetID("command-1").SetFrom(serverNode)
+	cmd.Method = lime.CommandMethodGet
+	cmd.Status = lime.CommandStatusFailure
+	cmd.Reason = &lime.Reason{Code: lime.ReasonCodeCommandResourceNotFound, Description: "Not found"}
+
+	received := sendReceive(t, client, server, cmd)
+
+	actual, ok := received.(*lime.ResponseCommand)
+	require.True(t, ok, "expected a response command, got %T", received)
+	assert.Equal(t, cmd.ID, actual.ID)
+	assert.Equal(t, cmd.From, actual.From)
+	assert.Equal(t, cmd.Method, actual.Method)
+	assert.Equal(t, cmd.Status, actual.Status)
+	assert.Equal(t, cmd.Reason, actual.Reason)
+}
+
+func testSession(t *testing.T, client lime.Transport, server lime.Transport) {
+	ses := &lime.Session{State: lime.SessionStateNew}
+	ses.SetID("session-1")
+
+	received := sendReceive(t, client, server, ses)
+
+	actual, ok := received.(*lime.Session)
+	require.True(t, ok, "expected a session, got %T", received)
+	assert.Equal(t, ses.ID, actual.ID)
+	assert.Equal(t, ses.State, actual.State)
+}
+
+func testServerToClient(t *testing.T, client lime.Transport, server lime.Transport) {
+	received := sendReceive(t, server, client, newMessage("message-1", "From the server"))
+
+	actual, ok := received.(*lime.Message)
+	require.True(t, ok, "expected a message, got %T", received)
+	assertText(t, "From the server", actual.Content)
+}
+
+func testOrder(t *testing.T, client lime.Transport, server lime.Transport) {
+	const count = 100
+	ctx := newContext(t)
+	errChan := make(chan error, 1)
+	go func() {
+		for i := 0; i < count; i++ {
+			if err := client.Send(ctx, newMessage(fmt.Sprint(i), fmt.Sprint(i))); err != nil {
+				errChan <- err
+				return
+			}
+		}
+		errChan <- nil
+	}()
+
+	for i := 0; i < count; i++ {
+		received, err := server.Receive(ctx)
+		require.NoError(t, err, "receive %v", i)
+		msg, ok := received.(*lime.Message)
+		require.True(t, ok, "expected a message, got %T", received)
+		require.Equal(t, fmt.Sprint(i), msg.ID, "the envelopes were received out of order")
+	}
+	assert.NoError(t, <-errChan, "send")
+}
+
+func testConcurrentSendReceive(t *testing.T, client lime.Transport, server lime.Transport) {
+	const count = 50
+	ctx := newContext(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	exchange := func(from lime.Transport, to lime.Transport) {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				if err := from.Send(ctx, newMessage(fmt.Sprint(i), "concurrent")); err != nil {
+					errs <- fmt.Errorf("send: %w", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				if _, err := to.Receive(ctx); err != nil {
+					errs <- fmt.Errorf("receive: %w", err)
+					return
+				}
+			}
+		}()
+	}
+
+	exchange(client, server)
+	exchange(server, client)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func testLargeEnvelope(t *testing.T, client lime.Transport, server lime.Transport) {
+	text := strings.Repeat("0123456789abcdef", 4096)
+
+	received := sendReceive(t, client, server, newMessage("message-1", text))
+
+	actual, ok := received.(*lime.Message)
+	require.True(t, ok, "expected a message, got %T", received)
+	assertText(t, text, actual.Content)
+}
+
+func testSpecialCharacters(t *testing.T, client lime.Transport, server lime.Transport) {
+	text := "Olá, \"mundo\"! \\ 你好   \n\t} ] { [ 🙂"
+
+	received := sendReceive(t, client, server, newMessage("message-1", text))
+
+	actual, ok := received.(*lime.Message)
+	require.True(t, ok, "expected a message, got %T", received)
+	assertText(t, text, actual.Content)
+}
+
+func testReceiveCanceled(t *testing.T, client lime.Transport, server lime.Transport) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := server.Receive(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err, "receive should fail when the context is done")
+	case <-time.After(Timeout):
+		t.Fatal("receive did not return after the context was done")
+	}
+}
+
+func testOptions(t *testing.T, client lime.Transport, server lime.Transport) {
+	for _, transport := range []lime.Transport{client, server} {
+		assert.True(t, transport.Connected())
+		assert.NotNil(t, transport.LocalAddr())
+		assert.NotNil(t, transport.RemoteAddr())
+		assert.True(t,
+			slices.Contains(transport.SupportedCompression(), transport.Compression()),
+			"the current compression should be supported")
+		assert.True(t,
+			slices.Contains(transport.SupportedEncryption(), transport.Encryption()),
+			"the current encryption should be supported")
+	}
+}
+
+func testClose(t *testing.T, client lime.Transport, server lime.Transport) {
+	ctx := newContext(t)
+
+	err := client.Close()
+
+	assert.NoError(t, err)
+	assert.False(t, client.Connected())
+	assert.Error(t, client.Send(ctx, newMessage("message-1", "closed")), "send should fail after closing")
+	_, err = server.Receive(ctx)
+	assert.Error(t, err, "receive should fail after the remote party closed")
+	assert.False(t, errors.Is(err, context.DeadlineExceeded), "receive should not wait the timeout")
+}
+
+func assertText(t *testing.T, expected string, actual lime.Document) {
+	switch d := actual.(type) {
+	case lime.TextDocument:
+		assert.Equal(t, expected, string(d))
+	case *lime.TextDocument:
+		assert.Equal(t, expected, string(*d))
+	default:
+		assert.Fail(t, fmt.Sprintf("expected a text document, got %T", actual))
+	}
+}
+
+// establish creates client and server channels with an established session.
+func establish(t *testing.T, newPair TransportPair) (*lime.ClientChannel, *lime.ServerChannel) {
+	clientTransport, serverTransport := newPair(t)
+	client := lime.NewClientChannel(clientTransport, 1)
+	server := lime.NewServerChannel(serverTransport, 1, serverNode, "conformance-session")
+	t.Cleanup(func() {
+		// Closing the transports first unblocks the channel receivers
+		_ = clientTransport.Close()
+		_ = client.Close()
+		_ = server.Close()
+	})
+	ctx := newContext(t)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.EstablishSession(
+			ctx,
+			[]lime.SessionCompression{serverTransport.Compression()},
+			[]lime.SessionEncryption{serverTransport.Encryption()},
+			[]lime.AuthenticationScheme{lime.AuthenticationSchemeGuest},
+			func(context.Context, lime.Identity, lime.Authentication) (*lime.AuthenticationResult, error) {
+				return lime.MemberAuthenticationResult(), nil
+			},
+			func(_ context.Context, node lime.Node, _ *lime.ServerChannel) (lime.Node, error) {
+				return node, nil
+			})
+	}()
+
+	ses, err := client.EstablishSession(
+		ctx,
+		func(options []lime.SessionCompression) lime.SessionCompression {
+			return options[0]
+		},
+		func(options []lime.SessionEncryption) lime.SessionEncryption {
+			return options[0]
+		},
+		clientNode.Identity,
+		func([]lime.AuthenticationScheme, lime.Authentication) lime.Authentication {
+			return &lime.GuestAuthentication{}
+		},
+		clientNode.Instance)
+	require.NoError(t, err, "client establish session")
+	require.NoError(t, <-errChan, "server establish session")
+	require.Equal(t, lime.SessionStateEstablished, ses.State)
+	return client, server
+}
+
+func testExchangeEnvelopes(t *testing.T, newPair TransportPair) {
+	client, server := establish(t, newPair)
+	ctx := newContext(t)
+
+	require.NoError(t, client.SendMessage(ctx, newMessage("message-1", "Hello")))
+	select {
+	case <-ctx.Done():
+		t.Fatal("the server did not receive the message")
+	case msg := <-server.MsgChan():
+		assertText(t, "Hello", msg.Content)
+		require.NoError(t, server.SendNotification(ctx, msg.Notification(lime.NotificationEventReceived)))
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal("the client did not receive the notification")
+	case not := <-client.NotChan():
+		assert.Equal(t, "message-1", not.ID)
+		assert.Equal(t, lime.NotificationEventReceived, not.Event)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case cmd := <-server.ReqCmdChan():
+			_ = server.SendResponseCommand(ctx, cmd.SuccessResponse())
+		}
+	}()
+	cmd := &lime.RequestCommand{}
+	cmd.SetID("command-1").SetTo(serverNode)
+	cmd.Method = lime.CommandMethodGet
+	cmd.SetURI(lime.NewResourceURI(lime.PingPath))
+	resp, err := client.ProcessCommand(ctx, cmd)
+	require.NoError(t, err, "process command")
+	assert.Equal(t, lime.CommandStatusSuccess, resp.Status)
+}
+
+func testFinish(t *testing.T, newPair TransportPair) {
+	client, server := establish(t, newPair)
+	ctx := newContext(t)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-server.RcvDone():
+			_ = server.FinishSession(ctx)
+		}
+	}()
+	ses, err := client.FinishSession(ctx)
+
+	require.NoError(t, err, "finish session")
+	assert.Equal(t, lime.SessionStateFinished, ses.State)
+}
@@ -0,0 +1,194 @@
+package limeconformance
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/phonero/lime"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func freeTCPAddr(t *testing.T) *net.TCPAddr {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	_ = l.Close()
+	return addr
+}
+
+// acceptPair listens with the listener, dials the client and accepts the server transport.
+func acceptPair(t *testing.T, listener lime.TransportListener, addr net.Addr, dial func() (lime.Transport, error)) (lime.Transport, lime.Transport) {
+	ctx := newContext(t)
+	if err := listener.Listen(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	client, err := dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := listener.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+var inProcessCount atomic.Int64
+
+// The in-process transport discards the pending envelopes when closed, so the session finishing is not reliable and
+// only the transport tests are run.
+func TestInProcessTransport(t *testing.T) {
+	RunTransport(t, func(t *testing.T) (lime.Transport, lime.Transport) {
+		addr := lime.InProcessAddr(fmt.Sprintf("conformance-%v", inProcessCount.Add(1)))
+		return acceptPair(t, lime.NewInProcessTransportListener(addr), addr, func() (lime.Transport, error) {
+			return lime.DialInProcess(addr, 16)
+		})
+	})
+}
+
+func TestTCPTransport(t *testing.T) {
+	Run(t, func(t *testing.T) (lime.Transport, lime.Transport) {
+		addr := freeTCPAddr(t)
+		return acceptPair(t, lime.NewTCPTransportListener(nil), addr, func() (lime.Transport, error) {
+			return lime.DialTcp(context.Background(), addr, nil)
+		})
+	})
+}
+
+func TestWebsocketTransport(t *testing.T) {
+	Run(t, func(t *testing.T) (lime.Transport, lime.Transport) {
+		addr := freeTCPAddr(t)
+		return acceptPair(t, lime.NewWebsocketTransportListener(nil), addr, func() (lime.Transport, error) {
+			return lime.DialWebsocket(context.Background(), fmt.Sprintf("ws://%v", addr), nil, nil)
+		})
+	})
+}
+
+// connTransport is a Transport implemented outside the lime package, which sends the envelopes as JSON lines.
+type connTransport struct {
+	conn     net.Conn
+	sendMu   sync.Mutex
+	received chan []byte
+	done     chan struct{}
+	closed   sync.Once
+}
+
+func newConnTransport(conn net.Conn) *connTransport {
+	t := &connTransport{conn: conn, received: make(chan []byte), done: make(chan struct{})}
+	go t.read()
+	return t
+}
+
+func (t *connTransport) read() {
+	defer close(t.received)
+	r := bufio.NewReader(t.conn)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		select {
+		case <-t.done:
+			return
+		case t.received <- line:
+		}
+	}
+}
+
+func (t *connTransport) Send(_ context.Context, e lime.TransportEnvelope) error {
+	if !t.Connected() {
+		return errors.New("transport closed")
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	_, err = t.conn.Write(append(b, '\n'))
+	return err
+}
+
+func (t *connTransport) Receive(ctx context.Context) (lime.TransportEnvelope, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case line, ok := <-t.received:
+		if !ok {
+			return nil, io.EOF
+		}
+		return lime.UnmarshalTransportEnvelope(line)
+	}
+}
+
+func (t *connTransport) Close() error {
+	t.closed.Do(func() {
+		close(t.done)
+	})
+	return t.conn.Close()
+}
+
+func (t *connTransport) Connected() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (t *connTransport) SupportedCompression() []lime.SessionCompression {
+	return []lime.SessionCompression{lime.SessionCompressionNone}
+}
+
+func (t *connTransport) Compression() lime.SessionCompression {
+	return lime.SessionCompressionNone
+}
+
+func (t *connTransport) SetCompression(_ context.Context, c lime.SessionCompression) error {
+	if c != lime.SessionCompressionNone {
+		return fmt.Errorf("unsupported compression %v", c)
+	}
+	return nil
+}
+
+func (t *connTransport) SupportedEncryption() []lime.SessionEncryption {
+	return []lime.SessionEncryption{lime.SessionEncryptionNone}
+}
+
+func (t *connTransport) Encryption() lime.SessionEncryption {
+	return lime.SessionEncryptionNone
+}
+
+func (t *connTransport) SetEncryption(_ context.Context, e lime.SessionEncryption) error {
+	if e != lime.SessionEncryptionNone {
+		return fmt.Errorf("unsupported encryption %v", e)
+	}
+	return nil
+}
+
+func (t *connTransport) LocalAddr() net.Addr {
+	return t.conn.LocalAddr()
+}
+
+func (t *connTransport) RemoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+func TestExternalTransport(t *testing.T) {
+	Run(t, func(t *testing.T) (lime.Transport, lime.Transport) {
+		client, server := net.Pipe()
+		return newConnTransport(client), newConnTransport(server)
+	})
+}
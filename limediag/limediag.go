@@ -0,0 +1,154 @@
+// Package limediag provides an HTTP endpoint for debugging a lime.Server, like for finding goroutine leaks in
+// long-running brokers. The endpoint is started with the server as a plugin:
+//
+//	srv := lime.NewServerBuilder().
+//		ListenTCP(addr, nil).
+//		Plugin(limediag.NewPlugin("127.0.0.1:6060")).
+//		Build()
+//
+// The profiles are served from the runtime/pprof package, so the net/http/pprof handlers are not registered in the
+// http.DefaultServeMux of the application.
+package limediag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/phonero/lime"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler returns an HTTP handler for debugging the server, which serves the following paths:
+//
+//   - /debug/pprof/: the runtime profiles, like /debug/pprof/heap, with the debug query parameter of pprof;
+//   - /debug/pprof/profile and /debug/pprof/trace: the CPU profile and the execution trace of the duration in the
+//     seconds query parameter, which is 30 by default;
+//   - /debug/lime/goroutines: the goroutine dump filtered to the goroutines labeled by lime;
+//   - /debug/lime/channels: the JSON list of the established sessions.
+//
+// The handler exposes sensitive information and should not be reachable from the public networks.
+func Handler(srv *lime.Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", serveProfile)
+	mux.HandleFunc("/debug/pprof/profile", func(w http.ResponseWriter, r *http.Request) {
+		serveRecording(w, r, pprof.StartCPUProfile, pprof.StopCPUProfile)
+	})
+	mux.HandleFunc("/debug/pprof/trace", func(w http.ResponseWriter, r *http.Request) {
+		serveRecording(w, r, trace.Start, trace.Stop)
+	})
+	mux.HandleFunc("/debug/lime/goroutines", serveGoroutines)
+	mux.HandleFunc("/debug/lime/channels", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(srv.DiagnosticsChannels())
+	})
+	return mux
+}
+
+// serveProfile writes the runtime profile of the path, or the index of the profiles.
+func serveProfile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	if name == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, "<html><body><ul>\n")
+		for _, p := range pprof.Profiles() {
+			n := html.EscapeString(p.Name())
+			_, _ = fmt.Fprintf(w, "<li><a href=\"%v?debug=1\">%v</a> (%d)</li>\n", n, n, p.Count())
+		}
+		_, _ = fmt.Fprint(w, "</ul></body></html>\n")
+		return
+	}
+	p := pprof.Lookup(name)
+	if p == nil {
+		http.Error(w, "unknown profile", http.StatusNotFound)
+		return
+	}
+	debug, _ := strconv.Atoi(r.FormValue("debug"))
+	if debug != 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	_ = p.WriteTo(w, debug)
+}
+
+// serveRecording writes the data recorded between the start and stop functions during the requested seconds.
+func serveRecording(w http.ResponseWriter, r *http.Request, start func(w io.Writer) error, stop func()) {
+	seconds, err := strconv.ParseFloat(r.FormValue("seconds"), 64)
+	if err != nil || seconds <= 0 {
+		seconds = 30
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := start(w); err != nil {
+		// Only one recording of each kind can run at a time
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	select {
+	case <-time.After(time.Duration(seconds * float64(time.Second))):
+	case <-r.Context().Done():
+	}
+	stop()
+}
+
+func serveGoroutines(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	if _, err := lime.WriteGoroutines(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// Plugin is a lime.Plugin that serves the Handler of the server in an address while the server is listening.
+type Plugin struct {
+	addr    string
+	httpSrv *http.Server
+	done    chan error
+}
+
+// NewPlugin creates a Plugin for serving the diagnostics endpoint in the address.
+func NewPlugin(addr string) *Plugin {
+	return &Plugin{addr: addr}
+}
+
+func (p *Plugin) Start(ctx context.Context, srv *lime.Server) error {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("diagnostics listen error: %w", err)
+	}
+	p.httpSrv = &http.Server{
+		Handler:           Handler(srv),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	p.done = make(chan error, 1)
+	go func() {
+		p.done <- p.httpSrv.Serve(listener)
+	}()
+	return nil
+}
+
+func (p *Plugin) Stop(context.Context) error {
+	if p.httpSrv == nil {
+		return nil
+	}
+	err := p.httpSrv.Close()
+	if serveErr := <-p.done; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = serveErr
+	}
+	if err != nil {
+		return fmt.Errorf("diagnostics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package limediag
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/phonero/lime"
+	"github.com/phonero/lime/limetest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b, err := limetest.StartBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	client, err := b.Connect(ctx, b.ClientBuilder("golang"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	handler := Handler(b.Server())
+
+	// Act
+	channelsRec := httptest.NewRecorder()
+	handler.ServeHTTP(channelsRec, httptest.NewRequest(http.MethodGet, "/debug/lime/channels", nil))
+	goroutinesRec := httptest.NewRecorder()
+	handler.ServeHTTP(goroutinesRec, httptest.NewRequest(http.MethodGet, "/debug/lime/goroutines", nil))
+	pprofRec := httptest.NewRecorder()
+	handler.ServeHTTP(pprofRec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	heapRec := httptest.NewRecorder()
+	handler.ServeHTTP(heapRec, httptest.NewRequest(http.MethodGet, "/debug/pprof/heap?debug=1", nil))
+	cpuRec := httptest.NewRecorder()
+	handler.ServeHTTP(cpuRec, httptest.NewRequest(http.MethodGet, "/debug/pprof/profile?seconds=0.01", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, channelsRec.Code)
+	var channels []lime.DiagnosticsChannel
+	assert.NoError(t, json.Unmarshal(channelsRec.Body.Bytes(), &channels))
+	if assert.Len(t, channels, 1) {
+		assert.Equal(t, lime.SessionStateEstablished, channels[0].State)
+		assert.Equal(t, "golang", channels[0].RemoteNode.Name)
+	}
+	assert.Equal(t, http.StatusOK, goroutinesRec.Code)
+	assert.Contains(t, goroutinesRec.Body.String(), `"lime.session":"`+channels[0].SessionID+`"`)
+	assert.NotContains(t, goroutinesRec.Body.String(), "TestHandler")
+	assert.Equal(t, http.StatusOK, pprofRec.Code)
+	assert.Contains(t, pprofRec.Body.String(), "goroutine")
+	assert.Equal(t, http.StatusOK, heapRec.Code)
+	assert.Contains(t, heapRec.Body.String(), "heap profile")
+	assert.Equal(t, http.StatusOK, cpuRec.Code)
+	assert.NotEmpty(t, cpuRec.Body.Bytes())
+}
+
+func TestHandler_DefaultServeMux(t *testing.T) {
+	// Act
+	_, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+
+	// Assert
+	assert.Empty(t, pattern)
+}
+
+func TestPlugin(t *testing.T) {
+	// Arrange
+	srv := lime.NewServerBuilder().
+		ListenInProcess(lime.InProcessAddr("limediag-plugin")).
+		Plugin(NewPlugin("127.0.0.1:58061")).
+		Build()
+	go func() {
+		_ = srv.ListenAndServe()
+	}()
+
+	// Act
+	var status int
+	ok := assert.Eventually(t, func() bool {
+		resp, err := http.Get("http://127.0.0.1:58061/debug/lime/channels")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		status = resp.StatusCode
+		return true
+	}, 250*time.Millisecond, 5*time.Millisecond)
+
+	// Assert
+	if ok {
+		assert.Equal(t, http.StatusOK, status)
+	}
+	assert.NoError(t, srv.Close())
+	_, err := http.Get("http://127.0.0.1:58061/debug/lime/channels")
+	assert.Error(t, err)
+}
@@ -0,0 +1,243 @@
+package limetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/phonero/lime"
+	"go.uber.org/multierr"
+	"net"
+)
+
+// BrokerDomain is the domain of the Broker nodes.
+const BrokerDomain = "localhost"
+
+// Broker is a minimal in-memory Lime server for the end-to-end examples and tests, which accepts the guest sessions
+// of any identity through an in-process listener.
+// The envelopes addressed to the identity of another session are routed to it, like the messages between two
+// clients, and the others are handled by the broker modules, like the commands to the postmaster:
+//
+//	broker, err := limetest.StartBroker(func(mux *lime.EnvelopeMux) {
+//		mux.RequestCommandHandlerFunc(isPresenceCommand, setPresence)
+//	})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer broker.Close()
+//	alice, err := broker.Connect(ctx, broker.ClientBuilder("alice"))
+//
+// The messages to identities without sessions are failed with the ReasonCodeRoutingDestinationNotFound code, if
+// they have an ID. The pings to the broker are replied.
+type Broker struct {
+	addr lime.InProcessAddr
+	srv  *lime.Server
+	done chan error
+}
+
+// StartBroker starts a Broker with the modules handling the envelopes that are not routed to other sessions, in the
+// registration order.
+func StartBroker(modules ...Module) (*Broker, error) {
+	addr := lime.InProcessAddr("limetest-broker-" + lime.NewEnvelopeID())
+	config := lime.NewServerConfig()
+	config.Node.Instance = "limetest"
+	config.SchemeOpts = []lime.AuthenticationScheme{lime.AuthenticationSchemeGuest}
+	config.EncryptOpts = []lime.SessionEncryption{lime.SessionEncryptionNone}
+
+	mux := &lime.EnvelopeMux{}
+	r := &brokerRouter{node: config.Node}
+	r.RegisterHandlers(mux)
+	for _, m := range modules {
+		m(mux)
+	}
+	mux.RequestCommandHandlerFunc(
+		func(cmd *lime.RequestCommand) bool {
+			return cmd.Method == lime.CommandMethodGet && cmd.URI.Path() == lime.PingPath
+		},
+		func(ctx context.Context, cmd *lime.RequestCommand, s lime.Sender) error {
+			return s.SendResponseCommand(ctx, cmd.SuccessResponseWithResource(&lime.Ping{}))
+		})
+	config.Plugins = []lime.Plugin{r}
+
+	listener := &readyListener{TransportListener: lime.NewInProcessTransportListener(addr), ready: make(chan struct{})}
+	b := &Broker{
+		addr: addr,
+		srv:  lime.NewServer(config, mux, lime.NewBoundListener(listener, addr)),
+		done: make(chan error, 1),
+	}
+	go func() {
+		b.done <- b.srv.ListenAndServe()
+	}()
+	select {
+	case <-listener.ready:
+		return b, nil
+	case err := <-b.done:
+		return nil, fmt.Errorf("start broker: %w", err)
+	}
+}
+
+// Addr returns the in-process address of the broker.
+func (b *Broker) Addr() lime.InProcessAddr {
+	return b.addr
+}
+
+// Server returns the server of the broker, which can be used to deliver messages to the sessions.
+func (b *Broker) Server() *lime.Server {
+	return b.srv
+}
+
+// ClientBuilder creates a lime.ClientBuilder for a client of the broker with the name, which authenticates as a
+// guest. The handlers of the client can be defined before it is connected.
+func (b *Broker) ClientBuilder(name string) *lime.ClientBuilder {
+	return lime.NewClientBuilder().
+		UseInProcess(b.addr, pipelineBufferSize).
+		Name(name).
+		Domain(BrokerDomain).
+		GuestAuthentication()
+}
+
+// Connect builds the client and establishes its session with the broker.
+func (b *Broker) Connect(ctx context.Context, builder *lime.ClientBuilder) (*lime.Client, error) {
+	client := builder.Build()
+	if err := client.Establish(ctx); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("connect to broker: %w", err)
+	}
+	return client, nil
+}
+
+// Close stops the broker, finishing its sessions.
+func (b *Broker) Close() error {
+	err := b.srv.Close()
+	if serveErr := <-b.done; !errors.Is(serveErr, lime.ErrServerClosed) {
+		err = multierr.Append(err, serveErr)
+	}
+	return err
+}
+
+// readyListener signals when the transport listener is listening, before the server accepts the sessions.
+type readyListener struct {
+	lime.TransportListener
+	ready chan struct{}
+}
+
+func (l *readyListener) Listen(ctx context.Context, addr net.Addr) error {
+	if err := l.TransportListener.Listen(ctx, addr); err != nil {
+		return err
+	}
+	close(l.ready)
+	return nil
+}
+
+// brokerRouter routes the envelopes addressed to other identities to their sessions.
+type brokerRouter struct {
+	node lime.Node
+	srv  *lime.Server
+}
+
+func (r *brokerRouter) Start(_ context.Context, srv *lime.Server) error {
+	r.srv = srv
+	return nil
+}
+
+func (r *brokerRouter) Stop(context.Context) error {
+	return nil
+}
+
+func (r *brokerRouter) RegisterHandlers(mux *lime.EnvelopeMux) {
+	mux.MessageHandlerFunc(
+		func(msg *lime.Message) bool {
+			return r.routed(msg.To)
+		},
+		func(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+			m := *msg
+			m.From = sender(ctx, msg.From)
+			sessions := r.destinations(msg.To)
+			if len(sessions) == 0 {
+				if msg.ID == "" {
+					return nil
+				}
+				return s.SendNotification(ctx, msg.FailedNotification(&lime.Reason{
+					Code:        lime.ReasonCodeRoutingDestinationNotFound,
+					Description: "Destination not found",
+				}))
+			}
+			for _, c := range sessions {
+				if err := c.SendMessage(ctx, &m); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	mux.NotificationHandlerFunc(
+		func(not *lime.Notification) bool {
+			return r.routed(not.To)
+		},
+		func(ctx context.Context, not *lime.Notification) error {
+			n := *not
+			n.From = sender(ctx, not.From)
+			for _, c := range r.destinations(not.To) {
+				if err := c.SendNotification(ctx, &n); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	mux.RequestCommandHandlerFunc(
+		func(cmd *lime.RequestCommand) bool {
+			return r.routed(cmd.To)
+		},
+		func(ctx context.Context, cmd *lime.RequestCommand, s lime.Sender) error {
+			sessions := r.destinations(cmd.To)
+			if len(sessions) == 0 {
+				return s.SendResponseCommand(ctx, cmd.FailureResponse(&lime.Reason{
+					Code:        lime.ReasonCodeRoutingDestinationNotFound,
+					Description: "Destination not found",
+				}))
+			}
+			c := *cmd
+			c.From = sender(ctx, cmd.From)
+			// The commands are sent to a single session, since only one response is expected
+			return sessions[0].SendRequestCommand(ctx, &c)
+		})
+	mux.ResponseCommandHandlerFunc(
+		func(cmd *lime.ResponseCommand) bool {
+			return r.routed(cmd.To)
+		},
+		func(ctx context.Context, cmd *lime.ResponseCommand, s lime.Sender) error {
+			c := *cmd
+			c.From = sender(ctx, cmd.From)
+			for _, ses := range r.destinations(cmd.To) {
+				if err := ses.SendResponseCommand(ctx, &c); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
+
+// routed indicates if the envelope is addressed to an identity other than the broker.
+func (r *brokerRouter) routed(to lime.Node) bool {
+	return to.Name != "" && to.Identity != r.node.Identity
+}
+
+// destinations returns the sessions of the node, or of all the instances of its identity, if the instance is not
+// defined.
+func (r *brokerRouter) destinations(to lime.Node) []*lime.ServerChannel {
+	var sessions []*lime.ServerChannel
+	for _, c := range r.srv.Sessions() {
+		remote := c.RemoteNode()
+		if remote.Identity == to.Identity && (to.Instance == "" || remote.Instance == to.Instance) {
+			sessions = append(sessions, c)
+		}
+	}
+	return sessions
+}
+
+// sender returns the node that sent the envelope, which is the session remote node, like in a Lime server that does
+// not trust the nodes defined by the clients.
+func sender(ctx context.Context, from lime.Node) lime.Node {
+	if node, ok := lime.ContextSessionRemoteNode(ctx); ok {
+		return node
+	}
+	return from
+}
@@ -0,0 +1,152 @@
+package limetest
+
+import (
+	"context"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func startBroker(t *testing.T, modules ...Module) *Broker {
+	b, err := StartBroker(modules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func connect(t *testing.T, ctx context.Context, b *Broker, builder *lime.ClientBuilder) *lime.Client {
+	client, err := b.Connect(ctx, builder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestBroker_RouteMessage(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b := startBroker(t)
+	defer b.Close()
+	received := make(chan *lime.Message, 1)
+	bob := connect(t, ctx, b, b.ClientBuilder("bob").
+		MessagesHandlerFunc(func(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+			received <- msg
+			return nil
+		}))
+	defer bob.Close()
+	alice := connect(t, ctx, b, b.ClientBuilder("alice"))
+	defer alice.Close()
+	msg := createTextMessage("Hello Bob")
+	msg.To = lime.Node{Identity: lime.Identity{Name: "bob", Domain: BrokerDomain}}
+
+	// Act
+	err := alice.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case actual := <-received:
+		assert.Equal(t, msg.ID, actual.ID)
+		assert.Equal(t, msg.Content, actual.Content)
+		assert.Equal(t, lime.Identity{Name: "alice", Domain: BrokerDomain}, actual.From.Identity)
+		assert.NotEmpty(t, actual.From.Instance)
+	}
+}
+
+func TestBroker_RouteMessage_DestinationNotFound(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b := startBroker(t)
+	defer b.Close()
+	notifications := make(chan *lime.Notification, 1)
+	alice := connect(t, ctx, b, b.ClientBuilder("alice").
+		NotificationsHandlerFunc(func(ctx context.Context, not *lime.Notification) error {
+			notifications <- not
+			return nil
+		}))
+	defer alice.Close()
+	msg := createTextMessage("Hello?")
+	msg.To = lime.Node{Identity: lime.Identity{Name: "nobody", Domain: BrokerDomain}}
+
+	// Act
+	err := alice.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case not := <-notifications:
+		assert.Equal(t, msg.ID, not.ID)
+		assert.Equal(t, lime.NotificationEventFailed, not.Event)
+		assert.Equal(t, lime.ReasonCodeRoutingDestinationNotFound, not.Reason.Code)
+	}
+}
+
+func TestBroker_RouteCommand(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b := startBroker(t)
+	defer b.Close()
+	bob := connect(t, ctx, b, b.ClientBuilder("bob").AutoReplyPings())
+	defer bob.Close()
+	alice := connect(t, ctx, b, b.ClientBuilder("alice"))
+	defer alice.Close()
+	cmd := &lime.RequestCommand{}
+	cmd.SetURIString(lime.PingPath).
+		SetMethod(lime.CommandMethodGet).
+		SetTo(lime.Node{Identity: lime.Identity{Name: "bob", Domain: BrokerDomain}}).
+		SetID(lime.NewEnvelopeID())
+
+	// Act
+	respCmd, err := alice.ProcessCommand(ctx, cmd)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.NotNil(t, respCmd) {
+		assert.Equal(t, lime.CommandStatusSuccess, respCmd.Status)
+		assert.Equal(t, lime.Identity{Name: "bob", Domain: BrokerDomain}, respCmd.From.Identity)
+	}
+}
+
+func TestBroker_Modules(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	b := startBroker(t, echoModule)
+	defer b.Close()
+	received := make(chan *lime.Message, 1)
+	alice := connect(t, ctx, b, b.ClientBuilder("alice").
+		MessagesHandlerFunc(func(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+			received <- msg
+			return nil
+		}))
+	defer alice.Close()
+	ping := &lime.RequestCommand{}
+	ping.SetURIString(lime.PingPath).
+		SetMethod(lime.CommandMethodGet).
+		SetID(lime.NewEnvelopeID())
+	msg := createTextMessage("Echo")
+
+	// Act
+	respCmd, err := alice.ProcessCommand(ctx, ping)
+	sendErr := alice.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, lime.CommandStatusSuccess, respCmd.Status)
+	assert.NoError(t, sendErr)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case actual := <-received:
+		assert.Equal(t, msg.Content, actual.Content)
+	}
+}
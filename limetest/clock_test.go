@@ -0,0 +1,95 @@
+package limetest
+
+import (
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock_Advance(t *testing.T) {
+	// Arrange
+	clock := NewFakeClock(epoch)
+	timer1 := clock.NewTimer(2 * time.Second)
+	timer2 := clock.NewTimer(time.Second)
+
+	// Act
+	clock.Advance(time.Second)
+
+	// Assert
+	assert.Equal(t, epoch.Add(time.Second), clock.Now())
+	assert.Equal(t, epoch.Add(time.Second), <-timer2.C())
+	assert.Empty(t, timer1.C())
+	assert.Equal(t, 1, clock.Timers())
+	clock.Advance(time.Second)
+	assert.Equal(t, epoch.Add(2*time.Second), <-timer1.C())
+	assert.Equal(t, 0, clock.Timers())
+}
+
+func TestFakeClock_NewTimer_NonPositive(t *testing.T) {
+	// Arrange
+	clock := NewFakeClock(epoch)
+
+	// Act
+	timer := clock.NewTimer(0)
+
+	// Assert
+	assert.Equal(t, epoch, <-timer.C())
+	assert.False(t, timer.Stop())
+}
+
+func TestFakeClock_Stop(t *testing.T) {
+	// Arrange
+	clock := NewFakeClock(epoch)
+	timer := clock.NewTimer(time.Second)
+
+	// Act
+	stopped := timer.Stop()
+
+	// Assert
+	assert.True(t, stopped)
+	clock.Advance(time.Minute)
+	assert.Empty(t, timer.C())
+	assert.False(t, timer.Stop())
+}
+
+func TestFakeClock_BlockUntil(t *testing.T) {
+	// Arrange
+	clock := NewFakeClock(epoch)
+	fired := make(chan time.Time)
+	go func() {
+		timer := clock.NewTimer(time.Minute)
+		fired <- <-timer.C()
+	}()
+
+	// Act
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	// Assert
+	assert.Equal(t, epoch.Add(time.Minute), <-fired)
+}
+
+func TestFakeClock_NotificationTracker(t *testing.T) {
+	// Arrange
+	clock := NewFakeClock(epoch)
+	tracker := lime.NewNotificationTracker()
+	tracker.SetClock(clock)
+	msg := &lime.Message{}
+	msg.ID = "1"
+	tracker.Track(msg)
+	clock.Advance(3 * time.Second)
+	not := &lime.Notification{Event: lime.NotificationEventReceived}
+	not.ID = "1"
+
+	// Act
+	tracker.Observe(not)
+
+	// Assert
+	status, ok := tracker.Status("1")
+	assert.True(t, ok)
+	assert.Equal(t, epoch, status.SentAt)
+	assert.Equal(t, epoch.Add(3*time.Second), status.Notifications[0].ReceivedAt)
+}
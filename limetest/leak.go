@@ -0,0 +1,40 @@
+package limetest
+
+import (
+	"bytes"
+	"github.com/phonero/lime"
+	"testing"
+	"time"
+)
+
+// LeakTimeout is the time that VerifyNoLeaks waits for the lime goroutines to stop.
+var LeakTimeout = time.Second
+
+// VerifyNoLeaks fails the test if there are lime goroutines still running after LeakTimeout, like the channel
+// receivers, dispatcher workers and the envelope handlers started by them, which usually means that a client,
+// server or channel was not closed. It should be deferred before the clients and servers of the test, so it runs
+// after they are closed:
+//
+//	defer limetest.VerifyNoLeaks(t)
+//	defer client.Close()
+func VerifyNoLeaks(t testing.TB) {
+	t.Helper()
+	var buf bytes.Buffer
+	deadline := time.Now().Add(LeakTimeout)
+	for wait := time.Millisecond; ; wait = min(2*wait, 100*time.Millisecond) {
+		buf.Reset()
+		n, err := lime.WriteGoroutines(&buf)
+		if err != nil {
+			t.Errorf("limetest: goroutines profile: %v", err)
+			return
+		}
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("limetest: found %d leaked lime goroutines:\n\n%s", n, buf.String())
+			return
+		}
+		time.Sleep(wait)
+	}
+}
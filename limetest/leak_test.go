@@ -0,0 +1,88 @@
+package limetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+// recordingT records the test failures, for verifying the failing expectations.
+type recordingT struct {
+	testing.TB
+	failed bool
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.failed = true
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+}
+
+func TestVerifyNoLeaks_Closed(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := lime.InProcessAddr("limetest-leaks")
+	srv := lime.NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		Build()
+	go func() {
+		_ = srv.ListenAndServe()
+	}()
+	// The client retries the connection until the server is listening
+	client := lime.NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		Build()
+	err := client.SendMessage(ctx, &lime.Message{
+		Envelope: lime.Envelope{To: lime.Node{Identity: lime.Identity{Name: "postmaster", Domain: "localhost"}}},
+		Type:     lime.MediaTypeTextPlain(),
+		Content:  lime.TextDocument("Hello"),
+	})
+	assert.NoError(t, err)
+	n, _ := lime.WriteGoroutines(io.Discard)
+	assert.NotZero(t, n)
+	_ = client.Close()
+	_ = srv.Close()
+	rt := &recordingT{TB: t}
+
+	// Act
+	VerifyNoLeaks(rt)
+
+	// Assert
+	assert.False(t, rt.failed)
+}
+
+func TestVerifyNoLeaks_NotClosed(t *testing.T) {
+	// Arrange
+	wp := lime.NewWorkerPool(1, 1)
+	defer wp.Close()
+	done := make(chan struct{})
+	_ = wp.Dispatch(context.Background(), "", func() {
+		close(done)
+	})
+	<-done
+	timeout := LeakTimeout
+	LeakTimeout = 50 * time.Millisecond
+	defer func() {
+		LeakTimeout = timeout
+	}()
+	rt := &recordingT{TB: t}
+
+	// Act
+	VerifyNoLeaks(rt)
+
+	// Assert
+	assert.True(t, rt.failed)
+}
@@ -0,0 +1,385 @@
+package limetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/phonero/lime"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	// PipelineTimeout is the time that Scenario.ExpectSent waits for the expected envelopes.
+	PipelineTimeout = time.Second
+	// PipelineQuietPeriod is the time that Scenario.ExpectSent waits for unexpected envelopes, after receiving the
+	// expected ones.
+	PipelineQuietPeriod = 50 * time.Millisecond
+)
+
+// Module registers handlers and middlewares in the lime.EnvelopeMux of the pipeline, like the ones of a server or
+// client module, or a router.
+type Module func(mux *lime.EnvelopeMux)
+
+// Matcher checks an envelope sent by the pipeline, returning an error describing the mismatch, if any.
+// The envelope is a *lime.Message, *lime.Notification, *lime.RequestCommand or *lime.ResponseCommand.
+type Matcher func(e any) error
+
+// Scenario is an envelope pipeline test, which processes the inbound envelopes through the modules of a server
+// session and asserts the envelopes sent back by them:
+//
+//	limetest.Given(msg).
+//		WhenProcessedBy(echoModule).
+//		ExpectSent(t, limetest.TextMessage("Hello world"))
+type Scenario struct {
+	inbound []any
+	modules []Module
+}
+
+// Given creates a Scenario with the envelopes received by the pipeline, in order. The envelopes should be
+// *lime.Message, *lime.Notification, *lime.RequestCommand or *lime.ResponseCommand values.
+func Given(envelopes ...any) *Scenario {
+	for _, e := range envelopes {
+		switch e.(type) {
+		case *lime.Message, *lime.Notification, *lime.RequestCommand, *lime.ResponseCommand:
+		default:
+			panic(fmt.Errorf("unsupported envelope type %T", e))
+		}
+	}
+	return &Scenario{inbound: envelopes}
+}
+
+// WhenProcessedBy adds the modules to the pipeline, in the registration order.
+func (s *Scenario) WhenProcessedBy(modules ...Module) *Scenario {
+	s.modules = append(s.modules, modules...)
+	return s
+}
+
+// ExpectSent processes the inbound envelopes and fails the test if the envelopes sent by the pipeline do not match
+// the matchers, in order. It waits up to PipelineTimeout for the expected envelopes and PipelineQuietPeriod for
+// any other, so calling it without matchers asserts that nothing is sent.
+func (s *Scenario) ExpectSent(t testing.TB, matchers ...Matcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), PipelineTimeout)
+	defer cancel()
+
+	p, err := startPipeline(ctx, s.modules)
+	if err != nil {
+		t.Fatalf("limetest: start pipeline: %v", err)
+	}
+	defer p.close()
+
+	go func() {
+		for _, e := range s.inbound {
+			if err := p.send(ctx, e); err != nil {
+				p.errs <- err
+				return
+			}
+		}
+	}()
+
+	sent := p.collect(ctx, len(matchers))
+	select {
+	case err := <-p.errs:
+		t.Errorf("limetest: pipeline: %v", err)
+	default:
+	}
+
+	for i, m := range matchers {
+		if i >= len(sent) {
+			t.Errorf("limetest: expected %d sent envelopes, got %d", len(matchers), len(sent))
+			return
+		}
+		if err := m(sent[i]); err != nil {
+			t.Errorf("limetest: sent envelope %d: %v", i, err)
+		}
+	}
+	for _, e := range sent[len(matchers):] {
+		t.Errorf("limetest: unexpected sent envelope %T %+v", e, e)
+	}
+}
+
+// pipeline is an established session whose server side is handled by the modules.
+type pipeline struct {
+	listener lime.TransportListener
+	client   *lime.ClientChannel
+	server   *lime.ServerChannel
+	tap      *lime.Tap
+	sent     chan any // sent are the envelopes sent by the server, in order
+	cancel   context.CancelFunc
+	done     chan struct{}
+	drained  chan struct{}
+	errs     chan error
+}
+
+const (
+	pipelineBufferSize = 16
+	pipelineSentSize   = 1024
+)
+
+func startPipeline(ctx context.Context, modules []Module) (*pipeline, error) {
+	addr := lime.InProcessAddr("limetest-" + lime.NewEnvelopeID())
+	listener := lime.NewInProcessTransportListener(addr)
+	if err := listener.Listen(ctx, addr); err != nil {
+		return nil, err
+	}
+	clientTransport, err := lime.DialInProcess(addr, pipelineBufferSize)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	serverTransport, err := listener.Accept(ctx)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	serverNode := lime.Node{Identity: lime.Identity{Name: "postmaster", Domain: "localhost"}, Instance: "limetest"}
+	p := &pipeline{
+		listener: listener,
+		client:   lime.NewClientChannel(clientTransport, pipelineBufferSize),
+		server:   lime.NewServerChannel(serverTransport, pipelineBufferSize, serverNode, lime.NewEnvelopeID()),
+		sent:     make(chan any, pipelineSentSize),
+		done:     make(chan struct{}),
+		drained:  make(chan struct{}),
+		errs:     make(chan error, 2),
+	}
+	// The tap mirrors the sent envelopes in order, unlike the client channel, which receives each envelope type in
+	// a different Go channel
+	p.tap = lime.NewTap(
+		lime.TapSinkFunc(func(ctx context.Context, r *lime.TapRecord) error {
+			p.sent <- r.Envelope
+			return nil
+		}),
+		pipelineSentSize,
+		func(r *lime.TapRecord) bool { return r.Direction == lime.TapDirectionSent })
+	p.server.SetTap(p.tap)
+
+	established := make(chan error, 1)
+	go func() {
+		established <- p.server.EstablishSession(
+			ctx,
+			[]lime.SessionCompression{lime.SessionCompressionNone},
+			[]lime.SessionEncryption{lime.SessionEncryptionNone},
+			[]lime.AuthenticationScheme{lime.AuthenticationSchemeGuest},
+			func(context.Context, lime.Identity, lime.Authentication) (*lime.AuthenticationResult, error) {
+				return lime.MemberAuthenticationResult(), nil
+			},
+			func(_ context.Context, node lime.Node, _ *lime.ServerChannel) (lime.Node, error) {
+				return node, nil
+			})
+	}()
+	_, err = p.client.EstablishSession(
+		ctx,
+		func([]lime.SessionCompression) lime.SessionCompression { return lime.SessionCompressionNone },
+		func([]lime.SessionEncryption) lime.SessionEncryption { return lime.SessionEncryptionNone },
+		lime.Identity{Name: "limetest", Domain: "localhost"},
+		func([]lime.AuthenticationScheme, lime.Authentication) lime.Authentication {
+			return &lime.GuestAuthentication{}
+		},
+		"default")
+	if err == nil {
+		err = <-established
+	}
+	if err == nil && !p.server.Established() {
+		err = errors.New("session not established")
+	}
+	if err != nil {
+		close(p.done)
+		close(p.drained)
+		p.close()
+		return nil, err
+	}
+	go p.drain()
+
+	mux := &lime.EnvelopeMux{}
+	for _, m := range modules {
+		m(mux)
+	}
+	listenCtx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go func() {
+		defer close(p.done)
+		if err := mux.ListenServer(listenCtx, p.server); err != nil && listenCtx.Err() == nil {
+			p.errs <- err
+		}
+	}()
+	return p, nil
+}
+
+func (p *pipeline) send(ctx context.Context, e any) error {
+	switch e := e.(type) {
+	case *lime.Message:
+		return p.client.SendMessage(ctx, e)
+	case *lime.Notification:
+		return p.client.SendNotification(ctx, e)
+	case *lime.RequestCommand:
+		return p.client.SendRequestCommand(ctx, e)
+	case *lime.ResponseCommand:
+		return p.client.SendResponseCommand(ctx, e)
+	default:
+		return fmt.Errorf("unsupported envelope type %T", e)
+	}
+}
+
+// drain discards the envelopes received by the client channel, which are collected from the tap.
+func (p *pipeline) drain() {
+	defer close(p.drained)
+	for {
+		var ok bool
+		select {
+		case _, ok = <-p.client.MsgChan():
+		case _, ok = <-p.client.NotChan():
+		case _, ok = <-p.client.ReqCmdChan():
+		case _, ok = <-p.client.RespCmdChan():
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// collect receives the envelopes sent by the server until the expected count is reached, and then until the quiet
+// period passes without more envelopes.
+func (p *pipeline) collect(ctx context.Context, expected int) []any {
+	var sent []any
+	for {
+		var timeout <-chan time.Time
+		if len(sent) >= expected {
+			timeout = time.After(PipelineQuietPeriod)
+		}
+		select {
+		case <-ctx.Done():
+			return sent
+		case <-timeout:
+			return sent
+		case e := <-p.sent:
+			sent = append(sent, e)
+		}
+	}
+}
+
+func (p *pipeline) close() {
+	_ = p.client.Close()
+	_ = p.server.Close()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	<-p.done
+	<-p.drained
+	_ = p.tap.Close()
+	_ = p.listener.Close()
+}
+
+// MessageThat matches the messages that satisfy the predicate.
+func MessageThat(predicate func(msg *lime.Message) bool) Matcher {
+	return func(e any) error {
+		msg, ok := e.(*lime.Message)
+		if !ok {
+			return fmt.Errorf("expected a message, got %T", e)
+		}
+		if !predicate(msg) {
+			return fmt.Errorf("message %+v does not match", msg)
+		}
+		return nil
+	}
+}
+
+// TextMessage matches the messages with the text content.
+func TextMessage(text string) Matcher {
+	return func(e any) error {
+		msg, ok := e.(*lime.Message)
+		if !ok {
+			return fmt.Errorf("expected a message, got %T", e)
+		}
+		d, ok := msg.Content.(*lime.TextDocument)
+		if !ok {
+			return fmt.Errorf("expected a text content, got %T", msg.Content)
+		}
+		if string(*d) != text {
+			return fmt.Errorf("expected the text %q, got %q", text, string(*d))
+		}
+		return nil
+	}
+}
+
+// NotificationThat matches the notifications that satisfy the predicate.
+func NotificationThat(predicate func(not *lime.Notification) bool) Matcher {
+	return func(e any) error {
+		not, ok := e.(*lime.Notification)
+		if !ok {
+			return fmt.Errorf("expected a notification, got %T", e)
+		}
+		if !predicate(not) {
+			return fmt.Errorf("notification %+v does not match", not)
+		}
+		return nil
+	}
+}
+
+// NotificationEvent matches the notifications with the event.
+func NotificationEvent(event lime.NotificationEvent) Matcher {
+	return func(e any) error {
+		not, ok := e.(*lime.Notification)
+		if !ok {
+			return fmt.Errorf("expected a notification, got %T", e)
+		}
+		if not.Event != event {
+			return fmt.Errorf("expected the %v event, got %v", event, not.Event)
+		}
+		return nil
+	}
+}
+
+// RequestCommandThat matches the request commands that satisfy the predicate.
+func RequestCommandThat(predicate func(cmd *lime.RequestCommand) bool) Matcher {
+	return func(e any) error {
+		cmd, ok := e.(*lime.RequestCommand)
+		if !ok {
+			return fmt.Errorf("expected a request command, got %T", e)
+		}
+		if !predicate(cmd) {
+			return fmt.Errorf("request command %+v does not match", cmd)
+		}
+		return nil
+	}
+}
+
+// ResponseCommandThat matches the response commands that satisfy the predicate.
+func ResponseCommandThat(predicate func(cmd *lime.ResponseCommand) bool) Matcher {
+	return func(e any) error {
+		cmd, ok := e.(*lime.ResponseCommand)
+		if !ok {
+			return fmt.Errorf("expected a response command, got %T", e)
+		}
+		if !predicate(cmd) {
+			return fmt.Errorf("response command %+v does not match", cmd)
+		}
+		return nil
+	}
+}
+
+// ResponseStatus matches the response commands with the status.
+func ResponseStatus(status lime.CommandStatus) Matcher {
+	return func(e any) error {
+		cmd, ok := e.(*lime.ResponseCommand)
+		if !ok {
+			return fmt.Errorf("expected a response command, got %T", e)
+		}
+		if cmd.Status != status {
+			return fmt.Errorf("expected the %v status, got %v", status, cmd.Status)
+		}
+		return nil
+	}
+}
+
+// Equal matches the envelopes deeply equal to the expected one.
+func Equal(expected any) Matcher {
+	return func(e any) error {
+		if !reflect.DeepEqual(expected, e) {
+			return fmt.Errorf("expected %T %+v, got %T %+v", expected, expected, e, e)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package limetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/phonero/lime"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func createTextMessage(text string) *lime.Message {
+	msg := &lime.Message{}
+	d := lime.TextDocument(text)
+	msg.SetContent(&d).SetNewEnvelopeID()
+	return msg
+}
+
+func echoModule(mux *lime.EnvelopeMux) {
+	mux.MessageHandlerFunc(
+		func(msg *lime.Message) bool { return true },
+		func(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+			if err := s.SendNotification(ctx, msg.Notification(lime.NotificationEventReceived)); err != nil {
+				return err
+			}
+			return s.SendMessage(ctx, msg.Reply(msg.Content))
+		})
+}
+
+func pingModule(mux *lime.EnvelopeMux) {
+	mux.RequestCommandHandlerFunc(
+		func(cmd *lime.RequestCommand) bool { return cmd.URI != nil && cmd.URI.Path() == "/ping" },
+		func(ctx context.Context, cmd *lime.RequestCommand, s lime.Sender) error {
+			return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+		})
+}
+
+func TestScenario_ExpectSent(t *testing.T) {
+	// Arrange
+	msg1 := createTextMessage("Hello")
+	msg2 := createTextMessage("World")
+
+	// Act & Assert
+	Given(msg1, msg2).
+		WhenProcessedBy(echoModule).
+		ExpectSent(t,
+			NotificationEvent(lime.NotificationEventReceived),
+			TextMessage("Hello"),
+			NotificationEvent(lime.NotificationEventReceived),
+			MessageThat(func(msg *lime.Message) bool { return msg.ReplyTo() == msg2.ID }))
+}
+
+func TestScenario_ExpectSent_Modules(t *testing.T) {
+	// Arrange
+	cmd := &lime.RequestCommand{}
+	cmd.SetURIString("/ping").SetMethod(lime.CommandMethodGet).SetNewEnvelopeID()
+
+	// Act & Assert
+	Given(cmd).
+		WhenProcessedBy(echoModule, pingModule).
+		ExpectSent(t, ResponseStatus(lime.CommandStatusSuccess))
+}
+
+func TestScenario_ExpectSent_Nothing(t *testing.T) {
+	// Arrange
+	not := &lime.Notification{Event: lime.NotificationEventConsumed}
+	not.SetNewEnvelopeID()
+
+	// Act & Assert
+	Given(not).
+		WhenProcessedBy(echoModule).
+		ExpectSent(t)
+}
+
+func TestScenario_ExpectSent_Mismatch(t *testing.T) {
+	// Arrange
+	rt := &recordingT{TB: t}
+
+	// Act
+	Given(createTextMessage("Hello")).
+		WhenProcessedBy(echoModule).
+		ExpectSent(rt, TextMessage("Hello"))
+
+	// Assert
+	if assert.Len(t, rt.errors, 2) {
+		assert.Contains(t, rt.errors[0], "expected a message, got *lime.Notification")
+		assert.Contains(t, rt.errors[1], "unexpected sent envelope *lime.Message")
+	}
+}
+
+func TestScenario_ExpectSent_Missing(t *testing.T) {
+	// Arrange
+	rt := &recordingT{TB: t}
+	defer func(timeout time.Duration) { PipelineTimeout = timeout }(PipelineTimeout)
+	PipelineTimeout = 100 * time.Millisecond
+
+	// Act
+	Given(createTextMessage("Hello")).
+		ExpectSent(rt, TextMessage("Hello"))
+
+	// Assert
+	if assert.Len(t, rt.errors, 1) {
+		assert.Contains(t, rt.errors[0], "expected 1 sent envelopes, got 0")
+	}
+}
+
+func TestScenario_ExpectSent_HandlerError(t *testing.T) {
+	// Arrange
+	rt := &recordingT{TB: t}
+	failing := func(mux *lime.EnvelopeMux) {
+		mux.MessageHandlerFunc(
+			func(msg *lime.Message) bool { return true },
+			func(ctx context.Context, msg *lime.Message, s lime.Sender) error {
+				return fmt.Errorf("handler failed")
+			})
+	}
+
+	// Act
+	Given(createTextMessage("Hello")).
+		WhenProcessedBy(failing).
+		ExpectSent(rt)
+
+	// Assert
+	if assert.Len(t, rt.errors, 1) {
+		assert.Contains(t, rt.errors[0], "handler failed")
+	}
+}
+
+func TestGiven_UnsupportedEnvelope(t *testing.T) {
+	// Act & Assert
+	assert.Panics(t, func() {
+		Given(&lime.Session{})
+	})
+}
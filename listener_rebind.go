@@ -0,0 +1,116 @@
+package lime
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+)
+
+// ErrListenerNotFound is returned when there's no server listener bound to the specified address.
+var ErrListenerNotFound = errors.New("listener not found")
+
+// RebindListener moves the listener bound to the address to a new address, while the server is running.
+// A new listener of the same type and configuration starts accepting connections on the new address before the old
+// one is closed, so there's no moment without a listening socket.
+// The sessions accepted by the old listener are not affected and continue until they finish, which allows the
+// migration of the server port without downtime.
+func (srv *Server) RebindListener(addr net.Addr, newAddr net.Addr) error {
+	bl, ok := srv.findListener(addr)
+	if !ok {
+		return fmt.Errorf("rebind listener: %w", ErrListenerNotFound)
+	}
+
+	var listener TransportListener
+	switch l := bl.Listener.(type) {
+	case *tcpTransportListener:
+		l.mu.RLock()
+		config := l.TCPConfig
+		l.mu.RUnlock()
+		listener = NewTCPTransportListener(&config)
+	case *websocketTransportListener:
+		config := l.WebsocketConfig
+		listener = NewWebsocketTransportListener(&config)
+	case *inProcessTransportListener:
+		inProcAddr, ok := newAddr.(InProcessAddr)
+		if !ok {
+			return fmt.Errorf("rebind listener: invalid in process address type %T", newAddr)
+		}
+		listener = NewInProcessTransportListener(inProcAddr)
+	default:
+		return fmt.Errorf("rebind listener: unsupported listener type %T", bl.Listener)
+	}
+
+	if err := srv.ReplaceListener(addr, NewBoundListener(listener, newAddr)); err != nil {
+		return fmt.Errorf("rebind listener: %w", err)
+	}
+	return nil
+}
+
+// ReplaceListener starts accepting connections on a new listener and then closes the listener bound to the address.
+// Like in RebindListener, the sessions accepted by the replaced listener continue until they finish.
+// If the server is not running, the listener is only replaced in the server list.
+func (srv *Server) ReplaceListener(addr net.Addr, bl BoundListener) error {
+	if bl.Listener == nil {
+		panic("nil Listener")
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	i := srv.listenerIndex(addr)
+	if i < 0 {
+		return fmt.Errorf("replace listener: %w", ErrListenerNotFound)
+	}
+	old := srv.listeners[i]
+
+	if srv.shutdown == nil {
+		srv.listeners[i] = bl
+		return nil
+	}
+
+	if err := bl.Listener.Listen(srv.serveCtx, bl.Addr); err != nil {
+		return fmt.Errorf("replace listener: listen error: %w", err)
+	}
+
+	// The old listener is removed from the list before closing it, so its accept loop ends without stopping the server
+	srv.listeners[i] = bl
+	srv.serveGroup.Go(srv.acceptFunc(srv.serveCtx, bl.Listener))
+
+	if err := old.Listener.Close(); err != nil {
+		return fmt.Errorf("replace listener: close error: %w", err)
+	}
+	return nil
+}
+
+// boundListeners returns a copy of the current server listeners.
+func (srv *Server) boundListeners() []BoundListener {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return slices.Clone(srv.listeners)
+}
+
+func (srv *Server) findListener(addr net.Addr) (BoundListener, bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	i := srv.listenerIndex(addr)
+	if i < 0 {
+		return BoundListener{}, false
+	}
+	return srv.listeners[i], true
+}
+
+func (srv *Server) hasListener(listener TransportListener) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return slices.ContainsFunc(srv.listeners, func(bl BoundListener) bool {
+		return bl.Listener == listener
+	})
+}
+
+// listenerIndex returns the position of the listener bound to the address. It should be called with the lock held.
+func (srv *Server) listenerIndex(addr net.Addr) int {
+	return slices.IndexFunc(srv.listeners, func(bl BoundListener) bool {
+		return bl.Addr.Network() == addr.Network() && bl.Addr.String() == addr.String()
+	})
+}
@@ -0,0 +1,66 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestServer_RebindListener(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("rebind-old")
+	newAddr := InProcessAddr("rebind-new")
+	srv, _ := startReauthenticationServer(t, addr)
+	defer silentClose(srv)
+	oldClient := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(oldClient)
+
+	// Act
+	err := srv.RebindListener(addr, newAddr)
+
+	// Assert
+	assert.NoError(t, err)
+	newClient := establishInProcGuestSession(t, ctx, newAddr, "golang")
+	defer silentClose(newClient)
+	respCmd, err := newClient.ProcessCommand(ctx, createGetPingCommand())
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	respCmd, err = oldClient.ProcessCommand(ctx, createGetPingCommand())
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	_, err = DialInProcess(addr, 1)
+	assert.Error(t, err)
+	listeners := srv.boundListeners()
+	assert.Len(t, listeners, 1)
+	assert.Equal(t, newAddr, listeners[0].Addr)
+}
+
+func TestServer_RebindListener_NotFound(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("rebind-not-found")
+	srv, _ := startReauthenticationServer(t, addr)
+	defer silentClose(srv)
+
+	// Act
+	err := srv.RebindListener(InProcessAddr("rebind-other"), InProcessAddr("rebind-new"))
+
+	// Assert
+	assert.ErrorIs(t, err, ErrListenerNotFound)
+}
+
+func TestServer_ReplaceListener_NotListening(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("replace-not-listening")
+	newAddr := InProcessAddr("replace-not-listening-new")
+	srv := NewServer(NewServerConfig(), &EnvelopeMux{}, createBoundInProcTransportListener(addr))
+
+	// Act
+	err := srv.ReplaceListener(addr, createBoundInProcTransportListener(newAddr))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, newAddr, srv.boundListeners()[0].Addr)
+}
@@ -2,11 +2,13 @@ package lime
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"strings"
 )
 
+// MediaTypeWildcard matches any type or subtype in the MediaType.Matches method.
+const MediaTypeWildcard = "*"
+
 const (
 	MediaTypeText        = "text"
 	MediaTypeApplication = "application"
@@ -44,19 +46,41 @@ func (m MediaType) String() string {
 	return v
 }
 
-func ParseMediaType(s string) (MediaType, error) {
-	var suffix string
-	values := strings.Split(s, "+")
-	if len(values) > 1 {
-		suffix = values[1]
+// Matches indicates if the other media type is matched by the current one, which can contain wildcards in the
+// type and subtype, like 'application/*' and '*/*'. The comparison is case-insensitive.
+// A wildcard subtype matches any subtype and suffix, so 'application/*' matches 'application/vnd.lime.account+json'.
+func (m MediaType) Matches(other MediaType) bool {
+	if m.Type != MediaTypeWildcard && !strings.EqualFold(m.Type, other.Type) {
+		return false
 	}
-	values = strings.Split(values[0], "/")
-
-	if len(values) == 1 {
-		return MediaType{}, errors.New("invalid media type")
+	if m.Subtype != MediaTypeWildcard &&
+		(!strings.EqualFold(m.Subtype, other.Subtype) || !strings.EqualFold(m.Suffix, other.Suffix)) {
+		return false
 	}
+	return true
+}
+
+// EqualFold indicates if the media types are equal, ignoring the case of the type, subtype and suffix.
+func (m MediaType) EqualFold(other MediaType) bool {
+	return strings.EqualFold(m.Type, other.Type) &&
+		strings.EqualFold(m.Subtype, other.Subtype) &&
+		strings.EqualFold(m.Suffix, other.Suffix)
+}
 
-	return MediaType{values[0], values[1], suffix}, nil
+// IsWildcard indicates if the media type has a wildcard type or subtype.
+func (m MediaType) IsWildcard() bool {
+	return m.Type == MediaTypeWildcard || m.Subtype == MediaTypeWildcard
+}
+
+// ParseMediaType parses a MIME type in the 'type/subtype+suffix' form.
+// The parameters, like in 'text/plain; charset=utf-8', are validated but not kept in the MediaType, so the media
+// types remain comparable. Use ParseContentType for reading them.
+func ParseMediaType(s string) (MediaType, error) {
+	c, err := ParseContentType(s)
+	if err != nil {
+		return MediaType{}, err
+	}
+	return c.MediaType, nil
 }
 
 func (m MediaType) MarshalText() ([]byte, error) {
@@ -72,6 +96,282 @@ func (m *MediaType) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// ContentType is a MediaType with its parameters, like 'text/plain; charset=utf-8'.
+type ContentType struct {
+	MediaType
+	// params holds the parameters in the canonical form, like 'charset=utf-8; format=flowed'.
+	params string
+}
+
+// NewContentType creates a ContentType for the media type, without parameters.
+func NewContentType(m MediaType) ContentType {
+	return ContentType{MediaType: m}
+}
+
+func (c ContentType) String() string {
+	if c.params == "" {
+		return c.MediaType.String()
+	}
+	return fmt.Sprintf("%v; %v", c.MediaType, c.params)
+}
+
+// Matches indicates if the other content type is matched by the current one. The media types are matched by the
+// MediaType.Matches method and the parameters of the current content type should be present in the other one with
+// the same values, ignoring the case, while the additional parameters of the other content type are ignored.
+func (c ContentType) Matches(other ContentType) bool {
+	if !c.MediaType.Matches(other.MediaType) {
+		return false
+	}
+	for _, p := range c.parameters() {
+		if v, ok := other.Parameter(p.name); !ok || !strings.EqualFold(v, p.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualFold indicates if the content types are equal, ignoring the case of the media types and the parameter
+// names. The parameters are compared regardless of their order.
+func (c ContentType) EqualFold(other ContentType) bool {
+	if !c.MediaType.EqualFold(other.MediaType) {
+		return false
+	}
+	params, otherParams := c.parameters(), other.parameters()
+	if len(params) != len(otherParams) {
+		return false
+	}
+	for _, p := range params {
+		if v, ok := other.Parameter(p.name); !ok || v != p.value {
+			return false
+		}
+	}
+	return true
+}
+
+// Parameter returns the value of the parameter with the specified name, which is case-insensitive.
+func (c ContentType) Parameter(name string) (string, bool) {
+	for _, p := range c.parameters() {
+		if strings.EqualFold(p.name, name) {
+			return p.value, true
+		}
+	}
+	return "", false
+}
+
+// Parameters returns the content type parameters, like the charset.
+// The returned map is a copy and changes on it are not reflected in the content type.
+func (c ContentType) Parameters() map[string]string {
+	params := c.parameters()
+	if len(params) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(params))
+	for _, p := range params {
+		values[p.name] = p.value
+	}
+	return values
+}
+
+// WithParameter returns a copy of the content type with the specified parameter value, replacing any existing
+// parameter with the same name.
+func (c ContentType) WithParameter(name, value string) ContentType {
+	if !isMediaTypeToken(name) {
+		panic(fmt.Sprintf("invalid parameter name %q", name))
+	}
+	params := c.parameters()
+	replaced := false
+	for i := range params {
+		if strings.EqualFold(params[i].name, name) {
+			params[i].value = value
+			replaced = true
+		}
+	}
+	if !replaced {
+		params = append(params, mediaTypeParam{name, value})
+	}
+	c.params = formatMediaTypeParams(params)
+	return c
+}
+
+func (c ContentType) parameters() []mediaTypeParam {
+	if c.params == "" {
+		return nil
+	}
+	// The canonical form is always valid
+	params, _ := parseMediaTypeParams(c.params)
+	return params
+}
+
+// ParseContentType parses a MIME type in the 'type/subtype+suffix; name=value' form.
+// The parameters are preserved, including the unknown ones.
+func ParseContentType(s string) (ContentType, error) {
+	base, rawParams, _ := strings.Cut(s, ";")
+	base = strings.TrimSpace(base)
+
+	t, subtype, ok := strings.Cut(base, "/")
+	if !ok {
+		return ContentType{}, fmt.Errorf("invalid media type %q: missing subtype", s)
+	}
+	if t == MediaTypeWildcard {
+		// The wildcard type is only valid with a wildcard subtype
+		if subtype != MediaTypeWildcard {
+			return ContentType{}, fmt.Errorf("invalid media type %q: invalid wildcard", s)
+		}
+	} else if !isMediaTypeName(t) {
+		return ContentType{}, fmt.Errorf("invalid media type %q: invalid type", s)
+	}
+
+	var suffix string
+	if subtype != MediaTypeWildcard {
+		if i := strings.LastIndex(subtype, "+"); i >= 0 {
+			subtype, suffix = subtype[:i], subtype[i+1:]
+			if !isMediaTypeName(suffix) {
+				return ContentType{}, fmt.Errorf("invalid media type %q: invalid suffix", s)
+			}
+		}
+		if !isMediaTypeName(subtype) {
+			return ContentType{}, fmt.Errorf("invalid media type %q: invalid subtype", s)
+		}
+	}
+
+	params, err := parseMediaTypeParams(rawParams)
+	if err != nil {
+		return ContentType{}, fmt.Errorf("invalid media type %q: %w", s, err)
+	}
+
+	return ContentType{
+		MediaType: MediaType{Type: t, Subtype: subtype, Suffix: suffix},
+		params:    formatMediaTypeParams(params),
+	}, nil
+}
+
+func (c ContentType) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *ContentType) UnmarshalText(text []byte) error {
+	contentType, err := ParseContentType(string(text))
+	if err != nil {
+		return err
+	}
+	*c = contentType
+	return nil
+}
+
+type mediaTypeParam struct {
+	name  string
+	value string
+}
+
+// parseMediaTypeParams parses a list of parameters separated by semicolons, with token or quoted string values.
+func parseMediaTypeParams(s string) ([]mediaTypeParam, error) {
+	var params []mediaTypeParam
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return params, nil
+		}
+
+		name, rest, ok := strings.Cut(s, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !isMediaTypeToken(name) {
+			return nil, fmt.Errorf("invalid parameter %q", s)
+		}
+		for _, p := range params {
+			if strings.EqualFold(p.name, name) {
+				return nil, fmt.Errorf("duplicate parameter %q", name)
+			}
+		}
+
+		rest = strings.TrimLeft(rest, " \t")
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				b.WriteByte(rest[i])
+			}
+			if i == len(rest) {
+				return nil, fmt.Errorf("unterminated quoted value for parameter %q", name)
+			}
+			value, rest = b.String(), rest[i+1:]
+		} else {
+			value, rest, _ = strings.Cut(rest, ";")
+			value = strings.TrimSpace(value)
+			rest = ";" + rest
+			if !isMediaTypeToken(value) {
+				return nil, fmt.Errorf("invalid value for parameter %q", name)
+			}
+		}
+
+		rest = strings.TrimLeft(rest, " \t")
+		if rest != "" && rest[0] != ';' {
+			return nil, fmt.Errorf("unexpected characters after parameter %q", name)
+		}
+		params = append(params, mediaTypeParam{name, value})
+		if rest != "" {
+			rest = rest[1:]
+		}
+		s = rest
+	}
+}
+
+func formatMediaTypeParams(params []mediaTypeParam) string {
+	var b strings.Builder
+	for i, p := range params {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(p.name)
+		b.WriteByte('=')
+		if isMediaTypeToken(p.value) {
+			b.WriteString(p.value)
+			continue
+		}
+		b.WriteByte('"')
+		for _, r := range p.value {
+			if r == '"' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// isMediaTypeName checks if the value is a restricted name, as defined by the RFC 6838.
+func isMediaTypeName(s string) bool {
+	if s == "" || len(s) > 127 {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case i > 0 && strings.ContainsRune("!#$&-^_.", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isMediaTypeToken checks if the value is a token, as defined by the RFC 2045.
+func isMediaTypeToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`()<>@,;:\"/[]?=`, r) {
+			return false
+		}
+	}
+	return true
+}
+
 // Unexported to avoid changing
 var mediaTypeApplicationJson = MediaType{MediaTypeApplication, "json", ""}
 var mediaTypeTextPlain = MediaType{MediaTypeText, "plain", ""}
@@ -97,9 +397,14 @@ func GetDocumentFactory(t MediaType) (func() Document, error) {
 	factory, ok := documentFactories[t]
 	if !ok {
 		// Use the default ones
-		if t.IsJson() {
+		switch {
+		case t.IsJson():
 			factory = documentFactories[mediaTypeApplicationJson]
-		} else {
+		case isBinaryMediaType(t):
+			factory = func() Document {
+				return &BinaryDocument{Type: t}
+			}
+		default:
 			factory = documentFactories[mediaTypeTextPlain]
 		}
 	}
@@ -0,0 +1,241 @@
+package lime
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseMediaType(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want MediaType
+	}{
+		{"Simple", "text/plain", MediaType{Type: "text", Subtype: "plain"}},
+		{"Suffix", "application/vnd.lime.account+json", MediaType{Type: "application", Subtype: "vnd.lime.account", Suffix: "json"}},
+		{"Parameter", "text/plain; charset=utf-8", MediaType{Type: "text", Subtype: "plain"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := ParseMediaType(tt.s)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, actual)
+		})
+	}
+}
+
+func TestParseContentType(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want ContentType
+	}{
+		{"Simple", "text/plain", ContentType{MediaType: MediaType{Type: "text", Subtype: "plain"}}},
+		{"Parameter", "text/plain; charset=utf-8", ContentType{MediaType: MediaType{Type: "text", Subtype: "plain"}, params: "charset=utf-8"}},
+		{"Parameters", "text/plain;charset=utf-8;format=flowed", ContentType{MediaType: MediaType{Type: "text", Subtype: "plain"}, params: "charset=utf-8; format=flowed"}},
+		{"QuotedParameter", `text/plain; title="a \"b\"; c"`, ContentType{MediaType: MediaType{Type: "text", Subtype: "plain"}, params: `title="a \"b\"; c"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual, err := ParseContentType(tt.s)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, actual)
+		})
+	}
+}
+
+func TestParseMediaType_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"text",
+		"a+b/c",
+		"text/",
+		"/plain",
+		"application/json+",
+		"text/pl ain",
+		"text/plain; charset",
+		"text/plain; charset=",
+		"text/plain; charset=utf-8; charset=ascii",
+		`text/plain; title="unterminated`,
+		`text/plain; title="a" b`,
+	}
+	for _, s := range tests {
+		t.Run(s, func(t *testing.T) {
+			// Act
+			_, err := ParseMediaType(s)
+
+			// Assert
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestContentType_String_RoundTrip(t *testing.T) {
+	tests := []string{
+		"text/plain",
+		"application/vnd.lime.account+json",
+		"text/plain; charset=utf-8; x-unknown=value",
+		`text/plain; title="hello world"`,
+	}
+	for _, s := range tests {
+		t.Run(s, func(t *testing.T) {
+			// Arrange
+			c, err := ParseContentType(s)
+			assert.NoError(t, err)
+
+			// Act
+			actual := c.String()
+
+			// Assert
+			assert.Equal(t, s, actual)
+		})
+	}
+}
+
+func TestContentType_Parameter(t *testing.T) {
+	// Arrange
+	c, _ := ParseContentType("text/plain; Charset=utf-8")
+
+	// Act
+	value, ok := c.Parameter("charset")
+	_, okMissing := c.Parameter("format")
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, "utf-8", value)
+	assert.False(t, okMissing)
+	assert.Equal(t, map[string]string{"Charset": "utf-8"}, c.Parameters())
+}
+
+func TestContentType_WithParameter(t *testing.T) {
+	// Arrange
+	c := NewContentType(MediaTypeTextPlain())
+
+	// Act
+	c1 := c.WithParameter("charset", "utf-8")
+	c2 := c1.WithParameter("charset", "us-ascii").WithParameter("title", "a b")
+
+	// Assert
+	assert.Equal(t, "text/plain", c.String())
+	assert.Equal(t, "text/plain; charset=utf-8", c1.String())
+	assert.Equal(t, `text/plain; charset=us-ascii; title="a b"`, c2.String())
+	assert.Equal(t, MediaTypeTextPlain(), c2.MediaType)
+}
+
+func TestMediaType_UnmarshalJSON_WithParameters(t *testing.T) {
+	// Arrange
+	j := []byte(`{"type":"text/plain; charset=utf-8","content":"Hello"}`)
+	var msg Message
+
+	// Act
+	err := json.Unmarshal(j, &msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, MediaTypeTextPlain(), msg.Type)
+	assert.Equal(t, TextDocument("Hello"), *msg.Content.(*TextDocument))
+}
+
+func TestMediaType_Matches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		other   string
+		want    bool
+	}{
+		{"text/plain", "text/plain", true},
+		{"Text/Plain", "text/plain", true},
+		{"text/plain", "text/html", false},
+		{"application/*", "application/vnd.lime.account+json", true},
+		{"application/*", "text/plain", false},
+		{"*/*", "image/png", true},
+		{"application/vnd.lime.account+json", "application/vnd.lime.account", false},
+		{"application/vnd.lime.account+JSON", "application/vnd.lime.account+json", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.other, func(t *testing.T) {
+			// Arrange
+			pattern, err := ParseMediaType(tt.pattern)
+			assert.NoError(t, err)
+			other, err := ParseMediaType(tt.other)
+			assert.NoError(t, err)
+
+			// Act
+			actual := pattern.Matches(other)
+
+			// Assert
+			assert.Equal(t, tt.want, actual)
+		})
+	}
+}
+
+func TestContentType_Matches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		other   string
+		want    bool
+	}{
+		{"text/plain; charset=utf-8", "text/plain; charset=UTF-8; format=flowed", true},
+		{"text/plain; charset=utf-8", "text/plain", false},
+		{"text/plain", "text/plain; charset=utf-8", true},
+		{"text/*; charset=utf-8", "text/html; charset=utf-8", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.other, func(t *testing.T) {
+			// Arrange
+			pattern, err := ParseContentType(tt.pattern)
+			assert.NoError(t, err)
+			other, err := ParseContentType(tt.other)
+			assert.NoError(t, err)
+
+			// Act
+			actual := pattern.Matches(other)
+
+			// Assert
+			assert.Equal(t, tt.want, actual)
+		})
+	}
+}
+
+func TestMediaType_EqualFold(t *testing.T) {
+	// Arrange
+	m1, _ := ParseMediaType("Application/Vnd.Lime.Account+Json")
+	m2, _ := ParseMediaType("application/vnd.lime.account+json")
+	m3, _ := ParseMediaType("application/vnd.lime.account")
+
+	// Act & Assert
+	assert.True(t, m1.EqualFold(m2))
+	assert.False(t, m1.EqualFold(m3))
+}
+
+func TestContentType_EqualFold(t *testing.T) {
+	// Arrange
+	c1, _ := ParseContentType("Application/Vnd.Lime.Account+Json; a=1; B=2")
+	c2, _ := ParseContentType("application/vnd.lime.account+json; b=2; a=1")
+	c3, _ := ParseContentType("application/vnd.lime.account+json; a=1")
+
+	// Act & Assert
+	assert.True(t, c1.EqualFold(c2))
+	assert.False(t, c1.EqualFold(c3))
+}
+
+func TestParseMediaType_Wildcard(t *testing.T) {
+	// Act
+	m1, err1 := ParseMediaType("*/*")
+	m2, err2 := ParseMediaType("application/*")
+	_, err3 := ParseMediaType("*/plain")
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.True(t, m1.IsWildcard())
+	assert.NoError(t, err2)
+	assert.Equal(t, MediaType{Type: "application", Subtype: "*"}, m2)
+	assert.Error(t, err3)
+	assert.False(t, MediaTypeTextPlain().IsWildcard())
+}
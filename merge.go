@@ -0,0 +1,173 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// MergePatch applies the patch JSON document to the target one, following the JSON merge patch semantics
+// (RFC 7396): the patch object members replace the target ones, the null members remove them and the nested objects
+// are merged recursively. If the patch is not an object, it replaces the target.
+// An empty target is considered as null.
+func MergePatch(target []byte, patch []byte) ([]byte, error) {
+	var t interface{}
+	if len(bytes.TrimSpace(target)) != 0 {
+		if err := unmarshalUseNumber(target, &t); err != nil {
+			return nil, fmt.Errorf("merge patch: invalid target: %w", err)
+		}
+	}
+	var p interface{}
+	if err := unmarshalUseNumber(patch, &p); err != nil {
+		return nil, fmt.Errorf("merge patch: invalid patch: %w", err)
+	}
+
+	b, err := json.Marshal(mergePatch(t, p))
+	if err != nil {
+		return nil, fmt.Errorf("merge patch: %w", err)
+	}
+	return b, nil
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{}, len(p))
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergePatch(t[name], value)
+		}
+	}
+	return t
+}
+
+func unmarshalUseNumber(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// MergeDocument returns a new document with the patch document merged into the target one, as in MergePatch.
+// The resulting document has the target media type, or the patch one if the target is nil.
+func MergeDocument(target Document, patch Document) (Document, error) {
+	if patch == nil {
+		panic("nil patch")
+	}
+	var t []byte
+	mediaType := patch.MediaType()
+	if target != nil {
+		var err error
+		if t, err = json.Marshal(target); err != nil {
+			return nil, fmt.Errorf("merge document: %w", err)
+		}
+		mediaType = target.MediaType()
+	}
+	p, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("merge document: %w", err)
+	}
+	return mergeDocumentPatch(t, p, mediaType)
+}
+
+func mergeDocumentPatch(t []byte, p []byte, mediaType MediaType) (Document, error) {
+	merged, err := MergePatch(t, p)
+	if err != nil {
+		return nil, fmt.Errorf("merge document: %w", err)
+	}
+	raw := json.RawMessage(merged)
+	d, err := UnmarshalDocument(&raw, mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("merge document: %w", err)
+	}
+	return d, nil
+}
+
+// receivedMergePatchKey is the annotation key of the resource of a received merge command as JSON, since the
+// decoded document can lose the null members that remove the target ones.
+type receivedMergePatchKey struct{}
+
+// annotateReceivedMergePatch attaches the received JSON resource to the envelope, if it is a merge command.
+func annotateReceivedMergePatch(cmd *Command, raw *json.RawMessage, method *CommandMethod) {
+	if method == nil || *method != CommandMethodMerge || raw == nil {
+		return
+	}
+	cmd.Annotate(receivedMergePatchKey{}, bytes.Clone(*raw))
+}
+
+// mergeCommandResource merges the resource of the merge command into the target document, using the resource JSON
+// as received if available.
+func mergeCommandResource(target Document, cmd *RequestCommand) (Document, error) {
+	p, ok := cmd.Annotation(receivedMergePatchKey{})
+	if !ok {
+		return MergeDocument(target, cmd.Resource)
+	}
+	var t []byte
+	mediaType := cmd.Resource.MediaType()
+	if target != nil {
+		var err error
+		if t, err = json.Marshal(target); err != nil {
+			return nil, fmt.Errorf("merge document: %w", err)
+		}
+		mediaType = target.MediaType()
+	}
+	return mergeDocumentPatch(t, p.([]byte), mediaType)
+}
+
+// ResourceGetter returns the current value of the resource addressed by the command, or nil if it doesn't exist.
+type ResourceGetter func(ctx context.Context, cmd *RequestCommand) (Document, error)
+
+// ResourceSetter stores the value of the resource addressed by the command.
+type ResourceSetter func(ctx context.Context, cmd *RequestCommand, d Document) error
+
+// RequestCommandMatches returns a RequestCommandPredicate that is satisfied by the commands with the specified method
+// and URI path.
+func RequestCommandMatches(method CommandMethod, path string) RequestCommandPredicate {
+	return func(cmd *RequestCommand) bool {
+		return cmd.Method == method && cmd.URI != nil && cmd.URI.Path() == path
+	}
+}
+
+// MergeHandlerFunc allows the registration of a handler for the merge commands with the specified URI path.
+// The command resource is merged as a patch into the current value of the resource, which is stored and returned in
+// the response, so the partial updates don't require sending the entire document.
+func (m *EnvelopeMux) MergeHandlerFunc(path string, get ResourceGetter, set ResourceSetter) {
+	if get == nil {
+		panic("nil getter")
+	}
+	if set == nil {
+		panic("nil setter")
+	}
+	m.RequestCommandHandlerFunc(RequestCommandMatches(CommandMethodMerge, path), mergeHandlerFunc(get, set))
+}
+
+func mergeHandlerFunc(get ResourceGetter, set ResourceSetter) RequestCommandHandlerFunc {
+	return func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+		if cmd.Resource == nil {
+			return s.SendResponseCommand(ctx, cmd.FailureResponse(InvalidArgumentReason("The merge resource is required")))
+		}
+
+		current, err := get(ctx, cmd)
+		if err != nil {
+			log.Printf("merge handler: %v", err)
+			return s.SendResponseCommand(ctx, cmd.FailureResponse(NewReason(ReasonCodeCommandProcessingError, "The resource could not be read")))
+		}
+		merged, err := mergeCommandResource(current, cmd)
+		if err != nil {
+			return s.SendResponseCommand(ctx, cmd.FailureResponse(InvalidArgumentReason("The merge resource could not be applied to the resource")))
+		}
+		if err := set(ctx, cmd, merged); err != nil {
+			log.Printf("merge handler: %v", err)
+			return s.SendResponseCommand(ctx, cmd.FailureResponse(NewReason(ReasonCodeCommandProcessingError, "The resource could not be stored")))
+		}
+		return s.SendResponseCommand(ctx, cmd.SuccessResponseWithResource(merged))
+	}
+}
@@ -0,0 +1,181 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Cases from the RFC 7396 appendix
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{``, `{"a":12345678901234567890}`, `{"a":12345678901234567890}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target+" "+tt.patch, func(t *testing.T) {
+			// Act
+			got, err := MergePatch([]byte(tt.target), []byte(tt.patch))
+
+			// Assert
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestMergePatch_InvalidPatch(t *testing.T) {
+	// Act
+	_, err := MergePatch([]byte(`{}`), []byte(`{`))
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestMergeDocument(t *testing.T) {
+	// Arrange
+	target := &JsonDocument{"name": "Andre", "address": map[string]interface{}{"city": "Belo Horizonte", "zip": "30000"}}
+	patch := &JsonDocument{"address": map[string]interface{}{"zip": nil}, "phone": "5531"}
+
+	// Act
+	merged, err := MergeDocument(target, patch)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, MediaTypeApplicationJson(), merged.MediaType())
+	assert.Equal(t, &JsonDocument{"name": "Andre", "address": map[string]interface{}{"city": "Belo Horizonte"}, "phone": "5531"}, merged)
+}
+
+func TestMergeDocument_NilTarget(t *testing.T) {
+	// Arrange
+	patch := &PublicKey{ID: "1", Key: "a2V5"}
+
+	// Act
+	merged, err := MergeDocument(nil, patch)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, patch, merged)
+}
+
+func createMergeCommand(resource Document) *RequestCommand {
+	cmd := &RequestCommand{}
+	cmd.SetURIString("/account").
+		SetMethod(CommandMethodMerge).
+		SetResource(resource).
+		SetID("merge-1")
+	return cmd
+}
+
+func TestEnvelopeMux_MergeHandlerFunc(t *testing.T) {
+	// Arrange
+	account := Document(&JsonDocument{"fullName": "Andre", "email": "andre@limeprotocol.org"})
+	mux := &EnvelopeMux{}
+	mux.MergeHandlerFunc(
+		"/account",
+		func(ctx context.Context, cmd *RequestCommand) (Document, error) {
+			return account, nil
+		},
+		func(ctx context.Context, cmd *RequestCommand, d Document) error {
+			account = d
+			return nil
+		})
+	cmd := createMergeCommand(&JsonDocument{"email": nil, "city": "Belo Horizonte"})
+	s := &responseRecorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := mux.handleRequestCommand(ctx, cmd, s)
+
+	// Assert
+	assert.NoError(t, err)
+	want := &JsonDocument{"fullName": "Andre", "city": "Belo Horizonte"}
+	assert.Equal(t, want, account)
+	if assert.Len(t, s.responses, 1) {
+		assert.Equal(t, CommandStatusSuccess, s.responses[0].Status)
+		assert.Equal(t, want, s.responses[0].Resource)
+	}
+}
+
+func TestEnvelopeMux_MergeHandlerFunc_SetError(t *testing.T) {
+	// Arrange
+	mux := &EnvelopeMux{}
+	mux.MergeHandlerFunc(
+		"/account",
+		func(ctx context.Context, cmd *RequestCommand) (Document, error) {
+			return nil, nil
+		},
+		func(ctx context.Context, cmd *RequestCommand, d Document) error {
+			return errors.New("storage unavailable")
+		})
+	cmd := createMergeCommand(&JsonDocument{"city": "Belo Horizonte"})
+	s := &responseRecorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := mux.handleRequestCommand(ctx, cmd, s)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, s.responses, 1) {
+		assert.Equal(t, CommandStatusFailure, s.responses[0].Status)
+		assert.Equal(t, "The resource could not be stored", s.responses[0].Reason.Description)
+	}
+}
+
+func TestEnvelopeMux_MergeHandlerFunc_ReceivedResource(t *testing.T) {
+	// Arrange
+	RegisterDocumentFactory(func() Document {
+		return &testJsonDocument{}
+	})
+	var current Document = createTestJsonDocument()
+	mux := &EnvelopeMux{}
+	mux.MergeHandlerFunc(
+		"/account",
+		func(ctx context.Context, cmd *RequestCommand) (Document, error) {
+			return current, nil
+		},
+		func(ctx context.Context, cmd *RequestCommand, d Document) error {
+			current = d
+			return nil
+		})
+	e, err := UnmarshalTransportEnvelope([]byte(`{"id":"merge-1","method":"merge","uri":"/account","type":"application/x-lime-test+json","resource":{"property1":"changed","property3":{"subproperty1":null}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &responseRecorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err = mux.handleRequestCommand(ctx, e.(*RequestCommand), s)
+
+	// Assert
+	assert.NoError(t, err)
+	want := createTestJsonDocument()
+	want.Property1 = "changed"
+	want.Property3 = map[string]interface{}{}
+	assert.Equal(t, want, current)
+}
@@ -2,7 +2,6 @@ package lime
 
 import (
 	"encoding/json"
-	"errors"
 )
 
 // Message encapsulates a document for transport between nodes in a network.
@@ -25,7 +24,7 @@ func (msg *Message) MarshalJSON() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(raw)
+	return marshalRawEnvelope(envelopeKindMessage, raw)
 }
 
 func (msg *Message) UnmarshalJSON(b []byte) error {
@@ -52,11 +51,11 @@ func (msg *Message) toRawEnvelope() (*rawEnvelope, error) {
 	}
 
 	if msg.Content == nil {
-		return nil, errors.New("message content is required")
+		return nil, newEnvelopeError(envelopeKindMessage, msg.ID, "content", ErrFieldRequired)
 	}
 	b, err := json.Marshal(msg.Content)
 	if err != nil {
-		return nil, err
+		return nil, newEnvelopeError(envelopeKindMessage, msg.ID, "content", err)
 	}
 	content := json.RawMessage(b)
 
@@ -74,26 +73,29 @@ func (msg *Message) populate(raw *rawEnvelope) error {
 
 	// Create the document type instance and unmarshal the json To it
 	if raw.Type == nil {
-		return errors.New("message type is required")
+		return newEnvelopeError(envelopeKindMessage, raw.ID, "type", ErrFieldRequired)
 	}
 
 	if raw.Content == nil {
-		return errors.New("message content is required")
+		return newEnvelopeError(envelopeKindMessage, raw.ID, "content", ErrFieldRequired)
 	}
 
-	document, err := UnmarshalDocument(raw.Content, *raw.Type)
+	document, err := unmarshalRawDocument(raw, raw.Content, *raw.Type)
 	if err != nil {
-		return err
+		return newEnvelopeError(envelopeKindMessage, raw.ID, "content", err)
 	}
 
 	msg.Type = *raw.Type
 	msg.Content = document
+	if raw.streamed == nil {
+		annotateReceivedContentHash(&msg.Envelope, raw.Content)
+	}
 	return nil
 }
 
 // Notification creates a notification for the current message.
 func (msg *Message) Notification(event NotificationEvent) *Notification {
-	return &Notification{
+	not := &Notification{
 		Envelope: Envelope{
 			ID:   msg.ID,
 			From: msg.To,
@@ -101,6 +103,10 @@ func (msg *Message) Notification(event NotificationEvent) *Notification {
 		},
 		Event: event,
 	}
+	if id := msg.ConversationID(); id != "" {
+		not.SetConversationID(id)
+	}
+	return not
 }
 
 // FailedNotification creates a notification for the current message with
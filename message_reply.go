@@ -0,0 +1,179 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// MetadataKeyReplyTo is the metadata key that holds the identifier of the message that the envelope replies to.
+const MetadataKeyReplyTo = "#replyTo"
+
+// ReplyTo returns the identifier of the message that the envelope replies to, if defined.
+func (env *Envelope) ReplyTo() string {
+	return env.Metadata[MetadataKeyReplyTo]
+}
+
+// SetReplyTo sets the identifier of the message that the envelope replies to.
+func (env *Envelope) SetReplyTo(id string) *Envelope {
+	return env.SetMetadataKeyValue(MetadataKeyReplyTo, id)
+}
+
+// Reply creates a reply message with the document for the current message, which references its identifier and
+// conversation.
+func (msg *Message) Reply(d Document) *Message {
+	reply := &Message{}
+	reply.SetContent(d).
+		SetTo(msg.Sender()).
+		SetNewEnvelopeID()
+	if msg.ID != "" {
+		reply.SetReplyTo(msg.ID)
+	}
+	if id := msg.ConversationID(); id != "" {
+		reply.SetConversationID(id)
+	}
+	return reply
+}
+
+// ReplyMatcher defines a rule for correlating a received message as the reply of a sent one.
+type ReplyMatcher func(sent *Message, received *Message) bool
+
+// MatchReplyTo matches the received messages with the MetadataKeyReplyTo metadata equal to the sent message id.
+func MatchReplyTo(sent *Message, received *Message) bool {
+	return sent.ID != "" && received.ReplyTo() == sent.ID
+}
+
+// MatchConversation matches the received messages with the same conversation id of the sent message.
+func MatchConversation(sent *Message, received *Message) bool {
+	id := sent.ConversationID()
+	return id != "" && received.ConversationID() == id
+}
+
+// MatchReply matches the received messages by the MatchReplyTo or MatchConversation rules.
+func MatchReply(sent *Message, received *Message) bool {
+	return MatchReplyTo(sent, received) || MatchConversation(sent, received)
+}
+
+// awaitingReply is a sent message waiting for a reply.
+type awaitingReply struct {
+	msg     *Message
+	matcher ReplyMatcher
+	reply   chan *Message
+}
+
+// fromDestination indicates if the received message was sent by the destination of the awaiting message. The
+// remote node of the channel is considered for the messages without addresses.
+func (w *awaitingReply) fromDestination(received *Message, remoteNode Node) bool {
+	expected := w.msg.To
+	if expected == (Node{}) {
+		expected = remoteNode
+	}
+	sender := received.Sender()
+	if sender == (Node{}) {
+		sender = remoteNode
+	}
+	if sender.Identity != expected.Identity {
+		return false
+	}
+	return expected.Instance == "" || sender.Instance == expected.Instance
+}
+
+// awaitingReplies holds the messages of a channel that are waiting for replies.
+type awaitingReplies struct {
+	mu      sync.Mutex
+	waiters []*awaitingReply
+	// listened indicates that an EnvelopeMux listens the channel, so the replies are submitted by it after the
+	// verification of the received messages, instead of by the channel receiver.
+	listened atomic.Bool
+}
+
+func (r *awaitingReplies) add(w *awaitingReply) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.waiters = append(r.waiters, w)
+}
+
+func (r *awaitingReplies) remove(w *awaitingReply) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, waiter := range r.waiters {
+		if waiter == w {
+			r.waiters = append(r.waiters[:i], r.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// trySubmit delivers the message to the first waiter that it matches and whose destination sent it, which stops
+// waiting.
+func (r *awaitingReplies) trySubmit(msg *Message, remoteNode Node) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, w := range r.waiters {
+		if w.fromDestination(msg, remoteNode) && w.matcher(w.msg, msg) {
+			r.waiters = append(r.waiters[:i], r.waiters[i+1:]...)
+			w.reply <- msg
+			return true
+		}
+	}
+	return false
+}
+
+// SendAndAwaitReply sends the message and waits for a received message that matches it, according to the matcher,
+// until the context is done. If the matcher is nil, MatchReply is used. The message receives a new id if it has
+// none.
+// Only the messages sent by the destination of the message, or by the remote node if it has no destination, are
+// considered. If the channel is listened by an EnvelopeMux, the reply is taken after the mux verifications, like the
+// sender and the content hash ones, and it is not delivered to the message handlers.
+func (c *channel) SendAndAwaitReply(ctx context.Context, msg *Message, matcher ReplyMatcher) (*Message, error) {
+	return c.sendAndAwaitReply(ctx, c, msg, matcher)
+}
+
+func (c *channel) sendAndAwaitReply(ctx context.Context, sender MessageSender, msg *Message, matcher ReplyMatcher) (*Message, error) {
+	if msg == nil {
+		panic("send and await reply: message cannot be nil")
+	}
+	if err := c.ensureEstablished("send and await reply"); err != nil {
+		return nil, err
+	}
+	if matcher == nil {
+		matcher = MatchReply
+	}
+	if msg.ID == "" {
+		msg.SetNewEnvelopeID()
+	}
+
+	w := &awaitingReply{msg: msg, matcher: matcher, reply: make(chan *Message, 1)}
+	c.replies.add(w)
+	defer c.replies.remove(w)
+
+	if err := sender.SendMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("send and await reply: %w", ctx.Err())
+	case <-c.rcvDone:
+		// The reply may have been submitted just before the receiver stopped
+		select {
+		case reply := <-w.reply:
+			return reply, nil
+		default:
+			return nil, fmt.Errorf("send and await reply: %w", c.Err())
+		}
+	case reply := <-w.reply:
+		return reply, nil
+	}
+}
+
+// SendAndAwaitReply sends a Message to the server and waits for a received message that matches it, according to
+// the matcher. See channel.SendAndAwaitReply.
+func (c *Client) SendAndAwaitReply(ctx context.Context, msg *Message, matcher ReplyMatcher) (*Message, error) {
+	channel, err := c.getOrBuildChannel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return channel.sendAndAwaitReply(ctx, c, msg, matcher)
+}
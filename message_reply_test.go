@@ -0,0 +1,211 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMessage_Reply(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.From = Node{Identity: Identity{Name: "andreb", Domain: "limeprotocol.org"}, Instance: "home"}
+	msg.SetConversationID("d4c6f6f1-3f5c-4a8a-9d4e-8b8b1e5c6c3a")
+	d := TextDocument("Hi")
+
+	// Act
+	reply := msg.Reply(&d)
+
+	// Assert
+	assert.NotEmpty(t, reply.ID)
+	assert.NotEqual(t, msg.ID, reply.ID)
+	assert.Equal(t, msg.From, reply.To)
+	assert.Equal(t, msg.ID, reply.ReplyTo())
+	assert.Equal(t, msg.ConversationID(), reply.ConversationID())
+	assert.Equal(t, &d, reply.Content)
+}
+
+func TestChannel_SendAndAwaitReply(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	go func() {
+		e, _ := server.Receive(ctx)
+		d := TextDocument("Pong")
+		reply := e.(*Message).Reply(&d)
+		reply.From = e.(*Message).To
+		_ = server.Send(ctx, reply)
+	}()
+
+	// Act
+	reply, err := c.SendAndAwaitReply(ctx, createMessage(), nil)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.NotNil(t, reply) {
+		assert.Equal(t, createMessage().ID, reply.ReplyTo())
+		assert.Equal(t, "Pong", string(*reply.Content.(*TextDocument)))
+	}
+	assert.Len(t, c.MsgChan(), 0)
+}
+
+func TestChannel_SendAndAwaitReply_Conversation(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	msg := createMessage()
+	msg.SetConversationID("d4c6f6f1-3f5c-4a8a-9d4e-8b8b1e5c6c3a")
+	go func() {
+		_, _ = server.Receive(ctx)
+		other := createMessage()
+		other.SetNewEnvelopeID()
+		_ = server.Send(ctx, other)
+		reply := createMessage()
+		reply.SetNewEnvelopeID()
+		reply.From = msg.To
+		reply.SetConversationID("d4c6f6f1-3f5c-4a8a-9d4e-8b8b1e5c6c3a")
+		_ = server.Send(ctx, reply)
+	}()
+	go func() {
+		<-c.MsgChan()
+	}()
+
+	// Act
+	reply, err := c.SendAndAwaitReply(ctx, msg, MatchConversation)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.NotNil(t, reply) {
+		assert.Equal(t, msg.ConversationID(), reply.ConversationID())
+	}
+}
+
+func TestChannel_SendAndAwaitReply_Timeout(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	go func() {
+		_, _ = server.Receive(ctx)
+		other := createMessage()
+		other.SetNewEnvelopeID()
+		_ = server.Send(ctx, other)
+	}()
+
+	// Act
+	reply, err := c.SendAndAwaitReply(ctx, createMessage(), MatchReplyTo)
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, reply)
+	assert.Len(t, c.MsgChan(), 1)
+	assert.Empty(t, c.replies.waiters)
+}
+
+func TestClient_SendAndAwaitReply(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("send-and-await-reply")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		MessagesHandlerFunc(func(ctx context.Context, msg *Message, s Sender) error {
+			d := TextDocument("Pong")
+			reply := msg.Reply(&d)
+			reply.From = msg.To
+			return s.SendMessage(ctx, reply)
+		}).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		Build()
+	defer silentClose(client)
+	msg := createMessage()
+	msg.ID = ""
+
+	// Act
+	reply, err := client.SendAndAwaitReply(ctx, msg, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.ID)
+	if assert.NotNil(t, reply) {
+		assert.Equal(t, msg.ID, reply.ReplyTo())
+		assert.Equal(t, "Pong", string(*reply.Content.(*TextDocument)))
+	}
+}
+
+func TestChannel_SendAndAwaitReply_OtherSender(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	go func() {
+		e, _ := server.Receive(ctx)
+		d := TextDocument("Pong")
+		reply := e.(*Message).Reply(&d)
+		reply.SetFromString("mallory@limeprotocol.org/home")
+		_ = server.Send(ctx, reply)
+	}()
+
+	// Act
+	reply, err := c.SendAndAwaitReply(ctx, createMessage(), nil)
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, reply)
+	assert.Len(t, c.MsgChan(), 1)
+}
+
+func TestEnvelopeMux_ListenClient_AwaitReplyContentHashMismatch(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	mux.VerifyContentHashes()
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+	for !c.replies.listened.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		e, _ := server.Receive(ctx)
+		d := TextDocument("Pong")
+		reply := e.(*Message).Reply(&d)
+		reply.From = e.(*Message).To
+		reply.SetMetadataKeyValue(MetadataKeyContentHash, "sha256=00")
+		_ = server.Send(ctx, reply)
+	}()
+
+	// Act
+	reply, err := c.SendAndAwaitReply(ctx, createMessage(), nil)
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, reply)
+}
@@ -0,0 +1,229 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetentionPolicy limits the messages kept by a MessageStore, preventing the unbounded growth of the storage.
+// The zero values mean no limit. The oldest messages are removed first.
+type RetentionPolicy struct {
+	// MaxAge is the maximum age of the stored messages.
+	MaxAge time.Duration
+	// MaxCountPerPeer is the maximum number of stored messages of each conversation peer.
+	MaxCountPerPeer int
+	// MaxSize is the maximum total size of the stored messages, in bytes of their JSON encoding.
+	MaxSize int64
+}
+
+// RetentionStore is a MessageStore that removes the messages exceeding a RetentionPolicy.
+// Both the MemoryMessageStore and the SQLiteMessageStore implement it.
+type RetentionStore interface {
+	MessageStore
+	// Prune removes the messages exceeding the policy, returning the number of removed messages.
+	Prune(ctx context.Context, policy RetentionPolicy) (int, error)
+}
+
+// RetentionStats are the metrics of a Retention cleanup.
+type RetentionStats struct {
+	Runs     int64     `json:"runs"`              // Runs is the number of cleanup runs.
+	Failures int64     `json:"failures"`          // Failures is the number of failed cleanup runs.
+	Removed  int64     `json:"removed"`           // Removed is the total number of removed messages.
+	LastRun  time.Time `json:"lastRun,omitempty"` // LastRun is the moment when the last cleanup completed.
+}
+
+// Retention applies a RetentionPolicy to a store in the background, in a fixed interval.
+type Retention struct {
+	store    RetentionStore
+	policy   RetentionPolicy
+	interval time.Duration
+	clock    Clock
+
+	runs     atomic.Int64
+	failures atomic.Int64
+	removed  atomic.Int64
+	mu       sync.Mutex
+	lastRun  time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartRetention starts the cleanup of the store, which is pruned immediately and then in each interval, until the
+// Retention is closed. If the clock is nil, the SystemClock is used.
+func StartRetention(store RetentionStore, policy RetentionPolicy, interval time.Duration, clock Clock) *Retention {
+	if store == nil {
+		panic("nil store")
+	}
+	if interval <= 0 {
+		panic("the interval should be positive")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Retention{
+		store:    store,
+		policy:   policy,
+		interval: interval,
+		clock:    clockOrSystem(clock),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	goLabeled(ctx, "retention", r.run)
+	return r
+}
+
+func (r *Retention) run(ctx context.Context) {
+	defer close(r.done)
+	for {
+		if _, err := r.Prune(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("retention: %v", err)
+		}
+		if sleep(ctx, r.clock, r.interval) != nil {
+			return
+		}
+	}
+}
+
+// Prune applies the policy to the store immediately, counting the run in the stats.
+func (r *Retention) Prune(ctx context.Context) (int, error) {
+	n, err := r.store.Prune(ctx, r.policy)
+	r.runs.Add(1)
+	r.removed.Add(int64(n))
+	if err != nil {
+		r.failures.Add(1)
+		return n, fmt.Errorf("prune: %w", err)
+	}
+	r.mu.Lock()
+	r.lastRun = r.clock.Now()
+	r.mu.Unlock()
+	return n, nil
+}
+
+// Stats returns the metrics of the cleanup.
+func (r *Retention) Stats() RetentionStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RetentionStats{
+		Runs:     r.runs.Load(),
+		Failures: r.failures.Load(),
+		Removed:  r.removed.Load(),
+		LastRun:  r.lastRun,
+	}
+}
+
+// Close stops the cleanup, waiting for the running one to complete.
+func (r *Retention) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// Prune removes the messages exceeding the policy. The sizes of the messages are computed on the first prune and
+// cached.
+func (s *MemoryMessageStore) Prune(_ context.Context, policy RetentionPolicy) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var minTimestamp time.Time
+	if policy.MaxAge > 0 {
+		minTimestamp = s.clock.Now().Add(-policy.MaxAge)
+	}
+	counts := make(map[Identity]int)
+	var size int64
+
+	// The messages are visited from the most recent, so the oldest ones are removed
+	kept := make([]*StoredMessage, 0, len(s.messages))
+	var removed []*StoredMessage
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		stored := s.messages[i]
+		remove := !minTimestamp.IsZero() && stored.Timestamp.Before(minTimestamp)
+		if !remove && policy.MaxCountPerPeer > 0 {
+			counts[stored.Peer]++
+			remove = counts[stored.Peer] > policy.MaxCountPerPeer
+		}
+		if !remove && policy.MaxSize > 0 {
+			n, err := s.messageSize(stored)
+			if err != nil {
+				return 0, err
+			}
+			size += n
+			remove = size > policy.MaxSize
+		}
+		if remove {
+			removed = append(removed, stored)
+		} else {
+			kept = append(kept, stored)
+		}
+	}
+
+	for _, stored := range removed {
+		delete(s.sizes, stored)
+		key := storedMessageKey{direction: stored.Direction, id: stored.Message.ID}
+		if s.byID[key] == stored {
+			delete(s.byID, key)
+		}
+	}
+	slices.Reverse(kept)
+	s.messages = kept
+	return len(removed), nil
+}
+
+func (s *MemoryMessageStore) messageSize(stored *StoredMessage) (int64, error) {
+	if n, ok := s.sizes[stored]; ok {
+		return n, nil
+	}
+	b, err := json.Marshal(stored.Message)
+	if err != nil {
+		return 0, fmt.Errorf("memory message store: %w", err)
+	}
+	if s.sizes == nil {
+		s.sizes = make(map[*StoredMessage]int64)
+	}
+	s.sizes[stored] = int64(len(b))
+	return int64(len(b)), nil
+}
+
+// Prune removes the messages exceeding the policy. The count and size limits require SQLite 3.25 or later, which
+// support the window functions.
+func (s *SQLiteMessageStore) Prune(ctx context.Context, policy RetentionPolicy) (int, error) {
+	var statements []string
+	var args [][]any
+	if policy.MaxAge > 0 {
+		statements = append(statements, `DELETE FROM lime_messages WHERE timestamp < ?`)
+		args = append(args, []any{s.clock.Now().Add(-policy.MaxAge).UnixNano()})
+	}
+	if policy.MaxCountPerPeer > 0 {
+		statements = append(statements, `DELETE FROM lime_messages WHERE rowid IN (
+			SELECT rowid FROM (
+				SELECT rowid, ROW_NUMBER() OVER (PARTITION BY peer ORDER BY timestamp DESC, rowid DESC) AS n
+				FROM lime_messages)
+			WHERE n > ?)`)
+		args = append(args, []any{policy.MaxCountPerPeer})
+	}
+	if policy.MaxSize > 0 {
+		statements = append(statements, `DELETE FROM lime_messages WHERE rowid IN (
+			SELECT rowid FROM (
+				SELECT rowid, SUM(length(CAST(message AS BLOB))) OVER (ORDER BY timestamp DESC, rowid DESC) AS total
+				FROM lime_messages)
+			WHERE total > ?)`)
+		args = append(args, []any{policy.MaxSize})
+	}
+
+	removed := 0
+	for i, statement := range statements {
+		res, err := s.db.ExecContext(ctx, statement, args[i]...)
+		if err != nil {
+			return removed, fmt.Errorf("sqlite message store: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			removed += int(n)
+		}
+	}
+	return removed, nil
+}
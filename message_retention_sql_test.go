@@ -0,0 +1,78 @@
+//go:build sqlite
+
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSQLiteMessageStore_Prune_MaxAge(t *testing.T) {
+	// Arrange
+	s := newTestSQLiteMessageStore(t)
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{MaxAge: 150 * time.Second})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"3", "4"}, storedIDs(t, s))
+}
+
+func TestSQLiteMessageStore_Prune_MaxCountPerPeer(t *testing.T) {
+	// Arrange
+	s := newTestSQLiteMessageStore(t)
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{MaxCountPerPeer: 1})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"2", "4"}, storedIDs(t, s))
+}
+
+func TestSQLiteMessageStore_Prune_MaxSize(t *testing.T) {
+	// Arrange
+	s := newTestSQLiteMessageStore(t)
+	b3, _ := json.Marshal(createStoreMessage("3", "alice@localhost"))
+	b4, _ := json.Marshal(createStoreMessage("4", "alice@localhost"))
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{MaxSize: int64(len(b3) + len(b4))})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"3", "4"}, storedIDs(t, s))
+}
+
+func TestSQLiteMessageStore_Prune_AllLimits(t *testing.T) {
+	// Arrange
+	s := newTestSQLiteMessageStore(t)
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{MaxAge: 150 * time.Second, MaxCountPerPeer: 1})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"4"}, storedIDs(t, s))
+}
+
+func TestSQLiteMessageStore_Prune_NoLimits(t *testing.T) {
+	// Arrange
+	s := newTestSQLiteMessageStore(t)
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, storedIDs(t, s))
+}
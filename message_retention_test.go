@@ -0,0 +1,99 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+func storedIDs(t *testing.T, s MessageStore) []string {
+	messages, err := s.QueryMessages(context.Background(), MessageQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range messages {
+		ids = append(ids, m.Message.ID)
+	}
+	return ids
+}
+
+func TestMemoryMessageStore_Prune_MaxAge(t *testing.T) {
+	// Arrange
+	s := newTestMemoryMessageStore(t)
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{MaxAge: 150 * time.Second})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"3", "4"}, storedIDs(t, s))
+}
+
+func TestMemoryMessageStore_Prune_MaxCountPerPeer(t *testing.T) {
+	// Arrange
+	s := newTestMemoryMessageStore(t)
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{MaxCountPerPeer: 1})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"2", "4"}, storedIDs(t, s))
+	assert.NotContains(t, s.byID, storedMessageKey{direction: TapDirectionReceived, id: "1"})
+}
+
+func TestMemoryMessageStore_Prune_MaxSize(t *testing.T) {
+	// Arrange
+	s := newTestMemoryMessageStore(t)
+	b3, _ := json.Marshal(createStoreMessage("3", "alice@localhost"))
+	b4, _ := json.Marshal(createStoreMessage("4", "alice@localhost"))
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{MaxSize: int64(len(b3) + len(b4))})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"3", "4"}, storedIDs(t, s))
+	assert.Len(t, s.sizes, 2)
+}
+
+func TestMemoryMessageStore_Prune_NoLimits(t *testing.T) {
+	// Arrange
+	s := newTestMemoryMessageStore(t)
+
+	// Act
+	n, err := s.Prune(context.Background(), RetentionPolicy{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, storedIDs(t, s))
+}
+
+func TestStartRetention(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	s := newTestMemoryMessageStore(t)
+
+	// Act
+	r := StartRetention(s, RetentionPolicy{MaxCountPerPeer: 2}, time.Millisecond, nil)
+	assert.Eventually(t, func() bool {
+		return r.Stats().Runs >= 2
+	}, time.Second, time.Millisecond)
+	err := r.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	stats := r.Stats()
+	assert.Equal(t, int64(1), stats.Removed)
+	assert.Zero(t, stats.Failures)
+	assert.False(t, stats.LastRun.IsZero())
+	assert.Equal(t, []string{"2", "3", "4"}, storedIDs(t, s))
+}
@@ -0,0 +1,203 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// StoredMessage is a message sent or received by the client, recorded by a MessageStore.
+type StoredMessage struct {
+	// Direction indicates if the message was sent or received.
+	Direction TapDirection
+	// Peer is the identity of the other party of the conversation, which is the destination of the sent messages
+	// and the origin of the received ones.
+	Peer Identity
+	// Timestamp is the moment when the message was sent or received.
+	Timestamp time.Time
+	// Message is the recorded message.
+	Message *Message
+	// Event is the most advanced notification event of the message, which is received from the peer for the sent
+	// messages and sent to the peer for the received ones.
+	Event NotificationEvent
+	// Reason is the failure reason, if the message has failed.
+	Reason *Reason
+}
+
+// MessageQuery filters the messages of a MessageStore.
+type MessageQuery struct {
+	// Peer is the conversation peer. If empty, the messages of all the conversations are returned.
+	Peer Identity
+	// Since is the start of the time range, inclusive. If zero, the range has no start.
+	Since time.Time
+	// Until is the end of the time range, exclusive. If zero, the range has no end.
+	Until time.Time
+	// Limit is the maximum number of messages, which are the most recent ones in the range. If zero, all the
+	// messages in the range are returned.
+	Limit int
+}
+
+// MessageStore records the messages and notifications exchanged by the client, allowing the applications to build
+// the conversations history locally, like in a chat UI.
+type MessageStore interface {
+	// StoreMessage records a sent or received message.
+	StoreMessage(ctx context.Context, direction TapDirection, msg *Message) error
+	// StoreNotification updates the event of the message of a notification. A received notification refers to a
+	// sent message, and a sent notification to a received message. The notifications of unknown messages are
+	// ignored.
+	StoreNotification(ctx context.Context, direction TapDirection, not *Notification) error
+	// QueryMessages returns the messages that match the query, in the chronological order.
+	QueryMessages(ctx context.Context, q MessageQuery) ([]StoredMessage, error)
+}
+
+// messagePeer returns the conversation peer of a sent or received message.
+func messagePeer(direction TapDirection, msg *Message) Identity {
+	if direction == TapDirectionSent {
+		return msg.To.Identity
+	}
+	return msg.From.Identity
+}
+
+// notifiedDirection returns the direction of the message of a notification.
+func notifiedDirection(direction TapDirection) TapDirection {
+	if direction == TapDirectionSent {
+		return TapDirectionReceived
+	}
+	return TapDirectionSent
+}
+
+// advancesEvent indicates if the notification event should replace the current event of a message, since the
+// notifications may arrive out of order and the completed messages are not changed.
+func advancesEvent(current, event NotificationEvent) bool {
+	if current == NotificationEventConsumed || current == NotificationEventFailed {
+		return false
+	}
+	return notificationEventOrder(event) > notificationEventOrder(current)
+}
+
+// storeMessage records a message in the store, if defined. The store failures are logged, without interrupting the
+// message flow.
+func storeMessage(ctx context.Context, s MessageStore, direction TapDirection, msg *Message) {
+	if s == nil {
+		return
+	}
+	if err := s.StoreMessage(ctx, direction, msg); err != nil {
+		log.Printf("store message: %v", err)
+	}
+}
+
+// storeNotification records a notification in the store, if defined.
+func storeNotification(ctx context.Context, s MessageStore, direction TapDirection, not *Notification) {
+	if s == nil {
+		return
+	}
+	if err := s.StoreNotification(ctx, direction, not); err != nil {
+		log.Printf("store notification: %v", err)
+	}
+}
+
+// MemoryMessageStore is a MessageStore that keeps the messages in memory. The messages are copied when stored, so
+// they are not affected by later changes of the senders and handlers.
+type MemoryMessageStore struct {
+	mu       sync.RWMutex
+	clock    Clock
+	messages []*StoredMessage
+	byID     map[storedMessageKey]*StoredMessage
+	sizes    map[*StoredMessage]int64 // sizes are the encoded sizes of the messages, computed by the retention
+}
+
+type storedMessageKey struct {
+	direction TapDirection
+	id        string
+}
+
+// NewMemoryMessageStore creates a new instance of MemoryMessageStore.
+func NewMemoryMessageStore() *MemoryMessageStore {
+	return &MemoryMessageStore{
+		clock: SystemClock,
+		byID:  make(map[storedMessageKey]*StoredMessage),
+	}
+}
+
+// SetClock defines the clock for the timestamps of the messages.
+func (s *MemoryMessageStore) SetClock(clock Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clockOrSystem(clock)
+}
+
+func (s *MemoryMessageStore) StoreMessage(_ context.Context, direction TapDirection, msg *Message) error {
+	if msg == nil {
+		return errors.New("nil message")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := storedMessageKey{direction: direction, id: msg.ID}
+	if _, ok := s.byID[key]; ok && msg.ID != "" {
+		// A retransmitted message is recorded only once
+		return nil
+	}
+	c, err := msg.Clone()
+	if err != nil {
+		return fmt.Errorf("memory message store: %w", err)
+	}
+	stored := &StoredMessage{
+		Direction: direction,
+		Peer:      messagePeer(direction, msg),
+		Timestamp: s.clock.Now(),
+		Message:   c,
+	}
+	// The timestamps are usually increasing, so the insertion is near the end
+	i := len(s.messages)
+	for i > 0 && s.messages[i-1].Timestamp.After(stored.Timestamp) {
+		i--
+	}
+	s.messages = slices.Insert(s.messages, i, stored)
+	if msg.ID != "" {
+		s.byID[key] = stored
+	}
+	return nil
+}
+
+func (s *MemoryMessageStore) StoreNotification(_ context.Context, direction TapDirection, not *Notification) error {
+	if not == nil {
+		return errors.New("nil notification")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.byID[storedMessageKey{direction: notifiedDirection(direction), id: not.ID}]
+	if !ok || !advancesEvent(stored.Event, not.Event) {
+		return nil
+	}
+	stored.Event = not.Event
+	stored.Reason = not.Reason
+	return nil
+}
+
+func (s *MemoryMessageStore) QueryMessages(_ context.Context, q MessageQuery) ([]StoredMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []StoredMessage
+	for i := len(s.messages) - 1; i >= 0 && (q.Limit <= 0 || len(result) < q.Limit); i-- {
+		stored := s.messages[i]
+		if !q.Until.IsZero() && !stored.Timestamp.Before(q.Until) {
+			continue
+		}
+		if !q.Since.IsZero() && stored.Timestamp.Before(q.Since) {
+			break
+		}
+		if q.Peer != (Identity{}) && stored.Peer != q.Peer {
+			continue
+		}
+		result = append(result, *stored)
+	}
+	slices.Reverse(result)
+	return result, nil
+}
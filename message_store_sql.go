@@ -0,0 +1,177 @@
+package lime
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SQLiteMessageStore is a MessageStore that persists the messages in a SQLite database, through the database/sql
+// package. The application should import a SQLite driver and open the database, like:
+//
+//	db, err := sql.Open("sqlite", "messages.db")
+//	store, err := lime.NewSQLiteMessageStore(ctx, db)
+type SQLiteMessageStore struct {
+	db    *sql.DB
+	clock Clock
+}
+
+const sqliteMessageStoreSchema = `
+CREATE TABLE IF NOT EXISTS lime_messages (
+	direction   TEXT    NOT NULL,
+	id          TEXT    NOT NULL,
+	peer        TEXT    NOT NULL,
+	timestamp   INTEGER NOT NULL,
+	event       TEXT    NOT NULL DEFAULT '',
+	event_order INTEGER NOT NULL DEFAULT 0,
+	reason      TEXT,
+	message     TEXT    NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS lime_messages_id ON lime_messages (direction, id) WHERE id <> '';
+CREATE INDEX IF NOT EXISTS lime_messages_peer_timestamp ON lime_messages (peer, timestamp);
+CREATE INDEX IF NOT EXISTS lime_messages_timestamp ON lime_messages (timestamp);
+`
+
+// NewSQLiteMessageStore creates a SQLiteMessageStore, creating its table in the database if it does not exist.
+func NewSQLiteMessageStore(ctx context.Context, db *sql.DB) (*SQLiteMessageStore, error) {
+	if db == nil {
+		panic("nil db")
+	}
+	if _, err := db.ExecContext(ctx, sqliteMessageStoreSchema); err != nil {
+		return nil, fmt.Errorf("sqlite message store: %w", err)
+	}
+	return &SQLiteMessageStore{db: db, clock: SystemClock}, nil
+}
+
+// SetClock defines the clock for the timestamps of the messages. It should be called before the store is used.
+func (s *SQLiteMessageStore) SetClock(clock Clock) {
+	s.clock = clockOrSystem(clock)
+}
+
+func (s *SQLiteMessageStore) StoreMessage(ctx context.Context, direction TapDirection, msg *Message) error {
+	if msg == nil {
+		return errors.New("nil message")
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("sqlite message store: %w", err)
+	}
+	// A retransmitted message is recorded only once
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO lime_messages (direction, id, peer, timestamp, message) VALUES (?, ?, ?, ?, ?)`,
+		string(direction), msg.ID, messagePeer(direction, msg).String(), s.clock.Now().UnixNano(), string(b))
+	if err != nil {
+		return fmt.Errorf("sqlite message store: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteMessageStore) StoreNotification(ctx context.Context, direction TapDirection, not *Notification) error {
+	if not == nil {
+		return errors.New("nil notification")
+	}
+	if not.ID == "" {
+		return nil
+	}
+	var reason sql.NullString
+	if not.Reason != nil {
+		b, err := json.Marshal(not.Reason)
+		if err != nil {
+			return fmt.Errorf("sqlite message store: %w", err)
+		}
+		reason = sql.NullString{String: string(b), Valid: true}
+	}
+	// The notifications may arrive out of order, so only the most advanced event is kept
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE lime_messages SET event = ?, event_order = ?, reason = ?
+		WHERE direction = ? AND id = ? AND event_order < ? AND event NOT IN (?, ?)`,
+		string(not.Event), notificationEventOrder(not.Event), reason,
+		string(notifiedDirection(direction)), not.ID, notificationEventOrder(not.Event),
+		string(NotificationEventConsumed), string(NotificationEventFailed))
+	if err != nil {
+		return fmt.Errorf("sqlite message store: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteMessageStore) QueryMessages(ctx context.Context, q MessageQuery) ([]StoredMessage, error) {
+	var where []string
+	var args []any
+	if q.Peer != (Identity{}) {
+		where = append(where, "peer = ?")
+		args = append(args, q.Peer.String())
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "timestamp < ?")
+		args = append(args, q.Until.UnixNano())
+	}
+	query := "SELECT direction, peer, timestamp, event, reason, message FROM lime_messages"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// The most recent messages are selected for the limit, and then put in the chronological order
+	query += " ORDER BY timestamp DESC, rowid DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite message store: %w", err)
+	}
+	defer rows.Close()
+
+	var result []StoredMessage
+	for rows.Next() {
+		stored, err := scanStoredMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite message store: %w", err)
+		}
+		result = append(result, stored)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite message store: %w", err)
+	}
+	slices.Reverse(result)
+	return result, nil
+}
+
+func scanStoredMessage(rows *sql.Rows) (StoredMessage, error) {
+	var (
+		stored    StoredMessage
+		direction string
+		peer      string
+		timestamp int64
+		event     string
+		reason    sql.NullString
+		message   string
+	)
+	if err := rows.Scan(&direction, &peer, &timestamp, &event, &reason, &message); err != nil {
+		return stored, err
+	}
+	stored.Direction = TapDirection(direction)
+	stored.Peer = ParseIdentity(peer)
+	stored.Timestamp = time.Unix(0, timestamp)
+	stored.Event = NotificationEvent(event)
+	if reason.Valid {
+		stored.Reason = &Reason{}
+		if err := json.Unmarshal([]byte(reason.String), stored.Reason); err != nil {
+			return stored, err
+		}
+	}
+	stored.Message = &Message{}
+	if err := json.Unmarshal([]byte(message), stored.Message); err != nil {
+		return stored, err
+	}
+	return stored, nil
+}
@@ -0,0 +1,166 @@
+//go:build sqlite
+
+package lime
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+	"testing"
+	"time"
+)
+
+// The SQLiteMessageStore tests require a SQLite driver, so they only run with the sqlite build tag:
+//
+//	go test -tags sqlite ./...
+
+func newSQLiteMessageStore(t *testing.T) *SQLiteMessageStore {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	s, err := NewSQLiteMessageStore(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetClock(&stepClock{now: storeEpoch})
+	return s
+}
+
+func newTestSQLiteMessageStore(t *testing.T) *SQLiteMessageStore {
+	ctx := context.Background()
+	s := newSQLiteMessageStore(t)
+	for i, peer := range []string{"alice@localhost", "bob@localhost", "alice@localhost", "alice@localhost"} {
+		msg := createStoreMessage(string(rune('1'+i)), peer)
+		if err := s.StoreMessage(ctx, TapDirectionReceived, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestSQLiteMessageStore_QueryMessages_Peer(t *testing.T) {
+	// Arrange
+	s := newTestSQLiteMessageStore(t)
+
+	// Act
+	actual, err := s.QueryMessages(context.Background(), MessageQuery{Peer: ParseIdentity("alice@localhost")})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, actual, 3) {
+		assert.Equal(t, "1", actual[0].Message.ID)
+		assert.Equal(t, "3", actual[1].Message.ID)
+		assert.Equal(t, "4", actual[2].Message.ID)
+		assert.True(t, storeEpoch.Add(time.Minute).Equal(actual[0].Timestamp))
+		assert.Equal(t, TapDirectionReceived, actual[0].Direction)
+		assert.Equal(t, ParseIdentity("alice@localhost"), actual[0].Peer)
+		assert.Equal(t, TextDocument("Hello world"), *actual[0].Message.Content.(*TextDocument))
+	}
+}
+
+func TestSQLiteMessageStore_QueryMessages_TimeRange(t *testing.T) {
+	// Arrange
+	s := newTestSQLiteMessageStore(t)
+
+	// Act
+	actual, err := s.QueryMessages(context.Background(), MessageQuery{
+		Since: storeEpoch.Add(2 * time.Minute),
+		Until: storeEpoch.Add(4 * time.Minute),
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, actual, 2) {
+		assert.Equal(t, "2", actual[0].Message.ID)
+		assert.Equal(t, "3", actual[1].Message.ID)
+	}
+}
+
+func TestSQLiteMessageStore_QueryMessages_Limit(t *testing.T) {
+	// Arrange
+	s := newTestSQLiteMessageStore(t)
+
+	// Act
+	actual, err := s.QueryMessages(context.Background(), MessageQuery{Peer: ParseIdentity("alice@localhost"), Limit: 2})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, actual, 2) {
+		assert.Equal(t, "3", actual[0].Message.ID)
+		assert.Equal(t, "4", actual[1].Message.ID)
+	}
+}
+
+func TestSQLiteMessageStore_StoreMessage_Retransmission(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	s := newSQLiteMessageStore(t)
+	msg := createMessage()
+	_ = s.StoreMessage(ctx, TapDirectionSent, msg)
+
+	// Act
+	err := s.StoreMessage(ctx, TapDirectionSent, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	actual, _ := s.QueryMessages(ctx, MessageQuery{})
+	if assert.Len(t, actual, 1) {
+		assert.Equal(t, msg.To.Identity, actual[0].Peer)
+	}
+}
+
+func TestSQLiteMessageStore_StoreNotification(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	s := newSQLiteMessageStore(t)
+	msg := createMessage()
+	_ = s.StoreMessage(ctx, TapDirectionSent, msg)
+	received := &Notification{Envelope: Envelope{ID: msg.ID}, Event: NotificationEventReceived}
+	accepted := &Notification{Envelope: Envelope{ID: msg.ID}, Event: NotificationEventAccepted}
+	other := &Notification{Envelope: Envelope{ID: msg.ID}, Event: NotificationEventConsumed}
+
+	// Act
+	_ = s.StoreNotification(ctx, TapDirectionReceived, received)
+	_ = s.StoreNotification(ctx, TapDirectionReceived, accepted)
+	_ = s.StoreNotification(ctx, TapDirectionSent, other)
+
+	// Assert
+	actual, _ := s.QueryMessages(ctx, MessageQuery{})
+	if assert.Len(t, actual, 1) {
+		assert.Equal(t, NotificationEventReceived, actual[0].Event)
+	}
+}
+
+func TestSQLiteMessageStore_StoreNotification_Failed(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	s := newSQLiteMessageStore(t)
+	msg := createMessage()
+	_ = s.StoreMessage(ctx, TapDirectionSent, msg)
+	failed := &Notification{
+		Envelope: Envelope{ID: msg.ID},
+		Event:    NotificationEventFailed,
+		Reason:   &Reason{Code: ReasonCodeGeneralError, Description: "Failure"},
+	}
+	consumed := &Notification{Envelope: Envelope{ID: msg.ID}, Event: NotificationEventConsumed}
+
+	// Act
+	errFailed := s.StoreNotification(ctx, TapDirectionReceived, failed)
+	errConsumed := s.StoreNotification(ctx, TapDirectionReceived, consumed)
+
+	// Assert
+	assert.NoError(t, errFailed)
+	assert.NoError(t, errConsumed)
+	actual, _ := s.QueryMessages(ctx, MessageQuery{})
+	if assert.Len(t, actual, 1) {
+		assert.Equal(t, NotificationEventFailed, actual[0].Event)
+		assert.Equal(t, &Reason{Code: ReasonCodeGeneralError, Description: "Failure"}, actual[0].Reason)
+	}
+}
@@ -0,0 +1,207 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+// stepClock is a Clock that advances one minute on each call to Now.
+type stepClock struct {
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.now = c.now.Add(time.Minute)
+	return c.now
+}
+
+func (c *stepClock) NewTimer(d time.Duration) Timer {
+	return SystemClock.NewTimer(d)
+}
+
+var storeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func createStoreMessage(id string, peer string) *Message {
+	msg := createMessage()
+	msg.ID = id
+	msg.To = Node{}
+	msg.From = Node{Identity: ParseIdentity(peer)}
+	return msg
+}
+
+func newTestMemoryMessageStore(t *testing.T) *MemoryMessageStore {
+	ctx := context.Background()
+	s := NewMemoryMessageStore()
+	s.SetClock(&stepClock{now: storeEpoch})
+	for i, m := range []struct {
+		direction TapDirection
+		peer      string
+	}{
+		{TapDirectionReceived, "alice@localhost"},
+		{TapDirectionReceived, "bob@localhost"},
+		{TapDirectionReceived, "alice@localhost"},
+		{TapDirectionReceived, "alice@localhost"},
+	} {
+		msg := createStoreMessage(string(rune('1'+i)), m.peer)
+		if err := s.StoreMessage(ctx, m.direction, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestMemoryMessageStore_QueryMessages_Peer(t *testing.T) {
+	// Arrange
+	s := newTestMemoryMessageStore(t)
+
+	// Act
+	actual, err := s.QueryMessages(context.Background(), MessageQuery{Peer: ParseIdentity("alice@localhost")})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, actual, 3) {
+		assert.Equal(t, "1", actual[0].Message.ID)
+		assert.Equal(t, "3", actual[1].Message.ID)
+		assert.Equal(t, "4", actual[2].Message.ID)
+		assert.Equal(t, storeEpoch.Add(time.Minute), actual[0].Timestamp)
+		assert.Equal(t, TapDirectionReceived, actual[0].Direction)
+	}
+}
+
+func TestMemoryMessageStore_QueryMessages_TimeRange(t *testing.T) {
+	// Arrange
+	s := newTestMemoryMessageStore(t)
+
+	// Act
+	actual, err := s.QueryMessages(context.Background(), MessageQuery{
+		Since: storeEpoch.Add(2 * time.Minute),
+		Until: storeEpoch.Add(4 * time.Minute),
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, actual, 2) {
+		assert.Equal(t, "2", actual[0].Message.ID)
+		assert.Equal(t, "3", actual[1].Message.ID)
+	}
+}
+
+func TestMemoryMessageStore_QueryMessages_Limit(t *testing.T) {
+	// Arrange
+	s := newTestMemoryMessageStore(t)
+
+	// Act
+	actual, err := s.QueryMessages(context.Background(), MessageQuery{Peer: ParseIdentity("alice@localhost"), Limit: 2})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, actual, 2) {
+		assert.Equal(t, "3", actual[0].Message.ID)
+		assert.Equal(t, "4", actual[1].Message.ID)
+	}
+}
+
+func TestMemoryMessageStore_StoreMessage_Retransmission(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	s := NewMemoryMessageStore()
+	msg := createMessage()
+	_ = s.StoreMessage(ctx, TapDirectionSent, msg)
+
+	// Act
+	err := s.StoreMessage(ctx, TapDirectionSent, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	actual, _ := s.QueryMessages(ctx, MessageQuery{})
+	if assert.Len(t, actual, 1) {
+		assert.Equal(t, msg.To.Identity, actual[0].Peer)
+	}
+}
+
+func TestMemoryMessageStore_StoreNotification(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	s := NewMemoryMessageStore()
+	msg := createMessage()
+	_ = s.StoreMessage(ctx, TapDirectionSent, msg)
+	received := &Notification{Envelope: Envelope{ID: msg.ID}, Event: NotificationEventReceived}
+	accepted := &Notification{Envelope: Envelope{ID: msg.ID}, Event: NotificationEventAccepted}
+	other := &Notification{Envelope: Envelope{ID: msg.ID}, Event: NotificationEventConsumed}
+
+	// Act
+	_ = s.StoreNotification(ctx, TapDirectionReceived, received)
+	_ = s.StoreNotification(ctx, TapDirectionReceived, accepted)
+	_ = s.StoreNotification(ctx, TapDirectionSent, other)
+
+	// Assert
+	actual, _ := s.QueryMessages(ctx, MessageQuery{})
+	if assert.Len(t, actual, 1) {
+		assert.Equal(t, NotificationEventReceived, actual[0].Event)
+	}
+}
+
+func TestEnvelopeMux_Listen_StoreMessages(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	store := NewMemoryMessageStore()
+	mux := &EnvelopeMux{store: store}
+	handled := make(chan *Message, 1)
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			handled <- msg
+			return nil
+		})
+	msg := createStoreMessage("1", "alice@localhost")
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+
+	// Act
+	err := client.Send(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case <-handled:
+	}
+	actual, _ := store.QueryMessages(ctx, MessageQuery{Peer: ParseIdentity("alice@localhost")})
+	if assert.Len(t, actual, 1) {
+		assert.Equal(t, msg.ID, actual[0].Message.ID)
+		assert.Equal(t, TapDirectionReceived, actual[0].Direction)
+	}
+	cancel()
+}
+
+func TestMemoryMessageStore_StoreMessage_Copy(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	s := NewMemoryMessageStore()
+	msg := createMessage()
+
+	// Act
+	err := s.StoreMessage(ctx, TapDirectionSent, msg)
+	msg.SetMetadataKeyValue("changed", "true")
+	*msg.Content.(*TextDocument) = "Changed"
+
+	// Assert
+	assert.NoError(t, err)
+	actual, _ := s.QueryMessages(ctx, MessageQuery{})
+	if assert.Len(t, actual, 1) {
+		assert.NotSame(t, msg, actual[0].Message)
+		assert.NotContains(t, actual[0].Message.Metadata, "changed")
+		assert.Equal(t, TextDocument("Hello world"), *actual[0].Message.Content.(*TextDocument))
+	}
+}
@@ -0,0 +1,140 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Messaging is a high-level API over a Client for the most common use cases, like sending texts and documents,
+// receiving texts and managing resources.
+type Messaging struct {
+	client       *Client
+	mu           sync.RWMutex
+	textHandlers []TextMessageHandlerFunc
+}
+
+// TextMessageHandlerFunc defines an action to be executed to a received text message.
+type TextMessageHandlerFunc func(ctx context.Context, from Node, text string) error
+
+// BuildMessaging creates a new Client with the builder configuration and wraps it in a Messaging instance.
+// The text handlers registered through Messaging.OnTextMessage take precedence over the builder message handlers.
+func (b *ClientBuilder) BuildMessaging() *Messaging {
+	m := &Messaging{}
+	b.mux.msgHandlers = append([]MessageHandler{&messageHandler{
+		predicate:   m.matchText,
+		handlerFunc: m.handleText,
+	}}, b.mux.msgHandlers...)
+	m.client = b.Build()
+	return m
+}
+
+// Client returns the underlying client.
+func (m *Messaging) Client() *Client {
+	return m.client
+}
+
+// Close closes the underlying client.
+func (m *Messaging) Close() error {
+	return m.client.Close()
+}
+
+// SendText sends a text message to the specified node.
+func (m *Messaging) SendText(ctx context.Context, to Node, text string) error {
+	d := TextDocument(text)
+	return m.SendDocument(ctx, to, &d)
+}
+
+// SendDocument sends a message with the document to the specified node.
+func (m *Messaging) SendDocument(ctx context.Context, to Node, d Document) error {
+	if d == nil {
+		panic("nil document")
+	}
+	msg := &Message{}
+	msg.SetContent(d).
+		SetTo(to).
+		SetNewEnvelopeID()
+	return m.client.SendMessage(ctx, msg)
+}
+
+// OnTextMessage registers a handler for the received text messages.
+// All the registered handlers are executed in the registration order, until one of them returns an error.
+func (m *Messaging) OnTextMessage(handler TextMessageHandlerFunc) {
+	if handler == nil {
+		panic("nil handler")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.textHandlers = append(m.textHandlers, handler)
+}
+
+func (m *Messaging) matchText(msg *Message) bool {
+	if _, ok := msg.Content.(*TextDocument); !ok {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.textHandlers) > 0
+}
+
+func (m *Messaging) handleText(ctx context.Context, msg *Message, _ Sender) error {
+	text := string(*msg.Content.(*TextDocument))
+	m.mu.RLock()
+	handlers := m.textHandlers
+	m.mu.RUnlock()
+	for _, h := range handlers {
+		if err := h(ctx, msg.From, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetResource sets the value of the resource in the specified URI.
+func (m *Messaging) SetResource(ctx context.Context, uri string, d Document) error {
+	if d == nil {
+		panic("nil document")
+	}
+	cmd, err := newResourceCommand(uri, CommandMethodSet)
+	if err != nil {
+		return fmt.Errorf("set resource: %w", err)
+	}
+	cmd.SetResource(d)
+
+	respCmd, err := m.client.ProcessCommand(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("set resource: %w", err)
+	}
+	if respCmd.Status != CommandStatusSuccess {
+		return fmt.Errorf("set resource: command failed: %v", respCmd.Reason)
+	}
+	return nil
+}
+
+// GetResource returns the value of the resource in the specified URI, which should be of the T type.
+// If the resource has no value, the T zero value is returned.
+func GetResource[T Document](ctx context.Context, m *Messaging, uri string) (T, error) {
+	var v T
+	cmd, err := newResourceCommand(uri, CommandMethodGet)
+	if err != nil {
+		return v, fmt.Errorf("get resource: %w", err)
+	}
+
+	v, err = ProcessCommandAs[T](ctx, m.client, cmd)
+	if err != nil {
+		return v, fmt.Errorf("get resource: %w", err)
+	}
+	return v, nil
+}
+
+func newResourceCommand(uri string, method CommandMethod) (*RequestCommand, error) {
+	u, err := ParseLimeURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	cmd := &RequestCommand{}
+	cmd.SetURI(u).
+		SetMethod(method).
+		SetNewEnvelopeID()
+	return cmd, nil
+}
@@ -0,0 +1,112 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func startMessagingServer(addr *net.TCPAddr) *Server {
+	var mu sync.Mutex
+	var account Document
+	server := NewServerBuilder().
+		ListenTCP(addr, nil).
+		EnableGuestAuthentication().
+		MessagesHandlerFunc(func(ctx context.Context, msg *Message, s Sender) error {
+			// Echoes the message to the sender
+			echo := &Message{}
+			echo.SetContent(msg.Content).SetID(msg.ID)
+			return s.SendMessage(ctx, echo)
+		}).
+		RequestCommandHandlerFunc(
+			func(cmd *RequestCommand) bool {
+				return cmd.URI.Path() == "/account"
+			},
+			func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if cmd.Method == CommandMethodSet {
+					account = cmd.Resource
+					return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+				}
+				if account == nil {
+					return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+				}
+				return s.SendResponseCommand(ctx, cmd.SuccessResponseWithResource(account))
+			}).
+		Build()
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, ErrServerClosed) {
+			log.Println(err)
+		}
+	}()
+	return server
+}
+
+func TestMessaging_SendText_OnTextMessage(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostTCPAddress().(*net.TCPAddr)
+	server := startMessagingServer(addr)
+	defer silentClose(server)
+	m := NewClientBuilder().
+		UseTCP(addr, nil).
+		Encryption(SessionEncryptionNone).
+		BuildMessaging()
+	defer silentClose(m)
+	received := make(chan string, 1)
+	m.OnTextMessage(func(ctx context.Context, from Node, text string) error {
+		received <- text
+		return nil
+	})
+
+	// Act
+	err := m.SendText(ctx, Node{Identity: Identity{Name: "echo", Domain: "localhost"}}, "Hello world")
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "receive text timeout")
+	case text := <-received:
+		assert.Equal(t, "Hello world", text)
+	}
+}
+
+func TestMessaging_SetResource_GetResource(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostTCPAddress().(*net.TCPAddr)
+	server := startMessagingServer(addr)
+	defer silentClose(server)
+	m := NewClientBuilder().
+		UseTCP(addr, nil).
+		Encryption(SessionEncryptionNone).
+		BuildMessaging()
+	defer silentClose(m)
+	account := &JsonDocument{"fullName": "Andre"}
+
+	// Act
+	empty, emptyErr := GetResource[*JsonDocument](ctx, m, "/account")
+	err := m.SetResource(ctx, "/account", account)
+	actual, getErr := GetResource[*JsonDocument](ctx, m, "/account")
+	_, typeErr := GetResource[*Ping](ctx, m, "/account")
+
+	// Assert
+	assert.NoError(t, emptyErr)
+	assert.Nil(t, empty)
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, account, actual)
+	assert.Error(t, typeErr)
+}
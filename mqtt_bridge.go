@@ -0,0 +1,312 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MetadataKeyMQTTTopic is the metadata key with the MQTT topic of the messages received by the MQTTBridge.
+	MetadataKeyMQTTTopic = "#mqttTopic"
+	// MetadataKeyMQTTQoS is the metadata key with the MQTT QoS level of a message. In the messages sent to the
+	// MQTTBridge, it overrides the default QoS level.
+	MetadataKeyMQTTQoS = "#mqttQos"
+)
+
+// MQTTClient defines the operations of an MQTT client used by the MQTTBridge, allowing the use of any MQTT library
+// through a small adapter.
+type MQTTClient interface {
+	// Publish sends the payload to the topic, returning after the delivery is confirmed accordingly to the QoS level.
+	Publish(ctx context.Context, topic string, qos byte, payload []byte) error
+	// Subscribe registers the handler for the messages published in the topics matching the filter.
+	Subscribe(ctx context.Context, filter string, qos byte, handler func(topic string, qos byte, payload []byte)) error
+}
+
+// MQTTBridgeConfig defines the options of a MQTTBridge.
+type MQTTBridgeConfig struct {
+	// Domain is the domain of the identities of the MQTT devices. The bridge only forwards the topics of this
+	// domain and only publishes the messages and commands addressed to it, so the devices cannot send envelopes as
+	// the other LIME nodes.
+	Domain string
+	// TopicPrefix is the root of the topics published by the devices, which are forwarded as messages, in the
+	// '<prefix>/<domain>/<name>/<path>' format.
+	TopicPrefix string
+	// OutboundTopicPrefix is the root of the topics published by the bridge, in the same format. It should not
+	// overlap the TopicPrefix, so the bridge does not receive its own publications.
+	OutboundTopicPrefix string
+	// MessagesPath is the resource path of the topics for the messages sent to the MQTT nodes.
+	MessagesPath string
+	// SubscriptionQoS is the QoS level of the bridge subscription.
+	SubscriptionQoS byte
+	// Destination is the node that receives the messages published in the MQTT topics. If empty, the messages are
+	// addressed to the remote party of the sender.
+	Destination Node
+	// ContentType is the media type of the MQTT payloads. The JSON types are decoded as documents, while the others
+	// are handled as text.
+	ContentType MediaType
+}
+
+// NewMQTTBridgeConfig creates a new instance of MQTTBridgeConfig with the default values.
+func NewMQTTBridgeConfig() *MQTTBridgeConfig {
+	return &MQTTBridgeConfig{
+		TopicPrefix:         "lime/in",
+		OutboundTopicPrefix: "lime/out",
+		MessagesPath:        "/messages",
+		SubscriptionQoS:     1,
+		ContentType:         MediaTypeApplicationJson(),
+	}
+}
+
+// MQTTBridge translates between MQTT topics and LIME envelopes, allowing IoT devices publishing in MQTT to interoperate
+// with LIME nodes. The topics are mapped to URIs of the device identity, so 'lime/in/example.com/sensor1/telemetry' is
+// 'lime://sensor1@example.com/telemetry'.
+// The QoS level of the MQTT messages is mapped to the notification events: QoS 0 messages have no ID, since they don't
+// expect notifications, QoS 1 confirms that the message was dispatched and QoS 2 that it was received.
+type MQTTBridge struct {
+	config *MQTTBridgeConfig
+	mqtt   MQTTClient
+	sender MessageSender
+}
+
+// NewMQTTBridge creates a new MQTTBridge which sends the MQTT messages through the specified sender.
+func NewMQTTBridge(config *MQTTBridgeConfig, mqtt MQTTClient, sender MessageSender) *MQTTBridge {
+	if config == nil {
+		panic("nil config")
+	}
+	if mqtt == nil {
+		panic("nil mqtt client")
+	}
+	if sender == nil {
+		panic("nil sender")
+	}
+	if config.Domain == "" {
+		panic("empty domain")
+	}
+	if mqttTopicsOverlap(config.TopicPrefix, config.OutboundTopicPrefix) {
+		panic("the inbound and outbound topic prefixes should not overlap")
+	}
+	return &MQTTBridge{config: config, mqtt: mqtt, sender: sender}
+}
+
+// Start subscribes to the topics under the configured prefix, forwarding the published payloads as messages.
+func (b *MQTTBridge) Start(ctx context.Context) error {
+	filter := strings.TrimSuffix(b.config.TopicPrefix, "/") + "/#"
+	if err := b.mqtt.Subscribe(ctx, filter, b.config.SubscriptionQoS, b.handlePublish); err != nil {
+		return fmt.Errorf("mqtt bridge: subscribe: %w", err)
+	}
+	return nil
+}
+
+func (b *MQTTBridge) handlePublish(topic string, qos byte, payload []byte) {
+	msg, err := b.toMessage(topic, qos, payload)
+	if err != nil {
+		log.Printf("mqtt bridge: %v", err)
+		return
+	}
+	if err := b.sender.SendMessage(context.Background(), msg); err != nil {
+		log.Printf("mqtt bridge: send message: %v", err)
+	}
+}
+
+func (b *MQTTBridge) toMessage(topic string, qos byte, payload []byte) (*Message, error) {
+	uri, err := MQTTTopicToURI(b.config.TopicPrefix, topic)
+	if err != nil {
+		return nil, err
+	}
+	u := uri.URL()
+	if u.Host != b.config.Domain {
+		return nil, fmt.Errorf("mqtt bridge: topic '%v' is not of the '%v' domain", topic, b.config.Domain)
+	}
+	content, err := b.decodePayload(payload)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt bridge: topic '%v': %w", topic, err)
+	}
+
+	msg := &Message{}
+	msg.SetContent(content)
+	msg.From = Node{Identity: Identity{Name: u.User.Username(), Domain: u.Host}}
+	msg.To = b.config.Destination
+	msg.SetMetadataKeyValue(MetadataKeyMQTTTopic, topic)
+	if qos > 0 {
+		// Only the messages with ID may be notified
+		msg.SetNewEnvelopeID()
+		msg.SetMetadataKeyValue(MetadataKeyMQTTQoS, strconv.Itoa(int(qos)))
+	}
+	return msg, nil
+}
+
+func (b *MQTTBridge) decodePayload(payload []byte) (Document, error) {
+	t := b.config.ContentType
+	if !t.IsJson() && t != MediaTypeApplicationJson() {
+		d := TextDocument(payload)
+		return &d, nil
+	}
+	raw := json.RawMessage(payload)
+	return UnmarshalDocument(&raw, t)
+}
+
+func encodeMQTTPayload(d Document) ([]byte, error) {
+	if text, ok := d.(*TextDocument); ok {
+		return []byte(*text), nil
+	}
+	return json.Marshal(d)
+}
+
+// MessageHandler returns a MessageHandler that publishes the received messages addressed to the devices in the
+// messages topic of the destination node, sending back a notification accordingly to the QoS level.
+func (b *MQTTBridge) MessageHandler() MessageHandler {
+	return &messageHandler{
+		predicate: func(msg *Message) bool {
+			return msg.To.Name != "" && msg.To.Domain == b.config.Domain
+		},
+		handlerFunc: b.publishMessage,
+	}
+}
+
+func (b *MQTTBridge) publishMessage(ctx context.Context, msg *Message, s Sender) error {
+	qos, err := messageQoS(msg)
+	if err == nil {
+		err = b.publish(ctx, msg.To.Identity, b.config.MessagesPath, qos, msg.Content)
+	}
+
+	if msg.ID == "" {
+		return nil
+	}
+	if err != nil {
+		return s.SendNotification(ctx, msg.FailedNotification(NewReason(ReasonCodeGatewayError, err.Error())))
+	}
+	if event := NotificationEventForMQTTQoS(qos); event != "" {
+		return s.SendNotification(ctx, msg.Notification(event))
+	}
+	return nil
+}
+
+// RequestCommandHandler returns a RequestCommandHandler that publishes the resources of the set commands with
+// absolute URIs of the devices in the mapped topics.
+func (b *MQTTBridge) RequestCommandHandler() RequestCommandHandler {
+	return &requestCommandHandler{
+		predicate: func(cmd *RequestCommand) bool {
+			if cmd.Method != CommandMethodSet || cmd.URI == nil {
+				return false
+			}
+			u := cmd.URI.URL()
+			return u.Scheme == URISchemeLime && u.User != nil && u.User.Username() != "" && u.Host == b.config.Domain
+		},
+		handlerFunc: func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+			u := cmd.URI.URL()
+			owner := Identity{Name: u.User.Username(), Domain: u.Host}
+			if err := b.publish(ctx, owner, u.Path, 1, cmd.Resource); err != nil {
+				return s.SendResponseCommand(ctx, cmd.FailureResponse(NewReason(ReasonCodeGatewayError, err.Error())))
+			}
+			return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+		},
+	}
+}
+
+func (b *MQTTBridge) publish(ctx context.Context, owner Identity, path string, qos byte, d Document) error {
+	if d == nil {
+		return errors.New("mqtt bridge: the content is required")
+	}
+	uri, err := ParseLimeURI(fmt.Sprintf("%v://%v%v", URISchemeLime, owner, path))
+	if err != nil {
+		return fmt.Errorf("mqtt bridge: %w", err)
+	}
+	topic, err := MQTTURIToTopic(b.config.OutboundTopicPrefix, uri)
+	if err != nil {
+		return err
+	}
+	payload, err := encodeMQTTPayload(d)
+	if err != nil {
+		return fmt.Errorf("mqtt bridge: %w", err)
+	}
+	if err := b.mqtt.Publish(ctx, topic, qos, payload); err != nil {
+		return fmt.Errorf("mqtt bridge: publish: %w", err)
+	}
+	return nil
+}
+
+// messageQoS returns the QoS level defined in the message metadata or, by default, 1 for the messages with ID and
+// 0 for the others.
+func messageQoS(msg *Message) (byte, error) {
+	if v, ok := msg.Metadata[MetadataKeyMQTTQoS]; ok {
+		qos, err := strconv.Atoi(v)
+		if err != nil || qos < 0 || qos > 2 {
+			return 0, fmt.Errorf("mqtt bridge: invalid qos '%v'", v)
+		}
+		return byte(qos), nil
+	}
+	if msg.ID == "" {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// NotificationEventForMQTTQoS returns the notification event confirmed by a delivery with the QoS level.
+// The QoS 0 has no confirmation, so no event is returned.
+func NotificationEventForMQTTQoS(qos byte) NotificationEvent {
+	switch qos {
+	case 1:
+		return NotificationEventDispatched
+	case 2:
+		return NotificationEventReceived
+	}
+	return ""
+}
+
+// MQTTQoSForNotificationEvent returns the minimum QoS level for confirming the notification event.
+func MQTTQoSForNotificationEvent(event NotificationEvent) byte {
+	switch event {
+	case NotificationEventAccepted, NotificationEventDispatched:
+		return 1
+	case NotificationEventReceived, NotificationEventConsumed:
+		return 2
+	}
+	return 0
+}
+
+// mqttTopicsOverlap indicates if the topics under one of the prefixes are also under the other.
+func mqttTopicsOverlap(a, b string) bool {
+	a = strings.TrimSuffix(a, "/") + "/"
+	b = strings.TrimSuffix(b, "/") + "/"
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// MQTTTopicToURI maps a topic in the '<prefix>/<domain>/<name>/<path>' format to the 'lime://name@domain/path' URI.
+func MQTTTopicToURI(prefix string, topic string) (*URI, error) {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	rest, ok := strings.CutPrefix(topic, prefix)
+	if !ok {
+		return nil, fmt.Errorf("mqtt bridge: topic '%v' is not under the '%v' prefix", topic, prefix)
+	}
+	levels := strings.SplitN(rest, "/", 3)
+	if len(levels) < 2 || levels[0] == "" || levels[1] == "" {
+		return nil, fmt.Errorf("mqtt bridge: topic '%v' has no identity", topic)
+	}
+	path := "/"
+	if len(levels) == 3 {
+		path += levels[2]
+	}
+	return ParseLimeURI(fmt.Sprintf("%v://%v@%v%v", URISchemeLime, levels[1], levels[0], path))
+}
+
+// MQTTURIToTopic maps an absolute URI in the 'lime://name@domain/path' format to the '<prefix>/<domain>/<name>/<path>'
+// topic.
+func MQTTURIToTopic(prefix string, uri *URI) (string, error) {
+	u := uri.URL()
+	if u == nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return "", fmt.Errorf("mqtt bridge: uri '%v' has no owner identity", uri)
+	}
+	topic := strings.TrimSuffix(prefix, "/") + "/" + u.Host + "/" + u.User.Username()
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		topic += "/" + path
+	}
+	if strings.ContainsAny(topic, "+#") {
+		return "", fmt.Errorf("mqtt bridge: topic '%v' has wildcards", topic)
+	}
+	return topic, nil
+}
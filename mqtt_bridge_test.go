@@ -0,0 +1,254 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mqttPublish struct {
+	topic   string
+	qos     byte
+	payload []byte
+}
+
+// fakeMQTTClient is an in-memory MQTTClient that delivers the publishes to the matching subscriptions.
+type fakeMQTTClient struct {
+	mu        sync.Mutex
+	published []mqttPublish
+	handlers  map[string]func(topic string, qos byte, payload []byte)
+	err       error
+}
+
+func (c *fakeMQTTClient) Publish(_ context.Context, topic string, qos byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.published = append(c.published, mqttPublish{topic: topic, qos: qos, payload: payload})
+	return nil
+}
+
+func (c *fakeMQTTClient) Subscribe(_ context.Context, filter string, _ byte, handler func(topic string, qos byte, payload []byte)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handlers == nil {
+		c.handlers = make(map[string]func(topic string, qos byte, payload []byte))
+	}
+	c.handlers[filter] = handler
+	return nil
+}
+
+// notificationRecorder is a Sender that keeps the sent notifications.
+type notificationRecorder struct {
+	Sender
+	notifications []*Notification
+}
+
+func (r *notificationRecorder) SendNotification(_ context.Context, not *Notification) error {
+	r.notifications = append(r.notifications, not)
+	return nil
+}
+
+func newMQTTBridgeConfig() *MQTTBridgeConfig {
+	config := NewMQTTBridgeConfig()
+	config.Domain = "example.com"
+	return config
+}
+
+func TestMQTTTopicToURI(t *testing.T) {
+	// Act
+	uri, err := MQTTTopicToURI("lime", "lime/example.com/sensor1/telemetry/temperature")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "lime://sensor1@example.com/telemetry/temperature", uri.String())
+}
+
+func TestMQTTTopicToURI_InvalidTopic(t *testing.T) {
+	for _, topic := range []string{"other/example.com/sensor1", "lime/example.com", "lime//sensor1"} {
+		t.Run(topic, func(t *testing.T) {
+			// Act
+			_, err := MQTTTopicToURI("lime", topic)
+
+			// Assert
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMQTTURIToTopic(t *testing.T) {
+	// Arrange
+	uri, _ := ParseLimeURI("lime://sensor1@example.com/config/")
+
+	// Act
+	topic, err := MQTTURIToTopic("lime/", uri)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "lime/example.com/sensor1/config", topic)
+}
+
+func TestMQTTBridge_Start(t *testing.T) {
+	// Arrange
+	mqtt := &fakeMQTTClient{}
+	sender := &messageRecorder{}
+	config := newMQTTBridgeConfig()
+	config.Destination = Node{Identity: Identity{Name: "backend", Domain: "example.com"}}
+	bridge := NewMQTTBridge(config, mqtt, sender)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := bridge.Start(ctx)
+	handler := mqtt.handlers["lime/in/#"]
+	handler("lime/in/example.com/sensor1/telemetry", 1, []byte(`{"temperature":21.5}`))
+	handler("lime/in/example.com/sensor2/telemetry", 0, []byte(`{"temperature":19}`))
+	handler("lime/in/msging.net/postmaster/telemetry", 1, []byte(`{"temperature":0}`))
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, sender.messages, 2) {
+		msg := sender.messages[0]
+		assert.NotEmpty(t, msg.ID)
+		assert.Equal(t, "sensor1@example.com", msg.From.String())
+		assert.Equal(t, config.Destination, msg.To)
+		assert.Equal(t, &JsonDocument{"temperature": 21.5}, msg.Content)
+		assert.Equal(t, "lime/in/example.com/sensor1/telemetry", msg.Metadata[MetadataKeyMQTTTopic])
+		assert.Equal(t, "1", msg.Metadata[MetadataKeyMQTTQoS])
+		assert.Empty(t, sender.messages[1].ID)
+	}
+}
+
+func TestMQTTBridge_MessageHandler(t *testing.T) {
+	// Arrange
+	mqtt := &fakeMQTTClient{}
+	bridge := NewMQTTBridge(newMQTTBridgeConfig(), mqtt, &messageRecorder{})
+	msg := &Message{}
+	msg.SetContent(&JsonDocument{"led": "on"}).
+		SetToString("sensor1@example.com").
+		SetID("msg-1")
+	msg.SetMetadataKeyValue(MetadataKeyMQTTQoS, "2")
+	s := &notificationRecorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	handler := bridge.MessageHandler()
+
+	// Act
+	matched := handler.Match(msg)
+	err := handler.Handle(ctx, msg, s)
+
+	// Assert
+	assert.True(t, matched)
+	assert.NoError(t, err)
+	assert.Equal(t, []mqttPublish{{topic: "lime/out/example.com/sensor1/messages", qos: 2, payload: []byte(`{"led":"on"}`)}}, mqtt.published)
+	if assert.Len(t, s.notifications, 1) {
+		assert.Equal(t, NotificationEventReceived, s.notifications[0].Event)
+		assert.Equal(t, "msg-1", s.notifications[0].ID)
+	}
+}
+
+func TestMQTTBridge_MessageHandler_PublishError(t *testing.T) {
+	// Arrange
+	mqtt := &fakeMQTTClient{err: errors.New("not connected")}
+	bridge := NewMQTTBridge(newMQTTBridgeConfig(), mqtt, &messageRecorder{})
+	msg := createMessage()
+	msg.SetToString("sensor1@example.com")
+	s := &notificationRecorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := bridge.MessageHandler().Handle(ctx, msg, s)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, s.notifications, 1) {
+		assert.Equal(t, NotificationEventFailed, s.notifications[0].Event)
+	}
+}
+
+func TestMQTTBridge_RequestCommandHandler(t *testing.T) {
+	// Arrange
+	mqtt := &fakeMQTTClient{}
+	bridge := NewMQTTBridge(newMQTTBridgeConfig(), mqtt, &messageRecorder{})
+	resource := TextDocument("interval=30")
+	cmd := &RequestCommand{}
+	cmd.SetURIString("lime://sensor1@example.com/config").
+		SetMethod(CommandMethodSet).
+		SetResource(&resource).
+		SetID("cmd-1")
+	s := &responseRecorder{}
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	handler := bridge.RequestCommandHandler()
+
+	// Act
+	matched := handler.Match(cmd)
+	err := handler.Handle(ctx, cmd, s)
+
+	// Assert
+	assert.True(t, matched)
+	assert.NoError(t, err)
+	assert.Equal(t, []mqttPublish{{topic: "lime/out/example.com/sensor1/config", qos: 1, payload: []byte("interval=30")}}, mqtt.published)
+	if assert.Len(t, s.responses, 1) {
+		assert.Equal(t, CommandStatusSuccess, s.responses[0].Status)
+	}
+}
+
+func TestMQTTBridge_RequestCommandHandler_OtherURIs(t *testing.T) {
+	bridge := NewMQTTBridge(newMQTTBridgeConfig(), &fakeMQTTClient{}, &messageRecorder{})
+	handler := bridge.RequestCommandHandler()
+	for _, uri := range []string{"lime://postmaster@msging.net/config", "http://example.com/config", "/config"} {
+		t.Run(uri, func(t *testing.T) {
+			// Arrange
+			cmd := &RequestCommand{}
+			cmd.SetURIString(uri).
+				SetMethod(CommandMethodSet).
+				SetID("cmd-1")
+
+			// Act
+			matched := handler.Match(cmd)
+
+			// Assert
+			assert.False(t, matched)
+		})
+	}
+}
+
+func TestMQTTBridge_MessageHandler_OtherDomain(t *testing.T) {
+	// Arrange
+	bridge := NewMQTTBridge(newMQTTBridgeConfig(), &fakeMQTTClient{}, &messageRecorder{})
+	msg := createMessage()
+
+	// Act
+	matched := bridge.MessageHandler().Match(msg)
+
+	// Assert
+	assert.False(t, matched)
+}
+
+func TestNewMQTTBridge_OverlappingPrefixes(t *testing.T) {
+	// Arrange
+	config := newMQTTBridgeConfig()
+	config.TopicPrefix = "lime"
+
+	// Act & Assert
+	assert.Panics(t, func() {
+		NewMQTTBridge(config, &fakeMQTTClient{}, &messageRecorder{})
+	})
+}
+
+func TestNotificationEventForMQTTQoS(t *testing.T) {
+	for qos := byte(0); qos <= 2; qos++ {
+		event := NotificationEventForMQTTQoS(qos)
+		if event != "" {
+			assert.Equal(t, qos, MQTTQoSForNotificationEvent(event))
+		}
+	}
+	assert.Equal(t, NotificationEvent(""), NotificationEventForMQTTQoS(0))
+}
@@ -0,0 +1,193 @@
+package lime
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// NegotiationEventType defines the type of the events emitted during the session establishment.
+type NegotiationEventType string
+
+const (
+	// NegotiationEventStateEntered is emitted when the channel session changes its state.
+	NegotiationEventStateEntered = NegotiationEventType("state.entered")
+	// NegotiationEventOptionsOffered is emitted when the compression, encryption or authentication scheme options are
+	// sent or received.
+	NegotiationEventOptionsOffered = NegotiationEventType("options.offered")
+	// NegotiationEventOptionChosen is emitted when the compression and encryption options are selected or confirmed.
+	NegotiationEventOptionChosen = NegotiationEventType("option.chosen")
+	// NegotiationEventSchemeSelected is emitted when an authentication with a scheme is sent or received.
+	NegotiationEventSchemeSelected = NegotiationEventType("scheme.selected")
+	// NegotiationEventRoundTrip is emitted when a session envelope is received in response to a sent one, with the
+	// elapsed time between them.
+	NegotiationEventRoundTrip = NegotiationEventType("round-trip")
+)
+
+// NegotiationEvent is a structured record of a step of the session establishment.
+type NegotiationEvent struct {
+	Type        NegotiationEventType   // Type is the event type.
+	Timestamp   time.Time              // Timestamp is the moment when the event occurred.
+	Client      bool                   // Client indicates if the event was emitted by the client side of the session.
+	Received    bool                   // Received indicates if the event refers to a session received from the remote party.
+	SessionID   string                 // SessionID is the id of the session, if known.
+	State       SessionState           // State is the entered state or the state of the sent or received session.
+	Compression []SessionCompression   // Compression are the offered or the chosen compression options.
+	Encryption  []SessionEncryption    // Encryption are the offered or the chosen encryption options.
+	Schemes     []AuthenticationScheme // Schemes are the offered or the selected authentication schemes.
+	Latency     time.Duration          // Latency is the round-trip time.
+	Reason      *Reason                // Reason is the failure reason, in the failed state.
+}
+
+func (e NegotiationEvent) String() string {
+	var b strings.Builder
+	side := "server"
+	if e.Client {
+		side = "client"
+	}
+	fmt.Fprintf(&b, "%v: session %v: %v", side, e.SessionID, e.Type)
+	if e.Type != NegotiationEventStateEntered {
+		if e.Received {
+			b.WriteString(" (received)")
+		} else {
+			b.WriteString(" (sent)")
+		}
+	}
+	if e.State != "" {
+		fmt.Fprintf(&b, " state=%v", e.State)
+	}
+	if len(e.Compression) != 0 {
+		fmt.Fprintf(&b, " compression=%v", e.Compression)
+	}
+	if len(e.Encryption) != 0 {
+		fmt.Fprintf(&b, " encryption=%v", e.Encryption)
+	}
+	if len(e.Schemes) != 0 {
+		fmt.Fprintf(&b, " schemes=%v", e.Schemes)
+	}
+	if e.Type == NegotiationEventRoundTrip {
+		fmt.Fprintf(&b, " latency=%v", e.Latency)
+	}
+	if e.Reason != nil {
+		fmt.Fprintf(&b, " reason=%q", e.Reason.Description)
+	}
+	return b.String()
+}
+
+// NegotiationTracer receives the events emitted by a channel during the session establishment, which allows the
+// diagnosis of failed or slow establishments.
+// The implementations should be safe for concurrent use, since it may be shared by the channels.
+type NegotiationTracer interface {
+	TraceNegotiation(event NegotiationEvent)
+}
+
+// NegotiationTracerFunc is an adapter to allow the use of functions as NegotiationTracer.
+type NegotiationTracerFunc func(event NegotiationEvent)
+
+func (f NegotiationTracerFunc) TraceNegotiation(event NegotiationEvent) {
+	f(event)
+}
+
+// LogNegotiationTracer returns a NegotiationTracer that writes the events to the logger.
+// If the logger is nil, the standard logger is used.
+func LogNegotiationTracer(logger *log.Logger) NegotiationTracer {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return NegotiationTracerFunc(func(event NegotiationEvent) {
+		logger.Println(event)
+	})
+}
+
+// SetNegotiationTracer defines the tracer for the session establishment events of the channel.
+func (c *channel) SetNegotiationTracer(t NegotiationTracer) {
+	if err := c.ensureState(SessionStateNew, "set negotiation tracer"); err != nil {
+		panic(err)
+	}
+	c.negotiationTracer = t
+}
+
+func (c *channel) traceNegotiation(event NegotiationEvent) {
+	event.Timestamp = c.clock.Now()
+	event.Client = c.client
+	if event.SessionID == "" {
+		event.SessionID = c.sessionID
+	}
+	c.negotiationTracer.TraceNegotiation(event)
+}
+
+// traceSession emits the events of a sent or received session envelope.
+func (c *channel) traceSession(ses *Session, received bool) {
+	if c.negotiationTracer == nil {
+		return
+	}
+
+	now := c.clock.Now()
+	if !received {
+		c.lastSessionSent = now
+	} else if !c.lastSessionSent.IsZero() {
+		c.traceNegotiation(NegotiationEvent{
+			Type:      NegotiationEventRoundTrip,
+			Received:  true,
+			SessionID: ses.ID,
+			State:     ses.State,
+			Latency:   now.Sub(c.lastSessionSent),
+		})
+		c.lastSessionSent = time.Time{}
+	}
+
+	if len(ses.CompressionOptions) != 0 || len(ses.EncryptionOptions) != 0 || len(ses.SchemeOptions) != 0 {
+		c.traceNegotiation(NegotiationEvent{
+			Type:        NegotiationEventOptionsOffered,
+			Received:    received,
+			SessionID:   ses.ID,
+			State:       ses.State,
+			Compression: ses.CompressionOptions,
+			Encryption:  ses.EncryptionOptions,
+			Schemes:     ses.SchemeOptions,
+		})
+	}
+	if ses.Compression != "" || ses.Encryption != "" {
+		event := NegotiationEvent{
+			Type:      NegotiationEventOptionChosen,
+			Received:  received,
+			SessionID: ses.ID,
+			State:     ses.State,
+		}
+		if ses.Compression != "" {
+			event.Compression = []SessionCompression{ses.Compression}
+		}
+		if ses.Encryption != "" {
+			event.Encryption = []SessionEncryption{ses.Encryption}
+		}
+		c.traceNegotiation(event)
+	}
+	if ses.Scheme != "" {
+		c.traceNegotiation(NegotiationEvent{
+			Type:      NegotiationEventSchemeSelected,
+			Received:  received,
+			SessionID: ses.ID,
+			State:     ses.State,
+			Schemes:   []AuthenticationScheme{ses.Scheme},
+		})
+	}
+	if ses.State == SessionStateFailed {
+		c.failedReason = ses.Reason
+	}
+}
+
+// traceState emits the state entered event.
+func (c *channel) traceState(state SessionState) {
+	if c.negotiationTracer == nil {
+		return
+	}
+	event := NegotiationEvent{
+		Type:  NegotiationEventStateEntered,
+		State: state,
+	}
+	if state == SessionStateFailed {
+		event.Reason = c.failedReason
+	}
+	c.traceNegotiation(event)
+}
@@ -0,0 +1,127 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// negotiationRecorder is a NegotiationTracer that keeps the traced events.
+type negotiationRecorder struct {
+	mu     sync.Mutex
+	events []NegotiationEvent
+}
+
+func (r *negotiationRecorder) TraceNegotiation(event NegotiationEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *negotiationRecorder) ofType(t NegotiationEventType) []NegotiationEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []NegotiationEvent
+	for _, e := range r.events {
+		if e.Type == t {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestClientChannel_EstablishSession_TraceNegotiation(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostTCPAddress().(*net.TCPAddr)
+	serverRecorder := &negotiationRecorder{}
+	srv := NewServerBuilder().
+		ListenTCP(addr, nil).
+		EnableGuestAuthentication().
+		TraceNegotiation(serverRecorder).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	client := createClientTCPTransport(t, addr)
+	c := NewClientChannel(client, 1)
+	defer silentClose(c)
+	// Closing the server first finishes the client receiver
+	defer silentClose(srv)
+	clientRecorder := &negotiationRecorder{}
+	c.SetNegotiationTracer(clientRecorder)
+
+	// Act
+	ses, err := c.EstablishSession(
+		ctx,
+		func([]SessionCompression) SessionCompression {
+			return SessionCompressionNone
+		},
+		func([]SessionEncryption) SessionEncryption {
+			return SessionEncryptionNone
+		},
+		Identity{Name: "golang", Domain: "localhost"},
+		func([]AuthenticationScheme, Authentication) Authentication {
+			return &GuestAuthentication{}
+		},
+		"default")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStateEstablished, ses.State)
+	var states []SessionState
+	for _, e := range clientRecorder.ofType(NegotiationEventStateEntered) {
+		assert.True(t, e.Client)
+		states = append(states, e.State)
+	}
+	assert.Equal(t, []SessionState{SessionStateNegotiating, SessionStateNegotiating, SessionStateAuthenticating, SessionStateEstablished}, states)
+	offered := clientRecorder.ofType(NegotiationEventOptionsOffered)
+	if assert.Len(t, offered, 2) {
+		assert.True(t, offered[0].Received)
+		assert.Equal(t, []SessionCompression{SessionCompressionNone}, offered[0].Compression)
+		assert.Equal(t, []AuthenticationScheme{AuthenticationSchemeGuest}, offered[1].Schemes)
+	}
+	chosen := clientRecorder.ofType(NegotiationEventOptionChosen)
+	if assert.NotEmpty(t, chosen) {
+		assert.False(t, chosen[0].Received)
+		assert.Equal(t, []SessionEncryption{SessionEncryptionNone}, chosen[0].Encryption)
+	}
+	selected := clientRecorder.ofType(NegotiationEventSchemeSelected)
+	if assert.Len(t, selected, 1) {
+		assert.Equal(t, []AuthenticationScheme{AuthenticationSchemeGuest}, selected[0].Schemes)
+	}
+	roundTrips := clientRecorder.ofType(NegotiationEventRoundTrip)
+	assert.Len(t, roundTrips, 3)
+	for _, e := range roundTrips {
+		assert.Equal(t, ses.ID, e.SessionID)
+		assert.Positive(t, e.Latency)
+	}
+	assert.Eventually(t, func() bool {
+		entered := serverRecorder.ofType(NegotiationEventStateEntered)
+		return len(entered) != 0 && !entered[0].Client && entered[len(entered)-1].State == SessionStateEstablished
+	}, 100*time.Millisecond, 5*time.Millisecond)
+}
+
+func TestLogNegotiationTracer(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	tracer := LogNegotiationTracer(log.New(&buf, "", 0))
+
+	// Act
+	tracer.TraceNegotiation(NegotiationEvent{
+		Type:      NegotiationEventRoundTrip,
+		Client:    true,
+		Received:  true,
+		SessionID: "52e59849",
+		State:     SessionStateAuthenticating,
+		Latency:   12 * time.Millisecond,
+	})
+
+	// Assert
+	assert.Equal(t, "client: session 52e59849: round-trip (received) state=authenticating latency=12ms\n", buf.String())
+}
@@ -2,7 +2,6 @@ package lime
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 )
 
@@ -32,7 +31,7 @@ func (not Notification) MarshalJSON() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(raw)
+	return marshalRawEnvelope(envelopeKindNotification, raw)
 }
 
 func (not *Notification) UnmarshalJSON(b []byte) error {
@@ -74,7 +73,7 @@ func (not *Notification) populate(raw *rawEnvelope) error {
 	}
 
 	if raw.Event == nil {
-		return errors.New("notification event is required")
+		return newEnvelopeError(envelopeKindNotification, raw.ID, "event", ErrFieldRequired)
 	}
 
 	not.Event = *raw.Event
@@ -0,0 +1,272 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MessageStatus represents the delivery state of a sent message, aggregated from its notifications.
+type MessageStatus struct {
+	// ID is the identifier of the tracked message.
+	ID string
+	// To is the destination of the tracked message.
+	To Node
+	// SentAt is the moment that the message was tracked.
+	SentAt time.Time
+	// Event is the most advanced event received for the message in the lifecycle
+	// accepted → dispatched → received → consumed, or failed.
+	Event NotificationEvent
+	// Reason is the failure reason, if the message has failed.
+	Reason *Reason
+	// Notifications are the received notifications for the message, in the arrival order.
+	Notifications []NotificationRecord
+}
+
+// NotificationRecord is a notification received for a tracked message.
+type NotificationRecord struct {
+	Event      NotificationEvent
+	From       Node
+	Reason     *Reason
+	ReceivedAt time.Time
+}
+
+// Completed indicates if the message reached a final state, which are consumed and failed.
+func (s *MessageStatus) Completed() bool {
+	return s.Event == NotificationEventConsumed || s.Event == NotificationEventFailed
+}
+
+// NotificationTracker aggregates the notifications of the sent messages, allowing the query of the delivery state
+// of each one of them.
+type NotificationTracker struct {
+	mu         sync.RWMutex
+	clock      Clock
+	messages   map[string]*MessageStatus
+	waiters    map[string][]chan struct{} // waiters are signaled when the event of the message changes
+	onComplete []func(status MessageStatus)
+}
+
+// NewNotificationTracker creates a new instance of NotificationTracker.
+func NewNotificationTracker() *NotificationTracker {
+	return &NotificationTracker{
+		clock:    SystemClock,
+		messages: make(map[string]*MessageStatus),
+		waiters:  make(map[string][]chan struct{}),
+	}
+}
+
+// SetClock defines the clock for the timestamps of the sent messages and received notifications.
+func (t *NotificationTracker) SetClock(clock Clock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clock = clockOrSystem(clock)
+}
+
+// Track starts tracking the notifications of the specified message.
+// The messages without ID are ignored, since no notification is generated for them.
+func (t *NotificationTracker) Track(msg *Message) {
+	if msg == nil || msg.ID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.messages[msg.ID]; ok {
+		return
+	}
+	t.messages[msg.ID] = &MessageStatus{
+		ID:     msg.ID,
+		To:     msg.To,
+		SentAt: t.clock.Now(),
+	}
+}
+
+// Tracking indicates if the message with the specified ID is being tracked.
+func (t *NotificationTracker) Tracking(id string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, ok := t.messages[id]
+	return ok
+}
+
+// Observe aggregates the notification to the status of its message.
+// It returns false if the notification message is not being tracked.
+func (t *NotificationTracker) Observe(not *Notification) bool {
+	t.mu.Lock()
+	status, ok := t.messages[not.ID]
+	if !ok {
+		t.mu.Unlock()
+		return false
+	}
+
+	wasCompleted := status.Completed()
+	status.Notifications = append(status.Notifications, NotificationRecord{
+		Event:      not.Event,
+		From:       not.From,
+		Reason:     not.Reason,
+		ReceivedAt: t.clock.Now(),
+	})
+	// The notifications may arrive out of order, so only the most advanced event is kept
+	if !wasCompleted && notificationEventOrder(not.Event) > notificationEventOrder(status.Event) {
+		status.Event = not.Event
+		status.Reason = not.Reason
+		t.signal(not.ID)
+	}
+
+	var callbacks []func(status MessageStatus)
+	var snapshot MessageStatus
+	if !wasCompleted && status.Completed() {
+		callbacks = t.onComplete
+		snapshot = status.copy()
+	}
+	t.mu.Unlock()
+
+	for _, f := range callbacks {
+		f(snapshot)
+	}
+	return true
+}
+
+// Await blocks until the message with the specified ID reaches the event, a more advanced one or the failed state,
+// returning its status.
+func (t *NotificationTracker) Await(ctx context.Context, id string, event NotificationEvent) (MessageStatus, error) {
+	for {
+		t.mu.Lock()
+		status, ok := t.messages[id]
+		if !ok {
+			t.mu.Unlock()
+			return MessageStatus{}, fmt.Errorf("await notification: the message '%v' is not tracked", id)
+		}
+		if status.Event == NotificationEventFailed ||
+			notificationEventOrder(status.Event) >= notificationEventOrder(event) {
+			snapshot := status.copy()
+			t.mu.Unlock()
+			return snapshot, nil
+		}
+		ch := make(chan struct{})
+		t.waiters[id] = append(t.waiters[id], ch)
+		t.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			t.removeWaiter(id, ch)
+			return MessageStatus{}, fmt.Errorf("await notification: %w", ctx.Err())
+		case <-ch:
+		}
+	}
+}
+
+// signal wakes up the waiters of the message. It should be called with the lock held.
+func (t *NotificationTracker) signal(id string) {
+	for _, ch := range t.waiters[id] {
+		close(ch)
+	}
+	delete(t.waiters, id)
+}
+
+func (t *NotificationTracker) removeWaiter(id string, ch chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	waiters := t.waiters[id]
+	for i, w := range waiters {
+		if w == ch {
+			t.waiters[id] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(t.waiters[id]) == 0 {
+		delete(t.waiters, id)
+	}
+}
+
+// Status returns the current status of the message with the specified ID.
+func (t *NotificationTracker) Status(id string) (MessageStatus, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	status, ok := t.messages[id]
+	if !ok {
+		return MessageStatus{}, false
+	}
+	return status.copy(), true
+}
+
+// Pending returns the status of the tracked messages that are not completed yet.
+func (t *NotificationTracker) Pending() []MessageStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var pending []MessageStatus
+	for _, status := range t.messages {
+		if !status.Completed() {
+			pending = append(pending, status.copy())
+		}
+	}
+	return pending
+}
+
+// OnComplete registers a callback to be called when a tracked message reaches a final state.
+// The callback is called synchronously by the goroutine that observed the notification.
+func (t *NotificationTracker) OnComplete(f func(status MessageStatus)) {
+	if f == nil {
+		panic("nil callback")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onComplete = append(t.onComplete, f)
+}
+
+// Remove stops tracking the message with the specified ID.
+func (t *NotificationTracker) Remove(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.messages, id)
+	t.signal(id)
+}
+
+// RemoveCompleted stops tracking the completed messages that were sent before the specified moment,
+// returning the number of removed messages.
+func (t *NotificationTracker) RemoveCompleted(before time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var count int
+	for id, status := range t.messages {
+		if status.Completed() && status.SentAt.Before(before) {
+			delete(t.messages, id)
+			count++
+		}
+	}
+	return count
+}
+
+// Match implements the NotificationHandler interface, matching the notifications of the tracked messages.
+func (t *NotificationTracker) Match(not *Notification) bool {
+	return t.Tracking(not.ID)
+}
+
+// Handle implements the NotificationHandler interface.
+func (t *NotificationTracker) Handle(_ context.Context, not *Notification) error {
+	t.Observe(not)
+	return nil
+}
+
+func (s *MessageStatus) copy() MessageStatus {
+	c := *s
+	c.Notifications = append([]NotificationRecord(nil), s.Notifications...)
+	return c
+}
+
+func notificationEventOrder(e NotificationEvent) int {
+	switch e {
+	case NotificationEventAccepted:
+		return 1
+	case NotificationEventDispatched:
+		return 2
+	case NotificationEventReceived:
+		return 3
+	case NotificationEventConsumed:
+		return 4
+	case NotificationEventFailed:
+		return 5
+	default:
+		return 0
+	}
+}
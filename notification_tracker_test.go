@@ -0,0 +1,141 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNotificationTracker_Observe(t *testing.T) {
+	// Arrange
+	tracker := NewNotificationTracker()
+	msg := createMessage()
+	tracker.Track(msg)
+
+	// Act
+	ok1 := tracker.Observe(msg.Notification(NotificationEventAccepted))
+	ok2 := tracker.Observe(msg.Notification(NotificationEventReceived))
+	ok3 := tracker.Observe(msg.Notification(NotificationEventDispatched))
+
+	// Assert
+	assert.True(t, ok1)
+	assert.True(t, ok2)
+	assert.True(t, ok3)
+	status, ok := tracker.Status(msg.ID)
+	assert.True(t, ok)
+	assert.Equal(t, NotificationEventReceived, status.Event)
+	assert.False(t, status.Completed())
+	assert.Len(t, status.Notifications, 3)
+	assert.Len(t, tracker.Pending(), 1)
+}
+
+func TestNotificationTracker_Observe_NotTracked(t *testing.T) {
+	// Arrange
+	tracker := NewNotificationTracker()
+
+	// Act
+	ok := tracker.Observe(createNotification())
+
+	// Assert
+	assert.False(t, ok)
+	_, found := tracker.Status(createNotification().ID)
+	assert.False(t, found)
+}
+
+func TestNotificationTracker_OnComplete(t *testing.T) {
+	// Arrange
+	tracker := NewNotificationTracker()
+	msg := createMessage()
+	tracker.Track(msg)
+	var completed []MessageStatus
+	tracker.OnComplete(func(status MessageStatus) {
+		completed = append(completed, status)
+	})
+	reason := &Reason{Code: 1, Description: "Destination not found"}
+
+	// Act
+	tracker.Observe(msg.Notification(NotificationEventAccepted))
+	tracker.Observe(msg.FailedNotification(reason))
+	tracker.Observe(msg.Notification(NotificationEventConsumed))
+
+	// Assert
+	if assert.Len(t, completed, 1) {
+		assert.Equal(t, NotificationEventFailed, completed[0].Event)
+		assert.Equal(t, reason, completed[0].Reason)
+	}
+	assert.Empty(t, tracker.Pending())
+}
+
+func TestNotificationTracker_RemoveCompleted(t *testing.T) {
+	// Arrange
+	tracker := NewNotificationTracker()
+	msg1 := createMessage()
+	msg2 := createMessage()
+	msg2.ID = "other-id"
+	tracker.Track(msg1)
+	tracker.Track(msg2)
+	tracker.Observe(msg1.Notification(NotificationEventConsumed))
+
+	// Act
+	count := tracker.RemoveCompleted(time.Now().Add(time.Second))
+
+	// Assert
+	assert.Equal(t, 1, count)
+	assert.False(t, tracker.Tracking(msg1.ID))
+	assert.True(t, tracker.Tracking(msg2.ID))
+}
+
+func TestNotificationTracker_Handle(t *testing.T) {
+	// Arrange
+	tracker := NewNotificationTracker()
+	mux := &EnvelopeMux{}
+	mux.NotificationHandler(tracker)
+	msg := createMessage()
+	tracker.Track(msg)
+
+	// Act
+	err := mux.handleNotification(context.Background(), msg.Notification(NotificationEventConsumed))
+
+	// Assert
+	assert.NoError(t, err)
+	status, _ := tracker.Status(msg.ID)
+	assert.True(t, status.Completed())
+}
+
+func TestNotificationTracker_Await(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	tracker := NewNotificationTracker()
+	msg := createMessage()
+	tracker.Track(msg)
+	go func() {
+		tracker.Observe(msg.Notification(NotificationEventAccepted))
+		tracker.Observe(msg.Notification(NotificationEventConsumed))
+	}()
+
+	// Act
+	status, err := tracker.Await(ctx, msg.ID, NotificationEventReceived)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, NotificationEventConsumed, status.Event)
+}
+
+func TestNotificationTracker_Await_Timeout(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	tracker := NewNotificationTracker()
+	msg := createMessage()
+	tracker.Track(msg)
+	tracker.Observe(msg.Notification(NotificationEventAccepted))
+
+	// Act
+	_, err := tracker.Await(ctx, msg.ID, NotificationEventReceived)
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, tracker.waiters)
+}
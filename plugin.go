@@ -0,0 +1,105 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/multierr"
+	"time"
+)
+
+// pluginStopTimeout is the time that the plugins have to stop when the server is closed.
+const pluginStopTimeout = 10 * time.Second
+
+// Plugin is an extension of the Server with a managed lifecycle, like the metrics exporters, the backplanes, the
+// stores and the admin APIs.
+// The plugins are started in the registration order by Server.ListenAndServe, before the listeners, and stopped in
+// the reverse order by Server.Close. The Start context is canceled when the server is closed, so it can be used by
+// the goroutines of the plugin.
+// A plugin can also implement the HandlerPlugin interface to register its envelope handlers and the SessionPlugin
+// interface to be notified of the sessions.
+type Plugin interface {
+	// Start starts the plugin for the server, which gives access to its sessions through the Sessions and
+	// SessionByID methods and to the delivery of messages to them through the DeliverMessage method.
+	// If it fails, the server is not started.
+	Start(ctx context.Context, srv *Server) error
+	// Stop releases the plugin resources.
+	Stop(ctx context.Context) error
+}
+
+// HandlerPlugin is a Plugin that registers envelope handlers in the server mux. The handlers are registered when the
+// plugin is added to the ServerBuilder, after the handlers that were already defined.
+type HandlerPlugin interface {
+	Plugin
+	RegisterHandlers(mux *EnvelopeMux)
+}
+
+// SessionPlugin is a Plugin that is notified when the sessions of the server are established and finished.
+type SessionPlugin interface {
+	Plugin
+	SessionEstablished(ctx context.Context, c *ServerChannel)
+	SessionFinished(ctx context.Context, c *ServerChannel)
+}
+
+// Plugin adds a plugin to the server, which is started and stopped with it.
+func (b *ServerBuilder) Plugin(p Plugin) *ServerBuilder {
+	if p == nil {
+		panic("nil plugin")
+	}
+	if hp, ok := p.(HandlerPlugin); ok {
+		hp.RegisterHandlers(b.mux)
+	}
+	b.config.Plugins = append(b.config.Plugins, p)
+	return b
+}
+
+// Sessions returns the established sessions of the server.
+func (srv *Server) Sessions() []*ServerChannel {
+	return srv.establishedSessions()
+}
+
+// SessionByID returns the established session with the specified id, if any.
+func (srv *Server) SessionByID(sessionID string) (*ServerChannel, bool) {
+	c, ok := srv.session(sessionID)
+	if !ok || !c.Established() {
+		return nil, false
+	}
+	return c, true
+}
+
+// startPlugins starts the plugins in order, stopping the started ones if any fails.
+func (srv *Server) startPlugins(ctx context.Context) error {
+	for i, p := range srv.config.Plugins {
+		if err := p.Start(ctx, srv); err != nil {
+			return multierr.Append(
+				fmt.Errorf("start plugin %T: %w", p, err),
+				stopPlugins(srv.config.Plugins[:i]))
+		}
+	}
+	return nil
+}
+
+// stopPlugins stops the plugins in the reverse order.
+func stopPlugins(plugins []Plugin) error {
+	if len(plugins) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginStopTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(plugins) - 1; i >= 0; i-- {
+		if err := plugins[i].Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop plugin %T: %w", plugins[i], err))
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// notifyPlugins calls the SessionPlugin of the plugins with the session.
+func notifyPlugins(plugins []Plugin, f func(p SessionPlugin)) {
+	for _, p := range plugins {
+		if sp, ok := p.(SessionPlugin); ok {
+			f(sp)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pluginCalls are the lifecycle calls of the plugins of a server, in order.
+type pluginCalls struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+// recordingPlugin records the lifecycle calls in the calls shared with the other plugins.
+type recordingPlugin struct {
+	name     string
+	startErr error
+	calls    *pluginCalls
+	srv      *Server
+}
+
+func (p *recordingPlugin) record(call string) {
+	p.calls.mu.Lock()
+	defer p.calls.mu.Unlock()
+	p.calls.calls = append(p.calls.calls, p.name+"."+call)
+}
+
+func (p *recordingPlugin) recorded() []string {
+	p.calls.mu.Lock()
+	defer p.calls.mu.Unlock()
+	return append([]string(nil), p.calls.calls...)
+}
+
+func (p *recordingPlugin) Start(_ context.Context, srv *Server) error {
+	p.srv = srv
+	p.record("start")
+	return p.startErr
+}
+
+func (p *recordingPlugin) Stop(context.Context) error {
+	p.record("stop")
+	return nil
+}
+
+// sessionPlugin is a recordingPlugin that handles the '/plugin' commands and records the session events.
+type sessionPlugin struct {
+	recordingPlugin
+}
+
+func (p *sessionPlugin) RegisterHandlers(mux *EnvelopeMux) {
+	mux.RequestCommandHandlerFunc(
+		func(cmd *RequestCommand) bool {
+			return cmd.URI.Path() == "/plugin"
+		},
+		func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+			return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+		})
+}
+
+func (p *sessionPlugin) SessionEstablished(_ context.Context, c *ServerChannel) {
+	p.record("established")
+}
+
+func (p *sessionPlugin) SessionFinished(_ context.Context, c *ServerChannel) {
+	p.record("finished")
+}
+
+func buildPluginServer(addr InProcessAddr, plugins ...Plugin) *Server {
+	b := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication()
+	for _, p := range plugins {
+		b.Plugin(p)
+	}
+	srv := b.Build()
+	acceptAnyGuest(srv)
+	return srv
+}
+
+func TestServer_Plugin_Lifecycle(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("plugin-lifecycle")
+	calls := &pluginCalls{}
+	first := &recordingPlugin{name: "first", calls: calls}
+	second := &sessionPlugin{recordingPlugin{name: "second", calls: calls}}
+	srv := buildPluginServer(addr, first, second)
+	startServer(t, srv)
+
+	// Act
+	client := establishInProcGuestSession(t, ctx, addr, "user")
+	cmd := &RequestCommand{}
+	cmd.SetURIString("/plugin").SetMethod(CommandMethodGet)
+	cmd.ID = NewEnvelopeID()
+	respCmd, err := client.ProcessCommand(ctx, cmd)
+	sessions := srv.Sessions()
+	_ = client.Close()
+	assert.Eventually(t, func() bool {
+		return len(srv.Sessions()) == 0
+	}, 100*time.Millisecond, 5*time.Millisecond)
+	closeErr := srv.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	assert.Len(t, sessions, 1)
+	assert.NoError(t, closeErr)
+	assert.Equal(t, []string{
+		"first.start",
+		"second.start",
+		"second.established",
+		"second.finished",
+		"second.stop",
+		"first.stop",
+	}, first.recorded())
+	assert.Equal(t, srv, first.srv)
+}
+
+func TestServer_Plugin_StartError(t *testing.T) {
+	// Arrange
+	addr := InProcessAddr("plugin-start-error")
+	calls := &pluginCalls{}
+	startErr := errors.New("store unavailable")
+	first := &recordingPlugin{name: "first", calls: calls}
+	second := &recordingPlugin{name: "second", calls: calls, startErr: startErr}
+	third := &recordingPlugin{name: "third", calls: calls}
+	srv := buildPluginServer(addr, first, second, third)
+
+	// Act
+	err := srv.ListenAndServe()
+
+	// Assert
+	assert.ErrorIs(t, err, startErr)
+	assert.Equal(t, []string{"first.start", "second.start", "first.stop"}, first.recorded())
+	assert.Error(t, srv.Close())
+}
+
+func TestServer_SessionByID(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("plugin-session-by-id")
+	srv := startAdminServer(t, addr, &auditRecorder{})
+	defer silentClose(srv)
+	client := establishInProcGuestSession(t, ctx, addr, "user")
+	defer silentClose(client)
+
+	// Act
+	c, ok := srv.SessionByID(client.sessionID)
+	_, unknown := srv.SessionByID("unknown")
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, client.sessionID, c.ID())
+	assert.False(t, unknown)
+}
@@ -0,0 +1,236 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Policy is a compiled expression over the fields of an envelope, like a routing or filtering rule defined in the
+// configuration:
+//
+//	from.domain == "msging.net" && type == "message"
+//
+// The expression is compiled once and evaluated for each envelope, with the following variables:
+//
+//	type        the envelope type: "message", "notification", "command" or "response"
+//	id          the envelope id
+//	from        the sender node, with the name, domain and instance fields
+//	pp          the delegate node, with the same fields of from
+//	to          the destination node, with the same fields of from
+//	metadata    the envelope metadata
+//	mediaType   the media type of the message content or command resource, like "text/plain"
+//	content     the message content, as its JSON value
+//	event       the notification event
+//	method      the command method
+//	uri         the request command URI
+//	status      the response command status
+//	resource    the command resource, as its JSON value
+//	reason      the notification or response reason, with the code and description fields
+//
+// The variables that do not apply to the envelope type are null. The expressions use a restricted language with the
+// CEL syntax, not the full CEL, which is described in policy_expr.go.
+type Policy struct {
+	expr string
+	root policyNode
+	uses map[string]bool // uses are the variables referenced by the expression
+}
+
+// policyVariables are the variables declared for the policy expressions.
+var policyVariables = map[string]bool{
+	"type": true, "id": true, "from": true, "pp": true, "to": true, "metadata": true, "mediaType": true,
+	"content": true, "event": true, "method": true, "uri": true, "status": true, "resource": true, "reason": true,
+}
+
+// CompilePolicy compiles the policy expression, failing if it is not well-formed or references an undeclared
+// variable or function.
+func CompilePolicy(expr string) (*Policy, error) {
+	root, uses, err := parsePolicy(expr, policyVariables)
+	if err != nil {
+		return nil, fmt.Errorf("compile policy: %w", err)
+	}
+	return &Policy{expr: expr, root: root, uses: uses}, nil
+}
+
+// MustCompilePolicy is like CompilePolicy but panics if the expression cannot be compiled.
+func MustCompilePolicy(expr string) *Policy {
+	p, err := CompilePolicy(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source expression of the policy.
+func (p *Policy) String() string {
+	return p.expr
+}
+
+// Eval evaluates the policy for the envelope, which should be a *Message, *Notification, *RequestCommand or
+// *ResponseCommand value. It fails if the expression does not result in a bool value.
+func (p *Policy) Eval(e envelope) (bool, error) {
+	vars, err := p.variables(e)
+	if err != nil {
+		return false, fmt.Errorf("eval policy: %w", err)
+	}
+	v, err := p.root.eval(vars)
+	if err != nil {
+		return false, fmt.Errorf("eval policy: %w", err)
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("eval policy: expected a bool result, got %v", policyTypeName(v))
+	}
+	return b, nil
+}
+
+// match evaluates the policy, considering the evaluation errors as a mismatch.
+func (p *Policy) match(e envelope) bool {
+	ok, err := p.Eval(e)
+	return ok && err == nil
+}
+
+// MessagePredicate returns a predicate for the messages that match the policy, for routing them to a handler.
+func (p *Policy) MessagePredicate() MessagePredicate {
+	return func(msg *Message) bool {
+		return p.match(msg)
+	}
+}
+
+// NotificationPredicate returns a predicate for the notifications that match the policy.
+func (p *Policy) NotificationPredicate() NotificationPredicate {
+	return func(not *Notification) bool {
+		return p.match(not)
+	}
+}
+
+// RequestCommandPredicate returns a predicate for the request commands that match the policy.
+func (p *Policy) RequestCommandPredicate() RequestCommandPredicate {
+	return func(cmd *RequestCommand) bool {
+		return p.match(cmd)
+	}
+}
+
+// ResponseCommandPredicate returns a predicate for the response commands that match the policy.
+func (p *Policy) ResponseCommandPredicate() ResponseCommandPredicate {
+	return func(cmd *ResponseCommand) bool {
+		return p.match(cmd)
+	}
+}
+
+// variables returns the values of the variables for the envelope. The documents are only converted if referenced
+// by the expression.
+func (p *Policy) variables(e envelope) (map[string]interface{}, error) {
+	env := envelopeOf(e)
+	vars := map[string]interface{}{
+		"id":       env.ID,
+		"from":     policyNodeValue(env.From),
+		"pp":       policyNodeValue(env.PP),
+		"to":       policyNodeValue(env.To),
+		"metadata": policyMetadataValue(env.Metadata),
+	}
+	var (
+		doc    Document
+		docVar string
+	)
+	switch e := e.(type) {
+	case *Message:
+		vars["type"] = "message"
+		vars["mediaType"] = e.Type.String()
+		doc, docVar = e.Content, "content"
+	case *Notification:
+		vars["type"] = "notification"
+		vars["event"] = string(e.Event)
+		vars["reason"] = policyReasonValue(e.Reason)
+	case *RequestCommand:
+		vars["type"] = "command"
+		vars["method"] = string(e.Method)
+		if e.URI != nil {
+			vars["uri"] = e.URI.String()
+		}
+		if e.Type != nil {
+			vars["mediaType"] = e.Type.String()
+		}
+		doc, docVar = e.Resource, "resource"
+	case *ResponseCommand:
+		vars["type"] = "response"
+		vars["method"] = string(e.Method)
+		vars["status"] = string(e.Status)
+		vars["reason"] = policyReasonValue(e.Reason)
+		if e.Type != nil {
+			vars["mediaType"] = e.Type.String()
+		}
+		doc, docVar = e.Resource, "resource"
+	default:
+		return nil, fmt.Errorf("unsupported envelope type %T", e)
+	}
+	if doc != nil && p.uses[docVar] {
+		v, err := documentToMapValue(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", docVar, err)
+		}
+		vars[docVar] = v
+	}
+	return vars, nil
+}
+
+func policyNodeValue(n Node) interface{} {
+	if n == (Node{}) {
+		return nil
+	}
+	return map[string]interface{}{"name": n.Name, "domain": n.Domain, "instance": n.Instance}
+}
+
+func policyMetadataValue(metadata map[string]string) interface{} {
+	m := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		m[k] = v
+	}
+	return m
+}
+
+func policyReasonValue(r *Reason) interface{} {
+	if r == nil {
+		return nil
+	}
+	return map[string]interface{}{"code": float64(r.Code), "description": r.Description}
+}
+
+// Filter defines a policy for the received envelopes, which are only handled if they match it. The rejected messages
+// and request commands are replied with a failure with the ReasonCodeAuthorizationError code, while the other
+// envelopes are discarded. Multiple filters can be defined, and the envelopes must match all of them.
+func (m *EnvelopeMux) Filter(policy *Policy) {
+	if policy == nil {
+		panic("nil policy")
+	}
+	m.filters = append(m.filters, policy)
+}
+
+// SetFilters defines the policies that the envelopes received by the channel must match, in addition to the filters
+// of the EnvelopeMux that listens to it.
+func (c *channel) SetFilters(policies ...*Policy) {
+	if err := c.ensureState(SessionStateNew, "set filters"); err != nil {
+		panic(err)
+	}
+	for _, p := range policies {
+		if p == nil {
+			panic("nil policy")
+		}
+	}
+	c.filters = slices.Clone(policies)
+}
+
+// filterEnvelope returns the reason for rejecting the envelope, or nil if it matches the filters of the mux and of
+// the channel.
+func (m *EnvelopeMux) filterEnvelope(ctx context.Context, c *channel, e envelope) *Reason {
+	for _, filters := range [][]*Policy{m.filters, c.filters} {
+		for _, p := range filters {
+			if !p.match(e) {
+				env := envelopeOf(e)
+				AuditAuthorizationDenied(ctx, env.Sender().Identity.String(), "The envelope was rejected by the filter "+p.expr)
+				return NewReason(ReasonCodeAuthorizationError, "The envelope was rejected by the filter")
+			}
+		}
+	}
+	return nil
+}
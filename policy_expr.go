@@ -0,0 +1,701 @@
+package lime
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The policy expressions are a restricted language of their own, which borrows its syntax from CEL
+// (https://github.com/google/cel-spec) but is not a CEL implementation. Only the following constructs are supported:
+//
+//	literals:    "text", 'text', 12, 1.5, true, false, null, ["a", "b"]
+//	selection:   from.domain, metadata["#key"], list[0]
+//	operators:   !, -, ==, !=, <, <=, >, >=, in, &&, ||, ( )
+//	functions:   has(x.field), size(x), s.startsWith(p), s.endsWith(p), s.contains(p), s.matches(regex)
+//
+// Everything else of CEL is not available: the arithmetic and conditional operators, the macros like all, exists
+// and map, the type conversions, the timestamps and durations, and the static type checking, so the type errors are
+// only reported by the evaluation. The numbers are float64 values, like the ones decoded from JSON.
+// Unlike CEL, the selection of a missing field evaluates to null instead of failing, so optional fields can be
+// compared directly, like metadata["#key"] == null.
+//
+// The cel-go and Starlark interpreters were not embedded because they would add a large dependency tree to the
+// module, which has only a few direct dependencies: cel-go requires the ANTLR runtime, protobuf and the genproto
+// packages, and its recent versions a newer Go release than the one of the module. The routing and filtering rules
+// only compare the fields of an envelope, which this small evaluator covers. If a richer language is needed, the
+// Policy type is the place for swapping the engine, since its API does not expose the evaluator.
+
+// policyNode is a node of a compiled policy expression.
+type policyNode interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type policyLiteral struct {
+	value interface{}
+}
+
+func (n *policyLiteral) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type policyIdent struct {
+	name string
+}
+
+func (n *policyIdent) eval(vars map[string]interface{}) (interface{}, error) {
+	return vars[n.name], nil
+}
+
+type policySelect struct {
+	x     policyNode
+	field string
+}
+
+func (n *policySelect) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return x[n.field], nil
+	default:
+		return nil, fmt.Errorf("cannot select '%v' of %v", n.field, policyTypeName(x))
+	}
+}
+
+type policyIndex struct {
+	x, index policyNode
+}
+
+func (n *policyIndex) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.index.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid map key of %v", policyTypeName(index))
+		}
+		return x[key], nil
+	case []interface{}:
+		f, ok := index.(float64)
+		if !ok || f != float64(int(f)) {
+			return nil, fmt.Errorf("invalid list index %v", index)
+		}
+		if i := int(f); i >= 0 && i < len(x) {
+			return x[i], nil
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("cannot index %v", policyTypeName(x))
+	}
+}
+
+type policyList struct {
+	items []policyNode
+}
+
+func (n *policyList) eval(vars map[string]interface{}) (interface{}, error) {
+	list := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+type policyUnary struct {
+	op string
+	x  policyNode
+}
+
+func (n *policyUnary) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator '!' is not defined for %v", policyTypeName(x))
+		}
+		return !b, nil
+	default:
+		f, ok := x.(float64)
+		if !ok {
+			return nil, fmt.Errorf("operator '-' is not defined for %v", policyTypeName(x))
+		}
+		return -f, nil
+	}
+}
+
+type policyBinary struct {
+	op   string
+	l, r policyNode
+}
+
+func (n *policyBinary) eval(vars map[string]interface{}) (interface{}, error) {
+	l, err := n.l.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator '%v' is not defined for %v", n.op, policyTypeName(l))
+		}
+		// The logical operators short-circuit, so the right side can assume the left one
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		r, err := n.r.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := r.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator '%v' is not defined for %v", n.op, policyTypeName(r))
+		}
+		return rb, nil
+	}
+
+	r, err := n.r.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return policyEqual(l, r), nil
+	case "!=":
+		return !policyEqual(l, r), nil
+	case "in":
+		switch r := r.(type) {
+		case nil:
+			return false, nil
+		case []interface{}:
+			for _, item := range r {
+				if policyEqual(l, item) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]interface{}:
+			key, ok := l.(string)
+			if !ok {
+				return false, nil
+			}
+			_, ok = r[key]
+			return ok, nil
+		default:
+			return nil, fmt.Errorf("operator 'in' is not defined for %v", policyTypeName(r))
+		}
+	default:
+		c, err := policyCompare(l, r)
+		if err != nil {
+			return nil, fmt.Errorf("operator '%v': %w", n.op, err)
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	}
+}
+
+// policyHas is the has(x.field) macro, which tests the presence of the field.
+type policyHas struct {
+	sel *policySelect
+}
+
+func (n *policyHas) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.sel.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := x.(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	_, ok = m[n.sel.field]
+	return ok, nil
+}
+
+type policySize struct {
+	x policyNode
+}
+
+func (n *policySize) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case string:
+		return float64(utf8.RuneCountInString(x)), nil
+	case []interface{}:
+		return float64(len(x)), nil
+	case map[string]interface{}:
+		return float64(len(x)), nil
+	default:
+		return nil, fmt.Errorf("size is not defined for %v", policyTypeName(x))
+	}
+}
+
+// policyStringCall is a string method call, like s.startsWith("prefix").
+type policyStringCall struct {
+	method string
+	x, arg policyNode
+	re     *regexp.Regexp // re is the compiled pattern of a matches call with a literal argument
+}
+
+func (n *policyStringCall) eval(vars map[string]interface{}) (interface{}, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	arg, err := n.arg.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if x == nil {
+		return false, nil
+	}
+	s, ok := x.(string)
+	if !ok {
+		return nil, fmt.Errorf("%v is not defined for %v", n.method, policyTypeName(x))
+	}
+	a, ok := arg.(string)
+	if !ok {
+		return nil, fmt.Errorf("%v argument must be a string, got %v", n.method, policyTypeName(arg))
+	}
+	switch n.method {
+	case "startsWith":
+		return strings.HasPrefix(s, a), nil
+	case "endsWith":
+		return strings.HasSuffix(s, a), nil
+	case "contains":
+		return strings.Contains(s, a), nil
+	default:
+		re := n.re
+		if re == nil {
+			if re, err = regexp.Compile(a); err != nil {
+				return nil, err
+			}
+		}
+		return re.MatchString(s), nil
+	}
+}
+
+func policyEqual(l, r interface{}) bool {
+	return reflect.DeepEqual(l, r)
+}
+
+func policyCompare(l, r interface{}) (int, error) {
+	switch l := l.(type) {
+	case float64:
+		if r, ok := r.(float64); ok {
+			switch {
+			case l < r:
+				return -1, nil
+			case l > r:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	case string:
+		if r, ok := r.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %v and %v", policyTypeName(l), policyTypeName(r))
+}
+
+func policyTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// policyToken is a lexical token of a policy expression.
+type policyToken struct {
+	kind  policyTokenKind
+	text  string
+	value interface{} // value is the literal value of the string and number tokens
+	pos   int
+}
+
+type policyTokenKind int
+
+const (
+	policyTokenEOF policyTokenKind = iota
+	policyTokenIdent
+	policyTokenString
+	policyTokenNumber
+	policyTokenOperator
+)
+
+func tokenizePolicy(expr string) ([]policyToken, error) {
+	var tokens []policyToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(expr) && expr[j] != c {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at %v", i)
+			}
+			s, err := unquotePolicyString(expr[i+1:j], c)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %v: %w", i, err)
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenString, text: expr[i : j+1], value: s, pos: i})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			f, err := strconv.ParseFloat(expr[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at %v: %w", i, err)
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenNumber, text: expr[i:j], value: f, pos: i})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(expr) && (expr[j] == '_' || unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j]))) {
+				j++
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenIdent, text: expr[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "-", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character '%c' at %v", c, i)
+			}
+			tokens = append(tokens, policyToken{kind: policyTokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, policyToken{kind: policyTokenEOF, pos: len(expr)}), nil
+}
+
+func unquotePolicyString(s string, quote byte) (string, error) {
+	if quote == '\'' {
+		// Converts to a double-quoted string, escaping the double quotes
+		s = strings.ReplaceAll(strings.ReplaceAll(s, `\'`, `'`), `"`, `\"`)
+	}
+	return strconv.Unquote(`"` + s + `"`)
+}
+
+// policyParser is a recursive descent parser of policy expressions.
+type policyParser struct {
+	tokens []policyToken
+	pos    int
+	vars   map[string]bool // vars are the declared variables
+	uses   map[string]bool // uses are the variables referenced by the expression
+}
+
+func parsePolicy(expr string, vars map[string]bool) (policyNode, map[string]bool, error) {
+	tokens, err := tokenizePolicy(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &policyParser{tokens: tokens, vars: vars, uses: make(map[string]bool)}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t := p.peek(); t.kind != policyTokenEOF {
+		return nil, nil, fmt.Errorf("unexpected '%v' at %v", t.text, t.pos)
+	}
+	return n, p.uses, nil
+}
+
+func (p *policyParser) peek() policyToken {
+	return p.tokens[p.pos]
+}
+
+func (p *policyParser) next() policyToken {
+	t := p.tokens[p.pos]
+	if t.kind != policyTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator or keyword.
+func (p *policyParser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == policyTokenOperator || t.kind == policyTokenIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *policyParser) expect(text string) error {
+	if !p.accept(text) {
+		t := p.peek()
+		if t.kind == policyTokenEOF {
+			return fmt.Errorf("expected '%v' at the end", text)
+		}
+		return fmt.Errorf("expected '%v' at %v, got '%v'", text, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *policyParser) parseOr() (policyNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &policyBinary{op: "||", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *policyParser) parseAnd() (policyNode, error) {
+	l, err := p.parseRelation()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		r, err := p.parseRelation()
+		if err != nil {
+			return nil, err
+		}
+		l = &policyBinary{op: "&&", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *policyParser) parseRelation() (policyNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+			if p.accept(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &policyBinary{op: op, l: l, r: r}
+	}
+}
+
+func (p *policyParser) parseUnary() (policyNode, error) {
+	for _, op := range []string{"!", "-"} {
+		if p.accept(op) {
+			x, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &policyUnary{op: op, x: x}, nil
+		}
+	}
+	return p.parseMember()
+}
+
+func (p *policyParser) parseMember() (policyNode, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != policyTokenIdent {
+				return nil, fmt.Errorf("expected a field name at %v", t.pos)
+			}
+			if p.accept("(") {
+				if x, err = p.parseMethod(x, t); err != nil {
+					return nil, err
+				}
+			} else {
+				x = &policySelect{x: x, field: t.text}
+			}
+		case p.accept("["):
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &policyIndex{x: x, index: index}
+		default:
+			return x, nil
+		}
+	}
+}
+
+// parseMethod parses the arguments of a method call, after the opening parenthesis.
+func (p *policyParser) parseMethod(x policyNode, name policyToken) (policyNode, error) {
+	switch name.text {
+	case "startsWith", "endsWith", "contains", "matches":
+	default:
+		return nil, fmt.Errorf("undeclared function '%v' at %v", name.text, name.pos)
+	}
+	arg, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	call := &policyStringCall{method: name.text, x: x, arg: arg}
+	if lit, ok := arg.(*policyLiteral); ok && call.method == "matches" {
+		pattern, ok := lit.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("matches argument must be a string at %v", name.pos)
+		}
+		// The literal patterns are compiled once, with the expression
+		if call.re, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern at %v: %w", name.pos, err)
+		}
+	}
+	return call, nil
+}
+
+func (p *policyParser) parsePrimary() (policyNode, error) {
+	t := p.next()
+	switch t.kind {
+	case policyTokenString, policyTokenNumber:
+		return &policyLiteral{value: t.value}, nil
+	case policyTokenIdent:
+		switch t.text {
+		case "true":
+			return &policyLiteral{value: true}, nil
+		case "false":
+			return &policyLiteral{value: false}, nil
+		case "null":
+			return &policyLiteral{value: nil}, nil
+		case "has", "size":
+			if p.accept("(") {
+				return p.parseFunction(t)
+			}
+		}
+		if !p.vars[t.text] {
+			return nil, fmt.Errorf("undeclared reference to '%v' at %v", t.text, t.pos)
+		}
+		p.uses[t.text] = true
+		return &policyIdent{name: t.text}, nil
+	case policyTokenOperator:
+		switch t.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			list := &policyList{}
+			for !p.accept("]") {
+				if len(list.items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+			}
+			return list, nil
+		}
+	case policyTokenEOF:
+		return nil, errors.New("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected '%v' at %v", t.text, t.pos)
+}
+
+// parseFunction parses the arguments of a global function call, after the opening parenthesis.
+func (p *policyParser) parseFunction(name policyToken) (policyNode, error) {
+	x, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	if name.text == "size" {
+		return &policySize{x: x}, nil
+	}
+	sel, ok := x.(*policySelect)
+	if !ok {
+		return nil, fmt.Errorf("has argument must be a field selection at %v", name.pos)
+	}
+	return &policyHas{sel: sel}, nil
+}
@@ -0,0 +1,260 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPolicy_Eval(t *testing.T) {
+	// Arrange
+	msg := createMessage()
+	msg.From = ParseNode("john@msging.net/home")
+	msg.SetMetadataKeyValue("#tenant", "acme")
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`from.domain == "msging.net" && type == "message"`, true},
+		{`from.domain == "msging.net" && type == "notification"`, false},
+		{`from.name == 'john' || to.name == 'john'`, true},
+		{`!(from.instance == "home")`, false},
+		{`mediaType == "text/plain" && content == "Hello world"`, true},
+		{`content.startsWith("Hello") && content.endsWith("world") && content.contains("o w")`, true},
+		{`to.domain.matches("^limeprotocol\\.(org|net)$")`, true},
+		{`metadata["#tenant"] in ["acme", "globex"]`, true},
+		{`"#tenant" in metadata && !("#other" in metadata)`, true},
+		{`metadata["#other"] == null && pp == null && event == null`, true},
+		{`has(from.domain) && !has(from.other)`, true},
+		{`size(content) == 11 && size(metadata) >= 1`, true},
+		{`size(content) > 11 || -size(content) < -20`, false},
+		{`id < "5" && id >= "4"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := CompilePolicy(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Act
+			actual, err := p.Eval(msg)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestPolicy_Eval_Commands(t *testing.T) {
+	// Arrange
+	reqCmd := createGetPingCommand()
+	respCmd := createResponseCommand()
+	respCmd.SetStatusFailure(Reason{Code: ReasonCodeCommandResourceNotFound, Description: "Not found"})
+	p := MustCompilePolicy(`(type == "command" && method == "get" && uri.startsWith("/ping")) || (type == "response" && status == "failure" && reason.code == 67)`)
+
+	// Act
+	ok1, err1 := p.Eval(reqCmd)
+	ok2, err2 := p.Eval(respCmd)
+	ok3, err3 := p.Eval(createNotification())
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+	assert.True(t, ok1)
+	assert.True(t, ok2)
+	assert.False(t, ok3)
+}
+
+func TestPolicy_Eval_Resource(t *testing.T) {
+	// Arrange
+	cmd := createSetCommand()
+	cmd.SetResource(createJsonDocument())
+	p := MustCompilePolicy(`resource.property3.subproperty1 == "subvalue1" && resource.property2 == 2`)
+
+	// Act
+	actual, err := p.Eval(cmd)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, actual)
+}
+
+func TestPolicy_Eval_NotBool(t *testing.T) {
+	// Arrange
+	p := MustCompilePolicy(`from.domain`)
+	msg := createMessage()
+	msg.From = ParseNode("john@msging.net/home")
+
+	// Act
+	_, err := p.Eval(msg)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestPolicy_Eval_TypeError(t *testing.T) {
+	// Arrange
+	p := MustCompilePolicy(`content > 1`)
+
+	// Act
+	_, err := p.Eval(createMessage())
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestCompilePolicy_Invalid(t *testing.T) {
+	tests := []string{
+		`from.domain ==`,
+		`sender.domain == "msging.net"`,
+		`from.domain == "msging.net`,
+		`content.upper() == "A"`,
+		`(type == "message"`,
+		`content.matches("(")`,
+		`has(from)`,
+		`type = "message"`,
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			// Act
+			p, err := CompilePolicy(expr)
+
+			// Assert
+			assert.Error(t, err)
+			assert.Nil(t, p)
+		})
+	}
+}
+
+func TestPolicy_MessagePredicate(t *testing.T) {
+	// Arrange
+	p := MustCompilePolicy(`from.domain == "msging.net"`)
+	msg1 := createMessage()
+	msg1.From = ParseNode("john@msging.net/home")
+	msg2 := createMessage()
+	msg2.From = ParseNode("john@limeprotocol.org/home")
+	predicate := p.MessagePredicate()
+
+	// Act
+	ok1 := predicate(msg1)
+	ok2 := predicate(msg2)
+
+	// Assert
+	assert.True(t, ok1)
+	assert.False(t, ok2)
+}
+
+func TestEnvelopeMux_Filter(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	handled := make(chan *Message, 2)
+	mux := &EnvelopeMux{}
+	mux.Filter(MustCompilePolicy(`from.domain == "msging.net"`))
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			handled <- msg
+			return nil
+		})
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+	rejected := createMessage()
+	rejected.From = ParseNode("john@limeprotocol.org/home")
+	accepted := createMessage()
+	accepted.ID = NewEnvelopeID()
+	accepted.From = ParseNode("john@msging.net/home")
+
+	// Act
+	_ = client.Send(ctx, rejected)
+	actual, err := client.Receive(ctx)
+	_ = client.Send(ctx, accepted)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.IsType(t, &Notification{}, actual) {
+		not := actual.(*Notification)
+		assert.Equal(t, rejected.ID, not.ID)
+		assert.Equal(t, NotificationEventFailed, not.Event)
+		assert.Equal(t, ReasonCodeAuthorizationError, not.Reason.Code)
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case msg := <-handled:
+		assert.Equal(t, accepted.ID, msg.ID)
+	}
+}
+
+func TestChannel_SetFilters(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.SetFilters(MustCompilePolicy(`type == "message"`))
+	c.setState(SessionStateEstablished)
+	handled := make(chan *Message, 2)
+	mux := &EnvelopeMux{}
+	mux.Filter(MustCompilePolicy(`from.domain == "msging.net"`))
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			handled <- msg
+			return nil
+		})
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+	rejected := createMessage()
+	rejected.From = ParseNode("john@limeprotocol.org/home")
+	not := &Notification{Event: NotificationEventReceived}
+	not.ID = NewEnvelopeID()
+	not.From = ParseNode("john@msging.net/home")
+	accepted := createMessage()
+	accepted.ID = NewEnvelopeID()
+	accepted.From = ParseNode("john@msging.net/home")
+
+	// Act
+	_ = client.Send(ctx, rejected)
+	actual, err := client.Receive(ctx)
+	_ = client.Send(ctx, not)
+	_ = client.Send(ctx, accepted)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.IsType(t, &Notification{}, actual) {
+		assert.Equal(t, rejected.ID, actual.(*Notification).ID)
+	}
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case msg := <-handled:
+		assert.Equal(t, accepted.ID, msg.ID)
+	}
+}
+
+func TestChannel_SetFilters_Established(t *testing.T) {
+	// Arrange
+	_, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+
+	// Act & Assert
+	assert.Panics(t, func() {
+		c.SetFilters(MustCompilePolicy(`type == "message"`))
+	})
+}
@@ -0,0 +1,47 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrUnexpectedResource is returned when the resource of a command response is not of the expected type.
+var ErrUnexpectedResource = errors.New("unexpected resource type")
+
+// ProcessCommandAs processes the command and returns the resource of the success response as the T type.
+// If the response has no resource, the T zero value is returned. If the response is a failure, the returned error
+// wraps its Reason, which can be obtained with errors.As.
+// A T value type, like TextDocument, also accepts a resource of the *T type.
+func ProcessCommandAs[T Document](ctx context.Context, processor CommandProcessor, cmd *RequestCommand) (T, error) {
+	var v T
+	if processor == nil {
+		panic("nil processor")
+	}
+
+	respCmd, err := processor.ProcessCommand(ctx, cmd)
+	if err != nil {
+		return v, fmt.Errorf("process command: %w", err)
+	}
+	if respCmd.Status != CommandStatusSuccess {
+		if respCmd.Reason == nil {
+			return v, errors.New("process command: command failed")
+		}
+		return v, fmt.Errorf("process command: command failed: %w", respCmd.Reason)
+	}
+	return resourceAs[T](respCmd.Resource)
+}
+
+func resourceAs[T Document](resource Document) (T, error) {
+	var v T
+	if resource == nil {
+		return v, nil
+	}
+	if r, ok := resource.(T); ok {
+		return r, nil
+	}
+	if p, ok := any(resource).(*T); ok && p != nil {
+		return *p, nil
+	}
+	return v, fmt.Errorf("process command: %w '%v'", ErrUnexpectedResource, resource.MediaType())
+}
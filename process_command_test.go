@@ -0,0 +1,80 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type commandProcessorFunc func(ctx context.Context, cmd *RequestCommand) (*ResponseCommand, error)
+
+func (f commandProcessorFunc) ProcessCommand(ctx context.Context, cmd *RequestCommand) (*ResponseCommand, error) {
+	return f(ctx, cmd)
+}
+
+func respondWith(resource Document) CommandProcessor {
+	return commandProcessorFunc(func(ctx context.Context, cmd *RequestCommand) (*ResponseCommand, error) {
+		if resource == nil {
+			return cmd.SuccessResponse(), nil
+		}
+		return cmd.SuccessResponseWithResource(resource), nil
+	})
+}
+
+func TestProcessCommandAs(t *testing.T) {
+	// Arrange
+	redirect := &Redirect{Address: "net.tcp://server2:55321"}
+
+	// Act
+	actual, err := ProcessCommandAs[*Redirect](context.Background(), respondWith(redirect), createGetPingCommand())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Same(t, redirect, actual)
+}
+
+func TestProcessCommandAs_ValueType(t *testing.T) {
+	// Arrange
+	d := TextDocument("Hello world")
+
+	// Act
+	actual, err := ProcessCommandAs[TextDocument](context.Background(), respondWith(&d), createGetPingCommand())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, d, actual)
+}
+
+func TestProcessCommandAs_NoResource(t *testing.T) {
+	// Act
+	actual, err := ProcessCommandAs[*Redirect](context.Background(), respondWith(nil), createGetPingCommand())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Nil(t, actual)
+}
+
+func TestProcessCommandAs_UnexpectedResource(t *testing.T) {
+	// Act
+	_, err := ProcessCommandAs[*Redirect](context.Background(), respondWith(&Ping{}), createGetPingCommand())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrUnexpectedResource)
+}
+
+func TestProcessCommandAs_Failure(t *testing.T) {
+	// Arrange
+	processor := commandProcessorFunc(func(ctx context.Context, cmd *RequestCommand) (*ResponseCommand, error) {
+		return cmd.FailureResponse(NotFoundReason("")), nil
+	})
+
+	// Act
+	_, err := ProcessCommandAs[*Redirect](context.Background(), processor, createGetPingCommand())
+
+	// Assert
+	var reason *Reason
+	if assert.True(t, errors.As(err, &reason)) {
+		assert.Equal(t, ReasonCodeCommandResourceNotFound, reason.Code)
+	}
+}
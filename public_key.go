@@ -0,0 +1,124 @@
+package lime
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PublicKeysPath is the URI path of the public keys of an identity, which allows the distribution of the keys for
+// the end-to-end encryption of the envelope contents. A set command publishes a key of the session identity and a
+// get command to 'lime://name@domain/public-keys' returns the keys of the identity.
+const PublicKeysPath = "/public-keys"
+
+// PublicKey represents a public key published by an identity.
+type PublicKey struct {
+	// ID is the key identifier, which allows the key rotation.
+	ID string `json:"id,omitempty"`
+	// Algorithm is the key algorithm, like 'x25519' or 'rsa-oaep-256'.
+	Algorithm string `json:"algorithm,omitempty"`
+	// Key is the base64 representation of the encoded public key.
+	Key string `json:"key,omitempty"`
+	// Expiration is the moment when the key should not be used anymore.
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+func MediaTypePublicKey() MediaType {
+	return MediaType{
+		Type:    "application",
+		Subtype: "vnd.lime.public-key",
+		Suffix:  "json",
+	}
+}
+
+func (k *PublicKey) MediaType() MediaType {
+	return MediaTypePublicKey()
+}
+
+// SetKeyAsBase64 sets the encoded public key.
+func (k *PublicKey) SetKeyAsBase64(key []byte) {
+	k.Key = base64.StdEncoding.EncodeToString(key)
+}
+
+// GetKeyFromBase64 returns the encoded public key.
+func (k *PublicKey) GetKeyFromBase64() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(k.Key)
+}
+
+// Expired indicates if the key expiration has passed.
+func (k *PublicKey) Expired(now time.Time) bool {
+	return k.Expiration != nil && !now.Before(*k.Expiration)
+}
+
+// PublishPublicKey publishes a public key for the client identity.
+func (c *Client) PublishPublicKey(ctx context.Context, key *PublicKey) error {
+	if key == nil {
+		panic("nil key")
+	}
+	if key.Key == "" {
+		return errors.New("publish public key: the key is required")
+	}
+	cmd := &RequestCommand{}
+	cmd.SetURIString(PublicKeysPath).
+		SetMethod(CommandMethodSet).
+		SetResource(key).
+		SetNewEnvelopeID()
+
+	respCmd, err := c.ProcessCommand(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("publish public key: %w", err)
+	}
+	if respCmd.Status != CommandStatusSuccess {
+		return fmt.Errorf("publish public key: command failed: %v", respCmd.Reason)
+	}
+	return nil
+}
+
+// GetPublicKeys returns the public keys published by the specified identity, excluding the expired ones.
+func (c *Client) GetPublicKeys(ctx context.Context, identity Identity) ([]*PublicKey, error) {
+	uri, err := ParseLimeURI(fmt.Sprintf("%v://%v%v", URISchemeLime, identity, PublicKeysPath))
+	if err != nil {
+		return nil, fmt.Errorf("get public keys: %w", err)
+	}
+	cmd := &RequestCommand{}
+	cmd.SetURI(uri).
+		SetMethod(CommandMethodGet).
+		SetNewEnvelopeID()
+
+	respCmd, err := c.ProcessCommand(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("get public keys: %w", err)
+	}
+	if respCmd.Status != CommandStatusSuccess {
+		return nil, fmt.Errorf("get public keys: command failed: %v", respCmd.Reason)
+	}
+	return publicKeysFromResource(respCmd.Resource, clockOrSystem(c.config.Clock).Now())
+}
+
+func publicKeysFromResource(resource Document, now time.Time) ([]*PublicKey, error) {
+	var items []Document
+	switch d := resource.(type) {
+	case nil:
+		return nil, nil
+	case *PublicKey:
+		items = []Document{d}
+	case *DocumentCollection:
+		items = d.Items
+	default:
+		return nil, fmt.Errorf("get public keys: unexpected resource type '%v'", resource.MediaType())
+	}
+
+	keys := make([]*PublicKey, 0, len(items))
+	for _, item := range items {
+		key, ok := item.(*PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("get public keys: unexpected item type '%v'", item.MediaType())
+		}
+		if !key.Expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
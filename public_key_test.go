@@ -0,0 +1,99 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_PublishPublicKey_GetPublicKeys(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr1 := createLocalhostTCPAddress().(*net.TCPAddr)
+	var mu sync.Mutex
+	keys := make(map[string][]Document)
+	server := NewServerBuilder().
+		ListenTCP(addr1, nil).
+		EnableGuestAuthentication().
+		RequestCommandHandlerFunc(
+			func(cmd *RequestCommand) bool {
+				return cmd.URI.Path() == PublicKeysPath
+			},
+			func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if cmd.Method == CommandMethodSet {
+					node, _ := ContextSessionRemoteNode(ctx)
+					keys[node.Identity.String()] = append(keys[node.Identity.String()], cmd.Resource)
+					return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+				}
+				u := cmd.URI.URL()
+				owner := u.User.Username() + "@" + u.Host
+				return s.SendResponseCommand(ctx, cmd.SuccessResponseWithResource(
+					NewDocumentCollection(keys[owner], MediaTypePublicKey())))
+			}).
+		Build()
+	defer silentClose(server)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, ErrServerClosed) {
+			log.Println(err)
+		}
+	}()
+	config := NewClientConfig()
+	config.EncryptSelector = NoneEncryptionSelector
+	config.NewTransport = func(ctx context.Context) (Transport, error) {
+		return DialTcp(ctx, addr1, nil)
+	}
+	client := NewClient(config, &EnvelopeMux{})
+	defer silentClose(client)
+	key := &PublicKey{ID: "key1", Algorithm: "x25519"}
+	key.SetKeyAsBase64([]byte{1, 2, 3, 4})
+
+	// Act
+	err := client.PublishPublicKey(ctx, key)
+	actual, getErr := client.GetPublicKeys(ctx, Identity{Name: config.Node.Name, Domain: "localhost"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, []*PublicKey{key}, actual)
+}
+
+func TestPublicKeysFromResource_Expired(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	expired := &PublicKey{ID: "key1", Key: "AQID", Expiration: &past}
+	valid := &PublicKey{ID: "key2", Key: "BAUG", Expiration: &future}
+	resource := NewDocumentCollection([]Document{expired, valid}, MediaTypePublicKey())
+
+	// Act
+	keys, err := publicKeysFromResource(resource, now)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*PublicKey{valid}, keys)
+}
+
+func TestPublicKey_GetKeyFromBase64(t *testing.T) {
+	// Arrange
+	key := &PublicKey{}
+	key.SetKeyAsBase64([]byte("public"))
+
+	// Act
+	actual, err := key.GetKeyFromBase64()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("public"), actual)
+	assert.Equal(t, "cHVibGlj", key.Key)
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package lime
+
+// raceEnabled indicates that the tests run with the race detector, which changes the allocations.
+const raceEnabled = true
@@ -0,0 +1,197 @@
+package lime
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The reason codes defined by the protocol. The codes are grouped by the category in the tens digit, and the first
+// code of each group is the generic one for the category.
+const (
+	ReasonCodeGeneralError = 1
+
+	ReasonCodeSessionError                       = 11
+	ReasonCodeSessionRegistrationError           = 12
+	ReasonCodeSessionAuthenticationFailed        = 13
+	ReasonCodeSessionUnregisterFailed            = 14
+	ReasonCodeSessionInvalidActionForState       = 15
+	ReasonCodeSessionNegotiationTimeout          = 16
+	ReasonCodeSessionNegotiationInvalidOptions   = 17
+	ReasonCodeSessionInvalidSessionModeRequested = 18
+
+	ReasonCodeValidationError             = 21
+	ReasonCodeValidationEmptyDocument     = 22
+	ReasonCodeValidationInvalidResource   = 23
+	ReasonCodeValidationInvalidStatus     = 24
+	ReasonCodeValidationInvalidIdentity   = 25
+	ReasonCodeValidationInvalidRecipients = 26
+	ReasonCodeValidationInvalidMethod     = 27
+	ReasonCodeValidationInvalidURI        = 28
+
+	ReasonCodeAuthorizationError = 31
+	// ReasonCodeUnauthorizedSender is the reason code for the envelopes rejected because the sender is not allowed to
+	// send on behalf of the From identity.
+	ReasonCodeUnauthorizedSender               = 32
+	ReasonCodeAuthorizationDestinationNotFound = 33
+	ReasonCodeAuthorizationQuotaExceeded       = 34
+
+	ReasonCodeRoutingError               = 41
+	ReasonCodeRoutingDestinationNotFound = 42
+	ReasonCodeRoutingGatewayNotFound     = 43
+	ReasonCodeRoutingRouteNotFound       = 44
+
+	ReasonCodeDispatchError = 51
+
+	ReasonCodeCommandProcessingError      = 61
+	ReasonCodeCommandResourceNotSupported = 62
+	ReasonCodeCommandMethodNotSupported   = 63
+	ReasonCodeCommandInvalidArgument      = 64
+	ReasonCodeCommandInvalidSessionMode   = 65
+	ReasonCodeCommandNotAllowed           = 66
+	ReasonCodeCommandResourceNotFound     = 67
+
+	ReasonCodeMessageProcessingError        = 71
+	ReasonCodeMessageUnsupportedContentType = 72
+
+	ReasonCodeGatewayError                   = 81
+	ReasonCodeGatewayContentTypeNotSupported = 82
+	ReasonCodeGatewayDestinationNotFound     = 83
+	ReasonCodeGatewayNotSupported            = 84
+
+	ReasonCodeApplicationError = 101
+)
+
+// ReasonCodeInfo describes a reason code.
+type ReasonCodeInfo struct {
+	// Name is the short name of the code, like 'session_authentication_failed'.
+	Name string
+	// Description is the default human description of the code.
+	Description string
+}
+
+var (
+	reasonCodesMu sync.RWMutex
+	reasonCodes   = map[int]ReasonCodeInfo{
+		ReasonCodeGeneralError: {"general_error", "An unexpected error occurred"},
+
+		ReasonCodeSessionError:                       {"session_error", "A session error occurred"},
+		ReasonCodeSessionRegistrationError:           {"session_registration_error", "The session node could not be registered"},
+		ReasonCodeSessionAuthenticationFailed:        {"session_authentication_failed", "The session authentication failed"},
+		ReasonCodeSessionUnregisterFailed:            {"session_unregister_failed", "The session node could not be unregistered"},
+		ReasonCodeSessionInvalidActionForState:       {"session_invalid_action_for_state", "The action is not valid for the session state"},
+		ReasonCodeSessionNegotiationTimeout:          {"session_negotiation_timeout", "The session negotiation timed out"},
+		ReasonCodeSessionNegotiationInvalidOptions:   {"session_negotiation_invalid_options", "An invalid negotiation option was selected"},
+		ReasonCodeSessionInvalidSessionModeRequested: {"session_invalid_session_mode_requested", "The requested session mode is not valid"},
+
+		ReasonCodeValidationError:             {"validation_error", "The envelope is not valid"},
+		ReasonCodeValidationEmptyDocument:     {"validation_empty_document", "The document is empty"},
+		ReasonCodeValidationInvalidResource:   {"validation_invalid_resource", "The resource is not valid"},
+		ReasonCodeValidationInvalidStatus:     {"validation_invalid_status", "The status is not valid"},
+		ReasonCodeValidationInvalidIdentity:   {"validation_invalid_identity", "The identity is not valid"},
+		ReasonCodeValidationInvalidRecipients: {"validation_invalid_recipients", "The recipients are not valid"},
+		ReasonCodeValidationInvalidMethod:     {"validation_invalid_method", "The command method is not valid"},
+		ReasonCodeValidationInvalidURI:        {"validation_invalid_uri", "The command URI is not valid"},
+
+		ReasonCodeAuthorizationError:               {"authorization_error", "The action is not authorized"},
+		ReasonCodeUnauthorizedSender:               {"authorization_unauthorized_sender", "The sender is not authorized"},
+		ReasonCodeAuthorizationDestinationNotFound: {"authorization_destination_not_found", "The destination was not found"},
+		ReasonCodeAuthorizationQuotaExceeded:       {"authorization_quota_threshold_exceeded", "The quota threshold was exceeded"},
+
+		ReasonCodeRoutingError:               {"routing_error", "The envelope could not be routed"},
+		ReasonCodeRoutingDestinationNotFound: {"routing_destination_not_found", "The destination was not found"},
+		ReasonCodeRoutingGatewayNotFound:     {"routing_gateway_not_found", "The gateway was not found"},
+		ReasonCodeRoutingRouteNotFound:       {"routing_route_not_found", "The route was not found"},
+
+		ReasonCodeDispatchError: {"dispatch_error", "The envelope could not be dispatched"},
+
+		ReasonCodeCommandProcessingError:      {"command_processing_error", "The command could not be processed"},
+		ReasonCodeCommandResourceNotSupported: {"command_resource_not_supported", "The command resource is not supported"},
+		ReasonCodeCommandMethodNotSupported:   {"command_method_not_supported", "The command method is not supported"},
+		ReasonCodeCommandInvalidArgument:      {"command_invalid_argument", "The command argument is not valid"},
+		ReasonCodeCommandInvalidSessionMode:   {"command_invalid_session_mode", "The command is not valid for the session mode"},
+		ReasonCodeCommandNotAllowed:           {"command_not_allowed", "The command is not allowed"},
+		ReasonCodeCommandResourceNotFound:     {"command_resource_not_found", "The command resource was not found"},
+
+		ReasonCodeMessageProcessingError:        {"message_processing_error", "The message could not be processed"},
+		ReasonCodeMessageUnsupportedContentType: {"message_unsupported_content_type", "The message content type is not supported"},
+
+		ReasonCodeGatewayError:                   {"gateway_error", "A gateway error occurred"},
+		ReasonCodeGatewayContentTypeNotSupported: {"gateway_content_type_not_supported", "The content type is not supported by the gateway"},
+		ReasonCodeGatewayDestinationNotFound:     {"gateway_destination_not_found", "The gateway destination was not found"},
+		ReasonCodeGatewayNotSupported:            {"gateway_not_supported", "The operation is not supported by the gateway"},
+
+		ReasonCodeApplicationError: {"application_error", "An application error occurred"},
+	}
+)
+
+// RegisterReasonCode adds an application specific reason code to the registry, or replaces the information of an
+// existing code.
+func RegisterReasonCode(code int, info ReasonCodeInfo) {
+	if info.Name == "" {
+		panic("empty reason code name")
+	}
+	reasonCodesMu.Lock()
+	defer reasonCodesMu.Unlock()
+	reasonCodes[code] = info
+}
+
+// LookupReasonCode returns the information of a registered reason code.
+func LookupReasonCode(code int) (ReasonCodeInfo, bool) {
+	reasonCodesMu.RLock()
+	defer reasonCodesMu.RUnlock()
+	info, ok := reasonCodes[code]
+	return info, ok
+}
+
+// NewReason creates a Reason with the specified code. If the description is empty, the default description of the
+// code is used.
+func NewReason(code int, description string) *Reason {
+	if description == "" {
+		if info, ok := LookupReasonCode(code); ok {
+			description = info.Description
+		}
+	}
+	return &Reason{Code: code, Description: description}
+}
+
+// NotFoundReason creates a Reason for a resource that does not exist.
+func NotFoundReason(description string) *Reason {
+	return NewReason(ReasonCodeCommandResourceNotFound, description)
+}
+
+// UnauthorizedReason creates a Reason for an action that the node is not allowed to perform.
+func UnauthorizedReason(description string) *Reason {
+	return NewReason(ReasonCodeAuthorizationError, description)
+}
+
+// TimeoutReason creates a Reason for an operation that was not completed in time. The protocol has no specific code
+// for the timeouts, so the command processing error code is used.
+func TimeoutReason(description string) *Reason {
+	if description == "" {
+		description = "The command timed out"
+	}
+	return NewReason(ReasonCodeCommandProcessingError, description)
+}
+
+// InvalidArgumentReason creates a Reason for a command with an invalid resource or argument.
+func InvalidArgumentReason(description string) *Reason {
+	return NewReason(ReasonCodeCommandInvalidArgument, description)
+}
+
+// Name returns the registered name of the reason code, or an empty string if the code is not registered.
+func (r Reason) Name() string {
+	info, _ := LookupReasonCode(r.Code)
+	return info.Name
+}
+
+// Error implements the error interface, so a received failure reason can be returned as an error.
+func (r *Reason) Error() string {
+	name := r.Name()
+	if name == "" {
+		name = fmt.Sprintf("reason code %v", r.Code)
+	}
+	if r.Description == "" {
+		return name
+	}
+	return fmt.Sprintf("%v: %v", name, r.Description)
+}
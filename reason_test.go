@@ -0,0 +1,69 @@
+package lime
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewReason_DefaultDescription(t *testing.T) {
+	// Act
+	r := NewReason(ReasonCodeSessionAuthenticationFailed, "")
+
+	// Assert
+	assert.Equal(t, ReasonCodeSessionAuthenticationFailed, r.Code)
+	assert.Equal(t, "The session authentication failed", r.Description)
+	assert.Equal(t, "session_authentication_failed", r.Name())
+}
+
+func TestReason_Error(t *testing.T) {
+	testCases := []struct {
+		name     string
+		reason   *Reason
+		expected string
+	}{
+		{"NotFound", NotFoundReason("The contact was not found"), "command_resource_not_found: The contact was not found"},
+		{"Unauthorized", UnauthorizedReason(""), "authorization_error: The action is not authorized"},
+		{"Timeout", TimeoutReason(""), "command_processing_error: The command timed out"},
+		{"Unregistered", &Reason{Code: 999, Description: "Custom"}, "reason code 999: Custom"},
+		{"NoDescription", &Reason{Code: ReasonCodeDispatchError}, "dispatch_error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			actual := tc.reason.Error()
+
+			// Assert
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestReason_Error_As(t *testing.T) {
+	// Arrange
+	err := fmt.Errorf("process command: %w", NotFoundReason(""))
+
+	// Act
+	var reason *Reason
+	ok := errors.As(err, &reason)
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, ReasonCodeCommandResourceNotFound, reason.Code)
+}
+
+func TestRegisterReasonCode(t *testing.T) {
+	// Arrange
+	code := 1001
+	info := ReasonCodeInfo{Name: "order_not_paid", Description: "The order was not paid"}
+
+	// Act
+	RegisterReasonCode(code, info)
+
+	// Assert
+	actual, ok := LookupReasonCode(code)
+	assert.True(t, ok)
+	assert.Equal(t, info, actual)
+	assert.Equal(t, "order_not_paid: The order was not paid", NewReason(code, "").Error())
+}
@@ -0,0 +1,252 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrReauthenticationPending is returned when a re-authentication is requested while the previous one was not
+// completed by the remote node.
+var ErrReauthenticationPending = errors.New("re-authentication pending")
+
+// DefaultReauthenticationTimeout is the time that the clients have for completing a re-authentication, if not
+// defined by SetReauthenticationTimeout.
+const DefaultReauthenticationTimeout = 30 * time.Second
+
+// reauthentication holds the state of a re-authentication requested by the server while the session is established.
+type reauthentication struct {
+	mu           sync.Mutex
+	timeout      time.Duration
+	schemes      map[AuthenticationScheme]struct{}
+	authenticate func(context.Context, Identity, Authentication) (*AuthenticationResult, error)
+	pending      chan struct{} // pending is closed when the pending re-authentication is completed or failed
+}
+
+// complete clears the pending re-authentication. It should be called with the lock held.
+func (r *reauthentication) complete() {
+	r.authenticate = nil
+	if r.pending != nil {
+		close(r.pending)
+		r.pending = nil
+	}
+}
+
+// SetReauthenticationTimeout defines the time that the client has for completing a re-authentication, after which
+// the session is failed. If not defined, the DefaultReauthenticationTimeout is used.
+func (c *ServerChannel) SetReauthenticationTimeout(timeout time.Duration) {
+	if err := c.ensureState(SessionStateNew, "set reauthentication timeout"); err != nil {
+		panic(err)
+	}
+	if timeout <= 0 {
+		panic("the timeout should be positive")
+	}
+	c.reauth.timeout = timeout
+}
+
+// RequestReauthentication sends an "authenticating" session envelope with the scheme options to the established
+// client, which should reply with new credentials.
+// The session remains established during the re-authentication, and the credentials are verified by the channel
+// receiver using the authenticate func. If the authentication fails or the client tries to change its identity, the
+// session is failed, as well as if the client doesn't complete it before the re-authentication timeout.
+func (c *ServerChannel) RequestReauthentication(
+	ctx context.Context,
+	schemeOpts []AuthenticationScheme,
+	authenticate func(context.Context, Identity, Authentication) (*AuthenticationResult, error)) error {
+	if authenticate == nil {
+		panic("authenticate cannot be nil")
+	}
+	if len(schemeOpts) == 0 {
+		return errors.New("request reauthentication: there's no available options for authentication")
+	}
+	if err := c.ensureEstablished("request reauthentication"); err != nil {
+		return err
+	}
+
+	c.reauth.mu.Lock()
+	defer c.reauth.mu.Unlock()
+	if c.reauth.authenticate != nil {
+		return fmt.Errorf("request reauthentication: %w", ErrReauthenticationPending)
+	}
+
+	schemes := make(map[AuthenticationScheme]struct{}, len(schemeOpts))
+	for _, v := range schemeOpts {
+		schemes[v] = struct{}{}
+	}
+
+	ses := Session{
+		Envelope: Envelope{
+			ID:   c.sessionID,
+			From: c.localNode,
+			To:   c.remoteNode,
+		},
+		State:         SessionStateAuthenticating,
+		SchemeOptions: schemeOpts,
+	}
+	if err := c.sendSession(ctx, &ses); err != nil {
+		return fmt.Errorf("request reauthentication: %w", err)
+	}
+
+	c.reauth.schemes = schemes
+	c.reauth.authenticate = authenticate
+	c.reauth.pending = make(chan struct{})
+	c.expireReauthentication(c.reauth.pending)
+	return nil
+}
+
+// expireReauthentication fails the session if the pending re-authentication is not completed before the timeout.
+func (c *ServerChannel) expireReauthentication(pending chan struct{}) {
+	timeout := c.reauth.timeout
+	if timeout == 0 {
+		timeout = DefaultReauthenticationTimeout
+	}
+	timer := c.clock.NewTimer(timeout)
+	goLabeled(context.Background(), "server.reauthenticationTimeout", func(context.Context) {
+		defer timer.Stop()
+		select {
+		case <-pending:
+			return
+		case <-c.rcvDone:
+			return
+		case <-timer.C():
+		}
+
+		c.reauth.mu.Lock()
+		expired := c.reauth.pending == pending
+		if expired {
+			c.reauth.complete()
+		}
+		c.reauth.mu.Unlock()
+		if !expired {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		reason := NewReason(ReasonCodeSessionAuthenticationFailed, "The re-authentication was not completed in time")
+		if err := c.FailSession(ctx, reason); err != nil {
+			log.Printf("reauthenticate: %v", err)
+		}
+	}, "session", c.sessionID)
+}
+
+// reauthenticateEvery requests a re-authentication of the established session on each interval, until the context
+// is canceled or the session is finished.
+func (c *ServerChannel) reauthenticateEvery(
+	ctx context.Context,
+	interval time.Duration,
+	schemeOpts []AuthenticationScheme,
+	authenticate func(context.Context, Identity, Authentication) (*AuthenticationResult, error)) {
+	for sleep(ctx, c.clock, interval) == nil && c.Established() {
+		// The requests are skipped while the previous one is pending, which fails the session on timeout
+		if err := c.RequestReauthentication(ctx, schemeOpts, authenticate); err != nil && !errors.Is(err, ErrReauthenticationPending) {
+			log.Printf("reauthenticate: %v", err)
+		}
+	}
+}
+
+// handleReauthentication verifies the credentials sent by the client for a pending re-authentication.
+func (c *ServerChannel) handleReauthentication(ctx context.Context, ses *Session) bool {
+	if ses.State != SessionStateAuthenticating {
+		return false
+	}
+
+	c.reauth.mu.Lock()
+	defer c.reauth.mu.Unlock()
+	if c.reauth.authenticate == nil {
+		return false
+	}
+
+	if reason := c.reauthenticate(ctx, ses); reason != nil {
+		c.reauth.complete()
+		if err := c.failFromReceiver(ctx, reason); err != nil {
+			log.Printf("reauthenticate: %v", err)
+		}
+	}
+	return true
+}
+
+// reauthenticate returns the reason for failing the session, or nil if the re-authentication succeeded or requires
+// another round-trip. It should be called with the lock held.
+func (c *ServerChannel) reauthenticate(ctx context.Context, ses *Session) *Reason {
+	if ses.ID != c.sessionID {
+		return NewReason(ReasonCodeSessionError, "Invalid session id")
+	}
+	if _, ok := c.reauth.schemes[ses.Scheme]; !ok {
+		return NewReason(ReasonCodeSessionAuthenticationFailed, "An invalid authentication scheme was selected")
+	}
+	if ses.From.Identity != c.remoteNode.Identity {
+		return NewReason(ReasonCodeSessionAuthenticationFailed, "The session identity cannot be changed")
+	}
+
+	authResult, err := c.reauth.authenticate(ctx, ses.From.Identity, ses.Authentication)
+	if err != nil {
+		return NewReason(ReasonCodeSessionAuthenticationFailed, "The session authentication failed")
+	}
+
+	reply := Session{
+		Envelope: Envelope{
+			ID:   c.sessionID,
+			From: c.localNode,
+			To:   c.remoteNode,
+		},
+	}
+	if authResult.Role != "" && authResult.Role != DomainRoleUnknown {
+		c.reauth.complete()
+		reply.State = SessionStateEstablished
+	} else if authResult.RoundTrip != nil {
+		reply.State = SessionStateAuthenticating
+		reply.Authentication = authResult.RoundTrip
+	} else {
+		return NewReason(ReasonCodeSessionAuthenticationFailed, "The session authentication failed")
+	}
+
+	if err := c.sendSession(ctx, &reply); err != nil {
+		log.Printf("reauthenticate: %v", err)
+	}
+	return nil
+}
+
+// handleReauthentication replies the server requests for new credentials while the session is established, using
+// the authenticator of the session establishment.
+func (c *ClientChannel) handleReauthentication(ctx context.Context, ses *Session) bool {
+	switch ses.State {
+	case SessionStateEstablished:
+		// The server accepted the new credentials
+		return true
+	case SessionStateAuthenticating:
+		if c.authenticator == nil {
+			return false
+		}
+		authSes := Session{
+			Envelope: Envelope{
+				ID:   c.sessionID,
+				From: c.localNode,
+			},
+			State: SessionStateAuthenticating,
+		}
+		authSes.SetAuthentication(c.authenticator(ses.SchemeOptions, ses.Authentication))
+		if err := c.sendSession(ctx, &authSes); err != nil {
+			log.Printf("reauthenticate: %v", err)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// ReauthenticateSession requests new credentials from the remote node of the specified established session, which
+// are verified with the server authentication scheme options and authenticate func.
+func (srv *Server) ReauthenticateSession(ctx context.Context, sessionID string) error {
+	c, ok := srv.session(sessionID)
+	if !ok {
+		return fmt.Errorf("reauthenticate session: %w", ErrSessionNotFound)
+	}
+	config := srv.currentConfig()
+	if err := c.RequestReauthentication(ctx, config.SchemeOpts, config.Authenticate); err != nil {
+		return fmt.Errorf("reauthenticate session: %w", err)
+	}
+	return nil
+}
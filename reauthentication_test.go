@@ -0,0 +1,196 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func startReauthenticationServer(t *testing.T, addr InProcessAddr, results ...*AuthenticationResult) (*Server, *atomic.Int32) {
+	return startReauthenticationServerWith(t, NewServerBuilder(), addr, results...)
+}
+
+func startReauthenticationServerWith(t *testing.T, b *ServerBuilder, addr InProcessAddr, results ...*AuthenticationResult) (*Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := b.
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		AutoReplyPings().
+		Build()
+	srv.config.SchemeOpts = []AuthenticationScheme{AuthenticationSchemeGuest}
+	srv.config.Authenticate = func(ctx context.Context, identity Identity, authentication Authentication) (*AuthenticationResult, error) {
+		i := int(calls.Add(1)) - 1
+		if i < len(results) {
+			return results[i], nil
+		}
+		return MemberAuthenticationResult(), nil
+	}
+	startServer(t, srv)
+	return srv, &calls
+}
+
+func TestServer_ReauthenticateSession(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("reauthenticate")
+	srv, calls := startReauthenticationServer(t, addr)
+	defer silentClose(srv)
+	client := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(client)
+
+	// Act
+	err := srv.ReauthenticateSession(ctx, client.sessionID)
+
+	// Assert
+	assert.NoError(t, err)
+	respCmd, err := client.ProcessCommand(ctx, createGetPingCommand())
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	assert.Eventually(t, func() bool {
+		return calls.Load() == 2
+	}, 100*time.Millisecond, 5*time.Millisecond)
+	assert.True(t, client.Established())
+	c, _ := srv.session(client.sessionID)
+	assert.Eventually(t, func() bool {
+		c.reauth.mu.Lock()
+		defer c.reauth.mu.Unlock()
+		return c.reauth.authenticate == nil
+	}, 100*time.Millisecond, 5*time.Millisecond)
+}
+
+func TestServer_ReauthenticateSession_Failed(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("reauthenticate-failed")
+	srv, _ := startReauthenticationServer(t, addr, MemberAuthenticationResult(), UnknownAuthenticationResult())
+	defer silentClose(srv)
+	client := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(client)
+
+	// Act
+	err := srv.ReauthenticateSession(ctx, client.sessionID)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "session finish timeout")
+	case <-client.RcvDone():
+	}
+	assert.Equal(t, SessionStateFailed, client.State())
+}
+
+func TestServer_ReauthenticateSession_Pending(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("reauthenticate-pending")
+	srv, _ := startReauthenticationServer(t, addr)
+	defer silentClose(srv)
+	// The client holds the reply of the re-authentication until the end of the test
+	release := make(chan struct{})
+	c := establishHoldingReauthentication(t, ctx, addr, release)
+	defer silentClose(c)
+	defer close(release)
+	if err := srv.ReauthenticateSession(ctx, c.sessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	err := srv.ReauthenticateSession(ctx, c.sessionID)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReauthenticationPending)
+}
+
+func TestServer_ReauthenticateSession_NotFound(t *testing.T) {
+	// Arrange
+	srv := NewServerBuilder().ListenInProcess(InProcessAddr("reauthenticate-not-found")).Build()
+
+	// Act
+	err := srv.ReauthenticateSession(context.Background(), "not-found")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestServer_ReauthenticateSession_Timeout(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("reauthenticate-timeout")
+	srv, _ := startReauthenticationServerWith(t, NewServerBuilder().Reauthentication(0, 20*time.Millisecond), addr)
+	defer silentClose(srv)
+	release := make(chan struct{})
+	c := establishHoldingReauthentication(t, ctx, addr, release)
+	defer silentClose(c)
+	defer close(release)
+	sc, _ := srv.session(c.sessionID)
+
+	// Act
+	err := srv.ReauthenticateSession(ctx, c.sessionID)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "session finish timeout")
+	case <-sc.RcvDone():
+	}
+	assert.Equal(t, SessionStateFailed, sc.State())
+	assert.Eventually(t, func() bool {
+		_, ok := srv.session(c.sessionID)
+		return !ok
+	}, 100*time.Millisecond, 5*time.Millisecond)
+}
+
+func TestServer_Reauthentication_Interval(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("reauthenticate-interval")
+	srv, calls := startReauthenticationServerWith(t, NewServerBuilder().Reauthentication(10*time.Millisecond, time.Second), addr)
+	defer silentClose(srv)
+
+	// Act
+	client := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(client)
+
+	// Assert
+	assert.Eventually(t, func() bool {
+		return calls.Load() >= 3
+	}, 200*time.Millisecond, 5*time.Millisecond)
+	assert.True(t, client.Established())
+}
+
+// establishHoldingReauthentication establishes a client session that holds the replies of the re-authentications
+// until the release channel is closed.
+func establishHoldingReauthentication(t *testing.T, ctx context.Context, addr InProcessAddr, release chan struct{}) *ClientChannel {
+	client, err := DialInProcess(addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClientChannel(client, 1)
+	var calls int
+	_, err = c.EstablishSession(
+		ctx,
+		NoneCompressionSelector,
+		NoneEncryptionSelector,
+		Identity{Name: "golang", Domain: "localhost"},
+		func(schemes []AuthenticationScheme, roundTrip Authentication) Authentication {
+			calls++
+			if calls > 1 {
+				<-release
+			}
+			return &GuestAuthentication{}
+		},
+		"default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,202 @@
+package lime
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"maps"
+	"strconv"
+)
+
+const (
+	// MetadataKeyNonce is the metadata key with the sequential number of the envelopes sent by a channel with the
+	// replay protection negotiated.
+	MetadataKeyNonce = "#nonce"
+	// MetadataKeyNonceMAC is the metadata key with the HMAC-SHA256 of the nonce and the envelope, in base64, which is
+	// computed with the session replay key, so the nonces cannot be changed by a third party.
+	MetadataKeyNonceMAC = "#nonceMac"
+	// MetadataKeyReplayKey is the session metadata key with the X25519 public key of a party, in base64. The client
+	// sends its key in the new session and the server replies its own in the established session, and both derive the
+	// key of the nonce MACs from them.
+	MetadataKeyReplayKey = "#replayKey"
+)
+
+// replayProtection holds the nonces of a channel session.
+type replayProtection struct {
+	private  *ecdh.PrivateKey // private is the key of the local party for the key exchange
+	key      []byte           // key is the key of the nonce MACs, defined if the protection was negotiated
+	sent     uint64           // sent is the nonce of the last sent envelope, protected by the channel sendMu
+	received uint64           // received is the nonce of the last received envelope, used only by the receiver goroutine
+}
+
+// EnableReplayProtection makes the channel stamp the envelopes sent while established with a monotonic nonce and
+// its MAC, and reject the received envelopes without a valid MAC or a nonce greater than the previous one, failing
+// the session.
+// The protection is used only if the remote party also enables it, which is negotiated in the session establishment.
+func (c *channel) EnableReplayProtection() {
+	if err := c.ensureState(SessionStateNew, "enable replay protection"); err != nil {
+		panic(err)
+	}
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		panic(fmt.Errorf("enable replay protection: %w", err))
+	}
+	c.replay = &replayProtection{private: private}
+}
+
+// offerReplayKey adds the public key of the channel to the session sent to the remote party.
+func (c *channel) offerReplayKey(ses *Session) {
+	if c.replay == nil {
+		return
+	}
+	ses.SetMetadataKeyValue(MetadataKeyReplayKey, base64.StdEncoding.EncodeToString(c.replay.private.PublicKey().Bytes()))
+}
+
+// acceptReplayKey derives the key of the nonce MACs from the public key in the session received from the remote
+// party. If the remote party doesn't enable the protection, the channel doesn't use it.
+func (c *channel) acceptReplayKey(ses *Session) {
+	if c.replay == nil {
+		return
+	}
+	c.replay.key = nil
+	if !c.Supports(FeatureReplayProtection) {
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(ses.Metadata[MetadataKeyReplayKey])
+	if err != nil {
+		log.Printf("replay protection: invalid remote key: %v", err)
+		return
+	}
+	remote, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		log.Printf("replay protection: invalid remote key: %v", err)
+		return
+	}
+	secret, err := c.replay.private.ECDH(remote)
+	if err != nil {
+		log.Printf("replay protection: %v", err)
+		return
+	}
+	// The key is bound to the session, so the MACs of a session are not valid in the other ones
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(c.sessionID))
+	c.replay.key = h.Sum(nil)
+}
+
+// mac computes the MAC of the nonce of the envelope, which covers its kind, identification, addresses and content.
+// The content hash of the received envelopes is computed from the JSON as received, if available.
+func (r *replayProtection) mac(e envelope, nonce string) string {
+	env := envelopeOf(e)
+	contentHash, ok := env.Annotation(receivedContentHashKey{})
+	if !ok {
+		contentHash = ""
+		if d := documentOf(e); d != nil {
+			contentHash, _ = ContentHash(d)
+		}
+	}
+	h := hmac.New(sha256.New, r.key)
+	for _, v := range []string{envelopeKind(e), env.ID, env.From.String(), env.To.String(), nonce, contentHash.(string)} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// stamp returns a copy of the envelope with the next nonce and its MAC, without changing the original one, which
+// may be shared with other channels.
+func (r *replayProtection) stamp(e envelope) envelope {
+	r.sent++
+	nonce := strconv.FormatUint(r.sent, 10)
+	withNonce := func(env Envelope) Envelope {
+		env.Metadata = maps.Clone(env.Metadata)
+		if env.Metadata == nil {
+			env.Metadata = make(map[string]string)
+		}
+		env.Metadata[MetadataKeyNonce] = nonce
+		env.Metadata[MetadataKeyNonceMAC] = r.mac(e, nonce)
+		return env
+	}
+
+	switch e := e.(type) {
+	case *Message:
+		stamped := *e
+		stamped.Envelope = withNonce(e.Envelope)
+		return &stamped
+	case *Notification:
+		stamped := *e
+		stamped.Envelope = withNonce(e.Envelope)
+		return &stamped
+	case *RequestCommand:
+		stamped := *e
+		stamped.Envelope = withNonce(e.Envelope)
+		return &stamped
+	case *ResponseCommand:
+		stamped := *e
+		stamped.Envelope = withNonce(e.Envelope)
+		return &stamped
+	default:
+		return e
+	}
+}
+
+// verify checks the nonce of a received envelope, returning the description of the failure if it is missing,
+// forged, reused or regressed, or an empty string if it is valid.
+func (r *replayProtection) verify(e envelope) string {
+	env := envelopeOf(e)
+	v, ok := env.Metadata[MetadataKeyNonce]
+	if !ok {
+		return "The envelope nonce is missing"
+	}
+	nonce, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return "The envelope nonce is invalid"
+	}
+	if !hmac.Equal([]byte(env.Metadata[MetadataKeyNonceMAC]), []byte(r.mac(e, v))) {
+		return "The envelope nonce MAC is invalid"
+	}
+	if nonce <= r.received {
+		return fmt.Sprintf("The envelope nonce %v is not greater than %v", nonce, r.received)
+	}
+
+	r.received = nonce
+	return ""
+}
+
+// stampNonce returns a copy of the envelope with the next nonce, if the replay protection was negotiated for the
+// session. It should be called with the send lock held.
+func (c *channel) stampNonce(e envelope) envelope {
+	if c.replay == nil || c.replay.key == nil {
+		return e
+	}
+	return c.replay.stamp(e)
+}
+
+// verifyNonce checks the nonce of a received envelope, failing the session if it is not valid.
+// It returns false if the envelope should be discarded.
+func (c *channel) verifyNonce(ctx context.Context, e envelope) bool {
+	if c.replay == nil || c.replay.key == nil {
+		return true
+	}
+	if _, ok := e.(*Session); ok {
+		return true
+	}
+
+	if description := c.replay.verify(e); description != "" {
+		return c.rejectReplay(ctx, description)
+	}
+	return true
+}
+
+// rejectReplay fails the session with the generic session error code, since the protocol has no specific code for
+// the replayed envelopes.
+func (c *channel) rejectReplay(ctx context.Context, description string) bool {
+	if err := c.failFromReceiver(ctx, NewReason(ReasonCodeSessionError, description)); err != nil {
+		log.Printf("replay protection: %v", err)
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var testReplayKey = []byte("0123456789abcdef0123456789abcdef")
+
+// enableTestReplayProtection enables the replay protection of the channel as if it was negotiated with testReplayKey.
+func enableTestReplayProtection(c *channel) {
+	c.EnableReplayProtection()
+	c.replay.key = testReplayKey
+}
+
+func TestChannel_SendMessage_ReplayProtection(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 2)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	enableTestReplayProtection(c)
+	c.setState(SessionStateEstablished)
+	msg := createMessage()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err1 := c.SendMessage(ctx, msg)
+	err2 := c.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NotContains(t, msg.Metadata, MetadataKeyNonce)
+	actual1, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", actual1.(*Message).Metadata[MetadataKeyNonce])
+	actual2, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", actual2.(*Message).Metadata[MetadataKeyNonce])
+	assert.Equal(t, msg.ID, actual2.(*Message).ID)
+	assert.NotEqual(t, actual1.(*Message).Metadata[MetadataKeyNonceMAC], actual2.(*Message).Metadata[MetadataKeyNonceMAC])
+}
+
+func TestServerChannel_ReplayProtection_RegressedNonce(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 2)
+	c := NewServerChannel(server, 1, Node{Identity{"server", "localhost"}, "instance"}, "52e59849")
+	defer silentClose(c)
+	enableTestReplayProtection(c.channel)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	sender := &replayProtection{key: testReplayKey, sent: 1}
+	stamped := sender.stamp(createMessage())
+
+	// Act
+	assert.NoError(t, client.Send(ctx, stamped))
+	assert.NoError(t, client.Send(ctx, stamped))
+
+	// Assert
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case msg := <-c.MsgChan():
+		assert.Equal(t, "2", msg.Metadata[MetadataKeyNonce])
+	}
+	_, ok := <-c.MsgChan()
+	assert.False(t, ok)
+	assert.Equal(t, SessionStateFailed, c.State())
+	assert.Equal(t, ReasonCodeSessionError, c.failedReason.Code)
+}
+
+func TestServerChannel_ReplayProtection_MissingNonce(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 2)
+	c := NewServerChannel(server, 1, Node{Identity{"server", "localhost"}, "instance"}, "52e59849")
+	defer silentClose(c)
+	enableTestReplayProtection(c.channel)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	assert.NoError(t, client.Send(ctx, createMessage()))
+
+	// Assert
+	_, ok := <-c.MsgChan()
+	assert.False(t, ok)
+	assert.Equal(t, SessionStateFailed, c.State())
+	assert.Equal(t, "The envelope nonce is missing", c.failedReason.Description)
+}
+
+func TestServerChannel_ReplayProtection_ForgedNonce(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 2)
+	c := NewServerChannel(server, 1, Node{Identity{"server", "localhost"}, "instance"}, "52e59849")
+	defer silentClose(c)
+	enableTestReplayProtection(c.channel)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	sender := &replayProtection{key: []byte("fedcba9876543210fedcba9876543210")}
+
+	// Act
+	assert.NoError(t, client.Send(ctx, sender.stamp(createMessage())))
+
+	// Assert
+	_, ok := <-c.MsgChan()
+	assert.False(t, ok)
+	assert.Equal(t, SessionStateFailed, c.State())
+	assert.Equal(t, "The envelope nonce MAC is invalid", c.failedReason.Description)
+}
+
+func TestServer_ReplayProtection(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("replay-protection")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		AutoReplyPings().
+		EnableReplayProtection().
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	transport, err := DialInProcess(addr, 1)
+	assert.NoError(t, err)
+	client := NewClientChannel(transport, 1)
+	defer silentClose(client)
+	client.EnableReplayProtection()
+	_, err = client.EstablishSession(
+		ctx,
+		func([]SessionCompression) SessionCompression {
+			return SessionCompressionNone
+		},
+		func([]SessionEncryption) SessionEncryption {
+			return SessionEncryptionNone
+		},
+		Identity{Name: "golang", Domain: "localhost"},
+		func([]AuthenticationScheme, Authentication) Authentication {
+			return &GuestAuthentication{}
+		},
+		"default")
+	assert.NoError(t, err)
+
+	// Act
+	respCmd1, err1 := client.ProcessCommand(ctx, createGetPingCommand())
+	respCmd2, err2 := client.ProcessCommand(ctx, createGetPingCommand())
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, CommandStatusSuccess, respCmd1.Status)
+	assert.Equal(t, "1", respCmd1.Metadata[MetadataKeyNonce])
+	assert.Equal(t, "2", respCmd2.Metadata[MetadataKeyNonce])
+	assert.True(t, client.Established())
+}
+
+func TestServer_ReplayProtection_NotNegotiated(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("replay-protection-not-negotiated")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		AutoReplyPings().
+		EnableReplayProtection().
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	client := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(client)
+
+	// Act
+	respCmd, err := client.ProcessCommand(ctx, createGetPingCommand())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	assert.NotContains(t, respCmd.Metadata, MetadataKeyNonce)
+	assert.True(t, client.Established())
+}
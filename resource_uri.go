@@ -0,0 +1,149 @@
+package lime
+
+import (
+	"net/url"
+	"strings"
+)
+
+// The URI paths of the core resources of the Lime protocol, which are usually provided by the server for the nodes
+// of the domain. The commands to an absolute URI, like 'lime://name@domain/account', address the resources of
+// another identity.
+const (
+	// PingPath is the path of the ping resource. A get command to '/ping' is replied with a Ping document.
+	PingPath = "/ping"
+	// PresencePath is the path of the presence of the session node.
+	PresencePath = "/presence"
+	// ReceiptPath is the path of the receipt settings of the session node, which define the notification events that
+	// the node wants to receive for its sent messages.
+	ReceiptPath = "/receipt"
+	// AccountPath is the path of the account information of the session identity.
+	AccountPath = "/account"
+	// ContactsPath is the path of the roster of the session identity. A contact is addressed by
+	// '/contacts/name@domain'.
+	ContactsPath = "/contacts"
+	// GroupsPath is the path of the groups of the session identity. A group is addressed by '/groups/name@domain'
+	// and its members by '/groups/name@domain/members'.
+	GroupsPath = "/groups"
+	// DelegationsPath is the path of the delegations granted by the session identity. A delegation is addressed by
+	// '/delegations/name@domain'.
+	DelegationsPath = "/delegations"
+	// QuotaPath is the path of the usage quota of the session identity.
+	QuotaPath = "/quota"
+)
+
+// groupMembersSegment is the sub resource of a group with its members.
+const groupMembersSegment = "members"
+
+// NewResourceURI creates the URI of the resource in the path, followed by the segments, which are escaped if needed.
+//
+//	lime.NewResourceURI(lime.GroupsPath, "team@msging.net", "members") // '/groups/team@msging.net/members'
+func NewResourceURI(path string, segments ...string) *URI {
+	u := &url.URL{Path: joinResourcePath(path, segments)}
+	return &URI{url: u}
+}
+
+// NewOwnerResourceURI creates the absolute URI of the resource of the owner identity, like
+// 'lime://john@msging.net/account'.
+func NewOwnerResourceURI(owner Identity, path string, segments ...string) *URI {
+	u := &url.URL{
+		Scheme: URISchemeLime,
+		User:   url.User(owner.Name),
+		Host:   owner.Domain,
+		Path:   joinResourcePath(path, segments),
+	}
+	return &URI{url: u}
+}
+
+func joinResourcePath(path string, segments []string) string {
+	var b strings.Builder
+	b.WriteString(path)
+	for _, s := range segments {
+		b.WriteString("/")
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// ContactURI returns the URI of the contact of the identity, in the roster of the session identity.
+func ContactURI(contact Identity) *URI {
+	return NewResourceURI(ContactsPath, contact.String())
+}
+
+// GroupURI returns the URI of the group with the identity.
+func GroupURI(group Identity) *URI {
+	return NewResourceURI(GroupsPath, group.String())
+}
+
+// GroupMembersURI returns the URI of the members of the group.
+func GroupMembersURI(group Identity) *URI {
+	return NewResourceURI(GroupsPath, group.String(), groupMembersSegment)
+}
+
+// GroupMemberURI returns the URI of a member of the group.
+func GroupMemberURI(group, member Identity) *URI {
+	return NewResourceURI(GroupsPath, group.String(), groupMembersSegment, member.String())
+}
+
+// DelegationURI returns the URI of the delegation granted to the target identity.
+func DelegationURI(target Identity) *URI {
+	return NewResourceURI(DelegationsPath, target.String())
+}
+
+// Segments returns the segments of the URI path, unescaped.
+//
+//	'/groups/team@msging.net/members' -> ["groups", "team@msging.net", "members"]
+func (u *URI) Segments() []string {
+	p := strings.Trim(u.Path(), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// IsResource indicates if the URI addresses the resource in the path or one of its items, like '/contacts' or
+// '/contacts/john@msging.net' for the ContactsPath.
+func (u *URI) IsResource(path string) bool {
+	p := u.Path()
+	return p == path || strings.HasPrefix(p, strings.TrimSuffix(path, "/")+"/")
+}
+
+// ResourceID returns the segment of the URI that follows the resource path, which identifies an item of a collection
+// resource, like the identity of a contact in '/contacts/john@msging.net'. It returns false if the URI does not
+// address an item of the resource.
+func (u *URI) ResourceID(path string) (string, bool) {
+	p := u.Path()
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	if !strings.HasPrefix(p, prefix) {
+		return "", false
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(p, prefix), "/")
+	return id, id != ""
+}
+
+// ResourceIdentity is like ResourceID, but parses the segment as an identity, like for the ContactsPath, GroupsPath
+// and DelegationsPath resources.
+func (u *URI) ResourceIdentity(path string) (Identity, bool) {
+	id, ok := u.ResourceID(path)
+	if !ok {
+		return Identity{}, false
+	}
+	identity := ParseIdentity(id)
+	if identity.Name == "" || identity.Domain == "" {
+		return Identity{}, false
+	}
+	return identity, true
+}
+
+// GroupMember returns the group and member identities of a URI like '/groups/team@msging.net/members/john@msging.net',
+// or false if the URI does not address a group member.
+func (u *URI) GroupMember() (group Identity, member Identity, ok bool) {
+	segments := u.Segments()
+	if len(segments) != 4 || "/"+segments[0] != GroupsPath || segments[2] != groupMembersSegment {
+		return Identity{}, Identity{}, false
+	}
+	group, member = ParseIdentity(segments[1]), ParseIdentity(segments[3])
+	if group.Name == "" || member.Name == "" {
+		return Identity{}, Identity{}, false
+	}
+	return group, member, true
+}
@@ -0,0 +1,127 @@
+package lime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewResourceURI(t *testing.T) {
+	// Arrange
+	group := Identity{Name: "team", Domain: "msging.net"}
+	member := Identity{Name: "john", Domain: "msging.net"}
+
+	// Act
+	uris := []*URI{
+		NewResourceURI(PresencePath),
+		ContactURI(member),
+		GroupURI(group),
+		GroupMembersURI(group),
+		GroupMemberURI(group, member),
+		DelegationURI(member),
+		NewResourceURI(ContactsPath, "john doe@msging.net"),
+	}
+
+	// Assert
+	var actual []string
+	for _, u := range uris {
+		actual = append(actual, u.String())
+	}
+	assert.Equal(t, []string{
+		"/presence",
+		"/contacts/john@msging.net",
+		"/groups/team@msging.net",
+		"/groups/team@msging.net/members",
+		"/groups/team@msging.net/members/john@msging.net",
+		"/delegations/john@msging.net",
+		"/contacts/john%20doe@msging.net",
+	}, actual)
+}
+
+func TestNewOwnerResourceURI(t *testing.T) {
+	// Arrange
+	owner := Identity{Name: "john", Domain: "msging.net"}
+
+	// Act
+	u := NewOwnerResourceURI(owner, AccountPath)
+
+	// Assert
+	assert.Equal(t, "lime://john@msging.net/account", u.String())
+	assert.Equal(t, &owner, u.Owner())
+	assert.Equal(t, AccountPath, u.Path())
+	parsed, err := ParseLimeURI(u.String())
+	assert.NoError(t, err)
+	assert.Equal(t, u, parsed)
+}
+
+func TestURI_Segments(t *testing.T) {
+	// Arrange
+	u, _ := ParseLimeURI("/groups/team%40msging.net/members")
+
+	// Act
+	segments := u.Segments()
+
+	// Assert
+	assert.Equal(t, []string{"groups", "team@msging.net", "members"}, segments)
+	assert.Nil(t, NewResourceURI("/").Segments())
+}
+
+func TestURI_IsResource(t *testing.T) {
+	// Arrange
+	contacts := NewResourceURI(ContactsPath)
+	contact := ContactURI(Identity{Name: "john", Domain: "msging.net"})
+	other, _ := ParseLimeURI("/contactsx")
+
+	// Act & Assert
+	assert.True(t, contacts.IsResource(ContactsPath))
+	assert.True(t, contact.IsResource(ContactsPath))
+	assert.False(t, other.IsResource(ContactsPath))
+	assert.False(t, contact.IsResource(GroupsPath))
+}
+
+func TestURI_ResourceIdentity(t *testing.T) {
+	// Arrange
+	contact := Identity{Name: "john", Domain: "msging.net"}
+	u, _ := ParseLimeURI("lime://owner@msging.net/contacts/john@msging.net")
+
+	// Act
+	identity, ok := u.ResourceIdentity(ContactsPath)
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, contact, identity)
+	_, ok = NewResourceURI(ContactsPath).ResourceIdentity(ContactsPath)
+	assert.False(t, ok)
+	_, ok = NewResourceURI(ContactsPath, "john").ResourceIdentity(ContactsPath)
+	assert.False(t, ok)
+}
+
+func TestURI_ResourceID(t *testing.T) {
+	// Arrange
+	u := GroupMembersURI(Identity{Name: "team", Domain: "msging.net"})
+
+	// Act
+	id, ok := u.ResourceID(GroupsPath)
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, "team@msging.net", id)
+	_, ok = u.ResourceID(ContactsPath)
+	assert.False(t, ok)
+}
+
+func TestURI_GroupMember(t *testing.T) {
+	// Arrange
+	group := Identity{Name: "team", Domain: "msging.net"}
+	member := Identity{Name: "john", Domain: "msging.net"}
+	u := GroupMemberURI(group, member)
+
+	// Act
+	actualGroup, actualMember, ok := u.GroupMember()
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, group, actualGroup)
+	assert.Equal(t, member, actualMember)
+	_, _, ok = GroupMembersURI(group).GroupMember()
+	assert.False(t, ok)
+}
@@ -0,0 +1,109 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrMessageNotAcknowledged is returned when no acknowledgment notification is received for a message after all
+	// the retransmissions.
+	ErrMessageNotAcknowledged = errors.New("message not acknowledged")
+	// ErrMessageFailed is returned when a failed notification is received for a message.
+	ErrMessageFailed = errors.New("message failed")
+)
+
+// RetransmissionPolicy defines how a message is retransmitted while it is not acknowledged by the destination.
+type RetransmissionPolicy struct {
+	// Timeout is the time to wait for the acknowledgment after each transmission.
+	Timeout time.Duration
+	// MaxRetries is the maximum number of retransmissions after the first one.
+	MaxRetries int
+	// AckEvent is the notification event that acknowledges the message. The more advanced events in the message
+	// lifecycle are also considered acknowledgments. If empty, NotificationEventReceived is used.
+	AckEvent NotificationEvent
+	// Backoff defines the intervals to wait after an acknowledgment timeout, before each retransmission. If not
+	// defined, the message is retransmitted immediately.
+	Backoff BackoffStrategy
+}
+
+// DefaultRetransmissionPolicy waits 5 seconds for the received notification, retransmitting the message up to
+// 3 times.
+var DefaultRetransmissionPolicy = RetransmissionPolicy{
+	Timeout:    5 * time.Second,
+	MaxRetries: 3,
+	AckEvent:   NotificationEventReceived,
+}
+
+// SendMessageWithRetransmission sends a Message to the server, retransmitting it with the same ID if no
+// acknowledgment notification is received within the policy timeout.
+// It blocks until the message is acknowledged, returning its status, or fails with ErrMessageNotAcknowledged after
+// the last retransmission. The message ID is required and the client should have a NotificationTracker.
+func (c *Client) SendMessageWithRetransmission(ctx context.Context, msg *Message, policy RetransmissionPolicy) (MessageStatus, error) {
+	if msg == nil {
+		panic("nil message")
+	}
+	if policy.Timeout <= 0 {
+		panic("the timeout should be positive")
+	}
+	tracker := c.config.NotificationTracker
+	if tracker == nil {
+		return MessageStatus{}, errors.New("send message: a notification tracker is required for retransmission")
+	}
+	if msg.ID == "" {
+		return MessageStatus{}, errors.New("send message: the message id is required for retransmission")
+	}
+	ackEvent := policy.AckEvent
+	if ackEvent == "" {
+		ackEvent = NotificationEventReceived
+	}
+
+	clock := clockOrSystem(c.config.Clock)
+	var interval time.Duration
+	for attempt := 0; attempt <= policy.MaxRetries; attempt++ {
+		if attempt > 0 && policy.Backoff != nil {
+			interval = policy.Backoff.Backoff(attempt-1, interval)
+			if err := sleep(ctx, clock, interval); err != nil {
+				return MessageStatus{}, fmt.Errorf("send message: %w", err)
+			}
+		}
+		sendCtx := ctx
+		if attempt > 0 {
+			sendCtx = withRetransmission(ctx)
+		}
+		if err := c.SendMessage(sendCtx, msg); err != nil {
+			return MessageStatus{}, err
+		}
+
+		status, err := awaitAcknowledgment(ctx, clock, tracker, msg.ID, ackEvent, policy.Timeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return MessageStatus{}, fmt.Errorf("send message: %w", ctx.Err())
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				continue
+			}
+			return MessageStatus{}, fmt.Errorf("send message: %w", err)
+		}
+		if status.Event == NotificationEventFailed {
+			return status, fmt.Errorf("send message: %w: %v", ErrMessageFailed, status.Reason)
+		}
+		return status, nil
+	}
+
+	status, _ := tracker.Status(msg.ID)
+	return status, fmt.Errorf("send message: %w after %d retries", ErrMessageNotAcknowledged, policy.MaxRetries)
+}
+
+// awaitAcknowledgment awaits the notification of the message for the timeout, which is measured in the clock.
+func awaitAcknowledgment(ctx context.Context, clock Clock, tracker *NotificationTracker, id string, event NotificationEvent, timeout time.Duration) (MessageStatus, error) {
+	ctx, cancel := withClockTimeout(ctx, clock, timeout)
+	defer cancel()
+	status, err := tracker.Await(ctx, id, event)
+	if err != nil && errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		return status, fmt.Errorf("await notification: %w", context.DeadlineExceeded)
+	}
+	return status, err
+}
@@ -0,0 +1,164 @@
+package lime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"strings"
+	"time"
+)
+
+const (
+	// MetadataKeyTraceParent is the metadata key with the trace context of the envelope, in the W3C traceparent
+	// format, like '00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'.
+	MetadataKeyTraceParent = "#traceParent"
+	// MetadataKeyHops is the metadata key with the nodes that forwarded the envelope, as a JSON array of Hop. Only
+	// the most recent 32 hops are kept.
+	MetadataKeyHops = "#hops"
+)
+
+// maxRouteHops is the maximum number of hops kept in the envelopes, which are the most recent ones.
+const maxRouteHops = 32
+
+// Hop identifies a node that forwarded an envelope, allowing the reconstruction of the multi-hop deliveries.
+type Hop struct {
+	Node      Node      `json:"node"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TraceParent returns the trace context of the envelope, if defined.
+func (env *Envelope) TraceParent() string {
+	return env.Metadata[MetadataKeyTraceParent]
+}
+
+// SetTraceParent sets the trace context of the envelope, in the W3C traceparent format.
+func (env *Envelope) SetTraceParent(traceParent string) *Envelope {
+	return env.SetMetadataKeyValue(MetadataKeyTraceParent, traceParent)
+}
+
+// Hops returns the nodes that forwarded the envelope, in order.
+func (env *Envelope) Hops() ([]Hop, error) {
+	value, ok := env.Metadata[MetadataKeyHops]
+	if !ok {
+		return nil, nil
+	}
+	var hops []Hop
+	if err := json.Unmarshal([]byte(value), &hops); err != nil {
+		return nil, err
+	}
+	return hops, nil
+}
+
+// routeTrace is the trace context of the envelope being handled.
+type routeTrace struct {
+	traceParent string
+	hops        []Hop  // hops are the hops of the received envelope, or nil if the context was not received
+	hopsValue   string // hopsValue is the metadata value of the received hops
+}
+
+// ContextTraceParent gets the trace context from the context.
+// It is defined when handling a received envelope with the MetadataKeyTraceParent metadata.
+func ContextTraceParent(ctx context.Context) (string, bool) {
+	trace, ok := ctx.Value(contextKeyRouteTrace).(*routeTrace)
+	if !ok || trace.traceParent == "" {
+		return "", false
+	}
+	return trace.traceParent, true
+}
+
+// WithTraceParent returns a copy of the context with the trace context, in the W3C traceparent format, which is
+// propagated to the envelopes sent through a channel using the context.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	trace := &routeTrace{traceParent: traceParent}
+	if current, ok := ctx.Value(contextKeyRouteTrace).(*routeTrace); ok {
+		trace.hops = current.hops
+		trace.hopsValue = current.hopsValue
+	}
+	return context.WithValue(ctx, contextKeyRouteTrace, trace)
+}
+
+// routeTraceContext returns a copy of the context with the trace context and hops of the received envelope, if it
+// has any of them. The hops are kept even if empty, since the envelopes sent with the context are forwarded by the
+// local node.
+func routeTraceContext(ctx context.Context, env *Envelope) context.Context {
+	_, hasHops := env.Metadata[MetadataKeyHops]
+	traceParent := env.TraceParent()
+	if traceParent == "" && !hasHops {
+		return ctx
+	}
+	hops, _ := env.Hops()
+	if hops == nil {
+		hops = []Hop{}
+	}
+	return context.WithValue(ctx, contextKeyRouteTrace, &routeTrace{
+		traceParent: traceParent,
+		hops:        hops,
+		hopsValue:   env.Metadata[MetadataKeyHops],
+	})
+}
+
+// propagateRouteTrace sets the trace context in the envelope, as a child of the context one, and adds the local
+// node to its hops, when the envelope is forwarded while handling a received one with a trace context or hops, like
+// when routing it to another channel. The forwarded envelopes are the ones with the same trace context or hops of
+// the received one, so the other envelopes, like the replies, are not changed. If the trace context was defined by
+// the application, it is set in the envelopes without one.
+func propagateRouteTrace(ctx context.Context, env *Envelope, local Node, clock Clock) {
+	trace, ok := ctx.Value(contextKeyRouteTrace).(*routeTrace)
+	if !ok {
+		return
+	}
+	received := trace.hops != nil
+	traceParent, hasTraceParent := env.Metadata[MetadataKeyTraceParent]
+	var update bool
+	if received {
+		forwardedTraceParent := trace.traceParent != "" && traceParent == trace.traceParent
+		forwardedHops := trace.hopsValue != "" && env.Metadata[MetadataKeyHops] == trace.hopsValue
+		update = forwardedTraceParent || forwardedHops
+	} else {
+		update = trace.traceParent != "" && !hasTraceParent
+	}
+	if !update {
+		return
+	}
+	if !received {
+		// The trace context was defined by the application, which is the origin of the envelope
+		setSharedMetadataValue(env, MetadataKeyTraceParent, trace.traceParent)
+		return
+	}
+
+	// Copies the metadata to avoid changing a map that can be shared by other envelopes
+	metadata := maps.Clone(env.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string, 1)
+	}
+	if hasTraceParent {
+		if child, ok := childTraceParent(traceParent); ok {
+			metadata[MetadataKeyTraceParent] = child
+		}
+	}
+	hops := append(trace.hops[:len(trace.hops):len(trace.hops)], Hop{Node: local, Timestamp: clock.Now().UTC()})
+	if len(hops) > maxRouteHops {
+		hops = hops[len(hops)-maxRouteHops:]
+	}
+	if b, err := json.Marshal(hops); err == nil {
+		metadata[MetadataKeyHops] = string(b)
+	}
+	env.Metadata = metadata
+}
+
+// childTraceParent returns the traceparent with the same trace id and flags, and a new parent id, for the span of
+// the forwarding.
+func childTraceParent(traceParent string) (string, bool) {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+	var spanID [8]byte
+	if _, err := rand.Read(spanID[:]); err != nil {
+		return "", false
+	}
+	parts[2] = hex.EncodeToString(spanID[:])
+	return strings.Join(parts, "-"), true
+}
@@ -0,0 +1,208 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// forwardMessages listens the source channel, forwarding the received messages to the target channel.
+func forwardMessages(ctx context.Context, source *channel, target *channel) {
+	mux := &EnvelopeMux{}
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			return target.SendMessage(ctx, msg)
+		})
+	go func() {
+		_ = mux.listen(ctx, source)
+	}()
+}
+
+func TestChannel_SendMessage_RouteTrace(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client1, server1 := newInProcessTransportPair("localhost", 1)
+	client2, server2 := newInProcessTransportPair("localhost", 1)
+	source := newChannel(server1, 1)
+	defer silentClose(source)
+	target := newChannel(server2, 1)
+	defer silentClose(target)
+	target.localNode = Node{Identity: Identity{Name: "router", Domain: "limeprotocol.org"}, Instance: "node1"}
+	source.setState(SessionStateEstablished)
+	target.setState(SessionStateEstablished)
+	forwardMessages(ctx, source, target)
+	msg := createMessage()
+	msg.SetTraceParent(testTraceParent)
+
+	// Act
+	_ = client1.Send(ctx, msg)
+	actual, err := client2.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	forwarded := actual.(*Message)
+	traceParent := strings.Split(forwarded.TraceParent(), "-")
+	if assert.Len(t, traceParent, 4) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceParent[1])
+		assert.NotEqual(t, "00f067aa0ba902b7", traceParent[2])
+		assert.Equal(t, "01", traceParent[3])
+	}
+	hops, err := forwarded.Hops()
+	assert.NoError(t, err)
+	if assert.Len(t, hops, 1) {
+		assert.Equal(t, target.localNode, hops[0].Node)
+		assert.WithinDuration(t, time.Now(), hops[0].Timestamp, time.Second)
+	}
+}
+
+func TestChannel_SendMessage_RouteTraceMultipleHops(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client1, server1 := newInProcessTransportPair("localhost", 1)
+	client2, server2 := newInProcessTransportPair("localhost", 1)
+	source := newChannel(server1, 1)
+	defer silentClose(source)
+	target := newChannel(server2, 1)
+	defer silentClose(target)
+	target.localNode = Node{Identity: Identity{Name: "router", Domain: "limeprotocol.org"}, Instance: "node2"}
+	source.setState(SessionStateEstablished)
+	target.setState(SessionStateEstablished)
+	forwardMessages(ctx, source, target)
+	msg := createMessage()
+	msg.SetMetadataKeyValue(MetadataKeyHops, `[{"node":"router@limeprotocol.org/node1","timestamp":"2024-01-01T00:00:00Z"}]`)
+
+	// Act
+	_ = client1.Send(ctx, msg)
+	actual, err := client2.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	forwarded := actual.(*Message)
+	assert.Empty(t, forwarded.TraceParent())
+	hops, _ := forwarded.Hops()
+	if assert.Len(t, hops, 2) {
+		assert.Equal(t, "node1", hops[0].Node.Instance)
+		assert.Equal(t, "node2", hops[1].Node.Instance)
+	}
+}
+
+func TestChannel_SendMessage_WithoutRouteTrace(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client1, server1 := newInProcessTransportPair("localhost", 1)
+	client2, server2 := newInProcessTransportPair("localhost", 1)
+	source := newChannel(server1, 1)
+	defer silentClose(source)
+	target := newChannel(server2, 1)
+	defer silentClose(target)
+	source.setState(SessionStateEstablished)
+	target.setState(SessionStateEstablished)
+	forwardMessages(ctx, source, target)
+
+	// Act
+	_ = client1.Send(ctx, createMessage())
+	actual, err := client2.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotContains(t, actual.(*Message).Metadata, MetadataKeyTraceParent)
+	assert.NotContains(t, actual.(*Message).Metadata, MetadataKeyHops)
+}
+
+func TestChannel_SendMessage_WithTraceParent(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(WithTraceParent(ctx, testTraceParent), createMessage())
+
+	// Assert
+	assert.NoError(t, err)
+	actual, _ := server.Receive(ctx)
+	assert.Equal(t, testTraceParent, actual.(*Message).TraceParent())
+	assert.NotContains(t, actual.(*Message).Metadata, MetadataKeyHops)
+}
+
+func TestChannel_SendMessage_RouteTraceClock(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client1, server1 := newInProcessTransportPair("localhost", 1)
+	client2, server2 := newInProcessTransportPair("localhost", 1)
+	source := newChannel(server1, 1)
+	defer silentClose(source)
+	target := newChannel(server2, 1)
+	defer silentClose(target)
+	target.SetClock(&stepClock{now: storeEpoch})
+	source.setState(SessionStateEstablished)
+	target.setState(SessionStateEstablished)
+	forwardMessages(ctx, source, target)
+	msg := createMessage()
+	msg.SetTraceParent(testTraceParent)
+
+	// Act
+	_ = client1.Send(ctx, msg)
+	actual, err := client2.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	hops, _ := actual.(*Message).Hops()
+	if assert.Len(t, hops, 1) {
+		assert.Equal(t, storeEpoch.Add(time.Minute), hops[0].Timestamp)
+	}
+}
+
+func TestPropagateRouteTrace_Reply(t *testing.T) {
+	// Arrange
+	received := createMessage()
+	received.SetTraceParent(testTraceParent)
+	received.SetMetadataKeyValue(MetadataKeyHops, `[{"node":"router@limeprotocol.org/node1","timestamp":"2024-01-01T00:00:00Z"}]`)
+	ctx := routeTraceContext(context.Background(), &received.Envelope)
+	reply := received.Notification(NotificationEventReceived)
+
+	// Act
+	propagateRouteTrace(ctx, &reply.Envelope, Node{Identity: Identity{Name: "router", Domain: "limeprotocol.org"}}, SystemClock)
+
+	// Assert
+	assert.NotContains(t, reply.Metadata, MetadataKeyTraceParent)
+	assert.NotContains(t, reply.Metadata, MetadataKeyHops)
+}
+
+func TestPropagateRouteTrace_MaxHops(t *testing.T) {
+	// Arrange
+	hops := make([]Hop, maxRouteHops)
+	for i := range hops {
+		hops[i] = Hop{Node: Node{Identity: Identity{Name: "router", Domain: "limeprotocol.org"}, Instance: strconv.Itoa(i)}}
+	}
+	value, _ := json.Marshal(hops)
+	msg := createMessage()
+	msg.SetMetadataKeyValue(MetadataKeyHops, string(value))
+	ctx := routeTraceContext(context.Background(), &msg.Envelope)
+	local := Node{Identity: Identity{Name: "router", Domain: "limeprotocol.org"}, Instance: "local"}
+
+	// Act
+	propagateRouteTrace(ctx, &msg.Envelope, local, SystemClock)
+
+	// Assert
+	actual, err := msg.Hops()
+	assert.NoError(t, err)
+	if assert.Len(t, actual, maxRouteHops) {
+		assert.Equal(t, "1", actual[0].Node.Instance)
+		assert.Equal(t, local, actual[maxRouteHops-1].Node)
+	}
+}
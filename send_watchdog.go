@@ -0,0 +1,125 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// StuckSendEvent describes a send of a channel that is blocked beyond the threshold of the SendWatchdog, like when
+// the remote node is not reading from the connection and the transport buffers are full.
+type StuckSendEvent struct {
+	SessionID  string // SessionID is the id of the session of the channel.
+	RemoteNode Node   // RemoteNode is the node that should receive the envelope.
+	Action     string // Action is the blocked operation, like 'send message'.
+	EnvelopeID string // EnvelopeID is the id of the envelope being sent, if any.
+	// Blocked is the time since the send was called, including the wait for the previous sends of the channel.
+	Blocked time.Duration
+	// QueueDepth is the number of sends of the channel in progress, including the stuck one.
+	QueueDepth int
+	// LastActivity is the moment when the last send of the channel was completed, or zero if none was.
+	LastActivity time.Time
+	// Aborted indicates if the transport of the channel was closed by the watchdog.
+	Aborted bool
+}
+
+func (e *StuckSendEvent) String() string {
+	return fmt.Sprintf(
+		"%v to %v (session %v) blocked for %v, with %v sends in progress",
+		e.Action, e.RemoteNode, e.SessionID, e.Blocked, e.QueueDepth)
+}
+
+// SendWatchdog detects the sends of the channels that are blocked beyond a threshold, reporting them instead of
+// letting the producer goroutines pile up silently. Each stuck send is reported once, while it is still blocked.
+type SendWatchdog struct {
+	threshold time.Duration
+	onStuck   func(ctx context.Context, e *StuckSendEvent)
+	abort     bool
+	clock     Clock
+}
+
+// NewSendWatchdog creates a SendWatchdog for the sends blocked for longer than the threshold, which are reported to
+// the onStuck function with the session in the context. If onStuck is nil, the events are logged.
+func NewSendWatchdog(threshold time.Duration, onStuck func(ctx context.Context, e *StuckSendEvent)) *SendWatchdog {
+	if threshold <= 0 {
+		panic("threshold must be positive")
+	}
+	if onStuck == nil {
+		onStuck = func(_ context.Context, e *StuckSendEvent) {
+			log.Printf("send watchdog: %v", e)
+		}
+	}
+	return &SendWatchdog{threshold: threshold, onStuck: onStuck, clock: SystemClock}
+}
+
+// SetAbort makes the watchdog close the transport of the channels with stuck sends, which fails the blocked sends
+// and finishes the session, since a failed session envelope could not be sent either. It should be called before
+// the watchdog is used.
+func (w *SendWatchdog) SetAbort(abort bool) {
+	w.abort = abort
+}
+
+// SetClock defines the clock for measuring the blocked sends. It should be called before the watchdog is used.
+func (w *SendWatchdog) SetClock(clock Clock) {
+	w.clock = clockOrSystem(clock)
+}
+
+// SetSendWatchdog defines the watchdog of the envelopes sent by the channel while established.
+func (c *channel) SetSendWatchdog(w *SendWatchdog) {
+	if err := c.ensureState(SessionStateNew, "set send watchdog"); err != nil {
+		panic(err)
+	}
+	c.sendWatchdog = w
+}
+
+// watchSend starts watching a send, if the watchdog is defined, returning the function that must be called when the
+// send returns.
+func (c *channel) watchSend(id, action string) func(sent bool) {
+	w := c.sendWatchdog
+	if w == nil {
+		return func(bool) {}
+	}
+
+	started := w.clock.Now()
+	c.pendingSends.Add(1)
+	timer := w.clock.NewTimer(w.threshold)
+	done := make(chan struct{})
+	goLabeled(context.Background(), "channel.sendWatchdog", func(context.Context) {
+		select {
+		case <-done:
+		case <-timer.C():
+			c.reportStuckSend(w, id, action, started)
+		}
+	}, "session", c.sessionID)
+
+	return func(sent bool) {
+		timer.Stop()
+		close(done)
+		c.pendingSends.Add(-1)
+		if sent {
+			c.lastSendActivity.Store(w.clock.Now().UnixNano())
+		}
+	}
+}
+
+func (c *channel) reportStuckSend(w *SendWatchdog, id, action string, started time.Time) {
+	e := &StuckSendEvent{
+		SessionID:  c.sessionID,
+		RemoteNode: c.remoteNode,
+		Action:     action,
+		EnvelopeID: id,
+		Blocked:    w.clock.Now().Sub(started),
+		QueueDepth: int(c.pendingSends.Load()),
+	}
+	if last := c.lastSendActivity.Load(); last != 0 {
+		e.LastActivity = time.Unix(0, last)
+	}
+	if w.abort {
+		if err := c.transport.Close(); err != nil {
+			log.Printf("send watchdog: close transport: %v", err)
+		}
+		e.Aborted = true
+	}
+	w.onStuck(sessionContext(context.Background(), c), e)
+}
@@ -0,0 +1,136 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSendTransport blocks the sends until the transport is closed, like a connection whose remote party is not
+// reading.
+type blockingSendTransport struct {
+	Transport
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (t *blockingSendTransport) Send(ctx context.Context, _ envelope) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.closed:
+		return errors.New("transport is closed")
+	}
+}
+
+func (t *blockingSendTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return t.Transport.Close()
+}
+
+func TestChannel_SendMessage_StuckAborted(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := newChannel(&blockingSendTransport{Transport: client, closed: make(chan struct{})}, 1)
+	defer silentClose(c)
+	events := make(chan *StuckSendEvent, 1)
+	w := NewSendWatchdog(20*time.Millisecond, func(ctx context.Context, e *StuckSendEvent) {
+		events <- e
+	})
+	w.SetAbort(true)
+	c.SetSendWatchdog(w)
+	c.setState(SessionStateEstablished)
+	m := createMessage()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(ctx, m)
+
+	// Assert
+	assert.Error(t, err)
+	assert.NoError(t, ctx.Err())
+	// The event is reported after the transport is closed
+	select {
+	case e := <-events:
+		assert.Equal(t, m.ID, e.EnvelopeID)
+		assert.Equal(t, "send message", e.Action)
+		assert.Equal(t, 1, e.QueueDepth)
+		assert.GreaterOrEqual(t, e.Blocked, 20*time.Millisecond)
+		assert.True(t, e.LastActivity.IsZero())
+		assert.True(t, e.Aborted)
+	case <-ctx.Done():
+		assert.Fail(t, "stuck send not reported")
+	}
+}
+
+func TestChannel_SendMessage_StuckQueued(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, _ := newInProcessTransportPair("localhost", 1)
+	transport := &blockingSendTransport{Transport: client, closed: make(chan struct{})}
+	c := newChannel(transport, 1)
+	defer silentClose(c)
+	events := make(chan *StuckSendEvent, 2)
+	c.SetSendWatchdog(NewSendWatchdog(20*time.Millisecond, func(ctx context.Context, e *StuckSendEvent) {
+		events <- e
+	}))
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	errs := make(chan error, 2)
+
+	// Act
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- c.SendMessage(ctx, createMessage())
+		}()
+	}
+
+	// Assert
+	var depths []int
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		case e := <-events:
+			assert.False(t, e.Aborted)
+			depths = append(depths, e.QueueDepth)
+		}
+	}
+	assert.Contains(t, depths, 2)
+	_ = transport.Close()
+	assert.Error(t, <-errs)
+	assert.Error(t, <-errs)
+}
+
+func TestChannel_SendMessage_NotStuck(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	events := make(chan *StuckSendEvent, 1)
+	c.SetSendWatchdog(NewSendWatchdog(20*time.Millisecond, func(ctx context.Context, e *StuckSendEvent) {
+		events <- e
+	}))
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(ctx, createMessage())
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = server.Receive(ctx)
+	assert.NoError(t, err)
+	time.Sleep(40 * time.Millisecond)
+	assert.Empty(t, events)
+	assert.NotZero(t, c.lastSendActivity.Load())
+}
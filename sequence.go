@@ -0,0 +1,27 @@
+package lime
+
+// Sequence returns the position of the envelope in the order it was received by the session, starting from 1, if
+// the sequence numbers are enabled in the channel. It allows restoring the received order after the envelopes are
+// processed concurrently, like by a Dispatcher.
+func (env *Envelope) Sequence() (uint64, bool) {
+	return env.sequence, env.sequence != 0
+}
+
+// EnableSequenceNumbers makes the channel stamp the envelopes received while established with a sequence number,
+// which increases monotonically in the session. See Envelope.Sequence.
+func (c *channel) EnableSequenceNumbers() {
+	if err := c.ensureState(SessionStateNew, "enable sequence numbers"); err != nil {
+		panic(err)
+	}
+	c.sequencing = true
+}
+
+// stampSequence sets the next sequence number in the received envelope, if enabled. It is only called by the
+// receiver goroutine.
+func (c *channel) stampSequence(e envelope) {
+	if !c.sequencing {
+		return
+	}
+	c.lastSequence++
+	envelopeOf(e).sequence = c.lastSequence
+}
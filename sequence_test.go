@@ -0,0 +1,52 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_ReceiveMessage_SequenceNumbers(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 4)
+	c := newChannel(client, 4)
+	defer silentClose(c)
+	c.EnableSequenceNumbers()
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_ = server.Send(ctx, createMessage())
+	_ = server.Send(ctx, createNotification())
+	_ = server.Send(ctx, createMessage())
+
+	// Assert
+	msg1 := <-c.MsgChan()
+	not := <-c.NotChan()
+	msg2 := <-c.MsgChan()
+	seq1, ok1 := msg1.Sequence()
+	seq2, ok2 := not.Sequence()
+	seq3, ok3 := msg2.Sequence()
+	assert.True(t, ok1 && ok2 && ok3)
+	assert.Equal(t, []uint64{1, 2, 3}, []uint64{seq1, seq2, seq3})
+}
+
+func TestChannel_ReceiveMessage_WithoutSequenceNumbers(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_ = server.Send(ctx, createMessage())
+
+	// Assert
+	msg := <-c.MsgChan()
+	_, ok := msg.Sequence()
+	assert.False(t, ok)
+}
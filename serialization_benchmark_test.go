@@ -0,0 +1,182 @@
+package lime
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// The serialization benchmarks are grouped by envelope type, with small and large payloads as sub-benchmarks, so
+// their results can be compared with benchstat:
+//
+//	go test -run '^$' -bench 'Marshal|Unmarshal' -benchmem -count 10 . > old.txt
+//	go test -run '^$' -bench 'Marshal|Unmarshal' -benchmem -count 10 . > new.txt
+//	benchstat old.txt new.txt
+
+// createLargeJsonDocument creates a document with nested objects and arrays, of about 36 KiB when serialized.
+func createLargeJsonDocument() *JsonDocument {
+	items := make([]interface{}, 256)
+	for i := range items {
+		items[i] = map[string]interface{}{
+			"id":       fmt.Sprintf("item-%d", i),
+			"name":     "The quick brown fox jumps over the lazy dog",
+			"price":    float64(i) * 1.5,
+			"quantity": float64(i % 10),
+			"tags":     []interface{}{"tag1", "tag2", "tag3"},
+			"enabled":  i%2 == 0,
+		}
+	}
+	return &JsonDocument{"items": items, "total": float64(len(items))}
+}
+
+func createLargeMessage() *Message {
+	msg := createMessage()
+	msg.SetContent(createLargeJsonDocument())
+	msg.SetMetadataKeyValue("#message.origin", "benchmark")
+	return msg
+}
+
+func createLargeRequestCommand() *RequestCommand {
+	cmd := createSetCommand()
+	cmd.SetResource(createLargeJsonDocument())
+	return cmd
+}
+
+func createLargeResponseCommand() *ResponseCommand {
+	cmd := createResponseCommand()
+	cmd.SetResource(createLargeJsonDocument())
+	return cmd
+}
+
+func createLargeNotification() *Notification {
+	not := createNotification()
+	not.Event = NotificationEventFailed
+	not.Reason = &Reason{Code: ReasonCodeMessageProcessingError, Description: string(make([]byte, 4096))}
+	for i := 0; i < 64; i++ {
+		not.SetMetadataKeyValue(fmt.Sprintf("#key%d", i), "The quick brown fox jumps over the lazy dog")
+	}
+	return not
+}
+
+// serializationCase is an envelope used by the serialization benchmarks and allocation guards.
+type serializationCase struct {
+	name     string
+	envelope envelope
+	new      func() envelope // new creates an empty envelope of the same type, for unmarshalling
+}
+
+func serializationCases() []serializationCase {
+	newMessage := func() envelope { return &Message{} }
+	newNotification := func() envelope { return &Notification{} }
+	newRequestCommand := func() envelope { return &RequestCommand{} }
+	newResponseCommand := func() envelope { return &ResponseCommand{} }
+	return []serializationCase{
+		{"Message/Small", createMessage(), newMessage},
+		{"Message/Large", createLargeMessage(), newMessage},
+		{"Notification/Small", createNotification(), newNotification},
+		{"Notification/Large", createLargeNotification(), newNotification},
+		{"RequestCommand/Small", createGetPingCommand(), newRequestCommand},
+		{"RequestCommand/Large", createLargeRequestCommand(), newRequestCommand},
+		{"ResponseCommand/Small", createResponseCommand(), newResponseCommand},
+		{"ResponseCommand/Large", createLargeResponseCommand(), newResponseCommand},
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	for _, c := range serializationCases() {
+		c := c
+		b.Run(c.name, func(b *testing.B) {
+			// Arrange
+			data, err := json.Marshal(c.envelope)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			// Act
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(c.envelope); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, c := range serializationCases() {
+		c := c
+		b.Run(c.name, func(b *testing.B) {
+			// Arrange
+			data, err := json.Marshal(c.envelope)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			// Act
+			for i := 0; i < b.N; i++ {
+				if err := json.Unmarshal(data, c.new()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// serializationAllocBudgets are the maximum allocations per operation of the serialization cases, which guard
+// against regressions of the serialization fast paths. The budgets have some room over the baselines documented in
+// the README, and should be lowered along with them when an optimization is merged.
+var serializationAllocBudgets = map[string]struct{ marshal, unmarshal float64 }{
+	"Message/Small":         {20, 16},
+	"Message/Large":         {30, 7500},
+	"Notification/Small":    {16, 12},
+	"Notification/Large":    {90, 100},
+	"RequestCommand/Small":  {14, 16},
+	"RequestCommand/Large":  {30, 7500},
+	"ResponseCommand/Small": {12, 12},
+	"ResponseCommand/Large": {25, 7500},
+}
+
+func TestSerialization_AllocationBudgets(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector changes the allocations")
+	}
+	if testing.Short() {
+		t.Skip("skipping the allocation budgets in short mode")
+	}
+	for _, c := range serializationCases() {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			budget, ok := serializationAllocBudgets[c.name]
+			if !ok {
+				t.Fatalf("no allocation budget for %v", c.name)
+			}
+			data, err := json.Marshal(c.envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Act
+			marshal := testing.AllocsPerRun(10, func() {
+				_, _ = json.Marshal(c.envelope)
+			})
+			unmarshal := testing.AllocsPerRun(10, func() {
+				_ = json.Unmarshal(data, c.new())
+			})
+
+			// Assert
+			if marshal > budget.marshal {
+				t.Errorf("marshal: %v allocs/op, budget is %v", marshal, budget.marshal)
+			}
+			if unmarshal > budget.unmarshal {
+				t.Errorf("unmarshal: %v allocs/op, budget is %v", unmarshal, budget.unmarshal)
+			}
+		})
+	}
+}
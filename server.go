@@ -26,6 +26,12 @@ type Server struct {
 	mu            sync.Mutex
 	transportChan chan Transport
 	shutdown      context.CancelFunc
+	sessions      map[string]*ServerChannel // sessions are the established sessions, by id
+	sessionsMu    sync.RWMutex
+	configMu      sync.RWMutex         // configMu protects the config values that can be changed by ApplySettings
+	settings      *serverSettingsState // settings holds the state of the server created from a ServerSettings
+	serveCtx      context.Context      // serveCtx is the context of the listeners started by ListenAndServe
+	serveGroup    *errgroup.Group      // serveGroup runs the accept loops of the listeners
 }
 
 // NewServer creates a new instance of the Server type.
@@ -39,19 +45,35 @@ func NewServer(config *ServerConfig, mux *EnvelopeMux, listeners ...BoundListene
 	if len(listeners) == 0 {
 		panic("empty listeners")
 	}
-	return &Server{
+	if config.AdminACL != nil {
+		// The admin handler is added to a copy, since the mux can be shared by other servers
+		mux = mux.clone()
+	}
+	srv := &Server{
 		config:        config,
 		mux:           mux,
 		listeners:     listeners,
 		transportChan: make(chan Transport, config.Backlog),
+		sessions:      make(map[string]*ServerChannel),
+		settings:      &serverSettingsState{},
+	}
+	if config.AdminACL != nil {
+		// The admin handler must be the first one, since the commands should not be captured by the user handlers
+		mux.reqCmdHandlers = append([]RequestCommandHandler{&adminCommandHandler{
+			srv: srv,
+			acl: config.AdminACL,
+		}}, mux.reqCmdHandlers...)
 	}
+	return srv
 }
 
 // ListenAndServe starts listening for new connections in the registered transport listeners.
 // This is a blocking call which always returns a non nil error.
 // In case of a graceful closing, the returned error is ErrServerClosed.
 func (srv *Server) ListenAndServe() error {
+	srv.mu.Lock()
 	if srv.shutdown != nil {
+		srv.mu.Unlock()
 		return errors.New("server already listening")
 	}
 
@@ -59,22 +81,30 @@ func (srv *Server) ListenAndServe() error {
 	srv.shutdown = cancel
 
 	if len(srv.listeners) == 0 {
+		srv.mu.Unlock()
 		return errors.New("no listeners found")
 	}
 
 	eg, ctx := errgroup.WithContext(ctx)
+	srv.serveCtx = ctx
+	srv.serveGroup = eg
+
+	if err := srv.startPlugins(ctx); err != nil {
+		srv.shutdown = nil
+		cancel()
+		srv.mu.Unlock()
+		return err
+	}
 
 	for _, l := range srv.listeners {
 		if err := l.Listener.Listen(ctx, l.Addr); err != nil {
+			srv.mu.Unlock()
 			return fmt.Errorf("listen error: %w", err)
 		}
 
-		listener := l
-
-		eg.Go(func() error {
-			return acceptTransports(ctx, listener.Listener, srv.transportChan)
-		})
+		eg.Go(srv.acceptFunc(ctx, l.Listener))
 	}
+	srv.mu.Unlock()
 
 	eg.Go(func() error {
 		srv.consumeTransports(ctx)
@@ -89,6 +119,18 @@ func (srv *Server) ListenAndServe() error {
 	return err
 }
 
+// acceptFunc returns the accept loop of a listener. The loop of a listener replaced by ReplaceListener stops without
+// error, so the server keeps running.
+func (srv *Server) acceptFunc(ctx context.Context, listener TransportListener) func() error {
+	return func() error {
+		err := acceptTransports(ctx, listener, srv.transportChan)
+		if ctx.Err() == nil && !srv.hasListener(listener) {
+			return nil
+		}
+		return err
+	}
+}
+
 func acceptTransports(ctx context.Context, listener TransportListener, c chan<- Transport) error {
 	for {
 		transport, err := listener.Accept(ctx)
@@ -109,35 +151,127 @@ func (srv *Server) consumeTransports(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case t := <-srv.transportChan:
-			c := NewServerChannel(t, srv.config.ChannelBufferSize, srv.config.Node, uuid.NewString())
-			go func() {
+			config := srv.currentConfig()
+			c := NewServerChannel(t, config.ChannelBufferSize, config.Node, uuid.NewString())
+			if len(config.Codecs) > 0 {
+				c.SetCodecs(config.Codecs...)
+			}
+			if config.RequireAuthentication {
+				c.RequireAuthentication()
+			}
+			if config.NegotiationTracer != nil {
+				c.SetNegotiationTracer(config.NegotiationTracer)
+			}
+			if config.Tap != nil {
+				c.SetTap(config.Tap)
+			}
+			if config.SendWatchdog != nil {
+				c.SetSendWatchdog(config.SendWatchdog)
+			}
+			if config.Clock != nil {
+				c.SetClock(config.Clock)
+			}
+			if config.EnvelopeIDPolicy != nil {
+				c.SetEnvelopeIDPolicy(config.EnvelopeIDPolicy)
+			}
+			if config.CommandResponseMatching != CommandResponseMatchID {
+				c.SetCommandResponseMatching(config.CommandResponseMatching)
+			}
+			if len(config.Features) > 0 {
+				c.AdvertiseFeatures(config.Features...)
+			}
+			if config.ReplayProtection {
+				c.EnableReplayProtection()
+			}
+			if config.ContentHashes {
+				c.EnableContentHashes()
+			}
+			if config.SequenceNumbers {
+				c.EnableSequenceNumbers()
+			}
+			if config.IdentityMap != nil {
+				c.SetIdentityMap(config.IdentityMap)
+			}
+			if config.ResumptionTokens != nil {
+				c.EnableResumption(config.ResumptionTokens)
+			}
+			if config.Version > 0 {
+				c.SetVersion(config.Version, config.VersionShims...)
+			}
+			if config.OnUnknownEnvelope != nil {
+				c.OnUnknownEnvelope(config.OnUnknownEnvelope)
+			}
+			if config.OnMalformedEnvelope != nil {
+				c.OnMalformedEnvelope(config.OnMalformedEnvelope)
+			}
+			if len(config.Filters) > 0 {
+				c.SetFilters(config.Filters...)
+			}
+			if config.ReauthenticationTimeout > 0 {
+				c.SetReauthenticationTimeout(config.ReauthenticationTimeout)
+			}
+			goLabeled(ctx, "server.session", func(ctx context.Context) {
 				srv.handleChannel(ctx, c)
-			}()
+			}, "session", c.ID())
 		}
 	}
 }
 
 func (srv *Server) handleChannel(ctx context.Context, c *ServerChannel) {
+	config := srv.currentConfig()
+	auditor := config.Auditor
+	authenticate := config.Authenticate
+	if auditor != nil {
+		authenticate = auditAuthenticate(auditor, c, authenticate)
+		ctx = withAuditor(ctx, auditor, c.clock)
+	}
+
 	err := c.EstablishSession(
 		ctx,
-		srv.config.CompOpts,
-		srv.config.EncryptOpts,
-		srv.config.SchemeOpts,
-		srv.config.Authenticate,
-		srv.config.Register,
+		config.CompOpts,
+		config.EncryptOpts,
+		config.SchemeOpts,
+		authenticate,
+		config.Register,
 	)
 
+	if c.Established() {
+		srv.emitSessionEvent(ctx, &config, c.auditEvent(AuditEventSessionEstablished))
+	} else {
+		event := c.auditEvent(AuditEventSessionFailed)
+		if err != nil {
+			event.Reason = err.Error()
+		} else if c.failedReason != nil {
+			event.Reason = c.failedReason.Description
+		}
+		srv.emitSessionEvent(ctx, &config, event)
+	}
+
 	if err != nil {
 		log.Printf("server: establish: %v\n", err)
 		return
 	}
 
-	established := srv.config.Established
+	srv.addSession(c)
+
+	established := config.Established
 	if established != nil {
 		established(c.sessionID, c)
 	}
+	notifyPlugins(config.Plugins, func(p SessionPlugin) {
+		p.SessionEstablished(ctx, c)
+	})
+	if config.ReauthenticationInterval > 0 {
+		reauthCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		goLabeled(reauthCtx, "server.reauthentication", func(ctx context.Context) {
+			c.reauthenticateEvery(ctx, config.ReauthenticationInterval, config.SchemeOpts, config.Authenticate)
+		}, "session", c.ID())
+	}
 
 	defer func() {
+		srv.removeSession(c.sessionID)
+
 		if c.Established() {
 			// Do not use the shared context since it could be canceled
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -145,10 +279,15 @@ func (srv *Server) handleChannel(ctx context.Context, c *ServerChannel) {
 			_ = c.FinishSession(ctx)
 		}
 
-		finished := srv.config.Finished
+		srv.emitSessionEvent(context.Background(), &config, c.auditEvent(AuditEventSessionFinished))
+
+		finished := config.Finished
 		if finished != nil {
 			finished(c.sessionID)
 		}
+		notifyPlugins(config.Plugins, func(p SessionPlugin) {
+			p.SessionFinished(context.Background(), c)
+		})
 	}()
 
 	if err = srv.mux.ListenServer(ctx, c); err != nil {
@@ -157,7 +296,56 @@ func (srv *Server) handleChannel(ctx context.Context, c *ServerChannel) {
 	}
 }
 
+// emitSessionEvent sends the session event to the auditor and the session webhook, if defined.
+func (srv *Server) emitSessionEvent(ctx context.Context, config *ServerConfig, event AuditEvent) {
+	if config.Auditor != nil {
+		config.Auditor.Audit(ctx, event)
+	}
+	if config.SessionWebhook != nil {
+		config.SessionWebhook.notify(event)
+	}
+}
+
+// currentConfig returns a copy of the server configuration, which is not affected by the settings reload.
+func (srv *Server) currentConfig() ServerConfig {
+	srv.configMu.RLock()
+	defer srv.configMu.RUnlock()
+	return *srv.config
+}
+
+func (srv *Server) addSession(c *ServerChannel) {
+	srv.sessionsMu.Lock()
+	defer srv.sessionsMu.Unlock()
+	srv.sessions[c.sessionID] = c
+}
+
+func (srv *Server) removeSession(sessionID string) {
+	srv.sessionsMu.Lock()
+	defer srv.sessionsMu.Unlock()
+	delete(srv.sessions, sessionID)
+}
+
+func (srv *Server) session(sessionID string) (*ServerChannel, bool) {
+	srv.sessionsMu.RLock()
+	defer srv.sessionsMu.RUnlock()
+	c, ok := srv.sessions[sessionID]
+	return c, ok
+}
+
+func (srv *Server) establishedSessions() []*ServerChannel {
+	srv.sessionsMu.RLock()
+	defer srv.sessionsMu.RUnlock()
+	sessions := make([]*ServerChannel, 0, len(srv.sessions))
+	for _, c := range srv.sessions {
+		if c.Established() {
+			sessions = append(sessions, c)
+		}
+	}
+	return sessions
+}
+
 // Close stops the server by closing the transport listeners and all active sessions.
+// The mux Dispatcher, if defined, is also closed, and the plugins are stopped.
 func (srv *Server) Close() error {
 	srv.mu.Lock()
 	defer srv.mu.Unlock()
@@ -177,7 +365,18 @@ func (srv *Server) Close() error {
 		}
 	}
 
-	close(srv.transportChan)
+	// The transport channel is not closed, since the accept and consume loops may still be selecting on it until
+	// they see the canceled context
+
+	if srv.mux.dispatcher != nil {
+		if err := srv.mux.dispatcher.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := stopPlugins(srv.currentConfig().Plugins); err != nil {
+		errs = append(errs, err)
+	}
 	return multierr.Combine(errs...)
 }
 
@@ -189,6 +388,44 @@ type ServerConfig struct {
 	SchemeOpts        []AuthenticationScheme // SchemeOpts defines the authentication schemes that should be presented to the clients during session establishment.
 	Backlog           int                    // Backlog defines the size of the listener's pending connections queue.
 	ChannelBufferSize int                    // ChannelBufferSize determines the internal envelope buffer size for the channels.
+	Codecs            []Codec                // Codecs defines the envelope codecs that can be selected by the clients, in addition to JSON.
+	// RequireAuthentication refuses the guest authentication scheme, even if it is present in SchemeOpts.
+	RequireAuthentication bool
+	// NegotiationTracer receives the session establishment events of the channels.
+	NegotiationTracer NegotiationTracer
+	// Tap mirrors a copy of the envelopes of the sessions to a secondary sink, for compliance archiving.
+	Tap *Tap
+	// SendWatchdog reports the sends of the sessions that are blocked beyond its threshold.
+	SendWatchdog *SendWatchdog
+	// EnvelopeIDPolicy defines the checks of the IDs of the envelopes sent to the sessions.
+	EnvelopeIDPolicy *EnvelopeIDPolicy
+	// CommandResponseMatching defines how the responses received by the sessions are correlated with the commands.
+	CommandResponseMatching CommandResponseMatching
+	// Features are advertised to the clients in the established sessions.
+	Features []Feature
+	// ReplayProtection enables the envelope nonces in the sessions, which are used only with the clients that also
+	// enable them.
+	ReplayProtection bool
+	// ContentHashes makes the sessions embed the content hash in the sent messages and commands.
+	ContentHashes bool
+	// SequenceNumbers makes the sessions stamp the received envelopes with their order. See Envelope.Sequence.
+	SequenceNumbers bool
+	// IdentityMap rewrites the identities of the envelopes between the internal and the external namespaces.
+	IdentityMap *IdentityMap
+	// ResumptionTokens issues the session resumption tokens, which allow the clients to reconnect without the
+	// authentication.
+	ResumptionTokens *ResumptionTokens
+	// Version is the envelope schema version of the server, which is negotiated with the clients.
+	Version int
+	// VersionShims convert the documents exchanged with the clients of older versions.
+	VersionShims []VersionShim
+	// OnUnknownEnvelope receives the envelopes of unknown types, which are ignored by the channels.
+	OnUnknownEnvelope func(ctx context.Context, err *UnknownEnvelopeError)
+	// OnMalformedEnvelope receives the envelopes that could not be decoded, which are skipped by the channels.
+	OnMalformedEnvelope func(ctx context.Context, err *MalformedEnvelopeError)
+	// Filters are the policies that the envelopes received by the sessions must match, in addition to the filters of
+	// the EnvelopeMux. See EnvelopeMux.Filter.
+	Filters []*Policy
 
 	// Authenticate is called for authenticating a client session.
 	// It should return an AuthenticationResult instance with DomainRole different of DomainRoleUnknown for a successful authentication.
@@ -201,6 +438,25 @@ type ServerConfig struct {
 	Established func(sessionID string, c *ServerChannel)
 	// Finished is called when an established session with a node is finished.
 	Finished func(sessionID string)
+	// Plugins are the extensions started and stopped with the server.
+	Plugins []Plugin
+	// Auditor receives the authentication, session and authorization events of the server sessions.
+	Auditor Auditor
+	// Clock provides the timestamps of the server channels, like the ones of the audit events. If not defined, the
+	// SystemClock is used.
+	Clock Clock
+	// SessionWebhook posts the established, failed and finished session events to a webhook.
+	SessionWebhook *SessionWebhook
+	// ReauthenticationTimeout is the time that the clients have for completing a re-authentication. If not defined,
+	// the DefaultReauthenticationTimeout is used.
+	ReauthenticationTimeout time.Duration
+	// ReauthenticationInterval makes the server request a re-authentication of the established sessions on each
+	// interval, for rotating the credentials. If not defined, the sessions are re-authenticated only on request.
+	ReauthenticationInterval time.Duration
+	// AdminACL enables the administration commands for the session nodes that it allows.
+	// The commands allow finishing a session through the AdminSessionsPath and broadcasting messages through the
+	// AdminBroadcastPath.
+	AdminACL AdminACL
 }
 
 var defaultServerConfig = NewServerConfig()
@@ -248,6 +504,10 @@ type ServerBuilder struct {
 	plainAuth    PlainAuthenticator
 	keyAuth      KeyAuthenticator
 	externalAuth ExternalAuthenticator
+	customAuths  map[AuthenticationScheme]CustomAuthenticator
+	certAuth     *certificateAuthenticator
+
+	settings *serverSettingsState // settings is defined for the builders created from a ServerSettings
 }
 
 // NewServerBuilder creates a new ServerBuilder, which is a helper for building Server instances.
@@ -340,11 +600,25 @@ func (b *ServerBuilder) RequestCommandHandler(handler RequestCommandHandler) *Se
 	return b
 }
 
+// MergeHandlerFunc allows the registration of a handler for the merge commands with the specified URI path, which
+// merges the command resource into the current value of the resource.
+func (b *ServerBuilder) MergeHandlerFunc(path string, get ResourceGetter, set ResourceSetter) *ServerBuilder {
+	b.mux.MergeHandlerFunc(path, get, set)
+	return b
+}
+
+// RequestCommandMiddleware allows the registration of middlewares for the received commands with a URI path under
+// the specified prefix. The middlewares are executed in the registration order, before the command handlers.
+func (b *ServerBuilder) RequestCommandMiddleware(prefix string, middlewares ...RequestCommandMiddleware) *ServerBuilder {
+	b.mux.RequestCommandMiddleware(prefix, middlewares...)
+	return b
+}
+
 // AutoReplyPings adds a RequestCommandHandler handler to automatically reply ping requests from the remote node.
 func (b *ServerBuilder) AutoReplyPings() *ServerBuilder {
 	return b.RequestCommandHandlerFunc(
 		func(cmd *RequestCommand) bool {
-			return cmd.Method == CommandMethodGet && cmd.URI.Path() == "/ping"
+			return cmd.Method == CommandMethodGet && cmd.URI.Path() == PingPath
 		},
 		func(ctx context.Context, cmd *RequestCommand, s Sender) error {
 			return s.SendResponseCommand(
@@ -383,6 +657,14 @@ func (b *ServerBuilder) ListenTCP(addr *net.TCPAddr, config *TCPConfig) *ServerB
 	return b
 }
 
+// ListenTransportMux adds a listener for the multiplexed connections opened by the DialTransportMux function, which
+// run several sessions over a single connection. This is an experimental feature.
+func (b *ServerBuilder) ListenTransportMux(addr *net.TCPAddr, config *TCPConfig) *ServerBuilder {
+	listener := NewMuxTransportListener(config)
+	b.listeners = append(b.listeners, NewBoundListener(listener, addr))
+	return b
+}
+
 // ListenWebsocket adds a new Websocket transport listener with the specified configuration.
 // This method can be called multiple times.
 func (b *ServerBuilder) ListenWebsocket(addr *net.TCPAddr, config *WebsocketConfig) *ServerBuilder {
@@ -440,6 +722,20 @@ func (b *ServerBuilder) EnableTransportAuthentication() *ServerBuilder {
 	return b
 }
 
+// EnableCertificateAuthentication enables the use of transport authentication scheme with the client certificates of
+// the TLS sessions, which are mapped to the identities by the mapper. The sessions are authenticated if the
+// certificate is mapped to the identity of the session and, if the checker is not nil, it was not revoked.
+// The TLSConfig of the listeners must verify the client certificates, with the tls.RequireAndVerifyClientCert or
+// tls.VerifyClientCertIfGiven option and the pool of the CAs that issue them, since the certificates that were not
+// verified are refused.
+func (b *ServerBuilder) EnableCertificateAuthentication(mapper CertificateMapper, checker RevocationChecker) *ServerBuilder {
+	if mapper == nil {
+		panic("nil mapper")
+	}
+	b.certAuth = &certificateAuthenticator{mapper: mapper, checker: checker}
+	return b.EnableTransportAuthentication()
+}
+
 // PlainAuthenticator defines a function for authenticating an identity session using a password.
 type PlainAuthenticator func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error)
 
@@ -489,12 +785,216 @@ func (b *ServerBuilder) EnableExternalAuthentication(a ExternalAuthenticator) *S
 	return b
 }
 
+// CustomAuthenticator defines a function for authenticating an identity session using a custom authentication scheme.
+type CustomAuthenticator func(ctx context.Context, identity Identity, a Authentication) (*AuthenticationResult, error)
+
+// EnableCustomAuthentication enables the use of a custom authentication scheme during the authentication of the
+// client sessions. The scheme Authentication type should be registered with the RegisterAuthenticationFactory function.
+// The provided CustomAuthenticator function is called for authenticating any session with this scheme.
+func (b *ServerBuilder) EnableCustomAuthentication(scheme AuthenticationScheme, a CustomAuthenticator) *ServerBuilder {
+	if a == nil {
+		panic("nil authenticator")
+	}
+	if _, ok := authFactories[scheme]; !ok {
+		panic(fmt.Errorf("the authentication scheme '%v' is not registered", scheme))
+	}
+	if b.customAuths == nil {
+		b.customAuths = make(map[AuthenticationScheme]CustomAuthenticator)
+	}
+	b.customAuths[scheme] = a
+	if !contains(b.config.SchemeOpts, scheme) {
+		b.config.SchemeOpts = append(b.config.SchemeOpts, scheme)
+	}
+	return b
+}
+
+// RequireAuthentication refuses the guest authentication scheme, failing the sessions of the clients that do not
+// provide credentials.
+func (b *ServerBuilder) RequireAuthentication() *ServerBuilder {
+	b.config.RequireAuthentication = true
+	return b
+}
+
+// TraceNegotiation defines a tracer for the session establishment events of the channels.
+func (b *ServerBuilder) TraceNegotiation(t NegotiationTracer) *ServerBuilder {
+	b.config.NegotiationTracer = t
+	return b
+}
+
+// EnableReplayProtection makes the sessions stamp the envelopes with nonces and fail if a replayed envelope is
+// received. The protection is used only with the clients that also enable it.
+func (b *ServerBuilder) EnableReplayProtection() *ServerBuilder {
+	b.config.ReplayProtection = true
+	return b
+}
+
+// EnableContentHashes makes the sessions embed the content hash in the sent messages and commands, allowing the
+// clients to detect corrupted contents.
+func (b *ServerBuilder) EnableContentHashes() *ServerBuilder {
+	b.config.ContentHashes = true
+	return b
+}
+
+// EnableSequenceNumbers makes the sessions stamp the received envelopes with their order, allowing it to be restored
+// after the concurrent processing. See Envelope.Sequence.
+func (b *ServerBuilder) EnableSequenceNumbers() *ServerBuilder {
+	b.config.SequenceNumbers = true
+	return b
+}
+
+// MapIdentities rewrites the identities of the envelopes exchanged with the clients according to the map, which can
+// be changed while the server is running. See IdentityMap.
+func (b *ServerBuilder) MapIdentities(m *IdentityMap) *ServerBuilder {
+	b.config.IdentityMap = m
+	return b
+}
+
+// EnableResumption makes the sessions issue resumption tokens, which allow the clients to reconnect without the
+// authentication until they expire or are revoked. See ResumptionTokens.
+func (b *ServerBuilder) EnableResumption(tokens *ResumptionTokens) *ServerBuilder {
+	b.config.ResumptionTokens = tokens
+	return b
+}
+
+// VerifyContentHashes enables the verification of the content hashes of the received messages and commands,
+// rejecting the ones whose content does not match the hash. The envelopes without a hash are accepted.
+func (b *ServerBuilder) VerifyContentHashes() *ServerBuilder {
+	b.mux.VerifyContentHashes()
+	return b
+}
+
+// OnHandlerError defines the policy for the failures of the envelope handlers, like sending a failed notification
+// or dropping the envelope instead of finishing the session. The handler panics are also recovered.
+func (b *ServerBuilder) OnHandlerError(policy HandlerErrorPolicy) *ServerBuilder {
+	b.mux.OnHandlerError(policy)
+	return b
+}
+
+// Filter defines a policy that the received envelopes must match for being handled, like
+// lime.MustCompilePolicy(`from.domain == "msging.net"`). See EnvelopeMux.Filter.
+func (b *ServerBuilder) Filter(policy *Policy) *ServerBuilder {
+	b.mux.Filter(policy)
+	return b
+}
+
+// Version defines the envelope schema version of the server and the shims for converting the documents exchanged
+// with the clients of older versions.
+func (b *ServerBuilder) Version(version int, shims ...VersionShim) *ServerBuilder {
+	b.config.Version = version
+	b.config.VersionShims = shims
+	return b
+}
+
+// OnUnknownEnvelope defines a handler for the received envelopes of unknown types, which are ignored by the channels.
+func (b *ServerBuilder) OnUnknownEnvelope(f func(ctx context.Context, err *UnknownEnvelopeError)) *ServerBuilder {
+	b.config.OnUnknownEnvelope = f
+	return b
+}
+
+// OnMalformedEnvelope defines a handler for the received envelopes that could not be decoded, which are skipped by
+// the channels.
+func (b *ServerBuilder) OnMalformedEnvelope(f func(ctx context.Context, err *MalformedEnvelopeError)) *ServerBuilder {
+	b.config.OnMalformedEnvelope = f
+	return b
+}
+
+// Tap defines a Tap for mirroring the envelopes of the sessions to a secondary sink.
+func (b *ServerBuilder) Tap(t *Tap) *ServerBuilder {
+	b.config.Tap = t
+	return b
+}
+
+// Reauthentication makes the server request a re-authentication of the established sessions on each interval, which
+// the clients should complete before the timeout, for rotating the credentials. The timeout also applies to the
+// re-authentications requested through Server.ReauthenticateSession. If the interval is zero, the sessions are
+// re-authenticated only on request.
+func (b *ServerBuilder) Reauthentication(interval, timeout time.Duration) *ServerBuilder {
+	if interval < 0 || timeout < 0 {
+		panic("the interval and timeout should not be negative")
+	}
+	b.config.ReauthenticationInterval = interval
+	b.config.ReauthenticationTimeout = timeout
+	return b
+}
+
+// SendWatchdog defines a watchdog for the sends of the sessions that are blocked beyond its threshold, like the ones
+// to clients that stopped reading from the connection.
+func (b *ServerBuilder) SendWatchdog(w *SendWatchdog) *ServerBuilder {
+	b.config.SendWatchdog = w
+	return b
+}
+
+// EnvelopeIDPolicy defines the checks of the IDs of the envelopes sent to the sessions, like the format validation
+// and the detection of the reused message and command IDs.
+func (b *ServerBuilder) EnvelopeIDPolicy(p *EnvelopeIDPolicy) *ServerBuilder {
+	b.config.EnvelopeIDPolicy = p
+	return b
+}
+
+// CommandResponseMatching defines how the responses received by the sessions are correlated with the commands sent
+// to the clients.
+func (b *ServerBuilder) CommandResponseMatching(m CommandResponseMatching) *ServerBuilder {
+	b.config.CommandResponseMatching = m
+	return b
+}
+
+// AdvertiseFeatures adds features to be advertised to the clients in the established sessions, which can be checked
+// by them through the Supports method of the channel.
+func (b *ServerBuilder) AdvertiseFeatures(features ...Feature) *ServerBuilder {
+	b.config.Features = append(b.config.Features, features...)
+	return b
+}
+
 // ChannelBufferSize determines the internal envelope buffer size for the channels.
 func (b *ServerBuilder) ChannelBufferSize(bufferSize int) *ServerBuilder {
 	b.config.ChannelBufferSize = bufferSize
 	return b
 }
 
+// WorkerPool enables the handling of the received envelopes by a pool with the specified number of workers and
+// queue size, instead of a goroutine per session. The envelopes of each sender identity are handled in order.
+// The pool is closed with the server.
+func (b *ServerBuilder) WorkerPool(workers int, queueSize int) *ServerBuilder {
+	b.mux.UseDispatcher(NewWorkerPool(workers, queueSize))
+	return b
+}
+
+// ShardedWorkers enables the handling of the received envelopes by the specified number of shards, which are
+// workers with dedicated queues. The envelopes of each sender identity are always handled by the same shard.
+// The shards are closed with the server.
+func (b *ServerBuilder) ShardedWorkers(shards int, queueSize int) *ServerBuilder {
+	b.mux.UseDispatcher(NewShardedDispatcher(shards, queueSize))
+	return b
+}
+
+// VerifyDelegations enables the verification of the envelopes sent on behalf of other identities through the PP
+// field, rejecting the ones without a delegation confirmed by the checker.
+func (b *ServerBuilder) VerifyDelegations(checker DelegationChecker) *ServerBuilder {
+	b.mux.VerifyDelegations(checker)
+	return b
+}
+
+// MeterTenants enables the counting of the envelopes received from each tenant and the enforcement of its quotas,
+// rejecting the messages and commands of the tenants that exceeded them. See TenantMeter.
+func (b *ServerBuilder) MeterTenants(meter *TenantMeter) *ServerBuilder {
+	b.mux.MeterTenants(meter)
+	return b
+}
+
+// IdempotentCommands enables the caching of the command responses by sender and envelope ID for the specified
+// duration, so the commands retried by the clients are not executed again.
+func (b *ServerBuilder) IdempotentCommands(ttl time.Duration) *ServerBuilder {
+	b.mux.RequestCommandMiddleware("/", NewCommandResponseCache(ttl).Middleware())
+	return b
+}
+
+// Codecs allows the clients to select alternative envelope codecs during the session establishment.
+// The codec is only switched in transports that implement the CodecTransport interface.
+func (b *ServerBuilder) Codecs(codecs ...Codec) *ServerBuilder {
+	b.config.Codecs = append(b.config.Codecs, codecs...)
+	return b
+}
+
 // Register is called for the client Node address registration.
 // It receives a candidate node from the client and should return the effective node address that will be assigned
 // to the session.
@@ -515,13 +1015,54 @@ func (b *ServerBuilder) Finished(finished func(sessionID string)) *ServerBuilder
 	return b
 }
 
+// Auditor defines the receiver of the security events of the server sessions, like the authentication attempts.
+// Use NewJSONAuditor for writing the events to a file.
+func (b *ServerBuilder) Auditor(a Auditor) *ServerBuilder {
+	b.config.Auditor = a
+	return b
+}
+
+// Clock defines the clock for the timestamps of the server channels, like the ones of the audit events.
+func (b *ServerBuilder) Clock(c Clock) *ServerBuilder {
+	b.config.Clock = c
+	return b
+}
+
+// SessionWebhook defines a webhook for the established, failed and finished session events, like
+// NewSessionWebhook(NewWebhookConfig("https://billing.example.com/sessions")). The webhook is not closed with the
+// server.
+func (b *ServerBuilder) SessionWebhook(w *SessionWebhook) *ServerBuilder {
+	b.config.SessionWebhook = w
+	return b
+}
+
+// AdminCommands enables the administration commands for the session nodes allowed by the ACL, which can finish
+// the sessions of other nodes and broadcast announcements to all established sessions.
+func (b *ServerBuilder) AdminCommands(acl AdminACL) *ServerBuilder {
+	if acl == nil {
+		panic("nil acl")
+	}
+	b.config.AdminACL = acl
+	return b
+}
+
 // Build creates a new instance of Server.
 func (b *ServerBuilder) Build() *Server {
-	b.config.Authenticate = buildAuthenticate(b.plainAuth, b.keyAuth, b.externalAuth)
-	return NewServer(b.config, b.mux, b.listeners...)
+	b.config.Authenticate = buildAuthenticate(b.plainAuth, b.keyAuth, b.externalAuth, b.customAuths, b.certAuth)
+	srv := NewServer(b.config, b.mux, b.listeners...)
+	if b.settings != nil {
+		srv.settings = b.settings
+	}
+	return srv
 }
 
-func buildAuthenticate(plainAuth PlainAuthenticator, keyAuth KeyAuthenticator, externalAuth ExternalAuthenticator) func(
+func buildAuthenticate(
+	plainAuth PlainAuthenticator,
+	keyAuth KeyAuthenticator,
+	externalAuth ExternalAuthenticator,
+	customAuths map[AuthenticationScheme]CustomAuthenticator,
+	certAuth *certificateAuthenticator,
+) func(
 	ctx context.Context,
 	identity Identity,
 	authentication Authentication,
@@ -534,7 +1075,10 @@ func buildAuthenticate(plainAuth PlainAuthenticator, keyAuth KeyAuthenticator, e
 			}
 			return MemberAuthenticationResult(), nil
 		case *TransportAuthentication:
-			return nil, errors.New("transport auth not implemented yet")
+			if certAuth == nil {
+				return nil, errors.New("transport authenticator is nil")
+			}
+			return certAuth.authenticate(ctx, identity)
 		case *PlainAuthentication:
 			if plainAuth == nil {
 				return nil, errors.New("plain authenticator is nil")
@@ -560,6 +1104,12 @@ func buildAuthenticate(plainAuth PlainAuthenticator, keyAuth KeyAuthenticator, e
 			return externalAuth(ctx, identity, a.Token, a.Issuer)
 		}
 
+		if authentication != nil {
+			if customAuth, ok := customAuths[authentication.GetAuthenticationScheme()]; ok {
+				return customAuth(ctx, identity, authentication)
+			}
+		}
+
 		return nil, errors.New("unknown authentication scheme")
 	}
 }
@@ -5,10 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 )
 
 type ServerChannel struct {
 	*channel
+	requireAuthentication bool
+	reauth                reauthentication
+	resumption            *ResumptionTokens // resumption validates and issues the session resumption tokens
+	resumptionToken       string            // resumptionToken is the token issued for the established session
 }
 
 func NewServerChannel(t Transport, bufferSize int, serverNode Node, sessionID string) *ServerChannel {
@@ -24,7 +29,18 @@ func NewServerChannel(t Transport, bufferSize int, serverNode Node, sessionID st
 	c.localNode = serverNode
 	c.sessionID = sessionID
 
-	return &ServerChannel{channel: c}
+	sc := &ServerChannel{channel: c}
+	c.sessionHandler = sc.handleReauthentication
+	return sc
+}
+
+// RequireAuthentication makes the channel refuse the guest authentication scheme, failing the session establishment
+// if no other scheme is available.
+func (c *ServerChannel) RequireAuthentication() {
+	if err := c.ensureState(SessionStateNew, "require authentication"); err != nil {
+		panic(err)
+	}
+	c.requireAuthentication = true
 }
 
 // receiveNewSession receives a new session envelope from the client node.
@@ -146,8 +162,6 @@ func (c *ServerChannel) sendEstablishedSession(ctx context.Context, node Node) e
 		return fmt.Errorf("cannot establish the session in the %v state", c.state)
 	}
 
-	c.setState(SessionStateEstablished)
-
 	c.remoteNode = node
 
 	ses := Session{
@@ -158,6 +172,30 @@ func (c *ServerChannel) sendEstablishedSession(ctx context.Context, node Node) e
 		},
 		State: SessionStateEstablished,
 	}
+	if c.version > 0 {
+		ses.SetMetadataKeyValue(MetadataKeySessionVersion, strconv.Itoa(c.sessionVersion))
+	}
+	if c.resumptionToken != "" {
+		ses.SetMetadataKeyValue(MetadataKeyResumptionToken, c.resumptionToken)
+	}
+	c.advertiseFeatures(&ses)
+	c.offerReplayKey(&ses)
+
+	if c.codec != nil {
+		// The established session is sent with the current codec and the new one must be set before starting the
+		// channel receiver, so the state is changed only after the switch.
+		ses.SetMetadataKeyValue(MetadataKeySessionCodec, c.codec.Name())
+		if err := c.sendSession(ctx, &ses); err != nil {
+			return err
+		}
+		if err := c.switchCodec(ctx, c.codec); err != nil {
+			return err
+		}
+		c.setState(SessionStateEstablished)
+		return nil
+	}
+
+	c.setState(SessionStateEstablished)
 	return c.sendSession(ctx, &ses)
 }
 
@@ -223,11 +261,13 @@ func (c *ServerChannel) EstablishSession(
 		return err
 	}
 
+	c.codec = c.selectCodec(ses)
+	c.selectVersion(ses)
+	c.receiveFeatures(ses)
+	c.acceptReplayKey(ses)
+
 	if ses.ID != "" {
-		return c.FailSession(ctx, &Reason{
-			Code:        1,
-			Description: "Invalid session id",
-		})
+		return c.FailSession(ctx, NewReason(ReasonCodeSessionError, "Invalid session id"))
 	}
 
 	if ses.State == SessionStateNew {
@@ -261,10 +301,7 @@ func (c *ServerChannel) EstablishSession(
 
 	// If the channel state is not final at this point, fail the session
 	if c.state != SessionStateEstablished && c.state != SessionStateFailed && c.transport.Connected() {
-		return c.FailSession(ctx, &Reason{
-			Code:        1,
-			Description: "The session establishment failed",
-		})
+		return c.FailSession(ctx, NewReason(ReasonCodeSessionError, "The session establishment failed"))
 	}
 
 	return nil
@@ -277,10 +314,7 @@ func (c *ServerChannel) negotiateSession(ctx context.Context, compOpts []Session
 	}
 
 	if ses.ID != c.sessionID {
-		return c.FailSession(ctx, &Reason{
-			Code:        1,
-			Description: "Invalid session id",
-		})
+		return c.FailSession(ctx, NewReason(ReasonCodeSessionError, "Invalid session id"))
 	}
 
 	// Convert the slices to maps for lookup
@@ -317,10 +351,7 @@ func (c *ServerChannel) negotiateSession(ctx context.Context, compOpts []Session
 		}
 	}
 
-	return c.FailSession(ctx, &Reason{
-		Code:        1,
-		Description: "An invalid negotiation option was selected",
-	})
+	return c.FailSession(ctx, NewReason(ReasonCodeSessionNegotiationInvalidOptions, "An invalid negotiation option was selected"))
 }
 
 func (c *ServerChannel) authenticateSession(
@@ -328,6 +359,19 @@ func (c *ServerChannel) authenticateSession(
 	schemeOpts []AuthenticationScheme,
 	authenticate func(context.Context, Identity, Authentication) (*AuthenticationResult, error),
 	register func(context.Context, Node, *ServerChannel) (Node, error)) error {
+	if c.requireAuthentication {
+		authSchemeOpts := make([]AuthenticationScheme, 0, len(schemeOpts))
+		for _, v := range schemeOpts {
+			if v != AuthenticationSchemeGuest {
+				authSchemeOpts = append(authSchemeOpts, v)
+			}
+		}
+		if len(authSchemeOpts) == 0 {
+			return c.FailSession(ctx, NewReason(ReasonCodeSessionAuthenticationFailed, "The authentication is required but no scheme is available"))
+		}
+		schemeOpts = authSchemeOpts
+	}
+
 	// Convert the slice to a map for lookup
 	schemeOptsMap := make(map[AuthenticationScheme]struct{})
 	for _, v := range schemeOpts {
@@ -341,33 +385,29 @@ func (c *ServerChannel) authenticateSession(
 
 	for c.state == SessionStateAuthenticating {
 		if ses.State != SessionStateAuthenticating {
-			return c.FailSession(ctx, &Reason{
-				Code:        1,
-				Description: "Invalid session state",
-			})
+			return c.FailSession(ctx, NewReason(ReasonCodeSessionInvalidActionForState, "Invalid session state"))
 		}
 
 		if ses.ID != c.sessionID {
-			return c.FailSession(ctx, &Reason{
-				Code:        1,
-				Description: "Invalid session id",
-			})
+			return c.FailSession(ctx, NewReason(ReasonCodeSessionError, "Invalid session id"))
 		}
 		if _, ok := schemeOptsMap[ses.Scheme]; !ok {
-			return c.FailSession(ctx, &Reason{
-				Code:        1,
-				Description: "An invalid authentication scheme was selected",
-			})
+			return c.FailSession(ctx, NewReason(ReasonCodeSessionAuthenticationFailed, "An invalid authentication scheme was selected"))
 		}
 
-		// Authenticate using the provided func
-		authResult, err := authenticate(ctx, ses.From.Identity, ses.Authentication)
-		if err != nil {
-			return err
+		// Authenticate using the resumption token or the provided func
+		authResult, ok := c.resume(ses)
+		if !ok {
+			if authResult, err = authenticate(tlsStateContext(ctx, c.channel), ses.From.Identity, ses.Authentication); err != nil {
+				return err
+			}
 		}
 
 		// If the auth result contains the identity domain role, it has succeeded
 		if authResult.Role != "" && authResult.Role != DomainRoleUnknown {
+			if err = c.issueResumptionToken(ses.From.Identity, authResult.Role); err != nil {
+				return err
+			}
 			node, err := register(ctx, ses.From, c)
 			if err != nil {
 				return err
@@ -383,10 +423,7 @@ func (c *ServerChannel) authenticateSession(
 			}
 
 		} else {
-			if err = c.FailSession(ctx, &Reason{
-				Code:        1,
-				Description: "The session authentication failed",
-			}); err != nil {
+			if err = c.FailSession(ctx, NewReason(ReasonCodeSessionAuthenticationFailed, "The session authentication failed")); err != nil {
 				return err
 			}
 		}
@@ -437,6 +474,7 @@ func (c *ServerChannel) FailSession(ctx context.Context, reason *Reason) error {
 	}
 	err := c.sendSession(ctx, &ses)
 
+	c.failedReason = reason
 	c.setState(SessionStateFailed)
 
 	if err == nil {
@@ -179,3 +179,59 @@ func TestServerChannel_FailSession(t *testing.T) {
 	assert.Equal(t, SessionStateFailed, s.State)
 	assert.Equal(t, r, s.Reason)
 }
+
+func TestServerChannel_EstablishSession_RequireAuthentication(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	sessionID := "52e59849-19a8-4b2d-86b7-3fa563cdb616"
+	serverNode := Node{
+		Identity: Identity{Name: "postmaster", Domain: "limeprotocol.org"},
+		Instance: "server1",
+	}
+	c := NewServerChannel(server, 1, serverNode, sessionID)
+	defer silentClose(c)
+	c.RequireAuthentication()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	sesChan := make(chan *Session, 1)
+
+	// Act
+	go func() {
+		err := client.Send(ctx, &Session{
+			State: SessionStateNew,
+		})
+		if err != nil {
+			return
+		}
+		env, err := client.Receive(ctx)
+		if err != nil {
+			return
+		}
+		if s, ok := env.(*Session); ok {
+			sesChan <- s
+		}
+	}()
+	err := c.EstablishSession(
+		ctx,
+		[]SessionCompression{SessionCompressionNone},
+		[]SessionEncryption{SessionEncryptionNone},
+		[]AuthenticationScheme{AuthenticationSchemeGuest},
+		func(context.Context, Identity, Authentication) (*AuthenticationResult, error) {
+			return MemberAuthenticationResult(), nil
+		},
+		func(ctx context.Context, node Node, _ *ServerChannel) (Node, error) {
+			return node, nil
+		},
+	)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStateFailed, c.state)
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "receive session timeout")
+	case ses := <-sesChan:
+		assert.Equal(t, SessionStateFailed, ses.State)
+		assert.NotNil(t, ses.Reason)
+	}
+}
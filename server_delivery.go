@@ -0,0 +1,37 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/multierr"
+)
+
+// DeliverMessage sends the message to the established sessions of its destination, like a message received by a
+// handler that should be forwarded to another node. If the destination has no instance, a copy is sent to each session
+// of the identity, otherwise only to the session of the node.
+// It returns the number of sessions that the message was sent to, which is zero if the destination is not connected,
+// and the errors of the sessions which the sending failed.
+func (srv *Server) DeliverMessage(ctx context.Context, msg *Message) (int, error) {
+	if msg == nil {
+		panic("nil message")
+	}
+
+	var sent int
+	var errs []error
+	for _, c := range srv.establishedSessions() {
+		if c.remoteNode.Identity != msg.To.Identity || (msg.To.Instance != "" && c.remoteNode.Instance != msg.To.Instance) {
+			continue
+		}
+		m := *msg
+		if m.From == (Node{}) {
+			m.From = c.localNode
+		}
+		m.To = c.remoteNode
+		if err := c.SendMessage(ctx, &m); err != nil {
+			errs = append(errs, fmt.Errorf("deliver to %v: %w", c.sessionID, err))
+			continue
+		}
+		sent++
+	}
+	return sent, multierr.Combine(errs...)
+}
@@ -0,0 +1,59 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestServer_DeliverMessage(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("deliver-message")
+	srv := startAdminServer(t, addr, &auditRecorder{})
+	defer silentClose(srv)
+	recipient := establishInProcGuestSession(t, ctx, addr, "recipient")
+	defer silentClose(recipient)
+	other := establishInProcGuestSession(t, ctx, addr, "other")
+	defer silentClose(other)
+	msg := createMessage()
+	msg.From = Node{}
+	msg.To = Node{Identity: Identity{Name: "recipient", Domain: "localhost"}}
+
+	// Act
+	sent, err := srv.DeliverMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "receive message timeout")
+	case received := <-recipient.MsgChan():
+		assert.Equal(t, msg.ID, received.ID)
+		assert.Equal(t, recipient.remoteNode, received.From)
+	}
+	assert.Empty(t, other.MsgChan())
+}
+
+func TestServer_DeliverMessage_NotConnected(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("deliver-message-offline")
+	srv := startAdminServer(t, addr, &auditRecorder{})
+	defer silentClose(srv)
+	recipient := establishInProcGuestSession(t, ctx, addr, "recipient")
+	defer silentClose(recipient)
+	msg := createMessage()
+	msg.To = Node{Identity: Identity{Name: "recipient", Domain: "localhost"}, Instance: "other"}
+
+	// Act
+	sent, err := srv.DeliverMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, sent)
+}
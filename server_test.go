@@ -32,6 +32,54 @@ func createBoundWSTransportListener(addr net.Addr) BoundListener {
 	}
 }
 
+// readyListener signals when the transport listener is listening, like the one of the limetest broker.
+type readyListener struct {
+	TransportListener
+	ready chan struct{}
+}
+
+func (l *readyListener) Listen(ctx context.Context, addr net.Addr) error {
+	if err := l.TransportListener.Listen(ctx, addr); err != nil {
+		return err
+	}
+	close(l.ready)
+	return nil
+}
+
+// startServer runs the server in background, returning when its listeners are listening.
+func startServer(t testing.TB, srv *Server) {
+	t.Helper()
+	listeners := append([]BoundListener{}, srv.listeners...)
+	ready := make([]chan struct{}, len(srv.listeners))
+	for i, l := range srv.listeners {
+		ready[i] = make(chan struct{})
+		srv.listeners[i].Listener = &readyListener{TransportListener: l.Listener, ready: ready[i]}
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ListenAndServe()
+	}()
+	for _, r := range ready {
+		select {
+		case <-r:
+		case err := <-done:
+			t.Fatalf("start server: %v", err)
+		}
+	}
+	// The original listeners are restored for the server methods that depend on their types
+	srv.mu.Lock()
+	copy(srv.listeners, listeners)
+	srv.mu.Unlock()
+}
+
+// acceptAnyGuest makes the server accept the guest sessions with any name, instead of only the UUID ones, which is
+// done by the default authenticator of the ServerConfig. The guest scheme becomes the only option.
+func acceptAnyGuest(srv *Server) *Server {
+	srv.config.Authenticate = NewServerConfig().Authenticate
+	srv.config.SchemeOpts = []AuthenticationScheme{AuthenticationSchemeGuest}
+	return srv
+}
+
 func TestServer_ListenAndServe_WithMultipleListeners(t *testing.T) {
 	// Arrange
 	defer goleak.VerifyNone(t)
@@ -197,3 +245,28 @@ func TestServerBuilder_Build(t *testing.T) {
 	//builder := NewServerBuilder().
 
 }
+
+func TestServerBuilder_EnableCustomAuthentication(t *testing.T) {
+	// Arrange
+	RegisterAuthenticationFactory(func() Authentication {
+		return &tokenAuthentication{}
+	})
+	var actual Authentication
+	srv := NewServerBuilder().
+		ListenInProcess("localhost").
+		EnableCustomAuthentication("x-token", func(ctx context.Context, identity Identity, a Authentication) (*AuthenticationResult, error) {
+			actual = a
+			return MemberAuthenticationResult(), nil
+		}).
+		Build()
+	auth := &tokenAuthentication{Token: "abc"}
+
+	// Act
+	result, err := srv.config.Authenticate(context.Background(), Identity{Name: "golang"}, auth)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, DomainRoleMember, result.Role)
+	assert.Equal(t, auth, actual)
+	assert.Contains(t, srv.config.SchemeOpts, AuthenticationScheme("x-token"))
+}
@@ -3,7 +3,6 @@ package lime
 import (
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 )
 
@@ -51,7 +50,7 @@ func (s *Session) MarshalJSON() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(raw)
+	return marshalRawEnvelope(envelopeKindSession, raw)
 }
 
 func (s *Session) UnmarshalJSON(b []byte) error {
@@ -80,7 +79,7 @@ func (s *Session) toRawEnvelope() (*rawEnvelope, error) {
 	if s.Authentication != nil {
 		b, err := json.Marshal(s.Authentication)
 		if err != nil {
-			return nil, err
+			return nil, newEnvelopeError(envelopeKindSession, s.ID, "authentication", err)
 		}
 		a := json.RawMessage(b)
 		raw.Authentication = &a
@@ -120,17 +119,17 @@ func (s *Session) populate(raw *rawEnvelope) error {
 	// Create the auth type instance and unmarshal the json to it
 	if raw.Authentication != nil {
 		if raw.Scheme == nil {
-			return errors.New("session scheme is required when authentication is present")
+			return newEnvelopeError(envelopeKindSession, raw.ID, "scheme", ErrFieldRequired)
 		}
 
 		factory, ok := authFactories[*raw.Scheme]
 		if !ok {
-			return fmt.Errorf(`unknown authentication scheme '%v'`, raw.Scheme)
+			return newEnvelopeError(envelopeKindSession, raw.ID, "scheme", fmt.Errorf(`unknown authentication scheme '%v'`, *raw.Scheme))
 		}
 		a := factory()
 		err := json.Unmarshal(*raw.Authentication, &a)
 		if err != nil {
-			return err
+			return newEnvelopeError(envelopeKindSession, raw.ID, "authentication", err)
 		}
 
 		s.Authentication = a
@@ -138,7 +137,7 @@ func (s *Session) populate(raw *rawEnvelope) error {
 	}
 
 	if raw.State == nil {
-		return errors.New("session state is required")
+		return newEnvelopeError(envelopeKindSession, raw.ID, "state", ErrFieldRequired)
 	}
 
 	s.State = *raw.State
@@ -300,6 +299,18 @@ var authFactories = map[AuthenticationScheme]func() Authentication{
 	},
 }
 
+// RegisterAuthenticationFactory allows the registration of custom Authentication types, which allow these types to be
+// discovered for the session deserialization process. The factory is registered for the scheme returned by the
+// GetAuthenticationScheme method of the created instance. The payload is marshaled using the encoding/json package.
+func RegisterAuthenticationFactory(f func() Authentication) {
+	a := f()
+	scheme := a.GetAuthenticationScheme()
+	if scheme == "" {
+		panic("the authentication scheme cannot be empty")
+	}
+	authFactories[scheme] = f
+}
+
 // Authentication defines a session authentications scheme container
 type Authentication interface {
 	GetAuthenticationScheme() AuthenticationScheme
@@ -0,0 +1,40 @@
+package lime
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrChannelClosed is returned by the channel Err method when the receiver was stopped locally, like by closing the
+// channel.
+var ErrChannelClosed = errors.New("channel closed")
+
+// SessionEndedError is returned by the channel Err method when the session was finished or failed, holding the
+// last session envelope, with the failure reason, if any.
+type SessionEndedError struct {
+	Session *Session
+}
+
+func (e *SessionEndedError) Error() string {
+	if e.Session.Reason != nil {
+		return fmt.Sprintf("session %v: %v", e.Session.State, *e.Session.Reason)
+	}
+	return fmt.Sprintf("session %v", e.Session.State)
+}
+
+// Done returns a channel that is closed when the session ends, after which the MsgChan, NotChan, ReqCmdChan and
+// RespCmdChan channels are also closed, allowing the select loops to exit. The cause is returned by Err.
+func (c *channel) Done() <-chan struct{} {
+	return c.rcvDone
+}
+
+// Err returns nil if Done is not yet closed. Otherwise, it returns a SessionEndedError when the session was finished
+// or failed, by any of the parties, ErrChannelClosed if the channel was closed locally, or the transport error.
+func (c *channel) Err() error {
+	select {
+	case <-c.rcvDone:
+		return c.rcvErr
+	default:
+		return nil
+	}
+}
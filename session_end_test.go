@@ -0,0 +1,96 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestChannel_Err_WhenEstablished(t *testing.T) {
+	// Arrange
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+
+	// Act
+	c.setState(SessionStateEstablished)
+
+	// Assert
+	assert.NoError(t, c.Err())
+	select {
+	case <-c.Done():
+		t.Fatal("done should not be closed")
+	default:
+	}
+}
+
+func TestChannel_Done_WhenRemoteFailed(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	c.client = true
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	reason := &Reason{Code: ReasonCodeSessionError, Description: "Server shutting down"}
+
+	// Act
+	_ = server.Send(ctx, &Session{State: SessionStateFailed, Reason: reason})
+
+	// Assert
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case <-c.Done():
+	}
+	_, ok := <-c.MsgChan()
+	assert.False(t, ok)
+	var endedErr *SessionEndedError
+	if assert.True(t, errors.As(c.Err(), &endedErr)) {
+		assert.Equal(t, SessionStateFailed, endedErr.Session.State)
+		assert.Equal(t, reason, endedErr.Session.Reason)
+	}
+}
+
+func TestChannel_Done_WhenRemoteFinished(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	c.client = true
+	defer silentClose(c)
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_ = server.Send(ctx, &Session{State: SessionStateFinished})
+
+	// Assert
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case <-c.Done():
+	}
+	var endedErr *SessionEndedError
+	if assert.True(t, errors.As(c.Err(), &endedErr)) {
+		assert.Equal(t, SessionStateFinished, endedErr.Session.State)
+		assert.Nil(t, endedErr.Session.Reason)
+	}
+}
+
+func TestChannel_Err_WhenClosed(t *testing.T) {
+	// Arrange
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	c.setState(SessionStateEstablished)
+
+	// Act
+	_ = c.Close()
+
+	// Assert
+	<-c.Done()
+	assert.ErrorIs(t, c.Err(), ErrChannelClosed)
+}
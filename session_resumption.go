@@ -0,0 +1,159 @@
+package lime
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MetadataKeyResumptionToken is the session metadata key with the resumption token, which is issued by the server
+// in the established session and presented by the client in the authenticating session of a new connection.
+const MetadataKeyResumptionToken = "#resumptionToken"
+
+// ResumptionTokens issues and validates the session resumption tokens of a server, which allow the clients to
+// reconnect without the full authentication, like the validation of an external token. Each token can be used
+// once, and the resumed session receives a new one.
+type ResumptionTokens struct {
+	mu       sync.Mutex
+	lifetime time.Duration
+	clock    Clock
+	tokens   map[string]resumptionToken
+}
+
+type resumptionToken struct {
+	identity Identity
+	role     DomainRole
+	expires  time.Time
+}
+
+// NewResumptionTokens creates a ResumptionTokens whose tokens expire after the lifetime.
+func NewResumptionTokens(lifetime time.Duration) *ResumptionTokens {
+	if lifetime <= 0 {
+		panic("lifetime must be positive")
+	}
+	return &ResumptionTokens{
+		lifetime: lifetime,
+		clock:    SystemClock,
+		tokens:   make(map[string]resumptionToken),
+	}
+}
+
+// SetClock defines the Clock used for the token expiration.
+func (r *ResumptionTokens) SetClock(clock Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = clockOrSystem(clock)
+}
+
+// Issue creates a token for the authenticated identity, which resumes a session with the same role.
+func (r *ResumptionTokens) Issue(identity Identity, role DomainRole) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("issue resumption token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	for t, rt := range r.tokens {
+		if !now.Before(rt.expires) {
+			delete(r.tokens, t)
+		}
+	}
+	r.tokens[token] = resumptionToken{identity: identity, role: role, expires: now.Add(r.lifetime)}
+	return token, nil
+}
+
+// Resume consumes the token, returning the role of the session if it was issued for the identity and is not
+// expired or revoked.
+func (r *ResumptionTokens) Resume(token string, identity Identity) (DomainRole, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt, ok := r.tokens[token]
+	if !ok {
+		return "", false
+	}
+	delete(r.tokens, token)
+	if rt.identity != identity || !r.clock.Now().Before(rt.expires) {
+		return "", false
+	}
+	return rt.role, true
+}
+
+// Revoke invalidates the token.
+func (r *ResumptionTokens) Revoke(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tokens, token)
+}
+
+// RevokeIdentity invalidates all the tokens of the identity, like after a password change, returning the number of
+// revoked tokens.
+func (r *ResumptionTokens) RevokeIdentity(identity Identity) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for t, rt := range r.tokens {
+		if rt.identity == identity {
+			delete(r.tokens, t)
+			n++
+		}
+	}
+	return n
+}
+
+// EnableResumption makes the channel accept the resumption tokens of the tokens instance, skipping the
+// authentication of the sessions that present a valid one, and issue a new token in the established session.
+func (c *ServerChannel) EnableResumption(tokens *ResumptionTokens) {
+	if err := c.ensureState(SessionStateNew, "enable resumption"); err != nil {
+		panic(err)
+	}
+	c.resumption = tokens
+}
+
+// resume returns the authentication result of the resumption token of the authenticating session, if valid.
+func (c *ServerChannel) resume(ses *Session) (*AuthenticationResult, bool) {
+	if c.resumption == nil {
+		return nil, false
+	}
+	token := ses.Metadata[MetadataKeyResumptionToken]
+	if token == "" {
+		return nil, false
+	}
+	role, ok := c.resumption.Resume(token, ses.From.Identity)
+	if !ok {
+		return nil, false
+	}
+	return &AuthenticationResult{Role: role}, true
+}
+
+// issueResumptionToken creates the token sent in the established session, if the resumption is enabled.
+func (c *ServerChannel) issueResumptionToken(identity Identity, role DomainRole) error {
+	if c.resumption == nil {
+		return nil
+	}
+	token, err := c.resumption.Issue(identity, role)
+	if err != nil {
+		return err
+	}
+	c.resumptionToken = token
+	return nil
+}
+
+// SetResumptionToken defines the token presented to the server during the authentication, which is received in the
+// established session of a previous connection. If the server accepts it, the authenticator credentials are ignored.
+func (c *ClientChannel) SetResumptionToken(token string) {
+	if err := c.ensureState(SessionStateNew, "set resumption token"); err != nil {
+		panic(err)
+	}
+	c.resumptionToken = token
+}
+
+// ResumptionToken returns the token issued by the server in the established session, if any, for resuming the
+// session in a new connection.
+func (c *ClientChannel) ResumptionToken() string {
+	return c.resumptionToken
+}
@@ -0,0 +1,210 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResumptionTokens_Resume(t *testing.T) {
+	// Arrange
+	tokens := NewResumptionTokens(time.Hour)
+	identity := Identity{Name: "golang", Domain: "localhost"}
+	token, _ := tokens.Issue(identity, DomainRoleMember)
+
+	// Act
+	role, ok1 := tokens.Resume(token, identity)
+	_, ok2 := tokens.Resume(token, identity)
+
+	// Assert
+	assert.NotEmpty(t, token)
+	assert.True(t, ok1)
+	assert.Equal(t, DomainRoleMember, role)
+	assert.False(t, ok2)
+}
+
+func TestResumptionTokens_Resume_OtherIdentity(t *testing.T) {
+	// Arrange
+	tokens := NewResumptionTokens(time.Hour)
+	token, _ := tokens.Issue(Identity{Name: "golang", Domain: "localhost"}, DomainRoleMember)
+
+	// Act
+	_, ok := tokens.Resume(token, Identity{Name: "rust", Domain: "localhost"})
+
+	// Assert
+	assert.False(t, ok)
+}
+
+func TestResumptionTokens_Resume_Expired(t *testing.T) {
+	// Arrange
+	tokens := NewResumptionTokens(time.Hour)
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	tokens.SetClock(clock)
+	identity := Identity{Name: "golang", Domain: "localhost"}
+	token, _ := tokens.Issue(identity, DomainRoleMember)
+	clock.now = clock.now.Add(time.Hour)
+
+	// Act
+	_, ok := tokens.Resume(token, identity)
+
+	// Assert
+	assert.False(t, ok)
+}
+
+func TestResumptionTokens_Revoke(t *testing.T) {
+	// Arrange
+	tokens := NewResumptionTokens(time.Hour)
+	golang := Identity{Name: "golang", Domain: "localhost"}
+	rust := Identity{Name: "rust", Domain: "localhost"}
+	token1, _ := tokens.Issue(golang, DomainRoleMember)
+	token2, _ := tokens.Issue(golang, DomainRoleMember)
+	token3, _ := tokens.Issue(rust, DomainRoleMember)
+	token4, _ := tokens.Issue(rust, DomainRoleMember)
+
+	// Act
+	n := tokens.RevokeIdentity(golang)
+	tokens.Revoke(token3)
+
+	// Assert
+	assert.Equal(t, 2, n)
+	_, ok1 := tokens.Resume(token1, golang)
+	_, ok2 := tokens.Resume(token2, golang)
+	_, ok3 := tokens.Resume(token3, rust)
+	_, ok4 := tokens.Resume(token4, rust)
+	assert.False(t, ok1)
+	assert.False(t, ok2)
+	assert.False(t, ok3)
+	assert.True(t, ok4)
+}
+
+func establishPlainSession(t *testing.T, ctx context.Context, addr InProcessAddr, password string, token string) (*ClientChannel, *Session) {
+	client, err := DialInProcess(addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := NewClientChannel(client, 1)
+	if token != "" {
+		channel.SetResumptionToken(token)
+	}
+	ses, err := channel.EstablishSession(
+		ctx,
+		func([]SessionCompression) SessionCompression {
+			return SessionCompressionNone
+		},
+		func([]SessionEncryption) SessionEncryption {
+			return SessionEncryptionNone
+		},
+		Identity{Name: "golang", Domain: "localhost"},
+		func([]AuthenticationScheme, Authentication) Authentication {
+			a := &PlainAuthentication{}
+			a.SetPasswordAsBase64(password)
+			return a
+		},
+		"default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return channel, ses
+}
+
+func TestServer_EnableResumption(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("session-resumption")
+	var authentications atomic.Int32
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			authentications.Add(1)
+			if password != "secret" {
+				return UnknownAuthenticationResult(), nil
+			}
+			return MemberAuthenticationResult(), nil
+		}).
+		EnableResumption(NewResumptionTokens(time.Hour)).
+		Build()
+	startServer(t, srv)
+	defer silentClose(srv)
+	client1, ses1 := establishPlainSession(t, ctx, addr, "secret", "")
+	defer silentClose(client1)
+	token := client1.ResumptionToken()
+
+	// Act
+	client2, ses2 := establishPlainSession(t, ctx, addr, "wrong", token)
+	defer silentClose(client2)
+	client3, ses3 := establishPlainSession(t, ctx, addr, "wrong", token)
+	defer silentClose(client3)
+
+	// Assert
+	assert.Equal(t, SessionStateEstablished, ses1.State)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, SessionStateEstablished, ses2.State)
+	assert.NotEmpty(t, client2.ResumptionToken())
+	assert.NotEqual(t, token, client2.ResumptionToken())
+	assert.Equal(t, SessionStateFailed, ses3.State)
+	assert.Equal(t, int32(2), authentications.Load())
+}
+
+func TestServer_WithoutResumption(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("session-without-resumption")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			return MemberAuthenticationResult(), nil
+		}).
+		Build()
+	startServer(t, srv)
+	defer silentClose(srv)
+
+	// Act
+	client, ses := establishPlainSession(t, ctx, addr, "secret", "")
+	defer silentClose(client)
+
+	// Assert
+	assert.Equal(t, SessionStateEstablished, ses.State)
+	assert.Empty(t, client.ResumptionToken())
+}
+
+func TestClient_ResumeSessions(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("client-resume-sessions")
+	tokens := NewResumptionTokens(time.Hour)
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			return MemberAuthenticationResult(), nil
+		}).
+		EnableResumption(tokens).
+		Build()
+	startServer(t, srv)
+	defer silentClose(srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		PlainAuthentication("secret").
+		ResumeSessions().
+		Build()
+	defer silentClose(client)
+	<-client.Ready()
+	token := client.resumptionToken
+
+	// Act
+	_ = client.channel.Close()
+	err := client.SendMessage(ctx, createMessage())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, client.resumptionToken)
+	assert.NotEqual(t, token, client.resumptionToken)
+	_, ok := tokens.Resume(token, Identity{Name: "golang", Domain: "localhost"})
+	assert.False(t, ok)
+}
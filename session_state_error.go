@@ -0,0 +1,52 @@
+package lime
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrSessionNotEstablished is returned when an action that requires an established session is performed before
+	// the session establishment.
+	ErrSessionNotEstablished = errors.New("session not established")
+	// ErrSessionAlreadyEstablished is returned when the session establishment is requested on a channel which already
+	// has an established session.
+	ErrSessionAlreadyEstablished = errors.New("session already established")
+	// ErrSessionFinished is returned when an action is performed on a channel which session is finishing, finished or
+	// failed.
+	ErrSessionFinished = errors.New("session finished")
+)
+
+// SessionStateError is returned when an action is not allowed in the current state of the channel session.
+// It can be compared with the ErrSessionNotEstablished, ErrSessionAlreadyEstablished and ErrSessionFinished errors
+// through errors.Is.
+type SessionStateError struct {
+	// Action is the name of the rejected action.
+	Action string
+	// State is the channel session state when the action was performed.
+	State SessionState
+	// Expected is the session state required by the action.
+	Expected SessionState
+	err      error
+}
+
+func newSessionStateError(action string, state SessionState, expected SessionState) *SessionStateError {
+	e := &SessionStateError{Action: action, State: state, Expected: expected}
+	switch {
+	case state.Step() > SessionStateEstablished.Step():
+		e.err = ErrSessionFinished
+	case state == SessionStateEstablished:
+		e.err = ErrSessionAlreadyEstablished
+	case expected == SessionStateEstablished:
+		e.err = ErrSessionNotEstablished
+	}
+	return e
+}
+
+func (e *SessionStateError) Error() string {
+	return fmt.Sprintf("%v: cannot do in the %v state", e.Action, e.State)
+}
+
+func (e *SessionStateError) Unwrap() error {
+	return e.err
+}
@@ -592,3 +592,31 @@ func TestSession_UnmarshalJSON_Failed(t *testing.T) {
 	assert.Equal(t, SessionStateFailed, s.State)
 	assert.Equal(t, Reason{13, "The session authentication failed"}, *s.Reason)
 }
+
+type tokenAuthentication struct {
+	Token string `json:"token"`
+}
+
+func (a *tokenAuthentication) GetAuthenticationScheme() AuthenticationScheme {
+	return "x-token"
+}
+
+func TestSession_UnmarshalJSON_AuthenticatingCustom(t *testing.T) {
+	// Arrange
+	RegisterAuthenticationFactory(func() Authentication {
+		return &tokenAuthentication{}
+	})
+	j := []byte(`{"id":"4609d0a3-00eb-4e16-9d44-27d115c6eb31","state":"authenticating","scheme":"x-token","authentication":{"token":"abc"}}`)
+	var s Session
+
+	// Act
+	err := json.Unmarshal(j, &s)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, AuthenticationScheme("x-token"), s.Scheme)
+	assert.Equal(t, &tokenAuthentication{Token: "abc"}, s.Authentication)
+	b, err := json.Marshal(&s)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(j), string(b))
+}
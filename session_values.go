@@ -0,0 +1,89 @@
+package lime
+
+import (
+	"context"
+	"sync"
+)
+
+// SessionValues is a concurrency-safe key/value store attached to a channel, which allows the handlers and modules
+// to share state of the session, like the user profile or locale, without global maps keyed by the session ID.
+// The values are available during the channel lifetime. Like in the context values, the keys should be of an
+// unexported type, to avoid collisions between packages.
+type SessionValues struct {
+	mu     sync.RWMutex
+	values map[interface{}]interface{}
+}
+
+// Load returns the value stored for the key.
+func (v *SessionValues) Load(key interface{}) (interface{}, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	value, ok := v.values[key]
+	return value, ok
+}
+
+// Store sets the value for the key.
+func (v *SessionValues) Store(key interface{}, value interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.values == nil {
+		v.values = make(map[interface{}]interface{})
+	}
+	v.values[key] = value
+}
+
+// LoadOrStore returns the existing value for the key, if present. Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (v *SessionValues) LoadOrStore(key interface{}, value interface{}) (actual interface{}, loaded bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if existing, ok := v.values[key]; ok {
+		return existing, true
+	}
+	if v.values == nil {
+		v.values = make(map[interface{}]interface{})
+	}
+	v.values[key] = value
+	return value, false
+}
+
+// Delete removes the value for the key.
+func (v *SessionValues) Delete(key interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.values, key)
+}
+
+// Range calls f for each key and value, stopping if f returns false. The store should not be changed by f.
+func (v *SessionValues) Range(f func(key interface{}, value interface{}) bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for key, value := range v.values {
+		if !f(key, value) {
+			return
+		}
+	}
+}
+
+// SessionValue returns the value stored for the key, if it is of the type T.
+func SessionValue[T any](v *SessionValues, key interface{}) (T, bool) {
+	value, ok := v.Load(key)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	t, ok := value.(T)
+	return t, ok
+}
+
+// Values returns the key/value store of the session.
+func (c *channel) Values() *SessionValues {
+	return &c.values
+}
+
+// ContextSessionValues gets the key/value store of the session from the context.
+// It is defined when handling the envelopes received by a channel.
+func ContextSessionValues(ctx context.Context) (*SessionValues, bool) {
+	values, ok := ctx.Value(contextKeySessionValues).(*SessionValues)
+	return values, ok
+}
@@ -0,0 +1,153 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testValueKey string
+
+func TestSessionValues_StoreLoad(t *testing.T) {
+	// Arrange
+	var values SessionValues
+	key := testValueKey("locale")
+
+	// Act
+	values.Store(key, "pt-BR")
+
+	// Assert
+	v, ok := values.Load(key)
+	assert.True(t, ok)
+	assert.Equal(t, "pt-BR", v)
+	_, ok = values.Load(testValueKey("profile"))
+	assert.False(t, ok)
+}
+
+func TestSessionValues_LoadOrStore(t *testing.T) {
+	// Arrange
+	var values SessionValues
+	key := testValueKey("locale")
+
+	// Act
+	actual1, loaded1 := values.LoadOrStore(key, "pt-BR")
+	actual2, loaded2 := values.LoadOrStore(key, "en-US")
+
+	// Assert
+	assert.False(t, loaded1)
+	assert.Equal(t, "pt-BR", actual1)
+	assert.True(t, loaded2)
+	assert.Equal(t, "pt-BR", actual2)
+}
+
+func TestSessionValues_Delete(t *testing.T) {
+	// Arrange
+	var values SessionValues
+	key := testValueKey("locale")
+	values.Store(key, "pt-BR")
+
+	// Act
+	values.Delete(key)
+
+	// Assert
+	_, ok := values.Load(key)
+	assert.False(t, ok)
+}
+
+func TestSessionValues_Range(t *testing.T) {
+	// Arrange
+	var values SessionValues
+	values.Store(testValueKey("a"), 1)
+	values.Store(testValueKey("b"), 2)
+	values.Store(testValueKey("c"), 3)
+	sum := 0
+
+	// Act
+	values.Range(func(key interface{}, value interface{}) bool {
+		sum += value.(int)
+		return true
+	})
+
+	// Assert
+	assert.Equal(t, 6, sum)
+}
+
+func TestSessionValues_Concurrent(t *testing.T) {
+	// Arrange
+	var values SessionValues
+	var wg sync.WaitGroup
+
+	// Act
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values.Store(i, i)
+			_, _ = values.Load(i)
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert
+	count := 0
+	values.Range(func(key interface{}, value interface{}) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 16, count)
+}
+
+func TestSessionValue_Typed(t *testing.T) {
+	// Arrange
+	var values SessionValues
+	values.Store(testValueKey("locale"), "pt-BR")
+
+	// Act
+	locale, ok1 := SessionValue[string](&values, testValueKey("locale"))
+	_, ok2 := SessionValue[int](&values, testValueKey("locale"))
+
+	// Assert
+	assert.True(t, ok1)
+	assert.Equal(t, "pt-BR", locale)
+	assert.False(t, ok2)
+}
+
+func TestServer_SessionValues(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("session-values")
+	locales := make(chan string, 1)
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		Established(func(sessionID string, c *ServerChannel) {
+			c.Values().Store(testValueKey("locale"), "pt-BR")
+		}).
+		MessagesHandlerFunc(func(ctx context.Context, msg *Message, s Sender) error {
+			values, _ := ContextSessionValues(ctx)
+			locale, _ := SessionValue[string](values, testValueKey("locale"))
+			locales <- locale
+			return nil
+		}).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	client := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(client)
+
+	// Act
+	err := client.SendMessage(ctx, createMessage())
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case locale := <-locales:
+		assert.Equal(t, "pt-BR", locale)
+	}
+}
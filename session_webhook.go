@@ -0,0 +1,78 @@
+package lime
+
+import (
+	"log"
+	"time"
+)
+
+// SessionEvent is the payload posted by a SessionWebhook.
+type SessionEvent struct {
+	// Type is the event type, which can be AuditEventSessionEstablished, AuditEventSessionFailed or
+	// AuditEventSessionFinished.
+	Type       AuditEventType `json:"type"`
+	Timestamp  time.Time      `json:"timestamp"`            // Timestamp is the moment when the event occurred.
+	SessionID  string         `json:"sessionId"`            // SessionID is the id of the session.
+	Identity   Identity       `json:"identity,omitempty"`   // Identity is the client identity, if known.
+	Instance   string         `json:"instance,omitempty"`   // Instance is the client node instance, if established.
+	RemoteAddr string         `json:"remoteAddr,omitempty"` // RemoteAddr is the client transport address.
+	Reason     string         `json:"reason,omitempty"`     // Reason describes the cause of a failed establishment.
+}
+
+// ID returns the id of the event, which is sent in the WebhookEnvelopeIDHeader.
+func (e *SessionEvent) ID() string {
+	return e.SessionID + ":" + string(e.Type)
+}
+
+// SessionWebhook posts the session events of a server to a webhook, so the external systems, like billing services
+// and presence caches, can react to them without code embedded in the server.
+// The events are delivered asynchronously, with the retries and signatures defined in the WebhookConfig. The server
+// sessions never wait for the webhook, so the events are discarded while the MaxConcurrency deliveries are in
+// progress, like when the endpoint is not responding.
+type SessionWebhook struct {
+	bridge *WebhookBridge
+	events map[AuditEventType]bool
+}
+
+// NewSessionWebhook creates a SessionWebhook that posts the specified event types. If no type is specified, the
+// established, failed and finished session events are posted.
+func NewSessionWebhook(config *WebhookConfig, events ...AuditEventType) *SessionWebhook {
+	if len(events) == 0 {
+		events = []AuditEventType{AuditEventSessionEstablished, AuditEventSessionFailed, AuditEventSessionFinished}
+	}
+	w := &SessionWebhook{bridge: NewWebhookBridge(config, nil), events: make(map[AuditEventType]bool, len(events))}
+	for _, t := range events {
+		switch t {
+		case AuditEventSessionEstablished, AuditEventSessionFailed, AuditEventSessionFinished:
+			w.events[t] = true
+		default:
+			panic("unsupported session event type: " + string(t))
+		}
+	}
+	return w
+}
+
+// Close cancels the pending retries and waits for the in-flight deliveries. It should be called after the server
+// is closed.
+func (w *SessionWebhook) Close() error {
+	return w.bridge.Close()
+}
+
+// notify posts the session event, if its type was requested.
+func (w *SessionWebhook) notify(event AuditEvent) {
+	if !w.events[event.Type] {
+		return
+	}
+	e := &SessionEvent{
+		Type:       event.Type,
+		Timestamp:  event.Timestamp,
+		SessionID:  event.SessionID,
+		Identity:   event.Identity,
+		Instance:   event.Node.Instance,
+		RemoteAddr: event.RemoteAddr,
+		Reason:     event.Reason,
+	}
+	// The session should not be affected by the webhook failures or delays
+	if err := w.bridge.tryDeliver(e.ID(), e); err != nil {
+		log.Printf("session webhook: event '%v' discarded: %v", e.ID(), err)
+	}
+}
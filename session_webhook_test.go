@@ -0,0 +1,180 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func startSessionWebhookEndpoint(t *testing.T) (*httptest.Server, chan *SessionEvent) {
+	events := make(chan *SessionEvent, 8)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var e SessionEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Error(err)
+		}
+		assert.Equal(t, e.ID(), r.Header.Get(WebhookEnvelopeIDHeader))
+		events <- &e
+	}))
+	return endpoint, events
+}
+
+func receiveSessionEvent(t *testing.T, events chan *SessionEvent) *SessionEvent {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("session event timeout")
+		return nil
+	}
+}
+
+func TestServer_SessionWebhook(t *testing.T) {
+	// Arrange
+	endpoint, events := startSessionWebhookEndpoint(t)
+	defer endpoint.Close()
+	webhook := NewSessionWebhook(NewWebhookConfig(endpoint.URL))
+	defer silentClose(webhook)
+	addr := InProcessAddr("server-session-webhook")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			return MemberAuthenticationResult(), nil
+		}).
+		SessionWebhook(webhook).
+		Build()
+	defer silentClose(srv)
+	startServer(t, srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		Instance("mobile").
+		PlainAuthentication("secret").
+		Build()
+
+	// Act
+	err := <-client.Ready()
+	established := receiveSessionEvent(t, events)
+	_ = client.Close()
+	finished := receiveSessionEvent(t, events)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, AuditEventSessionEstablished, established.Type)
+	assert.Equal(t, "golang", established.Identity.Name)
+	assert.NotEmpty(t, established.Instance)
+	assert.NotEmpty(t, established.SessionID)
+	assert.Empty(t, established.Reason)
+	assert.Equal(t, AuditEventSessionFinished, finished.Type)
+	assert.Equal(t, established.SessionID, finished.SessionID)
+}
+
+func TestServer_SessionWebhook_Failed(t *testing.T) {
+	// Arrange
+	endpoint, events := startSessionWebhookEndpoint(t)
+	defer endpoint.Close()
+	webhook := NewSessionWebhook(NewWebhookConfig(endpoint.URL), AuditEventSessionFailed)
+	defer silentClose(webhook)
+	addr := InProcessAddr("server-session-webhook-failed")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			return UnknownAuthenticationResult(), nil
+		}).
+		SessionWebhook(webhook).
+		Build()
+	defer silentClose(srv)
+	startServer(t, srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		Name("golang").
+		PlainAuthentication("wrong").
+		Warmup().
+		Build()
+	defer silentClose(client)
+
+	// Act
+	err := <-client.Ready()
+	failed := receiveSessionEvent(t, events)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, AuditEventSessionFailed, failed.Type)
+	assert.NotEmpty(t, failed.SessionID)
+	assert.NotEmpty(t, failed.Reason)
+}
+
+func TestServer_SessionWebhook_HungEndpoint(t *testing.T) {
+	// Arrange
+	release := make(chan struct{})
+	requests := make(chan string, 8)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Header.Get(WebhookEnvelopeIDHeader)
+		<-release
+	}))
+	defer endpoint.Close()
+	config := NewWebhookConfig(endpoint.URL)
+	config.MaxConcurrency = 1
+	webhook := NewSessionWebhook(config)
+	defer silentClose(webhook)
+	// The hung delivery is released before the webhook is closed, which waits for it
+	defer close(release)
+	addr := InProcessAddr("server-session-webhook-hung")
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			return MemberAuthenticationResult(), nil
+		}).
+		SessionWebhook(webhook).
+		Build()
+	defer silentClose(srv)
+	startServer(t, srv)
+	newClient := func() *Client {
+		return NewClientBuilder().
+			UseInProcess(addr, 1).
+			Name("golang").
+			PlainAuthentication("secret").
+			Build()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Act
+	first := newClient()
+	firstErr := <-first.Ready()
+	select {
+	case <-requests:
+	case <-ctx.Done():
+		t.Fatal("session event timeout")
+	}
+	_ = first.Close()
+	second := newClient()
+	defer silentClose(second)
+	var secondErr error
+	select {
+	case secondErr = <-second.Ready():
+	case <-ctx.Done():
+		t.Fatal("the session was blocked by the webhook")
+	}
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.Empty(t, requests)
+}
+
+func TestNewSessionWebhook_UnsupportedEvent(t *testing.T) {
+	// Act
+	f := func() {
+		NewSessionWebhook(NewWebhookConfig("http://localhost"), AuditEventAuthenticationFailed)
+	}
+
+	// Assert
+	assert.Panics(t, f)
+}
@@ -0,0 +1,423 @@
+package lime
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The environment variables that override the values of the ServerSettings file.
+// The list values are separated by commas.
+const (
+	EnvServerListeners             = "LIME_LISTENERS"
+	EnvServerChannelBufferSize     = "LIME_CHANNEL_BUFFER_SIZE"
+	EnvServerCompressionOptions    = "LIME_COMPRESSION_OPTIONS"
+	EnvServerEncryptionOptions     = "LIME_ENCRYPTION_OPTIONS"
+	EnvServerRequireAuthentication = "LIME_REQUIRE_AUTHENTICATION"
+	EnvServerReadLimit             = "LIME_READ_LIMIT"
+	EnvServerWriteLimit            = "LIME_WRITE_LIMIT"
+	EnvServerReadRateLimit         = "LIME_READ_RATE_LIMIT"
+	EnvServerWriteRateLimit        = "LIME_WRITE_RATE_LIMIT"
+	EnvServerTLSCertFile           = "LIME_TLS_CERT_FILE"
+	EnvServerTLSKeyFile            = "LIME_TLS_KEY_FILE"
+)
+
+// ServerSettings defines the Server settings that can be loaded from a JSON file and environment variables.
+// The listeners are only applied when the server is created, while the other values can be changed in a running
+// server through the Server.ApplySettings method, affecting the connections and sessions started after the change.
+type ServerSettings struct {
+	// Listeners are the URIs of the listeners, like 'net.tcp://:55321', 'ws://:8080' or 'wss://:8443'.
+	Listeners []string `json:"listeners,omitempty"`
+	// ChannelBufferSize determines the internal envelope buffer size for the channels.
+	ChannelBufferSize int `json:"channelBufferSize,omitempty"`
+	// CompressionOptions defines the compression options to be used in the session negotiation.
+	CompressionOptions []SessionCompression `json:"compressionOptions,omitempty"`
+	// EncryptionOptions defines the encryption options to be used in the session negotiation.
+	EncryptionOptions []SessionEncryption `json:"encryptionOptions,omitempty"`
+	// RequireAuthentication refuses the guest authentication scheme.
+	RequireAuthentication bool `json:"requireAuthentication,omitempty"`
+	// ReadLimit defines the limit for buffered data in the TCP read operations and the maximum size of the messages
+	// received by the websocket connections.
+	ReadLimit int64 `json:"readLimit,omitempty"`
+	// WriteLimit defines the maximum encoded size of the envelopes sent by the TCP connections. The websocket
+	// connections are not limited, like the ones of the rate limits below.
+	WriteLimit int64 `json:"writeLimit,omitempty"`
+	// ReadRateLimit defines the maximum rate, in bytes per second, for reading from the TCP connections.
+	ReadRateLimit int64 `json:"readRateLimit,omitempty"`
+	// WriteRateLimit defines the maximum rate, in bytes per second, for writing to the TCP connections.
+	WriteRateLimit int64 `json:"writeRateLimit,omitempty"`
+	// Filters are the policy expressions that the envelopes received by the sessions must match, like
+	// 'from.domain == "msging.net"'. See CompilePolicy. The expressions can contain commas, so they are only loaded
+	// from the file.
+	Filters []string `json:"filters,omitempty"`
+	// TLS defines the certificate for the TLS encryption of the connections.
+	TLS *TLSSettings `json:"tls,omitempty"`
+}
+
+// TLSSettings defines the files of the server certificate, which are read again when the settings are reloaded.
+type TLSSettings struct {
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+// LoadServerSettings reads the settings from the JSON file in the specified path, if not empty, and overrides its
+// values with the defined environment variables.
+func LoadServerSettings(path string) (*ServerSettings, error) {
+	return loadServerSettings(path, os.LookupEnv)
+}
+
+func loadServerSettings(path string, lookupEnv func(string) (string, bool)) (*ServerSettings, error) {
+	s := &ServerSettings{}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("load server settings: %w", err)
+		}
+		if err := json.Unmarshal(b, s); err != nil {
+			return nil, fmt.Errorf("load server settings: %w", err)
+		}
+	}
+	if err := s.applyEnv(lookupEnv); err != nil {
+		return nil, fmt.Errorf("load server settings: %w", err)
+	}
+	return s, nil
+}
+
+func (s *ServerSettings) applyEnv(lookupEnv func(string) (string, bool)) error {
+	if v, ok := lookupEnv(EnvServerListeners); ok {
+		s.Listeners = splitList(v)
+	}
+	if v, ok := lookupEnv(EnvServerCompressionOptions); ok {
+		s.CompressionOptions = nil
+		for _, o := range splitList(v) {
+			s.CompressionOptions = append(s.CompressionOptions, SessionCompression(o))
+		}
+	}
+	if v, ok := lookupEnv(EnvServerEncryptionOptions); ok {
+		s.EncryptionOptions = nil
+		for _, o := range splitList(v) {
+			s.EncryptionOptions = append(s.EncryptionOptions, SessionEncryption(o))
+		}
+	}
+	if v, ok := lookupEnv(EnvServerRequireAuthentication); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%v: %w", EnvServerRequireAuthentication, err)
+		}
+		s.RequireAuthentication = b
+	}
+	if v, ok := lookupEnv(EnvServerChannelBufferSize); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%v: %w", EnvServerChannelBufferSize, err)
+		}
+		s.ChannelBufferSize = n
+	}
+	for name, field := range map[string]*int64{
+		EnvServerReadLimit:      &s.ReadLimit,
+		EnvServerWriteLimit:     &s.WriteLimit,
+		EnvServerReadRateLimit:  &s.ReadRateLimit,
+		EnvServerWriteRateLimit: &s.WriteRateLimit,
+	} {
+		if v, ok := lookupEnv(name); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%v: %w", name, err)
+			}
+			*field = n
+		}
+	}
+	certFile, okCert := lookupEnv(EnvServerTLSCertFile)
+	keyFile, okKey := lookupEnv(EnvServerTLSKeyFile)
+	if okCert || okKey {
+		if s.TLS == nil {
+			s.TLS = &TLSSettings{}
+		}
+		if okCert {
+			s.TLS.CertFile = certFile
+		}
+		if okKey {
+			s.TLS.KeyFile = keyFile
+		}
+	}
+	return nil
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, i := range strings.Split(v, ",") {
+		if i = strings.TrimSpace(i); i != "" {
+			items = append(items, i)
+		}
+	}
+	return items
+}
+
+// Builder creates a ServerBuilder with the listeners and options of the settings, which can be used for adding the
+// handlers before building the server.
+// The servers created by the builder support the certificate reload through the Server.ApplySettings method.
+func (s *ServerSettings) Builder() (*ServerBuilder, error) {
+	if len(s.Listeners) == 0 {
+		return nil, errors.New("server settings: no listeners")
+	}
+	filters, err := s.compileFilters()
+	if err != nil {
+		return nil, fmt.Errorf("server settings: %w", err)
+	}
+	state := &serverSettingsState{current: *s}
+
+	var tlsConfig *tls.Config
+	if s.TLS != nil {
+		certs, err := NewCertificateReloader(s.TLS.CertFile, s.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("server settings: %w", err)
+		}
+		state.certs = certs
+		tlsConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+
+	b := NewServerBuilder()
+	for _, l := range s.Listeners {
+		u, err := url.Parse(l)
+		if err != nil {
+			return nil, fmt.Errorf("server settings: invalid listener '%v': %w", l, err)
+		}
+		addr, err := net.ResolveTCPAddr("tcp", u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("server settings: invalid listener '%v': %w", l, err)
+		}
+		switch u.Scheme {
+		case "net.tcp":
+			b.ListenTCP(addr, &TCPConfig{
+				TLSConfig:      tlsConfig,
+				ReadLimit:      s.ReadLimit,
+				WriteLimit:     s.WriteLimit,
+				ReadRateLimit:  s.ReadRateLimit,
+				WriteRateLimit: s.WriteRateLimit,
+			})
+		case "ws":
+			b.ListenWebsocket(addr, &WebsocketConfig{ReadLimit: s.ReadLimit})
+		case "wss":
+			if tlsConfig == nil {
+				return nil, fmt.Errorf("server settings: the listener '%v' requires the tls settings", l)
+			}
+			b.ListenWebsocket(addr, &WebsocketConfig{TLSConfig: tlsConfig, ReadLimit: s.ReadLimit})
+		default:
+			return nil, fmt.Errorf("server settings: unsupported listener scheme '%v'", u.Scheme)
+		}
+	}
+
+	s.applyConfig(b.config, filters)
+	b.settings = state
+	return b, nil
+}
+
+// compileFilters compiles the filter expressions of the settings.
+func (s *ServerSettings) compileFilters() ([]*Policy, error) {
+	var filters []*Policy
+	for _, expr := range s.Filters {
+		p, err := CompilePolicy(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter '%v': %w", expr, err)
+		}
+		filters = append(filters, p)
+	}
+	return filters, nil
+}
+
+// applyConfig sets the reloadable values to the server configuration.
+func (s *ServerSettings) applyConfig(config *ServerConfig, filters []*Policy) {
+	if s.ChannelBufferSize > 0 {
+		config.ChannelBufferSize = s.ChannelBufferSize
+	}
+	if len(s.CompressionOptions) > 0 {
+		config.CompOpts = s.CompressionOptions
+	}
+	if len(s.EncryptionOptions) > 0 {
+		config.EncryptOpts = s.EncryptionOptions
+	}
+	config.RequireAuthentication = s.RequireAuthentication
+	config.Filters = filters
+}
+
+// serverSettingsState holds the settings applied to a server.
+type serverSettingsState struct {
+	mu      sync.Mutex
+	current ServerSettings
+	certs   *CertificateReloader
+}
+
+// ApplySettings changes the configuration of the running server, affecting the connections and sessions started
+// after the call. It returns the names of the settings that were changed but require a restart to be applied, like
+// the listeners.
+// If the certificate files cannot be loaded or a filter expression is invalid, no setting is changed.
+func (srv *Server) ApplySettings(s *ServerSettings) ([]string, error) {
+	if s == nil {
+		panic("nil settings")
+	}
+	state := srv.settings
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	filters, err := s.compileFilters()
+	if err != nil {
+		return nil, fmt.Errorf("apply settings: %w", err)
+	}
+	var restart []string
+	if state.certs != nil && s.TLS != nil {
+		if err := state.certs.Update(s.TLS.CertFile, s.TLS.KeyFile); err != nil {
+			return nil, fmt.Errorf("apply settings: %w", err)
+		}
+	} else if (s.TLS == nil) != (state.current.TLS == nil) {
+		restart = append(restart, "tls")
+	}
+	if !slices.Equal(s.Listeners, state.current.Listeners) {
+		restart = append(restart, "listeners")
+	}
+
+	srv.configMu.Lock()
+	s.applyConfig(srv.config, filters)
+	srv.configMu.Unlock()
+
+	for _, l := range srv.boundListeners() {
+		switch tl := l.Listener.(type) {
+		case *tcpTransportListener:
+			tl.setLimits(s.ReadLimit, s.WriteLimit, s.ReadRateLimit, s.WriteRateLimit)
+		case *websocketTransportListener:
+			tl.setReadLimit(s.ReadLimit)
+		}
+	}
+
+	state.current = *s
+	return restart, nil
+}
+
+// WatchSettings reloads the settings from the file in the specified path and applies them to the server when the
+// process receives a SIGHUP signal or, if the interval is positive, when the file modification time changes.
+// The reload failures are logged and the previous settings are kept.
+// It blocks until the context is done.
+func (srv *Server) WatchSettings(ctx context.Context, path string, interval time.Duration) error {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	// The file is polled with the timers of the server clock, which has no tickers
+	clock := clockOrSystem(srv.currentConfig().Clock)
+	modTime := fileModTime(path)
+
+	for {
+		var timer Timer
+		var tick <-chan time.Time
+		if interval > 0 {
+			timer = clock.NewTimer(interval)
+			tick = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-sighup:
+			if timer != nil {
+				timer.Stop()
+			}
+			modTime = fileModTime(path)
+		case <-tick:
+			t := fileModTime(path)
+			if t.Equal(modTime) {
+				continue
+			}
+			modTime = t
+		}
+		srv.reloadSettings(path)
+	}
+}
+
+func (srv *Server) reloadSettings(path string) {
+	s, err := LoadServerSettings(path)
+	if err != nil {
+		log.Printf("server: reload settings: %v\n", err)
+		return
+	}
+	restart, err := srv.ApplySettings(s)
+	if err != nil {
+		log.Printf("server: reload settings: %v\n", err)
+		return
+	}
+	if len(restart) > 0 {
+		log.Printf("server: reload settings: the changes in %v require a restart\n", strings.Join(restart, ", "))
+	}
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// CertificateReloader provides the server certificate for the TLS handshakes from files that can be read again,
+// allowing the certificate renewal without restarting the listeners.
+type CertificateReloader struct {
+	mu       sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+}
+
+// NewCertificateReloader creates a CertificateReloader, loading the certificate from the specified files.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{}
+	if err := r.Update(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate files again. In case of failure, the current certificate is kept.
+func (r *CertificateReloader) Reload() error {
+	r.mu.RLock()
+	certFile, keyFile := r.certFile, r.keyFile
+	r.mu.RUnlock()
+	return r.Update(certFile, keyFile)
+}
+
+// Update loads the certificate from the specified files, which are used in the next reloads. In case of failure,
+// the current certificate and files are kept.
+func (r *CertificateReloader) Update(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certFile = certFile
+	r.keyFile = keyFile
+	r.cert = &cert
+	return nil
+}
+
+// GetCertificate returns the current certificate. It can be used as the tls.Config GetCertificate function.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
@@ -0,0 +1,274 @@
+package lime
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeServerSettings(t *testing.T, path string, content string) {
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeCertificateFiles(t *testing.T, dir string) (string, string) {
+	cert, err := createCertificate("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestLoadServerSettings(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "lime.json")
+	writeServerSettings(t, path, `{"listeners":["net.tcp://:55321"],"channelBufferSize":16,"readRateLimit":1024}`)
+	env := map[string]string{
+		EnvServerListeners:             "net.tcp://:55321, ws://:8080",
+		EnvServerRequireAuthentication: "true",
+		EnvServerWriteRateLimit:        "2048",
+	}
+
+	// Act
+	s, err := loadServerSettings(path, func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"net.tcp://:55321", "ws://:8080"}, s.Listeners)
+	assert.Equal(t, 16, s.ChannelBufferSize)
+	assert.Equal(t, int64(1024), s.ReadRateLimit)
+	assert.Equal(t, int64(2048), s.WriteRateLimit)
+	assert.True(t, s.RequireAuthentication)
+}
+
+func TestLoadServerSettings_InvalidEnv(t *testing.T) {
+	// Act
+	_, err := loadServerSettings("", func(name string) (string, bool) {
+		return "many", name == EnvServerChannelBufferSize
+	})
+
+	// Assert
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), EnvServerChannelBufferSize)
+	}
+}
+
+func TestServer_ApplySettings(t *testing.T) {
+	// Arrange
+	s := &ServerSettings{
+		Listeners:         []string{"net.tcp://127.0.0.1:55321"},
+		ChannelBufferSize: 8,
+		ReadLimit:         1024,
+	}
+	b, err := s.Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := b.Build()
+	reloaded := *s
+	reloaded.Listeners = []string{"net.tcp://127.0.0.1:55322"}
+	reloaded.ChannelBufferSize = 32
+	reloaded.ReadRateLimit = 4096
+	reloaded.WriteLimit = 2048
+	reloaded.RequireAuthentication = true
+
+	// Act
+	restart, err := srv.ApplySettings(&reloaded)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"listeners"}, restart)
+	config := srv.currentConfig()
+	assert.Equal(t, 32, config.ChannelBufferSize)
+	assert.True(t, config.RequireAuthentication)
+	listener := srv.listeners[0].Listener.(*tcpTransportListener)
+	assert.Equal(t, int64(4096), listener.ReadRateLimit)
+	assert.Equal(t, int64(1024), listener.ReadLimit)
+	assert.Equal(t, int64(2048), listener.WriteLimit)
+}
+
+func TestServer_ApplySettings_Filters(t *testing.T) {
+	// Arrange
+	s := &ServerSettings{
+		Listeners: []string{"net.tcp://127.0.0.1:55321"},
+		Filters:   []string{`from.domain == "msging.net"`},
+	}
+	b, err := s.Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := b.Build()
+	reloaded := *s
+	reloaded.Filters = []string{`type == "message"`, `from.domain != "limeprotocol.org"`}
+
+	// Act
+	_, err = srv.ApplySettings(&reloaded)
+
+	// Assert
+	assert.NoError(t, err)
+	filters := srv.currentConfig().Filters
+	if assert.Len(t, filters, 2) {
+		assert.Equal(t, `type == "message"`, filters[0].String())
+	}
+}
+
+func TestServer_ApplySettings_InvalidFilter(t *testing.T) {
+	// Arrange
+	s := &ServerSettings{
+		Listeners:         []string{"net.tcp://127.0.0.1:55321"},
+		ChannelBufferSize: 8,
+		Filters:           []string{`from.domain == "msging.net"`},
+	}
+	b, err := s.Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := b.Build()
+	reloaded := *s
+	reloaded.ChannelBufferSize = 32
+	reloaded.Filters = []string{`from.domain ==`}
+
+	// Act
+	restart, err := srv.ApplySettings(&reloaded)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, restart)
+	config := srv.currentConfig()
+	assert.Equal(t, 8, config.ChannelBufferSize)
+	assert.Len(t, config.Filters, 1)
+}
+
+func TestServer_ApplySettings_WebsocketReadLimit(t *testing.T) {
+	// Arrange
+	s := &ServerSettings{
+		Listeners: []string{"ws://127.0.0.1:8080"},
+		ReadLimit: 1024,
+	}
+	b, err := s.Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := b.Build()
+	listener := srv.listeners[0].Listener.(*websocketTransportListener)
+	built := listener.ReadLimit
+	reloaded := *s
+	reloaded.ReadLimit = 4096
+
+	// Act
+	_, err = srv.ApplySettings(&reloaded)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), built)
+	assert.Equal(t, int64(4096), listener.ReadLimit)
+}
+
+func TestServerSettings_Builder_InvalidListener(t *testing.T) {
+	// Arrange
+	s := &ServerSettings{Listeners: []string{"wss://127.0.0.1:8443"}}
+
+	// Act
+	b, err := s.Builder()
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, b)
+}
+
+func TestCertificateReloader_Reload(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificateFiles(t, dir)
+	r, err := NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := r.GetCertificate(nil)
+	writeCertificateFiles(t, dir)
+
+	// Act
+	err = r.Reload()
+
+	// Assert
+	assert.NoError(t, err)
+	second, _ := r.GetCertificate(nil)
+	assert.NotEqual(t, first.Certificate, second.Certificate)
+}
+
+func TestCertificateReloader_Reload_InvalidFile(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificateFiles(t, dir)
+	r, err := NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := r.GetCertificate(nil)
+	writeServerSettings(t, certFile, "invalid")
+
+	// Act
+	err = r.Reload()
+
+	// Assert
+	assert.Error(t, err)
+	current, _ := r.GetCertificate(nil)
+	assert.Same(t, first, current)
+}
+
+func TestServer_WatchSettings(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "lime.json")
+	writeServerSettings(t, path, `{"listeners":["net.tcp://127.0.0.1:55321"],"channelBufferSize":8}`)
+	s, err := LoadServerSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := b.Build()
+	done := make(chan error)
+	go func() {
+		done <- srv.WatchSettings(ctx, path, 5*time.Millisecond)
+	}()
+
+	// Act
+	time.Sleep(10 * time.Millisecond)
+	writeServerSettings(t, path, `{"listeners":["net.tcp://127.0.0.1:55321"],"channelBufferSize":64}`)
+	// Ensures that the modification time changes in file systems with low resolution
+	future := time.Now().Add(time.Second)
+	_ = os.Chtimes(path, future, future)
+
+	// Assert
+	assert.Eventually(t, func() bool {
+		return srv.currentConfig().ChannelBufferSize == 64
+	}, 200*time.Millisecond, 5*time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,496 @@
+package lime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrReadLimitExceeded is returned when a received value is larger than the transport read limit.
+	ErrReadLimitExceeded = errors.New("read limit exceeded")
+	// ErrWriteLimitExceeded is returned when an envelope to be sent is larger than the transport write limit.
+	ErrWriteLimitExceeded = errors.New("write limit exceeded")
+)
+
+// StreamedDocument is a received message content or command resource that was written to a temporary file by the
+// transport, instead of being buffered in memory, since it was larger than the configured stream threshold.
+// The file holds the JSON encoded document and is owned by the handler of the envelope, which should call Remove
+// after handling it. The files of the envelopes that are not handled, like the ones without a matching handler or
+// refused by the listener, are removed by the EnvelopeMux.
+type StreamedDocument struct {
+	Type MediaType // Type is the media type of the document, as declared by the envelope.
+	Path string    // Path is the location of the temporary file.
+	Size int64     // Size is the length of the file, in bytes.
+}
+
+func (d *StreamedDocument) MediaType() MediaType {
+	return d.Type
+}
+
+// Open returns a reader for the JSON encoded document.
+func (d *StreamedDocument) Open() (io.ReadCloser, error) {
+	return os.Open(d.Path)
+}
+
+// Decode unmarshals the JSON encoded document into v, without reading the whole file in memory first.
+func (d *StreamedDocument) Decode(v any) error {
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(bufio.NewReader(f)).Decode(v)
+}
+
+// Document unmarshals the file to a document of the registered type for the media type.
+func (d *StreamedDocument) Document() (Document, error) {
+	factory, err := GetDocumentFactory(d.Type)
+	if err != nil {
+		return nil, err
+	}
+	document := factory()
+	if err = d.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// Remove deletes the temporary file.
+func (d *StreamedDocument) Remove() error {
+	return os.Remove(d.Path)
+}
+
+// Clone copies the file to a new temporary file in the same directory, so the copy can be removed independently of
+// the original document.
+func (d *StreamedDocument) Clone() (*StreamedDocument, error) {
+	src, err := os.Open(d.Path)
+	if err != nil {
+		return nil, fmt.Errorf("streamed document: %w", err)
+	}
+	defer src.Close()
+	dst, err := os.CreateTemp(filepath.Dir(d.Path), "lime-document-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("streamed document: %w", err)
+	}
+	size, err := io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst.Name())
+		return nil, fmt.Errorf("streamed document: %w", err)
+	}
+	return &StreamedDocument{Type: d.Type, Path: dst.Name(), Size: size}, nil
+}
+
+// MarshalJSON reads the file contents, allowing the envelope to be forwarded. Note that the document is buffered in
+// memory while sending.
+func (d *StreamedDocument) MarshalJSON() ([]byte, error) {
+	return os.ReadFile(d.Path)
+}
+
+// discardStreamed removes the file of the document of an envelope that was not handled, if it is streamed.
+func discardStreamed(d Document) {
+	if doc, ok := d.(*StreamedDocument); ok && doc != nil {
+		_ = doc.Remove()
+	}
+}
+
+// unmarshalRawDocument returns the streamed document of the raw envelope, if any, or unmarshals the document.
+func unmarshalRawDocument(raw *rawEnvelope, d *json.RawMessage, t MediaType) (Document, error) {
+	if raw.streamed != nil {
+		raw.streamed.Type = t
+		return raw.streamed, nil
+	}
+	return UnmarshalDocument(d, t)
+}
+
+// streamedPlaceholder replaces the streamed documents in the decoded JSON.
+var streamedPlaceholder = []byte("{}")
+
+// streamingDecoder decodes JSON envelopes, writing the message contents and command resources larger than the
+// threshold to temporary files. The read limit applies only to the data buffered in memory.
+type streamingDecoder struct {
+	r         *bufio.Reader
+	readLimit int64
+	threshold int64
+	limit     int64
+	dir       string
+	buf       []byte                    // buf is the in-memory JSON of the current value
+	streamed  map[int]*StreamedDocument // streamed are the documents of the current value, by envelope index
+}
+
+func newStreamingDecoder(r io.Reader, readLimit, threshold, limit int64, dir string) *streamingDecoder {
+	return &streamingDecoder{
+		r:         bufio.NewReader(r),
+		readLimit: readLimit,
+		threshold: threshold,
+		limit:     limit,
+		dir:       dir,
+	}
+}
+
+func (d *streamingDecoder) Decode(v any) error {
+	d.buf = d.buf[:0]
+	d.streamed = nil
+	if err := d.scanValue(); err != nil {
+		d.removeStreamed()
+		return err
+	}
+	if err := json.Unmarshal(d.buf, v); err != nil {
+		d.removeStreamed()
+		return err
+	}
+
+	batch, ok := v.(*rawEnvelopeBatch)
+	if !ok {
+		d.removeStreamed()
+		return nil
+	}
+	for i, doc := range d.streamed {
+		if i < len(*batch) {
+			(*batch)[i].streamed = doc
+		} else {
+			_ = doc.Remove()
+		}
+	}
+	return nil
+}
+
+// Buffered returns the data read ahead by the decoder, for switching the codec.
+func (d *streamingDecoder) Buffered() io.Reader {
+	b, _ := d.r.Peek(d.r.Buffered())
+	return bytes.NewReader(b)
+}
+
+func (d *streamingDecoder) removeStreamed() {
+	for _, doc := range d.streamed {
+		_ = doc.Remove()
+	}
+	d.streamed = nil
+}
+
+// WriteByte appends to the in-memory JSON, enforcing the read limit.
+func (d *streamingDecoder) WriteByte(c byte) error {
+	if d.readLimit > 0 && int64(len(d.buf)) >= d.readLimit {
+		return ErrReadLimitExceeded
+	}
+	d.buf = append(d.buf, c)
+	return nil
+}
+
+func (d *streamingDecoder) write(b []byte) error {
+	for _, c := range b {
+		if err := d.WriteByte(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanValue reads the next JSON value, which can be an envelope or an array of envelopes.
+func (d *streamingDecoder) scanValue() error {
+	c, err := d.skipSpace()
+	if err != nil {
+		return err
+	}
+	if c != '[' {
+		_ = d.r.UnreadByte()
+		return d.scanEnvelope(0)
+	}
+
+	if err = d.WriteByte(c); err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		if c, err = d.nextSpace(); err != nil {
+			return err
+		}
+		if c == ']' && i == 0 {
+			return d.WriteByte(c)
+		}
+		_ = d.r.UnreadByte()
+		if err = d.scanEnvelope(i); err != nil {
+			return err
+		}
+		if c, err = d.nextSpace(); err != nil {
+			return err
+		}
+		if err = d.WriteByte(c); err != nil {
+			return err
+		}
+		if c == ']' {
+			return nil
+		}
+		if c != ',' {
+			return syntaxError(c)
+		}
+	}
+}
+
+// scanEnvelope reads an envelope object, streaming its document, or any other value, which is kept in memory.
+func (d *streamingDecoder) scanEnvelope(index int) error {
+	c, err := d.nextSpace()
+	if err != nil {
+		return err
+	}
+	_ = d.r.UnreadByte()
+	if c != '{' {
+		return d.copyValue(d)
+	}
+
+	_, _ = d.r.ReadByte()
+	if err = d.WriteByte('{'); err != nil {
+		return err
+	}
+	if c, err = d.nextSpace(); err != nil {
+		return err
+	}
+	if c == '}' {
+		return d.WriteByte(c)
+	}
+	_ = d.r.UnreadByte()
+
+	for {
+		if c, err = d.nextSpace(); err != nil {
+			return err
+		}
+		if c != '"' {
+			return syntaxError(c)
+		}
+		start := len(d.buf)
+		if err = d.WriteByte(c); err != nil {
+			return err
+		}
+		if err = d.copyString(d); err != nil {
+			return err
+		}
+		key := string(d.buf[start:])
+
+		if c, err = d.nextSpace(); err != nil {
+			return err
+		}
+		if c != ':' {
+			return syntaxError(c)
+		}
+		if err = d.WriteByte(c); err != nil {
+			return err
+		}
+
+		if (key == `"content"` || key == `"resource"`) && d.streamed[index] == nil {
+			err = d.streamValue(index)
+		} else {
+			err = d.copyValue(d)
+		}
+		if err != nil {
+			return err
+		}
+
+		if c, err = d.nextSpace(); err != nil {
+			return err
+		}
+		if err = d.WriteByte(c); err != nil {
+			return err
+		}
+		if c == '}' {
+			return nil
+		}
+		if c != ',' {
+			return syntaxError(c)
+		}
+	}
+}
+
+// streamValue reads a document, writing it to a file if it is larger than the threshold.
+func (d *streamingDecoder) streamValue(index int) error {
+	s := &spillBuffer{threshold: d.threshold, limit: d.limit, dir: d.dir}
+	if err := d.copyValue(s); err != nil {
+		s.discard()
+		return err
+	}
+	if s.file == nil {
+		return d.write(s.buf.Bytes())
+	}
+
+	doc, err := s.close()
+	if err != nil {
+		return err
+	}
+	if d.streamed == nil {
+		d.streamed = make(map[int]*StreamedDocument)
+	}
+	d.streamed[index] = doc
+	return d.write(streamedPlaceholder)
+}
+
+// copyValue copies the next JSON value to the writer, without validating it.
+func (d *streamingDecoder) copyValue(w io.ByteWriter) error {
+	c, err := d.nextSpace()
+	if err != nil {
+		return err
+	}
+	if err = w.WriteByte(c); err != nil {
+		return err
+	}
+
+	switch c {
+	case '"':
+		return d.copyString(w)
+	case '{', '[':
+		for depth := 1; depth > 0; {
+			if c, err = d.next(); err != nil {
+				return err
+			}
+			if err = w.WriteByte(c); err != nil {
+				return err
+			}
+			switch c {
+			case '"':
+				if err = d.copyString(w); err != nil {
+					return err
+				}
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		return nil
+	default:
+		// Literals and numbers end at the next delimiter
+		for {
+			c, err = d.r.ReadByte()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			switch c {
+			case ',', '}', ']', ' ', '\t', '\r', '\n':
+				return d.r.UnreadByte()
+			}
+			if err = w.WriteByte(c); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// copyString copies the rest of a JSON string after the opening quote, including the closing one.
+func (d *streamingDecoder) copyString(w io.ByteWriter) error {
+	escaped := false
+	for {
+		c, err := d.next()
+		if err != nil {
+			return err
+		}
+		if err = w.WriteByte(c); err != nil {
+			return err
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			return nil
+		}
+	}
+}
+
+// skipSpace returns the first byte after the whitespaces, or io.EOF if the stream ended before a value.
+func (d *streamingDecoder) skipSpace() (byte, error) {
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c, nil
+		}
+	}
+}
+
+// nextSpace is like skipSpace, but in the middle of a value.
+func (d *streamingDecoder) nextSpace() (byte, error) {
+	c, err := d.skipSpace()
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return c, err
+}
+
+func (d *streamingDecoder) next() (byte, error) {
+	c, err := d.r.ReadByte()
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return c, err
+}
+
+func syntaxError(c byte) error {
+	return fmt.Errorf("invalid character %q in envelope", c)
+}
+
+// spillBuffer keeps a document in memory until it is larger than the threshold, and then moves it to a temporary
+// file.
+type spillBuffer struct {
+	threshold int64
+	limit     int64
+	dir       string
+	buf       bytes.Buffer
+	file      *os.File
+	w         *bufio.Writer
+	size      int64
+}
+
+func (s *spillBuffer) WriteByte(c byte) error {
+	s.size++
+	if s.limit > 0 && s.size > s.limit {
+		return fmt.Errorf("streamed document: %w", ErrReadLimitExceeded)
+	}
+	if s.file != nil {
+		return s.w.WriteByte(c)
+	}
+	if s.size <= s.threshold {
+		return s.buf.WriteByte(c)
+	}
+
+	f, err := os.CreateTemp(s.dir, "lime-document-*.json")
+	if err != nil {
+		return fmt.Errorf("streamed document: %w", err)
+	}
+	s.file = f
+	s.w = bufio.NewWriter(f)
+	if _, err = s.w.Write(s.buf.Bytes()); err != nil {
+		return err
+	}
+	s.buf = bytes.Buffer{}
+	return s.w.WriteByte(c)
+}
+
+func (s *spillBuffer) close() (*StreamedDocument, error) {
+	err := s.w.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(s.file.Name())
+		return nil, fmt.Errorf("streamed document: %w", err)
+	}
+	return &StreamedDocument{Path: s.file.Name(), Size: s.size}, nil
+}
+
+func (s *spillBuffer) discard() {
+	if s.file != nil {
+		_ = s.file.Close()
+		_ = os.Remove(s.file.Name())
+	}
+}
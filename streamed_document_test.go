@@ -0,0 +1,266 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamingDecoder_Decode_StreamsLargeContent(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	content := strings.Repeat("a", 256)
+	input := `{"id":"1","type":"text/plain","content":"` + content + `"} {"id":"2","type":"text/plain","content":"small"}`
+	d := newStreamingDecoder(strings.NewReader(input), 128, 64, 0, dir)
+
+	// Act
+	var batch1, batch2 rawEnvelopeBatch
+	err1 := d.Decode(&batch1)
+	err2 := d.Decode(&batch2)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	msg1, err := batch1[0].toEnvelope()
+	assert.NoError(t, err)
+	doc, ok := msg1.(*Message).Content.(*StreamedDocument)
+	if assert.True(t, ok) {
+		defer doc.Remove()
+		assert.Equal(t, MediaTypeTextPlain(), doc.MediaType())
+		assert.Equal(t, int64(len(content)+2), doc.Size)
+		var actual string
+		assert.NoError(t, doc.Decode(&actual))
+		assert.Equal(t, content, actual)
+	}
+	msg2, err := batch2[0].toEnvelope()
+	assert.NoError(t, err)
+	assert.Equal(t, TextDocument("small"), *msg2.(*Message).Content.(*TextDocument))
+}
+
+func TestStreamingDecoder_Decode_Array(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	resource := `{"text":"` + strings.Repeat("b", 128) + `","escaped":"\"}]"}`
+	input := `[{"id":"1","method":"set","uri":"/documents","type":"application/json","resource":` + resource + `},` +
+		`{"id":"2","to":"golang@limeprotocol.org","event":"received"}]`
+	d := newStreamingDecoder(strings.NewReader(input), 256, 64, 0, dir)
+
+	// Act
+	var batch rawEnvelopeBatch
+	err := d.Decode(&batch)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.Len(t, batch, 2) {
+		cmd, err := batch[0].toEnvelope()
+		assert.NoError(t, err)
+		doc := cmd.(*RequestCommand).Resource.(*StreamedDocument)
+		defer doc.Remove()
+		actual, err := doc.Document()
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("b", 128), (*actual.(*JsonDocument))["text"])
+		assert.Equal(t, `"}]`, (*actual.(*JsonDocument))["escaped"])
+		assert.Nil(t, batch[1].streamed)
+	}
+}
+
+func TestStreamingDecoder_Decode_ReadLimit(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	input := `{"id":"1","type":"text/plain","content":"small","metadata":{"key":"` + strings.Repeat("c", 128) + `"}}`
+	d := newStreamingDecoder(strings.NewReader(input), 64, 64, 0, dir)
+
+	// Act
+	var batch rawEnvelopeBatch
+	err := d.Decode(&batch)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReadLimitExceeded)
+}
+
+func TestStreamingDecoder_Decode_StreamLimit(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	input := `{"id":"1","type":"text/plain","content":"` + strings.Repeat("a", 256) + `"}`
+	d := newStreamingDecoder(strings.NewReader(input), 64, 16, 128, dir)
+
+	// Act
+	var batch rawEnvelopeBatch
+	err := d.Decode(&batch)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReadLimitExceeded)
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestTCPTransport_Receive_StreamedDocument(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	var transportChan = make(chan Transport, 1)
+	listener := NewTCPTransportListener(&TCPConfig{ReadLimit: 512, StreamThreshold: 256, StreamDir: t.TempDir()})
+	if err := listener.Listen(context.Background(), addr); err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(listener)
+	go func() {
+		if transport, err := listener.Accept(context.Background()); err == nil {
+			transportChan <- transport
+		}
+	}()
+	client := createClientTCPTransport(t, addr)
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	msg := createMessage()
+	content := TextDocument(strings.Repeat("d", 4096))
+	msg.SetContent(&content)
+
+	// Act
+	err := client.Send(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	doc := actual.(*Message).Content.(*StreamedDocument)
+	defer doc.Remove()
+	var actualContent string
+	assert.NoError(t, doc.Decode(&actualContent))
+	assert.Equal(t, string(content), actualContent)
+}
+
+func TestTCPTransport_Send_WriteLimit(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	var transportChan = make(chan Transport, 1)
+	listener := createTCPListener(t, addr, transportChan)
+	defer silentClose(listener)
+	client, err := DialTcp(context.Background(), addr, &TCPConfig{WriteLimit: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	msg := createMessage()
+	content := TextDocument(strings.Repeat("e", 512))
+	msg.SetContent(&content)
+
+	// Act
+	err1 := client.Send(ctx, msg)
+	err2 := client.Send(ctx, createMessage())
+
+	// Assert
+	assert.True(t, errors.Is(err1, ErrWriteLimitExceeded))
+	assert.NoError(t, err2)
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, createMessage().Content, actual.(*Message).Content)
+}
+
+func createStreamedDocument(t *testing.T, mediaType MediaType, content string) *StreamedDocument {
+	f, err := os.CreateTemp(t.TempDir(), "lime-document-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return &StreamedDocument{Type: mediaType, Path: f.Name(), Size: int64(len(content))}
+}
+
+func TestMessage_Clone_StreamedDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       MediaType
+		content string
+	}{
+		{"String", MediaTypeTextPlain(), `"Hello world"`},
+		{"Object", MediaTypeApplicationJson(), `{"text":"Hello world"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			doc := createStreamedDocument(t, tt.t, tt.content)
+			msg := &Message{}
+			msg.SetContent(doc)
+
+			// Act
+			clone, err := msg.Clone()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Assert
+			cloned, ok := clone.Content.(*StreamedDocument)
+			if assert.True(t, ok) {
+				assert.NotEqual(t, doc.Path, cloned.Path)
+				assert.Equal(t, doc.Type, cloned.Type)
+				assert.Equal(t, doc.Size, cloned.Size)
+				assert.NoError(t, cloned.Remove())
+				actual, err := os.ReadFile(doc.Path)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.content, string(actual))
+			}
+		})
+	}
+}
+
+func TestEnvelopeMux_HandleMessage_DiscardsStreamedDocument(t *testing.T) {
+	// Arrange
+	doc := createStreamedDocument(t, MediaTypeTextPlain(), `"Hello world"`)
+	msg := &Message{}
+	msg.SetContent(doc)
+	m := &EnvelopeMux{}
+	m.MessageHandlerFunc(
+		func(msg *Message) bool {
+			return msg.Type.Subtype == "json"
+		},
+		func(ctx context.Context, msg *Message, s Sender) error {
+			return nil
+		})
+
+	// Act
+	err := m.handleMessage(context.Background(), msg, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = os.Stat(doc.Path)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestEnvelopeMux_HandleMessage_KeepsHandledStreamedDocument(t *testing.T) {
+	// Arrange
+	doc := createStreamedDocument(t, MediaTypeTextPlain(), `"Hello world"`)
+	msg := &Message{}
+	msg.SetContent(doc)
+	m := &EnvelopeMux{}
+	m.MessageHandlerFunc(
+		func(msg *Message) bool {
+			return true
+		},
+		func(ctx context.Context, msg *Message, s Sender) error {
+			return nil
+		})
+
+	// Act
+	err := m.handleMessage(context.Background(), msg, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = os.Stat(doc.Path)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,188 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/multierr"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ObserveHandlerFunc handles the observe commands received for a subscription. The gap flag indicates that the
+// subscription was renewed after a reconnection, so the changes of the resource while the client was disconnected may
+// have been missed and the handler should get its current value.
+type ObserveHandlerFunc func(ctx context.Context, cmd *RequestCommand, gap bool) error
+
+// Subscription is a subscription of a Client to the changes of a resource, which is renewed automatically after the
+// reconnections.
+type Subscription struct {
+	client  *Client
+	uri     *URI
+	handler ObserveHandlerFunc
+	gap     atomic.Bool // gap indicates that the subscription was renewed and no observation was received since
+}
+
+// URI returns the subscribed resource URI.
+func (s *Subscription) URI() *URI {
+	return s.uri
+}
+
+// Unsubscribe removes the subscription and sends the unsubscribe command to the server, unless there are other
+// subscriptions to the same resource.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	s.client.subscriptions.remove(s)
+	if s.client.subscriptions.subscribed(s.uri.Path()) {
+		return nil
+	}
+	if err := s.client.processSubscription(ctx, s.uri, CommandMethodUnsubscribe); err != nil {
+		return fmt.Errorf("unsubscribe: %w", err)
+	}
+	return nil
+}
+
+// subscriptions holds the subscriptions of a client.
+type subscriptions struct {
+	mu   sync.RWMutex
+	subs []*Subscription
+}
+
+func (r *subscriptions) add(s *Subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs = append(r.subs, s)
+}
+
+func (r *subscriptions) remove(s *Subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subs = slices.DeleteFunc(r.subs, func(sub *Subscription) bool { return sub == s })
+}
+
+func (r *subscriptions) list() []*Subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.subs)
+}
+
+// subscribed indicates if there are subscriptions to the resource path.
+func (r *subscriptions) subscribed(path string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.ContainsFunc(r.subs, func(s *Subscription) bool { return s.uri.Path() == path })
+}
+
+// find returns the subscriptions of the observe command resource, if any.
+func (r *subscriptions) find(cmd *RequestCommand) []*Subscription {
+	if cmd.Method != CommandMethodObserve || cmd.URI == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var subs []*Subscription
+	for _, s := range r.subs {
+		if s.uri.Path() == cmd.URI.Path() {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
+
+func (r *subscriptions) matchObserve(cmd *RequestCommand) bool {
+	return len(r.find(cmd)) != 0
+}
+
+// handleObserve delivers the observe command to all the subscriptions of the resource.
+func (r *subscriptions) handleObserve(ctx context.Context, cmd *RequestCommand, _ Sender) error {
+	var errs []error
+	for _, s := range r.find(cmd) {
+		if err := s.handler(ctx, cmd, s.gap.Swap(false)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// Subscribe sends a subscribe command for the resource to the server and registers the handler for its observe
+// commands. After a reconnection, the subscription is sent again and the next observation is flagged as a gap.
+func (c *Client) Subscribe(ctx context.Context, uri string, handler ObserveHandlerFunc) (*Subscription, error) {
+	if handler == nil {
+		panic("nil handler")
+	}
+	u, err := ParseLimeURI(uri)
+	if err != nil {
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+	s := &Subscription{client: c, uri: u, handler: handler}
+	// The subscription is registered before the command, so the first observations are not lost
+	c.subscriptions.add(s)
+	if err = c.processSubscription(ctx, u, CommandMethodSubscribe); err != nil {
+		c.subscriptions.remove(s)
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+	return s, nil
+}
+
+func (c *Client) processSubscription(ctx context.Context, uri *URI, method CommandMethod) error {
+	cmd := &RequestCommand{}
+	cmd.SetURI(uri).
+		SetMethod(method).
+		SetNewEnvelopeID()
+	respCmd, err := c.processCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if respCmd.Status != CommandStatusSuccess {
+		if respCmd.Reason == nil {
+			return errors.New("command failed")
+		}
+		return fmt.Errorf("command failed: %w", respCmd.Reason)
+	}
+	return nil
+}
+
+// resubscribe sends the subscriptions again after a reconnection, flagging them with a gap. The failed subscriptions
+// are retried with the reconnection backoff, while their resources are still subscribed.
+func (c *Client) resubscribe(ctx context.Context) {
+	var wg sync.WaitGroup
+	paths := make(map[string]struct{})
+	for _, s := range c.subscriptions.list() {
+		s.gap.Store(true)
+		if _, ok := paths[s.uri.Path()]; ok {
+			continue
+		}
+		paths[s.uri.Path()] = struct{}{}
+		uri := s.uri
+		wg.Add(1)
+		goLabeled(ctx, "client.resubscribe", func(ctx context.Context) {
+			defer wg.Done()
+			c.resubscribeURI(ctx, uri)
+		}, "uri", uri.String())
+	}
+	wg.Wait()
+}
+
+func (c *Client) resubscribeURI(ctx context.Context, uri *URI) {
+	backoff := c.config.ReconnectBackoff
+	if backoff == nil {
+		backoff = quadraticBackoff
+	}
+	var interval time.Duration
+	for retry := 0; ; retry++ {
+		err := c.processSubscription(ctx, uri, CommandMethodSubscribe)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		interval = backoff.Backoff(retry, interval)
+		log.Printf("client: resubscribe %v, retrying in %v: %v", uri, interval, err)
+		if err := sleep(ctx, clockOrSystem(c.config.Clock), interval); err != nil {
+			return
+		}
+		if !c.subscriptions.subscribed(uri.Path()) {
+			return
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type observation struct {
+	text string
+	gap  bool
+}
+
+// startSubscriptionServer starts a server that replies the subscriptions to the '/presence' resource, sending an
+// observe command with the number of subscriptions after each one.
+func startSubscriptionServer(t *testing.T, addr InProcessAddr, unsubscribed chan<- struct{}) *Server {
+	var count atomic.Int32
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		RequestCommandHandlerFunc(
+			func(cmd *RequestCommand) bool { return cmd.Method == CommandMethodSubscribe },
+			func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+				if cmd.URI.Path() != "/presence" {
+					return s.SendResponseCommand(ctx, cmd.FailureResponse(&Reason{Code: 67, Description: "Resource not found"}))
+				}
+				if err := s.SendResponseCommand(ctx, cmd.SuccessResponse()); err != nil {
+					return err
+				}
+				d := TextDocument(strconv.Itoa(int(count.Add(1))))
+				observe := &RequestCommand{}
+				observe.SetURIString("/presence").SetMethod(CommandMethodObserve).SetResource(&d)
+				return s.SendRequestCommand(ctx, observe)
+			}).
+		RequestCommandHandlerFunc(
+			func(cmd *RequestCommand) bool { return cmd.Method == CommandMethodUnsubscribe },
+			func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+				unsubscribed <- struct{}{}
+				return s.SendResponseCommand(ctx, cmd.SuccessResponse())
+			}).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	return srv
+}
+
+func receiveObservation(t *testing.T, ctx context.Context, observations <-chan observation) observation {
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case o := <-observations:
+		return o
+	}
+	return observation{}
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("client-subscribe")
+	srv := startSubscriptionServer(t, addr, nil)
+	defer silentClose(srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		Build()
+	defer silentClose(client)
+	observations := make(chan observation, 2)
+
+	// Act
+	sub, err := client.Subscribe(ctx, "/presence", func(ctx context.Context, cmd *RequestCommand, gap bool) error {
+		observations <- observation{text: string(*cmd.Resource.(*TextDocument)), gap: gap}
+		return nil
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "/presence", sub.URI().Path())
+	assert.Equal(t, observation{text: "1", gap: false}, receiveObservation(t, ctx, observations))
+}
+
+func TestClient_Subscribe_Reconnection(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	addr := InProcessAddr("client-subscribe-reconnection")
+	srv := startSubscriptionServer(t, addr, nil)
+	defer silentClose(srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		Build()
+	defer silentClose(client)
+	observations := make(chan observation, 2)
+	_, err := client.Subscribe(ctx, "/presence", func(ctx context.Context, cmd *RequestCommand, gap bool) error {
+		observations <- observation{text: string(*cmd.Resource.(*TextDocument)), gap: gap}
+		return nil
+	})
+	assert.NoError(t, err)
+	_ = receiveObservation(t, ctx, observations)
+
+	// Act
+	client.mu.RLock()
+	_ = client.channel.Close()
+	client.mu.RUnlock()
+
+	// Assert
+	assert.Equal(t, observation{text: "2", gap: true}, receiveObservation(t, ctx, observations))
+}
+
+func TestClient_Subscribe_Failure(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("client-subscribe-failure")
+	srv := startSubscriptionServer(t, addr, nil)
+	defer silentClose(srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		Build()
+	defer silentClose(client)
+
+	// Act
+	sub, err := client.Subscribe(ctx, "/contacts", func(ctx context.Context, cmd *RequestCommand, gap bool) error {
+		return nil
+	})
+
+	// Assert
+	var reason *Reason
+	assert.ErrorAs(t, err, &reason)
+	assert.Nil(t, sub)
+	assert.Empty(t, client.subscriptions.list())
+}
+
+func TestSubscription_Unsubscribe(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("client-unsubscribe")
+	unsubscribed := make(chan struct{}, 1)
+	srv := startSubscriptionServer(t, addr, unsubscribed)
+	defer silentClose(srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		Build()
+	defer silentClose(client)
+	sub, _ := client.Subscribe(ctx, "/presence", func(ctx context.Context, cmd *RequestCommand, gap bool) error {
+		return nil
+	})
+
+	// Act
+	err := sub.Unsubscribe(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, unsubscribed, 1)
+	assert.Empty(t, client.subscriptions.list())
+}
+
+func TestClient_Subscribe_SharedResource(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("client-subscribe-shared")
+	unsubscribed := make(chan struct{}, 1)
+	srv := startSubscriptionServer(t, addr, unsubscribed)
+	defer silentClose(srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		Build()
+	defer silentClose(client)
+	observations1 := make(chan observation, 2)
+	observations2 := make(chan observation, 2)
+	sub1, err := client.Subscribe(ctx, "/presence", func(ctx context.Context, cmd *RequestCommand, gap bool) error {
+		observations1 <- observation{text: string(*cmd.Resource.(*TextDocument)), gap: gap}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, observation{text: "1"}, receiveObservation(t, ctx, observations1))
+
+	// Act
+	_, err = client.Subscribe(ctx, "/presence", func(ctx context.Context, cmd *RequestCommand, gap bool) error {
+		observations2 <- observation{text: string(*cmd.Resource.(*TextDocument)), gap: gap}
+		return nil
+	})
+	errUnsubscribe := sub1.Unsubscribe(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, observation{text: "2"}, receiveObservation(t, ctx, observations1))
+	assert.Equal(t, observation{text: "2"}, receiveObservation(t, ctx, observations2))
+	assert.NoError(t, errUnsubscribe)
+	assert.Empty(t, unsubscribed)
+	assert.Len(t, client.subscriptions.list(), 1)
+}
+
+func TestClient_Subscribe_ReconnectionRetry(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	addr := InProcessAddr("client-subscribe-reconnection-retry")
+	var count atomic.Int32
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		RequestCommandHandlerFunc(
+			func(cmd *RequestCommand) bool { return cmd.Method == CommandMethodSubscribe },
+			func(ctx context.Context, cmd *RequestCommand, s Sender) error {
+				n := count.Add(1)
+				if n == 2 {
+					// The first renewal fails
+					return s.SendResponseCommand(ctx, cmd.FailureResponse(&Reason{Code: 1, Description: "Temporarily unavailable"}))
+				}
+				if err := s.SendResponseCommand(ctx, cmd.SuccessResponse()); err != nil {
+					return err
+				}
+				d := TextDocument(strconv.Itoa(int(n)))
+				observe := &RequestCommand{}
+				observe.SetURIString("/presence").SetMethod(CommandMethodObserve).SetResource(&d)
+				return s.SendRequestCommand(ctx, observe)
+			}).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	client := NewClientBuilder().
+		UseInProcess(addr, 1).
+		GuestAuthentication().
+		ReconnectBackoff(BackoffFunc(func(int, time.Duration) time.Duration { return 5 * time.Millisecond })).
+		Build()
+	defer silentClose(client)
+	observations := make(chan observation, 2)
+	_, err := client.Subscribe(ctx, "/presence", func(ctx context.Context, cmd *RequestCommand, gap bool) error {
+		observations <- observation{text: string(*cmd.Resource.(*TextDocument)), gap: gap}
+		return nil
+	})
+	assert.NoError(t, err)
+	_ = receiveObservation(t, ctx, observations)
+
+	// Act
+	client.mu.RLock()
+	_ = client.channel.Close()
+	client.mu.RUnlock()
+
+	// Assert
+	assert.Equal(t, observation{text: "3", gap: true}, receiveObservation(t, ctx, observations))
+}
@@ -0,0 +1,283 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TapDirection indicates if a mirrored envelope was sent or received by the channel.
+type TapDirection string
+
+const (
+	TapDirectionSent     = TapDirection("sent")
+	TapDirectionReceived = TapDirection("received")
+)
+
+// TapEnvelopeType is the type of a mirrored envelope.
+type TapEnvelopeType string
+
+const (
+	TapEnvelopeTypeMessage         = TapEnvelopeType("message")
+	TapEnvelopeTypeNotification    = TapEnvelopeType("notification")
+	TapEnvelopeTypeRequestCommand  = TapEnvelopeType("requestCommand")
+	TapEnvelopeTypeResponseCommand = TapEnvelopeType("responseCommand")
+)
+
+// ErrTapClosed is returned when an envelope is mirrored to a closed Tap.
+var ErrTapClosed = errors.New("tap closed")
+
+// TapRecord is the copy of an envelope mirrored by a Tap.
+type TapRecord struct {
+	// Timestamp is the moment when the envelope was sent or received.
+	Timestamp time.Time `json:"timestamp"`
+	// SessionID is the identifier of the channel session.
+	SessionID string `json:"sessionId"`
+	// RemoteNode is the remote node of the channel session.
+	RemoteNode Node `json:"remoteNode"`
+	// Direction indicates if the envelope was sent or received.
+	Direction TapDirection `json:"direction"`
+	// Type is the envelope type.
+	Type TapEnvelopeType `json:"type"`
+	// Envelope is the copy of the envelope, which can be a *Message, *Notification, *RequestCommand or
+	// *ResponseCommand value.
+	Envelope envelope `json:"envelope"`
+}
+
+// TapSink receives the envelopes mirrored by a Tap, like a compliance archive.
+type TapSink interface {
+	WriteTap(ctx context.Context, r *TapRecord) error
+}
+
+// TapSinkFunc is an adapter to allow the use of functions as TapSink, for instance, for writing the records to a
+// message queue producer.
+type TapSinkFunc func(ctx context.Context, r *TapRecord) error
+
+func (f TapSinkFunc) WriteTap(ctx context.Context, r *TapRecord) error {
+	return f(ctx, r)
+}
+
+// WriterTapSink returns a TapSink that writes the records as JSON lines to the writer, like a file.
+func WriterTapSink(w io.Writer) TapSink {
+	var mu sync.Mutex
+	return TapSinkFunc(func(ctx context.Context, r *TapRecord) error {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("writer tap sink: %w", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err = w.Write(append(b, '\n')); err != nil {
+			return fmt.Errorf("writer tap sink: %w", err)
+		}
+		return nil
+	})
+}
+
+// SenderTapSink returns a TapSink that sends the mirrored envelopes through another channel or client to the
+// node, like an archiving service. The copies are addressed to the node only, with new identifiers, so they are not
+// delivered again to the original destinations nor taken as replies of the original envelopes.
+func SenderTapSink(s Sender, to Node) TapSink {
+	return TapSinkFunc(func(ctx context.Context, r *TapRecord) error {
+		env := envelopeOf(r.Envelope)
+		env.ID = NewEnvelopeID()
+		env.From = Node{}
+		env.PP = Node{}
+		env.To = to
+
+		switch e := r.Envelope.(type) {
+		case *Message:
+			return s.SendMessage(ctx, e)
+		case *Notification:
+			return s.SendNotification(ctx, e)
+		case *RequestCommand:
+			return s.SendRequestCommand(ctx, e)
+		case *ResponseCommand:
+			return s.SendResponseCommand(ctx, e)
+		default:
+			return fmt.Errorf("sender tap sink: unexpected envelope type %T", r.Envelope)
+		}
+	})
+}
+
+// TapFilter selects the envelopes mirrored by a Tap. The record envelope is not a copy, so it must not be changed.
+type TapFilter func(r *TapRecord) bool
+
+// TapIdentities selects the envelopes from or to the specified identities. The remote node of the channel is
+// considered if the envelope does not define the addresses.
+func TapIdentities(identities ...Identity) TapFilter {
+	return func(r *TapRecord) bool {
+		env := envelopeOf(r.Envelope)
+		for _, n := range []Node{env.From, env.To, r.RemoteNode} {
+			if n.Identity != (Identity{}) && slices.Contains(identities, n.Identity) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TapEnvelopeTypes selects the envelopes of the specified types.
+func TapEnvelopeTypes(types ...TapEnvelopeType) TapFilter {
+	return func(r *TapRecord) bool {
+		return slices.Contains(types, r.Type)
+	}
+}
+
+// Tap mirrors a copy of the envelopes sent and received by the channels to a secondary sink, for compliance
+// archiving. The copies are written asynchronously, so a slow or failing sink does not affect the primary
+// delivery; when the buffer is full, the copies are discarded and counted in Dropped.
+type Tap struct {
+	sink    TapSink
+	filters []TapFilter
+	records chan *TapRecord
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// NewTap creates a Tap that writes to the sink the envelopes matched by all the filters.
+// The bufferSize is the number of copies that can be pending for writing.
+func NewTap(sink TapSink, bufferSize int, filters ...TapFilter) *Tap {
+	if sink == nil {
+		panic("nil sink")
+	}
+	t := &Tap{
+		sink:    sink,
+		filters: filters,
+		records: make(chan *TapRecord, bufferSize),
+		done:    make(chan struct{}),
+	}
+	goLabeled(context.Background(), "tap.writer", func(context.Context) {
+		t.write()
+	})
+	return t
+}
+
+// Dropped returns the number of envelopes that were not mirrored because the buffer was full or they could not be
+// copied.
+func (t *Tap) Dropped() uint64 {
+	return t.dropped.Load()
+}
+
+// Close stops accepting new envelopes and waits for the pending copies to be written.
+func (t *Tap) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrTapClosed
+	}
+	t.closed = true
+	close(t.records)
+	t.mu.Unlock()
+
+	<-t.done
+	return nil
+}
+
+func (t *Tap) write() {
+	defer close(t.done)
+	for r := range t.records {
+		if err := t.sink.WriteTap(context.Background(), r); err != nil {
+			log.Printf("tap: %v", err)
+		}
+	}
+}
+
+// mirror queues a copy of the envelope if it matches the filters.
+func (t *Tap) mirror(r *TapRecord) {
+	for _, f := range t.filters {
+		if !f(r) {
+			return
+		}
+	}
+
+	e, err := cloneTapEnvelope(r.Envelope)
+	if err != nil {
+		// The mirroring must not affect the primary delivery, so the envelope is discarded
+		log.Printf("tap: %v", err)
+		t.dropped.Add(1)
+		return
+	}
+	r.Envelope = e
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.records <- r:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// cloneTapEnvelope copies the envelope for mirroring. Since it runs in the sender and receiver goroutines of the
+// channel, the panics of the document serialization are also returned as errors.
+func cloneTapEnvelope(e envelope) (c envelope, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c, err = nil, fmt.Errorf("clone envelope: panic: %v", r)
+		}
+	}()
+
+	switch e := e.(type) {
+	case *Message:
+		return e.Clone()
+	case *Notification:
+		return e.Clone(), nil
+	case *RequestCommand:
+		return e.Clone()
+	case *ResponseCommand:
+		return e.Clone()
+	default:
+		return nil, fmt.Errorf("clone envelope: unexpected envelope type %T", e)
+	}
+}
+
+// SetTap defines the Tap that mirrors the envelopes sent and received by the channel after the session is
+// established.
+func (c *channel) SetTap(t *Tap) {
+	if err := c.ensureState(SessionStateNew, "set tap"); err != nil {
+		panic(err)
+	}
+	c.tap = t
+}
+
+func (c *channel) mirror(e envelope, direction TapDirection) {
+	if c.tap == nil {
+		return
+	}
+
+	var envType TapEnvelopeType
+	switch e.(type) {
+	case *Message:
+		envType = TapEnvelopeTypeMessage
+	case *Notification:
+		envType = TapEnvelopeTypeNotification
+	case *RequestCommand:
+		envType = TapEnvelopeTypeRequestCommand
+	case *ResponseCommand:
+		envType = TapEnvelopeTypeResponseCommand
+	default:
+		return
+	}
+
+	c.tap.mirror(&TapRecord{
+		Timestamp:  c.clock.Now(),
+		SessionID:  c.sessionID,
+		RemoteNode: c.remoteNode,
+		Direction:  direction,
+		Type:       envType,
+		Envelope:   e,
+	})
+}
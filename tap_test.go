@@ -0,0 +1,283 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tapRecorder is a TapSink that keeps the written records.
+type tapRecorder struct {
+	mu      sync.Mutex
+	records []*TapRecord
+}
+
+func (r *tapRecorder) WriteTap(ctx context.Context, record *TapRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *tapRecorder) Records() []*TapRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*TapRecord(nil), r.records...)
+}
+
+func TestServer_Tap(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("tap")
+	recorder := &tapRecorder{}
+	tap := NewTap(recorder, 16, TapEnvelopeTypes(TapEnvelopeTypeRequestCommand, TapEnvelopeTypeResponseCommand))
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		AutoReplyPings().
+		Tap(tap).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	client := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(client)
+	reqCmd := createGetPingCommand()
+
+	// Act
+	respCmd, err := client.ProcessCommand(ctx, reqCmd)
+	_ = client.SendMessage(ctx, createMessage())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, CommandStatusSuccess, respCmd.Status)
+	assert.Eventually(t, func() bool {
+		return len(recorder.Records()) == 2
+	}, 100*time.Millisecond, 5*time.Millisecond)
+	assert.NoError(t, tap.Close())
+	records := recorder.Records()
+	assert.Len(t, records, 2)
+	assert.Equal(t, TapDirectionReceived, records[0].Direction)
+	assert.Equal(t, TapEnvelopeTypeRequestCommand, records[0].Type)
+	assert.Equal(t, reqCmd.ID, records[0].Envelope.(*RequestCommand).ID)
+	assert.Equal(t, "golang", records[0].RemoteNode.Name)
+	assert.Equal(t, TapDirectionSent, records[1].Direction)
+	assert.Equal(t, TapEnvelopeTypeResponseCommand, records[1].Type)
+	assert.Equal(t, records[0].SessionID, records[1].SessionID)
+}
+
+func TestTap_Mirror_CopiesEnvelope(t *testing.T) {
+	// Arrange
+	recorder := &tapRecorder{}
+	tap := NewTap(recorder, 1)
+	msg := createMessage()
+
+	// Act
+	tap.mirror(&TapRecord{Type: TapEnvelopeTypeMessage, Envelope: msg})
+	msg.SetMetadataKeyValue("changed", "true")
+
+	// Assert
+	assert.NoError(t, tap.Close())
+	records := recorder.Records()
+	assert.Len(t, records, 1)
+	assert.NotSame(t, msg, records[0].Envelope)
+	assert.NotContains(t, records[0].Envelope.(*Message).Metadata, "changed")
+}
+
+func TestTap_Mirror_Filters(t *testing.T) {
+	// Arrange
+	recorder := &tapRecorder{}
+	tap := NewTap(recorder, 4, TapIdentities(Identity{Name: "alice", Domain: "localhost"}))
+	fromAlice := createMessage()
+	fromAlice.SetFromString("alice@localhost/home")
+	fromBob := createMessage()
+	fromBob.SetFromString("bob@localhost/home")
+
+	// Act
+	tap.mirror(&TapRecord{Type: TapEnvelopeTypeMessage, Envelope: fromAlice})
+	tap.mirror(&TapRecord{Type: TapEnvelopeTypeMessage, Envelope: fromBob})
+	tap.mirror(&TapRecord{
+		Type:       TapEnvelopeTypeMessage,
+		RemoteNode: Node{Identity: Identity{Name: "alice", Domain: "localhost"}},
+		Envelope:   createMessage(),
+	})
+
+	// Assert
+	assert.NoError(t, tap.Close())
+	records := recorder.Records()
+	assert.Len(t, records, 2)
+	assert.Equal(t, fromAlice.ID, records[0].Envelope.(*Message).ID)
+}
+
+func TestTap_Mirror_BufferFull(t *testing.T) {
+	// Arrange
+	release := make(chan struct{})
+	sink := TapSinkFunc(func(ctx context.Context, r *TapRecord) error {
+		<-release
+		return nil
+	})
+	tap := NewTap(sink, 1)
+
+	// Act
+	for i := 0; i < 5; i++ {
+		tap.mirror(&TapRecord{Type: TapEnvelopeTypeMessage, Envelope: createMessage()})
+	}
+
+	// Assert
+	assert.GreaterOrEqual(t, tap.Dropped(), uint64(3))
+	close(release)
+	assert.NoError(t, tap.Close())
+	assert.ErrorIs(t, tap.Close(), ErrTapClosed)
+}
+
+func TestWriterTapSink(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	sink := WriterTapSink(&buf)
+	msg := createMessage()
+	r := &TapRecord{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		SessionID: "52e59849",
+		Direction: TapDirectionReceived,
+		Type:      TapEnvelopeTypeMessage,
+		Envelope:  msg,
+	}
+
+	// Act
+	err := sink.WriteTap(context.Background(), r)
+
+	// Assert
+	assert.NoError(t, err)
+	var decoded struct {
+		SessionID string          `json:"sessionId"`
+		Direction string          `json:"direction"`
+		Type      string          `json:"type"`
+		Envelope  json.RawMessage `json:"envelope"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "52e59849", decoded.SessionID)
+	assert.Equal(t, "received", decoded.Direction)
+	assert.Equal(t, "message", decoded.Type)
+	var got Message
+	assert.NoError(t, json.Unmarshal(decoded.Envelope, &got))
+	assert.Equal(t, msg.ID, got.ID)
+	assert.Equal(t, byte('\n'), buf.Bytes()[buf.Len()-1])
+}
+
+// panickingDocument is a document whose serialization panics.
+type panickingDocument struct{}
+
+func (d *panickingDocument) MediaType() MediaType {
+	return MediaType{Type: "application", Subtype: "x-panicking", Suffix: "json"}
+}
+
+func (d *panickingDocument) MarshalJSON() ([]byte, error) {
+	panic("marshal")
+}
+
+func TestTap_Mirror_CloneFailure(t *testing.T) {
+	// Arrange
+	recorder := &tapRecorder{}
+	tap := NewTap(recorder, 2)
+	msg := &Message{}
+	msg.SetContent(&panickingDocument{})
+
+	// Act
+	assert.NotPanics(t, func() {
+		tap.mirror(&TapRecord{Type: TapEnvelopeTypeMessage, Envelope: msg})
+	})
+
+	// Assert
+	assert.NoError(t, tap.Close())
+	assert.Empty(t, recorder.Records())
+	assert.Equal(t, uint64(1), tap.Dropped())
+}
+
+// sentRecorder is a Sender that keeps the sent envelopes.
+type sentRecorder struct {
+	mu        sync.Mutex
+	envelopes []envelope
+}
+
+func (r *sentRecorder) record(e envelope) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.envelopes = append(r.envelopes, e)
+	return nil
+}
+
+func (r *sentRecorder) SendMessage(_ context.Context, msg *Message) error {
+	return r.record(msg)
+}
+
+func (r *sentRecorder) SendNotification(_ context.Context, not *Notification) error {
+	return r.record(not)
+}
+
+func (r *sentRecorder) SendRequestCommand(_ context.Context, cmd *RequestCommand) error {
+	return r.record(cmd)
+}
+
+func (r *sentRecorder) SendResponseCommand(_ context.Context, cmd *ResponseCommand) error {
+	return r.record(cmd)
+}
+
+func TestSenderTapSink(t *testing.T) {
+	// Arrange
+	recorder := &sentRecorder{}
+	archive := Node{Identity: Identity{Name: "archive", Domain: "localhost"}}
+	sink := SenderTapSink(recorder, archive)
+	msg := createMessage()
+	msg.SetFromString("alice@localhost/home")
+	not := createNotification()
+	tests := []envelope{msg, not}
+
+	for _, e := range tests {
+		original := *envelopeOf(e)
+		c, err := cloneTapEnvelope(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		err = sink.WriteTap(context.Background(), &TapRecord{Envelope: c})
+
+		// Assert
+		assert.NoError(t, err)
+		sent := envelopeOf(recorder.envelopes[len(recorder.envelopes)-1])
+		assert.Equal(t, archive, sent.To)
+		assert.Equal(t, Node{}, sent.From)
+		assert.NotEmpty(t, sent.ID)
+		assert.NotEqual(t, original.ID, sent.ID)
+	}
+}
+
+func TestChannel_Tap_Clock(t *testing.T) {
+	// Arrange
+	client, _ := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	recorder := &tapRecorder{}
+	tap := NewTap(recorder, 1)
+	c.SetTap(tap)
+	c.SetClock(&fixedClock{now: storeEpoch})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(ctx, createMessage())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, tap.Close())
+	records := recorder.Records()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, storeEpoch, records[0].Timestamp)
+	}
+}
@@ -1,9 +1,10 @@
 package lime
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -16,16 +17,29 @@ import (
 
 const DefaultReadLimit int64 = 8192 * 1024
 
+// DefaultStreamLimit is the maximum size of a streamed document if the TCPConfig StreamLimit is not defined.
+const DefaultStreamLimit int64 = 64 * 1024 * 1024
+
 type tcpTransport struct {
 	TCPConfig
 	conn          net.Conn
 	ctxConn       *ctxConn
-	encoder       *json.Encoder
-	decoder       *json.Decoder
+	encoder       Encoder
+	decoder       Decoder
+	pending       []rawEnvelope // pending are the envelopes remaining from a received array
+	codec         Codec
+	writer        io.Writer
 	limitedReader io.LimitedReader
+	readBuffer    *bufio.Reader // readBuffer holds the data read ahead from the connection, if enabled
+	traceWriter   TraceWriter
 	encryption    SessionEncryption
+	tlsConn       *tls.Conn // tlsConn is the connection after the TLS handshake
+	connEncrypted bool      // connEncrypted indicates that the encryption is defined by the connection, like in a TransportMux
 	server        bool
 	eof           bool
+	connMu        sync.RWMutex // connMu protects the conn and eof, which are checked concurrently through Connected
+	counters      transportCounters
+	unpublish     func()
 }
 
 // DialTcp opens a TCP  transport connection with the specified URI.
@@ -64,6 +78,9 @@ func (t *tcpTransport) SetCompression(_ context.Context, c SessionCompression) e
 }
 
 func (t *tcpTransport) SupportedEncryption() []SessionEncryption {
+	if t.connEncrypted {
+		return []SessionEncryption{t.encryption}
+	}
 	return []SessionEncryption{SessionEncryptionNone, SessionEncryptionTLS}
 }
 
@@ -90,7 +107,7 @@ func (t *tcpTransport) SetEncryption(ctx context.Context, e SessionEncryption) e
 	if t.server {
 		tlsConn = tls.Server(t.conn, t.TLSConfig)
 	} else {
-		tlsConn = tls.Client(t.conn, t.TLSConfig)
+		tlsConn = tls.Client(t.conn, t.clientTLSConfig())
 	}
 
 	var deadline time.Time
@@ -111,6 +128,7 @@ func (t *tcpTransport) SetEncryption(ctx context.Context, e SessionEncryption) e
 		return err
 	}
 
+	t.tlsConn = tlsConn
 	t.setConn(tlsConn)
 	t.encryption = SessionEncryptionTLS
 	return nil
@@ -131,6 +149,9 @@ func (t *tcpTransport) Send(ctx context.Context, e envelope) error {
 
 	t.ctxConn.SetWriteContext(ctx)
 
+	if t.WriteLimit > 0 {
+		return t.sendLimited(e)
+	}
 	if err := t.encoder.Encode(e); err != nil {
 		if errors.Is(err, io.EOF) {
 			t.eof = true
@@ -138,6 +159,27 @@ func (t *tcpTransport) Send(ctx context.Context, e envelope) error {
 		return fmt.Errorf("tcp transport: send: %w", err)
 	}
 
+	t.counters.envelopesEncoded.Add(1)
+	return nil
+}
+
+// sendLimited encodes the envelope in memory before writing it, to ensure that the write limit is not exceeded.
+func (t *tcpTransport) sendLimited(e envelope) error {
+	var buf bytes.Buffer
+	if err := t.codec.NewEncoder(&buf).Encode(e); err != nil {
+		return fmt.Errorf("tcp transport: send: %w", err)
+	}
+	if int64(buf.Len()) > t.WriteLimit {
+		return fmt.Errorf("tcp transport: send: %w", ErrWriteLimitExceeded)
+	}
+	if _, err := t.writer.Write(buf.Bytes()); err != nil {
+		if errors.Is(err, io.EOF) {
+			t.eof = true
+		}
+		return fmt.Errorf("tcp transport: send: %w", err)
+	}
+
+	t.counters.envelopesEncoded.Add(1)
 	return nil
 }
 
@@ -150,18 +192,77 @@ func (t *tcpTransport) Receive(ctx context.Context) (envelope, error) {
 		return nil, err
 	}
 
-	t.ctxConn.SetReadContext(ctx)
-
-	var raw rawEnvelope
-	if err := t.decoder.Decode(&raw); err != nil {
-		if errors.Is(err, io.EOF) {
-			t.eof = true
+	if len(t.pending) == 0 {
+		t.ctxConn.SetReadContext(ctx)
+
+		read := t.counters.bytesRead.Load()
+		var batch rawEnvelopeBatch
+		for len(batch) == 0 {
+			if err := t.decoder.Decode(&batch); err != nil {
+				if malformedErr := malformedEnvelope(err); malformedErr != nil {
+					t.limitedReader.N = t.ReadLimit
+					t.counters.decodeErrors.Add(1)
+					return nil, malformedErr
+				}
+				if errors.Is(err, io.EOF) {
+					t.connMu.Lock()
+					t.eof = true
+					t.connMu.Unlock()
+				}
+				t.counters.receiveError(err)
+				return nil, fmt.Errorf("tcp transport: receive: %w", err)
+			}
+			t.limitedReader.N = t.ReadLimit
+		}
+		t.pending = batch
+		if t.counters.bytesRead.Load() == read {
+			t.counters.bufferedReceives.Add(1)
 		}
-		return nil, fmt.Errorf("tcp transport: receive: %w", err)
+	} else {
+		t.counters.bufferedReceives.Add(1)
 	}
 
-	t.limitedReader.N = t.ReadLimit
-	return raw.toEnvelope()
+	raw := t.pending[0]
+	t.pending = t.pending[1:]
+	e, err := raw.toEnvelope()
+	if err != nil {
+		t.counters.decodeErrors.Add(1)
+		return nil, raw.populateError(err)
+	}
+	t.counters.envelopeDecoded(e, raw.size)
+	return e, nil
+}
+
+// Buffered returns the number of remaining envelopes of a received array or, if there are none, one if the next
+// envelope or array was already read ahead by the decoder and the read buffer. Only the data of the JSON codec is
+// inspected, and the read buffer is not if the gzip data is detected, since it may hold compressed data.
+func (t *tcpTransport) Buffered() int {
+	if len(t.pending) > 0 {
+		return len(t.pending)
+	}
+	if t.codec == nil || t.codec.Name() != CodecJSON.Name() {
+		return 0
+	}
+	var readers []io.Reader
+	if d, ok := t.decoder.(interface{ Buffered() io.Reader }); ok {
+		readers = append(readers, d.Buffered())
+	}
+	if t.readBuffer != nil && !t.DetectGzip {
+		b, _ := t.readBuffer.Peek(t.readBuffer.Buffered())
+		readers = append(readers, bytes.NewReader(b))
+	}
+	if hasJSONValue(io.MultiReader(readers...)) {
+		return 1
+	}
+	return 0
+}
+
+func (t *tcpTransport) Stats() TransportStats {
+	return t.counters.snapshot()
+}
+
+func (t *tcpTransport) annotateReceivedSizes() {
+	t.counters.annotateSizes.Store(true)
 }
 
 func (t *tcpTransport) Close() error {
@@ -170,15 +271,30 @@ func (t *tcpTransport) Close() error {
 	}
 
 	err := t.ctxConn.Close()
+	t.connMu.Lock()
 	t.conn = nil
+	t.connMu.Unlock()
+
+	if t.unpublish != nil {
+		t.unpublish()
+	}
+
+	// Dedicated trace writers are owned by the transport
+	if c, ok := t.traceWriter.(io.Closer); ok && t.NewTraceWriter != nil {
+		_ = c.Close()
+	}
 	return err
 }
 
 func (t *tcpTransport) Connected() bool {
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
 	return t.conn != nil && !t.eof
 }
 
 func (t *tcpTransport) LocalAddr() net.Addr {
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
 	if t.conn == nil {
 		return nil
 	}
@@ -186,6 +302,8 @@ func (t *tcpTransport) LocalAddr() net.Addr {
 }
 
 func (t *tcpTransport) RemoteAddr() net.Addr {
+	t.connMu.RLock()
+	defer t.connMu.RUnlock()
 	if t.conn == nil {
 		return nil
 	}
@@ -193,21 +311,60 @@ func (t *tcpTransport) RemoteAddr() net.Addr {
 }
 
 func (t *tcpTransport) setConn(conn net.Conn) {
+	t.connMu.Lock()
 	t.conn = conn
+	t.connMu.Unlock()
 	t.ctxConn = NewCtxConn(conn, 5*time.Second, 5*time.Second)
 
-	var writer io.Writer = t.ctxConn
-	var reader io.Reader = t.ctxConn
+	t.counters.clock = t.Clock
+	var writer io.Writer = &countingWriter{w: t.ctxConn, count: &t.counters.bytesWritten}
+	var reader io.Reader = &countingReader{r: t.ctxConn, count: &t.counters.bytesRead}
+	if t.ReadBufferSize > 0 {
+		t.readBuffer = bufio.NewReaderSize(reader, t.ReadBufferSize)
+		reader = t.readBuffer
+	} else {
+		t.readBuffer = nil
+	}
+
+	// Configure the bandwidth throttling, if defined
+	if t.WriteRateLimit > 0 {
+		ctxConn := t.ctxConn
+		writer = newThrottledWriter(writer, t.WriteRateLimit, t.Clock, func() context.Context { return ctxConn.writeCtx })
+	}
+	if t.ReadRateLimit > 0 {
+		ctxConn := t.ctxConn
+		reader = newThrottledReader(reader, t.ReadRateLimit, t.Clock, func() context.Context { return ctxConn.readCtx })
+	}
+	if t.DetectGzip {
+		reader = newGzipDetectingReader(reader)
+	}
 
 	// Configure the trace writer, if defined
-	tw := t.TraceWriter
+	if t.traceWriter == nil {
+		if t.NewTraceWriter != nil {
+			t.traceWriter = t.NewTraceWriter()
+		} else {
+			t.traceWriter = t.TraceWriter
+		}
+	}
+	tw := t.traceWriter
 	if tw != nil {
 		writer = io.MultiWriter(writer, *tw.SendWriter())
 		reader = io.TeeReader(reader, *tw.ReceiveWriter())
 	}
 
+	if t.codec == nil {
+		t.codec = CodecJSON
+	}
+
+	if t.PublishStats && t.unpublish == nil {
+		key := fmt.Sprintf("tcp %v->%v", conn.LocalAddr(), conn.RemoteAddr())
+		t.unpublish = PublishTransportStats(key, t)
+	}
+
 	// Sets the encoder to be used for sending envelopes
-	t.encoder = json.NewEncoder(writer)
+	t.writer = writer
+	t.encoder = t.codec.NewEncoder(writer)
 
 	if t.ReadLimit == 0 {
 		t.ReadLimit = DefaultReadLimit
@@ -220,7 +377,58 @@ func (t *tcpTransport) setConn(conn net.Conn) {
 		R: reader,
 		N: t.ReadLimit,
 	}
-	t.decoder = json.NewDecoder(&t.limitedReader)
+	t.decoder = t.newDecoder(t.codec, nil)
+}
+
+// newDecoder creates a decoder for the codec, which first reads the data buffered by the previous decoder.
+// The streaming decoder enforces the read limit by itself, since the streamed documents are not buffered.
+func (t *tcpTransport) newDecoder(c Codec, buffered []byte) Decoder {
+	if t.StreamThreshold > 0 && c.Name() == CodecJSON.Name() {
+		var reader io.Reader = t.limitedReader.R
+		if len(buffered) != 0 {
+			reader = io.MultiReader(bytes.NewReader(buffered), reader)
+		}
+		limit := t.StreamLimit
+		if limit <= 0 {
+			limit = DefaultStreamLimit
+		}
+		return newStreamingDecoder(reader, t.ReadLimit, t.StreamThreshold, limit, t.StreamDir)
+	}
+
+	var reader io.Reader = &t.limitedReader
+	if len(buffered) != 0 {
+		reader = io.MultiReader(bytes.NewReader(buffered), reader)
+	}
+	return c.NewDecoder(reader)
+}
+
+func (t *tcpTransport) Codec() Codec {
+	return t.codec
+}
+
+func (t *tcpTransport) SetCodec(_ context.Context, c Codec) error {
+	if c == nil {
+		panic("nil codec")
+	}
+	if err := t.ensureOpen(); err != nil {
+		return err
+	}
+
+	// The current decoder may have read ahead some bytes, which belong to the new codec stream.
+	// The leading whitespaces are discarded since they are the separators of the previous JSON values.
+	var buffered []byte
+	if d, ok := t.decoder.(interface{ Buffered() io.Reader }); ok {
+		b, err := io.ReadAll(d.Buffered())
+		if err != nil {
+			return err
+		}
+		buffered = bytes.TrimLeft(b, " \t\r\n")
+	}
+
+	t.codec = c
+	t.encoder = c.NewEncoder(t.writer)
+	t.decoder = t.newDecoder(c, buffered)
+	return nil
 }
 
 func (t *tcpTransport) ensureOpen() error {
@@ -248,9 +456,56 @@ func NewTCPTransportListener(config *TCPConfig) TransportListener {
 
 type TCPConfig struct {
 	ReadLimit   int64       // ReadLimit defines the limit for buffered data in read operations.
+	WriteLimit  int64       // WriteLimit defines the maximum encoded size of a sent envelope. The zero value means no limit.
 	TraceWriter TraceWriter // TraceWriter sets the trace writer for tracing connection envelopes
 	TLSConfig   *tls.Config
 	ConnBuffer  int
+
+	// ReadRateLimit defines the maximum rate, in bytes per second, for reading from the connection.
+	// The zero value means no limit.
+	ReadRateLimit int64
+	// WriteRateLimit defines the maximum rate, in bytes per second, for writing to the connection.
+	// The zero value means no limit.
+	WriteRateLimit int64
+
+	// NewTraceWriter creates a dedicated trace writer for each connection, taking precedence over TraceWriter.
+	// If the created writer implements io.Closer, it is closed with the transport.
+	NewTraceWriter func() TraceWriter
+
+	// StreamThreshold defines the size, in bytes, above which the received message contents and command resources
+	// are written to temporary files and exposed as StreamedDocument values, instead of being buffered in memory.
+	// The streamed documents are not subject to the ReadLimit. The zero value disables the streaming, which is
+	// only supported by the JSON codec.
+	StreamThreshold int64
+	// StreamLimit defines the maximum size of a streamed document, which limits the disk usage of each connection.
+	// If not defined, the DefaultStreamLimit is used.
+	StreamLimit int64
+	// StreamDir is the directory for the streamed document files. If empty, the default temporary directory is used.
+	StreamDir string
+
+	// PublishStats enables the publication of the connection counters in the 'lime.transports' expvar map, keyed by
+	// the connection addresses. The entry is removed when the transport is closed.
+	PublishStats bool
+
+	// ReadBufferSize defines the size of the buffer for reading from the connection, which allows the envelopes
+	// packed by the peer in a single network segment to be received without more reads from the connection. The
+	// zero value disables the buffer.
+	ReadBufferSize int
+
+	// DetectGzip enables the decompression of the gzip data received from the peers that compress the envelopes
+	// without negotiating the session compression. The envelopes are detected by the gzip magic bytes, which are
+	// not valid in the JSON data.
+	DetectGzip bool
+
+	// TLSSessionCache stores the TLS sessions of the dialed connections, allowing the handshakes of the reconnections
+	// to be resumed. It is only used if the TLSConfig does not define a ClientSessionCache and should not be shared
+	// by the configs with distinct client certificates or server names, since a resumed session keeps the identity
+	// of the original handshake. If nil, the sessions are not resumed.
+	TLSSessionCache tls.ClientSessionCache
+
+	// Clock measures the rate limits and the window of the largest received envelopes. If not defined, the
+	// SystemClock is used.
+	Clock Clock
 }
 
 var defaultTCPConfig = TCPConfig{}
@@ -277,7 +532,9 @@ func (l *tcpTransportListener) Listen(ctx context.Context, addr net.Addr) error
 	l.done = make(chan struct{})
 	l.connChan = make(chan net.Conn, l.ConnBuffer)
 
-	go l.serve(listener)
+	goLabeled(context.Background(), "tcp.listener", func(context.Context) {
+		l.serve(listener)
+	}, "addr", listener.Addr().String())
 
 	return nil
 }
@@ -319,17 +576,29 @@ func (l *tcpTransportListener) Accept(ctx context.Context) (Transport, error) {
 		if !ok {
 			return nil, errors.New("tcp listener not serving")
 		}
+		l.mu.RLock()
+		config := l.TCPConfig
+		l.mu.RUnlock()
 		transport := tcpTransport{
-			TCPConfig:  l.TCPConfig,
+			TCPConfig:  config,
 			encryption: SessionEncryptionNone,
 		}
 		transport.server = true
-		transport.ReadLimit = l.ReadLimit
 		transport.setConn(conn)
 		return &transport, nil
 	}
 }
 
+// setLimits changes the limits of the connections accepted after the call.
+func (l *tcpTransportListener) setLimits(readLimit, writeLimit, readRateLimit, writeRateLimit int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ReadLimit = readLimit
+	l.WriteLimit = writeLimit
+	l.ReadRateLimit = readRateLimit
+	l.WriteRateLimit = writeRateLimit
+}
+
 func (l *tcpTransportListener) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -1,6 +1,8 @@
 package lime
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -9,6 +11,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/goleak"
 	"golang.org/x/sync/errgroup"
@@ -454,6 +457,72 @@ func TestTCPTransport_Receive_Session(t *testing.T) {
 	assert.Equal(t, s, received)
 }
 
+func TestTCPTransport_Stats(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	var transportChan = make(chan Transport, 1)
+	listener := createTCPListener(t, addr, transportChan)
+	defer silentClose(listener)
+	client := createClientTCPTransport(t, createLocalhostTCPAddress())
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	if err := client.Send(ctx, createSession()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	clientStats := client.(StatsTransport).Stats()
+	serverStats := server.(StatsTransport).Stats()
+
+	// Assert
+	assert.Equal(t, int64(1), clientStats.EnvelopesEncoded)
+	assert.Equal(t, int64(1), serverStats.EnvelopesDecoded)
+	assert.Greater(t, clientStats.BytesWritten, int64(0))
+	assert.Equal(t, clientStats.BytesWritten, serverStats.BytesRead)
+	assert.Zero(t, serverStats.DecodeErrors)
+}
+
+func TestTCPTransport_Stats_EnvelopeSizes(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	var transportChan = make(chan Transport, 1)
+	listener := createTCPListener(t, addr, transportChan)
+	defer silentClose(listener)
+	client := createClientTCPTransport(t, createLocalhostTCPAddress())
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	msg := createMessage()
+	if err := client.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	stats := server.(StatsTransport).Stats()
+
+	// Assert
+	assert.Equal(t, int64(1), stats.EnvelopeSizes[0]+stats.EnvelopeSizes[1])
+	if assert.Len(t, stats.LargestEnvelopes, 1) {
+		largest := stats.LargestEnvelopes[0]
+		assert.Equal(t, "message", largest.Type)
+		assert.Equal(t, msg.ID, largest.ID)
+		assert.Equal(t, msg.From, largest.From)
+		assert.Greater(t, largest.Size, int64(0))
+		assert.LessOrEqual(t, largest.Size, stats.BytesRead)
+	}
+}
+
 func TestTCPTransport_Receive_SessionTLS(t *testing.T) {
 	// Arrange
 	defer goleak.VerifyNone(t)
@@ -600,3 +669,203 @@ func BenchmarkTCPTransport_Send_MessageTLS(b *testing.B) {
 func silentClose(c io.Closer) {
 	_ = c.Close()
 }
+
+func TestTCPTransport_Receive_EnvelopeArray(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	var transportChan = make(chan Transport, 1)
+	listener := createTCPListener(t, addr, transportChan)
+	defer silentClose(listener)
+	client := createClientTCPTransport(t, createLocalhostTCPAddress())
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	m := createMessage()
+	n := createNotification()
+	mb, _ := m.MarshalJSON()
+	nb, _ := n.MarshalJSON()
+	frame := "[" + string(mb) + "," + string(nb) + "][]" + string(mb)
+	if _, err := client.(*tcpTransport).conn.Write([]byte(frame)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	e1, err1 := server.Receive(ctx)
+	e2, err2 := server.Receive(ctx)
+	e3, err3 := server.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+	assert.Equal(t, m, e1)
+	assert.Equal(t, n, e2)
+	assert.Equal(t, m, e3)
+}
+
+func TestTCPTransport_Buffered(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	var transportChan = make(chan Transport, 1)
+	listener := NewTCPTransportListener(&TCPConfig{ReadBufferSize: 4096})
+	if err := listener.Listen(context.Background(), addr); err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(listener)
+	listenTransports(transportChan, listener)
+	client := createClientTCPTransport(t, createLocalhostTCPAddress())
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	n := createNotification()
+	nb, _ := n.MarshalJSON()
+	frame := string(nb) + "\n" + string(nb) + "\n" + string(nb)
+	if _, err := client.(*tcpTransport).conn.Write([]byte(frame)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(16 * time.Millisecond)
+	buffered := server.(BufferedTransport)
+
+	// Act
+	before := buffered.Buffered()
+	_, err1 := server.Receive(ctx)
+	after1 := buffered.Buffered()
+	_, err2 := server.Receive(ctx)
+	_, err3 := server.Receive(ctx)
+	after3 := buffered.Buffered()
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+	assert.Equal(t, 0, before)
+	assert.Equal(t, 1, after1)
+	assert.Equal(t, 0, after3)
+	stats := server.(StatsTransport).Stats()
+	assert.Equal(t, int64(3), stats.EnvelopesDecoded)
+	assert.Equal(t, int64(2), stats.BufferedReceives)
+}
+
+func TestTCPTransport_Receive_DetectGzip(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	listener := NewTCPTransportListener(&TCPConfig{DetectGzip: true})
+	if err := listener.Listen(context.Background(), addr); err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(listener)
+	client := createClientTCPTransport(t, addr)
+	defer silentClose(client)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	server, err := listener.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(server)
+	m := createMessage()
+	mb, _ := m.MarshalJSON()
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, _ = w.Write(mb)
+	_ = w.Close()
+	if _, err := client.(*tcpTransport).conn.Write(append(mb, compressed.Bytes()...)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	e1, err1 := server.Receive(ctx)
+	e2, err2 := server.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, m, e1)
+	assert.Equal(t, m, e2)
+}
+
+func TestTCPTransport_Receive_MalformedEnvelope(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	var transportChan = make(chan Transport, 1)
+	listener := createTCPListener(t, addr, transportChan)
+	defer silentClose(listener)
+	client := createClientTCPTransport(t, createLocalhostTCPAddress())
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	m := createMessage()
+	mb, _ := m.MarshalJSON()
+	frame := `{"id":"1","type":"application/json","content":"text"}{"id":"2","type":5,"content":"text"}` + string(mb)
+	if _, err := client.(*tcpTransport).conn.Write([]byte(frame)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	_, err1 := server.Receive(ctx)
+	_, err2 := server.Receive(ctx)
+	e3, err3 := server.Receive(ctx)
+
+	// Assert
+	var malformedErr1, malformedErr2 *MalformedEnvelopeError
+	if assert.True(t, errors.As(err1, &malformedErr1)) {
+		assert.JSONEq(t, `{"id":"1","type":"application/json","content":"text"}`, string(malformedErr1.Raw))
+	}
+	if assert.True(t, errors.As(err2, &malformedErr2)) {
+		assert.JSONEq(t, `{"id":"2","type":5,"content":"text"}`, string(malformedErr2.Raw))
+		var envErr *EnvelopeError
+		assert.True(t, errors.As(err2, &envErr))
+		assert.Equal(t, "type", envErr.Field)
+	}
+	assert.NoError(t, err3)
+	assert.Equal(t, m, e3)
+	assert.Equal(t, int64(2), server.(*tcpTransport).Stats().DecodeErrors)
+}
+
+func TestChannel_OnMalformedEnvelope(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	var transportChan = make(chan Transport, 1)
+	listener := createTCPListener(t, addr, transportChan)
+	defer silentClose(listener)
+	client := createClientTCPTransport(t, createLocalhostTCPAddress())
+	c := newChannel(receiveTransport(t, transportChan), 1)
+	defer silentClose(c)
+	// The client is closed first, so the channel receiver stops without waiting for the read timeout
+	defer silentClose(client)
+	malformed := make(chan *MalformedEnvelopeError, 1)
+	c.OnMalformedEnvelope(func(ctx context.Context, err *MalformedEnvelopeError) {
+		malformed <- err
+	})
+	c.setState(SessionStateEstablished)
+	m := createMessage()
+	mb, _ := m.MarshalJSON()
+	frame := `{"id":"1","type":"application/json","content":"text"}` + string(mb)
+
+	// Act
+	if _, err := client.(*tcpTransport).conn.Write([]byte(frame)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert
+	select {
+	case err := <-malformed:
+		assert.JSONEq(t, `{"id":"1","type":"application/json","content":"text"}`, string(err.Raw))
+	case <-time.After(250 * time.Millisecond):
+		t.Fatal("timeout")
+	}
+	select {
+	case actual := <-c.MsgChan():
+		assert.Equal(t, m, actual)
+	case <-time.After(250 * time.Millisecond):
+		t.Fatal("timeout")
+	}
+}
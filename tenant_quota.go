@@ -0,0 +1,189 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TenantFunc returns the tenant of a session identity, which groups the usage counters of a TenantMeter.
+type TenantFunc func(id Identity) string
+
+// TenantByIdentity is a TenantFunc that considers each identity a tenant.
+func TenantByIdentity(id Identity) string {
+	return id.String()
+}
+
+// TenantByDomain is a TenantFunc that considers each domain a tenant, like the domains of the bots hosted in a
+// server.
+func TenantByDomain(id Identity) string {
+	return id.Domain
+}
+
+// TenantUsage holds the counters of the envelopes received from the sessions of a tenant in a day.
+type TenantUsage struct {
+	Tenant        string    `json:"tenant"`
+	Day           time.Time `json:"day"` // Day is the start of the UTC day of the counters.
+	Messages      int64     `json:"messages"`
+	Notifications int64     `json:"notifications"`
+	Commands      int64     `json:"commands"`
+	// Bytes is the encoded size of the envelopes, as counted by the transports that keep traffic counters, like the
+	// TCP and websocket ones.
+	Bytes int64 `json:"bytes"`
+}
+
+// QuotaFunc decides if a received message or request command is accepted, given the usage of the tenant that
+// includes it, returning the reason for rejecting it or nil.
+type QuotaFunc func(ctx context.Context, usage TenantUsage) *Reason
+
+// DailyQuota returns a QuotaFunc that rejects the envelopes after the tenant exceeds the number of messages or the
+// bytes in the day, with the ReasonCodeAuthorizationQuotaExceeded code. The zero values mean no limit.
+func DailyQuota(maxMessages, maxBytes int64) QuotaFunc {
+	return func(_ context.Context, usage TenantUsage) *Reason {
+		if maxMessages > 0 && usage.Messages > maxMessages {
+			return NewReason(ReasonCodeAuthorizationQuotaExceeded, fmt.Sprintf("The daily quota of %v messages was exceeded", maxMessages))
+		}
+		if maxBytes > 0 && usage.Bytes > maxBytes {
+			return NewReason(ReasonCodeAuthorizationQuotaExceeded, fmt.Sprintf("The daily quota of %v bytes was exceeded", maxBytes))
+		}
+		return nil
+	}
+}
+
+// TenantMeter counts the envelopes received by a server from each tenant and enforces their quotas, for the hosting
+// of multiple tenants, like bots, in the same server.
+type TenantMeter struct {
+	tenant TenantFunc
+	quota  QuotaFunc
+	clock  Clock
+	mu     sync.Mutex
+	day    time.Time               // day is the day of the usages
+	usages map[string]*TenantUsage // usages are the counters of the tenants with envelopes in the day
+}
+
+// NewTenantMeter creates a TenantMeter that groups the counters with the tenant function and enforces the quota,
+// which can be nil for only counting the usage.
+func NewTenantMeter(tenant TenantFunc, quota QuotaFunc) *TenantMeter {
+	if tenant == nil {
+		panic("nil tenant func")
+	}
+	return &TenantMeter{
+		tenant: tenant,
+		quota:  quota,
+		clock:  SystemClock,
+		usages: make(map[string]*TenantUsage),
+	}
+}
+
+// SetClock defines the clock for determining the day of the counters.
+func (m *TenantMeter) SetClock(clock Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = clockOrSystem(clock)
+}
+
+// Usage returns the counters of the tenant in the current day.
+func (m *TenantMeter) Usage(tenant string) TenantUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+	if u, ok := m.usages[tenant]; ok {
+		return *u
+	}
+	return TenantUsage{Tenant: tenant, Day: m.day}
+}
+
+// Usages returns the counters of the tenants with envelopes in the current day, sorted by tenant.
+func (m *TenantMeter) Usages() []TenantUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+	usages := make([]TenantUsage, 0, len(m.usages))
+	for _, u := range m.usages {
+		usages = append(usages, *u)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Tenant < usages[j].Tenant
+	})
+	return usages
+}
+
+func (m *TenantMeter) today() time.Time {
+	return m.clock.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// expire removes the counters of the previous days, so the tenants without envelopes in the current day are not
+// kept.
+func (m *TenantMeter) expire() {
+	if day := m.today(); !day.Equal(m.day) {
+		m.day = day
+		m.usages = make(map[string]*TenantUsage)
+	}
+}
+
+// usage returns the counters of the tenant in the current day.
+func (m *TenantMeter) usage(tenant string) *TenantUsage {
+	m.expire()
+	u, ok := m.usages[tenant]
+	if !ok {
+		u = &TenantUsage{Tenant: tenant, Day: m.day}
+		m.usages[tenant] = u
+	}
+	return u
+}
+
+// record counts the received envelope of the session identity with its encoded size, returning the reason for
+// rejecting it if the quota of the tenant was exceeded. The notifications and response commands are only counted.
+func (m *TenantMeter) record(ctx context.Context, id Identity, e envelope, size int64) *Reason {
+	m.mu.Lock()
+	u := m.usage(m.tenant(id))
+	u.Bytes += size
+	enforce := false
+	switch e.(type) {
+	case *Message:
+		u.Messages++
+		enforce = true
+	case *Notification:
+		u.Notifications++
+	case *RequestCommand:
+		u.Commands++
+		enforce = true
+	case *ResponseCommand:
+		u.Commands++
+	}
+	usage := *u
+	m.mu.Unlock()
+
+	if !enforce || m.quota == nil {
+		return nil
+	}
+	return m.quota(ctx, usage)
+}
+
+// MeterTenants enables the counting of the received envelopes by tenant and the enforcement of its quotas.
+// The messages and request commands of the tenants that exceeded the quota are replied with a failure with the
+// reason returned by the quota function.
+func (m *EnvelopeMux) MeterTenants(meter *TenantMeter) {
+	if meter == nil {
+		panic("nil meter")
+	}
+	m.meter = meter
+}
+
+// startMetering makes the channel transport annotate the received envelopes with their size, for counting the
+// bytes of the tenants.
+func (m *EnvelopeMux) startMetering(c *channel) {
+	if m.meter != nil {
+		annotateReceivedSizes(c.transport)
+	}
+}
+
+// meterUsage returns the reason for rejecting the envelope, or nil if the tenant of the session is within the quota.
+func (m *EnvelopeMux) meterUsage(ctx context.Context, c *channel, e envelope) *Reason {
+	if m.meter == nil {
+		return nil
+	}
+	return m.meter.record(ctx, c.remoteNode.Identity, e, receivedSize(e))
+}
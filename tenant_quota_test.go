@@ -0,0 +1,143 @@
+package lime
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+// fixedClock is a Clock whose time is only changed by the test.
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fixedClock) NewTimer(d time.Duration) Timer {
+	return SystemClock.NewTimer(d)
+}
+
+func TestTenantMeter_Record(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	meter := NewTenantMeter(TenantByDomain, nil)
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	meter.SetClock(clock)
+	bot1 := Identity{Name: "bot1", Domain: "tenant1.com"}
+	bot2 := Identity{Name: "bot2", Domain: "tenant1.com"}
+	other := Identity{Name: "bot1", Domain: "tenant2.com"}
+
+	// Act
+	r1 := meter.record(ctx, bot1, createMessage(), 120)
+	r2 := meter.record(ctx, bot2, createNotification(), 80)
+	r3 := meter.record(ctx, other, createGetPingCommand(), 60)
+
+	// Assert
+	assert.Nil(t, r1)
+	assert.Nil(t, r2)
+	assert.Nil(t, r3)
+	usages := meter.Usages()
+	if assert.Len(t, usages, 2) {
+		assert.Equal(t, "tenant1.com", usages[0].Tenant)
+		assert.Equal(t, int64(1), usages[0].Messages)
+		assert.Equal(t, int64(1), usages[0].Notifications)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), usages[0].Day)
+		assert.Equal(t, int64(200), usages[0].Bytes)
+		assert.Equal(t, "tenant2.com", usages[1].Tenant)
+		assert.Equal(t, int64(1), usages[1].Commands)
+	}
+}
+
+func TestTenantMeter_Record_QuotaExceeded(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	meter := NewTenantMeter(TenantByIdentity, DailyQuota(2, 0))
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	meter.SetClock(clock)
+	bot := Identity{Name: "bot1", Domain: "tenant1.com"}
+
+	// Act
+	r1 := meter.record(ctx, bot, createMessage(), 0)
+	r2 := meter.record(ctx, bot, createMessage(), 0)
+	r3 := meter.record(ctx, bot, createMessage(), 0)
+	clock.now = clock.now.Add(24 * time.Hour)
+	r4 := meter.record(ctx, bot, createMessage(), 0)
+
+	// Assert
+	assert.Nil(t, r1)
+	assert.Nil(t, r2)
+	if assert.NotNil(t, r3) {
+		assert.Equal(t, ReasonCodeAuthorizationQuotaExceeded, r3.Code)
+	}
+	assert.Nil(t, r4)
+	assert.Equal(t, int64(1), meter.Usage("bot1@tenant1.com").Messages)
+}
+
+func TestTenantMeter_Record_ExpireIdleTenants(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	meter := NewTenantMeter(TenantByIdentity, nil)
+	clock := &fixedClock{now: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}
+	meter.SetClock(clock)
+	idle := Identity{Name: "bot1", Domain: "tenant1.com"}
+	active := Identity{Name: "bot2", Domain: "tenant1.com"}
+	_ = meter.record(ctx, idle, createMessage(), 0)
+	_ = meter.record(ctx, active, createMessage(), 0)
+	clock.now = clock.now.Add(24 * time.Hour)
+
+	// Act
+	_ = meter.record(ctx, active, createMessage(), 0)
+	usage := meter.Usage("unknown@tenant1.com")
+
+	// Assert
+	assert.Len(t, meter.usages, 1)
+	assert.Contains(t, meter.usages, "bot2@tenant1.com")
+	assert.Zero(t, usage.Messages)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), usage.Day)
+}
+
+func TestEnvelopeMux_ListenServer_RejectQuotaExceeded(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.remoteNode = Node{Identity: Identity{Name: "bot1", Domain: "tenant1.com"}}
+	c.setState(SessionStateEstablished)
+	mux := &EnvelopeMux{}
+	mux.MeterTenants(NewTenantMeter(TenantByDomain, DailyQuota(0, 1)))
+	handled := false
+	mux.MessageHandlerFunc(
+		func(msg *Message) bool { return true },
+		func(ctx context.Context, msg *Message, s Sender) error {
+			handled = true
+			return nil
+		})
+	msg := createMessage()
+	// The in-process transport doesn't serialize the envelopes, so the size is defined as counted by the other ones
+	msg.Annotate(receivedSizeKey{}, int64(120))
+	if err := client.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = mux.listen(ctx, c)
+	}()
+
+	// Act
+	env, err := client.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	cancel()
+	assert.False(t, handled)
+	if not, ok := env.(*Notification); assert.True(t, ok) {
+		assert.Equal(t, NotificationEventFailed, not.Event)
+		assert.Equal(t, ReasonCodeAuthorizationQuotaExceeded, not.Reason.Code)
+	}
+}
@@ -0,0 +1,120 @@
+package lime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MetadataKeyPart is the metadata key that holds the position of a message in the sequence of messages created
+	// from a split text, like '2/3' for the second of three parts.
+	MetadataKeyPart = "#part"
+	// MetadataKeyPartOf is the metadata key that holds the identifier of the first message of the sequence of
+	// messages created from a split text, which is the same in all of its parts.
+	MetadataKeyPartOf = "#partOf"
+)
+
+// NewTextMessage creates a message with a TextDocument content and a new identifier, addressed to the node.
+func NewTextMessage(to Node, text string) *Message {
+	d := TextDocument(text)
+	msg := &Message{}
+	msg.SetContent(&d).
+		SetTo(to).
+		SetNewEnvelopeID()
+	return msg
+}
+
+// Text returns the text of the message content, if it is a TextDocument.
+func (msg *Message) Text() (string, bool) {
+	switch d := msg.Content.(type) {
+	case *TextDocument:
+		if d == nil {
+			return "", false
+		}
+		return string(*d), true
+	case TextDocument:
+		return string(d), true
+	}
+	return "", false
+}
+
+// NewTextMessages creates the messages for the text addressed to the node, splitting it in parts of up to limit
+// characters if needed, since the destination channels often cap the text length.
+// If split, the messages have the MetadataKeyPart and MetadataKeyPartOf metadata and must be sent in order. The
+// parts keep all the text characters, so the original text is the concatenation of their contents.
+func NewTextMessages(to Node, text string, limit int) []*Message {
+	parts := SplitText(text, limit)
+	if len(parts) == 1 {
+		return []*Message{NewTextMessage(to, text)}
+	}
+	messages := make([]*Message, len(parts))
+	for i, part := range parts {
+		messages[i] = NewTextMessage(to, part)
+	}
+	for i, msg := range messages {
+		msg.SetMetadataKeyValue(MetadataKeyPart, fmt.Sprintf("%d/%d", i+1, len(parts)))
+		msg.SetMetadataKeyValue(MetadataKeyPartOf, messages[0].ID)
+	}
+	return messages
+}
+
+// Part returns the position and the count of the parts of the sequence of messages created from a split text that
+// the envelope belongs to, if defined.
+func (env *Envelope) Part() (index int, total int, ok bool) {
+	value, found := env.Metadata[MetadataKeyPart]
+	if !found {
+		return 0, 0, false
+	}
+	i, t, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, false
+	}
+	index, err := strconv.Atoi(i)
+	if err != nil {
+		return 0, 0, false
+	}
+	total, err = strconv.Atoi(t)
+	if err != nil || index < 1 || index > total {
+		return 0, 0, false
+	}
+	return index, total, true
+}
+
+// PartOf returns the identifier of the first message of the sequence of messages created from a split text that the
+// envelope belongs to, if defined.
+func (env *Envelope) PartOf() string {
+	return env.Metadata[MetadataKeyPartOf]
+}
+
+// SplitText splits the text in parts of up to limit characters, preferring to break after a whitespace, so the
+// words are not split unless they are longer than the limit. The parts are not trimmed, so the text is the
+// concatenation of them.
+func SplitText(text string, limit int) []string {
+	if limit <= 0 {
+		panic("the limit should be positive")
+	}
+	var parts []string
+	// start is the byte offset of the current part, and cut is the offset after its last whitespace, or start if
+	// there is none. count is the number of characters of the part, and tail the ones after the cut.
+	var start, cut, count, tail int
+	for i := 0; i < len(text); {
+		if count == limit {
+			if cut == start {
+				cut, tail = i, 0
+			}
+			parts = append(parts, text[start:cut])
+			start, count, tail = cut, tail, 0
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		count++
+		tail++
+		if unicode.IsSpace(r) {
+			cut, tail = i, 0
+		}
+	}
+	return append(parts, text[start:])
+}
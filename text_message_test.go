@@ -0,0 +1,123 @@
+package lime
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{"Short", "Hello world", 20, []string{"Hello world"}},
+		{"Exact", "Hello world", 11, []string{"Hello world"}},
+		{"Empty", "", 5, []string{""}},
+		{"Words", "Hello big world", 10, []string{"Hello big ", "world"}},
+		{"LongWord", "Supercalifragilistic", 8, []string{"Supercal", "ifragili", "stic"}},
+		{"Multibyte", "ação é rápida", 6, []string{"ação ", "é ", "rápida"}},
+		{"Newlines", "first line\nsecond", 12, []string{"first line\n", "second"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			actual := SplitText(tt.text, tt.limit)
+
+			// Assert
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.text, strings.Join(actual, ""))
+		})
+	}
+}
+
+func TestSplitText_InvalidLimit(t *testing.T) {
+	// Act & Assert
+	assert.Panics(t, func() {
+		SplitText("Hello", 0)
+	})
+}
+
+func TestNewTextMessages(t *testing.T) {
+	// Arrange
+	to := ParseNode("golang@limeprotocol.org/default")
+
+	// Act
+	messages := NewTextMessages(to, "Hello big world", 10)
+
+	// Assert
+	if assert.Len(t, messages, 2) {
+		for i, msg := range messages {
+			assert.NotEmpty(t, msg.ID)
+			assert.Equal(t, to, msg.To)
+			assert.Equal(t, MediaTypeTextPlain(), msg.Type)
+			index, total, ok := msg.Part()
+			assert.True(t, ok)
+			assert.Equal(t, i+1, index)
+			assert.Equal(t, 2, total)
+			assert.Equal(t, messages[0].ID, msg.PartOf())
+		}
+		assert.NotEqual(t, messages[0].ID, messages[1].ID)
+		first, _ := messages[0].Text()
+		second, _ := messages[1].Text()
+		assert.Equal(t, "Hello big ", first)
+		assert.Equal(t, "world", second)
+	}
+}
+
+func TestNewTextMessages_NotSplit(t *testing.T) {
+	// Act
+	messages := NewTextMessages(Node{}, "Hello", 10)
+
+	// Assert
+	if assert.Len(t, messages, 1) {
+		_, _, ok := messages[0].Part()
+		assert.False(t, ok)
+		assert.Empty(t, messages[0].PartOf())
+	}
+}
+
+func TestMessage_Text(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  Document
+		expected string
+		ok       bool
+	}{
+		{"Pointer", func() Document { d := TextDocument("Hello"); return &d }(), "Hello", true},
+		{"Value", TextDocument("Hello"), "Hello", true},
+		{"Json", &JsonDocument{"text": "Hello"}, "", false},
+		{"Nil", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			msg := &Message{Content: tt.content}
+
+			// Act
+			actual, ok := msg.Text()
+
+			// Assert
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestEnvelope_Part_Invalid(t *testing.T) {
+	for _, value := range []string{"1", "a/2", "3/2", "0/2", "1/b"} {
+		t.Run(value, func(t *testing.T) {
+			// Arrange
+			msg := createMessage()
+			msg.SetMetadataKeyValue(MetadataKeyPart, value)
+
+			// Act
+			_, _, ok := msg.Part()
+
+			// Assert
+			assert.False(t, ok)
+		})
+	}
+}
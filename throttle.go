@@ -0,0 +1,123 @@
+package lime
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket implements a rate limiter for the bytes that flow through a connection.
+// The bucket capacity is equivalent to 100 milliseconds of traffic.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // rate is the amount of tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  Clock
+}
+
+func newTokenBucket(bytesPerSecond int64, clock Clock) *tokenBucket {
+	if bytesPerSecond <= 0 {
+		panic("the rate should be positive")
+	}
+	rate := float64(bytesPerSecond)
+	burst := math.Max(1, rate/10)
+	clock = clockOrSystem(clock)
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   clock.Now(),
+		clock:  clock,
+	}
+}
+
+// maxChunk returns the greater amount of bytes that can be consumed at once.
+func (b *tokenBucket) maxChunk() int {
+	return int(b.burst)
+}
+
+// wait blocks until n tokens are available or the context is done.
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	for n > 0 {
+		chunk := math.Min(float64(n), b.burst)
+
+		b.mu.Lock()
+		now := b.clock.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= chunk {
+			b.tokens -= chunk
+			b.mu.Unlock()
+			n -= int(chunk)
+			continue
+		}
+		delay := time.Duration((chunk - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if err := sleep(ctx, b.clock, delay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// throttledReader limits the reading rate of the underlying reader.
+type throttledReader struct {
+	r      io.Reader
+	bucket *tokenBucket
+	ctx    func() context.Context
+}
+
+func newThrottledReader(r io.Reader, bytesPerSecond int64, clock Clock, ctx func() context.Context) *throttledReader {
+	return &throttledReader{r: r, bucket: newTokenBucket(bytesPerSecond, clock), ctx: ctx}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if max := t.bucket.maxChunk(); len(p) > max {
+		p = p[:max]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		// Charges the read bytes, delaying the next read
+		if waitErr := t.bucket.wait(t.ctx(), n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// throttledWriter limits the writing rate to the underlying writer.
+type throttledWriter struct {
+	w      io.Writer
+	bucket *tokenBucket
+	ctx    func() context.Context
+}
+
+func newThrottledWriter(w io.Writer, bytesPerSecond int64, clock Clock, ctx func() context.Context) *throttledWriter {
+	return &throttledWriter{w: w, bucket: newTokenBucket(bytesPerSecond, clock), ctx: ctx}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	max := t.bucket.maxChunk()
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > max {
+			chunk = chunk[:max]
+		}
+		if err := t.bucket.wait(t.ctx(), len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,58 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestThrottledWriter_Write(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	w := newThrottledWriter(&buf, 100_000, SystemClock, context.Background)
+	data := make([]byte, 30_000)
+	start := time.Now()
+
+	// Act
+	n, err := w.Write(data)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, len(data), n)
+	assert.Equal(t, len(data), buf.Len())
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestThrottledWriter_Write_WhenContextCanceled(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	w := newThrottledWriter(&buf, 10_000, SystemClock, func() context.Context { return ctx })
+	data := make([]byte, 10_000)
+
+	// Act
+	n, err := w.Write(data)
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, n, len(data))
+}
+
+func TestThrottledReader_Read(t *testing.T) {
+	// Arrange
+	data := make([]byte, 30_000)
+	r := newThrottledReader(bytes.NewReader(data), 100_000, SystemClock, context.Background)
+	start := time.Now()
+
+	// Act
+	actual, err := io.ReadAll(r)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, actual, len(data))
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
@@ -0,0 +1,38 @@
+package lime
+
+import (
+	"crypto/tls"
+)
+
+// TLSTransport is implemented by the transports that can be encrypted with TLS.
+type TLSTransport interface {
+	Transport
+	// TLSConnectionState returns the state of the TLS connection, like if the handshake was resumed from a cached
+	// session, or false if the transport is not encrypted.
+	TLSConnectionState() (tls.ConnectionState, bool)
+}
+
+// clientTLSConfig returns the TLS configuration for a dialed connection, with the session cache, if defined.
+func (c *TCPConfig) clientTLSConfig() *tls.Config {
+	if c.TLSSessionCache == nil || c.TLSConfig.ClientSessionCache != nil {
+		return c.TLSConfig
+	}
+	config := c.TLSConfig.Clone()
+	config.ClientSessionCache = c.TLSSessionCache
+	return config
+}
+
+func (t *tcpTransport) TLSConnectionState() (tls.ConnectionState, bool) {
+	if t.tlsConn == nil {
+		return tls.ConnectionState{}, false
+	}
+	return t.tlsConn.ConnectionState(), true
+}
+
+// TLSConnectionState returns the state of the TLS connection of the channel transport, if it is encrypted.
+func (c *channel) TLSConnectionState() (tls.ConnectionState, bool) {
+	if t, ok := c.transport.(TLSTransport); ok {
+		return t.TLSConnectionState()
+	}
+	return tls.ConnectionState{}, false
+}
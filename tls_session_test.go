@@ -0,0 +1,93 @@
+package lime
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"testing"
+	"time"
+)
+
+// connectTLS dials an encrypted transport, receiving an envelope from the server, which allows the client to
+// process the session tickets.
+func connectTLS(t *testing.T, ctx context.Context, transportChan chan Transport, config *TCPConfig) (tls.ConnectionState, bool) {
+	client, err := DialTcp(ctx, createLocalhostTCPAddress(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+	defer silentClose(client)
+	if err = doTLSHandshake(ctx, server, client); err != nil {
+		t.Fatal(err)
+	}
+	if err = server.Send(ctx, createMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return client.(TLSTransport).TLSConnectionState()
+}
+
+func TestTCPTransport_TLSSessionCache(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	transportChan := make(chan Transport, 1)
+	listener := createTCPListenerTLS(t, createLocalhostTCPAddress(), transportChan)
+	defer silentClose(listener)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	config := &TCPConfig{
+		TLSConfig:       &tls.Config{ServerName: "127.0.0.1", InsecureSkipVerify: true},
+		TLSSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+
+	// Act
+	state1, ok1 := connectTLS(t, ctx, transportChan, config)
+	state2, ok2 := connectTLS(t, ctx, transportChan, config)
+
+	// Assert
+	assert.True(t, ok1)
+	assert.False(t, state1.DidResume)
+	assert.True(t, ok2)
+	assert.True(t, state2.DidResume)
+	assert.Nil(t, config.TLSConfig.ClientSessionCache)
+}
+
+func TestTCPTransport_TLSSessionCache_NotDefined(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	transportChan := make(chan Transport, 1)
+	listener := createTCPListenerTLS(t, createLocalhostTCPAddress(), transportChan)
+	defer silentClose(listener)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	config := &TCPConfig{
+		TLSConfig: &tls.Config{ServerName: "127.0.0.1", InsecureSkipVerify: true},
+	}
+
+	// Act
+	_, _ = connectTLS(t, ctx, transportChan, config)
+	state, ok := connectTLS(t, ctx, transportChan, config)
+
+	// Assert
+	assert.True(t, ok)
+	assert.False(t, state.DidResume)
+}
+
+func TestTCPTransport_TLSConnectionState_NotEncrypted(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	listener := createTCPListener(t, createLocalhostTCPAddress(), nil)
+	defer silentClose(listener)
+	client := createClientTCPTransport(t, createLocalhostTCPAddress())
+	defer silentClose(client)
+
+	// Act
+	_, ok := client.(TLSTransport).TLSConnectionState()
+
+	// Assert
+	assert.False(t, ok)
+}
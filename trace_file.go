@@ -0,0 +1,238 @@
+package lime
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TraceRecord represents an envelope written by a FileTracer.
+type TraceRecord struct {
+	// Timestamp is the moment when the envelope was traced.
+	Timestamp time.Time `json:"timestamp"`
+	// Action is the transport operation, which can be 'send' or 'receive'.
+	Action string `json:"action"`
+	// Envelope is the envelope JSON representation.
+	Envelope json.RawMessage `json:"envelope"`
+}
+
+// FileTracer writes the traced envelopes to gzip compressed files, with a distinct set of files for each session.
+// The files are rotated when the uncompressed size reaches the configured limit and are named as
+// '<sessionID>-<sequence>.jsonl.gz', where each line is a TraceRecord. The session IDs with characters other than
+// letters, digits, hyphens and underscores, which are defined by the remote party, are replaced by their SHA-256
+// hash, like 'session-9f86d081...'.
+type FileTracer struct {
+	dir     string
+	maxSize int64
+	clock   Clock
+}
+
+// DefaultTraceFileMaxSize is the default uncompressed size limit of a trace file.
+const DefaultTraceFileMaxSize int64 = 16 * 1024 * 1024
+
+// NewFileTracer creates a FileTracer that writes the trace files in the specified directory.
+// If maxSize is zero, the DefaultTraceFileMaxSize value is used.
+func NewFileTracer(dir string, maxSize int64) (*FileTracer, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultTraceFileMaxSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("file tracer: %w", err)
+	}
+	return &FileTracer{dir: dir, maxSize: maxSize, clock: SystemClock}, nil
+}
+
+// SetClock defines the clock for the timestamps of the records. It should be called before the tracer is used.
+func (f *FileTracer) SetClock(clock Clock) {
+	f.clock = clockOrSystem(clock)
+}
+
+// NewTraceWriter creates a TraceWriter for a single transport connection.
+// It can be used as the NewTraceWriter value of the TCPConfig type.
+func (f *FileTracer) NewTraceWriter() TraceWriter {
+	sendReader, sendWriter := io.Pipe()
+	receiveReader, receiveWriter := io.Pipe()
+
+	w := fileTraceWriter{
+		tracer:        f,
+		sendWriter:    sendWriter,
+		receiveWriter: receiveWriter,
+	}
+	w.wg.Add(2)
+	goLabeled(context.Background(), "trace.send", func(context.Context) {
+		w.trace(json.NewDecoder(sendReader), "send")
+	})
+	goLabeled(context.Background(), "trace.receive", func(context.Context) {
+		w.trace(json.NewDecoder(receiveReader), "receive")
+	})
+	return &w
+}
+
+// fileTraceWriter is the TraceWriter of a single connection.
+// Since the session ID is only known after the first session envelope from the server,
+// the records are kept in memory until it is available.
+type fileTraceWriter struct {
+	tracer        *FileTracer
+	sendWriter    io.WriteCloser
+	receiveWriter io.WriteCloser
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	sessionID     string
+	pending       []TraceRecord
+	file          *rotatingFile
+}
+
+func (w *fileTraceWriter) SendWriter() *io.Writer {
+	var sw io.Writer = w.sendWriter
+	return &sw
+}
+
+func (w *fileTraceWriter) ReceiveWriter() *io.Writer {
+	var rw io.Writer = w.receiveWriter
+	return &rw
+}
+
+func (w *fileTraceWriter) trace(dec *json.Decoder, action string) {
+	defer w.wg.Done()
+
+	for {
+		var j json.RawMessage
+		if err := dec.Decode(&j); err != nil {
+			break
+		}
+		w.write(TraceRecord{Timestamp: w.tracer.clock.Now(), Action: action, Envelope: j})
+	}
+}
+
+func (w *fileTraceWriter) write(r TraceRecord) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.sessionID == "" {
+		var raw rawEnvelope
+		if err := json.Unmarshal(r.Envelope, &raw); err == nil && raw.State != nil && raw.ID != "" {
+			w.sessionID = traceFileName(raw.ID)
+		}
+	}
+
+	if w.sessionID == "" {
+		w.pending = append(w.pending, r)
+		return
+	}
+
+	if w.file == nil {
+		w.file = &rotatingFile{dir: w.tracer.dir, name: w.sessionID, maxSize: w.tracer.maxSize}
+		for _, p := range w.pending {
+			w.writeRecord(p)
+		}
+		w.pending = nil
+	}
+
+	w.writeRecord(r)
+}
+
+func (w *fileTraceWriter) writeRecord(r TraceRecord) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	if err = w.file.Write(append(b, '\n')); err != nil {
+		log.Printf("file tracer: %v", err)
+	}
+}
+
+// Close stops the tracing and closes the current trace file.
+func (w *fileTraceWriter) Close() error {
+	_ = w.sendWriter.Close()
+	_ = w.receiveWriter.Close()
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil && len(w.pending) > 0 {
+		// The session was never established, but the records are still useful
+		w.sessionID = "unknown-" + uuid.NewString()
+		w.file = &rotatingFile{dir: w.tracer.dir, name: w.sessionID, maxSize: w.tracer.maxSize}
+		for _, p := range w.pending {
+			w.writeRecord(p)
+		}
+		w.pending = nil
+	}
+
+	if w.file != nil {
+		return w.file.Close()
+	}
+	return nil
+}
+
+// traceFileName returns the session ID if it is safe to be used in a file name, or its hash, so the remote party
+// cannot write outside the directory of the tracer with IDs like '../../etc/cron.d/x'.
+func traceFileName(sessionID string) string {
+	for _, c := range sessionID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			sum := sha256.Sum256([]byte(sessionID))
+			return "session-" + hex.EncodeToString(sum[:])
+		}
+	}
+	return sessionID
+}
+
+// rotatingFile is a gzip file writer that starts a new file when the uncompressed size reaches the limit.
+type rotatingFile struct {
+	dir     string
+	name    string
+	maxSize int64
+	seq     int
+	size    int64
+	f       *os.File
+	gz      *gzip.Writer
+}
+
+func (r *rotatingFile) Write(b []byte) error {
+	if r.f == nil {
+		f, err := os.Create(filepath.Join(r.dir, fmt.Sprintf("%s-%03d.jsonl.gz", r.name, r.seq)))
+		if err != nil {
+			return err
+		}
+		r.f = f
+		r.gz = gzip.NewWriter(f)
+		r.size = 0
+		r.seq++
+	}
+
+	n, err := r.gz.Write(b)
+	r.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if r.size >= r.maxSize {
+		return r.Close()
+	}
+	return nil
+}
+
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	gzErr := r.gz.Close()
+	err := r.f.Close()
+	r.f = nil
+	r.gz = nil
+	if gzErr != nil {
+		return gzErr
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package lime
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readTraceRecords(t *testing.T, path string) []TraceRecord {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(f)
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []TraceRecord
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var r TraceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestFileTracer_NewTraceWriter_WritesBySession(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	dir := t.TempDir()
+	tracer, err := NewFileTracer(dir, 0)
+	assert.NoError(t, err)
+	tw := tracer.NewTraceWriter()
+	ses := createSession()
+	msg := createMessage()
+
+	// Act
+	writeTraceEnvelopes(t, *tw.SendWriter(), &Session{State: SessionStateNew})
+	writeTraceEnvelopes(t, *tw.ReceiveWriter(), ses)
+	writeTraceEnvelopes(t, *tw.SendWriter(), msg)
+	err = tw.(io.Closer).Close()
+
+	// Assert
+	assert.NoError(t, err)
+	records := readTraceRecords(t, filepath.Join(dir, ses.ID+"-000.jsonl.gz"))
+	assert.Len(t, records, 3)
+	actions := map[string]int{}
+	for _, r := range records {
+		actions[r.Action]++
+	}
+	assert.Equal(t, map[string]int{"send": 2, "receive": 1}, actions)
+}
+
+func TestFileTracer_NewTraceWriter_Rotates(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	dir := t.TempDir()
+	tracer, err := NewFileTracer(dir, 512)
+	assert.NoError(t, err)
+	tw := tracer.NewTraceWriter()
+	ses := createSession()
+
+	// Act
+	writeTraceEnvelopes(t, *tw.ReceiveWriter(), ses)
+	for i := 0; i < 10; i++ {
+		writeTraceEnvelopes(t, *tw.SendWriter(), createMessage())
+	}
+	err = tw.(io.Closer).Close()
+
+	// Assert
+	assert.NoError(t, err)
+	files, _ := filepath.Glob(filepath.Join(dir, ses.ID+"-*.jsonl.gz"))
+	assert.Greater(t, len(files), 1)
+	var total int
+	for _, f := range files {
+		total += len(readTraceRecords(t, f))
+	}
+	assert.Equal(t, 11, total)
+}
+
+func TestFileTracer_NewTraceWriter_WithoutSession(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	dir := t.TempDir()
+	tracer, err := NewFileTracer(dir, 0)
+	assert.NoError(t, err)
+	tw := tracer.NewTraceWriter()
+
+	// Act
+	writeTraceEnvelopes(t, *tw.SendWriter(), &Session{State: SessionStateNew})
+	err = tw.(io.Closer).Close()
+
+	// Assert
+	assert.NoError(t, err)
+	files, _ := filepath.Glob(filepath.Join(dir, "unknown-*.jsonl.gz"))
+	assert.Len(t, files, 1)
+}
+
+func TestFileTracer_NewTraceWriter_UnsafeSessionID(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "traces")
+	assert.NoError(t, os.Mkdir(dir, 0o755))
+	tracer, err := NewFileTracer(dir, 0)
+	assert.NoError(t, err)
+	tw := tracer.NewTraceWriter()
+	ses := createSession()
+	ses.ID = "../escaped"
+
+	// Act
+	writeTraceEnvelopes(t, *tw.ReceiveWriter(), ses)
+	err = tw.(io.Closer).Close()
+
+	// Assert
+	assert.NoError(t, err)
+	parentEntries, _ := os.ReadDir(parent)
+	assert.Len(t, parentEntries, 1)
+	entries, _ := os.ReadDir(dir)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, traceFileName("../escaped")+"-000.jsonl.gz", entries[0].Name())
+		assert.Len(t, readTraceRecords(t, filepath.Join(dir, entries[0].Name())), 1)
+	}
+}
@@ -0,0 +1,210 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceEntry describes a traced envelope, allowing sampling decisions without a full deserialization.
+type TraceEntry struct {
+	// Action is the transport operation, which can be 'send' or 'receive'.
+	Action string
+	// Type is the envelope type, like 'Message', 'Notification', 'RequestCommand', 'ResponseCommand' or 'Session'.
+	Type string
+	// ID is the envelope identifier.
+	ID string
+	// From is the envelope sender node.
+	From Node
+	// To is the envelope destination node.
+	To Node
+	// Raw is the envelope JSON representation.
+	Raw json.RawMessage
+}
+
+// TraceSampler decides if an envelope should be written to the trace.
+type TraceSampler func(e *TraceEntry) bool
+
+// SamplingTraceWriter implements a TraceWriter that decorates another TraceWriter,
+// writing only the envelopes accepted by the TraceSampler.
+type SamplingTraceWriter struct {
+	tw            TraceWriter
+	sampler       TraceSampler
+	sendWriter    io.WriteCloser
+	receiveWriter io.WriteCloser
+	wg            sync.WaitGroup
+}
+
+// NewSamplingTraceWriter creates a TraceWriter that only writes the sampled envelopes to the specified TraceWriter.
+func NewSamplingTraceWriter(tw TraceWriter, sampler TraceSampler) *SamplingTraceWriter {
+	if tw == nil {
+		panic("nil trace writer")
+	}
+	if sampler == nil {
+		panic("nil sampler")
+	}
+
+	sendReader, sendWriter := io.Pipe()
+	receiveReader, receiveWriter := io.Pipe()
+
+	s := SamplingTraceWriter{
+		tw:            tw,
+		sampler:       sampler,
+		sendWriter:    sendWriter,
+		receiveWriter: receiveWriter,
+	}
+
+	s.wg.Add(2)
+	goLabeled(context.Background(), "trace.send", func(context.Context) {
+		s.sample(json.NewDecoder(sendReader), "send", *tw.SendWriter())
+	})
+	goLabeled(context.Background(), "trace.receive", func(context.Context) {
+		s.sample(json.NewDecoder(receiveReader), "receive", *tw.ReceiveWriter())
+	})
+
+	return &s
+}
+
+func (s *SamplingTraceWriter) sample(dec *json.Decoder, action string, w io.Writer) {
+	defer s.wg.Done()
+
+	for {
+		var j json.RawMessage
+		if err := dec.Decode(&j); err != nil {
+			break
+		}
+
+		var raw rawEnvelope
+		if err := json.Unmarshal(j, &raw); err != nil {
+			continue
+		}
+
+		entry := TraceEntry{Action: action, ID: raw.ID, Raw: j}
+		entry.Type, _ = raw.envelopeType()
+		if raw.From != nil {
+			entry.From = *raw.From
+		}
+		if raw.To != nil {
+			entry.To = *raw.To
+		}
+
+		if s.sampler(&entry) {
+			_, _ = w.Write(append(j, '\n'))
+		}
+	}
+}
+
+func (s *SamplingTraceWriter) SendWriter() *io.Writer {
+	var w io.Writer = s.sendWriter
+	return &w
+}
+
+func (s *SamplingTraceWriter) ReceiveWriter() *io.Writer {
+	var w io.Writer = s.receiveWriter
+	return &w
+}
+
+// Close stops the sampling of the envelopes, waiting for the pending writes to the decorated TraceWriter.
+func (s *SamplingTraceWriter) Close() error {
+	_ = s.sendWriter.Close()
+	_ = s.receiveWriter.Close()
+	s.wg.Wait()
+	return nil
+}
+
+// SampleOneInN creates a TraceSampler that accepts one envelope in every n envelopes.
+func SampleOneInN(n int) TraceSampler {
+	if n <= 0 {
+		panic("n must be positive")
+	}
+	var count uint64
+	return func(*TraceEntry) bool {
+		return (atomic.AddUint64(&count, 1)-1)%uint64(n) == 0
+	}
+}
+
+// SampleRate creates a TraceSampler that accepts up to perSecond envelopes in each second.
+func SampleRate(perSecond int) TraceSampler {
+	return SampleRateWithClock(perSecond, SystemClock)
+}
+
+// SampleRateWithClock creates a TraceSampler that accepts up to perSecond envelopes in each second of the clock.
+// If the clock is nil, the SystemClock is used.
+func SampleRateWithClock(perSecond int, clock Clock) TraceSampler {
+	clock = clockOrSystem(clock)
+	if perSecond <= 0 {
+		panic("perSecond must be positive")
+	}
+	var mu sync.Mutex
+	var window time.Time
+	var count int
+	return func(*TraceEntry) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := clock.Now().Truncate(time.Second)
+		if !now.Equal(window) {
+			window = now
+			count = 0
+		}
+		if count >= perSecond {
+			return false
+		}
+		count++
+		return true
+	}
+}
+
+// SampleEnvelopeTypes creates a TraceSampler that accepts only the envelopes of the specified types.
+func SampleEnvelopeTypes(types ...string) TraceSampler {
+	typesMap := make(map[string]struct{}, len(types))
+	for _, t := range types {
+		typesMap[t] = struct{}{}
+	}
+	return func(e *TraceEntry) bool {
+		_, ok := typesMap[e.Type]
+		return ok
+	}
+}
+
+// SampleIdentities creates a TraceSampler that accepts only the envelopes sent from or to the specified identities.
+func SampleIdentities(identities ...Identity) TraceSampler {
+	identitiesMap := make(map[Identity]struct{}, len(identities))
+	for _, i := range identities {
+		identitiesMap[i] = struct{}{}
+	}
+	return func(e *TraceEntry) bool {
+		if _, ok := identitiesMap[e.From.Identity]; ok {
+			return true
+		}
+		_, ok := identitiesMap[e.To.Identity]
+		return ok
+	}
+}
+
+// SampleAll creates a TraceSampler that accepts an envelope only if all the specified samplers accept it.
+func SampleAll(samplers ...TraceSampler) TraceSampler {
+	return func(e *TraceEntry) bool {
+		for _, s := range samplers {
+			if !s(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SampleAny creates a TraceSampler that accepts an envelope if any of the specified samplers accepts it.
+func SampleAny(samplers ...TraceSampler) TraceSampler {
+	return func(e *TraceEntry) bool {
+		for _, s := range samplers {
+			if s(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+package lime
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bufferTraceWriter struct {
+	sendWriter    io.Writer
+	receiveWriter io.Writer
+	sendBuf       *bytes.Buffer
+	receiveBuf    *bytes.Buffer
+}
+
+func newBufferTraceWriter() *bufferTraceWriter {
+	sendBuf := &bytes.Buffer{}
+	receiveBuf := &bytes.Buffer{}
+	return &bufferTraceWriter{
+		sendWriter:    sendBuf,
+		receiveWriter: receiveBuf,
+		sendBuf:       sendBuf,
+		receiveBuf:    receiveBuf,
+	}
+}
+
+func (t *bufferTraceWriter) SendWriter() *io.Writer {
+	return &t.sendWriter
+}
+
+func (t *bufferTraceWriter) ReceiveWriter() *io.Writer {
+	return &t.receiveWriter
+}
+
+func writeTraceEnvelopes(t *testing.T, w io.Writer, envelopes ...envelope) {
+	enc := json.NewEncoder(w)
+	for _, e := range envelopes {
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSamplingTraceWriter_EnvelopeTypes(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	inner := newBufferTraceWriter()
+	tw := NewSamplingTraceWriter(inner, SampleEnvelopeTypes("Message"))
+	msg := createMessage()
+	not := createNotification()
+
+	// Act
+	writeTraceEnvelopes(t, *tw.SendWriter(), msg, not, msg)
+	writeTraceEnvelopes(t, *tw.ReceiveWriter(), not)
+	_ = tw.Close()
+
+	// Assert
+	lines := strings.Split(strings.TrimSpace(inner.sendBuf.String()), "\n")
+	assert.Len(t, lines, 2)
+	var actual Message
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &actual))
+	assert.Equal(t, msg.ID, actual.ID)
+	assert.Empty(t, inner.receiveBuf.String())
+}
+
+func TestSamplingTraceWriter_OneInN(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	inner := newBufferTraceWriter()
+	tw := NewSamplingTraceWriter(inner, SampleOneInN(3))
+	msg := createMessage()
+
+	// Act
+	for i := 0; i < 7; i++ {
+		writeTraceEnvelopes(t, *tw.ReceiveWriter(), msg)
+	}
+	_ = tw.Close()
+
+	// Assert
+	lines := strings.Split(strings.TrimSpace(inner.receiveBuf.String()), "\n")
+	assert.Len(t, lines, 3)
+}
+
+func TestSampleRate(t *testing.T) {
+	// Arrange
+	sampler := SampleRate(2)
+	var accepted int
+
+	// Act
+	for i := 0; i < 5; i++ {
+		if sampler(&TraceEntry{}) {
+			accepted++
+		}
+	}
+
+	// Assert
+	assert.LessOrEqual(t, accepted, 4)
+	assert.GreaterOrEqual(t, accepted, 2)
+}
+
+func TestSampleRateWithClock(t *testing.T) {
+	// Arrange
+	clock := &fixedClock{now: storeEpoch}
+	sampler := SampleRateWithClock(2, clock)
+	var accepted []bool
+
+	// Act
+	for i := 0; i < 3; i++ {
+		accepted = append(accepted, sampler(&TraceEntry{}))
+	}
+	clock.now = clock.now.Add(time.Second)
+	accepted = append(accepted, sampler(&TraceEntry{}))
+
+	// Assert
+	assert.Equal(t, []bool{true, true, false, true}, accepted)
+}
+
+func TestSampleIdentities(t *testing.T) {
+	// Arrange
+	sampler := SampleIdentities(Identity{Name: "golang", Domain: "limeprotocol.org"})
+
+	// Act
+	fromMatch := sampler(&TraceEntry{From: ParseNode("golang@limeprotocol.org/home")})
+	toMatch := sampler(&TraceEntry{To: ParseNode("golang@limeprotocol.org")})
+	noMatch := sampler(&TraceEntry{From: ParseNode("other@limeprotocol.org")})
+
+	// Assert
+	assert.True(t, fromMatch)
+	assert.True(t, toMatch)
+	assert.False(t, noMatch)
+}
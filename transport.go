@@ -24,6 +24,17 @@ type Transport interface {
 	RemoteAddr() net.Addr                                           // RemoteAddr returns the remote endpoint address.
 }
 
+// BufferedTransport is implemented by the transports that read ahead from the connection, which happens when the
+// remote party packs many envelopes in a single network segment.
+type BufferedTransport interface {
+	Transport
+	// Buffered returns the number of envelopes, or envelope arrays, that were already read from the connection and
+	// can be received without waiting for it. The count may be lower than the actual one, since the transports
+	// do not decode the data ahead, but the next Receive does not wait for the connection if it is positive.
+	// It should only be called by the receiving goroutine.
+	Buffered() int
+}
+
 // TransportListener Defines a listener interface for the transports.
 type TransportListener interface {
 	io.Closer
@@ -66,8 +77,12 @@ func NewStdoutTraceWriter() TraceWriter {
 		}
 	}
 
-	go trace(receiveDecoder, "receive")
-	go trace(sendDecoder, "send")
+	goLabeled(context.Background(), "trace.receive", func(context.Context) {
+		trace(receiveDecoder, "receive")
+	})
+	goLabeled(context.Background(), "trace.send", func(context.Context) {
+		trace(sendDecoder, "send")
+	})
 
 	return &tw
 }
@@ -0,0 +1,634 @@
+package lime
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// The frames of a TransportMux connection have a header with the stream ID, the frame type and the payload length,
+// followed by the payload:
+//
+//	+-------------------+--------+----------------+-----------+
+//	| stream ID (4)     | type   | length (4)     | payload   |
+//	+-------------------+--------+----------------+-----------+
+const (
+	muxFrameData  = byte(0) // muxFrameData carries the data of a stream.
+	muxFrameOpen  = byte(1) // muxFrameOpen opens a stream, which is accepted by the remote party.
+	muxFrameClose = byte(2) // muxFrameClose closes a stream.
+
+	muxHeaderSize    = 9
+	muxMaxFrameSize  = 64 << 10
+	muxStreamBacklog = 64   // muxStreamBacklog is the number of received frames buffered for each stream.
+	muxMaxStreams    = 1024 // muxMaxStreams is the maximum number of open streams of a connection.
+)
+
+var (
+	// ErrTransportMuxClosed is returned when a stream is opened or accepted in a closed TransportMux.
+	ErrTransportMuxClosed = errors.New("transport mux closed")
+	// ErrTransportMuxStreamLimit is returned when a stream is opened in a TransportMux with the maximum number of
+	// open streams.
+	ErrTransportMuxStreamLimit = errors.New("transport mux stream limit reached")
+)
+
+// TransportMux runs several logical sessions over a single TCP connection, which is optionally encrypted with TLS,
+// cutting the connection count of the gateways that keep many identities connected. The data of the sessions is
+// sent in frames prefixed by their stream ID.
+//
+// The multiplexing is experimental, and both parties must use it: the clients dial the connection with the
+// DialTransportMux function and open a transport for each session, while the server accepts them with the
+// NewMuxTransportListener listener. The sessions are not encrypted individually, since the connection is.
+// The received data is queued for each session, and a session that stops receiving is closed when its queue is full,
+// so it doesn't block the others. The number of open sessions of a connection is limited, and the sessions opened
+// by the remote party beyond the limit are refused.
+type TransportMux struct {
+	conn    net.Conn
+	config  TCPConfig
+	tlsConn *tls.Conn
+	server  bool
+	// maxStreams is the maximum number of open streams, which is muxMaxStreams except in the tests
+	maxStreams int
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	streams map[uint32]*muxStream
+	nextID  uint32
+	closed  bool
+
+	accepted chan *muxStream
+	done     chan struct{}
+	err      error // err is the failure of the connection, defined before done is closed
+}
+
+// DialTransportMux opens a multiplexed connection with a server listening with the NewMuxTransportListener. The
+// connection is encrypted if the config defines the TLSConfig.
+func DialTransportMux(ctx context.Context, addr net.Addr, config *TCPConfig) (*TransportMux, error) {
+	if addr.Network() != "tcp" {
+		return nil, errors.New("address network should be tcp")
+	}
+	if config == nil {
+		config = &defaultTCPConfig
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, addr.Network(), addr.String())
+	if err != nil {
+		return nil, err
+	}
+	m, err := newTransportMux(ctx, conn, config, false)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func newTransportMux(ctx context.Context, conn net.Conn, config *TCPConfig, server bool) (*TransportMux, error) {
+	m := &TransportMux{
+		config:     *config,
+		server:     server,
+		maxStreams: muxMaxStreams,
+		streams:    make(map[uint32]*muxStream),
+		nextID:     1,
+		accepted:   make(chan *muxStream, muxStreamBacklog),
+		done:       make(chan struct{}),
+	}
+	if server {
+		m.nextID = 2
+	}
+
+	if config.TLSConfig != nil {
+		if server {
+			m.tlsConn = tls.Server(conn, config.TLSConfig)
+		} else {
+			m.tlsConn = tls.Client(conn, config.clientTLSConfig())
+		}
+		if err := m.tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("transport mux: tls handshake: %w", err)
+		}
+		conn = m.tlsConn
+	}
+	m.conn = conn
+	// The sessions cannot negotiate the encryption, which is defined by the connection
+	m.config.TLSConfig = nil
+
+	goLabeled(context.Background(), "transport.mux", m.readLoop, "addr", fmt.Sprint(conn.RemoteAddr()))
+	return m, nil
+}
+
+// Open opens a transport for a new logical session.
+func (m *TransportMux) Open(_ context.Context) (Transport, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrTransportMuxClosed
+	}
+	if len(m.streams) >= m.maxStreams {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("transport mux: open: %w", ErrTransportMuxStreamLimit)
+	}
+	id := m.nextID
+	m.nextID += 2
+	s := m.newStream(id)
+	m.mu.Unlock()
+
+	if err := m.writeFrame(id, muxFrameOpen, nil); err != nil {
+		s.close(false)
+		return nil, fmt.Errorf("transport mux: open: %w", err)
+	}
+	return m.newTransport(s), nil
+}
+
+// Accept returns the transport of a logical session opened by the remote party.
+func (m *TransportMux) Accept(ctx context.Context) (Transport, error) {
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("transport mux: %w", ctx.Err())
+	case s := <-m.accepted:
+		return m.newTransport(s), nil
+	case <-m.done:
+		return nil, ErrTransportMuxClosed
+	}
+}
+
+// Streams returns the number of open logical sessions.
+func (m *TransportMux) Streams() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.streams)
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (m *TransportMux) Done() <-chan struct{} {
+	return m.done
+}
+
+// Err returns the failure of the connection, after it is closed.
+func (m *TransportMux) Err() error {
+	select {
+	case <-m.done:
+		return m.err
+	default:
+		return nil
+	}
+}
+
+// Close closes the connection and all its logical sessions.
+func (m *TransportMux) Close() error {
+	err := m.conn.Close()
+	<-m.done
+	return err
+}
+
+func (m *TransportMux) newTransport(s *muxStream) Transport {
+	t := &tcpTransport{TCPConfig: m.config, encryption: SessionEncryptionNone, connEncrypted: true, server: m.server}
+	t.setConn(s)
+	if m.tlsConn != nil {
+		t.tlsConn = m.tlsConn
+		t.encryption = SessionEncryptionTLS
+	}
+	return t
+}
+
+// newStream registers a stream, which should be done with the lock held.
+func (m *TransportMux) newStream(id uint32) *muxStream {
+	user, inner := net.Pipe()
+	s := &muxStream{
+		Conn:         user,
+		id:           id,
+		mux:          m,
+		inner:        inner,
+		incoming:     make(chan []byte, muxStreamBacklog),
+		remoteClosed: make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	m.streams[id] = s
+	goLabeled(context.Background(), "transport.mux.stream", s.writeLoop)
+	goLabeled(context.Background(), "transport.mux.stream", s.readLoop)
+	return s
+}
+
+func (m *TransportMux) removeStream(s *muxStream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.streams[s.id] == s {
+		delete(m.streams, s.id)
+	}
+}
+
+func (m *TransportMux) stream(id uint32) *muxStream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.streams[id]
+}
+
+func (m *TransportMux) writeFrame(id uint32, frameType byte, payload []byte) error {
+	var header [muxHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], id)
+	header[4] = frameType
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(payload)))
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	if _, err := m.conn.Write(header[:]); err != nil {
+		return err
+	}
+	if len(payload) != 0 {
+		if _, err := m.conn.Write(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLoop dispatches the received frames to the streams, until the connection is closed.
+func (m *TransportMux) readLoop(context.Context) {
+	err := m.readFrames()
+
+	m.mu.Lock()
+	m.closed = true
+	streams := make([]*muxStream, 0, len(m.streams))
+	for _, s := range m.streams {
+		streams = append(streams, s)
+	}
+	m.mu.Unlock()
+
+	_ = m.conn.Close()
+	for _, s := range streams {
+		s.close(false)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	m.err = err
+	close(m.done)
+}
+
+func (m *TransportMux) readFrames() error {
+	var header [muxHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(m.conn, header[:]); err != nil {
+			return err
+		}
+		id := binary.BigEndian.Uint32(header[0:4])
+		length := binary.BigEndian.Uint32(header[5:9])
+		if length > muxMaxFrameSize {
+			return fmt.Errorf("transport mux: frame of stream %v exceeds the maximum size", id)
+		}
+		var payload []byte
+		if length > 0 {
+			payload = make([]byte, length)
+			if _, err := io.ReadFull(m.conn, payload); err != nil {
+				return err
+			}
+		}
+
+		switch header[4] {
+		case muxFrameOpen:
+			// The clients open the odd streams and the servers the even ones
+			if remote := id%2 == 1; remote != m.server {
+				return fmt.Errorf("transport mux: invalid stream id %v", id)
+			}
+			m.mu.Lock()
+			if _, ok := m.streams[id]; ok {
+				m.mu.Unlock()
+				return fmt.Errorf("transport mux: stream %v is already open", id)
+			}
+			if len(m.streams) >= m.maxStreams {
+				m.mu.Unlock()
+				log.Printf("transport mux: stream %v refused, since the stream limit is reached", id)
+				if err := m.writeFrame(id, muxFrameClose, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			s := m.newStream(id)
+			m.mu.Unlock()
+			select {
+			case m.accepted <- s:
+			default:
+				log.Printf("transport mux: stream %v refused, since the accept backlog is full", id)
+				s.close(true)
+			}
+		case muxFrameData:
+			if s := m.stream(id); s != nil {
+				s.deliver(payload)
+			}
+		case muxFrameClose:
+			if s := m.stream(id); s != nil {
+				s.closeRemote()
+			}
+		default:
+			return fmt.Errorf("transport mux: unknown frame type %v", header[4])
+		}
+	}
+}
+
+// muxStream is a logical connection of a TransportMux. It is a net.Conn, which is one end of a pipe whose other end
+// is pumped to and from the multiplexed connection, providing the deadlines expected by the transports.
+type muxStream struct {
+	net.Conn
+	id       uint32
+	mux      *TransportMux
+	inner    net.Conn
+	incoming chan []byte
+
+	remoteOnce   sync.Once
+	remoteClosed chan struct{} // remoteClosed is closed when the stream is closed by the remote party
+	closeOnce    sync.Once
+	done         chan struct{} // done is closed when the stream is closed locally
+}
+
+func (s *muxStream) LocalAddr() net.Addr {
+	return &muxAddr{Addr: s.mux.conn.LocalAddr(), id: s.id}
+}
+
+func (s *muxStream) RemoteAddr() net.Addr {
+	return &muxAddr{Addr: s.mux.conn.RemoteAddr(), id: s.id}
+}
+
+// SetDeadline sets the deadlines of the stream, which succeeds after it is closed by the remote party, for the reads to
+// report the end of the stream.
+func (s *muxStream) SetDeadline(t time.Time) error {
+	return ignoreClosedPipe(s.Conn.SetDeadline(t))
+}
+
+func (s *muxStream) SetReadDeadline(t time.Time) error {
+	return ignoreClosedPipe(s.Conn.SetReadDeadline(t))
+}
+
+func (s *muxStream) SetWriteDeadline(t time.Time) error {
+	return ignoreClosedPipe(s.Conn.SetWriteDeadline(t))
+}
+
+func ignoreClosedPipe(err error) error {
+	if errors.Is(err, io.ErrClosedPipe) {
+		return nil
+	}
+	return err
+}
+
+// deliver queues the received data. The stream is closed if its backlog is full, since waiting would block the
+// other streams of the connection.
+func (s *muxStream) deliver(payload []byte) {
+	select {
+	case s.incoming <- payload:
+	case <-s.done:
+	default:
+		log.Printf("transport mux: stream %v closed, since its receive backlog is full", s.id)
+		s.close(true)
+	}
+}
+
+// closeRemote signals that the remote party will not send more data.
+func (s *muxStream) closeRemote() {
+	s.remoteOnce.Do(func() {
+		close(s.remoteClosed)
+	})
+}
+
+// close closes the stream locally, notifying the remote party if requested.
+func (s *muxStream) close(notify bool) {
+	s.closeOnce.Do(func() {
+		s.mux.removeStream(s)
+		close(s.done)
+		_ = s.inner.Close()
+		select {
+		case <-s.remoteClosed:
+		default:
+			if notify {
+				_ = s.mux.writeFrame(s.id, muxFrameClose, nil)
+			}
+		}
+	})
+}
+
+// writeLoop sends the data written to the stream in frames, until the stream is closed by the user.
+func (s *muxStream) writeLoop(context.Context) {
+	defer s.close(true)
+	buf := make([]byte, muxMaxFrameSize)
+	for {
+		n, err := s.inner.Read(buf)
+		if n > 0 {
+			if werr := s.mux.writeFrame(s.id, muxFrameData, buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readLoop writes the received data to the stream, until it is closed by any party.
+func (s *muxStream) readLoop(context.Context) {
+	for {
+		select {
+		case payload := <-s.incoming:
+			if _, err := s.inner.Write(payload); err != nil {
+				return
+			}
+		case <-s.remoteClosed:
+			// The data is queued before the close frame is received
+			for {
+				select {
+				case payload := <-s.incoming:
+					if _, err := s.inner.Write(payload); err != nil {
+						return
+					}
+				default:
+					_ = s.inner.Close()
+					return
+				}
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// muxAddr is the address of a stream, which is the connection address with the stream ID.
+type muxAddr struct {
+	net.Addr
+	id uint32
+}
+
+func (a *muxAddr) String() string {
+	return fmt.Sprintf("%v#%d", a.Addr, a.id)
+}
+
+// muxTransportListener accepts the multiplexed connections, returning a transport for each logical session.
+type muxTransportListener struct {
+	TCPConfig
+	mu         sync.Mutex
+	listener   net.Listener
+	muxes      map[*TransportMux]struct{}
+	transports chan Transport
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewMuxTransportListener creates a listener for the connections opened by DialTransportMux, whose logical
+// sessions are returned by the Accept method as individual transports. The connections are encrypted if the config
+// defines the TLSConfig.
+func NewMuxTransportListener(config *TCPConfig) TransportListener {
+	if config == nil {
+		config = &defaultTCPConfig
+	}
+	return &muxTransportListener{TCPConfig: *config}
+}
+
+func (l *muxTransportListener) Listen(ctx context.Context, addr net.Addr) error {
+	if addr.Network() != "tcp" {
+		return errors.New("address network should be tcp")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.listener != nil {
+		return errors.New("mux listener is already started")
+	}
+
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", addr.String())
+	if err != nil {
+		return err
+	}
+
+	l.listener = listener
+	l.muxes = make(map[*TransportMux]struct{})
+	l.transports = make(chan Transport, l.ConnBuffer)
+	l.done = make(chan struct{})
+
+	l.wg.Add(1)
+	goLabeled(context.Background(), "mux.listener", func(context.Context) {
+		defer l.wg.Done()
+		l.serve(listener)
+	}, "addr", listener.Addr().String())
+	return nil
+}
+
+func (l *muxTransportListener) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-l.done:
+			default:
+				log.Printf("mux listener: serve: %v\n", err)
+			}
+			return
+		}
+		l.wg.Add(1)
+		goLabeled(context.Background(), "mux.listener.conn", func(context.Context) {
+			defer l.wg.Done()
+			l.serveConn(conn)
+		}, "addr", conn.RemoteAddr().String())
+	}
+}
+
+// serveConn accepts the logical sessions of a connection, until it is closed.
+func (l *muxTransportListener) serveConn(conn net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-l.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	m, err := newTransportMux(ctx, conn, &l.TCPConfig, true)
+	if err != nil {
+		log.Printf("mux listener: %v\n", err)
+		_ = conn.Close()
+		return
+	}
+	if !l.addMux(m) {
+		_ = m.Close()
+		return
+	}
+	defer l.removeMux(m)
+
+	for {
+		t, err := m.Accept(ctx)
+		if err != nil {
+			return
+		}
+		select {
+		case l.transports <- t:
+		case <-l.done:
+			_ = t.Close()
+			return
+		}
+	}
+}
+
+func (l *muxTransportListener) addMux(m *TransportMux) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.done:
+		return false
+	default:
+	}
+	l.muxes[m] = struct{}{}
+	return true
+}
+
+func (l *muxTransportListener) removeMux(m *TransportMux) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.muxes, m)
+}
+
+func (l *muxTransportListener) Accept(ctx context.Context) (Transport, error) {
+	l.mu.Lock()
+	started := l.listener != nil
+	l.mu.Unlock()
+	if !started {
+		return nil, errors.New("mux listener is not started")
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("mux listener: %w", ctx.Err())
+	case <-l.done:
+		return nil, errors.New("mux listener closed")
+	case t := <-l.transports:
+		return t, nil
+	}
+}
+
+// Close stops the listener and closes the accepted connections, with their logical sessions.
+func (l *muxTransportListener) Close() error {
+	l.mu.Lock()
+	if l.listener == nil {
+		l.mu.Unlock()
+		return errors.New("mux listener is not started")
+	}
+	close(l.done)
+	err := l.listener.Close()
+	l.listener = nil
+	muxes := make([]*TransportMux, 0, len(l.muxes))
+	for m := range l.muxes {
+		muxes = append(muxes, m)
+	}
+	l.mu.Unlock()
+
+	for _, m := range muxes {
+		_ = m.Close()
+	}
+	l.wg.Wait()
+	return err
+}
@@ -0,0 +1,359 @@
+package lime
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"net"
+	"testing"
+	"time"
+)
+
+func createMuxListener(t testing.TB, addr net.Addr, config *TCPConfig, transportChan chan Transport) TransportListener {
+	listener := NewMuxTransportListener(config)
+	if err := listener.Listen(context.Background(), addr); err != nil {
+		t.Fatal(err)
+		return nil
+	}
+
+	listenTransports(transportChan, listener)
+
+	return listener
+}
+
+func createTransportMux(t testing.TB, addr net.Addr, config *TCPConfig) *TransportMux {
+	m, err := DialTransportMux(context.Background(), addr, config)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	return m
+}
+
+func TestTransportMux_Open_Streams(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	transportChan := make(chan Transport, 2)
+	listener := createMuxListener(t, addr, nil, transportChan)
+	defer silentClose(listener)
+	m := createTransportMux(t, addr, nil)
+	defer silentClose(m)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client1, err := m.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server1 := receiveTransport(t, transportChan)
+	client2, err := m.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server2 := receiveTransport(t, transportChan)
+	msg1 := createMessage()
+	msg2 := createMessage()
+	msg2.ID = "other-id"
+
+	// Act
+	err1 := client1.Send(ctx, msg1)
+	err2 := client2.Send(ctx, msg2)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	e2, err := server2.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, msg2, e2)
+	e1, err := server1.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, msg1, e1)
+	assert.Equal(t, 2, m.Streams())
+	assert.NotEqual(t, server1.RemoteAddr().String(), server2.RemoteAddr().String())
+}
+
+func TestTransportMux_Open_Reply(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	transportChan := make(chan Transport, 1)
+	listener := createMuxListener(t, addr, nil, transportChan)
+	defer silentClose(listener)
+	m := createTransportMux(t, addr, nil)
+	defer silentClose(m)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, err := m.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := receiveTransport(t, transportChan)
+	s := createSession()
+
+	// Act
+	err = server.Send(ctx, s)
+
+	// Assert
+	assert.NoError(t, err)
+	e, err := client.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, s, e)
+}
+
+func TestTransportMux_Close_Stream(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	transportChan := make(chan Transport, 1)
+	listener := createMuxListener(t, addr, nil, transportChan)
+	defer silentClose(listener)
+	m := createTransportMux(t, addr, nil)
+	defer silentClose(m)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, err := m.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := receiveTransport(t, transportChan)
+
+	// Act
+	err = client.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = server.Receive(ctx)
+	assert.Error(t, err)
+	assert.False(t, server.Connected())
+	assert.Equal(t, 0, m.Streams())
+	select {
+	case <-m.Done():
+		t.Fatal("the connection should remain open")
+	default:
+	}
+}
+
+func TestTransportMux_Stream_BacklogFull(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	transportChan := make(chan Transport, 2)
+	listener := createMuxListener(t, addr, nil, transportChan)
+	defer silentClose(listener)
+	m := createTransportMux(t, addr, nil)
+	defer silentClose(m)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	client1, err := m.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server1 := receiveTransport(t, transportChan)
+	defer silentClose(server1)
+	client2, err := m.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server2 := receiveTransport(t, transportChan)
+	defer silentClose(server2)
+
+	// Act
+	// The server1 transport is not read, so its backlog is filled
+	for i := 0; i < 2*muxStreamBacklog && client1.Connected(); i++ {
+		_ = client1.Send(ctx, createMessage())
+	}
+	err = client2.Send(ctx, createMessage())
+
+	// Assert
+	assert.NoError(t, err)
+	e, err := server2.Receive(ctx)
+	assert.NoError(t, err)
+	assert.IsType(t, &Message{}, e)
+	assert.Eventually(t, func() bool {
+		return m.Streams() == 1
+	}, 250*time.Millisecond, 5*time.Millisecond)
+}
+
+func TestTransportMux_Open_StreamLimit(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	clientConn, serverConn := net.Pipe()
+	client, err := newTransportMux(ctx, clientConn, &defaultTCPConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(client)
+	server, err := newTransportMux(ctx, serverConn, &defaultTCPConfig, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silentClose(server)
+	server.mu.Lock()
+	server.maxStreams = 1
+	server.mu.Unlock()
+	client.mu.Lock()
+	client.maxStreams = 1
+	client.mu.Unlock()
+	t1, err := client.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = server.Accept(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	_, err1 := client.Open(ctx)
+	client.mu.Lock()
+	client.maxStreams = 2
+	client.mu.Unlock()
+	t2, err2 := client.Open(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err1, ErrTransportMuxStreamLimit)
+	assert.NoError(t, err2)
+	// The stream beyond the server limit is closed by the server
+	_, err = t2.Receive(ctx)
+	assert.Error(t, err)
+	assert.True(t, t1.Connected())
+	assert.Equal(t, 1, server.Streams())
+}
+
+func TestTransportMux_Close(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	transportChan := make(chan Transport, 1)
+	listener := createMuxListener(t, addr, nil, transportChan)
+	defer silentClose(listener)
+	m := createTransportMux(t, addr, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, err := m.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := receiveTransport(t, transportChan)
+
+	// Act
+	err = m.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = client.Receive(ctx)
+	assert.Error(t, err)
+	assert.False(t, client.Connected())
+	_, err = server.Receive(ctx)
+	assert.Error(t, err)
+	_, err = m.Open(ctx)
+	assert.ErrorIs(t, err, ErrTransportMuxClosed)
+}
+
+func TestTransportMux_Open_TLS(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	transportChan := make(chan Transport, 1)
+	listener := createMuxListener(t, addr, &TCPConfig{TLSConfig: &tls.Config{
+		GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return createCertificate("127.0.0.1")
+		},
+	}}, transportChan)
+	defer silentClose(listener)
+	m := createTransportMux(t, addr, &TCPConfig{TLSConfig: &tls.Config{ServerName: "127.0.0.1", InsecureSkipVerify: true}})
+	defer silentClose(m)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, err := m.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := receiveTransport(t, transportChan)
+	s := createSession()
+
+	// Act
+	err = client.Send(ctx, s)
+
+	// Assert
+	assert.NoError(t, err)
+	e, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, s, e)
+	assert.Equal(t, SessionEncryptionTLS, client.Encryption())
+	assert.Equal(t, SessionEncryptionTLS, server.Encryption())
+	assert.Equal(t, []SessionEncryption{SessionEncryptionTLS}, server.SupportedEncryption())
+}
+
+func TestTransportMux_SupportedEncryption(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress()
+	listener := createMuxListener(t, addr, nil, nil)
+	defer silentClose(listener)
+	m := createTransportMux(t, addr, nil)
+	defer silentClose(m)
+	client, err := m.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	encryptions := client.SupportedEncryption()
+
+	// Assert
+	assert.Equal(t, []SessionEncryption{SessionEncryptionNone}, encryptions)
+	assert.Equal(t, SessionEncryptionNone, client.Encryption())
+}
+
+func TestClient_UseTransportMux(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	addr := createLocalhostTCPAddress().(*net.TCPAddr)
+	msgChan := make(chan *Message, 2)
+	server := NewServerBuilder().
+		ListenTransportMux(addr, nil).
+		EnablePlainAuthentication(func(ctx context.Context, identity Identity, password string) (*AuthenticationResult, error) {
+			return MemberAuthenticationResult(), nil
+		}).
+		MessagesHandlerFunc(
+			func(ctx context.Context, msg *Message, s Sender) error {
+				msgChan <- msg
+				return nil
+			}).
+		Build()
+	defer silentClose(server)
+	startServer(t, server)
+	m := createTransportMux(t, addr, nil)
+	defer silentClose(m)
+	client1 := NewClientBuilder().UseTransportMux(m).Name("client1").PlainAuthentication("secret").Build()
+	client2 := NewClientBuilder().UseTransportMux(m).Name("client2").PlainAuthentication("secret").Build()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg1 := createMessage()
+	msg2 := createMessage()
+	msg2.ID = "other-id"
+
+	// Act
+	err1 := client1.SendMessage(ctx, msg1)
+	err2 := client2.SendMessage(ctx, msg2)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	ids := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-msgChan:
+			ids[msg.ID] = true
+		case <-ctx.Done():
+			t.Fatal("message not received")
+		}
+	}
+	assert.Equal(t, map[string]bool{msg1.ID: true, msg2.ID: true}, ids)
+	assert.Equal(t, 2, m.Streams())
+	assert.NoError(t, client1.Close())
+	assert.NoError(t, client2.Close())
+}
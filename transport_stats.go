@@ -0,0 +1,169 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// TransportStats holds the traffic counters of a transport connection.
+type TransportStats struct {
+	BytesRead        int64 `json:"bytesRead"`        // BytesRead is the number of bytes read from the connection.
+	BytesWritten     int64 `json:"bytesWritten"`     // BytesWritten is the number of bytes written to the connection.
+	EnvelopesEncoded int64 `json:"envelopesEncoded"` // EnvelopesEncoded is the number of sent envelopes.
+	EnvelopesDecoded int64 `json:"envelopesDecoded"` // EnvelopesDecoded is the number of received envelopes.
+	DecodeErrors     int64 `json:"decodeErrors"`     // DecodeErrors is the number of received data that could not be decoded.
+	// BufferedReceives is the number of received envelopes that were already buffered by the transport, without
+	// reading from the connection.
+	BufferedReceives int64 `json:"bufferedReceives,omitempty"`
+	// EnvelopeSizes is the histogram of the received envelope sizes, with the count of each EnvelopeSizeBuckets
+	// bucket followed by the count of the envelopes larger than the last bucket.
+	EnvelopeSizes []int64 `json:"envelopeSizes,omitempty"`
+	// LargestEnvelopes are the largest envelopes received in the LargestEnvelopesWindow, in descending size order.
+	LargestEnvelopes []EnvelopeSize `json:"largestEnvelopes,omitempty"`
+	// EnvelopesCompressed is the number of sent envelopes that were compressed by the adaptive compression.
+	EnvelopesCompressed int64 `json:"envelopesCompressed,omitempty"`
+	// CompressionSkipped is the number of sent envelopes that the adaptive compression did not compress.
+	CompressionSkipped int64 `json:"compressionSkipped,omitempty"`
+	// CompressionRatio is the compressed to uncompressed size ratio estimated by the adaptive compression samples.
+	CompressionRatio float64 `json:"compressionRatio,omitempty"`
+}
+
+// StatsTransport is implemented by the transports that keep traffic counters.
+type StatsTransport interface {
+	Transport
+	Stats() TransportStats // Stats returns a snapshot of the transport counters.
+}
+
+// transportCounters holds the counters of a transport, which are safe for concurrent use.
+type transportCounters struct {
+	bytesRead        atomic.Int64
+	bytesWritten     atomic.Int64
+	envelopesEncoded atomic.Int64
+	envelopesDecoded atomic.Int64
+	decodeErrors     atomic.Int64
+	bufferedReceives atomic.Int64
+	sizes            envelopeSizes
+	compression      compressionCounters
+	clock            Clock // clock defines the window of the largest envelopes. If nil, the SystemClock is used.
+	// annotateSizes makes the received envelopes be annotated with their encoded size.
+	annotateSizes atomic.Bool
+}
+
+func (c *transportCounters) snapshot() TransportStats {
+	stats := TransportStats{
+		BytesRead:        c.bytesRead.Load(),
+		BytesWritten:     c.bytesWritten.Load(),
+		EnvelopesEncoded: c.envelopesEncoded.Load(),
+		EnvelopesDecoded: c.envelopesDecoded.Load(),
+		DecodeErrors:     c.decodeErrors.Load(),
+		BufferedReceives: c.bufferedReceives.Load(),
+
+		EnvelopesCompressed: c.compression.compressed.Load(),
+		CompressionSkipped:  c.compression.skipped.Load(),
+		CompressionRatio:    c.compression.ratio(),
+	}
+	stats.EnvelopeSizes, stats.LargestEnvelopes = c.sizes.snapshot(clockOrSystem(c.clock).Now())
+	return stats
+}
+
+// envelopeDecoded counts a received envelope with its encoded size, which is zero if unknown.
+func (c *transportCounters) envelopeDecoded(e envelope, size int64) {
+	c.envelopesDecoded.Add(1)
+	c.sizes.record(e, size, clockOrSystem(c.clock).Now())
+	if size > 0 && c.annotateSizes.Load() {
+		envelopeOf(e).Annotate(receivedSizeKey{}, size)
+	}
+}
+
+// sizeAnnotator is implemented by the transports that can annotate the received envelopes with their encoded size.
+type sizeAnnotator interface {
+	annotateReceivedSizes()
+}
+
+// annotateReceivedSizes makes the transport annotate the received envelopes with their encoded size, if supported.
+func annotateReceivedSizes(t Transport) {
+	if a, ok := t.(sizeAnnotator); ok {
+		a.annotateReceivedSizes()
+	}
+}
+
+// receivedSizeKey is the annotation key of the encoded size of a received envelope, as counted by the transport.
+type receivedSizeKey struct{}
+
+// receivedSize returns the encoded size of the received envelope, or zero if the transport doesn't count it.
+func receivedSize(e envelope) int64 {
+	size, _ := envelopeOf(e).Annotation(receivedSizeKey{})
+	n, _ := size.(int64)
+	return n
+}
+
+// receiveError counts the error if it was caused by invalid data, instead of a connection or cancellation issue.
+func (c *transportCounters) receiveError(err error) {
+	var netErr net.Error
+	if errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) {
+		return
+	}
+	c.decodeErrors.Add(1)
+}
+
+type countingReader struct {
+	r     io.Reader
+	count *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	w     io.Writer
+	count *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.count.Add(int64(n))
+	return n, err
+}
+
+var (
+	transportStatsOnce sync.Once
+	transportStatsVar  *expvar.Map
+)
+
+// PublishTransportStats publishes the transport counters with the specified key in the 'lime.transports' expvar
+// map. The returned function removes the entry and should be called after closing the transport.
+func PublishTransportStats(key string, t StatsTransport) (unpublish func()) {
+	if t == nil {
+		panic("nil transport")
+	}
+	transportStatsOnce.Do(func() {
+		transportStatsVar = expvar.NewMap("lime.transports")
+	})
+	transportStatsVar.Set(key, expvar.Func(func() any {
+		return t.Stats()
+	}))
+	return func() {
+		transportStatsVar.Delete(key)
+	}
+}
+
+// TransportStats returns the counters of the channel transport, if it keeps them.
+func (c *channel) TransportStats() (TransportStats, bool) {
+	if t, ok := c.transport.(StatsTransport); ok {
+		return t.Stats(), true
+	}
+	return TransportStats{}, false
+}
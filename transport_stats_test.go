@@ -0,0 +1,64 @@
+package lime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTransportCounters_ReceiveError(t *testing.T) {
+	// Arrange
+	var c transportCounters
+
+	// Act
+	c.receiveError(io.EOF)
+	c.receiveError(context.Canceled)
+	c.receiveError(&json.SyntaxError{})
+	c.receiveError(errors.New("invalid data"))
+
+	// Assert
+	assert.Equal(t, int64(2), c.snapshot().DecodeErrors)
+}
+
+func TestTransportCounters_EnvelopeDecoded_Size(t *testing.T) {
+	// Arrange
+	var c transportCounters
+	c.annotateSizes.Store(true)
+	msg := createMessage()
+
+	// Act
+	c.envelopeDecoded(msg, 120)
+
+	// Assert
+	assert.Equal(t, int64(120), receivedSize(msg))
+	assert.Equal(t, int64(1), c.snapshot().EnvelopesDecoded)
+}
+
+func TestPublishTransportStats(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	client, server := newInProcessTransportPair("localhost", 1)
+	defer silentClose(client)
+	_ = client.Send(ctx, createMessage())
+	_, _ = server.Receive(ctx)
+
+	// Act
+	unpublish := PublishTransportStats("in.process client", client)
+	v := expvar.Get("lime.transports").(*expvar.Map).Get("in.process client")
+	unpublish()
+
+	// Assert
+	if assert.NotNil(t, v) {
+		var stats TransportStats
+		assert.NoError(t, json.Unmarshal([]byte(v.String()), &stats))
+		assert.Equal(t, int64(1), stats.EnvelopesEncoded)
+	}
+	assert.Nil(t, expvar.Get("lime.transports").(*expvar.Map).Get("in.process client"))
+	assert.Equal(t, int64(1), server.Stats().EnvelopesDecoded)
+}
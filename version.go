@@ -0,0 +1,225 @@
+package lime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// MetadataKeySessionVersion is the session metadata key that holds the envelope schema version. The client sends
+// its version in the new session and the server replies the negotiated one, which is the lower of both, in the
+// established session. The parties without a version use the version 0.
+const MetadataKeySessionVersion = "#version"
+
+// VersionShim converts the documents of a media type between a schema version and the previous one, allowing the
+// parties of older versions to keep working when a newer version changes a document, like by introducing a new
+// required field.
+type VersionShim struct {
+	// Version is the schema version that introduced the change.
+	Version int
+	// MediaType is the type of the converted documents.
+	MediaType MediaType
+	// Upgrade converts a received document of the previous version to the shim version. If nil, the documents are
+	// not changed.
+	Upgrade func(d Document) (Document, error)
+	// Downgrade converts a document of the shim version to be sent to a party of the previous version. If nil, the
+	// documents are not changed.
+	Downgrade func(d Document) (Document, error)
+}
+
+// SetVersion defines the envelope schema version of the channel for the negotiation during the session
+// establishment and the shims for converting the documents exchanged with parties of older versions.
+// The received messages contents and commands resources are upgraded from the session version to the channel
+// version, while the sent ones are downgraded to the session version.
+func (c *channel) SetVersion(version int, shims ...VersionShim) {
+	if err := c.ensureState(SessionStateNew, "set version"); err != nil {
+		panic(err)
+	}
+	if version < 0 {
+		panic("the version should not be negative")
+	}
+	c.version = version
+	c.sessionVersion = version
+	c.shims = slices.Clone(shims)
+	slices.SortStableFunc(c.shims, func(a, b VersionShim) int {
+		return a.Version - b.Version
+	})
+}
+
+// SessionVersion returns the envelope schema version negotiated for the session.
+func (c *channel) SessionVersion() int {
+	return c.sessionVersion
+}
+
+// offerVersion adds the channel version to the new session sent by the client.
+func (c *channel) offerVersion(ses *Session) {
+	if c.version == 0 {
+		return
+	}
+	ses.SetMetadataKeyValue(MetadataKeySessionVersion, strconv.Itoa(c.version))
+}
+
+// selectVersion negotiates the session version, which is the lower of the channel version and the version in the
+// session metadata, or 0 if absent.
+func (c *channel) selectVersion(ses *Session) {
+	if c.version == 0 {
+		return
+	}
+	version, err := strconv.Atoi(ses.Metadata[MetadataKeySessionVersion])
+	if err != nil || version < 0 {
+		version = 0
+	}
+	c.sessionVersion = min(version, c.version)
+}
+
+// upgradeEnvelope converts the document of a received envelope from the session version to the channel version.
+func (c *channel) upgradeEnvelope(e envelope) (envelope, error) {
+	if c.sessionVersion >= c.version {
+		return e, nil
+	}
+	return c.convertDocument(e, func(d Document) (Document, error) {
+		for _, shim := range c.shims {
+			if shim.Version > c.sessionVersion && shim.Version <= c.version {
+				var err error
+				if d, err = applyShim(shim, shim.Upgrade, d); err != nil {
+					return nil, fmt.Errorf("upgrade to version %v: %w", shim.Version, err)
+				}
+			}
+		}
+		return d, nil
+	})
+}
+
+// downgradeEnvelope returns a copy of an envelope to be sent with the document converted from the channel version to
+// the session version.
+func (c *channel) downgradeEnvelope(e envelope) (envelope, error) {
+	if c.sessionVersion >= c.version {
+		return e, nil
+	}
+	return c.convertDocument(e, func(d Document) (Document, error) {
+		for i := len(c.shims) - 1; i >= 0; i-- {
+			shim := c.shims[i]
+			if shim.Version > c.sessionVersion && shim.Version <= c.version {
+				var err error
+				if d, err = applyShim(shim, shim.Downgrade, d); err != nil {
+					return nil, fmt.Errorf("downgrade from version %v: %w", shim.Version, err)
+				}
+			}
+		}
+		return d, nil
+	})
+}
+
+func applyShim(shim VersionShim, convert func(d Document) (Document, error), d Document) (Document, error) {
+	if convert == nil || d == nil || d.MediaType() != shim.MediaType {
+		return d, nil
+	}
+	return convert(d)
+}
+
+// convertDocument returns a copy of the envelope with the converted message content or command resource.
+func (c *channel) convertDocument(e envelope, convert func(d Document) (Document, error)) (envelope, error) {
+	var err error
+	switch e := e.(type) {
+	case *Message:
+		converted := *e
+		converted.Content, err = convert(e.Content)
+		return &converted, err
+	case *RequestCommand:
+		converted := *e
+		converted.Resource, err = convert(e.Resource)
+		return &converted, err
+	case *ResponseCommand:
+		converted := *e
+		converted.Resource, err = convert(e.Resource)
+		return &converted, err
+	default:
+		return e, nil
+	}
+}
+
+// upgradeReceived upgrades a received envelope, returning false if it should be discarded. The messages with an ID
+// and the request commands that cannot be upgraded are replied with a failure. The content hash of the envelope,
+// which is computed by the sender for the received document, is replaced by the hash of the upgraded document when
+// the received one matches it, otherwise it is kept so the envelope fails its verification.
+func (c *channel) upgradeReceived(ctx context.Context, e envelope) (envelope, bool) {
+	upgraded, err := c.upgradeEnvelope(e)
+	if err != nil {
+		log.Printf("receiveFromTransport: discarding envelope '%v': %v", envelopeOf(e).ID, err)
+		c.replyUpgradeFailure(ctx, e)
+		return nil, false
+	}
+	if upgraded != e {
+		rehashUpgraded(e, upgraded)
+		forgetReceivedMergePatch(upgraded)
+	}
+	return upgraded, true
+}
+
+// replyUpgradeFailure replies a received envelope that could not be upgraded with a failure, if it expects one.
+func (c *channel) replyUpgradeFailure(ctx context.Context, e envelope) {
+	reason := NewReason(ReasonCodeValidationError, "The document could not be converted to the session version")
+	var err error
+	switch e := c.mapReceived(e).(type) {
+	case *Message:
+		if e.ID != "" {
+			err = c.SendNotification(ctx, e.FailedNotification(reason))
+		}
+	case *RequestCommand:
+		if e.ID != "" {
+			err = c.SendResponseCommand(ctx, e.FailureResponse(reason))
+		}
+	}
+	if err != nil {
+		log.Printf("receiveFromTransport: %v", err)
+	}
+}
+
+// rehashUpgraded replaces the content hash of the upgraded envelope by the hash of its document, if the hash
+// matches the document of the received envelope.
+func rehashUpgraded(received envelope, upgraded envelope) {
+	env := envelopeOf(received)
+	if _, ok := env.Metadata[MetadataKeyContentHash]; !ok {
+		return
+	}
+	if err := verifyContentHash(env, documentOf(received)); err != nil {
+		return
+	}
+	hash, err := ContentHash(documentOf(upgraded))
+	if err != nil {
+		return
+	}
+	target := envelopeOf(upgraded)
+	target.Metadata = maps.Clone(target.Metadata)
+	target.Metadata[MetadataKeyContentHash] = hash
+	target.annotations = maps.Clone(target.annotations)
+	target.RemoveAnnotation(receivedContentHashKey{})
+}
+
+// forgetReceivedMergePatch removes the received merge command resource JSON from the upgraded envelope, since it
+// doesn't match the upgraded resource anymore.
+func forgetReceivedMergePatch(upgraded envelope) {
+	env := envelopeOf(upgraded)
+	if _, ok := env.Annotation(receivedMergePatchKey{}); !ok {
+		return
+	}
+	env.annotations = maps.Clone(env.annotations)
+	env.RemoveAnnotation(receivedMergePatchKey{})
+}
+
+// documentOf returns the message content or the command resource of the envelope.
+func documentOf(e envelope) Document {
+	switch e := e.(type) {
+	case *Message:
+		return e.Content
+	case *RequestCommand:
+		return e.Resource
+	case *ResponseCommand:
+		return e.Resource
+	default:
+		return nil
+	}
+}
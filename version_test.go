@@ -0,0 +1,257 @@
+package lime
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+	"maps"
+	"testing"
+	"time"
+)
+
+// createPriorityShim creates a shim for the version 2, which introduced the required 'priority' field.
+func createPriorityShim() VersionShim {
+	return VersionShim{
+		Version:   2,
+		MediaType: MediaTypeApplicationJson(),
+		Upgrade: func(d Document) (Document, error) {
+			doc := maps.Clone(*d.(*JsonDocument))
+			if _, ok := doc["priority"]; !ok {
+				doc["priority"] = "normal"
+			}
+			return &doc, nil
+		},
+		Downgrade: func(d Document) (Document, error) {
+			doc := maps.Clone(*d.(*JsonDocument))
+			delete(doc, "priority")
+			return &doc, nil
+		},
+	}
+}
+
+func createJsonMessage(doc JsonDocument) *Message {
+	msg := createMessage()
+	msg.Content = &doc
+	msg.Type = MediaTypeApplicationJson()
+	return msg
+}
+
+func TestChannel_SetVersion_Upgrade(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.SetVersion(2, createPriorityShim())
+	c.selectVersion(&Session{})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	if err := client.Send(ctx, createJsonMessage(JsonDocument{"text": "Hello"})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case actual, ok := <-c.MsgChan():
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, 0, c.SessionVersion())
+		assert.Equal(t, &JsonDocument{"text": "Hello", "priority": "normal"}, actual.Content)
+	}
+}
+
+func TestChannel_SetVersion_UpgradeFailure(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	shim := createPriorityShim()
+	shim.Upgrade = func(d Document) (Document, error) {
+		return nil, errors.New("invalid document")
+	}
+	c.SetVersion(2, shim)
+	c.selectVersion(&Session{})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	msg := createJsonMessage(JsonDocument{"text": "Hello"})
+	msg.From = Node{Identity: Identity{Name: "andreb", Domain: "limeprotocol.org"}, Instance: "home"}
+
+	// Act
+	if err := client.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert
+	actual, err := client.Receive(ctx)
+	assert.NoError(t, err)
+	if not, ok := actual.(*Notification); assert.True(t, ok) {
+		assert.Equal(t, msg.ID, not.ID)
+		assert.Equal(t, msg.From, not.To)
+		assert.Equal(t, NotificationEventFailed, not.Event)
+		assert.Equal(t, ReasonCodeValidationError, not.Reason.Code)
+	}
+}
+
+func TestChannel_SetVersion_UpgradeFailureCommand(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	shim := createPriorityShim()
+	shim.Upgrade = func(d Document) (Document, error) {
+		return nil, errors.New("invalid document")
+	}
+	c.SetVersion(2, shim)
+	c.selectVersion(&Session{})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	cmd := &RequestCommand{}
+	cmd.ID = NewEnvelopeID()
+	cmd.Method = CommandMethodSet
+	cmd.SetURIString("/documents")
+	cmd.SetResource(&JsonDocument{"text": "Hello"})
+
+	// Act
+	if err := client.Send(ctx, cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert
+	actual, err := client.Receive(ctx)
+	assert.NoError(t, err)
+	if resp, ok := actual.(*ResponseCommand); assert.True(t, ok) {
+		assert.Equal(t, cmd.ID, resp.ID)
+		assert.Equal(t, CommandStatusFailure, resp.Status)
+		assert.Equal(t, ReasonCodeValidationError, resp.Reason.Code)
+	}
+}
+
+func TestChannel_SetVersion_UpgradeContentHash(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(server, 1)
+	defer silentClose(c)
+	c.SetVersion(2, createPriorityShim())
+	c.selectVersion(&Session{})
+	c.setState(SessionStateEstablished)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	valid := createJsonMessage(JsonDocument{"text": "Hello"})
+	if err := valid.SetContentHash(); err != nil {
+		t.Fatal(err)
+	}
+	tampered := createJsonMessage(JsonDocument{"text": "Hello"})
+	tampered.ID = NewEnvelopeID()
+	tampered.SetMetadataKeyValue(MetadataKeyContentHash, valid.Metadata[MetadataKeyContentHash])
+	tampered.Content = &JsonDocument{"text": "Bye"}
+
+	// Act
+	_ = client.Send(ctx, valid)
+	_ = client.Send(ctx, tampered)
+
+	// Assert
+	for _, expected := range []bool{true, false} {
+		select {
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		case actual := <-c.MsgChan():
+			assert.Equal(t, "normal", (*actual.Content.(*JsonDocument))["priority"])
+			assert.Equal(t, expected, actual.VerifyContentHash() == nil)
+		}
+	}
+}
+
+func TestChannel_SetVersion_Downgrade(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetVersion(3, createPriorityShim())
+	c.selectVersion(&Session{Envelope: Envelope{Metadata: map[string]string{MetadataKeySessionVersion: "1"}}})
+	c.setState(SessionStateEstablished)
+	msg := createJsonMessage(JsonDocument{"text": "Hello", "priority": "high"})
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.SessionVersion())
+	assert.Equal(t, &JsonDocument{"text": "Hello", "priority": "high"}, msg.Content)
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, &JsonDocument{"text": "Hello"}, actual.(*Message).Content)
+}
+
+func TestChannel_SetVersion_SameVersion(t *testing.T) {
+	// Arrange
+	client, server := newInProcessTransportPair("localhost", 1)
+	c := newChannel(client, 1)
+	defer silentClose(c)
+	c.SetVersion(2, createPriorityShim())
+	c.selectVersion(&Session{Envelope: Envelope{Metadata: map[string]string{MetadataKeySessionVersion: "5"}}})
+	c.setState(SessionStateEstablished)
+	msg := createJsonMessage(JsonDocument{"text": "Hello", "priority": "high"})
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := c.SendMessage(ctx, msg)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, c.SessionVersion())
+	actual, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.Content, actual.(*Message).Content)
+}
+
+func TestServer_Version_OldClient(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := InProcessAddr("version-old-client")
+	contents := make(chan Document, 1)
+	versions := make(chan int, 1)
+	srv := NewServerBuilder().
+		ListenInProcess(addr).
+		EnableGuestAuthentication().
+		Version(2, createPriorityShim()).
+		Established(func(sessionID string, c *ServerChannel) {
+			versions <- c.SessionVersion()
+		}).
+		MessagesHandlerFunc(func(ctx context.Context, msg *Message, s Sender) error {
+			contents <- msg.Content
+			return nil
+		}).
+		Build()
+	acceptAnyGuest(srv)
+	startServer(t, srv)
+	defer silentClose(srv)
+	client := establishInProcGuestSession(t, ctx, addr, "golang")
+	defer silentClose(client)
+
+	// Act
+	err := client.SendMessage(ctx, createJsonMessage(JsonDocument{"text": "Hello"}))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0, <-versions)
+	select {
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	case content := <-contents:
+		assert.Equal(t, &JsonDocument{"text": "Hello", "priority": "normal"}, content)
+	}
+}
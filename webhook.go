@@ -0,0 +1,360 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader is the HTTP header with the HMAC-SHA256 signature of the request body, in the
+	// 'sha256=<hex>' format. It is sent in the webhook deliveries and verified in the outbound envelope requests.
+	WebhookSignatureHeader = "X-Lime-Signature"
+	// WebhookEnvelopeIDHeader is the HTTP header with the ID of the delivered envelope or session event, which allows
+	// the endpoint to discard the duplicated deliveries caused by retries.
+	WebhookEnvelopeIDHeader = "X-Lime-Envelope-Id"
+)
+
+var (
+	// ErrWebhookBridgeClosed is returned when an envelope is delivered to a closed WebhookBridge.
+	ErrWebhookBridgeClosed = errors.New("webhook bridge closed")
+	// ErrWebhookSaturated is returned when a delivery that cannot wait is discarded, since the concurrency limit of
+	// the WebhookBridge is reached.
+	ErrWebhookSaturated = errors.New("webhook deliveries saturated")
+)
+
+// WebhookConfig defines the delivery options of a WebhookBridge.
+type WebhookConfig struct {
+	// URL is the endpoint where the incoming envelopes are posted.
+	URL string
+	// Secret is the key for the HMAC-SHA256 signatures of the requests. If empty, the deliveries are not signed and
+	// the outbound requests are refused, since anyone could send envelopes through the bridge.
+	Secret []byte
+	// MaxRetries is the maximum number of delivery retries after the first attempt.
+	MaxRetries int
+	// RetryInterval is the interval before the first retry, which doubles on each subsequent one.
+	RetryInterval time.Duration
+	// RetryBackoff defines the intervals between the retries, replacing the RetryInterval doubling if defined.
+	RetryBackoff BackoffStrategy
+	// Clock measures the intervals between the retries. If not defined, the SystemClock is used.
+	Clock Clock
+	// MaxConcurrency is the maximum number of simultaneous deliveries. When reached, the envelope handlers block
+	// until a delivery completes, applying backpressure to the channel, while the session events are discarded.
+	MaxConcurrency int
+	// MaxRequestSize is the maximum body size of the outbound envelope requests.
+	MaxRequestSize int64
+	// CanonicalJSON makes the deliveries use the canonical JSON encoding, and the signatures of the outbound
+	// requests be verified over the canonical form of the body, so the signatures computed by the implementations
+	// of other languages validate regardless of their field order and whitespace.
+	CanonicalJSON bool
+	// HTTPClient is the client used for the deliveries, which should define a timeout. If nil, http.DefaultClient is
+	// used.
+	HTTPClient *http.Client
+}
+
+// NewWebhookConfig creates a new instance of WebhookConfig with the default values.
+func NewWebhookConfig(url string) *WebhookConfig {
+	return &WebhookConfig{
+		URL:            url,
+		MaxRetries:     3,
+		RetryInterval:  time.Second,
+		MaxConcurrency: 16,
+		MaxRequestSize: 1 << 20,
+	}
+}
+
+// WebhookSender defines a service for sending the outbound envelopes received by the WebhookBridge HTTP API.
+// Both the Client and the channels implement it.
+type WebhookSender interface {
+	MessageSender
+	NotificationSender
+}
+
+// WebhookBridge connects LIME nodes to HTTP consumers, like serverless functions.
+// It posts the incoming messages and notifications to a webhook and accepts outbound envelopes through its HTTP
+// handler, which can be mounted in any http.ServeMux.
+type WebhookBridge struct {
+	config *WebhookConfig
+	sender WebhookSender
+	client *http.Client
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool // closed is set by Close before waiting for the deliveries, guarded by mu
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewWebhookBridge creates a new WebhookBridge with the specified configuration.
+// The sender is used for the outbound envelopes and can be nil if the HTTP API is not used.
+func NewWebhookBridge(config *WebhookConfig, sender WebhookSender) *WebhookBridge {
+	if config == nil {
+		panic("nil config")
+	}
+	if config.URL == "" {
+		panic("empty webhook url")
+	}
+	if config.MaxConcurrency <= 0 {
+		panic("the max concurrency should be positive")
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookBridge{
+		config: config,
+		sender: sender,
+		client: client,
+		sem:    make(chan struct{}, config.MaxConcurrency),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// MessageHandler returns a MessageHandler that delivers all the received messages to the webhook.
+func (b *WebhookBridge) MessageHandler() MessageHandler {
+	return &messageHandler{
+		handlerFunc: func(ctx context.Context, msg *Message, _ Sender) error {
+			return b.deliver(ctx, msg.ID, msg)
+		},
+	}
+}
+
+// NotificationHandler returns a NotificationHandler that delivers all the received notifications to the webhook.
+func (b *WebhookBridge) NotificationHandler() NotificationHandler {
+	return &notificationHandler{
+		handlerFunc: func(ctx context.Context, not *Notification) error {
+			return b.deliver(ctx, not.ID, not)
+		},
+	}
+}
+
+// Close cancels the pending retries and waits for the in-flight deliveries.
+func (b *WebhookBridge) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.cancel()
+	b.wg.Wait()
+	return nil
+}
+
+// deliver posts the value, usually an envelope, asynchronously, blocking only while the concurrency limit is
+// reached.
+func (b *WebhookBridge) deliver(ctx context.Context, id string, v any) error {
+	body, err := b.marshal(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.ctx.Done():
+		return ErrWebhookBridgeClosed
+	case b.sem <- struct{}{}:
+	}
+	return b.start(id, body)
+}
+
+// tryDeliver posts the value asynchronously like deliver, but without waiting, returning ErrWebhookSaturated if the
+// concurrency limit is reached.
+func (b *WebhookBridge) tryDeliver(id string, v any) error {
+	body, err := b.marshal(v)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-b.ctx.Done():
+		return ErrWebhookBridgeClosed
+	case b.sem <- struct{}{}:
+	default:
+		return ErrWebhookSaturated
+	}
+	return b.start(id, body)
+}
+
+func (b *WebhookBridge) marshal(v any) ([]byte, error) {
+	marshal := json.Marshal
+	if b.config.CanonicalJSON {
+		marshal = MarshalCanonical
+	}
+	body, err := marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: %w", err)
+	}
+	if b.ctx.Err() != nil {
+		return nil, ErrWebhookBridgeClosed
+	}
+	return body, nil
+}
+
+// start posts the body in a new goroutine, which releases the concurrency slot acquired by the caller.
+func (b *WebhookBridge) start(id string, body []byte) error {
+	// The delivery is added to the wait group only if the bridge is not closed, since Close may be already waiting
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		<-b.sem
+		return ErrWebhookBridgeClosed
+	}
+	b.wg.Add(1)
+	b.mu.Unlock()
+	goLabeled(b.ctx, "webhook.delivery", func(context.Context) {
+		defer func() {
+			<-b.sem
+			b.wg.Done()
+		}()
+		if err := b.post(id, body); err != nil {
+			log.Printf("webhook: delivery of '%v' failed: %v", id, err)
+		}
+	})
+	return nil
+}
+
+func (b *WebhookBridge) post(id string, body []byte) error {
+	backoff := b.config.RetryBackoff
+	if backoff == nil {
+		backoff = ExponentialBackoff{Initial: b.config.RetryInterval}
+	}
+	var interval time.Duration
+	for attempt := 0; ; attempt++ {
+		retry, err := b.postAttempt(id, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= b.config.MaxRetries {
+			return err
+		}
+
+		interval = backoff.Backoff(attempt, interval)
+		if sleep(b.ctx, clockOrSystem(b.config.Clock), interval) != nil {
+			return err
+		}
+	}
+}
+
+// postAttempt sends the request to the webhook, returning if a failure is transient and should be retried.
+func (b *WebhookBridge) postAttempt(id string, body []byte) (bool, error) {
+	// The in-flight requests are not canceled on close, only the retries
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, b.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if id != "" {
+		req.Header.Set(WebhookEnvelopeIDHeader, id)
+	}
+	if len(b.config.Secret) != 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(b.config.Secret, body))
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("unexpected status %v", resp.Status)
+}
+
+// ServeHTTP accepts outbound messages and notifications posted as JSON envelopes, sending them through the bridge
+// sender. The requests are rejected if the signature header doesn't match the body, or if no secret is configured.
+func (b *WebhookBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if b.sender == nil {
+		http.Error(w, "outbound envelopes are not supported", http.StatusNotImplemented)
+		return
+	}
+	if len(b.config.Secret) == 0 {
+		http.Error(w, "outbound envelopes require a secret", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, b.config.MaxRequestSize))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	signed := body
+	if b.config.CanonicalJSON {
+		if signed, err = CanonicalizeJSON(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if !VerifyWebhookSignature(b.config.Secret, signed, r.Header.Get(WebhookSignatureHeader)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var raw rawEnvelope
+	if err := json.Unmarshal(body, &raw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	e, err := raw.toEnvelope()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch e := e.(type) {
+	case *Message:
+		err = b.sender.SendMessage(r.Context(), e)
+	case *Notification:
+		err = b.sender.SendNotification(r.Context(), e)
+	default:
+		http.Error(w, "only messages and notifications are supported", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// SignWebhookPayload returns the HMAC-SHA256 signature of the payload in the WebhookSignatureHeader format.
+func SignWebhookPayload(secret []byte, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks if the signature, in the WebhookSignatureHeader format, matches the payload.
+func VerifyWebhookSignature(secret []byte, payload []byte, signature string) bool {
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	actual, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hmac.Equal(actual, mac.Sum(nil))
+}
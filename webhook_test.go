@@ -0,0 +1,257 @@
+package lime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// messageRecorder is a WebhookSender that keeps the sent envelopes.
+type messageRecorder struct {
+	mu            sync.Mutex
+	messages      []*Message
+	notifications []*Notification
+}
+
+func (r *messageRecorder) SendMessage(_ context.Context, msg *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *messageRecorder) SendNotification(_ context.Context, not *Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, not)
+	return nil
+}
+
+func TestWebhookBridge_MessageHandler(t *testing.T) {
+	// Arrange
+	secret := []byte("s3cr3t")
+	bodies := make(chan []byte, 1)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifyWebhookSignature(secret, body, r.Header.Get(WebhookSignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		bodies <- body
+	}))
+	defer endpoint.Close()
+	config := NewWebhookConfig(endpoint.URL)
+	config.Secret = secret
+	bridge := NewWebhookBridge(config, nil)
+	defer silentClose(bridge)
+	msg := createMessage()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := bridge.MessageHandler().Handle(ctx, msg, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "webhook delivery timeout")
+	case body := <-bodies:
+		var actual Message
+		assert.NoError(t, json.Unmarshal(body, &actual))
+		assert.Equal(t, msg, &actual)
+	}
+}
+
+func TestWebhookBridge_NotificationHandler_Retry(t *testing.T) {
+	// Arrange
+	var attempts atomic.Int32
+	ids := make(chan string, 1)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		ids <- r.Header.Get(WebhookEnvelopeIDHeader)
+	}))
+	defer endpoint.Close()
+	config := NewWebhookConfig(endpoint.URL)
+	config.RetryInterval = time.Millisecond
+	bridge := NewWebhookBridge(config, nil)
+	defer silentClose(bridge)
+	not := createNotification()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := bridge.NotificationHandler().Handle(ctx, not)
+
+	// Assert
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		assert.FailNow(t, "webhook delivery timeout")
+	case id := <-ids:
+		assert.Equal(t, not.ID, id)
+	}
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestWebhookBridge_NotificationHandler_ClientErrorNotRetried(t *testing.T) {
+	// Arrange
+	var attempts atomic.Int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer endpoint.Close()
+	config := NewWebhookConfig(endpoint.URL)
+	config.RetryInterval = time.Millisecond
+	bridge := NewWebhookBridge(config, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := bridge.NotificationHandler().Handle(ctx, createNotification())
+	_ = bridge.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestWebhookBridge_Closed(t *testing.T) {
+	// Arrange
+	bridge := NewWebhookBridge(NewWebhookConfig("http://localhost"), nil)
+	_ = bridge.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	// Act
+	err := bridge.NotificationHandler().Handle(ctx, createNotification())
+
+	// Assert
+	assert.ErrorIs(t, err, ErrWebhookBridgeClosed)
+}
+
+func TestWebhookBridge_ServeHTTP(t *testing.T) {
+	// Arrange
+	secret := []byte("s3cr3t")
+	config := NewWebhookConfig("http://localhost")
+	config.Secret = secret
+	sender := &messageRecorder{}
+	bridge := NewWebhookBridge(config, sender)
+	defer silentClose(bridge)
+	msg := createMessage()
+	body, _ := json.Marshal(msg)
+	req := httptest.NewRequest(http.MethodPost, "/envelopes", bytes.NewReader(body))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, body))
+	rec := httptest.NewRecorder()
+
+	// Act
+	bridge.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, []*Message{msg}, sender.messages)
+}
+
+func TestWebhookBridge_ServeHTTP_InvalidSignature(t *testing.T) {
+	// Arrange
+	config := NewWebhookConfig("http://localhost")
+	config.Secret = []byte("s3cr3t")
+	sender := &messageRecorder{}
+	bridge := NewWebhookBridge(config, sender)
+	defer silentClose(bridge)
+	body, _ := json.Marshal(createNotification())
+	req := httptest.NewRequest(http.MethodPost, "/envelopes", bytes.NewReader(body))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload([]byte("other"), body))
+	rec := httptest.NewRecorder()
+
+	// Act
+	bridge.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, sender.notifications)
+}
+
+func TestWebhookBridge_ServeHTTP_WithoutSecret(t *testing.T) {
+	// Arrange
+	sender := &messageRecorder{}
+	bridge := NewWebhookBridge(NewWebhookConfig("http://localhost"), sender)
+	defer silentClose(bridge)
+	body, _ := json.Marshal(createMessage())
+	req := httptest.NewRequest(http.MethodPost, "/envelopes", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	// Act
+	bridge.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, sender.messages)
+}
+
+func TestWebhookBridge_Close_ConcurrentDeliveries(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	bridge := NewWebhookBridge(NewWebhookConfig(server.URL), nil)
+	handler := bridge.NotificationHandler()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 16; j++ {
+				if err := handler.Handle(ctx, createNotification()); err != nil {
+					assert.ErrorIs(t, err, ErrWebhookBridgeClosed)
+					return
+				}
+			}
+		}()
+	}
+
+	// Act
+	err := bridge.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	wg.Wait()
+}
+
+func TestWebhookBridge_ServeHTTP_CanonicalSignature(t *testing.T) {
+	// Arrange
+	secret := []byte("s3cr3t")
+	config := NewWebhookConfig("http://localhost")
+	config.Secret = secret
+	config.CanonicalJSON = true
+	sender := &messageRecorder{}
+	bridge := NewWebhookBridge(config, sender)
+	defer silentClose(bridge)
+	body := []byte(`{ "to": "golang@limeprotocol.org", "id": "1", "event": "received" }`)
+	canonical := []byte(`{"event":"received","id":"1","to":"golang@limeprotocol.org"}`)
+	req := httptest.NewRequest(http.MethodPost, "/envelopes", bytes.NewReader(body))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, canonical))
+	rec := httptest.NewRecorder()
+
+	// Act
+	bridge.ServeHTTP(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Len(t, sender.notifications, 1)
+}
@@ -1,17 +1,22 @@
 package lime
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go.uber.org/multierr"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -43,9 +48,14 @@ func DialWebsocket(ctx context.Context, urlStr string, requestHeader http.Header
 }
 
 type websocketTransport struct {
-	conn *websocket.Conn
-	c    SessionCompression
-	e    SessionEncryption
+	conn     *websocket.Conn
+	closed   atomic.Bool // closed is set by Close, which can be concurrent with the Connected checks
+	c        SessionCompression
+	e        SessionEncryption
+	counters transportCounters
+	limit    int64                // limit is the maximum size of the received messages, after the decompression
+	pending  []rawEnvelope        // pending are the envelopes remaining from a received array
+	adaptive *AdaptiveCompression // adaptive decides which envelopes are compressed, if the compression was negotiated
 }
 
 func (t *websocketTransport) Send(ctx context.Context, e envelope) error {
@@ -63,7 +73,7 @@ func (t *websocketTransport) Send(ctx context.Context, e envelope) error {
 
 	errChan := make(chan error)
 	go func() {
-		errChan <- t.conn.WriteJSON(e)
+		errChan <- t.writeJSON(e)
 	}()
 
 	select {
@@ -77,10 +87,109 @@ func (t *websocketTransport) Send(ctx context.Context, e envelope) error {
 		if err != nil {
 			return fmt.Errorf("ws transport: send: %w", err)
 		}
+		t.counters.envelopesEncoded.Add(1)
 		return nil
 	}
 }
 
+// writeJSON is equivalent to the websocket.Conn WriteJSON method, but counting the written bytes.
+func (t *websocketTransport) writeJSON(v any) error {
+	if t.c == SessionCompressionGzip {
+		return t.writeGzip(v)
+	}
+	if t.adaptive != nil {
+		return t.writeAdaptive(v)
+	}
+	w, err := t.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	err1 := json.NewEncoder(&countingWriter{w: w, count: &t.counters.bytesWritten}).Encode(v)
+	err2 := w.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// writeAdaptive encodes the value in memory, for deciding if it should be compressed before writing it.
+func (t *websocketTransport) writeAdaptive(v any) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	t.conn.EnableWriteCompression(t.adaptive.shouldCompress(buf.Bytes(), &t.counters.compression))
+	if err := t.conn.WriteMessage(websocket.TextMessage, buf.Bytes()); err != nil {
+		return err
+	}
+	t.counters.bytesWritten.Add(int64(buf.Len()))
+	return nil
+}
+
+// writeGzip writes the value compressed with gzip in a binary message, after the session compression is
+// negotiated. If the adaptive compression is defined, the values that should not be compressed are written in text
+// messages, as before the negotiation.
+func (t *websocketTransport) writeGzip(v any) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	// The message compression of the connection is not used for the compressed payloads
+	t.conn.EnableWriteCompression(false)
+	if t.adaptive != nil && !t.adaptive.shouldCompress(buf.Bytes(), &t.counters.compression) {
+		if err := t.conn.WriteMessage(websocket.TextMessage, buf.Bytes()); err != nil {
+			return err
+		}
+		t.counters.bytesWritten.Add(int64(buf.Len()))
+		return nil
+	}
+
+	w, err := t.conn.NextWriter(websocket.BinaryMessage)
+	if err != nil {
+		return err
+	}
+	gz := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(gz)
+	gz.Reset(&countingWriter{w: w, count: &t.counters.bytesWritten})
+	_, err1 := gz.Write(buf.Bytes())
+	err2 := gz.Close()
+	err3 := w.Close()
+	return multierr.Combine(err1, err2, err3)
+}
+
+// readJSON is equivalent to the websocket.Conn ReadJSON method, but counting the read bytes. The binary messages
+// are decompressed with gzip, while the text messages are plain JSON, so the peer can send both after the
+// compression is negotiated. The binary messages are refused if the compression was not negotiated.
+func (t *websocketTransport) readJSON(v any) error {
+	mt, r, err := t.conn.NextReader()
+	if err != nil {
+		return err
+	}
+	r = &countingReader{r: r, count: &t.counters.bytesRead}
+	if mt == websocket.BinaryMessage {
+		if t.c != SessionCompressionGzip {
+			return errors.New("binary message without the gzip compression")
+		}
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("gzip: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	limit := t.limit
+	if limit <= 0 {
+		limit = DefaultReadLimit
+	}
+	// The limit applies to the decompressed data, since a small compressed message can expand to gigabytes
+	err = json.NewDecoder(&limitedReader{r: r, n: limit}).Decode(v)
+	if errors.Is(err, io.EOF) {
+		// One value is expected in the message, so the EOF means that the message is empty
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
 func (t *websocketTransport) Receive(ctx context.Context) (envelope, error) {
 	if ctx == nil {
 		panic("nil context")
@@ -90,15 +199,23 @@ func (t *websocketTransport) Receive(ctx context.Context) (envelope, error) {
 		return nil, err
 	}
 
-	rawChan := make(chan rawEnvelope)
+	if len(t.pending) != 0 {
+		// The remaining envelopes of an array are received without starting a read from the connection
+		t.counters.bufferedReceives.Add(1)
+		return t.nextPending()
+	}
+
+	rawChan := make(chan rawEnvelopeBatch)
 	errChan := make(chan error)
 	go func() {
-		var raw rawEnvelope
-		if err := t.conn.ReadJSON(&raw); err != nil {
-			errChan <- err
-		} else {
-			rawChan <- raw
+		var batch rawEnvelopeBatch
+		for len(batch) == 0 {
+			if err := t.readJSON(&batch); err != nil {
+				errChan <- err
+				return
+			}
 		}
+		rawChan <- batch
 	}()
 
 	select {
@@ -113,24 +230,61 @@ func (t *websocketTransport) Receive(ctx context.Context) (envelope, error) {
 		}
 		return nil, fmt.Errorf("ws transport: receive: %w", ctx.Err())
 	case err := <-errChan:
+		if malformedErr := malformedEnvelope(err); malformedErr != nil {
+			t.counters.decodeErrors.Add(1)
+			return nil, malformedErr
+		}
+		t.counters.receiveError(err)
 		return nil, fmt.Errorf("ws transport: receive: %w", err)
-	case raw := <-rawChan:
-		return raw.toEnvelope()
+	case batch := <-rawChan:
+		t.pending = batch
+		return t.nextPending()
+	}
+}
+
+// nextPending returns the first envelope of the last received batch.
+func (t *websocketTransport) nextPending() (envelope, error) {
+	raw := t.pending[0]
+	t.pending = t.pending[1:]
+	e, err := raw.toEnvelope()
+	if err != nil {
+		t.counters.decodeErrors.Add(1)
+		return nil, raw.populateError(err)
 	}
+	t.counters.envelopeDecoded(e, raw.size)
+	return e, nil
+}
+
+// Buffered returns the number of remaining envelopes of a received array. The WebSocket messages are read one at a
+// time, so the next messages are not buffered.
+func (t *websocketTransport) Buffered() int {
+	return len(t.pending)
+}
+
+func (t *websocketTransport) Stats() TransportStats {
+	return t.counters.snapshot()
+}
+
+func (t *websocketTransport) annotateReceivedSizes() {
+	t.counters.annotateSizes.Store(true)
 }
 
 func (t *websocketTransport) Close() error {
 	if err := t.ensureOpen(); err != nil {
 		return err
 	}
+	if !t.closed.CompareAndSwap(false, true) {
+		return errors.New("transport is not open")
+	}
 
-	err := t.conn.Close()
-	t.conn = nil
-	return err
+	return t.conn.Close()
 }
 
+// SupportedCompression returns the none and gzip options. With the gzip compression, the envelopes are sent in
+// compressed binary messages instead of text messages, which reduces the size of the large documents, like media
+// collections, even when the WebSocket message compression is not available.
 func (t *websocketTransport) SupportedCompression() []SessionCompression {
-	return []SessionCompression{t.c}
+	return []SessionCompression{SessionCompressionNone, SessionCompressionGzip}
 }
 
 func (t *websocketTransport) Compression() SessionCompression {
@@ -138,9 +292,10 @@ func (t *websocketTransport) Compression() SessionCompression {
 }
 
 func (t *websocketTransport) SetCompression(_ context.Context, c SessionCompression) error {
-	if c != t.c {
-		return errors.New("compression cannot be changed")
+	if c != SessionCompressionNone && c != SessionCompressionGzip {
+		return fmt.Errorf("compression '%v' is not supported", c)
 	}
+	t.c = c
 	return nil
 }
 
@@ -160,7 +315,7 @@ func (t *websocketTransport) SetEncryption(_ context.Context, e SessionEncryptio
 }
 
 func (t *websocketTransport) Connected() bool {
-	return t.conn != nil
+	return t.conn != nil && !t.closed.Load()
 }
 
 func (t *websocketTransport) LocalAddr() net.Addr {
@@ -172,7 +327,7 @@ func (t *websocketTransport) RemoteAddr() net.Addr {
 }
 
 func (t *websocketTransport) ensureOpen() error {
-	if t.conn == nil {
+	if !t.Connected() {
 		return errors.New("transport is not open")
 	}
 
@@ -180,10 +335,16 @@ func (t *websocketTransport) ensureOpen() error {
 }
 
 type WebsocketConfig struct {
-	TLSConfig         *tls.Config
-	TraceWriter       TraceWriter // TraceWriter sets the trace writer for tracing connection envelopes
+	TLSConfig   *tls.Config
+	TraceWriter TraceWriter // TraceWriter sets the trace writer for tracing connection envelopes
+	// ReadLimit defines the maximum size of a received message, after its decompression. If not defined, the
+	// DefaultReadLimit is used.
+	ReadLimit         int64
 	EnableCompression bool
-	ConnBuffer        int
+	// AdaptiveCompression defines which sent envelopes are compressed when the compression is enabled and
+	// negotiated with the client. If nil, all the envelopes are compressed.
+	AdaptiveCompression *AdaptiveCompression
+	ConnBuffer          int
 
 	// CheckOrigin returns true if the request Origin header is acceptable. If
 	// CheckOrigin is nil, then a safe default is used: return false if the
@@ -193,6 +354,9 @@ type WebsocketConfig struct {
 	// A CheckOrigin function should carefully validate the request origin to
 	// prevent cross-site request forgery.
 	CheckOrigin func(r *http.Request) bool
+
+	// Clock measures the window of the largest received envelopes. If not defined, the SystemClock is used.
+	Clock Clock
 }
 
 type websocketTransportListener struct {
@@ -200,7 +364,7 @@ type websocketTransportListener struct {
 	listener net.Listener
 	srv      *http.Server
 	upgrader *websocket.Upgrader
-	connChan chan *websocket.Conn
+	connChan chan acceptedConn
 	done     chan struct{}
 	mu       sync.RWMutex
 }
@@ -237,9 +401,9 @@ func (l *websocketTransportListener) Listen(ctx context.Context, addr net.Addr)
 		EnableCompression: l.EnableCompression,
 		CheckOrigin:       l.CheckOrigin,
 	}
-	l.connChan = make(chan *websocket.Conn, l.ConnBuffer)
+	l.connChan = make(chan acceptedConn, l.ConnBuffer)
 	l.done = make(chan struct{})
-	go func() {
+	goLabeled(context.Background(), "ws.listener", func(context.Context) {
 		if l.tls() {
 			if err := srv.ServeTLS(listener, "", ""); err != nil && err != net.ErrClosed {
 				log.Printf("ws listen: %v", err)
@@ -249,7 +413,7 @@ func (l *websocketTransportListener) Listen(ctx context.Context, addr net.Addr)
 				log.Printf("ws listen: %v", err)
 			}
 		}
-	}()
+	}, "addr", listener.Addr().String())
 
 	return nil
 }
@@ -268,10 +432,18 @@ func (l *websocketTransportListener) Accept(ctx context.Context) (Transport, err
 		return nil, fmt.Errorf("ws listener: %w", ctx.Err())
 	case <-l.done:
 		return nil, errors.New("ws listener closed")
-	case conn := <-l.connChan:
+	case accepted := <-l.connChan:
+		l.mu.RLock()
+		limit := l.ReadLimit
+		l.mu.RUnlock()
 		ws := &websocketTransport{
-			conn: conn,
-			c:    SessionCompressionNone,
+			conn:     accepted.conn,
+			c:        SessionCompressionNone,
+			limit:    limit,
+			counters: transportCounters{clock: l.Clock},
+		}
+		if accepted.compression {
+			ws.adaptive = l.AdaptiveCompression
 		}
 		if l.tls() {
 			ws.e = SessionEncryptionTLS
@@ -283,6 +455,13 @@ func (l *websocketTransportListener) Accept(ctx context.Context) (Transport, err
 	}
 }
 
+// setReadLimit changes the read limit of the connections accepted after the call.
+func (l *websocketTransportListener) setReadLimit(readLimit int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ReadLimit = readLimit
+}
+
 func (l *websocketTransportListener) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -316,8 +495,36 @@ func (l *websocketTransportListener) ServeHTTP(writer http.ResponseWriter, reque
 		return
 	}
 
+	// The compression is negotiated if enabled by the upgrader and offered by the client
+	compression := l.EnableCompression &&
+		strings.Contains(request.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate")
 	select {
 	case <-l.done:
-	case l.connChan <- conn:
+	case l.connChan <- acceptedConn{conn: conn, compression: compression}:
+	}
+}
+
+// acceptedConn is an upgraded connection waiting for the Accept call.
+type acceptedConn struct {
+	conn        *websocket.Conn
+	compression bool // compression indicates if the per-message compression was negotiated
+}
+
+// limitedReader is like the io.LimitedReader, but fails with ErrReadLimitExceeded when the limit is reached instead of
+// ending the data, so a truncated value is not reported as malformed.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, ErrReadLimitExceeded
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
 	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
 }
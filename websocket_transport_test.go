@@ -1,12 +1,16 @@
 package lime
 
 import (
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/goleak"
 	"net"
+	"strings"
 	"testing"
 	"time"
 )
@@ -340,6 +344,232 @@ func TestWebsocketTransport_Receive_Session(t *testing.T) {
 	assert.Equal(t, s, received)
 }
 
+func TestWebsocketTransport_Receive_EnvelopeArray(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostWSAddr()
+	var transportChan = make(chan Transport, 1)
+	listener := createWebsocketListener(ctx, t, addr, transportChan)
+	defer silentClose(listener)
+	url := fmt.Sprintf("ws://%s", addr)
+	client := createClientWebsocketTransport(ctx, t, url)
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	m := createMessage()
+	n := createNotification()
+	mb, _ := m.MarshalJSON()
+	nb, _ := n.MarshalJSON()
+	frame := "[" + string(mb) + "," + string(nb) + "]"
+	if err := client.(*websocketTransport).conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	e1, err1 := server.Receive(ctx)
+	buffered := server.(BufferedTransport).Buffered()
+	e2, err2 := server.Receive(ctx)
+
+	// Assert
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.Equal(t, m, e1)
+	assert.Equal(t, n, e2)
+	assert.Equal(t, 1, buffered)
+	assert.Equal(t, int64(1), server.(StatsTransport).Stats().BufferedReceives)
+}
+
+func TestWebsocketTransport_SetCompression_Gzip(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostWSAddr()
+	var transportChan = make(chan Transport, 1)
+	listener := createWebsocketListener(ctx, t, addr, transportChan)
+	defer silentClose(listener)
+	url := fmt.Sprintf("ws://%s", addr)
+	client := createClientWebsocketTransport(ctx, t, url)
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+	m := createMessage()
+
+	// Act
+	err1 := client.SetCompression(ctx, SessionCompressionGzip)
+	err2 := server.SetCompression(ctx, SessionCompressionGzip)
+	err3 := client.Send(ctx, m)
+	e, err4 := server.Receive(ctx)
+
+	// Assert
+	assert.Equal(t, []SessionCompression{SessionCompressionNone, SessionCompressionGzip}, client.SupportedCompression())
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+	assert.NoError(t, err4)
+	assert.Equal(t, SessionCompressionGzip, client.Compression())
+	assert.Equal(t, m, e)
+}
+
+func TestWebsocketTransport_Receive_GzipNotNegotiated(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostWSAddr()
+	var transportChan = make(chan Transport, 1)
+	listener := createWebsocketListener(ctx, t, addr, transportChan)
+	defer silentClose(listener)
+	url := fmt.Sprintf("ws://%s", addr)
+	client := createClientWebsocketTransport(ctx, t, url)
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+	if err := client.SetCompression(ctx, SessionCompressionGzip); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Send(ctx, createMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	e, err := server.Receive(ctx)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, e)
+}
+
+func TestWebsocketTransport_Receive_GzipReadLimit(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostWSAddr()
+	var transportChan = make(chan Transport, 1)
+	listener := NewWebsocketTransportListener(&WebsocketConfig{ReadLimit: 1024})
+	if err := listener.Listen(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+	listenTransports(transportChan, listener)
+	defer silentClose(listener)
+	url := fmt.Sprintf("ws://%s", addr)
+	client := createClientWebsocketTransport(ctx, t, url)
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+	_ = client.SetCompression(ctx, SessionCompressionGzip)
+	_ = server.SetCompression(ctx, SessionCompressionGzip)
+	m := createMessage()
+	content := TextDocument(strings.Repeat("a", 64*1024))
+	m.SetContent(&content)
+	if err := client.Send(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	e, err := server.Receive(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReadLimitExceeded)
+	assert.Nil(t, e)
+	assert.Less(t, server.(StatsTransport).Stats().BytesRead, int64(1024))
+}
+
+func TestWebsocketTransport_SetCompression_Unsupported(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostWSAddr()
+	var transportChan = make(chan Transport, 1)
+	listener := createWebsocketListener(ctx, t, addr, transportChan)
+	defer silentClose(listener)
+	url := fmt.Sprintf("ws://%s", addr)
+	client := createClientWebsocketTransport(ctx, t, url)
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+
+	// Act
+	err := client.SetCompression(ctx, SessionCompression("brotli"))
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, SessionCompressionNone, client.Compression())
+}
+
+func TestWebsocketTransport_Send_GzipBinaryFrame(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostWSAddr()
+	var transportChan = make(chan Transport, 1)
+	listener := createWebsocketListener(ctx, t, addr, transportChan)
+	defer silentClose(listener)
+	url := fmt.Sprintf("ws://%s", addr)
+	client := createClientWebsocketTransport(ctx, t, url)
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+	if err := client.SetCompression(ctx, SessionCompressionGzip); err != nil {
+		t.Fatal(err)
+	}
+	m := createMessage()
+
+	// Act
+	err := client.Send(ctx, m)
+
+	// Assert
+	assert.NoError(t, err)
+	mt, r, err := server.(*websocketTransport).conn.NextReader()
+	assert.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, mt)
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received Message
+	assert.NoError(t, json.NewDecoder(gz).Decode(&received))
+	assert.Equal(t, *m, received)
+}
+
+func TestWebsocketTransport_Send_AdaptiveGzipTextFrame(t *testing.T) {
+	// Arrange
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	addr := createLocalhostWSAddr()
+	var transportChan = make(chan Transport, 1)
+	listener := createWebsocketListener(ctx, t, addr, transportChan)
+	defer silentClose(listener)
+	url := fmt.Sprintf("ws://%s", addr)
+	client := createClientWebsocketTransport(ctx, t, url)
+	defer silentClose(client)
+	server := receiveTransport(t, transportChan)
+	defer silentClose(server)
+	client.(*websocketTransport).adaptive = &DefaultAdaptiveCompression
+	if err := client.SetCompression(ctx, SessionCompressionGzip); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.SetCompression(ctx, SessionCompressionGzip); err != nil {
+		t.Fatal(err)
+	}
+	m := createMessage()
+
+	// Act
+	err := client.Send(ctx, m)
+
+	// Assert
+	assert.NoError(t, err)
+	e, err := server.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, m, e)
+	assert.Equal(t, int64(1), client.(StatsTransport).Stats().CompressionSkipped)
+}
+
 func TestWebsocketTransport_Receive_SessionTLS(t *testing.T) {
 	// Arrange
 	defer goleak.VerifyNone(t)